@@ -0,0 +1,258 @@
+// Package archive описывает переносимый формат сессии мониторинга, общий для
+// receiver и offline-service, чтобы запись из одной установки можно было
+// загрузить в другую или отправить в offline-service на повторный анализ.
+//
+// Архив - JSON документ, сжатый gzip. Поля format и version обязательны:
+// читатель отклоняет чужой формат и версии новее, чем он поддерживает.
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	// Format - идентификатор формата архива
+	Format = "fetal-monitory.session"
+
+	// Version - текущая версия формата; увеличивается при несовместимых изменениях
+	Version = 1
+
+	// ContentType и FileExtension - для передачи архива по HTTP
+	ContentType   = "application/gzip"
+	FileExtension = ".ctg.json.gz"
+)
+
+// Источники архива
+const (
+	SourceReceiver       = "receiver"
+	SourceOfflineService = "offline-service"
+)
+
+// Типы событий
+const (
+	EventAcceleration = "acceleration"
+	EventDeceleration = "deceleration"
+	EventContraction  = "contraction"
+)
+
+var (
+	ErrUnsupportedFormat  = errors.New("unsupported archive format")
+	ErrUnsupportedVersion = errors.New("unsupported archive version")
+	ErrInvalid            = errors.New("invalid archive")
+)
+
+// Archive - переносимый снимок сессии
+type Archive struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	Source     string    `json:"source"`
+	ExportedAt time.Time `json:"exported_at"`
+
+	Session Session `json:"session"`
+
+	// RawSignals - сигналы в том виде, в каком их прислало устройство (может быть пусто)
+	RawSignals Signals `json:"raw_signals"`
+	// FilteredSignals - сигналы после фильтрации feature extractor
+	FilteredSignals Signals `json:"filtered_signals"`
+
+	Events      []Event      `json:"events"`
+	TimeSeries  TimeSeries   `json:"time_series"`
+	Metrics     *Metrics     `json:"metrics,omitempty"`
+	Predictions []Prediction `json:"predictions"`
+}
+
+// Session - описание сессии и метаданные пациента
+type Session struct {
+	ID              string                 `json:"id"`
+	Status          string                 `json:"status"`
+	StartedAt       time.Time              `json:"started_at"`
+	StoppedAt       *time.Time             `json:"stopped_at,omitempty"`
+	SavedAt         *time.Time             `json:"saved_at,omitempty"`
+	TotalDurationMs int64                  `json:"total_duration_ms"`
+	TotalDataPoints int64                  `json:"total_data_points"`
+	PatientID       string                 `json:"patient_id,omitempty"`
+	DoctorID        string                 `json:"doctor_id,omitempty"`
+	FacilityID      string                 `json:"facility_id,omitempty"`
+	Notes           string                 `json:"notes,omitempty"`
+	CreatedFrom     string                 `json:"created_from,omitempty"`
+	CustomData      map[string]interface{} `json:"custom_data,omitempty"`
+}
+
+// Signal - сигнал в колоночном виде (как в gRPC MetricRecord)
+type Signal struct {
+	TimeSec []float64 `json:"time_sec"`
+	Value   []float64 `json:"value"`
+}
+
+// Len возвращает количество точек сигнала
+func (s Signal) Len() int {
+	return len(s.TimeSec)
+}
+
+// Signals - ЧСС плода и маточная активность
+type Signals struct {
+	// T0Ms - абсолютное время (Unix, мс), от которого отсчитывается TimeSec;
+	// 0 - время отсчитывается от session.started_at
+	T0Ms int64  `json:"t0_ms,omitempty"`
+	FHR  Signal `json:"fhr"`
+	UC   Signal `json:"uc"`
+}
+
+// BaseMs возвращает абсолютное время (Unix, мс) нулевой точки сигналов
+func (s Signals) BaseMs(startedAt time.Time) int64 {
+	if s.T0Ms != 0 {
+		return s.T0Ms
+	}
+	return startedAt.UnixMilli()
+}
+
+// Event - событие, найденное feature extractor.
+// Start и End - индексы отсчетов сигнала (4 Гц), Duration - секунды
+type Event struct {
+	Type      string  `json:"type"`
+	Start     float64 `json:"start"`
+	End       float64 `json:"end"`
+	Duration  float64 `json:"duration"`
+	Amplitude float64 `json:"amplitude"`
+	IsLate    bool    `json:"is_late,omitempty"`
+}
+
+// Series - значения по окнам фиксированной длительности
+type Series struct {
+	WindowSec float64   `json:"window_sec"`
+	Values    []float64 `json:"values"`
+}
+
+// TimeSeries - кривые вариабельности
+type TimeSeries struct {
+	STV Series `json:"stv"`
+	LTV Series `json:"ltv"`
+}
+
+// Metrics - сводные показатели на момент экспорта
+type Metrics struct {
+	STV                   float64   `json:"stv"`
+	LTV                   float64   `json:"ltv"`
+	BaselineHeartRate     float64   `json:"baseline_heart_rate"`
+	TotalAccelerations    int64     `json:"total_accelerations"`
+	TotalDecelerations    int64     `json:"total_decelerations"`
+	LateDecelerations     int64     `json:"late_decelerations"`
+	LateDecelerationRatio float64   `json:"late_deceleration_ratio"`
+	TotalContractions     int64     `json:"total_contractions"`
+	AccelDecelRatio       float64   `json:"accel_decel_ratio"`
+	STVTrend              float64   `json:"stv_trend"`
+	BPMTrend              float64   `json:"bpm_trend"`
+	DataPoints            int64     `json:"data_points"`
+	TimeSpanSec           float64   `json:"time_span_sec"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+// Prediction - предсказание ML модели
+type Prediction struct {
+	At    time.Time `json:"at"`
+	Value float64   `json:"value"`
+}
+
+// New создает пустой архив текущей версии
+func New(source string) *Archive {
+	return &Archive{
+		Format:     Format,
+		Version:    Version,
+		Source:     source,
+		ExportedAt: time.Now().UTC(),
+	}
+}
+
+// LastPrediction возвращает последнее по времени предсказание
+func (a *Archive) LastPrediction() (Prediction, bool) {
+	if len(a.Predictions) == 0 {
+		return Prediction{}, false
+	}
+	last := a.Predictions[0]
+	for _, p := range a.Predictions[1:] {
+		if !p.At.Before(last.At) {
+			last = p
+		}
+	}
+	return last, true
+}
+
+// Validate проверяет формат, версию и согласованность сигналов
+func (a *Archive) Validate() error {
+	if a.Format != Format {
+		return fmt.Errorf("%w: %q", ErrUnsupportedFormat, a.Format)
+	}
+	if a.Version < 1 || a.Version > Version {
+		return fmt.Errorf("%w: %d (supported up to %d)", ErrUnsupportedVersion, a.Version, Version)
+	}
+	if a.Session.ID == "" {
+		return fmt.Errorf("%w: session id is empty", ErrInvalid)
+	}
+
+	signals := map[string]Signal{
+		"raw fhr":      a.RawSignals.FHR,
+		"raw uc":       a.RawSignals.UC,
+		"filtered fhr": a.FilteredSignals.FHR,
+		"filtered uc":  a.FilteredSignals.UC,
+	}
+	for name, s := range signals {
+		if len(s.TimeSec) != len(s.Value) {
+			return fmt.Errorf("%w: %s signal has %d timestamps and %d values",
+				ErrInvalid, name, len(s.TimeSec), len(s.Value))
+		}
+	}
+
+	for i, e := range a.Events {
+		switch e.Type {
+		case EventAcceleration, EventDeceleration, EventContraction:
+		default:
+			return fmt.Errorf("%w: event %d has unknown type %q", ErrInvalid, i, e.Type)
+		}
+	}
+
+	return nil
+}
+
+// Write записывает архив в w (gzip JSON)
+func Write(w io.Writer, a *Archive) error {
+	gz := gzip.NewWriter(w)
+	if err := json.NewEncoder(gz).Encode(a); err != nil {
+		gz.Close()
+		return fmt.Errorf("failed to encode archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to compress archive: %w", err)
+	}
+	return nil
+}
+
+// Read читает и проверяет архив. Принимает как gzip, так и несжатый JSON
+func Read(r io.Reader) (*Archive, error) {
+	br := bufio.NewReader(r)
+
+	var src io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		defer gz.Close()
+		src = gz
+	}
+
+	var a Archive
+	if err := json.NewDecoder(src).Decode(&a); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
@@ -0,0 +1,105 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func sampleArchive() *Archive {
+	a := New(SourceReceiver)
+	a.Session = Session{
+		ID:        "session-1",
+		Status:    "SAVED",
+		StartedAt: time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC),
+		PatientID: "P-42",
+	}
+	a.FilteredSignals = Signals{
+		FHR: Signal{TimeSec: []float64{0, 0.25, 0.5}, Value: []float64{140, 141, 139}},
+		UC:  Signal{TimeSec: []float64{0, 0.25, 0.5}, Value: []float64{10, 12, 11}},
+	}
+	a.Events = []Event{{Type: EventDeceleration, Start: 4, End: 80, Duration: 19, Amplitude: 20, IsLate: true}}
+	a.TimeSeries.STV = Series{WindowSec: 60, Values: []float64{5.1, 5.3}}
+	a.Predictions = []Prediction{
+		{At: a.Session.StartedAt.Add(time.Minute), Value: 0.4},
+		{At: a.Session.StartedAt.Add(2 * time.Minute), Value: 0.2},
+	}
+	return a
+}
+
+func TestWriteRead_RoundTrip(t *testing.T) {
+	want := sampleArchive()
+
+	var buf bytes.Buffer
+	if err := Write(&buf, want); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	got, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	if got.Session.ID != want.Session.ID || got.Session.PatientID != want.Session.PatientID {
+		t.Errorf("Session = %+v, want %+v", got.Session, want.Session)
+	}
+	if got.FilteredSignals.FHR.Len() != 3 || got.FilteredSignals.UC.Value[1] != 12 {
+		t.Errorf("Filtered signals were not restored: %+v", got.FilteredSignals)
+	}
+	if len(got.Events) != 1 || !got.Events[0].IsLate {
+		t.Errorf("Events = %+v", got.Events)
+	}
+	if p, ok := got.LastPrediction(); !ok || p.Value != 0.2 {
+		t.Errorf("LastPrediction = %+v, %v; want 0.2", p, ok)
+	}
+}
+
+func TestRead_PlainJSON(t *testing.T) {
+	data, err := json.Marshal(sampleArchive())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Read of uncompressed archive failed: %v", err)
+	}
+	if a.Session.ID != "session-1" {
+		t.Errorf("Session ID = %q", a.Session.ID)
+	}
+}
+
+func TestRead_Validation(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(a *Archive)
+		want   error
+	}{
+		{"foreign format", func(a *Archive) { a.Format = "other" }, ErrUnsupportedFormat},
+		{"newer version", func(a *Archive) { a.Version = Version + 1 }, ErrUnsupportedVersion},
+		{"missing session id", func(a *Archive) { a.Session.ID = "" }, ErrInvalid},
+		{"misaligned signal", func(a *Archive) { a.RawSignals.FHR = Signal{TimeSec: []float64{0}} }, ErrInvalid},
+		{"unknown event", func(a *Archive) { a.Events[0].Type = "spike" }, ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := sampleArchive()
+			tt.modify(a)
+
+			var buf bytes.Buffer
+			if err := Write(&buf, a); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			if _, err := Read(&buf); !errors.Is(err, tt.want) {
+				t.Errorf("Read error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := Read(bytes.NewReader([]byte("not an archive"))); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Read of garbage = %v, want ErrInvalid", err)
+	}
+}
@@ -0,0 +1,479 @@
+// Package audit ведет неизменяемый журнал доступа к данным пациенток: кто, когда
+// и с каким результатом просматривал, сохранял, выгружал и удалял сессии и карточки.
+//
+// Записи связаны цепочкой хешей: хеш каждой записи считается от хеша предыдущей
+// и полей самой записи, поэтому изменение, удаление или вставка записи задним
+// числом обнаруживается проверкой цепочки (Verify). Запись идет в отдельной
+// горутине пачками, чтобы журнал не замедлял API. Журнал отказывает закрыто:
+// пока хранилище не принимает записи, Err возвращает ошибку, middleware отклоняют
+// новые запросы с 503, а проверка готовности переводит сервис в not_ready.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Krimson/fetal-monitory/logging"
+)
+
+// Действия
+const (
+	ActionView        = "view"
+	ActionCreate      = "create"
+	ActionUpdate      = "update"
+	ActionDelete      = "delete"
+	ActionExport      = "export"
+	ActionImport      = "import"
+	ActionSave        = "save"
+	ActionStop        = "stop"
+	ActionAcknowledge = "acknowledge"
+	ActionResolve     = "resolve"
+	ActionSubscribe   = "subscribe"
+	ActionUnsubscribe = "unsubscribe"
+	ActionConnect     = "connect"
+)
+
+// Результаты
+const (
+	OutcomeSuccess = "success"
+	OutcomeDenied  = "denied"  // 401/403
+	OutcomeFailure = "failure" // Прочие ошибки
+)
+
+// Anonymous - субъект запросов без аутентификации (AUTH_ENABLED=false)
+const Anonymous = "anonymous"
+
+// Entry - запись журнала. Seq, PrevHash и Hash заполняет хранилище при добавлении
+type Entry struct {
+	Seq        int64     `json:"seq"`
+	Time       time.Time `json:"time"`
+	Actor      string    `json:"actor"`
+	Role       string    `json:"role,omitempty"`
+	Action     string    `json:"action"`
+	SessionID  string    `json:"session_id,omitempty"`
+	PatientID  string    `json:"patient_id,omitempty"`
+	Method     string    `json:"method,omitempty"`
+	Resource   string    `json:"resource,omitempty"` // Шаблон маршрута, например /api/sessions/{id}/export
+	Status     int       `json:"status,omitempty"`
+	Outcome    string    `json:"outcome"`
+	RemoteAddr string    `json:"remote_addr,omitempty"`
+	PrevHash   string    `json:"prev_hash"`
+	Hash       string    `json:"hash"`
+}
+
+// GenesisHash - PrevHash первой записи журнала
+const GenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// ComputeHash возвращает хеш записи: SHA-256 от хеша предыдущей записи и канонического
+// JSON полей записи. Время берется в UTC с точностью до микросекунд, как его хранит PostgreSQL
+func ComputeHash(prevHash string, e *Entry) string {
+	payload, _ := json.Marshal(struct {
+		Seq        int64  `json:"seq"`
+		Time       string `json:"time"`
+		Actor      string `json:"actor"`
+		Role       string `json:"role"`
+		Action     string `json:"action"`
+		SessionID  string `json:"session_id"`
+		PatientID  string `json:"patient_id"`
+		Method     string `json:"method"`
+		Resource   string `json:"resource"`
+		Status     int    `json:"status"`
+		Outcome    string `json:"outcome"`
+		RemoteAddr string `json:"remote_addr"`
+	}{
+		Seq:        e.Seq,
+		Time:       normalizeTime(e.Time).Format(time.RFC3339Nano),
+		Actor:      e.Actor,
+		Role:       e.Role,
+		Action:     e.Action,
+		SessionID:  e.SessionID,
+		PatientID:  e.PatientID,
+		Method:     e.Method,
+		Resource:   e.Resource,
+		Status:     e.Status,
+		Outcome:    e.Outcome,
+		RemoteAddr: e.RemoteAddr,
+	})
+
+	h := sha256.New()
+	h.Write([]byte(prevHash))
+	h.Write([]byte{'\n'})
+	h.Write(payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Chain заполняет Seq, PrevHash и Hash записей, продолжая цепочку после (lastSeq, lastHash).
+// Вызывается хранилищем под блокировкой журнала
+func Chain(entries []*Entry, lastSeq int64, lastHash string) {
+	if lastHash == "" {
+		lastHash = GenesisHash
+	}
+	for _, e := range entries {
+		lastSeq++
+		e.Seq = lastSeq
+		e.PrevHash = lastHash
+		e.Hash = ComputeHash(lastHash, e)
+		lastHash = e.Hash
+	}
+}
+
+func normalizeTime(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Microsecond)
+}
+
+// Filter - условия выборки журнала; записи возвращаются от новых к старым
+type Filter struct {
+	Actor     string
+	Action    string
+	SessionID string
+	PatientID string
+	Outcome   string
+	From      *time.Time
+	To        *time.Time
+	Before    int64 // Только записи с seq < Before (курсор страницы)
+	Limit     int
+}
+
+// Store - append-only хранилище журнала (реализуется PostgresRepository)
+type Store interface {
+	// Append добавляет записи в конец цепочки (см. Chain)
+	Append(ctx context.Context, entries []*Entry) error
+	Query(ctx context.Context, filter Filter) ([]*Entry, error)
+	// Scan обходит весь журнал по возрастанию seq
+	Scan(ctx context.Context, fn func(e *Entry) error) error
+}
+
+const (
+	// queueSize - сколько записей может ждать записи; при переполнении Record ждет
+	queueSize = 4096
+	batchSize = 256
+
+	writeTimeout  = 5 * time.Second
+	writeAttempts = 3
+	retryDelay    = 500 * time.Millisecond
+
+	// failedRetryDelay - пауза между попытками записать пачку, которую хранилище
+	// не приняло за writeAttempts попыток
+	failedRetryDelay = 5 * time.Second
+)
+
+// Ширина колонок audit_log (migrations/009_audit_log.up.sql), в символах. Поля
+// приходят из запроса до проверки токена, поэтому обрезаются в Record: иначе
+// одна запись с длинным идентификатором не давала бы сохранить всю пачку
+const (
+	maxActorLength      = 128
+	maxRoleLength       = 32
+	maxActionLength     = 32
+	maxIDLength         = 64 // session_id, patient_id
+	maxMethodLength     = 10
+	maxOutcomeLength    = 16
+	maxRemoteAddrLength = 64
+	maxResourceLength   = 1024 // resource TEXT; предел, чтобы длинный URL не раздувал журнал
+)
+
+var (
+	// ErrUnavailable возвращается Err, пока записи не удается сохранить
+	ErrUnavailable = errors.New("audit log unavailable")
+
+	// ErrRejected - хранилище отвергло данные записи (а не было недоступно);
+	// повтор той же записи не поможет
+	ErrRejected = errors.New("audit entry rejected by store")
+)
+
+// Stats - статистика журнала
+type Stats struct {
+	Recorded int64 `json:"recorded"` // Записей сохранено
+	Dropped  int64 `json:"dropped"`  // Записей потеряно (отмена запроса или остановка при недоступном хранилище)
+	Rejected int64 `json:"rejected"` // Записей, отвергнутых хранилищем и отложенных в журнал сервиса
+}
+
+// Logger принимает записи и пишет их в хранилище пачками
+type Logger struct {
+	store Store
+
+	mu       sync.Mutex
+	stats    Stats
+	writeErr error // Ошибка последней записи; nil - хранилище принимает записи
+
+	// closeMu защищает queue от записи после Stop (WebSocket клиенты могут пережить HTTP сервер)
+	closeMu sync.RWMutex
+	closed  bool
+
+	queue    chan *Entry
+	stopping chan struct{} // Закрывается в Stop: прекратить повторы недоступного хранилища
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewLogger создает журнал и запускает фоновую запись
+func NewLogger(store Store) *Logger {
+	l := &Logger{
+		store:    store,
+		queue:    make(chan *Entry, queueSize),
+		stopping: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go l.writer()
+
+	return l
+}
+
+// Record ставит запись в очередь. Если очередь заполнена, ждет места, пока не отменен ctx:
+// журнал доступа не должен терять записи молча. Ошибка (ErrUnavailable) - запись
+// потеряна; вызывающий, который еще не открыл доступ к данным, должен отказать
+func (l *Logger) Record(ctx context.Context, e *Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = normalizeTime(e.Time)
+	if e.Actor == "" {
+		e.Actor = Anonymous
+	}
+	e.sanitize()
+
+	l.closeMu.RLock()
+	defer l.closeMu.RUnlock()
+
+	if l.closed {
+		return l.drop(e, "audit log stopped")
+	}
+
+	select {
+	case l.queue <- e:
+		return nil
+	case <-ctx.Done():
+		return l.drop(e, "audit queue full")
+	}
+}
+
+// sanitize приводит поля к виду, который примут колонки audit_log
+func (e *Entry) sanitize() {
+	e.Actor = clamp(e.Actor, maxActorLength)
+	e.Role = clamp(e.Role, maxRoleLength)
+	e.Action = clamp(e.Action, maxActionLength)
+	e.SessionID = clamp(e.SessionID, maxIDLength)
+	e.PatientID = clamp(e.PatientID, maxIDLength)
+	e.Method = clamp(e.Method, maxMethodLength)
+	e.Resource = clamp(e.Resource, maxResourceLength)
+	e.Outcome = clamp(e.Outcome, maxOutcomeLength)
+	e.RemoteAddr = clamp(e.RemoteAddr, maxRemoteAddrLength)
+}
+
+// clamp возвращает s как валидный UTF-8 без NUL (PostgreSQL не принимает ни то,
+// ни другое) не длиннее max символов: VARCHAR(n) считает символы, а не байты
+func clamp(s string, max int) string {
+	s = strings.ReplaceAll(strings.ToValidUTF8(s, "\uFFFD"), "\x00", "")
+	n := 0
+	for i := range s {
+		if n == max {
+			return s[:i]
+		}
+		n++
+	}
+	return s
+}
+
+func (l *Logger) drop(e *Entry, reason string) error {
+	l.mu.Lock()
+	l.stats.Dropped++
+	l.mu.Unlock()
+	slog.Error("Audit entry dropped", "reason", reason, "actor", e.Actor, "action", e.Action, logging.SessionID(e.SessionID))
+	return fmt.Errorf("%w: %s", ErrUnavailable, reason)
+}
+
+// Stop дожидается записи всех принятых записей; более поздние записи отбрасываются
+func (l *Logger) Stop() {
+	l.stopOnce.Do(func() {
+		l.closeMu.Lock()
+		l.closed = true
+		close(l.queue)
+		close(l.stopping)
+		l.closeMu.Unlock()
+		<-l.done
+		stats := l.GetStats()
+		slog.Info("Audit log stopped", "recorded", stats.Recorded, "dropped", stats.Dropped)
+	})
+}
+
+// Err возвращает ErrUnavailable, пока хранилище не принимает записи. Проверка
+// готовности сервиса (health.Dependency) и middleware журнала опираются на нее
+func (l *Logger) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.writeErr != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, l.writeErr)
+	}
+	return nil
+}
+
+// Check - Err в форме проверки готовности
+func (l *Logger) Check(ctx context.Context) error {
+	return l.Err()
+}
+
+// GetStats возвращает статистику журнала
+func (l *Logger) GetStats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
+
+// writer пишет записи в порядке поступления, забирая из очереди все, что накопилось
+func (l *Logger) writer() {
+	defer close(l.done)
+
+	for e := range l.queue {
+		batch := []*Entry{e}
+	collect:
+		for len(batch) < batchSize {
+			select {
+			case next, ok := <-l.queue:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, next)
+			default:
+				break collect
+			}
+		}
+
+		l.persist(batch)
+	}
+}
+
+// persist пишет пачку, пока хранилище ее не примет. Записи не теряются: пока
+// хранилище недоступно, журнал неисправен (Err) и новые запросы отклоняются.
+// Если хранилище отвергает данные (ErrRejected), пачка пишется по одной записи,
+// а отвергнутые записи откладываются в журнал сервиса (reject), чтобы одна
+// запись не блокировала журнал навсегда. Пачка отбрасывается, только если
+// журнал остановлен
+func (l *Logger) persist(batch []*Entry) {
+	for len(batch) > 0 {
+		err := l.write(batch, writeAttempts)
+		if err == nil {
+			l.stored(len(batch))
+			return
+		}
+		if errors.Is(err, ErrRejected) {
+			batch = l.isolate(batch)
+			continue
+		}
+
+		l.mu.Lock()
+		l.writeErr = err
+		l.mu.Unlock()
+		slog.Error("Failed to store audit entries", "entries", len(batch), logging.Err(err))
+
+		select {
+		case <-l.stopping:
+			l.mu.Lock()
+			l.stats.Dropped += int64(len(batch))
+			l.mu.Unlock()
+			return
+		case <-time.After(failedRetryDelay):
+		}
+	}
+}
+
+// isolate пишет записи пачки по одной, откладывая отвергнутые. Возвращает остаток
+// пачки, начиная с записи, которую не удалось записать по другой причине
+func (l *Logger) isolate(batch []*Entry) []*Entry {
+	for i, e := range batch {
+		err := l.write([]*Entry{e}, 1)
+		switch {
+		case err == nil:
+			l.stored(1)
+		case errors.Is(err, ErrRejected):
+			l.reject(e, err)
+		default:
+			return batch[i:]
+		}
+	}
+	return nil
+}
+
+// stored учитывает n сохраненных записей: хранилище снова принимает записи
+func (l *Logger) stored(n int) {
+	l.mu.Lock()
+	l.stats.Recorded += int64(n)
+	l.writeErr = nil
+	l.mu.Unlock()
+}
+
+// reject откладывает отвергнутую хранилищем запись в журнал сервиса целиком,
+// чтобы ее можно было восстановить вручную. Хранилище при этом доступно
+func (l *Logger) reject(e *Entry, err error) {
+	l.mu.Lock()
+	l.stats.Rejected++
+	l.writeErr = nil
+	l.mu.Unlock()
+
+	entry, _ := json.Marshal(e)
+	slog.Error("Audit entry rejected by store, set aside", "entry", string(entry), logging.Err(err))
+}
+
+func (l *Logger) write(batch []*Entry, attempts int) error {
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+		err = l.store.Append(ctx, batch)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if attempt < attempts {
+			time.Sleep(retryDelay)
+		}
+	}
+	return err
+}
+
+// errChainBroken останавливает обход журнала на первой некорректной записи
+var errChainBroken = errors.New("audit chain broken")
+
+// Verification - результат проверки цепочки
+type Verification struct {
+	Valid     bool   `json:"valid"`
+	Checked   int64  `json:"checked"`              // Проверено записей
+	HeadSeq   int64  `json:"head_seq"`             // Последняя корректная запись
+	HeadHash  string `json:"head_hash"`            // ... и ее хеш
+	BrokenSeq int64  `json:"broken_seq,omitempty"` // Первая запись, на которой цепочка нарушена
+	Reason    string `json:"reason,omitempty"`
+}
+
+// Verify проверяет непрерывность seq и хеши всей цепочки
+func Verify(ctx context.Context, store Store) (*Verification, error) {
+	v := &Verification{Valid: true, HeadHash: GenesisHash}
+
+	err := store.Scan(ctx, func(e *Entry) error {
+		switch {
+		case e.Seq != v.HeadSeq+1:
+			v.Reason = "missing entries before this seq"
+		case e.PrevHash != v.HeadHash:
+			v.Reason = "prev_hash does not match previous entry"
+		case e.Hash != ComputeHash(e.PrevHash, e):
+			v.Reason = "entry content does not match its hash"
+		default:
+			v.Checked++
+			v.HeadSeq = e.Seq
+			v.HeadHash = e.Hash
+			return nil
+		}
+		v.Valid = false
+		v.BrokenSeq = e.Seq
+		return errChainBroken
+	})
+	if err != nil && !errors.Is(err, errChainBroken) {
+		return nil, err
+	}
+	return v, nil
+}
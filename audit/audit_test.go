@@ -0,0 +1,388 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// memoryStore - журнал в памяти
+type memoryStore struct {
+	mu      sync.Mutex
+	entries []*Entry
+}
+
+func (s *memoryStore) Append(ctx context.Context, entries []*Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var lastSeq int64
+	var lastHash string
+	if n := len(s.entries); n > 0 {
+		lastSeq, lastHash = s.entries[n-1].Seq, s.entries[n-1].Hash
+	}
+	Chain(entries, lastSeq, lastHash)
+	for _, e := range entries {
+		copied := *e
+		s.entries = append(s.entries, &copied)
+	}
+	return nil
+}
+
+func (s *memoryStore) Query(ctx context.Context, filter Filter) ([]*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []*Entry
+	for i := len(s.entries) - 1; i >= 0 && len(result) < filter.Limit; i-- {
+		e := s.entries[i]
+		if filter.SessionID != "" && e.SessionID != filter.SessionID {
+			continue
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func (s *memoryStore) Scan(ctx context.Context, fn func(e *Entry) error) error {
+	s.mu.Lock()
+	entries := append([]*Entry(nil), s.entries...)
+	s.mu.Unlock()
+
+	for _, e := range entries {
+		copied := *e
+		if err := fn(&copied); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// failingStore - хранилище, которое не принимает записи, пока fail = true
+type failingStore struct {
+	memoryStore
+	fail bool
+}
+
+func (s *failingStore) Append(ctx context.Context, entries []*Entry) error {
+	s.mu.Lock()
+	fail := s.fail
+	s.mu.Unlock()
+	if fail {
+		return errors.New("connection refused")
+	}
+	return s.memoryStore.Append(ctx, entries)
+}
+
+func (s *failingStore) setFail(fail bool) {
+	s.mu.Lock()
+	s.fail = fail
+	s.mu.Unlock()
+}
+
+func recordEntries(t *testing.T, n int) *memoryStore {
+	t.Helper()
+	store := &memoryStore{}
+	l := NewLogger(store)
+	for i := 0; i < n; i++ {
+		l.Record(context.Background(), &Entry{
+			Actor:     "dr.ivanova",
+			Action:    ActionView,
+			SessionID: "session-1",
+			Outcome:   OutcomeSuccess,
+			Time:      time.Date(2025, 3, 1, 10, 0, i, 123456789, time.FixedZone("MSK", 3*3600)),
+		})
+	}
+	l.Stop()
+
+	if len(store.entries) != n {
+		t.Fatalf("Expected %d entries, got %d", n, len(store.entries))
+	}
+	return store
+}
+
+func TestLoggerBuildsValidChain(t *testing.T) {
+	store := recordEntries(t, 5)
+
+	if store.entries[0].PrevHash != GenesisHash || store.entries[0].Seq != 1 {
+		t.Errorf("Unexpected first entry: %+v", store.entries[0])
+	}
+	if got := store.entries[0].Time; got.Location() != time.UTC || got.Nanosecond()%1000 != 0 {
+		t.Errorf("Expected UTC time with microsecond precision, got %v", got)
+	}
+
+	v, err := Verify(context.Background(), store)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !v.Valid || v.Checked != 5 || v.HeadSeq != 5 || v.HeadHash != store.entries[4].Hash {
+		t.Errorf("Unexpected verification: %+v", v)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(s *memoryStore)
+		broken int64
+	}{
+		{"modified entry", func(s *memoryStore) { s.entries[2].Actor = "someone-else" }, 3},
+		{"deleted entry", func(s *memoryStore) { s.entries = append(s.entries[:1], s.entries[2:]...) }, 3},
+		{"rehashed entry", func(s *memoryStore) {
+			s.entries[1].Action = ActionDelete
+			s.entries[1].Hash = ComputeHash(s.entries[1].PrevHash, s.entries[1])
+		}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := recordEntries(t, 5)
+			tt.tamper(store)
+
+			v, err := Verify(context.Background(), store)
+			if err != nil {
+				t.Fatalf("Verify failed: %v", err)
+			}
+			if v.Valid || v.BrokenSeq != tt.broken {
+				t.Errorf("Expected chain broken at %d, got %+v", tt.broken, v)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	store := &memoryStore{}
+	l := NewLogger(store)
+
+	router := mux.NewRouter()
+	router.Use(Middleware(l, func(r *http.Request) (string, string) {
+		if r.Header.Get("Authorization") == "" {
+			return "", ""
+		}
+		return "dr.ivanova", "clinician"
+	}))
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	ok := func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) }
+	router.HandleFunc("/api/sessions/{id}/export", ok)
+	router.HandleFunc("/api/sessions/{id}", ok).Methods("DELETE")
+	router.HandleFunc("/api/patients/{id}", ok)
+	router.HandleFunc("/ws", ok)
+	router.HandleFunc("/health", ok)
+
+	requests := []struct {
+		method, path string
+		authorized   bool
+	}{
+		{"GET", "/api/sessions/s-1/export", true},
+		{"DELETE", "/api/sessions/s-2", true},
+		{"GET", "/api/patients/p-1", false},
+		{"GET", "/ws", true},
+		{"GET", "/ws", false},
+		{"GET", "/health", true},
+	}
+	for _, req := range requests {
+		r := httptest.NewRequest(req.method, req.path, nil)
+		if req.authorized {
+			r.Header.Set("Authorization", "Bearer token")
+		}
+		router.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	l.Stop()
+
+	want := []Entry{
+		{Actor: "dr.ivanova", Role: "clinician", Action: ActionExport, SessionID: "s-1", Resource: "/api/sessions/{id}/export", Status: 200, Outcome: OutcomeSuccess},
+		{Actor: "dr.ivanova", Role: "clinician", Action: ActionDelete, SessionID: "s-2", Resource: "/api/sessions/{id}", Status: 200, Outcome: OutcomeSuccess},
+		{Actor: Anonymous, Action: ActionView, PatientID: "p-1", Resource: "/api/patients/{id}", Status: 401, Outcome: OutcomeDenied},
+		{Actor: Anonymous, Action: ActionConnect, Resource: "/ws", Status: 401, Outcome: OutcomeDenied},
+	}
+	if len(store.entries) != len(want) {
+		t.Fatalf("Expected %d entries, got %d: %+v", len(want), len(store.entries), store.entries)
+	}
+	for i, w := range want {
+		got := store.entries[i]
+		if got.Actor != w.Actor || got.Role != w.Role || got.Action != w.Action || got.SessionID != w.SessionID ||
+			got.PatientID != w.PatientID || got.Resource != w.Resource || got.Status != w.Status || got.Outcome != w.Outcome {
+			t.Errorf("Entry %d: expected %+v, got %+v", i, w, *got)
+		}
+	}
+}
+
+func TestLoggerFailsClosed(t *testing.T) {
+	store := &failingStore{fail: true}
+	l := NewLogger(store)
+
+	called := false
+	handler := PathMiddleware(l, nil, map[string]string{"/export": ActionExport})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+
+	l.Record(context.Background(), &Entry{Action: ActionView, SessionID: "session-1"})
+	deadline := time.Now().Add(5 * time.Second)
+	for l.Err() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected logger to report the failing store")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err := l.Check(context.Background()); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable from readiness check, got %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/export?session_id=session-1", nil))
+	if w.Code != http.StatusServiceUnavailable || called {
+		t.Errorf("Expected 503 without calling the handler, got %d (called=%v)", w.Code, called)
+	}
+
+	// Хранилище восстановилось: отказ записывается, недописанная пачка теряется только при остановке
+	store.setFail(false)
+	l.Stop()
+
+	if len(store.entries) != 1 || store.entries[0].Status != http.StatusServiceUnavailable || store.entries[0].SessionID != "session-1" {
+		t.Errorf("Expected the refused request in the log, got %+v", store.entries)
+	}
+	if stats := l.GetStats(); stats.Dropped != 1 || stats.Recorded != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestPathMiddleware(t *testing.T) {
+	store := &memoryStore{}
+	l := NewLogger(store)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		w.Write([]byte("ok"))
+	})
+	handler := PathMiddleware(l, func(r *http.Request) (string, string) { return "dr.ivanova", "clinician" },
+		map[string]string{"/session": ActionView, "/upload": ActionCreate})(ok)
+
+	for _, r := range []*http.Request{
+		httptest.NewRequest("GET", "/session?session_id=s-1", nil),
+		httptest.NewRequest("POST", "/upload", strings.NewReader("session_id=s-2")),
+		httptest.NewRequest("GET", "/decision", nil),
+		httptest.NewRequest("OPTIONS", "/session", nil),
+	} {
+		if r.Method == "POST" {
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	l.Stop()
+
+	want := []Entry{
+		{Action: ActionView, SessionID: "s-1", Resource: "/session"},
+		{Action: ActionCreate, SessionID: "s-2", Resource: "/upload"},
+	}
+	if len(store.entries) != len(want) {
+		t.Fatalf("Expected %d entries, got %d: %+v", len(want), len(store.entries), store.entries)
+	}
+	for i, w := range want {
+		got := store.entries[i]
+		if got.Actor != "dr.ivanova" || got.Action != w.Action || got.SessionID != w.SessionID || got.Resource != w.Resource || got.Outcome != OutcomeSuccess {
+			t.Errorf("Entry %d: expected %+v, got %+v", i, w, *got)
+		}
+	}
+}
+
+func TestRecordClampsOversizedFields(t *testing.T) {
+	store := &memoryStore{}
+	l := NewLogger(store)
+
+	l.Record(context.Background(), &Entry{
+		Actor:     strings.Repeat("в", 200),
+		Action:    ActionView,
+		SessionID: strings.Repeat("с", 100) + "\x00\xff",
+		Method:    "VERYLONGMETHOD",
+		Outcome:   OutcomeSuccess,
+	})
+	l.Stop()
+
+	if len(store.entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(store.entries))
+	}
+	e := store.entries[0]
+	if e.SessionID != strings.Repeat("с", maxIDLength) {
+		t.Errorf("Expected session ID clamped to %d characters, got %q", maxIDLength, e.SessionID)
+	}
+	if e.Actor != strings.Repeat("в", maxActorLength) || e.Method != "VERYLONGME" {
+		t.Errorf("Unexpected clamped entry: %+v", *e)
+	}
+	if got := clamp("ok\x00\xffend", 10); got != "ok\uFFFDend" {
+		t.Errorf("Expected NUL removed and invalid UTF-8 replaced, got %q", got)
+	}
+}
+
+// rejectingStore отвергает записи с действием ActionDelete, как PostgreSQL - ошибку данных
+type rejectingStore struct {
+	memoryStore
+}
+
+func (s *rejectingStore) Append(ctx context.Context, entries []*Entry) error {
+	for _, e := range entries {
+		if e.Action == ActionDelete {
+			return fmt.Errorf("%w: value too long for type character varying(64)", ErrRejected)
+		}
+	}
+	return s.memoryStore.Append(ctx, entries)
+}
+
+func TestLoggerSetsAsideRejectedEntries(t *testing.T) {
+	store := &rejectingStore{}
+	l := NewLogger(store)
+
+	for _, action := range []string{ActionView, ActionDelete, ActionExport} {
+		l.Record(context.Background(), &Entry{Action: action, SessionID: "session-1", Outcome: OutcomeSuccess})
+	}
+	l.Stop()
+
+	if len(store.entries) != 2 || store.entries[0].Action != ActionView || store.entries[1].Action != ActionExport {
+		t.Fatalf("Expected the other entries to be stored, got %+v", store.entries)
+	}
+	if stats := l.GetStats(); stats.Recorded != 2 || stats.Rejected != 1 || stats.Dropped != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if err := l.Err(); err != nil {
+		t.Errorf("Rejected entry must not make the log unavailable, got %v", err)
+	}
+
+	v, err := Verify(context.Background(), store)
+	if err != nil || !v.Valid || v.Checked != 2 {
+		t.Errorf("Expected a valid chain without the rejected entry, got %+v (%v)", v, err)
+	}
+}
+
+func TestClassifyPostgresErrors(t *testing.T) {
+	tests := []struct {
+		code     pq.ErrorCode
+		rejected bool
+	}{
+		{"22001", true},  // string_data_right_truncation
+		{"22021", true},  // character_not_in_repertoire
+		{"23502", true},  // not_null_violation
+		{"23505", false}, // unique_violation - конфликт seq, повтор допустим
+		{"08006", false}, // connection_failure
+	}
+	for _, tt := range tests {
+		err := classify(&pq.Error{Code: tt.code})
+		if errors.Is(err, ErrRejected) != tt.rejected {
+			t.Errorf("Code %s: expected rejected=%v, got %v", tt.code, tt.rejected, err)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package audit
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// IdentifyFunc возвращает субъекта и роль запроса (пустые строки - аноним)
+type IdentifyFunc func(r *http.Request) (actor, role string)
+
+// Middleware записывает в журнал каждый вызов /api/... и отклоненные подключения к /ws.
+// Успешные подключения к /ws не пишутся: подписки на сессии журналирует websocket.Hub.
+// Должен стоять до проверки токена, чтобы в журнал попадали и отказы в доступе
+func Middleware(l *Logger, identify IdentifyFunc) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			isWS := r.URL.Path == "/ws"
+			if r.Method == http.MethodOptions || (!isWS && !strings.HasPrefix(r.URL.Path, "/api/")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			status := serve(l, w, r, next)
+			if isWS && status < http.StatusBadRequest {
+				return
+			}
+
+			e := newEntry(r, status, identify)
+			if route := mux.CurrentRoute(r); route != nil {
+				if template, err := route.GetPathTemplate(); err == nil {
+					e.Resource = template
+				}
+			}
+			e.Action = actionOf(r.Method, e.Resource)
+			if isWS {
+				e.Action = ActionConnect
+			}
+
+			vars := mux.Vars(r)
+			if id := vars["session_id"]; id != "" {
+				e.SessionID = id
+			}
+			switch {
+			case strings.HasPrefix(e.Resource, "/api/sessions/{id}"):
+				e.SessionID = vars["id"]
+			case strings.HasPrefix(e.Resource, "/api/patients/{id}"):
+				e.PatientID = vars["id"]
+			}
+
+			l.Record(r.Context(), e)
+		})
+	}
+}
+
+// PathMiddleware записывает в журнал вызовы путей из actions (путь -> действие) для
+// сервисов на http.ServeMux. Сессия берется из параметра session_id запроса или формы.
+// Должен стоять до проверки токена, как и Middleware
+func PathMiddleware(l *Logger, identify IdentifyFunc, actions map[string]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			action, ok := actions[r.URL.Path]
+			if !ok || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			status := serve(l, w, r, next)
+
+			e := newEntry(r, status, identify)
+			e.Action = action
+			// Форму разбирает обработчик (multipart); сами не читаем тело повторно
+			if e.SessionID == "" && r.Form != nil {
+				e.SessionID = r.Form.Get("session_id")
+			}
+
+			l.Record(r.Context(), e)
+		})
+	}
+}
+
+// serve выполняет запрос и возвращает код ответа. Пока журнал не принимает записи,
+// запрос не выполняется (503): доступ к данным мимо журнала не допускается
+func serve(l *Logger, w http.ResponseWriter, r *http.Request, next http.Handler) int {
+	rec := &statusRecorder{ResponseWriter: w}
+	if l.Err() != nil {
+		respondError(rec, http.StatusServiceUnavailable, "Audit log unavailable")
+	} else {
+		next.ServeHTTP(rec, r)
+	}
+
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+	return rec.status
+}
+
+// newEntry - запись о запросе без действия
+func newEntry(r *http.Request, status int, identify IdentifyFunc) *Entry {
+	e := &Entry{
+		Method:     r.Method,
+		Resource:   r.URL.Path,
+		Status:     status,
+		Outcome:    outcomeOf(status),
+		RemoteAddr: r.RemoteAddr,
+		SessionID:  r.URL.Query().Get("session_id"),
+	}
+	if identify != nil {
+		e.Actor, e.Role = identify(r)
+	}
+	return e
+}
+
+// actionOf определяет действие по методу и последнему сегменту шаблона маршрута
+func actionOf(method, resource string) string {
+	switch resource[strings.LastIndex(resource, "/")+1:] {
+	case "export", "report":
+		return ActionExport
+	case "import":
+		return ActionImport
+	case "save":
+		return ActionSave
+	case "stop":
+		return ActionStop
+	case "ack":
+		return ActionAcknowledge
+	case "resolve":
+		return ActionResolve
+	}
+
+	switch method {
+	case http.MethodPost:
+		return ActionCreate
+	case http.MethodPut, http.MethodPatch:
+		return ActionUpdate
+	case http.MethodDelete:
+		return ActionDelete
+	default:
+		return ActionView
+	}
+}
+
+func outcomeOf(status int) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return OutcomeDenied
+	case status >= http.StatusBadRequest:
+		return OutcomeFailure
+	default:
+		return OutcomeSuccess
+	}
+}
+
+// statusRecorder запоминает код ответа; поддерживает потоковую отдачу и WebSocket
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
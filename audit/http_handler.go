@@ -0,0 +1,164 @@
+package audit
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Krimson/fetal-monitory/logging"
+)
+
+const (
+	defaultQueryLimit = 100
+	maxQueryLimit     = 1000
+)
+
+// HTTPHandler отдает журнал для проверки соответствия (Presentation Layer)
+type HTTPHandler struct {
+	store Store
+}
+
+// NewHTTPHandler создает новый HTTP обработчик
+func NewHTTPHandler(store Store) *HTTPHandler {
+	return &HTTPHandler{
+		store: store,
+	}
+}
+
+// RegisterRoutes регистрирует маршруты в роутере
+func (h *HTTPHandler) RegisterRoutes(router *mux.Router) {
+	api := router.PathPrefix("/api/audit").Subrouter()
+
+	api.HandleFunc("", h.QueryAudit).Methods("GET", "OPTIONS")
+	api.HandleFunc("/verify", h.VerifyAudit).Methods("GET", "OPTIONS")
+}
+
+// QueryAudit возвращает записи журнала
+// @Summary Журнал доступа
+// @Description Возвращает записи журнала доступа от новых к старым. Следующая страница - before=next_before
+// @Tags Audit
+// @Produce json
+// @Param actor query string false "Субъект (sub из JWT)"
+// @Param action query string false "Действие: view, create, update, delete, export, import, save, stop, acknowledge, resolve, subscribe, unsubscribe, connect"
+// @Param session_id query string false "ID сессии"
+// @Param patient_id query string false "ID пациентки"
+// @Param outcome query string false "Результат: success, denied, failure"
+// @Param from query string false "С момента (RFC3339 или YYYY-MM-DD)"
+// @Param to query string false "До момента, не включая (RFC3339 или YYYY-MM-DD)"
+// @Param before query int false "Только записи с seq меньше указанного"
+// @Param limit query int false "Количество записей (до 1000)" default(100)
+// @Success 200 {object} map[string]interface{} "Записи журнала"
+// @Failure 400 {object} map[string]interface{} "Неверные параметры"
+// @Failure 500 {object} map[string]interface{} "Ошибка сервера"
+// @Security BearerAuth
+// @Router /api/audit [get]
+func (h *HTTPHandler) QueryAudit(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := Filter{
+		Actor:     q.Get("actor"),
+		Action:    q.Get("action"),
+		SessionID: q.Get("session_id"),
+		PatientID: q.Get("patient_id"),
+		Outcome:   q.Get("outcome"),
+		Limit:     defaultQueryLimit,
+	}
+
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		if value := q.Get(p.name); value != "" {
+			t, err := parseTime(value)
+			if err != nil {
+				respondError(w, http.StatusBadRequest, "Invalid "+p.name+": expected RFC3339 or YYYY-MM-DD")
+				return
+			}
+			*p.dst = &t
+		}
+	}
+	if value := q.Get("before"); value != "" {
+		before, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || before <= 0 {
+			respondError(w, http.StatusBadRequest, "Invalid before")
+			return
+		}
+		filter.Before = before
+	}
+	if value := q.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			respondError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		filter.Limit = min(limit, maxQueryLimit)
+	}
+
+	entries, err := h.store.Query(r.Context(), filter)
+	if err != nil {
+		slog.Error("Failed to query audit log", logging.Err(err))
+		respondError(w, http.StatusInternalServerError, "Failed to query audit log")
+		return
+	}
+	if entries == nil {
+		entries = []*Entry{}
+	}
+
+	response := map[string]interface{}{
+		"entries": entries,
+		"count":   len(entries),
+		"limit":   filter.Limit,
+	}
+	if len(entries) == filter.Limit {
+		response["next_before"] = entries[len(entries)-1].Seq
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// VerifyAudit проверяет цепочку хешей журнала
+// @Summary Проверить целостность журнала
+// @Description Пересчитывает цепочку хешей всего журнала. valid=false и broken_seq указывают первую измененную, удаленную или вставленную запись
+// @Tags Audit
+// @Produce json
+// @Success 200 {object} Verification "Результат проверки"
+// @Failure 500 {object} map[string]interface{} "Ошибка сервера"
+// @Security BearerAuth
+// @Router /api/audit/verify [get]
+func (h *HTTPHandler) VerifyAudit(w http.ResponseWriter, r *http.Request) {
+	v, err := Verify(r.Context(), h.store)
+	if err != nil {
+		slog.Error("Failed to verify audit log", logging.Err(err))
+		respondError(w, http.StatusInternalServerError, "Failed to verify audit log")
+		return
+	}
+	if !v.Valid {
+		slog.Error("Audit log chain is broken", "seq", v.BrokenSeq, "reason", v.Reason)
+	}
+
+	respondJSON(w, http.StatusOK, v)
+}
+
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+func respondJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		slog.Error("Failed to encode JSON response", logging.Err(err))
+	}
+}
+
+func respondError(w http.ResponseWriter, status int, message string) {
+	respondJSON(w, status, map[string]interface{}{
+		"error":  message,
+		"status": status,
+	})
+}
@@ -0,0 +1,186 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// appendLockID - ключ pg_advisory_xact_lock, под которым добавляются записи:
+// цепочка должна продолжаться последовательно, даже если пишут несколько экземпляров receiver
+const appendLockID int64 = 0x61756469 // "audi"
+
+// PostgresRepository реализует Store для PostgreSQL (Infrastructure Layer)
+type PostgresRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresRepository создает репозиторий поверх общего пула соединений
+func NewPostgresRepository(db *sql.DB) *PostgresRepository {
+	return &PostgresRepository{
+		db: db,
+	}
+}
+
+const entryColumns = `seq, occurred_at, actor, role, action, COALESCE(session_id, ''), COALESCE(patient_id, ''),
+	method, resource, status, outcome, remote_addr, prev_hash, hash`
+
+// Append продолжает цепочку от последней записи журнала в одной транзакции
+func (r *PostgresRepository) Append(ctx context.Context, entries []*Entry) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", appendLockID); err != nil {
+		return fmt.Errorf("failed to lock audit log: %w", err)
+	}
+
+	var (
+		lastSeq  int64
+		lastHash string
+	)
+	err = tx.QueryRowContext(ctx, `SELECT seq, hash FROM audit_log ORDER BY seq DESC LIMIT 1`).Scan(&lastSeq, &lastHash)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to read audit log head: %w", err)
+	}
+
+	Chain(entries, lastSeq, lastHash)
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO audit_log (seq, occurred_at, actor, role, action, session_id, patient_id,
+			method, resource, status, outcome, remote_addr, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), $8, $9, $10, $11, $12, $13, $14)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare audit insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, e := range entries {
+		_, err := stmt.ExecContext(ctx,
+			e.Seq,
+			e.Time,
+			e.Actor,
+			e.Role,
+			e.Action,
+			e.SessionID,
+			e.PatientID,
+			e.Method,
+			e.Resource,
+			e.Status,
+			e.Outcome,
+			e.RemoteAddr,
+			e.PrevHash,
+			e.Hash,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert audit entry %d: %w", e.Seq, classify(err))
+		}
+	}
+
+	return tx.Commit()
+}
+
+// classify помечает ошибки данных (класс 22) и ограничений (класс 23, кроме
+// конфликта seq) как ErrRejected: повтор той же записи их не исправит
+func classify(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	class := pqErr.Code.Class()
+	if class == "22" || (class == "23" && pqErr.Code.Name() != "unique_violation") {
+		return fmt.Errorf("%w: %v", ErrRejected, err)
+	}
+	return err
+}
+
+// Query возвращает записи по фильтру, от новых к старым
+func (r *PostgresRepository) Query(ctx context.Context, filter Filter) ([]*Entry, error) {
+	var conds []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	for _, field := range []struct{ column, value string }{
+		{"actor", filter.Actor},
+		{"action", filter.Action},
+		{"session_id", filter.SessionID},
+		{"patient_id", filter.PatientID},
+		{"outcome", filter.Outcome},
+	} {
+		if field.value != "" {
+			conds = append(conds, field.column+" = "+arg(field.value))
+		}
+	}
+	if filter.From != nil {
+		conds = append(conds, "occurred_at >= "+arg(*filter.From))
+	}
+	if filter.To != nil {
+		conds = append(conds, "occurred_at < "+arg(*filter.To))
+	}
+	if filter.Before > 0 {
+		conds = append(conds, "seq < "+arg(filter.Before))
+	}
+
+	query := `SELECT ` + entryColumns + ` FROM audit_log`
+	if len(conds) > 0 {
+		query += "\n\t\tWHERE " + strings.Join(conds, "\n\t\t  AND ")
+	}
+	query += "\n\t\tORDER BY seq DESC\n\t\tLIMIT " + arg(filter.Limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*Entry
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// Scan обходит журнал по возрастанию seq одним запросом
+func (r *PostgresRepository) Scan(ctx context.Context, fn func(e *Entry) error) error {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+entryColumns+` FROM audit_log ORDER BY seq`)
+	if err != nil {
+		return fmt.Errorf("failed to scan audit log: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func scanEntry(rows *sql.Rows) (*Entry, error) {
+	var e Entry
+	err := rows.Scan(&e.Seq, &e.Time, &e.Actor, &e.Role, &e.Action, &e.SessionID, &e.PatientID,
+		&e.Method, &e.Resource, &e.Status, &e.Outcome, &e.RemoteAddr, &e.PrevHash, &e.Hash)
+	if err != nil {
+		return nil, err
+	}
+	e.Time = normalizeTime(e.Time)
+	return &e, nil
+}
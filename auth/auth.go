@@ -0,0 +1,210 @@
+// Package auth - аутентификация по JWT и ролевой доступ для HTTP, WebSocket и
+// gRPC API receiver и offline-service.
+//
+// Токены подписываются HS256 локальным ключом, поэтому проверяются без внешнего
+// сервиса авторизации: ключ общий у сервисов одной установки, токены выпускает
+// подкоманда token.
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Role - роль пользователя или устройства
+type Role string
+
+const (
+	RoleClinician Role = "clinician" // Врач
+	RoleMidwife   Role = "midwife"   // Акушерка
+	RoleAdmin     Role = "admin"     // Администратор установки
+	RoleDevice    Role = "device"    // Монитор/эмулятор, отправляющий телеметрию
+)
+
+// Staff - роли медицинского персонала и администратора
+var Staff = []Role{RoleClinician, RoleMidwife, RoleAdmin}
+
+// ParseRole разбирает название роли
+func ParseRole(s string) (Role, error) {
+	switch role := Role(strings.ToLower(s)); role {
+	case RoleClinician, RoleMidwife, RoleAdmin, RoleDevice:
+		return role, nil
+	default:
+		return "", fmt.Errorf("unknown role: %s", s)
+	}
+}
+
+// MinKeySize - минимальная длина ключа подписи HS256
+const MinKeySize = 32
+
+var (
+	ErrNoToken      = errors.New("missing token")
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token expired")
+	ErrForbidden    = errors.New("role is not allowed")
+)
+
+// Claims - содержимое токена
+type Claims struct {
+	ID        string `json:"jti,omitempty"`
+	Subject   string `json:"sub"`            // Пользователь или устройство
+	Name      string `json:"name,omitempty"` // Отображаемое имя
+	Role      Role   `json:"role"`
+	Issuer    string `json:"iss,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// HasRole проверяет, что роль токена входит в roles
+func (c *Claims) HasRole(roles ...Role) bool {
+	for _, role := range roles {
+		if c.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator выпускает и проверяет токены
+type Authenticator struct {
+	key    []byte
+	issuer string
+	leeway time.Duration // Допуск расхождения часов при проверке exp/iat
+	now    func() time.Time
+}
+
+// NewAuthenticator создает аутентификатор с ключом подписи. Если issuer не
+// пустой, принимаются только токены этого издателя
+func NewAuthenticator(key []byte, issuer string) (*Authenticator, error) {
+	if len(key) < MinKeySize {
+		return nil, fmt.Errorf("signing key must be at least %d bytes, got %d", MinKeySize, len(key))
+	}
+	return &Authenticator{
+		key:    key,
+		issuer: issuer,
+		leeway: 30 * time.Second,
+		now:    time.Now,
+	}, nil
+}
+
+// LoadKey возвращает ключ подписи из файла или, если файл не задан, из строки
+func LoadKey(secret, file string) ([]byte, error) {
+	if file == "" {
+		return []byte(secret), nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	return []byte(strings.TrimSpace(string(data))), nil
+}
+
+// jwtHeader - единственный поддерживаемый заголовок
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Issue выпускает токен на ttl
+func (a *Authenticator) Issue(subject, name string, role Role, ttl time.Duration) (string, *Claims, error) {
+	if subject == "" {
+		return "", nil, errors.New("subject is required")
+	}
+	if ttl <= 0 {
+		return "", nil, errors.New("ttl must be positive")
+	}
+
+	now := a.now()
+	claims := &Claims{
+		ID:        uuid.NewString(),
+		Subject:   subject,
+		Name:      name,
+		Role:      role,
+		Issuer:    a.issuer,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal claims: %w", err)
+	}
+	signingInput := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signingInput + "." + a.sign(signingInput), claims, nil
+}
+
+// Verify проверяет подпись, срок действия, издателя и роль токена
+func (a *Authenticator) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	// Заголовок сравнивается целиком: другие алгоритмы (в том числе "none") не принимаются
+	if parts[0] != jwtHeader {
+		header, err := base64.RawURLEncoding.DecodeString(parts[0])
+		var h struct {
+			Alg string `json:"alg"`
+		}
+		if err != nil || json.Unmarshal(header, &h) != nil || h.Alg != "HS256" {
+			return nil, fmt.Errorf("%w: unsupported algorithm", ErrInvalidToken)
+		}
+	}
+
+	signingInput := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(a.sign(signingInput))) {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	now := a.now()
+	switch {
+	case claims.Subject == "":
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	case a.issuer != "" && claims.Issuer != a.issuer:
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	case claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(a.leeway)):
+		return nil, ErrExpiredToken
+	case now.Add(a.leeway).Before(time.Unix(claims.IssuedAt, 0)):
+		return nil, fmt.Errorf("%w: issued in the future", ErrInvalidToken)
+	}
+	if _, err := ParseRole(string(claims.Role)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	return &claims, nil
+}
+
+func (a *Authenticator) sign(signingInput string) string {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(signingInput))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+type contextKey struct{}
+
+// NewContext возвращает контекст с данными токена
+func NewContext(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, contextKey{}, claims)
+}
+
+// FromContext возвращает данные токена запроса; nil, если аутентификация отключена
+func FromContext(ctx context.Context) *Claims {
+	claims, _ := ctx.Value(contextKey{}).(*Claims)
+	return claims
+}
@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func newTestAuthenticator(t *testing.T) *Authenticator {
+	t.Helper()
+	a, err := NewAuthenticator(testKey, "fetal-monitory")
+	if err != nil {
+		t.Fatalf("NewAuthenticator failed: %v", err)
+	}
+	return a
+}
+
+func issue(t *testing.T, a *Authenticator, role Role) string {
+	t.Helper()
+	token, _, err := a.Issue("user-1", "Dr. Test", role, time.Hour)
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+	return token
+}
+
+func TestNewAuthenticator_ShortKey(t *testing.T) {
+	if _, err := NewAuthenticator([]byte("short"), ""); err == nil {
+		t.Error("Expected error for a short key")
+	}
+}
+
+func TestIssueAndVerify(t *testing.T) {
+	a := newTestAuthenticator(t)
+	token := issue(t, a, RoleClinician)
+
+	claims, err := a.Verify(token)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if claims.Subject != "user-1" || claims.Role != RoleClinician || claims.Issuer != "fetal-monitory" || claims.ID == "" {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+}
+
+func TestVerify_Rejects(t *testing.T) {
+	a := newTestAuthenticator(t)
+	token := issue(t, a, RoleAdmin)
+	parts := strings.Split(token, ".")
+
+	other, _ := NewAuthenticator([]byte("another-key-another-key-another-k"), "fetal-monitory")
+	foreign := issue(t, other, RoleAdmin)
+
+	otherIssuer, _ := NewAuthenticator(testKey, "someone-else")
+	wrongIssuer := issue(t, otherIssuer, RoleAdmin)
+
+	noneHeader := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+	forgedPayload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"user-1","role":"admin","exp":9999999999}`))
+
+	tests := map[string]string{
+		"garbage":          "not-a-token",
+		"tampered payload": parts[0] + "." + forgedPayload + "." + parts[2],
+		"alg none":         noneHeader + "." + parts[1] + ".",
+		"foreign key":      foreign,
+		"wrong issuer":     wrongIssuer,
+	}
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := a.Verify(token); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Expected ErrInvalidToken, got %v", err)
+			}
+		})
+	}
+}
+
+func TestVerify_Expired(t *testing.T) {
+	a := newTestAuthenticator(t)
+	token := issue(t, a, RoleMidwife)
+
+	a.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if _, err := a.Verify(token); !errors.Is(err, ErrExpiredToken) {
+		t.Errorf("Expected ErrExpiredToken, got %v", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	a := newTestAuthenticator(t)
+	policy := Policy{
+		{PathPrefix: "/health", Public: true},
+		{PathPrefix: "/api/", Methods: []string{http.MethodDelete}, Roles: []Role{RoleClinician, RoleAdmin}},
+		{PathPrefix: "/api/", Roles: Staff},
+	}
+
+	var seen *Claims
+	handler := a.Middleware(policy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = FromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{"public", http.MethodGet, "/health", "", http.StatusOK},
+		{"preflight", http.MethodOptions, "/api/sessions", "", http.StatusOK},
+		{"no token", http.MethodGet, "/api/sessions", "", http.StatusUnauthorized},
+		{"bad token", http.MethodGet, "/api/sessions", "bad", http.StatusUnauthorized},
+		{"staff read", http.MethodGet, "/api/sessions", issue(t, a, RoleMidwife), http.StatusOK},
+		{"device denied", http.MethodGet, "/api/sessions", issue(t, a, RoleDevice), http.StatusForbidden},
+		{"midwife delete denied", http.MethodDelete, "/api/sessions/1", issue(t, a, RoleMidwife), http.StatusForbidden},
+		{"clinician delete", http.MethodDelete, "/api/sessions/1", issue(t, a, RoleClinician), http.StatusOK},
+		{"unmatched needs token", http.MethodGet, "/other", "", http.StatusUnauthorized},
+		{"unmatched any role", http.MethodGet, "/other", issue(t, a, RoleDevice), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("Expected %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/sessions", nil)
+	req.Header.Set("Authorization", "Bearer "+issue(t, a, RoleClinician))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if seen == nil || seen.Role != RoleClinician {
+		t.Errorf("Expected claims in request context, got %+v", seen)
+	}
+}
+
+func TestTokenFromRequest_WebSocket(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/ws?session_id=1&access_token=abc", nil)
+	if token := TokenFromRequest(req); token != "" {
+		t.Errorf("Query token must be ignored for plain requests, got %q", token)
+	}
+
+	req.Header.Set("Upgrade", "websocket")
+	if token := TokenFromRequest(req); token != "abc" {
+		t.Errorf("Expected query token for WebSocket upgrade, got %q", token)
+	}
+}
+
+func TestOrigins(t *testing.T) {
+	o := ParseOrigins("https://ctg.hospital.local, http://localhost:3000/")
+
+	if !o.Allowed("http://localhost:3000") || !o.Allowed("HTTPS://CTG.hospital.local") {
+		t.Error("Expected configured origins to be allowed")
+	}
+	if o.Allowed("https://evil.example") || o.Any() {
+		t.Error("Unexpected origin allowed")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	if !o.CheckOrigin(req) {
+		t.Error("Requests without Origin must be allowed")
+	}
+	req.Header.Set("Origin", "https://evil.example")
+	if o.CheckOrigin(req) {
+		t.Error("Foreign origin must be rejected")
+	}
+
+	if !ParseOrigins("*").Allowed("https://anything") {
+		t.Error("Wildcard must allow any origin")
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	a := newTestAuthenticator(t)
+	interceptor := a.UnaryServerInterceptor(MethodPolicy{"/telemetry.v1.DataService/": {RoleDevice, RoleAdmin}})
+
+	var seen *Claims
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		seen = FromContext(ctx)
+		return "ok", nil
+	}
+	call := func(method, token string) error {
+		ctx := context.Background()
+		if token != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+token))
+		}
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return err
+	}
+
+	if err := call("/grpc.health.v1.Health/Check", ""); err != nil {
+		t.Errorf("Methods without a rule must be open, got %v", err)
+	}
+	if err := call("/telemetry.v1.DataService/PushSamples", ""); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated, got %v", err)
+	}
+	if err := call("/telemetry.v1.DataService/PushSamples", issue(t, a, RoleMidwife)); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied, got %v", err)
+	}
+	if err := call("/telemetry.v1.DataService/PushSamples", issue(t, a, RoleDevice)); err != nil {
+		t.Errorf("Expected device to be allowed, got %v", err)
+	}
+	if seen == nil || seen.Role != RoleDevice {
+		t.Errorf("Expected claims in handler context, got %+v", seen)
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	a := newTestAuthenticator(t)
+
+	var out bytes.Buffer
+	if err := RunCommand(a, []string{"-sub", "monitor-3", "-role", "device", "-ttl", "720h"}, &out); err != nil {
+		t.Fatalf("RunCommand failed: %v", err)
+	}
+	token := strings.SplitN(out.String(), "\n", 2)[0]
+	claims, err := a.Verify(token)
+	if err != nil || claims.Subject != "monitor-3" || claims.Role != RoleDevice {
+		t.Errorf("Issued token is not valid: %+v, %v", claims, err)
+	}
+
+	if err := RunCommand(a, []string{"-sub", "x", "-role", "nurse"}, &out); err == nil {
+		t.Error("Expected error for unknown role")
+	}
+}
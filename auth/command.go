@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Usage - справка по подкоманде token
+const Usage = `usage: token -sub <subject> -role <clinician|midwife|admin|device> [-name <name>] [-ttl <duration>]
+
+issues a token signed with the local key and prints it to stdout`
+
+// RunCommand выполняет подкоманду token (общая для receiver и offline-service)
+func RunCommand(a *Authenticator, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("token", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	subject := fs.String("sub", "", "user or device ID")
+	name := fs.String("name", "", "display name")
+	roleName := fs.String("role", "", "role")
+	ttl := fs.Duration("ttl", 12*time.Hour, "token lifetime")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%v\n%s", err, Usage)
+	}
+
+	role, err := ParseRole(*roleName)
+	if err != nil {
+		return fmt.Errorf("%v\n%s", err, Usage)
+	}
+
+	token, claims, err := a.Issue(*subject, *name, role, *ttl)
+	if err != nil {
+		return fmt.Errorf("%v\n%s", err, Usage)
+	}
+
+	fmt.Fprintln(out, token)
+	fmt.Fprintf(out, "# sub=%s role=%s expires=%s\n", claims.Subject, claims.Role,
+		time.Unix(claims.ExpiresAt, 0).UTC().Format(time.RFC3339))
+	return nil
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// MethodPolicy - роли, которым доступны gRPC методы. Ключ - полное имя метода
+// ("/telemetry.v1.DataService/PushSamples") или префикс сервиса
+// ("/telemetry.v1.DataService/"). Методы без правила (health, reflection) открыты
+type MethodPolicy map[string][]Role
+
+func (p MethodPolicy) match(fullMethod string) ([]Role, bool) {
+	if roles, ok := p[fullMethod]; ok {
+		return roles, true
+	}
+	for prefix, roles := range p {
+		if strings.HasSuffix(prefix, "/") && strings.HasPrefix(fullMethod, prefix) {
+			return roles, true
+		}
+	}
+	return nil, false
+}
+
+// authorize проверяет токен из метаданных вызова
+func (a *Authenticator) authorize(ctx context.Context, policy MethodPolicy, fullMethod string) (context.Context, error) {
+	roles, ok := policy.match(fullMethod)
+	if !ok {
+		return ctx, nil
+	}
+
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, header := range md.Get("authorization") {
+			if scheme, value, ok := strings.Cut(header, " "); ok && strings.EqualFold(scheme, "Bearer") {
+				token = strings.TrimSpace(value)
+			}
+		}
+	}
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, ErrNoToken.Error())
+	}
+
+	claims, err := a.Verify(token)
+	if err != nil {
+		if errors.Is(err, ErrExpiredToken) {
+			return nil, status.Error(codes.Unauthenticated, ErrExpiredToken.Error())
+		}
+		return nil, status.Error(codes.Unauthenticated, ErrInvalidToken.Error())
+	}
+	if len(roles) > 0 && !claims.HasRole(roles...) {
+		slog.Warn("Access denied", "subject", claims.Subject, "role", claims.Role, "method", fullMethod)
+		return nil, status.Error(codes.PermissionDenied, ErrForbidden.Error())
+	}
+
+	return NewContext(ctx, claims), nil
+}
+
+// UnaryServerInterceptor проверяет токен унарных вызовов по политике
+func (a *Authenticator) UnaryServerInterceptor(policy MethodPolicy) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.authorize(ctx, policy, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor проверяет токен потоковых вызовов по политике
+func (a *Authenticator) StreamServerInterceptor(policy MethodPolicy) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authorize(ss.Context(), policy, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authStream подменяет контекст потока контекстом с данными токена
+type authStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authStream) Context() context.Context {
+	return s.ctx
+}
+
+// BearerCredentials - токен для клиентских gRPC вызовов (grpc.WithPerRPCCredentials)
+type BearerCredentials struct {
+	Token string
+	// Secure требует TLS для отправки токена
+	Secure bool
+}
+
+func (c BearerCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + c.Token}, nil
+}
+
+func (c BearerCredentials) RequireTransportSecurity() bool {
+	return c.Secure
+}
+
+// APIKeyHeader - ключ метаданных gRPC с API ключом устройства (см. реестр устройств receiver)
+const APIKeyHeader = "x-api-key"
+
+// APIKeyCredentials - API ключ устройства для клиентских gRPC вызовов
+type APIKeyCredentials struct {
+	Key string
+	// Secure требует TLS для отправки ключа
+	Secure bool
+}
+
+func (c APIKeyCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{APIKeyHeader: c.Key}, nil
+}
+
+func (c APIKeyCredentials) RequireTransportSecurity() bool {
+	return c.Secure
+}
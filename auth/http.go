@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// Rule - правило доступа к HTTP маршрутам с префиксом PathPrefix
+type Rule struct {
+	PathPrefix string
+	Methods    []string // Пусто - любые методы
+	Public     bool     // Доступ без токена
+	Roles      []Role   // Пусто - любая роль с действительным токеном
+}
+
+func (r Rule) matches(req *http.Request) bool {
+	if !strings.HasPrefix(req.URL.Path, r.PathPrefix) {
+		return false
+	}
+	if len(r.Methods) == 0 {
+		return true
+	}
+	for _, method := range r.Methods {
+		if req.Method == method {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy - правила доступа; применяется первое подходящее. Маршруты без
+// подходящего правила требуют действительный токен с любой ролью
+type Policy []Rule
+
+func (p Policy) match(req *http.Request) Rule {
+	for _, rule := range p {
+		if rule.matches(req) {
+			return rule
+		}
+	}
+	return Rule{}
+}
+
+// Middleware проверяет токен и роль по политике и кладет данные токена в
+// контекст запроса. Preflight-запросы CORS пропускаются без проверки
+func (a *Authenticator) Middleware(policy Policy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rule := policy.match(r)
+			if r.Method == http.MethodOptions || rule.Public {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token := TokenFromRequest(r)
+			if token == "" {
+				respondAuthError(w, http.StatusUnauthorized, ErrNoToken)
+				return
+			}
+			claims, err := a.Verify(token)
+			if err != nil {
+				respondAuthError(w, http.StatusUnauthorized, err)
+				return
+			}
+			if len(rule.Roles) > 0 && !claims.HasRole(rule.Roles...) {
+				slog.Warn("Access denied", "subject", claims.Subject, "role", claims.Role, "method", r.Method, "path", r.URL.Path)
+				respondAuthError(w, http.StatusForbidden, ErrForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), claims)))
+		})
+	}
+}
+
+// TokenFromRequest извлекает токен из заголовка Authorization: Bearer. Браузер
+// не может задать заголовки WebSocket, поэтому для upgrade-запросов токен
+// принимается и в параметре access_token
+func TokenFromRequest(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		if scheme, token, ok := strings.Cut(header, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return r.URL.Query().Get("access_token")
+	}
+	return ""
+}
+
+func respondAuthError(w http.ResponseWriter, status int, err error) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="fetal-monitory"`)
+	}
+	message := "Unauthorized"
+	switch {
+	case errors.Is(err, ErrForbidden):
+		message = "Forbidden"
+	case errors.Is(err, ErrExpiredToken):
+		message = "Token expired"
+	case errors.Is(err, ErrNoToken):
+		message = "Missing bearer token"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  message,
+		"status": status,
+	})
+}
+
+// Origins - разрешенные Origin для CORS и WebSocket. "*" разрешает любой
+type Origins struct {
+	any     bool
+	allowed map[string]bool
+}
+
+// ParseOrigins разбирает список Origin через запятую
+func ParseOrigins(s string) Origins {
+	o := Origins{allowed: make(map[string]bool)}
+	for _, origin := range strings.Split(s, ",") {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		switch origin {
+		case "":
+		case "*":
+			o.any = true
+		default:
+			o.allowed[strings.ToLower(origin)] = true
+		}
+	}
+	return o
+}
+
+// Any сообщает, что разрешен любой Origin
+func (o Origins) Any() bool {
+	return o.any
+}
+
+// Allowed проверяет Origin запроса
+func (o Origins) Allowed(origin string) bool {
+	return o.any || o.allowed[strings.ToLower(strings.TrimRight(origin, "/"))]
+}
+
+// CheckOrigin - проверка для websocket.Upgrader: запросы без Origin (не из
+// браузера) разрешены, браузерные - только с разрешенных Origin
+func (o Origins) CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	return origin == "" || o.Allowed(origin)
+}
@@ -6,7 +6,7 @@ import (
 	"crypto/rand"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"sync"
@@ -15,7 +15,7 @@ import (
 
 	"github.com/Krimson/fetal-monitory/emulator/internal/csvreader"
 	"github.com/Krimson/fetal-monitory/emulator/internal/grpcclient"
-
+	"github.com/Krimson/fetal-monitory/logging"
 	telemetryv1 "github.com/Krimson/fetal-monitory/proto/telemetry"
 )
 
@@ -25,32 +25,48 @@ func main() {
 		ucFile     = flag.String("uc", "uc.csv", "Файл с данными сокращений матки")
 		serverAddr = flag.String("server", "localhost:50051", "Адрес gRPC сервера")
 		sessionID  = flag.String("session", "", "ID сессии (если пусто - генерируется автоматически)")
+		token      = flag.String("token", os.Getenv("AUTH_TOKEN"), "JWT устройства (роль device), по умолчанию из AUTH_TOKEN")
+		apiKey     = flag.String("api-key", os.Getenv("DEVICE_API_KEY"), "API ключ из реестра устройств, по умолчанию из DEVICE_API_KEY")
+		tlsCA      = flag.String("tls-ca", "", "CA сервера; включает TLS")
+		tlsCert    = flag.String("tls-cert", "", "Клиентский сертификат устройства (mTLS, CN = ID устройства)")
+		tlsKey     = flag.String("tls-key", "", "Ключ клиентского сертификата")
 	)
 	flag.Parse()
 
+	if err := logging.Setup("emulator-client", logging.ConfigFromEnv()); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid logging configuration: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Генерируем UUID если session_id не указан
 	if *sessionID == "" {
 		*sessionID = generateSessionID()
-		log.Printf("Generated session ID: %s", *sessionID)
+		slog.Info("Generated session ID", logging.SessionID(*sessionID))
 	}
 
 	// Чтение CSV файлов
 	fhrData, err := csvreader.ReadCSVFile(*fhrFile)
 	if err != nil {
-		log.Fatalf("Failed to read FHR data: %v", err)
+		logging.Fatal("Failed to read FHR data", logging.Err(err))
 	}
 
 	ucData, err := csvreader.ReadCSVFile(*ucFile)
 	if err != nil {
-		log.Fatalf("Failed to read UC data: %v", err)
+		logging.Fatal("Failed to read UC data", logging.Err(err))
 	}
 
-	log.Printf("Loaded %d FHR records and %d UC records", len(fhrData), len(ucData))
+	slog.Info("Loaded records", "fhr", len(fhrData), "uc", len(ucData))
 
 	// Создание gRPC клиента
-	grpcClient, err := grpcclient.NewGRPCClient(*serverAddr, *sessionID)
+	grpcClient, err := grpcclient.NewGRPCClient(*serverAddr, *sessionID, grpcclient.Options{
+		Token:    *token,
+		APIKey:   *apiKey,
+		CAFile:   *tlsCA,
+		CertFile: *tlsCert,
+		KeyFile:  *tlsKey,
+	})
 	if err != nil {
-		log.Fatalf("Failed to create gRPC client: %v", err)
+		logging.Fatal("Failed to create gRPC client", logging.Err(err))
 	}
 	defer grpcClient.Close()
 
@@ -140,16 +156,16 @@ func main() {
 	go func() {
 		defer wg.Done()
 		if err := grpcClient.PushSamples(ctx, mergedSamples); err != nil {
-			log.Printf("Error pushing samples: %v", err)
+			slog.Error("Failed to push samples", logging.Err(err))
 		}
 	}()
 
 	// Ожидание сигнала завершения
 	<-sigCh
-	log.Println("Received shutdown signal...")
+	slog.Info("Received shutdown signal...")
 	cancel()
 	wg.Wait()
-	log.Println("Application stopped gracefully")
+	slog.Info("Application stopped gracefully")
 }
 
 // generateSessionID генерирует уникальный ID сессии
@@ -158,7 +174,7 @@ func generateSessionID() string {
 	b := make([]byte, 16)
 	_, err := rand.Read(b)
 	if err != nil {
-		log.Fatalf("Failed to generate session ID: %v", err)
+		logging.Fatal("Failed to generate session ID", logging.Err(err))
 	}
 	return fmt.Sprintf("%08x-%04x-%04x-%04x-%012x",
 		b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
@@ -4,7 +4,7 @@ package main
 import (
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
 	"os"
 	"os/signal"
@@ -14,6 +14,7 @@ import (
 	"google.golang.org/grpc"
 
 	"github.com/Krimson/fetal-monitory/emulator/internal/grpcserver"
+	"github.com/Krimson/fetal-monitory/logging"
 	telemetryv1 "github.com/Krimson/fetal-monitory/proto/telemetry"
 )
 
@@ -21,16 +22,21 @@ func main() {
 	port := flag.Int("port", 50051, "Port for gRPC server")
 	flag.Parse()
 
+	if err := logging.Setup("emulator-server", logging.ConfigFromEnv()); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid logging configuration: %v\n", err)
+		os.Exit(1)
+	}
+
 	server := grpcserver.NewServer()
 	grpcServer := grpc.NewServer()
 	telemetryv1.RegisterDataServiceServer(grpcServer, server)
 
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", *port))
 	if err != nil {
-		log.Fatalf("Failed to listen: %v", err)
+		logging.Fatal("Failed to listen", logging.Err(err))
 	}
 
-	log.Printf("Starting gRPC server on port %d", *port)
+	slog.Info("Starting gRPC server", "port", *port)
 
 	// Graceful shutdown
 	stop := make(chan os.Signal, 1)
@@ -38,7 +44,7 @@ func main() {
 
 	go func() {
 		if err := grpcServer.Serve(lis); err != nil {
-			log.Fatalf("Failed to serve: %v", err)
+			logging.Fatal("Failed to serve", logging.Err(err))
 		}
 	}()
 
@@ -51,22 +57,16 @@ func main() {
 			select {
 			case <-ticker.C:
 				sessions := server.GetAllSessions()
-				log.Printf("=== Server Statistics ===")
-				log.Printf("Active sessions: %d", len(sessions))
+				slog.Info("Server statistics", "active_sessions", len(sessions))
 				for sessionID, stats := range sessions {
-					log.Printf("Session %s: total=%d, FHR=%d, UC=%d, last_update=%v",
-						sessionID, stats.ReceivedCount,
-						stats.MetricCounts[telemetryv1.Metric_METRIC_FHR],
-						stats.MetricCounts[telemetryv1.Metric_METRIC_UC],
-						time.Since(stats.LastUpdate))
+					slog.Info("Session statistics", logging.SessionID(sessionID), "total", stats.ReceivedCount, "FHR", stats.MetricCounts[telemetryv1.Metric_METRIC_FHR], "UC", stats.MetricCounts[telemetryv1.Metric_METRIC_UC], "last_update", time.Since(stats.LastUpdate))
 				}
-				log.Printf("=========================")
 			}
 		}
 	}()
 
 	<-stop
-	log.Println("Shutting down server...")
+	slog.Info("Shutting down server...")
 	grpcServer.GracefulStop()
-	log.Println("Server stopped")
+	slog.Info("Server stopped")
 }
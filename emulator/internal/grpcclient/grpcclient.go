@@ -3,13 +3,19 @@ package grpcclient
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 
+	"github.com/Krimson/fetal-monitory/auth"
+	"github.com/Krimson/fetal-monitory/logging"
 	telemetryv1 "github.com/Krimson/fetal-monitory/proto/telemetry"
 )
 
@@ -19,10 +25,37 @@ type GRPCClient struct {
 	sessionID string
 }
 
-func NewGRPCClient(serverAddr, sessionID string) (*GRPCClient, error) {
-	conn, err := grpc.Dial(serverAddr,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithTimeout(5*time.Second))
+// Options - учетные данные устройства для подключения к receiver
+type Options struct {
+	Token  string // JWT с ролью device
+	APIKey string // API ключ из реестра устройств (метаданные x-api-key)
+
+	// TLS: CAFile - CA сервера; CertFile и KeyFile - клиентский сертификат для mTLS
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
+
+// NewGRPCClient подключается к receiver с учетными данными устройства
+func NewGRPCClient(serverAddr, sessionID string, o Options) (*GRPCClient, error) {
+	transport, err := transportCredentials(o)
+	if err != nil {
+		return nil, err
+	}
+	secure := o.CAFile != ""
+
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(transport),
+		grpc.WithTimeout(5 * time.Second),
+	}
+	if o.Token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(auth.BearerCredentials{Token: o.Token, Secure: secure}))
+	}
+	if o.APIKey != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(auth.APIKeyCredentials{Key: o.APIKey, Secure: secure}))
+	}
+
+	conn, err := grpc.Dial(serverAddr, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to gRPC server: %w", err)
 	}
@@ -57,14 +90,43 @@ func (g *GRPCClient) receiveAcks(stream telemetryv1.DataService_PushSamplesClien
 	for {
 		ack, err := stream.Recv()
 		if err != nil {
-			log.Printf("Failed to receive ack: %v", err)
+			slog.Warn("Failed to receive ack", logging.Err(err))
 			return
 		}
-		log.Printf("Received ack for session %s: received_cnt=%d",
-			ack.SessionId, ack.ReceivedCnt)
+		slog.Debug("Received ack", logging.SessionID(ack.SessionId), "received_cnt", ack.ReceivedCnt)
 	}
 }
 
 func (g *GRPCClient) Close() error {
 	return g.conn.Close()
 }
+
+// transportCredentials возвращает TLS, если задан CA сервера, иначе соединение без шифрования
+func transportCredentials(o Options) (credentials.TransportCredentials, error) {
+	if o.CAFile == "" {
+		if o.CertFile != "" {
+			return nil, fmt.Errorf("client certificate requires server CA (-tls-ca)")
+		}
+		return insecure.NewCredentials(), nil
+	}
+
+	pem, err := os.ReadFile(o.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read server CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", o.CAFile)
+	}
+	tlsConfig := &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+
+	if o.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return credentials.NewTLS(tlsConfig), nil
+}
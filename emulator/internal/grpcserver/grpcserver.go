@@ -2,10 +2,11 @@
 package grpcserver
 
 import (
-	"log"
+	"log/slog"
 	"sync"
 	"time"
 
+	"github.com/Krimson/fetal-monitory/logging"
 	telemetryv1 "github.com/Krimson/fetal-monitory/proto/telemetry"
 )
 
@@ -34,13 +35,13 @@ func (s *Server) PushSamples(stream telemetryv1.DataService_PushSamplesServer) e
 	for {
 		sample, err := stream.Recv()
 		if err != nil {
-			log.Printf("Stream closed for session %s: %v", sessionID, err)
+			slog.Warn("Stream closed", logging.SessionID(sessionID), logging.Err(err))
 			return err
 		}
 
 		if sessionID == "" {
 			sessionID = sample.SessionId
-			log.Printf("New connection for session: %s", sessionID)
+			slog.Info("New connection", logging.SessionID(sessionID))
 		}
 
 		// Обновляем статистику
@@ -58,16 +59,7 @@ func (s *Server) PushSamples(stream telemetryv1.DataService_PushSamplesServer) e
 		s.mu.Unlock()
 
 		// Логируем полученные данные
-		metricName := "UNKNOWN"
-		switch sample.Metric {
-		case telemetryv1.Metric_METRIC_FHR:
-			metricName = "FHR"
-		case telemetryv1.Metric_METRIC_UC:
-			metricName = "UC"
-		}
-
-		log.Printf("Received sample: session=%s, metric=%s, value=%.2f, ts_ms=%d",
-			sessionID, metricName, sample.Value, sample.TsMs)
+		slog.Debug("Received sample", logging.SessionID(sessionID), logging.Metric(sample.Metric), "value", sample.Value, "ts_ms", sample.TsMs)
 
 		// Отправляем подтверждение каждые 10 samples
 		if receivedCount%10 == 0 {
@@ -76,10 +68,10 @@ func (s *Server) PushSamples(stream telemetryv1.DataService_PushSamplesServer) e
 				ReceivedCnt: receivedCount,
 			}
 			if err := stream.Send(ack); err != nil {
-				log.Printf("Failed to send ack: %v", err)
+				slog.Error("Failed to send ack", logging.Err(err))
 				return err
 			}
-			log.Printf("Sent ack for session %s: count=%d", sessionID, receivedCount)
+			slog.Debug("Sent ack", logging.SessionID(sessionID), "count", receivedCount)
 		}
 	}
 }
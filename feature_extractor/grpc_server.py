@@ -4,6 +4,8 @@ from concurrent import futures
 from typing import Dict, Optional, List
 import grpc
 import pandas as pd
+from grpc_health.v1 import health, health_pb2, health_pb2_grpc
+from opentelemetry import trace
 
 # Импорты для gRPC
 import feature_extractor_pb2
@@ -11,10 +13,12 @@ import feature_extractor_pb2_grpc
 
 from collector import Collector
 from preprocessor import Preprocessor
+from tracing import batch_context, setup_tracing, shutdown_tracing
 
 # Настройка логирования
 logging.basicConfig(level=logging.INFO)
 logger = logging.getLogger(__name__)
+tracer = trace.get_tracer(__name__)
 
 class FeatureExtractorService(feature_extractor_pb2_grpc.FeatureExtractorServiceServicer):
     """
@@ -94,39 +98,57 @@ class FeatureExtractorService(feature_extractor_pb2_grpc.FeatureExtractorService
             ProcessBatchResponse с обработанными метриками
         """
         try:
-            session_id = request.session_id
-            logger.info(f"Processing batch for session: {session_id}")
-            
-            # Конвертируем данные из gRPC формата
-            bpm_dict = self._convert_datapoints_to_dict(request.bpm_data)
-            uterus_dict = self._convert_datapoints_to_dict(request.uterus_data)
-            
-            # Если есть данные, добавляем их в коллектор
-            collector = self._get_or_create_collector(session_id)
+            return self._process_batch(request)
+        except Exception as e:
+            logger.error(f"Error processing batch: {e}")
+            context.set_code(grpc.StatusCode.INTERNAL)
+            context.set_details(f"Error processing batch: {str(e)}")
+            return None
+    
+    def _process_batch(self, request) -> object:
+        """
+        Добавляет батч в коллектор сессии и вычисляет метрики.
+        
+        Raises:
+            Exception: если батч не удалось обработать
+        """
+        session_id = request.session_id
+        logger.info(f"Processing batch for session: {session_id}")
+        
+        # Конвертируем данные из gRPC формата
+        bpm_dict = self._convert_datapoints_to_dict(request.bpm_data)
+        uterus_dict = self._convert_datapoints_to_dict(request.uterus_data)
+        
+        # Если есть данные, добавляем их в коллектор
+        collector = self._get_or_create_collector(session_id)
+        
+        # Добавляем точки в коллектор (симулируем онлайн режим)
+        for i in range(max(len(bpm_dict['time_sec']), len(uterus_dict['time_sec']))):
+            new_data = {}
             
-            # Добавляем точки в коллектор (симулируем онлайн режим)
-            for i in range(max(len(bpm_dict['time_sec']), len(uterus_dict['time_sec']))):
-                new_data = {}
-                
-                if i < len(bpm_dict['time_sec']):
-                    new_data['bpm_s'] = bpm_dict['value'][i]
-                    new_data['time_sec'] = bpm_dict['time_sec'][i]
-                else:
-                    new_data['bmp_s'] = None
-                
-                if i < len(uterus_dict['time_sec']):
-                    new_data['uc_s'] = uterus_dict['value'][i]
-                    if 'time_sec' not in new_data:
-                        new_data['time_sec'] = uterus_dict['time_sec'][i]
-                else:
-                    new_data['uc_s'] = None
-                
-                if 'time_sec' in new_data:
-                    collector.update_collector(new_data)
+            if i < len(bpm_dict['time_sec']):
+                new_data['bpm_s'] = bpm_dict['value'][i]
+                new_data['time_sec'] = bpm_dict['time_sec'][i]
+            else:
+                new_data['bmp_s'] = None
             
-            # Получаем данные из коллектора
-            bpm_raw, uterus_raw = collector.get_data()
+            if i < len(uterus_dict['time_sec']):
+                new_data['uc_s'] = uterus_dict['value'][i]
+                if 'time_sec' not in new_data:
+                    new_data['time_sec'] = uterus_dict['time_sec'][i]
+            else:
+                new_data['uc_s'] = None
             
+            if 'time_sec' in new_data:
+                collector.update_collector(new_data)
+        
+        # Получаем данные из коллектора
+        bpm_raw, uterus_raw = collector.get_data()
+        
+        with tracer.start_as_current_span("Preprocessor.compute_metrics") as span:
+            span.set_attribute("session.id", session_id)
+            span.set_attribute("collector.points", len(bpm_raw))
+
             # Фильтруем данные
             bpm_filtered, uc_filtered = self.preprocessor.filter_physiological_signals(
                 bpm_raw, uterus_raw, fs_estimated=4.0
@@ -134,63 +156,57 @@ class FeatureExtractorService(feature_extractor_pb2_grpc.FeatureExtractorService
             
             # Вычисляем метрики
             metrics = self.preprocessor.compute_metrics(bpm_filtered, uc_filtered)
-            
-            # Создаем батчи отфильтрованных данных (последние 52 точки - скользящее окно)
-            filtered_bpm_batch = bpm_filtered.tail(min(len(bpm_filtered), 52))
-            filtered_uterus_batch = uc_filtered.tail(min(len(uc_filtered), 52))
-            
-            # Конвертируем в DataPoint messages
-            filtered_bpm_points = [
-                self._create_datapoint_response(row['time_sec'], row['value'])
-                for _, row in filtered_bpm_batch.iterrows()
-            ]
-            
-            filtered_uterus_points = [
-                self._create_datapoint_response(row['time_sec'], row['value'])
-                for _, row in filtered_uterus_batch.iterrows()
-            ]
-            
-            # Конвертируем события в response messages
-            accelerations = [self._create_acceleration_response(acc) for acc in metrics['accelerations']]
-            decelerations = [self._create_deceleration_response(dec) for dec in metrics['decelerations']]
-            contractions = [self._create_contraction_response(cont) for cont in metrics['contractions']]
-            
-            # Создаем ответ
-            response = feature_extractor_pb2.ProcessBatchResponse(
-                session_id=session_id,
-                batch_ts_ms=request.batch_ts_ms,
-                stv=metrics['stv'],
-                ltv=metrics['ltv'],
-                baseline_heart_rate=metrics['baseline_heart_rate'],
-                accelerations=accelerations,
-                decelerations=decelerations,
-                contractions=contractions,
-                stvs=list(metrics['stvs']),
-                stvs_window_duration=metrics['stvs_window_duration'],
-                ltvs=list(metrics['ltvs']),
-                ltvs_window_duration=metrics['ltvs_window_duration'],
-                total_decelerations=metrics['total_decelerations'],
-                late_decelerations=metrics['late_decelerations'],
-                late_deceleration_ratio=metrics['late_deceleration_ratio'],
-                total_accelerations=metrics['total_accelerations'],
-                accel_decel_ratio=metrics['accel_decel_ratio'],
-                total_contractions=metrics['total_contractions'],
-                stv_trend=metrics['stv_trend'],
-                bpm_trend=metrics['bpm_trend'],
-                data_points=metrics['data_points'],
-                time_span_sec=metrics['time_span_sec'],
-                filtered_bpm_batch=filtered_bpm_points,
-                filtered_uterus_batch=filtered_uterus_points
-            )
-            
-            logger.info(f"Successfully processed batch for session: {session_id}")
-            return response
-            
-        except Exception as e:
-            logger.error(f"Error processing batch: {e}")
-            context.set_code(grpc.StatusCode.INTERNAL)
-            context.set_details(f"Error processing batch: {str(e)}")
-            return None
+        
+        # Создаем батчи отфильтрованных данных (последние 52 точки - скользящее окно)
+        filtered_bpm_batch = bpm_filtered.tail(min(len(bpm_filtered), 52))
+        filtered_uterus_batch = uc_filtered.tail(min(len(uc_filtered), 52))
+        
+        # Конвертируем в DataPoint messages
+        filtered_bpm_points = [
+            self._create_datapoint_response(row['time_sec'], row['value'])
+            for _, row in filtered_bpm_batch.iterrows()
+        ]
+        
+        filtered_uterus_points = [
+            self._create_datapoint_response(row['time_sec'], row['value'])
+            for _, row in filtered_uterus_batch.iterrows()
+        ]
+        
+        # Конвертируем события в response messages
+        accelerations = [self._create_acceleration_response(acc) for acc in metrics['accelerations']]
+        decelerations = [self._create_deceleration_response(dec) for dec in metrics['decelerations']]
+        contractions = [self._create_contraction_response(cont) for cont in metrics['contractions']]
+        
+        # Создаем ответ
+        response = feature_extractor_pb2.ProcessBatchResponse(
+            session_id=session_id,
+            batch_ts_ms=request.batch_ts_ms,
+            stv=metrics['stv'],
+            ltv=metrics['ltv'],
+            baseline_heart_rate=metrics['baseline_heart_rate'],
+            accelerations=accelerations,
+            decelerations=decelerations,
+            contractions=contractions,
+            stvs=list(metrics['stvs']),
+            stvs_window_duration=metrics['stvs_window_duration'],
+            ltvs=list(metrics['ltvs']),
+            ltvs_window_duration=metrics['ltvs_window_duration'],
+            total_decelerations=metrics['total_decelerations'],
+            late_decelerations=metrics['late_decelerations'],
+            late_deceleration_ratio=metrics['late_deceleration_ratio'],
+            total_accelerations=metrics['total_accelerations'],
+            accel_decel_ratio=metrics['accel_decel_ratio'],
+            total_contractions=metrics['total_contractions'],
+            stv_trend=metrics['stv_trend'],
+            bpm_trend=metrics['bpm_trend'],
+            data_points=metrics['data_points'],
+            time_span_sec=metrics['time_span_sec'],
+            filtered_bpm_batch=filtered_bpm_points,
+            filtered_uterus_batch=filtered_uterus_points
+        )
+        
+        logger.info(f"Successfully processed batch for session: {session_id}")
+        return response
     
     async def ProcessBatchStream(self, request_iterator, context):
         """
@@ -201,19 +217,46 @@ class FeatureExtractorService(feature_extractor_pb2_grpc.FeatureExtractorService
             context: gRPC context
             
         Yields:
-            ProcessBatchResponse для каждого обработанного батча
+            ProcessBatchResponse на каждый батч в порядке запросов. Если батч
+            не удалось обработать, ответ содержит только session_id, batch_ts_ms
+            и error - receiver сразу повторит батч, не дожидаясь таймаута
         """
         try:
             async for request in request_iterator:
-                response = await self.ProcessBatch(request, context)
-                if response:
-                    yield response
+                yield self._process_stream_batch(request)
                     
         except Exception as e:
             logger.error(f"Error in batch stream: {e}")
             context.set_code(grpc.StatusCode.INTERNAL)
             context.set_details(f"Error in batch stream: {str(e)}")
     
+    def _process_stream_batch(self, request) -> object:
+        """
+        Обрабатывает батч из потока в собственном спане. Спан продолжает трассу
+        из trace_context запроса: metadata потока несет только контекст его открытия.
+        
+        Returns:
+            ProcessBatchResponse; если батч не удалось обработать - ответ
+            только с session_id, batch_ts_ms и error
+        """
+        with tracer.start_as_current_span(
+            "FeatureExtractorService.ProcessBatchStream.batch",
+            context=batch_context(request.trace_context),
+        ) as span:
+            span.set_attribute("session.id", request.session_id)
+            span.set_attribute("batch.ts_ms", request.batch_ts_ms)
+            try:
+                return self._process_batch(request)
+            except Exception as e:
+                logger.error(f"Error processing batch in stream: {e}")
+                span.record_exception(e)
+                span.set_status(trace.Status(trace.StatusCode.ERROR, str(e)))
+                return feature_extractor_pb2.ProcessBatchResponse(
+                    session_id=request.session_id,
+                    batch_ts_ms=request.batch_ts_ms,
+                    error=str(e),
+                )
+    
     async def ResetCollector(self, request, context) -> object:
         """
         Сбрасывает коллектор для указанной сессии.
@@ -252,10 +295,18 @@ class FeatureExtractorService(feature_extractor_pb2_grpc.FeatureExtractorService
 
 async def serve():
     """Запускает gRPC сервер"""
-    server = grpc.aio.server(futures.ThreadPoolExecutor(max_workers=10))
+    # Контекст трассировки приходит от receiver в gRPC metadata
+    interceptors = setup_tracing("feature-extractor")
+    server = grpc.aio.server(futures.ThreadPoolExecutor(max_workers=10), interceptors=interceptors)
     
     service = FeatureExtractorService()
     feature_extractor_pb2_grpc.add_FeatureExtractorServiceServicer_to_server(service, server)
+
+    # Стандартный grpc.health.v1: по нему receiver проверяет готовность (/readyz)
+    health_servicer = health.aio.HealthServicer()
+    health_pb2_grpc.add_HealthServicer_to_server(health_servicer, server)
+    for name in ("", feature_extractor_pb2.DESCRIPTOR.services_by_name["FeatureExtractorService"].full_name):
+        await health_servicer.set(name, health_pb2.HealthCheckResponse.SERVING)
     
     listen_addr = '[::]:50052'
     server.add_insecure_port(listen_addr)
@@ -267,7 +318,9 @@ async def serve():
         await server.wait_for_termination()
     except KeyboardInterrupt:
         logger.info("Shutting down gRPC server...")
+        await health_servicer.enter_graceful_shutdown()
         await server.stop(5)
+        shutdown_tracing()
 
 if __name__ == '__main__':
     asyncio.run(serve())
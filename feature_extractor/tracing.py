@@ -0,0 +1,82 @@
+"""
+Трассировка OpenTelemetry.
+
+Receiver передает контекст трассы батча в gRPC metadata (W3C traceparent),
+серверный перехватчик продолжает от него спан вызова. В ProcessBatchStream
+metadata несет только контекст открытия потока, поэтому контекст каждого батча
+приходит в поле trace_context запроса (см. batch_context). Экспортер задается
+переменной TRACING_EXPORTER: none (по умолчанию), stdout или otlp.
+"""
+import logging
+import os
+from typing import List, Optional
+
+from opentelemetry import trace
+from opentelemetry.context import Context
+from opentelemetry.propagate import extract
+from opentelemetry.instrumentation.grpc import aio_server_interceptor, filters
+from opentelemetry.sdk.resources import Resource
+from opentelemetry.sdk.trace import TracerProvider
+from opentelemetry.sdk.trace.export import BatchSpanProcessor, ConsoleSpanExporter
+from opentelemetry.sdk.trace.sampling import ParentBased, TraceIdRatioBased
+
+logger = logging.getLogger(__name__)
+
+
+def setup_tracing(service_name: str) -> List:
+    """
+    Настраивает TracerProvider по переменным окружения.
+
+    Args:
+        service_name: service.name по умолчанию (переопределяется TRACING_SERVICE_NAME)
+
+    Returns:
+        Перехватчики для grpc.aio.server (пустой список при TRACING_EXPORTER=none)
+    """
+    exporter_name = os.getenv("TRACING_EXPORTER", "none")
+    if exporter_name == "none":
+        return []
+
+    if exporter_name == "stdout":
+        exporter = ConsoleSpanExporter()
+    elif exporter_name == "otlp":
+        from opentelemetry.exporter.otlp.proto.grpc.trace_exporter import OTLPSpanExporter
+        exporter = OTLPSpanExporter(
+            endpoint=os.getenv("TRACING_OTLP_ENDPOINT", "otel-collector:4317"),
+            insecure=os.getenv("TRACING_OTLP_INSECURE", "true").lower() == "true",
+        )
+    else:
+        raise ValueError(f"Unknown tracing exporter: {exporter_name}")
+
+    # Решение о записи принимает receiver на корневом спане батча
+    ratio = float(os.getenv("TRACING_SAMPLE_RATIO", "1.0"))
+    provider = TracerProvider(
+        resource=Resource.create({"service.name": os.getenv("TRACING_SERVICE_NAME", service_name)}),
+        sampler=ParentBased(TraceIdRatioBased(ratio)),
+    )
+    provider.add_span_processor(BatchSpanProcessor(exporter))
+    trace.set_tracer_provider(provider)
+
+    logger.info(f"Tracing enabled: exporter={exporter_name} sample_ratio={ratio}")
+
+    # Проверки grpc.health.v1 не трассируются
+    return [aio_server_interceptor(filter_=filters.negate(filters.health_check()))]
+
+
+def batch_context(carrier) -> Optional[Context]:
+    """
+    Восстанавливает контекст трассы батча из поля trace_context запроса.
+
+    Returns:
+        Контекст для start_as_current_span или None, если receiver его не передал
+    """
+    if not carrier:
+        return None
+    return extract(dict(carrier))
+
+
+def shutdown_tracing():
+    """Дописывает оставшиеся спаны перед остановкой"""
+    provider = trace.get_tracer_provider()
+    if isinstance(provider, TracerProvider):
+        provider.shutdown()
@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type attrsKey struct{}
+
+// contextHandler добавляет к записи поля из ctx (см. With) и идентификаторы
+// трассы OpenTelemetry, чтобы по записи можно было найти трассу батча
+type contextHandler struct {
+	next slog.Handler
+}
+
+func (h *contextHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.next.Enabled(ctx, l)
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+			r.AddAttrs(attrs...)
+		}
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			r.AddAttrs(
+				slog.String(KeyTraceID, sc.TraceID().String()),
+				slog.String(KeySpanID, sc.SpanID().String()),
+			)
+		}
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{next: h.next.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{next: h.next.WithGroup(name)}
+}
+
+// samplingHandler пропускает одну из каждых every отладочных записей с одинаковым
+// сообщением; записи остальных уровней проходят все. Пропущенная запись несет
+// поле sample_every, чтобы при чтении было видно, что часть записей опущена
+type samplingHandler struct {
+	next   slog.Handler
+	every  uint64
+	counts *sync.Map // сообщение -> *atomic.Uint64 (общий для WithAttrs/WithGroup)
+}
+
+func newSamplingHandler(next slog.Handler, every int) *samplingHandler {
+	return &samplingHandler{next: next, every: uint64(every), counts: &sync.Map{}}
+}
+
+func (h *samplingHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.next.Enabled(ctx, l)
+}
+
+func (h *samplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level != slog.LevelDebug {
+		return h.next.Handle(ctx, r)
+	}
+
+	counter, _ := h.counts.LoadOrStore(r.Message, new(atomic.Uint64))
+	if (counter.(*atomic.Uint64).Add(1)-1)%h.every != 0 {
+		return nil
+	}
+	r.AddAttrs(slog.Uint64("sample_every", h.every))
+	return h.next.Handle(ctx, r)
+}
+
+func (h *samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &samplingHandler{next: h.next.WithAttrs(attrs), every: h.every, counts: h.counts}
+}
+
+func (h *samplingHandler) WithGroup(name string) slog.Handler {
+	return &samplingHandler{next: h.next.WithGroup(name), every: h.every, counts: h.counts}
+}
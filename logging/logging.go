@@ -0,0 +1,172 @@
+// Package logging - общий структурированный логгер (log/slog) для receiver,
+// emulator и offline-service.
+//
+// Записи о данных пациенток несут поля session_id, metric и batch_ts, чтобы журнал
+// можно было отфильтровать по сессии мониторинга. Уровень и формат (text или json)
+// задаются конфигурацией; отладочные записи конвейера, которые пишутся на каждый
+// батч, можно прореживать (DebugSample). Стандартный пакет log после Setup пишет
+// через тот же обработчик с уровнем INFO.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Форматы вывода
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Ключи полей корреляции
+const (
+	KeyService   = "service"
+	KeySessionID = "session_id"
+	KeyMetric    = "metric"
+	KeyBatchTS   = "batch_ts"
+	KeyError     = "error"
+	KeyTraceID   = "trace_id"
+	KeySpanID    = "span_id"
+)
+
+// Config - настройки логгера
+type Config struct {
+	Level       string // debug, info, warn или error
+	Format      string // FormatText или FormatJSON
+	DebugSample int    // Из каждых N отладочных записей с одним сообщением выводится одна (0 и 1 - все)
+}
+
+// ConfigFromEnv читает LOG_LEVEL, LOG_FORMAT и LOG_DEBUG_SAMPLE
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Level:  getEnv("LOG_LEVEL", "info"),
+		Format: getEnv("LOG_FORMAT", FormatText),
+	}
+	if n, err := strconv.Atoi(os.Getenv("LOG_DEBUG_SAMPLE")); err == nil {
+		cfg.DebugSample = n
+	}
+	return cfg
+}
+
+// level - текущий уровень логгера, заданного Setup; меняется SetLevel без перезапуска
+var level slog.LevelVar
+
+// Setup делает логгер сервиса service логгером по умолчанию (slog и log)
+func Setup(service string, cfg Config) error {
+	logger, err := New(os.Stderr, service, cfg, &level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+// SetLevel меняет уровень логгера, заданного Setup
+func SetLevel(name string) error {
+	l, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	level.Set(l)
+	return nil
+}
+
+// Level возвращает текущий уровень логгера, заданного Setup
+func Level() slog.Level {
+	return level.Level()
+}
+
+// New создает логгер, пишущий в w. Уровень cfg.Level записывается в levelVar,
+// через который его можно менять позже
+func New(w io.Writer, service string, cfg Config, levelVar *slog.LevelVar) (*slog.Logger, error) {
+	l, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+	levelVar.Set(l)
+
+	opts := &slog.HandlerOptions{Level: levelVar}
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case FormatText, "":
+		handler = slog.NewTextHandler(w, opts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q (expected %s or %s)", cfg.Format, FormatText, FormatJSON)
+	}
+
+	handler = &contextHandler{next: handler}
+	if cfg.DebugSample > 1 {
+		handler = newSamplingHandler(handler, cfg.DebugSample)
+	}
+
+	return slog.New(handler).With(KeyService, service), nil
+}
+
+// ParseLevel разбирает имя уровня: debug, info, warn (warning) или error
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("unknown log level %q (expected debug, info, warn or error)", name)
+	}
+}
+
+// Fatal пишет запись уровня ERROR и завершает процесс
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// SessionID - поле идентификатора сессии мониторинга
+func SessionID(id string) slog.Attr {
+	return slog.String(KeySessionID, id)
+}
+
+// Metric - поле канала (FHR, UC)
+func Metric(metric fmt.Stringer) slog.Attr {
+	return slog.String(KeyMetric, metric.String())
+}
+
+// BatchTS - поле метки времени батча (мс)
+func BatchTS[T int64 | uint64](ms T) slog.Attr {
+	return slog.Any(KeyBatchTS, ms)
+}
+
+// Err - поле ошибки
+func Err(err error) slog.Attr {
+	return slog.Any(KeyError, err)
+}
+
+// With сохраняет в ctx поля, которые добавляются ко всем записям *Context с этим ctx
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	if len(attrs) == 0 {
+		return ctx
+	}
+	existing, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(existing)+len(attrs))
+	merged = append(merged, existing...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, attrsKey{}, merged)
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,172 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+
+	telemetryv1 "github.com/Krimson/fetal-monitory/proto/telemetry"
+)
+
+// decodeLines разбирает вывод JSON обработчика построчно
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Failed to decode log line %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestNew_JSONFields(t *testing.T) {
+	var buf bytes.Buffer
+	var lv slog.LevelVar
+	logger, err := New(&buf, "receiver", Config{Format: FormatJSON}, &lv)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	logger.Warn("Batch dropped",
+		SessionID("session-1"),
+		Metric(telemetryv1.Metric_METRIC_FHR),
+		BatchTS(int64(1000)),
+		Err(errors.New("queue full")))
+
+	records := decodeLines(t, &buf)
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 record, got %d", len(records))
+	}
+	expected := map[string]any{
+		"level":      "WARN",
+		"msg":        "Batch dropped",
+		KeyService:   "receiver",
+		KeySessionID: "session-1",
+		KeyMetric:    "METRIC_FHR",
+		KeyBatchTS:   float64(1000),
+		KeyError:     "queue full",
+	}
+	for key, want := range expected {
+		if got := records[0][key]; got != want {
+			t.Errorf("Expected %s=%v, got %v", key, want, got)
+		}
+	}
+}
+
+func TestNew_Level(t *testing.T) {
+	var buf bytes.Buffer
+	var lv slog.LevelVar
+	logger, err := New(&buf, "receiver", Config{Level: "warn", Format: FormatJSON}, &lv)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	logger.Info("Hidden")
+	logger.Warn("Shown")
+	lv.Set(slog.LevelDebug)
+	logger.Debug("Shown after level change")
+
+	records := decodeLines(t, &buf)
+	if len(records) != 2 || records[0]["msg"] != "Shown" || records[1]["msg"] != "Shown after level change" {
+		t.Errorf("Unexpected records: %v", records)
+	}
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	var lv slog.LevelVar
+	if _, err := New(&bytes.Buffer{}, "receiver", Config{Level: "verbose"}, &lv); err == nil {
+		t.Error("Expected error for unknown level")
+	}
+	if _, err := New(&bytes.Buffer{}, "receiver", Config{Format: "xml"}, &lv); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	defer level.Set(level.Level())
+
+	if err := SetLevel("debug"); err != nil {
+		t.Fatalf("Failed to set level: %v", err)
+	}
+	if Level() != slog.LevelDebug {
+		t.Errorf("Expected debug level, got %v", Level())
+	}
+	if err := SetLevel("loud"); err == nil {
+		t.Error("Expected error for unknown level")
+	}
+	if Level() != slog.LevelDebug {
+		t.Errorf("Expected level to stay debug after invalid SetLevel, got %v", Level())
+	}
+}
+
+func TestNew_DebugSample(t *testing.T) {
+	var buf bytes.Buffer
+	var lv slog.LevelVar
+	logger, err := New(&buf, "receiver", Config{Level: "debug", Format: FormatJSON, DebugSample: 4}, &lv)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		logger.Debug("Batch", "i", i)
+		logger.With(SessionID("s")).Debug("Prediction", "i", i)
+	}
+	logger.Info("Batcher stats")
+
+	counts := map[string]int{}
+	for _, record := range decodeLines(t, &buf) {
+		counts[record["msg"].(string)]++
+		if record["level"] == "DEBUG" && record["sample_every"] != float64(4) {
+			t.Errorf("Expected sample_every=4 on sampled record, got %v", record)
+		}
+	}
+	// Записи 0, 4 и 8 каждого сообщения; INFO не прореживается
+	if counts["Batch"] != 3 || counts["Prediction"] != 3 || counts["Batcher stats"] != 1 {
+		t.Errorf("Unexpected sampled counts: %v", counts)
+	}
+}
+
+func TestNew_ContextFields(t *testing.T) {
+	var buf bytes.Buffer
+	var lv slog.LevelVar
+	logger, err := New(&buf, "receiver", Config{Format: FormatJSON}, &lv)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0xab},
+		SpanID:     trace.SpanID{0xcd},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+	ctx = With(ctx, SessionID("session-2"))
+
+	logger.InfoContext(ctx, "Processed batch")
+	logger.Info("Without context")
+
+	records := decodeLines(t, &buf)
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	if records[0][KeySessionID] != "session-2" ||
+		records[0][KeyTraceID] != sc.TraceID().String() ||
+		records[0][KeySpanID] != sc.SpanID().String() {
+		t.Errorf("Expected context fields in record, got %v", records[0])
+	}
+	if _, ok := records[1][KeyTraceID]; ok {
+		t.Errorf("Expected no trace fields without context, got %v", records[1])
+	}
+}
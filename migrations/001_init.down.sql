@@ -0,0 +1,9 @@
+-- Откат начальной схемы
+DROP TRIGGER IF EXISTS update_session_metrics_updated_at ON session_metrics;
+DROP FUNCTION IF EXISTS update_updated_at_column();
+
+DROP TABLE IF EXISTS session_raw_data;
+DROP TABLE IF EXISTS session_timeseries;
+DROP TABLE IF EXISTS session_events;
+DROP TABLE IF EXISTS session_metrics;
+DROP TABLE IF EXISTS sessions;
@@ -0,0 +1,3 @@
+-- Откат таблиц клинических тревог
+DROP TABLE IF EXISTS session_alert_history;
+DROP TABLE IF EXISTS session_alerts;
@@ -0,0 +1,39 @@
+-- Клинические тревоги сессии (состояние на момент сохранения)
+CREATE TABLE IF NOT EXISTS session_alerts (
+    id VARCHAR(64) PRIMARY KEY,
+    session_id VARCHAR(64) NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    alert_type VARCHAR(32) NOT NULL, -- 'low_stv', 'tachycardia', 'bradycardia', 'late_decelerations', 'high_risk_prediction'
+    severity VARCHAR(16) NOT NULL,   -- 'warning', 'critical'
+    state VARCHAR(16) NOT NULL,      -- 'active', 'acknowledged', 'resolved'
+    message TEXT,
+    value DOUBLE PRECISION DEFAULT 0,
+    threshold DOUBLE PRECISION DEFAULT 0,
+    raised_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    acknowledged_at TIMESTAMP,
+    acknowledged_by VARCHAR(255),
+    resolved_at TIMESTAMP,
+    resolved_by VARCHAR(255),
+    comment TEXT
+);
+
+CREATE INDEX idx_session_alerts_session_id ON session_alerts(session_id);
+CREATE INDEX idx_session_alerts_state ON session_alerts(state);
+
+-- Журнал изменений состояния тревог: кто и когда поднял, подтвердил, закрыл
+CREATE TABLE IF NOT EXISTS session_alert_history (
+    id BIGSERIAL PRIMARY KEY,
+    alert_id VARCHAR(64) NOT NULL REFERENCES session_alerts(id) ON DELETE CASCADE,
+    session_id VARCHAR(64) NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    from_state VARCHAR(16),
+    to_state VARCHAR(16) NOT NULL,
+    changed_by VARCHAR(255) NOT NULL,
+    comment TEXT,
+    changed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_session_alert_history_alert_id ON session_alert_history(alert_id);
+CREATE INDEX idx_session_alert_history_session_id ON session_alert_history(session_id);
+
+COMMENT ON TABLE session_alerts IS 'Клинические тревоги сессии';
+COMMENT ON TABLE session_alert_history IS 'Журнал подтверждений и закрытия тревог';
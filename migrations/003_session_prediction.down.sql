@@ -0,0 +1 @@
+ALTER TABLE session_metrics DROP COLUMN IF EXISTS prediction;
@@ -0,0 +1,2 @@
+-- Последнее предсказание ML сервиса сохраняется вместе с метриками сессии (нужно для отчетов)
+ALTER TABLE session_metrics ADD COLUMN IF NOT EXISTS prediction DOUBLE PRECISION DEFAULT 0;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS session_raw_samples;
+
+COMMENT ON TABLE session_raw_data IS 'Raw данные для replay и анализа';
@@ -0,0 +1,17 @@
+-- Сырые сэмплы телеметрии в порядке поступления, append-only чанками.
+-- Без внешнего ключа на sessions: сэмплы пишутся во время записи,
+-- а строка сессии появляется только при сохранении.
+CREATE TABLE IF NOT EXISTS session_raw_samples (
+    id BIGSERIAL PRIMARY KEY,
+    session_id VARCHAR(64) NOT NULL,
+    t0_ms BIGINT NOT NULL,
+    t1_ms BIGINT NOT NULL,
+    sample_count INTEGER NOT NULL,
+    samples JSONB NOT NULL, -- {"ts_ms": [...], "metric": [...], "value": [...]}, metric - telemetry.v1.Metric
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_raw_samples_session ON session_raw_samples(session_id, id);
+
+COMMENT ON TABLE session_raw_samples IS 'Сырые сэмплы телеметрии для replay';
+COMMENT ON TABLE session_raw_data IS 'Отфильтрованные сигналы сохраненной сессии';
@@ -0,0 +1,16 @@
+-- Прореженные (min/max по бакетам) трассы сохраненных сессий для обзорных графиков.
+-- Каждый уровень пирамиды - вся трасса с бакетом bucket_sec; уровни строятся при
+-- сохранении сессии, а для старых сессий - при первом запросе.
+CREATE TABLE IF NOT EXISTS session_signal_pyramids (
+    session_id VARCHAR(64) NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    metric_type VARCHAR(10) NOT NULL,          -- 'FHR' или 'UC', как в session_raw_data
+    bucket_sec DOUBLE PRECISION NOT NULL,
+    point_count INTEGER NOT NULL,
+    t0_sec DOUBLE PRECISION NOT NULL,          -- Первая и последняя точка уровня
+    t1_sec DOUBLE PRECISION NOT NULL,
+    data JSONB NOT NULL,                       -- {"time_sec": [...], "value": [...]}
+    created_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (session_id, metric_type, bucket_sec)
+);
+
+COMMENT ON TABLE session_signal_pyramids IS 'Пирамиды прореженных трасс для обзорных графиков';
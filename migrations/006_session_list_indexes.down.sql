@@ -0,0 +1,7 @@
+-- Откат индексов списка сессий
+DROP INDEX IF EXISTS idx_session_metrics_prediction;
+DROP INDEX IF EXISTS idx_sessions_facility_id;
+DROP INDEX IF EXISTS idx_sessions_doctor_id;
+DROP INDEX IF EXISTS idx_sessions_patient_id;
+DROP INDEX IF EXISTS idx_sessions_status_started_at;
+DROP INDEX IF EXISTS idx_sessions_started_at_id;
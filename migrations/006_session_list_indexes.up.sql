@@ -0,0 +1,8 @@
+-- Индексы для фильтрации и курсорной пагинации списка сессий (GET /api/sessions).
+-- Страницы читаются по ключу (started_at, id); фильтры по метаданным - выражения над JSONB.
+CREATE INDEX IF NOT EXISTS idx_sessions_started_at_id ON sessions(started_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_sessions_status_started_at ON sessions(status, started_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_sessions_patient_id ON sessions((metadata->>'patient_id'), started_at DESC);
+CREATE INDEX IF NOT EXISTS idx_sessions_doctor_id ON sessions((metadata->>'doctor_id'), started_at DESC);
+CREATE INDEX IF NOT EXISTS idx_sessions_facility_id ON sessions((metadata->>'facility_id'), started_at DESC);
+CREATE INDEX IF NOT EXISTS idx_session_metrics_prediction ON session_metrics(prediction);
@@ -0,0 +1,4 @@
+-- Откат карточек пациенток
+DROP INDEX IF EXISTS idx_sessions_patient_ref;
+ALTER TABLE sessions DROP COLUMN IF EXISTS patient_id;
+DROP TABLE IF EXISTS patients;
@@ -0,0 +1,23 @@
+-- Карточки пациенток и ссылка сессий на карточку для истории КТГ по беременности.
+-- sessions.patient_id заполняется, если metadata.patient_id совпадает с ID карточки.
+CREATE TABLE IF NOT EXISTS patients (
+    id VARCHAR(64) PRIMARY KEY,
+    mrn VARCHAR(64) UNIQUE,                    -- Номер медицинской карты
+    full_name TEXT NOT NULL DEFAULT '',
+    birth_date DATE,
+    edd DATE,                                  -- Предполагаемая дата родов
+    gravidity INTEGER NOT NULL DEFAULT 0,
+    parity INTEGER NOT NULL DEFAULT 0,
+    risk_factors TEXT[] NOT NULL DEFAULT '{}',
+    notes TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_patients_full_name ON patients(full_name);
+
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS patient_id VARCHAR(64) REFERENCES patients(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_patient_ref ON sessions(patient_id, started_at);
+
+COMMENT ON TABLE patients IS 'Карточки пациенток';
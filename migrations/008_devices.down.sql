@@ -0,0 +1,3 @@
+-- Откат реестра устройств
+DROP TABLE IF EXISTS device_sessions;
+DROP TABLE IF EXISTS devices;
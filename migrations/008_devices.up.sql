@@ -0,0 +1,26 @@
+-- Реестр мониторов (устройств), отправляющих телеметрию, и их привязка к сессиям.
+-- Устройство аутентифицируется API ключом (хранится только SHA-256) или клиентским
+-- сертификатом, CN которого равен ID устройства. Привязка может предшествовать сессии.
+CREATE TABLE IF NOT EXISTS devices (
+    id VARCHAR(64) PRIMARY KEY,
+    name TEXT NOT NULL DEFAULT '',
+    model TEXT NOT NULL DEFAULT '',
+    location TEXT NOT NULL DEFAULT '',
+    api_key_hash CHAR(64) UNIQUE,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    last_seen_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS device_sessions (
+    device_id VARCHAR(64) NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    session_id VARCHAR(64) NOT NULL,
+    bound_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (device_id, session_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_sessions_session ON device_sessions(session_id);
+
+COMMENT ON TABLE devices IS 'Зарегистрированные мониторы';
+COMMENT ON TABLE device_sessions IS 'Сессии, в которые устройству разрешено отправлять сэмплы';
@@ -0,0 +1,3 @@
+-- Откат журнала доступа
+DROP TABLE IF EXISTS audit_log;
+DROP FUNCTION IF EXISTS audit_log_append_only();
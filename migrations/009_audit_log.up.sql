@@ -0,0 +1,39 @@
+-- Журнал доступа к данным пациенток. Записи связаны цепочкой SHA-256 (prev_hash -> hash),
+-- которую проверяет GET /api/audit/verify; изменение и удаление записей запрещено триггерами.
+CREATE TABLE IF NOT EXISTS audit_log (
+    seq BIGINT PRIMARY KEY,                    -- Без пропусков: пропуск означает удаленную запись
+    occurred_at TIMESTAMPTZ NOT NULL,
+    actor VARCHAR(128) NOT NULL,               -- sub из JWT или anonymous
+    role VARCHAR(32) NOT NULL DEFAULT '',
+    action VARCHAR(32) NOT NULL,               -- view, create, update, delete, export, import, subscribe, ...
+    session_id VARCHAR(64),
+    patient_id VARCHAR(64),
+    method VARCHAR(10) NOT NULL DEFAULT '',
+    resource TEXT NOT NULL DEFAULT '',         -- Шаблон маршрута
+    status INTEGER NOT NULL DEFAULT 0,
+    outcome VARCHAR(16) NOT NULL,              -- success, denied, failure
+    remote_addr VARCHAR(64) NOT NULL DEFAULT '',
+    prev_hash CHAR(64) NOT NULL,
+    hash CHAR(64) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_session ON audit_log(session_id, seq) WHERE session_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_audit_log_patient ON audit_log(patient_id, seq) WHERE patient_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor, seq);
+CREATE INDEX IF NOT EXISTS idx_audit_log_occurred ON audit_log(occurred_at);
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_no_modify ON audit_log;
+CREATE TRIGGER audit_log_no_modify BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+DROP TRIGGER IF EXISTS audit_log_no_truncate ON audit_log;
+CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
+
+COMMENT ON TABLE audit_log IS 'Append-only журнал доступа с цепочкой хешей';
@@ -0,0 +1,4 @@
+-- Откат append-only журнала тревог
+DROP TRIGGER IF EXISTS session_alert_history_no_update ON session_alert_history;
+DROP FUNCTION IF EXISTS session_alert_history_append_only();
+DROP INDEX IF EXISTS idx_session_alert_history_transition;
@@ -0,0 +1,22 @@
+-- Журнал изменений тревог только дополняется: повторное сохранение тревоги
+-- вставляет лишь недостающие записи (ON CONFLICT DO NOTHING по ключу перехода).
+-- Удаление остается возможным только каскадом вместе с тревогой или сессией.
+DELETE FROM session_alert_history a
+    USING session_alert_history b
+    WHERE a.alert_id = b.alert_id
+      AND a.to_state = b.to_state
+      AND a.changed_at = b.changed_at
+      AND a.id > b.id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_session_alert_history_transition
+    ON session_alert_history(alert_id, to_state, changed_at);
+
+CREATE OR REPLACE FUNCTION session_alert_history_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'session_alert_history is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS session_alert_history_no_update ON session_alert_history;
+CREATE TRIGGER session_alert_history_no_update BEFORE UPDATE ON session_alert_history
+    FOR EACH ROW EXECUTE FUNCTION session_alert_history_append_only();
@@ -0,0 +1,2 @@
+-- Откат индекса постраничного чтения событий
+DROP INDEX IF EXISTS idx_session_events_session_start_id;
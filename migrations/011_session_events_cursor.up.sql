@@ -0,0 +1,2 @@
+-- Постраничное чтение событий сессии по ключу (start_time, id) (GET /api/sessions/{id}/events)
+CREATE INDEX IF NOT EXISTS idx_session_events_session_start_id ON session_events(session_id, start_time, id);
@@ -0,0 +1,6 @@
+-- Откат триграммных индексов поиска сессий. Расширение pg_trgm остается:
+-- им могут пользоваться объекты вне миграций
+DROP INDEX IF EXISTS idx_sessions_notes_trgm;
+DROP INDEX IF EXISTS idx_sessions_doctor_id_trgm;
+DROP INDEX IF EXISTS idx_sessions_patient_id_trgm;
+DROP INDEX IF EXISTS idx_sessions_id_trgm;
@@ -0,0 +1,7 @@
+-- Триграммные индексы для поиска по подстроке в списке сессий (GET /api/sessions?q=).
+-- Поиск - ILIKE '%q%' по ID сессии и полям метаданных; B-tree такие шаблоны не использует.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS idx_sessions_id_trgm ON sessions USING gin (id gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_sessions_patient_id_trgm ON sessions USING gin ((metadata->>'patient_id') gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_sessions_doctor_id_trgm ON sessions USING gin ((metadata->>'doctor_id') gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_sessions_notes_trgm ON sessions USING gin ((metadata->>'notes') gin_trgm_ops);
@@ -0,0 +1,94 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Usage - справка по подкоманде migrate
+const Usage = `usage: migrate <command>
+
+commands:
+  up          apply all pending migrations
+  down [N]    roll back the last N migrations (default 1)
+  status      list migrations and whether they are applied
+  version     print the current schema version`
+
+// RunCommand выполняет подкоманду migrate (общая для receiver и offline-service)
+func RunCommand(ctx context.Context, db *sql.DB, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing migrate command\n%s", Usage)
+	}
+
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		count, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "applied %d migration(s)\n", count)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps: %s", args[1])
+			}
+		}
+		count, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "rolled back %d migration(s)\n", count)
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if status.Modified {
+				state += " (modified)"
+			}
+			fmt.Fprintf(out, "%03d_%-30s %s\n", status.Version, status.Name, state)
+		}
+
+	case "version":
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%d (latest %d)\n", version, migrator.Latest())
+
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", args[0], Usage)
+	}
+
+	return nil
+}
+
+// Migrate применяет непримененные миграции при старте сервиса и возвращает версию схемы
+func Migrate(ctx context.Context, db *sql.DB) (int, error) {
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := migrator.Up(ctx); err != nil {
+		return 0, err
+	}
+
+	return migrator.Version(ctx)
+}
@@ -0,0 +1,122 @@
+// Package migrations содержит версионированные миграции схемы PostgreSQL,
+// общие для receiver и offline-service, и раннер для их применения.
+//
+// Миграции встроены в бинарник. Файлы называются NNN_name.up.sql и NNN_name.down.sql,
+// где NNN - номер версии. Примененные версии хранятся в таблице schema_migrations
+// вместе с контрольной суммой up-скрипта.
+package migrations
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// Migration - одна версия схемы
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string // Пусто - откат не поддерживается
+	Checksum string // sha256 up-скрипта
+}
+
+// Load возвращает встроенные миграции, отсортированные по версии
+func Load() ([]Migration, error) {
+	return load(files)
+}
+
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+
+		version, title, direction, err := parseFileName(name)
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", name, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		} else if m.Name != title {
+			return nil, fmt.Errorf("migration %d has conflicting names: %s and %s", version, m.Name, title)
+		}
+
+		switch direction {
+		case "up":
+			m.Up = string(content)
+			m.Checksum = checksum(content)
+		case "down":
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d (%s) has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// parseFileName разбирает имя вида 001_init.up.sql
+func parseFileName(name string) (version int, title, direction string, err error) {
+	base := strings.TrimSuffix(name, ".sql")
+
+	switch {
+	case strings.HasSuffix(base, ".up"):
+		direction = "up"
+	case strings.HasSuffix(base, ".down"):
+		direction = "down"
+	default:
+		return 0, "", "", fmt.Errorf("invalid migration file name %s: expected NNN_name.up.sql or NNN_name.down.sql", name)
+	}
+	base = strings.TrimSuffix(base, "."+direction)
+
+	prefix, title, ok := strings.Cut(base, "_")
+	if !ok || title == "" {
+		return 0, "", "", fmt.Errorf("invalid migration file name %s: expected NNN_name", name)
+	}
+
+	version, err = strconv.Atoi(prefix)
+	if err != nil || version <= 0 {
+		return 0, "", "", fmt.Errorf("invalid migration version in %s", name)
+	}
+
+	return version, title, direction, nil
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,80 @@
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad_EmbeddedMigrations(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load embedded migrations: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatalf("Expected embedded migrations")
+	}
+
+	if first := migrations[0]; first.Version != baselineVersion || first.Down == "" {
+		t.Errorf("Expected baseline migration %d with down script, got version=%d down=%v",
+			baselineVersion, first.Version, first.Down != "")
+	}
+
+	for i, m := range migrations {
+		if m.Checksum == "" {
+			t.Errorf("Migration %d has no checksum", m.Version)
+		}
+		if i > 0 && m.Version <= migrations[i-1].Version {
+			t.Errorf("Migrations are not strictly ordered: %d after %d", m.Version, migrations[i-1].Version)
+		}
+	}
+}
+
+func TestLoad_Validation(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{
+			name:  "missing up script",
+			files: fstest.MapFS{"002_add.down.sql": {Data: []byte("DROP TABLE x;")}},
+		},
+		{
+			name:  "no direction",
+			files: fstest.MapFS{"002_add.sql": {Data: []byte("CREATE TABLE x ();")}},
+		},
+		{
+			name:  "bad version",
+			files: fstest.MapFS{"abc_add.up.sql": {Data: []byte("CREATE TABLE x ();")}},
+		},
+		{
+			name: "conflicting names",
+			files: fstest.MapFS{
+				"002_add.up.sql":     {Data: []byte("CREATE TABLE x ();")},
+				"002_other.down.sql": {Data: []byte("DROP TABLE x;")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := load(tt.files); err == nil {
+				t.Errorf("Expected error for %s", tt.name)
+			}
+		})
+	}
+}
+
+func TestLoad_ChecksumTracksContent(t *testing.T) {
+	a, err := load(fstest.MapFS{"001_init.up.sql": {Data: []byte("CREATE TABLE a ();")}})
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+	b, err := load(fstest.MapFS{"001_init.up.sql": {Data: []byte("CREATE TABLE b ();")}})
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+
+	if a[0].Checksum == b[0].Checksum {
+		t.Errorf("Expected checksum to change with migration content")
+	}
+}
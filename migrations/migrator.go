@@ -0,0 +1,351 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Krimson/fetal-monitory/logging"
+)
+
+// advisoryLockID - ключ pg_advisory_lock, чтобы receiver и offline-service
+// не применяли миграции одновременно
+const advisoryLockID int64 = 0x66657461 // "feta"
+
+// baselineVersion - версия, которой соответствует схема, созданная до появления раннера
+// (001_init.sql через docker-entrypoint-initdb.d)
+const baselineVersion = 1
+
+// ErrChecksumMismatch - примененная миграция была изменена после применения
+var ErrChecksumMismatch = errors.New("migration checksum mismatch")
+
+// Status - состояние одной миграции
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	Modified  bool       `json:"modified"` // Контрольная сумма не совпадает с примененной
+}
+
+// appliedMigration - запись schema_migrations
+type appliedMigration struct {
+	version   int
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// Migrator применяет и откатывает миграции
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewMigrator создает раннер для встроенных миграций
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Latest возвращает номер последней известной миграции
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up применяет все непримененные миграции и возвращает их количество.
+// Перед применением проверяются контрольные суммы уже примененных миграций.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	conn, unlock, err := m.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	applied, err := loadApplied(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(applied) == 0 {
+		if applied, err = m.baseline(ctx, conn); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := m.verify(applied); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		start := time.Now()
+		if err := applyUp(ctx, conn, migration); err != nil {
+			return count, err
+		}
+		count++
+
+		slog.Info("Applied migration", "version", migration.Version, "name", migration.Name, "duration", time.Since(start))
+	}
+
+	return count, nil
+}
+
+// Down откатывает steps последних примененных миграций и возвращает их количество
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	conn, unlock, err := m.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	applied, err := loadApplied(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if migration.Down == "" {
+			return count, fmt.Errorf("migration %03d_%s has no down script", migration.Version, migration.Name)
+		}
+
+		if err := applyDown(ctx, conn, migration); err != nil {
+			return count, err
+		}
+		count++
+
+		slog.Info("Rolled back migration", "version", migration.Version, "name", migration.Name)
+	}
+
+	return count, nil
+}
+
+// Status возвращает состояние всех известных миграций
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	applied, err := loadApplied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			appliedAt := record.appliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+			status.Modified = record.checksum != migration.Checksum
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Version возвращает номер последней примененной миграции (0 - схема пуста)
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	applied, err := loadApplied(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+
+	version := 0
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+// lock берет выделенное соединение и advisory lock на нем
+func (m *Migrator) lock(ctx context.Context) (*sql.Conn, func(), error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", advisoryLockID); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+
+	unlock := func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", advisoryLockID); err != nil {
+			slog.Warn("Failed to release migration lock", logging.Err(err))
+		}
+		conn.Close()
+	}
+
+	return conn, unlock, nil
+}
+
+// verify проверяет, что примененные миграции не изменились
+func (m *Migrator) verify(applied map[int]appliedMigration) error {
+	known := make(map[int]Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+
+	for version, record := range applied {
+		migration, ok := known[version]
+		if !ok {
+			slog.Warn("Database has migration unknown to this build", "version", version, "name", record.name)
+			continue
+		}
+		if record.checksum != migration.Checksum {
+			return fmt.Errorf("%w: %03d_%s was modified after it was applied", ErrChecksumMismatch, version, migration.Name)
+		}
+	}
+
+	return nil
+}
+
+// baseline отмечает начальную миграцию примененной, если схема была создана
+// до появления раннера. Для пустой базы ничего не делает.
+func (m *Migrator) baseline(ctx context.Context, conn *sql.Conn) (map[int]appliedMigration, error) {
+	applied := make(map[int]appliedMigration)
+
+	var exists bool
+	if err := conn.QueryRowContext(ctx, "SELECT to_regclass('public.sessions') IS NOT NULL").Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to inspect existing schema: %w", err)
+	}
+	if !exists {
+		return applied, nil
+	}
+
+	for _, migration := range m.migrations {
+		if migration.Version != baselineVersion {
+			continue
+		}
+
+		record := appliedMigration{
+			version:   migration.Version,
+			name:      migration.Name,
+			checksum:  migration.Checksum,
+			appliedAt: time.Now(),
+		}
+		if _, err := conn.ExecContext(ctx,
+			"INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES ($1, $2, $3, $4)",
+			record.version, record.name, record.checksum, record.appliedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to record baseline migration: %w", err)
+		}
+		applied[record.version] = record
+
+		slog.Info("Existing schema detected, marked migration as applied", "version", migration.Version, "name", migration.Name)
+	}
+
+	return applied, nil
+}
+
+// loadApplied создает таблицу schema_migrations при необходимости и читает ее
+func loadApplied(ctx context.Context, conn *sql.Conn) (map[int]appliedMigration, error) {
+	_, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			checksum VARCHAR(64) NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var record appliedMigration
+		if err := rows.Scan(&record.version, &record.name, &record.checksum, &record.appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations: %w", err)
+		}
+		applied[record.version] = record
+	}
+
+	return applied, rows.Err()
+}
+
+// applyUp выполняет up-скрипт и записывает версию в одной транзакции
+func applyUp(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+		return fmt.Errorf("failed to apply migration %03d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)",
+		migration.Version, migration.Name, migration.Checksum,
+	); err != nil {
+		return fmt.Errorf("failed to record migration %03d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %03d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	return nil
+}
+
+// applyDown выполняет down-скрипт и удаляет версию в одной транзакции
+func applyDown(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+		return fmt.Errorf("failed to roll back migration %03d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", migration.Version); err != nil {
+		return fmt.Errorf("failed to unrecord migration %03d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rollback of %03d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	return nil
+}
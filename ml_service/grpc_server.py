@@ -3,6 +3,8 @@ import logging
 from concurrent import futures
 from typing import Dict
 import grpc
+from grpc_health.v1 import health, health_pb2, health_pb2_grpc
+from opentelemetry import trace
 
 # Импорты для gRPC
 import ml_service_pb2
@@ -10,10 +12,12 @@ import ml_service_pb2_grpc
 
 from collector import FeatureCollector
 from inference import ClassifierModel
+from tracing import setup_tracing, shutdown_tracing
 
 # Настройка логирования
 logging.basicConfig(level=logging.INFO)
 logger = logging.getLogger(__name__)
+tracer = trace.get_tracer(__name__)
 
 class MLService(ml_service_pb2_grpc.MLServiceServicer):
     """
@@ -128,7 +132,10 @@ class MLService(ml_service_pb2_grpc.MLServiceServicer):
             
             # Запускаем инференс в отдельном потоке чтобы не блокировать event loop
             loop = asyncio.get_event_loop()
-            prediction_array = await loop.run_in_executor(None, self.model, features_df)
+            with tracer.start_as_current_span("ClassifierModel.predict") as span:
+                span.set_attribute("session.id", session_id)
+                span.set_attribute("collector.samples", collector.get_count())
+                prediction_array = await loop.run_in_executor(None, self.model, features_df)
             
             # prediction_array это numpy array с вероятностями для каждого семпла
             # Берем первое (и единственное) значение
@@ -206,10 +213,18 @@ class MLService(ml_service_pb2_grpc.MLServiceServicer):
 
 async def serve():
     """Запускает gRPC сервер"""
-    server = grpc.aio.server(futures.ThreadPoolExecutor(max_workers=10))
+    # Контекст трассировки приходит от receiver в gRPC metadata
+    interceptors = setup_tracing("ml-service")
+    server = grpc.aio.server(futures.ThreadPoolExecutor(max_workers=10), interceptors=interceptors)
     
     service = MLService(model_type="catboost", weights_folder="./weights")
     ml_service_pb2_grpc.add_MLServiceServicer_to_server(service, server)
+
+    # Стандартный grpc.health.v1: по нему receiver проверяет готовность (/readyz)
+    health_servicer = health.aio.HealthServicer()
+    health_pb2_grpc.add_HealthServicer_to_server(health_servicer, server)
+    for name in ("", ml_service_pb2.DESCRIPTOR.services_by_name["MLService"].full_name):
+        await health_servicer.set(name, health_pb2.HealthCheckResponse.SERVING)
     
     listen_addr = '[::]:50053'
     server.add_insecure_port(listen_addr)
@@ -221,7 +236,9 @@ async def serve():
         await server.wait_for_termination()
     except KeyboardInterrupt:
         logger.info("Shutting down ML gRPC server...")
+        await health_servicer.enter_graceful_shutdown()
         await server.stop(5)
+        shutdown_tracing()
 
 if __name__ == '__main__':
     asyncio.run(serve())
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/Krimson/fetal-monitory/audit"
+	"github.com/Krimson/fetal-monitory/auth"
+
+	"offline-service/config"
+)
+
+// httpPolicy - доступ к HTTP API offline-service по ролям
+var httpPolicy = auth.Policy{
+	{PathPrefix: "/swagger/", Public: true},
+	{PathPrefix: "/debug/", Roles: []auth.Role{auth.RoleAdmin}},
+	// Импорт архива создает сессии в общей БД - только врач или администратор
+	{PathPrefix: "/import", Roles: []auth.Role{auth.RoleClinician, auth.RoleAdmin}},
+	{PathPrefix: "/", Roles: auth.Staff},
+}
+
+// newAuthenticator - создает аутентификатор из настроек AUTH_*
+func newAuthenticator(cfg *config.Config) (*auth.Authenticator, error) {
+	key, err := auth.LoadKey(cfg.AuthJWTSecret, cfg.AuthJWTSecretFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("AUTH_JWT_SECRET or AUTH_JWT_SECRET_FILE is required")
+	}
+	return auth.NewAuthenticator(key, cfg.AuthIssuer)
+}
+
+// auditedRoutes - маршруты с данными пациентов, которые пишутся в журнал доступа
+var auditedRoutes = map[string]string{
+	"/upload":      audit.ActionCreate,
+	"/upload-dual": audit.ActionCreate,
+	"/session":     audit.ActionView,
+	"/export":      audit.ActionExport,
+	"/import":      audit.ActionImport,
+}
+
+// identify - определяет субъекта запроса для журнала доступа. Журнал стоит до проверки
+// доступа, поэтому токен разбирается здесь же; невалидный токен - аноним
+func identify(authenticator *auth.Authenticator) audit.IdentifyFunc {
+	return func(r *http.Request) (string, string) {
+		if authenticator == nil {
+			return "", ""
+		}
+		claims, err := authenticator.Verify(auth.TokenFromRequest(r))
+		if err != nil {
+			return "", ""
+		}
+		return claims.Subject, string(claims.Role)
+	}
+}
+
+// runToken - выполняет подкоманду `offline-service token -sub <id> -role <role> [-ttl 12h]`
+func runToken(cfg *config.Config, args []string) error {
+	authenticator, err := newAuthenticator(cfg)
+	if err != nil {
+		return err
+	}
+	return auth.RunCommand(authenticator, args, os.Stdout)
+}
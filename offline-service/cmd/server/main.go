@@ -3,7 +3,8 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -14,6 +15,11 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 
+	"github.com/Krimson/fetal-monitory/audit"
+	"github.com/Krimson/fetal-monitory/auth"
+	"github.com/Krimson/fetal-monitory/logging"
+	"github.com/Krimson/fetal-monitory/migrations"
+
 	"offline-service/config"
 	"offline-service/internal/handler"
 	"offline-service/internal/pb"
@@ -31,6 +37,11 @@ import (
 // @description Этот сервис обрабатывает CSV файлы с данными о сердцебиении плода (BPM) и маточными сокращениями (UC).
 // @description Выполняет фильтрацию, анализ признаков и ML предсказание.
 // @description
+// @description ## Аутентификация
+// @description При AUTH_ENABLED=true (по умолчанию) все endpoints, кроме /swagger, требуют JWT в заголовке `Authorization: Bearer <token>`
+// @description с ролью clinician, midwife или admin (/import - clinician или admin, /debug - admin).
+// @description Ключ и издатель общие с receiver, токены выпускает `offline-service token` или `receiver token`.
+// @description
 // @termsOfService http://swagger.io/terms/
 
 // @contact.name API Support
@@ -39,6 +50,11 @@ import (
 // @license.name MIT
 // @license.url https://opensource.org/licenses/MIT
 
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description JWT в формате "Bearer <token>"
+
 // @host localhost:8081
 // @BasePath /
 // @schemes http
@@ -47,6 +63,43 @@ func main() {
 	// Загрузка конфигурации
 	cfg := config.LoadConfig()
 
+	// Структурированный журнал (LOG_LEVEL, LOG_FORMAT, LOG_DEBUG_SAMPLE)
+	if err := logging.Setup("offline-service", logging.ConfigFromEnv()); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid logging configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Подкоманда: offline-service migrate <up|down [N]|status|version>
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, os.Args[2:]); err != nil {
+			logging.Fatal("Migration failed", logging.Err(err))
+		}
+		return
+	}
+
+	// Подкоманда: offline-service token -sub <id> -role <role> [-ttl 12h]
+	if len(os.Args) > 1 && os.Args[1] == "token" {
+		if err := runToken(cfg, os.Args[2:]); err != nil {
+			logging.Fatal("Failed to issue token", logging.Err(err))
+		}
+		return
+	}
+
+	// Аутентификация и ролевой доступ
+	var authenticator *auth.Authenticator
+	if cfg.AuthEnabled {
+		a, err := newAuthenticator(cfg)
+		if err != nil {
+			logging.Fatal("Failed to initialize authentication", logging.Err(err))
+		}
+		authenticator = a
+		slog.Info("Authentication enabled", "issuer", cfg.AuthIssuer)
+	} else if cfg.AuthInsecureDev {
+		slog.Warn("Authentication is disabled (AUTH_INSECURE_DEV=true): HTTP API is open")
+	} else {
+		logging.Fatal("AUTH_ENABLED=false is allowed only with AUTH_INSECURE_DEV=true (local development)")
+	}
+
 	// Инициализация REAL Redis вместо заглушки
 	redisRepo := repository.NewRedisRepository(
 		cfg.RedisAddr,
@@ -58,31 +111,50 @@ func main() {
 	// Проверяем подключение к Redis
 	ctx := context.Background()
 	if err := redisRepo.CheckConnection(ctx); err != nil {
-		log.Printf("⚠️  Failed to connect to Redis: %v", err)
+		slog.Warn("Failed to connect to Redis", logging.Err(err))
 		return
 	} else {
-		log.Println("✅ Connected to REAL Redis")
+		slog.Info("Connected to Redis", "addr", cfg.RedisAddr)
 	}
 	defer redisRepo.Close()
 
 	postgresRepo, err := repository.NewPostgreSQLRepository(cfg.PostgreSQLConnStr)
 	if err != nil {
-		log.Printf("⚠️  PostgreSQL unavailable: %v", err)
+		slog.Warn("PostgreSQL unavailable", logging.Err(err))
 		return
 	} else {
-		log.Println("✅ Connected to REAL PostgreSQL (save only)")
+		slog.Info("Connected to PostgreSQL (save only)")
 	}
 	defer postgresRepo.Close()
 
-	log.Println("🚀 Using STUB repositories (Redis & PostgreSQL)")
+	if cfg.MigrateOnStart {
+		version, err := migrations.Migrate(ctx, postgresRepo.DB())
+		if err != nil {
+			slog.Error("Failed to migrate database schema", logging.Err(err))
+			return
+		}
+		slog.Info("Database schema version", "version", version)
+	}
+
+	slog.Info("Using STUB repositories (Redis & PostgreSQL)")
+
+	// Журнал доступа к данным пациентов (та же таблица, что у receiver)
+	var auditLogger *audit.Logger
+	if cfg.AuditEnabled {
+		auditLogger = audit.NewLogger(audit.NewPostgresRepository(postgresRepo.DB()))
+		defer auditLogger.Stop()
+		slog.Info("Audit log enabled")
+	} else {
+		slog.Warn("Audit log is disabled (AUDIT_ENABLED=false): access to patient data is not recorded")
+	}
 
 	// Инициализация gRPC клиентов (заглушки уже запущены отдельно)
 	filterConn, err := grpc.NewClient(cfg.FilterServiceAddr,
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
 	)
 	if err != nil {
-		log.Printf("⚠️  Failed to connect to filter service: %v", err)
-		log.Println("⚠️  Make sure filter stub is running on", cfg.FilterServiceAddr)
+		slog.Warn("Failed to connect to filter service", logging.Err(err))
+		slog.Warn("Make sure filter stub is running", "addr", cfg.FilterServiceAddr)
 	} else {
 		defer filterConn.Close()
 	}
@@ -91,8 +163,8 @@ func main() {
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
 	)
 	if err != nil {
-		log.Printf("⚠️  Failed to connect to ML service: %v", err)
-		log.Println("⚠️  Make sure ML stub is running on", cfg.MLServiceAddr)
+		slog.Warn("Failed to connect to ML service", logging.Err(err))
+		slog.Warn("Make sure ML stub is running", "addr", cfg.MLServiceAddr)
 	} else {
 		defer mlConn.Close()
 	}
@@ -107,9 +179,9 @@ func main() {
 			redisRepo,
 			postgresRepo,
 		)
-		log.Printf("✅ Connected to gRPC services: %s, %s", cfg.FilterServiceAddr, cfg.MLServiceAddr)
+		slog.Info("Connected to gRPC services", "filter", cfg.FilterServiceAddr, "ml", cfg.MLServiceAddr)
 	} else {
-		log.Println("❌ Running without gRPC services - some functionality will be limited")
+		slog.Warn("Running without gRPC services - some functionality will be limited")
 		// Можно создать сервис с nil клиентами, если обработать это в коде
 	}
 
@@ -122,6 +194,8 @@ func main() {
 	mux.HandleFunc("/upload-dual", httpHandler.UploadDualCSV)
 	mux.HandleFunc("/decision", httpHandler.HandleDecision)
 	mux.HandleFunc("/session", httpHandler.GetSessionData) // Новый endpoint
+	mux.HandleFunc("/export", httpHandler.ExportSession)
+	mux.HandleFunc("/import", httpHandler.ImportArchive)
 
 	// Swagger UI
 	mux.HandleFunc("/swagger/", func(w http.ResponseWriter, r *http.Request) {
@@ -145,7 +219,15 @@ func main() {
 		json.NewEncoder(w).Encode(stats)
 	})
 
-	handlerWithCORS := enableCORS(mux)
+	var apiHandler http.Handler = mux
+	if authenticator != nil {
+		apiHandler = authenticator.Middleware(httpPolicy)(apiHandler)
+	}
+	if auditLogger != nil {
+		// Снаружи проверки токена, чтобы в журнал попадали и отказы в доступе
+		apiHandler = audit.PathMiddleware(auditLogger, identify(authenticator), auditedRoutes)(apiHandler)
+	}
+	handlerWithCORS := enableCORS(auth.ParseOrigins(cfg.CORSAllowedOrigins), apiHandler)
 	// Настройка HTTP сервера
 	server := &http.Server{
 		Addr:         ":" + cfg.HTTPPort,
@@ -157,12 +239,12 @@ func main() {
 
 	// Graceful shutdown
 	go func() {
-		log.Printf("✅ Medical service starting on port %s", cfg.HTTPPort)
-		log.Printf("✅ Using STUB repositories")
-		log.Printf("✅ Debug stats available at http://localhost:%s/debug/stats", cfg.HTTPPort)
+		slog.Info("Medical service starting", "port", cfg.HTTPPort)
+		slog.Info("Using STUB repositories")
+		slog.Info("Debug stats available", "url", "http://localhost:"+cfg.HTTPPort+"/debug/stats")
 
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("❌ Server failed: %v", err)
+			logging.Fatal("Server failed", logging.Err(err))
 		}
 	}()
 
@@ -170,23 +252,30 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	log.Println("🛑 Shutting down server...")
+	slog.Info("Shutting down server...")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("❌ Server forced to shutdown: %v", err)
+		logging.Fatal("Server forced to shutdown", logging.Err(err))
 	}
 
-	log.Println("✅ Server exited gracefully")
+	slog.Info("Server exited gracefully")
 }
 
-func enableCORS(next http.Handler) http.Handler {
+func enableCORS(origins auth.Origins, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+		origin := r.Header.Get("Origin")
+		switch {
+		case origins.Any():
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		case origin != "" && origins.Allowed(origin):
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+		}
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if r.Method == "OPTIONS" {
 			return
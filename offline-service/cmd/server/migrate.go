@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	"github.com/Krimson/fetal-monitory/migrations"
+
+	"offline-service/config"
+)
+
+// migrateTimeout - ограничение на выполнение подкоманды migrate
+const migrateTimeout = 5 * time.Minute
+
+// runMigrate - выполняет подкоманду `offline-service migrate <up|down [N]|status|version>`
+func runMigrate(cfg *config.Config, args []string) error {
+	db, err := sql.Open("postgres", cfg.PostgreSQLConnStr)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), migrateTimeout)
+	defer cancel()
+
+	return migrations.RunCommand(ctx, db, args, os.Stdout)
+}
@@ -15,9 +15,21 @@ type Config struct {
 	RedisTTL      time.Duration `default:"24h"`
 
 	PostgreSQLConnStr string `default:"host=localhost port=5432 user=fetal_user password=fetal_pass dbname=fetal_monitor sslmode=disable"`
+	MigrateOnStart    bool   `default:"true"`
 
 	FilterServiceAddr string `default:"localhost:50051"`
 	MLServiceAddr     string `default:"localhost:50052"`
+
+	// Аутентификация (те же переменные AUTH_*, что и у receiver)
+	AuthEnabled        bool   `default:"true"`
+	AuthInsecureDev    bool   `default:"false"` // Разрешает AUTH_ENABLED=false (только локальная разработка)
+	AuthJWTSecret      string `default:""`
+	AuthJWTSecretFile  string `default:""`
+	AuthIssuer         string `default:"fetal-monitory"`
+	CORSAllowedOrigins string `default:""` // Пусто - ни один Origin, "*" - любой
+
+	// Журнал доступа к данным пациентов (общий с receiver)
+	AuditEnabled bool `default:"true"`
 }
 
 func LoadConfig() *Config {
@@ -28,8 +40,18 @@ func LoadConfig() *Config {
 		RedisDB:           getEnvInt("REDIS_DB", 0),
 		RedisTTL:          getEnvDuration("REDIS_TTL", 24*time.Hour),
 		PostgreSQLConnStr: getEnv("POSTGRES_CONN_STR", "host=localhost port=5432 user=fetal_user password=fetal_pass dbname=fetal_monitor sslmode=disable"),
+		MigrateOnStart:    getEnvBool("MIGRATE_ON_START", true),
 		FilterServiceAddr: getEnv("FILTER_SERVICE_ADDR", "localhost:50051"),
 		MLServiceAddr:     getEnv("ML_SERVICE_ADDR", "localhost:50052"),
+
+		AuthEnabled:        getEnvBool("AUTH_ENABLED", true),
+		AuthInsecureDev:    getEnvBool("AUTH_INSECURE_DEV", false),
+		AuthJWTSecret:      getEnv("AUTH_JWT_SECRET", ""),
+		AuthJWTSecretFile:  getEnv("AUTH_JWT_SECRET_FILE", ""),
+		AuthIssuer:         getEnv("AUTH_ISSUER", "fetal-monitory"),
+		CORSAllowedOrigins: getEnv("CORS_ALLOWED_ORIGINS", ""),
+
+		AuditEnabled: getEnvBool("AUDIT_ENABLED", true),
 	}
 	return cfg
 }
@@ -50,6 +72,15 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolVal, err := strconv.ParseBool(value); err == nil {
+			return boolVal
+		}
+	}
+	return defaultValue
+}
+
 func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
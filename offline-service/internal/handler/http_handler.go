@@ -1,12 +1,17 @@
 package handler
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"offline-service/internal/service"
 	"offline-service/pkg/models"
 
+	"github.com/Krimson/fetal-monitory/archive"
+	"github.com/Krimson/fetal-monitory/logging"
 	"github.com/google/uuid"
 )
 
@@ -32,6 +37,7 @@ func NewHTTPHandler(medicalService *service.MedicalService) *HTTPHandler {
 // @Success 200 {object} models.UploadResponse "Результат анализа"
 // @Failure 400 {object} map[string]string "Неверный запрос"
 // @Failure 500 {object} map[string]string "Ошибка обработки"
+// @Security BearerAuth
 // @Router /upload [post]
 func (h *HTTPHandler) UploadDualCSV(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -66,8 +72,7 @@ func (h *HTTPHandler) UploadDualCSV(w http.ResponseWriter, r *http.Request) {
 		sessionID = generateSessionID()
 	}
 
-	fmt.Printf("Received BPM file: %s, UC file: %s, Session: %s\n",
-		bpmHeader.Filename, ucHeader.Filename, sessionID)
+	slog.Info("Received files", logging.SessionID(sessionID), "bpm_file", bpmHeader.Filename, "uc_file", ucHeader.Filename)
 
 	// Обрабатываем оба файла
 	response, err := h.medicalService.ProcessDualCSV(r.Context(), bpmFile, ucFile, sessionID)
@@ -98,6 +103,7 @@ func (h *HTTPHandler) UploadDualCSV(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {object} models.DecisionResponse "Результат операции"
 // @Failure 400 {object} map[string]string "Неверный запрос"
 // @Failure 500 {object} map[string]string "Ошибка обработки"
+// @Security BearerAuth
 // @Router /decision [post]
 func (h *HTTPHandler) HandleDecision(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -154,6 +160,7 @@ func (h *HTTPHandler) HandleDecision(w http.ResponseWriter, r *http.Request) {
 // @Param session_id query string true "ID сессии"
 // @Success 200 {object} map[string]interface{} "Информация о сессии"
 // @Failure 400 {object} map[string]string "Неверный запрос"
+// @Security BearerAuth
 // @Router /session [get]
 func (h *HTTPHandler) GetSessionData(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -184,6 +191,102 @@ func (h *HTTPHandler) GetSessionData(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// ExportSession выгружает проанализированную сессию в переносимый архив
+// @Summary Экспортировать сессию
+// @Description Выгружает сессию из кэша в версионированный архив (gzip JSON), который можно загрузить в receiver другой установки
+// @Tags Offline Analysis
+// @Produce application/gzip
+// @Param session_id query string true "ID сессии"
+// @Success 200 {file} file "Архив сессии"
+// @Failure 400 {object} map[string]string "Неверный запрос"
+// @Failure 404 {object} map[string]string "Сессия не найдена или истекла"
+// @Failure 500 {object} map[string]string "Ошибка экспорта"
+// @Security BearerAuth
+// @Router /export [get]
+func (h *HTTPHandler) ExportSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	sessionID := r.URL.Query().Get("session_id")
+	if sessionID == "" {
+		writeJSONError(w, http.StatusBadRequest, "session_id parameter is required", nil)
+		return
+	}
+
+	a, err := h.medicalService.ExportSession(r.Context(), sessionID)
+	if err != nil {
+		if errors.Is(err, models.ErrSessionNotFound) {
+			writeJSONError(w, http.StatusNotFound, "Session not found", nil)
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Export failed", err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := archive.Write(&buf, a); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Export failed", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", archive.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=session-%s%s", sessionID, archive.FileExtension))
+	w.WriteHeader(http.StatusOK)
+	buf.WriteTo(w)
+}
+
+// ImportArchive загружает архив сессии и повторно анализирует ее
+// @Summary Импортировать архив для повторного анализа
+// @Description Принимает архив сессии (gzip или несжатый JSON), выгруженный receiver или offline-service, прогоняет сигналы через фильтрацию, извлечение признаков и ML предсказание. Результат можно сохранить через /decision
+// @Tags Offline Analysis
+// @Accept application/gzip
+// @Produce json
+// @Success 200 {object} models.UploadResponse "Результат анализа"
+// @Failure 400 {object} map[string]string "Неверный или неподдерживаемый архив"
+// @Failure 500 {object} map[string]string "Ошибка обработки"
+// @Security BearerAuth
+// @Router /import [post]
+func (h *HTTPHandler) ImportArchive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	a, err := archive.Read(http.MaxBytesReader(w, r.Body, 256<<20))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid archive", err)
+		return
+	}
+
+	response, err := h.medicalService.ImportArchive(r.Context(), a)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrNoSignals) {
+			status = http.StatusBadRequest
+		}
+		writeJSONError(w, status, "Processing failed", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string, err error) {
+	errorResponse := map[string]string{
+		"error": message,
+	}
+	if err != nil {
+		errorResponse["details"] = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse)
+}
+
 func generateSessionID() string {
 	return uuid.New().String()
 }
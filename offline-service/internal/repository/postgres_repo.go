@@ -5,9 +5,10 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
+	"github.com/Krimson/fetal-monitory/logging"
 	_ "github.com/lib/pq"
 	"offline-service/pkg/models"
 )
@@ -31,7 +32,7 @@ func NewPostgreSQLRepository(connStr string) (*PostgreSQLRepository, error) {
 	db.SetMaxIdleConns(25)
 	db.SetConnMaxLifetime(5 * time.Minute)
 
-	log.Println("✅ Connected to PostgreSQL database")
+	slog.Info("Connected to PostgreSQL database")
 	return &PostgreSQLRepository{db: db}, nil
 }
 
@@ -70,7 +71,7 @@ func (r *PostgreSQLRepository) SaveSession(ctx context.Context, session *models.
 		return err
 	}
 
-	log.Printf("✅ Session %s saved to PostgreSQL", session.SessionID)
+	slog.Info("Session saved to PostgreSQL", logging.SessionID(session.SessionID))
 
 	// Коммитим транзакцию
 	if err := tx.Commit(); err != nil {
@@ -141,6 +142,11 @@ func (r *PostgreSQLRepository) CheckConnection(ctx context.Context) error {
 	return r.db.PingContext(ctx)
 }
 
+// DB - возвращает пул соединений (используется раннером миграций)
+func (r *PostgreSQLRepository) DB() *sql.DB {
+	return r.db
+}
+
 // Close - закрывает соединение
 func (r *PostgreSQLRepository) Close() error {
 	return r.db.Close()
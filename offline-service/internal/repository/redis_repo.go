@@ -4,8 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"time"
 
+	"github.com/Krimson/fetal-monitory/logging"
 	"github.com/redis/go-redis/v9"
 	"offline-service/pkg/models"
 )
@@ -36,12 +38,15 @@ func (r *RedisRepository) SaveSession(ctx context.Context, sessionID string, ses
 		return fmt.Errorf("failed to save session to Redis: %w", err)
 	}
 
-	fmt.Printf("Session %s saved to Redis with TTL %v\n", sessionID, r.ttl)
+	slog.Debug("Session saved to Redis", logging.SessionID(sessionID), "ttl", r.ttl)
 	return nil
 }
 
 func (r *RedisRepository) GetSession(ctx context.Context, sessionID string) (*models.MedicalSession, error) {
 	data, err := r.client.Get(ctx, "session:"+sessionID).Result()
+	if err == redis.Nil {
+		return nil, models.ErrSessionNotFound
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get session from Redis: %w", err)
 	}
@@ -51,8 +56,8 @@ func (r *RedisRepository) GetSession(ctx context.Context, sessionID string) (*mo
 		return nil, fmt.Errorf("failed to unmarshal session: %w", err)
 	}
 
-	fmt.Printf("Session %s retrieved from Redis with %d FHR points and %d UC points\n", session.SessionID,
-		len(session.Records.FetalHeartRate.TimeSec), len(session.Records.UterineContractions.TimeSec))
+	slog.Debug("Session retrieved from Redis", logging.SessionID(session.SessionID),
+		"fhr_points", len(session.Records.FetalHeartRate.TimeSec), "uc_points", len(session.Records.UterineContractions.TimeSec))
 	return &session, nil
 }
 
@@ -61,7 +66,7 @@ func (r *RedisRepository) DeleteSession(ctx context.Context, sessionID string) e
 		return fmt.Errorf("failed to delete session from Redis: %w", err)
 	}
 
-	fmt.Printf("Session %s deleted from Redis\n", sessionID)
+	slog.Info("Session deleted from Redis", logging.SessionID(sessionID))
 	return nil
 }
 
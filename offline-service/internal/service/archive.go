@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"log/slog"
+	"offline-service/pkg/models"
+
+	"github.com/Krimson/fetal-monitory/archive"
+	"github.com/Krimson/fetal-monitory/logging"
+)
+
+// ErrNoSignals - в архиве нет сигналов для повторного анализа
+var ErrNoSignals = errors.New("archive has no signals to analyze")
+
+// ExportSession выгружает проанализированную сессию из Redis в переносимый архив
+func (s *MedicalService) ExportSession(ctx context.Context, sessionID string) (*archive.Archive, error) {
+	session, err := s.cacheRepo.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	a := archive.New(archive.SourceOfflineService)
+	if session.Metadata != nil {
+		a.Session = *session.Metadata
+	} else {
+		a.Session.CreatedFrom = archive.SourceOfflineService
+	}
+	a.Session.ID = session.SessionID
+	a.Session.Status = session.Status
+	if a.Session.StartedAt.IsZero() {
+		a.Session.StartedAt = session.CreatedAt
+	}
+
+	a.FilteredSignals = archiveSignals(session.Records)
+	if session.RawRecords != nil {
+		a.RawSignals = archiveSignals(*session.RawRecords)
+	}
+	a.Predictions = []archive.Prediction{{At: session.CreatedAt, Value: session.Prediction}}
+
+	if analysis := session.Analysis; analysis != nil {
+		a.Events = archiveEvents(analysis)
+		a.TimeSeries = archive.TimeSeries{
+			STV: archive.Series{WindowSec: analysis.STVsWindowDuration, Values: analysis.STVs},
+			LTV: archive.Series{WindowSec: analysis.LTVsWindowDuration, Values: analysis.LTVs},
+		}
+		a.Metrics = &archive.Metrics{
+			STV:                   analysis.STV,
+			LTV:                   analysis.LTV,
+			BaselineHeartRate:     analysis.BaselineHeartRate,
+			TotalAccelerations:    analysis.TotalAccelerations,
+			TotalDecelerations:    analysis.TotalDecelerations,
+			LateDecelerations:     analysis.LateDecelerations,
+			LateDecelerationRatio: analysis.LateDecelerationRatio,
+			TotalContractions:     analysis.TotalContractions,
+			AccelDecelRatio:       analysis.AccelDecelRatio,
+			STVTrend:              analysis.STVTrend,
+			BPMTrend:              analysis.BPMTrend,
+			DataPoints:            analysis.DataPoints,
+			TimeSpanSec:           analysis.TimeSpanSec,
+			UpdatedAt:             session.CreatedAt,
+		}
+	}
+
+	return a, nil
+}
+
+// ImportArchive повторно анализирует сессию из архива. Берется исходный сигнал,
+// а если его нет (например, архив receiver) - отфильтрованный
+func (s *MedicalService) ImportArchive(ctx context.Context, a *archive.Archive) (*models.UploadResponse, error) {
+	signals := a.RawSignals
+	if signals.FHR.Len() == 0 || signals.UC.Len() == 0 {
+		signals = a.FilteredSignals
+	}
+	if signals.FHR.Len() == 0 || signals.UC.Len() == 0 {
+		return nil, ErrNoSignals
+	}
+
+	slog.Info("Re-analyzing session from archive", logging.SessionID(a.Session.ID), "source", a.Source, "version", a.Version,
+		"fhr_points", signals.FHR.Len(), "uc_points", signals.UC.Len())
+
+	record := models.MedicalRecord{
+		FetalHeartRate:      models.MetricRecord{TimeSec: signals.FHR.TimeSec, Value: signals.FHR.Value},
+		UterineContractions: models.MetricRecord{TimeSec: signals.UC.TimeSec, Value: signals.UC.Value},
+	}
+
+	metadata := a.Session
+	response, err := s.analyze(ctx, record, a.Session.ID, &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze archive: %w", err)
+	}
+	return response, nil
+}
+
+func archiveSignals(record models.MedicalRecord) archive.Signals {
+	return archive.Signals{
+		FHR: archive.Signal{TimeSec: record.FetalHeartRate.TimeSec, Value: record.FetalHeartRate.Value},
+		UC:  archive.Signal{TimeSec: record.UterineContractions.TimeSec, Value: record.UterineContractions.Value},
+	}
+}
+
+func archiveEvents(analysis *models.CTGAnalysis) []archive.Event {
+	events := make([]archive.Event, 0,
+		len(analysis.Accelerations)+len(analysis.Decelerations)+len(analysis.Contractions))
+
+	for _, e := range analysis.Accelerations {
+		events = append(events, archive.Event{
+			Type:      archive.EventAcceleration,
+			Start:     e.StartTime,
+			End:       e.EndTime,
+			Duration:  e.Duration,
+			Amplitude: e.Amplitude,
+		})
+	}
+	for _, e := range analysis.Decelerations {
+		events = append(events, archive.Event{
+			Type:      archive.EventDeceleration,
+			Start:     e.StartTime,
+			End:       e.EndTime,
+			Duration:  e.Duration,
+			Amplitude: e.Amplitude,
+			IsLate:    e.IsLate,
+		})
+	}
+	for _, e := range analysis.Contractions {
+		events = append(events, archive.Event{
+			Type:      archive.EventContraction,
+			Start:     e.StartTime,
+			End:       e.EndTime,
+			Duration:  e.Duration,
+			Amplitude: e.Amplitude,
+		})
+	}
+
+	return events
+}
@@ -5,13 +5,16 @@ import (
 	"encoding/csv"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"strconv"
 	"strings"
 	"time"
 
 	"offline-service/internal/pb"
 	"offline-service/pkg/models"
+
+	"github.com/Krimson/fetal-monitory/archive"
+	"github.com/Krimson/fetal-monitory/logging"
 )
 
 type MedicalService struct {
@@ -36,9 +39,6 @@ func NewMedicalService(
 }
 
 func (s *MedicalService) ProcessDualCSV(ctx context.Context, bpmFile, ucFile io.Reader, sessionID string) (*models.UploadResponse, error) {
-	var prediction float64
-	var message string
-
 	// Парсим CSV файлы
 	bpmData, err := s.parseSingleCSV(bpmFile, "BPM")
 	if err != nil {
@@ -50,8 +50,7 @@ func (s *MedicalService) ProcessDualCSV(ctx context.Context, bpmFile, ucFile io.
 		return nil, fmt.Errorf("failed to parse UC CSV: %w", err)
 	}
 
-	log.Printf("Parsed %d BPM points and %d UC points for session %s",
-		len(bpmData.TimeSec), len(ucData.TimeSec), sessionID)
+	slog.Info("Parsed CSV files", logging.SessionID(sessionID), "bpm_points", len(bpmData.TimeSec), "uc_points", len(ucData.TimeSec))
 
 	// Создаем медицинскую запись
 	medicalRecord := models.MedicalRecord{
@@ -59,6 +58,14 @@ func (s *MedicalService) ProcessDualCSV(ctx context.Context, bpmFile, ucFile io.
 		UterineContractions: *ucData,
 	}
 
+	return s.analyze(ctx, medicalRecord, sessionID, nil)
+}
+
+// analyze прогоняет запись через фильтр и ML сервис и кладет результат в Redis
+func (s *MedicalService) analyze(ctx context.Context, medicalRecord models.MedicalRecord, sessionID string, metadata *archive.Session) (*models.UploadResponse, error) {
+	var prediction float64
+	var message string
+
 	// Конвертируем в protobuf для gRPC
 	pbMedicalRecord := s.convertMedicalRecordToGRPC(medicalRecord)
 	// Вызываем фильтр-сервис для анализа КТГ
@@ -93,6 +100,11 @@ func (s *MedicalService) ProcessDualCSV(ctx context.Context, bpmFile, ucFile io.
 		return nil, fmt.Errorf("ML services not available for session %s", sessionID)
 	}
 
+	// Отфильтрованные сигналы уже лежат в Records, в анализе их не дублируем
+	analysis := *processedData
+	analysis.FilteredBMPBatch = models.MetricRecord{}
+	analysis.FilteredUterusBatch = models.MetricRecord{}
+
 	// Сохранение в Redis
 	medicalSession := &models.MedicalSession{
 		SessionID: sessionID,
@@ -102,6 +114,9 @@ func (s *MedicalService) ProcessDualCSV(ctx context.Context, bpmFile, ucFile io.
 		Prediction: prediction,
 		CreatedAt:  time.Now(),
 		Status:     "completed",
+		Analysis:   &analysis,
+		RawRecords: &medicalRecord,
+		Metadata:   metadata,
 	}
 
 	if err := s.cacheRepo.SaveSession(ctx, sessionID, medicalSession); err != nil {
@@ -132,7 +147,7 @@ func (s *MedicalService) parseSingleCSV(file io.Reader, dataType string) (*model
 		return nil, fmt.Errorf("empty %s CSV file", dataType)
 	}
 
-	fmt.Printf("Read %d lines from %s CSV\n", len(records), dataType)
+	slog.Debug("Read CSV", "data_type", dataType, "lines", len(records))
 
 	result := &models.MetricRecord{
 		TimeSec: make([]float64, 0),
@@ -141,28 +156,28 @@ func (s *MedicalService) parseSingleCSV(file io.Reader, dataType string) (*model
 
 	startIndex := 0
 	if isHeader(records[0]) {
-		fmt.Printf("Found %s header, skipping: %v\n", dataType, records[0])
+		slog.Debug("Found CSV header, skipping", "data_type", dataType, "header", records[0])
 		startIndex = 1
 	}
 
 	validRecords := 0
 	for i := startIndex; i < len(records); i++ {
 		if len(records[i]) < 2 {
-			fmt.Printf("Warning: %s line %d has only %d columns, need 2\n", dataType, i+1, len(records[i]))
+			slog.Warn("CSV line has too few columns, need 2", "data_type", dataType, "line", i+1, "columns", len(records[i]))
 			continue
 		}
 
 		// Парсим время
 		timeVal, err := strconv.ParseFloat(strings.TrimSpace(records[i][0]), 64)
 		if err != nil {
-			fmt.Printf("Warning: %s line %d - time error: %v\n", dataType, i+1, err)
+			slog.Warn("CSV line has invalid time", "data_type", dataType, "line", i+1, logging.Err(err))
 			continue
 		}
 
 		// Парсим значение
 		value, err := strconv.ParseFloat(strings.TrimSpace(records[i][1]), 64)
 		if err != nil {
-			fmt.Printf("Warning: %s line %d - value error: %v\n", dataType, i+1, err)
+			slog.Warn("CSV line has invalid value", "data_type", dataType, "line", i+1, logging.Err(err))
 			continue
 		}
 
@@ -175,28 +190,28 @@ func (s *MedicalService) parseSingleCSV(file io.Reader, dataType string) (*model
 		return nil, fmt.Errorf("no valid %s records found in CSV", dataType)
 	}
 
-	fmt.Printf("Successfully parsed %d %s records\n", validRecords, dataType)
+	slog.Debug("Parsed CSV records", "data_type", dataType, "records", validRecords)
 	return result, nil
 }
 
 func (s *MedicalService) HandleDecision(ctx context.Context, decision *models.SaveDecision) (*models.DecisionResponse, error) {
-	fmt.Printf("Processing decision for session %s: save=%t\n", decision.SessionID, decision.Save)
+	slog.Info("Processing decision", logging.SessionID(decision.SessionID), "save", decision.Save)
 
 	if !decision.Save {
 		// Получаем данные перед удалением для ответа
 		session, err := s.cacheRepo.GetSession(ctx, decision.SessionID)
 		if err != nil {
-			fmt.Printf("Failed to get session %s from cache: %v\n", decision.SessionID, err)
+			slog.Error("Failed to get session from cache", logging.SessionID(decision.SessionID), logging.Err(err))
 			return nil, fmt.Errorf("failed to get session data: %w", err)
 		}
 
 		// Удаляем сессию из кеша
 		if err := s.cacheRepo.DeleteSession(ctx, decision.SessionID); err != nil {
-			fmt.Printf("Failed to delete session %s: %v\n", decision.SessionID, err)
+			slog.Error("Failed to delete session", logging.SessionID(decision.SessionID), logging.Err(err))
 			return nil, fmt.Errorf("failed to delete session: %w", err)
 		}
 
-		fmt.Printf("Session %s deleted from cache\n", decision.SessionID)
+		slog.Info("Session deleted from cache", logging.SessionID(decision.SessionID))
 
 		return &models.DecisionResponse{
 			Status:  "cancelled",
@@ -213,23 +228,23 @@ func (s *MedicalService) HandleDecision(ctx context.Context, decision *models.Sa
 	// Получаем данные из кеша
 	session, err := s.cacheRepo.GetSession(ctx, decision.SessionID)
 	if err != nil {
-		fmt.Printf("Failed to get session %s from cache: %v\n", decision.SessionID, err)
+		slog.Error("Failed to get session from cache", logging.SessionID(decision.SessionID), logging.Err(err))
 		return nil, fmt.Errorf("failed to get session data: %w", err)
 	}
 
 	if err := s.dbRepo.SaveSession(ctx, session); err != nil {
-		log.Printf("Warning: failed to save to PostgreSQL: %v", err)
+		slog.Warn("Failed to save to PostgreSQL", logging.Err(err))
 		// Но продолжаем работу - данные есть в Redis
 	}
 
 	// Обновляем статус в Redis
 	session.Status = "saved"
 	if err := s.cacheRepo.SaveSession(ctx, decision.SessionID, session); err != nil {
-		fmt.Printf("Warning: failed to update session status in Redis for %s: %v\n", decision.SessionID, err)
+		slog.Warn("Failed to update session status in Redis", logging.SessionID(decision.SessionID), logging.Err(err))
 	}
 
-	fmt.Printf("Session %s successfully saved to database with %d FHR points and %d UC points\n",
-		decision.SessionID, len(session.Records.FetalHeartRate.TimeSec), len(session.Records.UterineContractions.TimeSec))
+	slog.Info("Session saved to database", logging.SessionID(decision.SessionID),
+		"fhr_points", len(session.Records.FetalHeartRate.TimeSec), "uc_points", len(session.Records.UterineContractions.TimeSec))
 
 	return &models.DecisionResponse{
 		Status:  "saved",
@@ -3,6 +3,8 @@ package models
 import (
 	"errors"
 	"time"
+
+	"github.com/Krimson/fetal-monitory/archive"
 )
 
 type MedicalRecord struct {
@@ -21,6 +23,13 @@ type MedicalSession struct {
 	Prediction float64       `json:"prediction"`
 	CreatedAt  time.Time     `json:"created_at"`
 	Status     string        `json:"status"`
+
+	// Результат анализа - нужен для экспорта сессии в архив
+	Analysis *CTGAnalysis `json:"analysis,omitempty"`
+	// Исходный сигнал до фильтрации
+	RawRecords *MedicalRecord `json:"raw_records,omitempty"`
+	// Метаданные сессии, если она загружена из архива
+	Metadata *archive.Session `json:"metadata,omitempty"`
 }
 
 type Acceleration struct {
@@ -1,6 +1,6 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
 // versions:
-// 	protoc-gen-go v1.36.6
+// 	protoc-gen-go v1.36.9
 // 	protoc        v6.30.2
 // source: proto/feature_extractor/feature_extractor.proto
 
@@ -83,7 +83,11 @@ type ProcessBatchRequest struct {
 	// Данные маточных сокращений (может быть пустым если нет данных UC в батче)
 	UterusData []*DataPoint `protobuf:"bytes,3,rep,name=uterus_data,json=uterusData,proto3" json:"uterus_data,omitempty"`
 	// Временная метка батча
-	BatchTsMs     uint64 `protobuf:"varint,4,opt,name=batch_ts_ms,json=batchTsMs,proto3" json:"batch_ts_ms,omitempty"`
+	BatchTsMs uint64 `protobuf:"varint,4,opt,name=batch_ts_ms,json=batchTsMs,proto3" json:"batch_ts_ms,omitempty"`
+	// Контекст трассировки батча (W3C traceparent/tracestate). Заполняется в
+	// ProcessBatchStream: поток живет дольше батча, и metadata потока несет только
+	// контекст его открытия. В унарном ProcessBatch контекст идет в gRPC metadata
+	TraceContext  map[string]string `protobuf:"bytes,5,rep,name=trace_context,json=traceContext,proto3" json:"trace_context,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -146,6 +150,13 @@ func (x *ProcessBatchRequest) GetBatchTsMs() uint64 {
 	return 0
 }
 
+func (x *ProcessBatchRequest) GetTraceContext() map[string]string {
+	if x != nil {
+		return x.TraceContext
+	}
+	return nil
+}
+
 // Ответ с обработанными метриками
 type ProcessBatchResponse struct {
 	state     protoimpl.MessageState `protogen:"open.v1"`
@@ -180,8 +191,11 @@ type ProcessBatchResponse struct {
 	// Отфильтрованные данные для передачи на фронтенд
 	FilteredBpmBatch    []*DataPoint `protobuf:"bytes,23,rep,name=filtered_bpm_batch,json=filteredBpmBatch,proto3" json:"filtered_bpm_batch,omitempty"`          // Отфильтрованный батч ЧСС
 	FilteredUterusBatch []*DataPoint `protobuf:"bytes,24,rep,name=filtered_uterus_batch,json=filteredUterusBatch,proto3" json:"filtered_uterus_batch,omitempty"` // Отфильтрованный батч маточных сокращений
-	unknownFields       protoimpl.UnknownFields
-	sizeCache           protoimpl.SizeCache
+	// Ошибка обработки батча в ProcessBatchStream (пусто при успехе).
+	// Ответ с ошибкой содержит только session_id и batch_ts_ms запроса
+	Error         string `protobuf:"bytes,25,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ProcessBatchResponse) Reset() {
@@ -382,6 +396,13 @@ func (x *ProcessBatchResponse) GetFilteredUterusBatch() []*DataPoint {
 	return nil
 }
 
+func (x *ProcessBatchResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
 // Акселерация ЧСС
 type Acceleration struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -702,14 +723,18 @@ const file_proto_feature_extractor_feature_extractor_proto_rawDesc = "" +
 	"/proto/feature_extractor/feature_extractor.proto\x12\x14feature_extractor.v1\"<\n" +
 	"\tDataPoint\x12\x19\n" +
 	"\btime_sec\x18\x01 \x01(\x01R\atimeSec\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\x01R\x05value\"\xd2\x01\n" +
+	"\x05value\x18\x02 \x01(\x01R\x05value\"\xf5\x02\n" +
 	"\x13ProcessBatchRequest\x12\x1d\n" +
 	"\n" +
 	"session_id\x18\x01 \x01(\tR\tsessionId\x12:\n" +
 	"\bbpm_data\x18\x02 \x03(\v2\x1f.feature_extractor.v1.DataPointR\abpmData\x12@\n" +
 	"\vuterus_data\x18\x03 \x03(\v2\x1f.feature_extractor.v1.DataPointR\n" +
 	"uterusData\x12\x1e\n" +
-	"\vbatch_ts_ms\x18\x04 \x01(\x04R\tbatchTsMs\"\xd7\b\n" +
+	"\vbatch_ts_ms\x18\x04 \x01(\x04R\tbatchTsMs\x12`\n" +
+	"\rtrace_context\x18\x05 \x03(\v2;.feature_extractor.v1.ProcessBatchRequest.TraceContextEntryR\ftraceContext\x1a?\n" +
+	"\x11TraceContextEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xed\b\n" +
 	"\x14ProcessBatchResponse\x12\x1d\n" +
 	"\n" +
 	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x1e\n" +
@@ -737,7 +762,8 @@ const file_proto_feature_extractor_feature_extractor_proto_rawDesc = "" +
 	"dataPoints\x12\"\n" +
 	"\rtime_span_sec\x18\x16 \x01(\x01R\vtimeSpanSec\x12M\n" +
 	"\x12filtered_bpm_batch\x18\x17 \x03(\v2\x1f.feature_extractor.v1.DataPointR\x10filteredBpmBatch\x12S\n" +
-	"\x15filtered_uterus_batch\x18\x18 \x03(\v2\x1f.feature_extractor.v1.DataPointR\x13filteredUterusBatch\"p\n" +
+	"\x15filtered_uterus_batch\x18\x18 \x03(\v2\x1f.feature_extractor.v1.DataPointR\x13filteredUterusBatch\x12\x14\n" +
+	"\x05error\x18\x19 \x01(\tR\x05error\"p\n" +
 	"\fAcceleration\x12\x14\n" +
 	"\x05start\x18\x01 \x01(\x01R\x05start\x12\x10\n" +
 	"\x03end\x18\x02 \x01(\x01R\x03end\x12\x1a\n" +
@@ -777,7 +803,7 @@ func file_proto_feature_extractor_feature_extractor_proto_rawDescGZIP() []byte {
 	return file_proto_feature_extractor_feature_extractor_proto_rawDescData
 }
 
-var file_proto_feature_extractor_feature_extractor_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_proto_feature_extractor_feature_extractor_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
 var file_proto_feature_extractor_feature_extractor_proto_goTypes = []any{
 	(*DataPoint)(nil),              // 0: feature_extractor.v1.DataPoint
 	(*ProcessBatchRequest)(nil),    // 1: feature_extractor.v1.ProcessBatchRequest
@@ -787,26 +813,28 @@ var file_proto_feature_extractor_feature_extractor_proto_goTypes = []any{
 	(*Contraction)(nil),            // 5: feature_extractor.v1.Contraction
 	(*ResetCollectorRequest)(nil),  // 6: feature_extractor.v1.ResetCollectorRequest
 	(*ResetCollectorResponse)(nil), // 7: feature_extractor.v1.ResetCollectorResponse
+	nil,                            // 8: feature_extractor.v1.ProcessBatchRequest.TraceContextEntry
 }
 var file_proto_feature_extractor_feature_extractor_proto_depIdxs = []int32{
 	0,  // 0: feature_extractor.v1.ProcessBatchRequest.bpm_data:type_name -> feature_extractor.v1.DataPoint
 	0,  // 1: feature_extractor.v1.ProcessBatchRequest.uterus_data:type_name -> feature_extractor.v1.DataPoint
-	3,  // 2: feature_extractor.v1.ProcessBatchResponse.accelerations:type_name -> feature_extractor.v1.Acceleration
-	4,  // 3: feature_extractor.v1.ProcessBatchResponse.decelerations:type_name -> feature_extractor.v1.Deceleration
-	5,  // 4: feature_extractor.v1.ProcessBatchResponse.contractions:type_name -> feature_extractor.v1.Contraction
-	0,  // 5: feature_extractor.v1.ProcessBatchResponse.filtered_bpm_batch:type_name -> feature_extractor.v1.DataPoint
-	0,  // 6: feature_extractor.v1.ProcessBatchResponse.filtered_uterus_batch:type_name -> feature_extractor.v1.DataPoint
-	1,  // 7: feature_extractor.v1.FeatureExtractorService.ProcessBatch:input_type -> feature_extractor.v1.ProcessBatchRequest
-	1,  // 8: feature_extractor.v1.FeatureExtractorService.ProcessBatchStream:input_type -> feature_extractor.v1.ProcessBatchRequest
-	6,  // 9: feature_extractor.v1.FeatureExtractorService.ResetCollector:input_type -> feature_extractor.v1.ResetCollectorRequest
-	2,  // 10: feature_extractor.v1.FeatureExtractorService.ProcessBatch:output_type -> feature_extractor.v1.ProcessBatchResponse
-	2,  // 11: feature_extractor.v1.FeatureExtractorService.ProcessBatchStream:output_type -> feature_extractor.v1.ProcessBatchResponse
-	7,  // 12: feature_extractor.v1.FeatureExtractorService.ResetCollector:output_type -> feature_extractor.v1.ResetCollectorResponse
-	10, // [10:13] is the sub-list for method output_type
-	7,  // [7:10] is the sub-list for method input_type
-	7,  // [7:7] is the sub-list for extension type_name
-	7,  // [7:7] is the sub-list for extension extendee
-	0,  // [0:7] is the sub-list for field type_name
+	8,  // 2: feature_extractor.v1.ProcessBatchRequest.trace_context:type_name -> feature_extractor.v1.ProcessBatchRequest.TraceContextEntry
+	3,  // 3: feature_extractor.v1.ProcessBatchResponse.accelerations:type_name -> feature_extractor.v1.Acceleration
+	4,  // 4: feature_extractor.v1.ProcessBatchResponse.decelerations:type_name -> feature_extractor.v1.Deceleration
+	5,  // 5: feature_extractor.v1.ProcessBatchResponse.contractions:type_name -> feature_extractor.v1.Contraction
+	0,  // 6: feature_extractor.v1.ProcessBatchResponse.filtered_bpm_batch:type_name -> feature_extractor.v1.DataPoint
+	0,  // 7: feature_extractor.v1.ProcessBatchResponse.filtered_uterus_batch:type_name -> feature_extractor.v1.DataPoint
+	1,  // 8: feature_extractor.v1.FeatureExtractorService.ProcessBatch:input_type -> feature_extractor.v1.ProcessBatchRequest
+	1,  // 9: feature_extractor.v1.FeatureExtractorService.ProcessBatchStream:input_type -> feature_extractor.v1.ProcessBatchRequest
+	6,  // 10: feature_extractor.v1.FeatureExtractorService.ResetCollector:input_type -> feature_extractor.v1.ResetCollectorRequest
+	2,  // 11: feature_extractor.v1.FeatureExtractorService.ProcessBatch:output_type -> feature_extractor.v1.ProcessBatchResponse
+	2,  // 12: feature_extractor.v1.FeatureExtractorService.ProcessBatchStream:output_type -> feature_extractor.v1.ProcessBatchResponse
+	7,  // 13: feature_extractor.v1.FeatureExtractorService.ResetCollector:output_type -> feature_extractor.v1.ResetCollectorResponse
+	11, // [11:14] is the sub-list for method output_type
+	8,  // [8:11] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
 }
 
 func init() { file_proto_feature_extractor_feature_extractor_proto_init() }
@@ -820,7 +848,7 @@ func file_proto_feature_extractor_feature_extractor_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_feature_extractor_feature_extractor_proto_rawDesc), len(file_proto_feature_extractor_feature_extractor_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   8,
+			NumMessages:   9,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/Krimson/fetal-monitory/audit"
+	"github.com/Krimson/fetal-monitory/auth"
+	"github.com/Krimson/fetal-monitory/receiver/internal/config"
+)
+
+// httpPolicy - доступ к HTTP API receiver по ролям
+var httpPolicy = auth.Policy{
+	{PathPrefix: "/health", Public: true}, // /health и /healthz
+	{PathPrefix: "/readyz", Public: true},
+	{PathPrefix: "/metrics", Public: true},
+	{PathPrefix: "/swagger/", Public: true},
+	{PathPrefix: "/ws", Roles: auth.Staff},
+	// Реестр устройств, журнал доступа и конфигурация - только администратор; привязка устройств к сессиям - в /api/sessions
+	{PathPrefix: "/api/devices", Roles: []auth.Role{auth.RoleAdmin}},
+	{PathPrefix: "/api/audit", Roles: []auth.Role{auth.RoleAdmin}},
+	{PathPrefix: "/api/config", Roles: []auth.Role{auth.RoleAdmin}},
+	// Удаление сессий и карточек пациенток - только врач или администратор
+	{PathPrefix: "/api/", Methods: []string{http.MethodDelete}, Roles: []auth.Role{auth.RoleClinician, auth.RoleAdmin}},
+	{PathPrefix: "/api/", Roles: auth.Staff},
+}
+
+// dataServicePrefix - методы приема телеметрии
+const dataServicePrefix = "/telemetry.v1.DataService/"
+
+// grpcPolicy - доступ к gRPC сервисам по JWT; реестр устройств проверяет вызовы DataService
+// дополнительно. health и reflection открыты
+var grpcPolicy = auth.MethodPolicy{
+	dataServicePrefix: {auth.RoleDevice, auth.RoleAdmin},
+}
+
+// newAuthenticator создает аутентификатор из настроек AUTH_*
+func newAuthenticator(cfg *config.Config) (*auth.Authenticator, error) {
+	key, err := auth.LoadKey(cfg.AuthJWTSecret, cfg.AuthJWTSecretFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("AUTH_JWT_SECRET or AUTH_JWT_SECRET_FILE is required")
+	}
+	return auth.NewAuthenticator(key, cfg.AuthIssuer)
+}
+
+// identify определяет субъекта запроса для журнала доступа. Журнал стоит до проверки
+// доступа, поэтому токен разбирается здесь же; невалидный токен - аноним
+func identify(authenticator *auth.Authenticator) audit.IdentifyFunc {
+	return func(r *http.Request) (string, string) {
+		if authenticator == nil {
+			return "", ""
+		}
+		claims, err := authenticator.Verify(auth.TokenFromRequest(r))
+		if err != nil {
+			return "", ""
+		}
+		return claims.Subject, string(claims.Role)
+	}
+}
+
+// runToken выполняет подкоманду `receiver token -sub <id> -role <role> [-ttl 12h]`
+func runToken(cfg *config.Config, args []string) error {
+	authenticator, err := newAuthenticator(cfg)
+	if err != nil {
+		return err
+	}
+	return auth.RunCommand(authenticator, args, os.Stdout)
+}
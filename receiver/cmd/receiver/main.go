@@ -3,28 +3,43 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/lib/pq"
 	"github.com/redis/go-redis/v9"
 	httpSwagger "github.com/swaggo/http-swagger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 
+	"github.com/Krimson/fetal-monitory/audit"
+	"github.com/Krimson/fetal-monitory/auth"
+	"github.com/Krimson/fetal-monitory/logging"
+	"github.com/Krimson/fetal-monitory/migrations"
 	featureextractorv1 "github.com/Krimson/fetal-monitory/proto/feature_extractor"
 	telemetryv1 "github.com/Krimson/fetal-monitory/proto/telemetry"
+	"github.com/Krimson/fetal-monitory/receiver/internal/alert"
 	"github.com/Krimson/fetal-monitory/receiver/internal/batch"
 	"github.com/Krimson/fetal-monitory/receiver/internal/config"
+	"github.com/Krimson/fetal-monitory/receiver/internal/device"
 	"github.com/Krimson/fetal-monitory/receiver/internal/health"
+	"github.com/Krimson/fetal-monitory/receiver/internal/metrics"
+	"github.com/Krimson/fetal-monitory/receiver/internal/patient"
+	"github.com/Krimson/fetal-monitory/receiver/internal/recorder"
+	"github.com/Krimson/fetal-monitory/receiver/internal/replay"
 	"github.com/Krimson/fetal-monitory/receiver/internal/server"
 	"github.com/Krimson/fetal-monitory/receiver/internal/session"
+	"github.com/Krimson/fetal-monitory/receiver/internal/tracing"
 	"github.com/Krimson/fetal-monitory/receiver/internal/websocket"
 
 	_ "github.com/Krimson/fetal-monitory/receiver/docs" // Swagger docs
@@ -40,6 +55,17 @@ import (
 // @description ## WebSocket
 // @description Подключение: `ws://localhost:8080/ws?session_id={session_id}`
 // @description
+// @description Клиент получает только сообщения сессий, на которые подписан. Подписками можно управлять через то же соединение:
+// @description `{"action": "subscribe", "session_ids": ["id1", "id2"]}`, `{"action": "unsubscribe", "session_ids": ["id1"]}`, `{"action": "list"}`.
+// @description В ответ приходит `{"type": "subscriptions", "session_ids": [...]}`.
+// @description
+// @description Клинические тревоги приходят подписчикам сессии сообщением `{"type": "alert", "alert": {...}}` при поднятии, подтверждении и снятии.
+// @description
+// @description ## Аутентификация
+// @description При AUTH_ENABLED=true (по умолчанию) запросы к /api и /ws требуют JWT в заголовке `Authorization: Bearer <token>`
+// @description (для WebSocket из браузера - параметр `access_token`). Роли: clinician, midwife, admin - REST и WebSocket
+// @description (удаление - только clinician и admin), device - gRPC DataService. Токены выпускает `receiver token`.
+// @description
 // @termsOfService http://swagger.io/terms/
 
 // @contact.name API Support
@@ -48,16 +74,73 @@ import (
 // @license.name MIT
 // @license.url https://opensource.org/licenses/MIT
 
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description JWT в формате "Bearer <token>"
+
 // @host localhost:8080
 // @BasePath /
 // @schemes http ws
 
+// tracer - спаны стадий конвейера, выполняемых в main (рассылка в WebSocket)
+var tracer = otel.Tracer("github.com/Krimson/fetal-monitory/receiver")
+
 func main() {
-	log.Printf("[INFO] Starting receiver server...")
+	// Умолчания, файл CONFIG_FILE и переменные окружения (перекрывают файл)
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	// Структурированный журнал (LOG_LEVEL, LOG_FORMAT, LOG_DEBUG_SAMPLE)
+	if err := logging.Setup("receiver", logging.Config{
+		Level:       cfg.LogLevel,
+		Format:      cfg.LogFormat,
+		DebugSample: cfg.LogDebugSample,
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid logging configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Подкоманда: receiver migrate <up|down [N]|status|version>
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, os.Args[2:]); err != nil {
+			logging.Fatal("Migration failed", logging.Err(err))
+		}
+		return
+	}
+
+	// Подкоманда: receiver token -sub <id> -role <role> [-ttl 12h]
+	if len(os.Args) > 1 && os.Args[1] == "token" {
+		if err := runToken(cfg, os.Args[2:]); err != nil {
+			logging.Fatal("Failed to issue token", logging.Err(err))
+		}
+		return
+	}
+
+	slog.Info("Starting receiver server...")
+	slog.Info("Configuration loaded", "grpc_port", cfg.GRPCPort, "http_port", cfg.HTTPPort, "redis", cfg.RedisAddr)
+
+	// Метрики Prometheus (GET /metrics)
+	receiverMetrics := metrics.NewReceiver()
 
-	cfg := config.Load()
-	log.Printf("[INFO] Configuration loaded: grpc_port=%s http_port=%s redis=%s",
-		cfg.GRPCPort, cfg.HTTPPort, cfg.RedisAddr)
+	// Трассировка OpenTelemetry (TRACING_EXPORTER)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		logging.Fatal("Failed to initialize tracing", logging.Err(err))
+	}
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			slog.Warn("Failed to flush traces", logging.Err(err))
+		}
+	}()
+	if cfg.TracingExporter != config.TracingExporterNone {
+		slog.Info("Tracing enabled", "exporter", cfg.TracingExporter, "sample_ratio", cfg.TracingSampleRatio)
+	}
 
 	// Инициализируем Redis
 	redisClient := redis.NewClient(&redis.Options{
@@ -66,55 +149,134 @@ func main() {
 		DB:       cfg.RedisDB,
 	})
 	defer redisClient.Close()
+	receiverMetrics.InstrumentRedis(redisClient)
 
 	// Проверяем подключение к Redis
 	ctx := context.Background()
 	if err := redisClient.Ping(ctx).Err(); err != nil {
-		log.Fatalf("[FATAL] Failed to connect to Redis: %v", err)
+		logging.Fatal("Failed to connect to Redis", logging.Err(err))
 	}
-	log.Printf("[INFO] Connected to Redis: %s", cfg.RedisAddr)
+	slog.Info("Connected to Redis", "addr", cfg.RedisAddr)
 
 	// Инициализируем PostgreSQL
-	postgresRepo, err := session.NewPostgresRepositoryFromDSN(cfg.PostgresDSN)
+	pgConnector, err := pq.NewConnector(cfg.PostgresDSN)
 	if err != nil {
-		log.Fatalf("[FATAL] Failed to connect to PostgreSQL: %v", err)
+		logging.Fatal("Invalid PostgreSQL DSN", logging.Err(err))
+	}
+	postgresRepo, err := session.NewPostgresRepositoryFromConnector(receiverMetrics.InstrumentPostgres(pgConnector))
+	if err != nil {
+		logging.Fatal("Failed to connect to PostgreSQL", logging.Err(err))
 	}
 	defer postgresRepo.Close()
-	log.Printf("[INFO] Connected to PostgreSQL")
+	slog.Info("Connected to PostgreSQL")
+
+	if cfg.MigrateOnStart {
+		version, err := migrations.Migrate(ctx, postgresRepo.DB())
+		if err != nil {
+			logging.Fatal("Failed to migrate database schema", logging.Err(err))
+		}
+		slog.Info("Database schema version", "version", version)
+	}
+
+	// Аутентификация и ролевой доступ
+	var authenticator *auth.Authenticator
+	if cfg.AuthEnabled {
+		if authenticator, err = newAuthenticator(cfg); err != nil {
+			logging.Fatal("Failed to initialize authentication", logging.Err(err))
+		}
+		slog.Info("Authentication enabled", "issuer", cfg.AuthIssuer)
+	} else {
+		slog.Warn("Authentication is disabled (AUTH_INSECURE_DEV=true): HTTP, WebSocket and gRPC APIs are open")
+	}
+	origins := auth.ParseOrigins(cfg.CORSAllowedOrigins)
+	if origins.Any() {
+		slog.Warn("CORS allows any origin (CORS_ALLOWED_ORIGINS=*)")
+	}
 
 	// Создаем Session Manager
 	redisStore := session.NewRedisStore(redisClient)
 	sessionManager := session.NewManager(redisStore, postgresRepo)
-	log.Printf("[INFO] Session manager initialized")
+	sessionManager.SetDataTTL(time.Duration(cfg.SessionDataTTLSeconds) * time.Second)
+	receiverMetrics.TrackActiveSessions(sessionManager.ActiveSessionCount)
+	slog.Info("Session manager initialized")
 
 	// Создаем WebSocket hub
 	wsHub := websocket.NewHub()
+	wsHub.SetCheckOrigin(origins.CheckOrigin)
+	receiverMetrics.TrackWebSocketClients(wsHub.ClientCount)
 	go wsHub.Run()
 
+	// Журнал доступа к данным пациенток
+	auditStore := audit.NewPostgresRepository(postgresRepo.DB())
+	var auditLogger *audit.Logger
+	if cfg.AuditEnabled {
+		auditLogger = audit.NewLogger(auditStore)
+		wsHub.SetAuditor(auditLogger)
+		slog.Info("Audit log enabled")
+	} else {
+		slog.Warn("Audit log is disabled (AUDIT_ENABLED=false): access to patient data is not recorded")
+	}
+
+	// Создаем движок клинических тревог
+	var alertEngine *alert.Engine
+	if cfg.AlertsEnabled {
+		alertEngine = alert.NewEngine(cfg, alert.NewRedisStore(redisClient), wsHub)
+		// Тревоги сохраненных сессий после истечения TTL в Redis берутся из PostgreSQL
+		alertEngine.SetArchive(postgresRepo)
+		sessionManager.SetAlertService(alertEngine)
+		slog.Info("Alert engine initialized")
+	}
+
 	// Создаем Feature Extractor Sink с интеграцией Session Manager
-	featureSink, err := batch.NewFeatureExtractorSinkWithSession(cfg.FeatureExtractorAddr, sessionManager)
+	featureSink, err := batch.NewFeatureExtractorSinkWithMode(cfg.FeatureExtractorAddr, sessionManager, cfg.FeatureExtractorMode)
 	if err != nil {
-		log.Fatalf("[FATAL] Failed to create feature extractor sink: %v", err)
+		logging.Fatal("Failed to create feature extractor sink", logging.Err(err))
 	}
 	defer featureSink.Close()
+	featureSink.SetMetrics(receiverMetrics)
+	slog.Info("Feature extractor sink mode", "mode", cfg.FeatureExtractorMode)
 
 	// Создаем ML Service Sink
 	mlSink, err := batch.NewMLServiceSink(cfg.MLServiceAddr)
 	if err != nil {
-		log.Fatalf("[FATAL] Failed to create ML service sink: %v", err)
+		logging.Fatal("Failed to create ML service sink", logging.Err(err))
 	}
 	defer mlSink.Close()
+	mlSink.SetMetrics(receiverMetrics)
 
 	// Создаем композитный sink (логирование + feature extraction)
 	logSink := &batch.LogSink{}
 	compositeSink := batch.NewCompositeSink(logSink, featureSink)
 
 	batcher := batch.NewBatcher(cfg, compositeSink)
+	batcher.SetMetrics(receiverMetrics)
+	receiverMetrics.TrackChannel("flush", batcher.FlushChanDepth)
+	receiverMetrics.TrackChannel("processed_batch", func() int { return len(featureSink.GetProcessedBatchChannel()) })
+	receiverMetrics.TrackChannel("prediction", func() int { return len(mlSink.GetPredictionChannel()) })
+
+	// Воспроизведение записанных сессий через тот же Batcher
+	replayer := replay.NewReplayer(postgresRepo, sessionManager, batcher, cfg.ReplayMaxSpeed)
 
 	// Запускаем обработчики данных
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Перезагрузка конфигурации по SIGHUP и при изменении файла: батчинг, пороги тревог, уровень журнала
+	reloader := config.NewReloader(cfg)
+	reloader.OnReload(func(cfg *config.Config) {
+		batcher.UpdateConfig(cfg)
+		if alertEngine != nil {
+			alertEngine.UpdateConfig(cfg)
+		}
+		if err := logging.SetLevel(cfg.LogLevel); err != nil {
+			slog.Warn("Failed to apply log level", logging.Err(err))
+		}
+	})
+	go reloader.Watch(ctx)
+	if cfg.File != "" {
+		slog.Info("Configuration file loaded", "file", cfg.File, "watch_interval", cfg.ConfigWatchInterval)
+	}
+
 	// Обработчик признаков из feature extractor
 	// Он отправляет признаки и в WebSocket и в ML сервис
 	go func() {
@@ -122,17 +284,31 @@ func main() {
 			select {
 			case <-ctx.Done():
 				return
-			case features, ok := <-featureSink.GetProcessedBatchChannel():
+			case processed, ok := <-featureSink.GetProcessedBatchChannel():
 				if !ok {
 					return
 				}
+				features := processed.Features
+				// Дальнейшие стадии продолжают трассу батча
+				batchCtx := tracing.WithSpanContext(ctx, processed.Trace)
+
 				// 1. Отправляем в WebSocket
+				_, span := tracer.Start(batchCtx, "Hub.BroadcastProcessedData",
+					trace.WithAttributes(tracing.AttrSessionID.String(features.SessionId)))
 				wsHub.BroadcastProcessedData(features)
+				span.End()
 
-				// 2. Отправляем признаки в ML сервис (асинхронно, не блокируем)
+				// 2. Проверяем правила клинических тревог
+				if alertEngine != nil {
+					if err := alertEngine.ProcessFeatures(batchCtx, features); err != nil {
+						slog.ErrorContext(batchCtx, "Failed to evaluate alerts", logging.SessionID(features.SessionId), logging.Err(err))
+					}
+				}
+
+				// 3. Отправляем признаки в ML сервис (асинхронно, не блокируем)
 				go func(f *featureextractorv1.ProcessBatchResponse) {
-					if err := mlSink.ConsumeFeatures(ctx, f); err != nil {
-						log.Printf("[ERROR] Failed to send features to ML service: %v", err)
+					if err := mlSink.ConsumeFeatures(batchCtx, f); err != nil {
+						slog.ErrorContext(batchCtx, "Failed to send features to ML service", logging.SessionID(f.SessionId), logging.Err(err))
 					}
 				}(features)
 			}
@@ -151,42 +327,129 @@ func main() {
 				}
 				// Обновляем предсказание в Hub (оно будет использовано в следующем WebSocket сообщении)
 				wsHub.UpdatePrediction(prediction.SessionId, prediction.Prediction)
+
+				// Сохраняем предсказание в метриках сессии (попадет в PostgreSQL и отчет)
+				if err := sessionManager.UpdatePrediction(ctx, prediction.SessionId, prediction.Prediction); err != nil {
+					slog.Warn("Failed to store prediction", logging.SessionID(prediction.SessionId), logging.Err(err))
+				}
+
+				if alertEngine != nil {
+					if err := alertEngine.ProcessPrediction(ctx, prediction.SessionId, prediction.Prediction); err != nil {
+						slog.Error("Failed to evaluate prediction alert", logging.SessionID(prediction.SessionId), logging.Err(err))
+					}
+				}
 			}
 		}
 	}()
 
+	// Реестр устройств
+	deviceService := device.NewService(device.NewPostgresRepository(postgresRepo.DB()))
+
 	// Настраиваем gRPC сервер
-	grpcServer := grpc.NewServer()
+	grpcOptions := []grpc.ServerOption{tracing.ServerHandler()}
+	creds, err := grpcServerCredentials(cfg)
+	if err != nil {
+		logging.Fatal("Failed to configure gRPC TLS", logging.Err(err))
+	}
+	if creds != nil {
+		grpcOptions = append(grpcOptions, grpc.Creds(creds))
+		slog.Info("gRPC TLS enabled", "client_ca", cfg.GRPCTLSClientCAFile)
+	}
+	// Проверки складываются: DataService требует и JWT с ролью device, и учетные данные
+	// зарегистрированного устройства, привязанного к сессии
+	if authenticator != nil {
+		grpcOptions = append(grpcOptions,
+			grpc.ChainUnaryInterceptor(authenticator.UnaryServerInterceptor(grpcPolicy)),
+			grpc.ChainStreamInterceptor(authenticator.StreamServerInterceptor(grpcPolicy)),
+		)
+	}
+	if cfg.DeviceAuthEnabled {
+		grpcOptions = append(grpcOptions,
+			grpc.ChainUnaryInterceptor(deviceService.UnaryServerInterceptor(dataServicePrefix)),
+			grpc.ChainStreamInterceptor(deviceService.StreamServerInterceptor(dataServicePrefix)),
+		)
+		slog.Info("Device authentication enabled for DataService")
+	} else {
+		slog.Warn("Device authentication is disabled (AUTH_INSECURE_DEV=true): DataService accepts samples for any session")
+	}
+	grpcServer := grpc.NewServer(grpcOptions...)
 
 	dataServer := server.NewDataServer(cfg, batcher)
+	if cfg.DeviceAuthEnabled {
+		dataServer.SetSessionAuthorizer(deviceService)
+	}
+
+	// Запись сырого потока для воспроизведения и экспорта
+	var rawRecorder *recorder.Recorder
+	if cfg.RawRecordingEnabled {
+		rawRecorder = recorder.New(postgresRepo, cfg.RawChunkSamples, cfg.RawFlushInterval)
+		dataServer.SetRecorder(rawRecorder)
+		// Сэмплы несохраненных сессий удаляются вместе с истечением TTL их данных в Redis
+		rawRecorder.EnableRetention(postgresRepo, time.Duration(cfg.SessionDataTTLSeconds)*time.Second, recorder.RetentionInterval)
+		slog.Info("Raw sample recording enabled", "chunk", cfg.RawChunkSamples, "flush", cfg.RawFlushInterval)
+	}
 	telemetryv1.RegisterDataServiceServer(grpcServer, dataServer)
 
 	healthServer := health.NewHealthServer()
 	grpc_health_v1.RegisterHealthServer(grpcServer, healthServer)
 
+	// Проверка зависимостей: /readyz и статусы gRPC health
+	critical := make(map[string]bool)
+	for _, name := range strings.Split(cfg.HealthCriticalDependencies, ",") {
+		critical[strings.TrimSpace(name)] = true
+	}
+	dependencies := []health.Dependency{
+		{Name: "redis", Critical: critical["redis"], Check: func(ctx context.Context) error {
+			return redisClient.Ping(ctx).Err()
+		}},
+		{Name: "postgres", Critical: critical["postgres"], Check: postgresRepo.DB().PingContext},
+		{Name: "feature_extractor", Critical: critical["feature_extractor"], Check: featureSink.CheckHealth},
+		{Name: "ml_service", Critical: critical["ml_service"], Check: mlSink.CheckHealth},
+	}
+	if auditLogger != nil {
+		// Без журнала доступа API не обслуживается, поэтому он критичен всегда
+		dependencies = append(dependencies, health.Dependency{Name: "audit_log", Critical: true, Check: auditLogger.Check})
+	}
+	readiness := health.NewChecker(dependencies, cfg.HealthCheckInterval, cfg.HealthCheckTimeout)
+	readiness.SetHealthServer(healthServer, "", "telemetry.v1.DataService")
+
 	reflection.Register(grpcServer)
 
 	address := fmt.Sprintf(":%s", cfg.GRPCPort)
 	listener, err := net.Listen("tcp", address)
 	if err != nil {
-		log.Fatalf("[FATAL] Failed to listen on %s: %v", address, err)
+		logging.Fatal("Failed to listen", "address", address, logging.Err(err))
 	}
 
-	log.Printf("[INFO] gRPC server listening on %s", address)
+	slog.Info("gRPC server listening", "address", address)
 
 	// Настраиваем HTTP сервер с роутером
 	router := mux.NewRouter()
 
-	router.Use(corsMiddleware)
+	router.Use(corsMiddleware(origins))
+	if auditLogger != nil {
+		// До проверки токена, чтобы в журнал попадали и отказы в доступе
+		router.Use(audit.Middleware(auditLogger, identify(authenticator)))
+	}
+	if authenticator != nil {
+		router.Use(authenticator.Middleware(httpPolicy))
+	}
 
 	// WebSocket endpoint
 	router.HandleFunc("/ws", wsHub.HandleWebSocket)
 
-	// Health check
+	// Health check (liveness; /health оставлен для совместимости)
 	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
+	router.HandleFunc("/healthz", health.LiveHandler).Methods("GET")
+
+	// Readiness: состояние Redis, PostgreSQL, feature extractor и ML сервиса
+	router.HandleFunc("/readyz", readiness.ReadyHandler).Methods("GET")
+
+	// Метрики Prometheus
+	router.Handle("/metrics", receiverMetrics.Handler()).Methods("GET")
 
 	// Swagger UI
 	router.PathPrefix("/swagger/").Handler(httpSwagger.Handler(
@@ -200,11 +463,31 @@ func main() {
 	sessionHandler := session.NewHTTPHandler(sessionManager)
 	sessionHandler.RegisterRoutes(router)
 
+	// Patients API
+	patientService := patient.NewService(patient.NewPostgresRepository(postgresRepo.DB()))
+	patientHandler := patient.NewHTTPHandler(patientService)
+	patientHandler.RegisterRoutes(router)
+
+	auditHandler := audit.NewHTTPHandler(auditStore)
+	auditHandler.RegisterRoutes(router)
+
+	deviceHandler := device.NewHTTPHandler(deviceService)
+	deviceHandler.RegisterRoutes(router)
+
+	// Session replay API
+	replayHandler := replay.NewHTTPHandler(replayer)
+	replayHandler.RegisterRoutes(router)
+
+	// Действующая конфигурация (секреты скрыты)
+	configHandler := config.NewHTTPHandler(reloader)
+	configHandler.RegisterRoutes(router)
+
 	httpPort := cfg.HTTPPort
-	log.Printf("[INFO] HTTP server (WebSocket + API) listening on :%s", httpPort)
+	slog.Info("HTTP server (WebSocket + API) listening", "port", httpPort)
 
-	healthServer.SetServingStatus("")
-	healthServer.SetServingStatus("telemetry.v1.DataService")
+	// Первая проверка зависимостей задает статусы gRPC health, дальше - раз в HEALTH_CHECK_INTERVAL_MS
+	readiness.Start()
+	defer readiness.Stop()
 
 	// Запускаем серверы
 	serverErrChan := make(chan error, 2)
@@ -228,11 +511,12 @@ func main() {
 
 	select {
 	case err := <-serverErrChan:
-		log.Printf("[ERROR] Server error: %v", err)
+		slog.Error("Server error", logging.Err(err))
 
 	case sig := <-shutdownChan:
-		log.Printf("[INFO] Received signal %v, starting graceful shutdown...", sig)
+		slog.Info("Received signal, starting graceful shutdown", "signal", sig.String())
 
+		readiness.Stop()
 		healthServer.SetNotServingStatus("")
 		healthServer.SetNotServingStatus("telemetry.v1.DataService")
 
@@ -243,48 +527,57 @@ func main() {
 
 		grpcServer.GracefulStop()
 
+		if rawRecorder != nil {
+			rawRecorder.Stop()
+		}
+
+		replayer.Stop()
 		batcher.Stop()
 
-		log.Printf("[INFO] Graceful shutdown completed")
+		if auditLogger != nil {
+			auditLogger.Stop()
+		}
+
+		slog.Info("Graceful shutdown completed")
 
 		select {
 		case <-shutdownCtx.Done():
-			log.Printf("[WARN] Graceful shutdown timeout, forcing stop")
+			slog.Warn("Graceful shutdown timeout, forcing stop")
 			grpcServer.Stop()
 		default:
 		}
 	}
 
-	log.Printf("[INFO] Server stopped")
+	slog.Info("Server stopped")
 }
 
-// corsMiddleware добавляет CORS заголовки для разработки
-func corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Получаем Origin из запроса
-		origin := r.Header.Get("Origin")
-		if origin == "" {
-			origin = "*"
-		}
-
-		// Устанавливаем CORS заголовки
-		w.Header().Set("Access-Control-Allow-Origin", origin)
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, Authorization, X-CSRF-Token, X-Requested-With")
-		w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Type")
-		w.Header().Set("Access-Control-Max-Age", "86400")
-
-		// Если Origin конкретный (не *), разрешаем credentials
-		if origin != "*" {
-			w.Header().Set("Access-Control-Allow-Credentials", "true")
-		}
-
-		// Обработка preflight запросов
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
+// corsMiddleware разрешает кросс-доменные запросы с разрешенных Origin.
+// Токен передается в заголовке Authorization, поэтому cookies (credentials) не разрешаются
+func corsMiddleware(origins auth.Origins) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+
+			// Устанавливаем CORS заголовки
+			switch {
+			case origins.Any():
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			case origin != "" && origins.Allowed(origin):
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Add("Vary", "Origin")
+			}
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
+			w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, Authorization, X-CSRF-Token, X-Requested-With")
+			w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Type")
+			w.Header().Set("Access-Control-Max-Age", "86400")
+
+			// Обработка preflight запросов
+			if r.Method == "OPTIONS" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
 
-		next.ServeHTTP(w, r)
-	})
+			next.ServeHTTP(w, r)
+		})
+	}
 }
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Krimson/fetal-monitory/migrations"
+	"github.com/Krimson/fetal-monitory/receiver/internal/config"
+
+	_ "github.com/lib/pq"
+)
+
+// migrateTimeout - ограничение на выполнение подкоманды migrate
+const migrateTimeout = 5 * time.Minute
+
+// runMigrate выполняет подкоманду `receiver migrate <up|down [N]|status|version>`
+func runMigrate(cfg *config.Config, args []string) error {
+	db, err := sql.Open("postgres", cfg.PostgresDSN)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), migrateTimeout)
+	defer cancel()
+
+	return migrations.RunCommand(ctx, db, args, os.Stdout)
+}
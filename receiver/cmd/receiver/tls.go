@@ -0,0 +1,48 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc/credentials"
+
+	"github.com/Krimson/fetal-monitory/receiver/internal/config"
+)
+
+// grpcServerCredentials возвращает TLS для gRPC сервера или nil, если сертификат не задан.
+// С GRPC_TLS_CLIENT_CA_FILE сервер проверяет клиентские сертификаты устройств (mTLS);
+// клиенты без сертификата по-прежнему могут войти по API ключу
+func grpcServerCredentials(cfg *config.Config) (credentials.TransportCredentials, error) {
+	if cfg.GRPCTLSCertFile == "" && cfg.GRPCTLSKeyFile == "" {
+		if cfg.GRPCTLSClientCAFile != "" {
+			return nil, fmt.Errorf("GRPC_TLS_CLIENT_CA_FILE requires GRPC_TLS_CERT_FILE and GRPC_TLS_KEY_FILE")
+		}
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.GRPCTLSCertFile, cfg.GRPCTLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load gRPC server certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.GRPCTLSClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.GRPCTLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.GRPCTLSClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return credentials.NewTLS(tlsConfig), nil
+}
@@ -0,0 +1,523 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Krimson/fetal-monitory/logging"
+	featureextractorv1 "github.com/Krimson/fetal-monitory/proto/feature_extractor"
+	"github.com/Krimson/fetal-monitory/receiver/internal/config"
+)
+
+// signal - результат проверки правила на очередном батче признаков
+type signal int
+
+const (
+	signalHold signal = iota // Значение в полосе гистерезиса - состояние не меняется
+	signalOn                 // Условие тревоги выполняется
+	signalOff                // Условие гарантированно ушло
+)
+
+// observation - результат проверки одного правила
+type observation struct {
+	typ       Type
+	signal    signal
+	severity  Severity
+	value     float64
+	threshold float64
+	hold      time.Duration // Сколько условие должно держаться до поднятия тревоги
+	message   string
+}
+
+// condition - состояние одного правила внутри сессии
+type condition struct {
+	since      time.Time // Когда условие начало выполняться (zero - не выполняется)
+	clearSince time.Time // Когда условие ушло при открытой тревоге
+	alert      *Alert    // Открытая тревога (дедупликация: не больше одной на тип)
+	suppressed bool      // Тревогу закрыли вручную, пока условие держится - ждем его ухода
+}
+
+// decelMark - накопленное количество поздних децелераций на момент батча
+type decelMark struct {
+	at    time.Time
+	count int32
+}
+
+// idleSessionTimeout - через сколько без признаков и предсказаний состояние сессии
+// вытесняется из памяти (батчи, пришедшие после остановки, не должны копиться)
+const idleSessionTimeout = time.Hour
+
+// sessionState - состояние правил одной сессии
+type sessionState struct {
+	conditions map[Type]*condition
+	lateDecels []decelMark
+	seen       time.Time // Последнее обращение правил к сессии
+}
+
+func (s *sessionState) condition(typ Type) *condition {
+	c, ok := s.conditions[typ]
+	if !ok {
+		c = &condition{}
+		s.conditions[typ] = c
+	}
+	return c
+}
+
+// Engine оценивает признаки и предсказания по клиническим правилам и ведет жизненный цикл тревог
+type Engine struct {
+	cfg      atomic.Pointer[config.Config] // Пороги меняются UpdateConfig при перезагрузке конфигурации
+	store    Store
+	archive  Archive // nil - тревоги только в Store
+	notifier Notifier
+
+	mu       sync.Mutex
+	sessions map[string]*sessionState
+
+	now func() time.Time
+}
+
+// NewEngine создает движок тревог; notifier может быть nil
+func NewEngine(cfg *config.Config, store Store, notifier Notifier) *Engine {
+	e := &Engine{
+		store:    store,
+		notifier: notifier,
+		sessions: make(map[string]*sessionState),
+		now:      time.Now,
+	}
+	e.cfg.Store(cfg)
+	return e
+}
+
+// SetArchive подключает архив тревог сохраненных сессий: подтверждение и закрытие
+// тревоги, которой уже нет в Store, выполняются над архивной записью
+func (e *Engine) SetArchive(archive Archive) {
+	e.archive = archive
+}
+
+// UpdateConfig применяет перезагруженные пороги тревог. Активные тревоги остаются,
+// новые пороги действуют с ближайшей оценки
+func (e *Engine) UpdateConfig(cfg *config.Config) {
+	e.cfg.Store(cfg)
+}
+
+// Forget удаляет из памяти состояние правил сессии (остановка, сохранение, удаление).
+// Тревоги остаются в Store: ручные переходы и повторный мониторинг их восстанавливают
+func (e *Engine) Forget(sessionID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.sessions, sessionID)
+}
+
+// settings возвращает действующие пороги
+func (e *Engine) settings() *config.Config {
+	return e.cfg.Load()
+}
+
+// ProcessFeatures проверяет правила по STV, базальной ЧСС и поздним децелерациям
+func (e *Engine) ProcessFeatures(ctx context.Context, resp *featureextractorv1.ProcessBatchResponse) error {
+	if resp == nil || resp.SessionId == "" {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	state, err := e.sessionLocked(ctx, resp.SessionId)
+	if err != nil {
+		return err
+	}
+
+	var observations []observation
+	if resp.Stv > 0 {
+		observations = append(observations, e.checkSTV(resp.Stv))
+	}
+	if resp.BaselineHeartRate > 0 {
+		observations = append(observations,
+			e.checkTachycardia(resp.BaselineHeartRate),
+			e.checkBradycardia(resp.BaselineHeartRate),
+		)
+	}
+	observations = append(observations, e.checkLateDecelerations(state, resp.LateDecelerations))
+
+	for _, obs := range observations {
+		if err := e.applyLocked(ctx, resp.SessionId, state, obs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ProcessPrediction проверяет правило по вероятности патологии от ML сервиса
+func (e *Engine) ProcessPrediction(ctx context.Context, sessionID string, prediction float64) error {
+	if sessionID == "" {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	state, err := e.sessionLocked(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	return e.applyLocked(ctx, sessionID, state, e.checkPrediction(prediction))
+}
+
+// Acknowledge подтверждает, что врач увидел тревогу
+func (e *Engine) Acknowledge(ctx context.Context, alertID, user, comment string) (*Alert, error) {
+	return e.transition(ctx, alertID, func(a *Alert, now time.Time) error {
+		if a.State != StateActive {
+			return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, a.State, StateAcknowledged)
+		}
+		a.moveTo(StateAcknowledged, user, comment, now)
+		a.AcknowledgedAt = &now
+		a.AcknowledgedBy = user
+		if comment != "" {
+			a.Comment = comment
+		}
+		return nil
+	})
+}
+
+// Resolve закрывает тревогу вручную. Если условие все еще выполняется,
+// повторная тревога того же типа не поднимется, пока условие не уйдет
+func (e *Engine) Resolve(ctx context.Context, alertID, user, comment string) (*Alert, error) {
+	return e.transition(ctx, alertID, func(a *Alert, now time.Time) error {
+		if a.State == StateResolved {
+			return fmt.Errorf("%w: alert already resolved", ErrInvalidTransition)
+		}
+		a.moveTo(StateResolved, user, comment, now)
+		a.ResolvedAt = &now
+		a.ResolvedBy = user
+		if comment != "" {
+			a.Comment = comment
+		}
+		return nil
+	})
+}
+
+// Alerts возвращает все тревоги сессии в порядке появления
+func (e *Engine) Alerts(ctx context.Context, sessionID string) ([]*Alert, error) {
+	return e.store.ListAlerts(ctx, sessionID)
+}
+
+// transition применяет ручное изменение состояния к тревоге
+func (e *Engine) transition(ctx context.Context, alertID string, apply func(a *Alert, now time.Time) error) (*Alert, error) {
+	stored, err := e.store.GetAlert(ctx, alertID)
+	if errors.Is(err, ErrNotFound) && e.archive != nil {
+		return e.transitionArchived(ctx, alertID, apply)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// Если сессия уже в памяти, работаем с ее экземпляром тревоги
+	alert := stored
+	var cond *condition
+	if state, ok := e.sessions[stored.SessionID]; ok {
+		if c, ok := state.conditions[stored.Type]; ok && c.alert != nil && c.alert.ID == alertID {
+			alert = c.alert
+			cond = c
+		}
+	}
+
+	now := e.now()
+	if err := apply(alert, now); err != nil {
+		return nil, err
+	}
+
+	if cond != nil && alert.State == StateResolved {
+		cond.alert = nil
+		cond.clearSince = time.Time{}
+		cond.suppressed = !cond.since.IsZero()
+	}
+
+	if err := e.store.SaveAlert(ctx, alert); err != nil {
+		return nil, err
+	}
+	e.notify(alert)
+
+	return alert, nil
+}
+
+// transitionArchived применяет ручное изменение к тревоге сохраненной сессии, которой
+// уже нет в Store. Сессия не в мониторинге, поэтому состояние правил не затрагивается
+func (e *Engine) transitionArchived(ctx context.Context, alertID string, apply func(a *Alert, now time.Time) error) (*Alert, error) {
+	alert, err := e.archive.GetAlert(ctx, alertID)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := apply(alert, e.now()); err != nil {
+		return nil, err
+	}
+	if err := e.archive.SaveAlert(ctx, alert); err != nil {
+		return nil, err
+	}
+	e.notify(alert)
+
+	return alert, nil
+}
+
+// sessionLocked возвращает состояние сессии, при первом обращении восстанавливая
+// открытые тревоги из хранилища (дедупликация переживает рестарт)
+func (e *Engine) sessionLocked(ctx context.Context, sessionID string) (*sessionState, error) {
+	now := e.now()
+	if state, ok := e.sessions[sessionID]; ok {
+		state.seen = now
+		return state, nil
+	}
+	e.evictIdleLocked(now)
+
+	alerts, err := e.store.ListAlerts(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load alerts for session %s: %w", sessionID, err)
+	}
+
+	state := &sessionState{conditions: make(map[Type]*condition), seen: now}
+	for _, a := range alerts {
+		if a.Open() {
+			c := state.condition(a.Type)
+			c.alert = a
+			c.since = a.RaisedAt
+		}
+	}
+
+	e.sessions[sessionID] = state
+	return state, nil
+}
+
+// evictIdleLocked удаляет состояния сессий, к которым правила давно не обращались
+func (e *Engine) evictIdleLocked(now time.Time) {
+	for id, state := range e.sessions {
+		if now.Sub(state.seen) > idleSessionTimeout {
+			delete(e.sessions, id)
+		}
+	}
+}
+
+// applyLocked продвигает состояние правила и поднимает/обновляет/снимает тревогу
+func (e *Engine) applyLocked(ctx context.Context, sessionID string, state *sessionState, obs observation) error {
+	c := state.condition(obs.typ)
+	now := e.now()
+
+	switch obs.signal {
+	case signalOn:
+		c.clearSince = time.Time{}
+		if c.since.IsZero() {
+			c.since = now
+		}
+		if c.suppressed {
+			return nil
+		}
+
+		if c.alert != nil {
+			// Тревога уже открыта - только обновляем значение, без повторного уведомления
+			if c.alert.Value == obs.value {
+				return nil
+			}
+			c.alert.Value = obs.value
+			c.alert.Message = obs.message
+			c.alert.UpdatedAt = now
+			return e.store.SaveAlert(ctx, c.alert)
+		}
+
+		if now.Sub(c.since) < obs.hold {
+			return nil
+		}
+
+		alert := &Alert{
+			ID:        uuid.NewString(),
+			SessionID: sessionID,
+			Type:      obs.typ,
+			Severity:  obs.severity,
+			Message:   obs.message,
+			Value:     obs.value,
+			Threshold: obs.threshold,
+			RaisedAt:  now,
+		}
+		alert.moveTo(StateActive, SystemUser, "", now)
+		if err := e.store.SaveAlert(ctx, alert); err != nil {
+			return err
+		}
+		c.alert = alert
+
+		slog.Warn("Alert raised", logging.SessionID(sessionID), "type", obs.typ, "severity", obs.severity, "value", obs.value, "threshold", obs.threshold)
+		e.notify(alert)
+
+	case signalOff:
+		c.since = time.Time{}
+		c.suppressed = false
+		if c.alert == nil {
+			return nil
+		}
+
+		if c.clearSince.IsZero() {
+			c.clearSince = now
+		}
+		if now.Sub(c.clearSince) < e.settings().AlertClearDelay {
+			return nil
+		}
+
+		alert := c.alert
+		alert.moveTo(StateResolved, SystemUser, "", now)
+		alert.ResolvedAt = &now
+		alert.ResolvedBy = SystemUser
+		alert.Value = obs.value
+		if err := e.store.SaveAlert(ctx, alert); err != nil {
+			return err
+		}
+		c.alert = nil
+		c.clearSince = time.Time{}
+
+		slog.Info("Alert resolved", logging.SessionID(sessionID), "type", obs.typ, "value", obs.value)
+		e.notify(alert)
+
+	case signalHold:
+		// В полосе гистерезиса условие не считается ушедшим
+		c.clearSince = time.Time{}
+	}
+
+	return nil
+}
+
+func (e *Engine) notify(alert *Alert) {
+	if e.notifier != nil {
+		e.notifier.NotifyAlert(alert)
+	}
+}
+
+// ===== Правила =====
+
+func (e *Engine) checkSTV(stv float64) observation {
+	cfg := e.settings()
+	obs := observation{
+		typ:       TypeLowSTV,
+		severity:  SeverityCritical,
+		value:     stv,
+		threshold: cfg.AlertSTVLow,
+		hold:      cfg.AlertSTVDuration,
+		message:   fmt.Sprintf("STV %.2f мс ниже порога %.2f мс", stv, cfg.AlertSTVLow),
+	}
+	switch {
+	case stv < cfg.AlertSTVLow:
+		obs.signal = signalOn
+	case stv >= cfg.AlertSTVLow+cfg.AlertSTVHysteresis:
+		obs.signal = signalOff
+	}
+	return obs
+}
+
+func (e *Engine) checkTachycardia(bpm float64) observation {
+	cfg := e.settings()
+	obs := observation{
+		typ:       TypeTachycardia,
+		severity:  SeverityWarning,
+		value:     bpm,
+		threshold: cfg.AlertTachycardiaBPM,
+		hold:      cfg.AlertBaselineDuration,
+		message:   fmt.Sprintf("Базальная ЧСС %.0f уд/мин выше %.0f уд/мин (тахикардия)", bpm, cfg.AlertTachycardiaBPM),
+	}
+	switch {
+	case bpm > cfg.AlertTachycardiaBPM:
+		obs.signal = signalOn
+	case bpm <= cfg.AlertTachycardiaBPM-cfg.AlertBPMHysteresis:
+		obs.signal = signalOff
+	}
+	return obs
+}
+
+func (e *Engine) checkBradycardia(bpm float64) observation {
+	cfg := e.settings()
+	obs := observation{
+		typ:       TypeBradycardia,
+		severity:  SeverityCritical,
+		value:     bpm,
+		threshold: cfg.AlertBradycardiaBPM,
+		hold:      cfg.AlertBaselineDuration,
+		message:   fmt.Sprintf("Базальная ЧСС %.0f уд/мин ниже %.0f уд/мин (брадикардия)", bpm, cfg.AlertBradycardiaBPM),
+	}
+	switch {
+	case bpm < cfg.AlertBradycardiaBPM:
+		obs.signal = signalOn
+	case bpm >= cfg.AlertBradycardiaBPM+cfg.AlertBPMHysteresis:
+		obs.signal = signalOff
+	}
+	return obs
+}
+
+// checkLateDecelerations считает поздние децелерации за скользящее окно.
+// Feature extractor отдает накопленное количество, поэтому храним историю
+// значений и берем разницу с самым ранним значением внутри окна
+func (e *Engine) checkLateDecelerations(state *sessionState, total int32) observation {
+	cfg := e.settings()
+	now := e.now()
+
+	// Первое значение (новая сессия или рестарт receiver'а) - база: накопленные до него
+	// децелерации неизвестно когда произошли и в окно не попадают.
+	// Счетчик уменьшился - окно анализа в extractor'е сброшено, начинаем заново
+	switch n := len(state.lateDecels); {
+	case n == 0:
+		state.lateDecels = []decelMark{{at: now, count: total}}
+	case total < state.lateDecels[n-1].count:
+		state.lateDecels = []decelMark{{at: now, count: 0}}
+	}
+	if total != state.lateDecels[len(state.lateDecels)-1].count {
+		state.lateDecels = append(state.lateDecels, decelMark{at: now, count: total})
+	}
+
+	// Оставляем одну отметку на границе окна как базу для разницы
+	windowStart := now.Add(-cfg.AlertLateDecelWindow)
+	for len(state.lateDecels) > 1 && !state.lateDecels[1].at.After(windowStart) {
+		state.lateDecels = state.lateDecels[1:]
+	}
+
+	recent := total - state.lateDecels[0].count
+	obs := observation{
+		typ:       TypeLateDecelerations,
+		severity:  SeverityCritical,
+		value:     float64(recent),
+		threshold: float64(cfg.AlertLateDecelCount),
+		message: fmt.Sprintf("%d поздних децелераций за последние %.0f мин",
+			recent, cfg.AlertLateDecelWindow.Minutes()),
+	}
+	switch {
+	case int(recent) >= cfg.AlertLateDecelCount:
+		obs.signal = signalOn
+	case recent == 0:
+		obs.signal = signalOff
+	}
+	return obs
+}
+
+func (e *Engine) checkPrediction(prediction float64) observation {
+	cfg := e.settings()
+	obs := observation{
+		typ:       TypeHighRisk,
+		severity:  SeverityCritical,
+		value:     prediction,
+		threshold: cfg.AlertPredictionCutoff,
+		message:   fmt.Sprintf("Вероятность патологии %.2f выше порога %.2f", prediction, cfg.AlertPredictionCutoff),
+	}
+	switch {
+	case prediction >= cfg.AlertPredictionCutoff:
+		obs.signal = signalOn
+	case prediction < cfg.AlertPredictionCutoff-cfg.AlertPredictionHysteresis:
+		obs.signal = signalOff
+	}
+	return obs
+}
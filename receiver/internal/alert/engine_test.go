@@ -0,0 +1,407 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	featureextractorv1 "github.com/Krimson/fetal-monitory/proto/feature_extractor"
+	"github.com/Krimson/fetal-monitory/receiver/internal/config"
+)
+
+// MemoryStore - хранилище тревог в памяти для тестов
+type MemoryStore struct {
+	mu     sync.Mutex
+	alerts map[string]Alert
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{alerts: make(map[string]Alert)}
+}
+
+func (s *MemoryStore) SaveAlert(ctx context.Context, alert *Alert) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.alerts[alert.ID] = *alert
+	return nil
+}
+
+func (s *MemoryStore) GetAlert(ctx context.Context, alertID string) (*Alert, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.alerts[alertID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &a, nil
+}
+
+func (s *MemoryStore) ListAlerts(ctx context.Context, sessionID string) ([]*Alert, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []*Alert
+	for _, a := range s.alerts {
+		if a.SessionID == sessionID {
+			a := a
+			result = append(result, &a)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].RaisedAt.Before(result[j].RaisedAt) })
+	return result, nil
+}
+
+// RecordingNotifier запоминает все отправленные уведомления
+type RecordingNotifier struct {
+	mu     sync.Mutex
+	alerts []Alert
+}
+
+func (n *RecordingNotifier) NotifyAlert(alert *Alert) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.alerts = append(n.alerts, *alert)
+}
+
+func (n *RecordingNotifier) Count() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.alerts)
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		AlertSTVLow:               3.0,
+		AlertSTVHysteresis:        0.5,
+		AlertSTVDuration:          10 * time.Minute,
+		AlertTachycardiaBPM:       160,
+		AlertBradycardiaBPM:       110,
+		AlertBPMHysteresis:        5,
+		AlertBaselineDuration:     10 * time.Minute,
+		AlertLateDecelCount:       3,
+		AlertLateDecelWindow:      30 * time.Minute,
+		AlertPredictionCutoff:     0.7,
+		AlertPredictionHysteresis: 0.1,
+		AlertClearDelay:           time.Minute,
+	}
+}
+
+// testEngine создает движок с управляемыми часами
+func testEngine(store Store) (*Engine, *RecordingNotifier, *time.Time) {
+	notifier := &RecordingNotifier{}
+	engine := NewEngine(testConfig(), store, notifier)
+	clock := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	engine.now = func() time.Time { return clock }
+	return engine, notifier, &clock
+}
+
+func features(stv, baseline float64, late int32) *featureextractorv1.ProcessBatchResponse {
+	return &featureextractorv1.ProcessBatchResponse{
+		SessionId:         "session1",
+		Stv:               stv,
+		BaselineHeartRate: baseline,
+		LateDecelerations: late,
+	}
+}
+
+func openAlerts(t *testing.T, engine *Engine, typ Type) []*Alert {
+	t.Helper()
+	alerts, err := engine.Alerts(context.Background(), "session1")
+	if err != nil {
+		t.Fatalf("Alerts: %v", err)
+	}
+	var result []*Alert
+	for _, a := range alerts {
+		if a.Type == typ && a.Open() {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+func TestEngine_LowSTVRequiresDurationAndHysteresis(t *testing.T) {
+	ctx := context.Background()
+	engine, notifier, clock := testEngine(NewMemoryStore())
+
+	engine.ProcessFeatures(ctx, features(2.0, 140, 0))
+	*clock = clock.Add(5 * time.Minute)
+	engine.ProcessFeatures(ctx, features(2.5, 140, 0))
+	if len(openAlerts(t, engine, TypeLowSTV)) != 0 {
+		t.Fatal("alert raised before STV duration elapsed")
+	}
+
+	*clock = clock.Add(5 * time.Minute)
+	engine.ProcessFeatures(ctx, features(2.2, 140, 0))
+	alerts := openAlerts(t, engine, TypeLowSTV)
+	if len(alerts) != 1 || alerts[0].Severity != SeverityCritical {
+		t.Fatalf("expected one critical low STV alert, got %+v", alerts)
+	}
+
+	// Повторные срабатывания не создают дубликатов и не шлют уведомлений
+	*clock = clock.Add(time.Minute)
+	engine.ProcessFeatures(ctx, features(2.1, 140, 0))
+	if len(openAlerts(t, engine, TypeLowSTV)) != 1 || notifier.Count() != 1 {
+		t.Fatalf("expected deduplicated alert, notifications=%d", notifier.Count())
+	}
+
+	// В полосе гистерезиса тревога не снимается даже после задержки
+	for i := 0; i < 3; i++ {
+		*clock = clock.Add(time.Minute)
+		engine.ProcessFeatures(ctx, features(3.2, 140, 0))
+	}
+	if len(openAlerts(t, engine, TypeLowSTV)) != 1 {
+		t.Fatal("alert resolved inside hysteresis band")
+	}
+
+	// Выше порога+гистерезиса тревога снимается после ClearDelay
+	*clock = clock.Add(time.Minute)
+	engine.ProcessFeatures(ctx, features(3.6, 140, 0))
+	if len(openAlerts(t, engine, TypeLowSTV)) != 1 {
+		t.Fatal("alert resolved before clear delay")
+	}
+	*clock = clock.Add(time.Minute)
+	engine.ProcessFeatures(ctx, features(3.6, 140, 0))
+	if len(openAlerts(t, engine, TypeLowSTV)) != 0 {
+		t.Fatal("alert not resolved after clear delay")
+	}
+	if notifier.Count() != 2 {
+		t.Fatalf("expected raise and resolve notifications, got %d", notifier.Count())
+	}
+}
+
+func TestEngine_BaselineAndPrediction(t *testing.T) {
+	ctx := context.Background()
+	engine, _, clock := testEngine(NewMemoryStore())
+
+	engine.ProcessFeatures(ctx, features(5, 170, 0))
+	*clock = clock.Add(10 * time.Minute)
+	engine.ProcessFeatures(ctx, features(5, 168, 0))
+
+	tachy := openAlerts(t, engine, TypeTachycardia)
+	if len(tachy) != 1 || tachy[0].Severity != SeverityWarning {
+		t.Fatalf("expected tachycardia warning, got %+v", tachy)
+	}
+	if len(openAlerts(t, engine, TypeBradycardia)) != 0 {
+		t.Fatal("unexpected bradycardia alert")
+	}
+
+	// Предсказание поднимает тревогу сразу
+	engine.ProcessPrediction(ctx, "session1", 0.65)
+	if len(openAlerts(t, engine, TypeHighRisk)) != 0 {
+		t.Fatal("prediction below cutoff raised alert")
+	}
+	engine.ProcessPrediction(ctx, "session1", 0.8)
+	if len(openAlerts(t, engine, TypeHighRisk)) != 1 {
+		t.Fatal("prediction above cutoff did not raise alert")
+	}
+}
+
+func TestEngine_LateDecelerationsWindow(t *testing.T) {
+	ctx := context.Background()
+	engine, _, clock := testEngine(NewMemoryStore())
+
+	engine.ProcessFeatures(ctx, features(5, 140, 2))
+	*clock = clock.Add(40 * time.Minute)
+	engine.ProcessFeatures(ctx, features(5, 140, 3))
+	if len(openAlerts(t, engine, TypeLateDecelerations)) != 0 {
+		t.Fatal("decelerations outside window counted")
+	}
+
+	*clock = clock.Add(5 * time.Minute)
+	engine.ProcessFeatures(ctx, features(5, 140, 5))
+	alerts := openAlerts(t, engine, TypeLateDecelerations)
+	if len(alerts) != 1 || alerts[0].Value != 3 {
+		t.Fatalf("expected alert for 3 recent late decelerations, got %+v", alerts)
+	}
+}
+
+func TestEngine_LateDecelerationsAfterRestart(t *testing.T) {
+	ctx := context.Background()
+	engine, _, clock := testEngine(NewMemoryStore())
+
+	// После рестарта receiver'а extractor продолжает накопленный счет: старые
+	// децелерации не должны попасть в окно
+	engine.ProcessFeatures(ctx, features(5, 140, 10))
+	*clock = clock.Add(time.Minute)
+	engine.ProcessFeatures(ctx, features(5, 140, 12))
+	if alerts := openAlerts(t, engine, TypeLateDecelerations); len(alerts) != 0 {
+		t.Fatalf("decelerations counted before restart raised alert: %+v", alerts)
+	}
+
+	*clock = clock.Add(time.Minute)
+	engine.ProcessFeatures(ctx, features(5, 140, 13))
+	alerts := openAlerts(t, engine, TypeLateDecelerations)
+	if len(alerts) != 1 || alerts[0].Value != 3 {
+		t.Fatalf("expected alert for 3 late decelerations after restart, got %+v", alerts)
+	}
+}
+
+func TestEngine_ForgetsSessions(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	engine, _, clock := testEngine(store)
+
+	engine.ProcessPrediction(ctx, "session1", 0.9)
+	engine.ProcessPrediction(ctx, "session2", 0.2)
+	engine.Forget("session1")
+	if _, ok := engine.sessions["session1"]; ok || len(engine.sessions) != 1 {
+		t.Fatalf("Expected only session2 in memory after Forget, got %d sessions", len(engine.sessions))
+	}
+
+	// Открытая тревога забытой сессии восстанавливается из Store без дубликата
+	engine.ProcessPrediction(ctx, "session1", 0.9)
+	if n := len(openAlerts(t, engine, TypeHighRisk)); n != 1 {
+		t.Fatalf("Expected 1 open alert after Forget, got %d", n)
+	}
+
+	// Сессии без признаков дольше idleSessionTimeout вытесняются при появлении новой
+	*clock = clock.Add(idleSessionTimeout + time.Minute)
+	engine.ProcessPrediction(ctx, "session3", 0.2)
+	if _, ok := engine.sessions["session3"]; !ok || len(engine.sessions) != 1 {
+		t.Fatalf("Expected idle sessions to be evicted, got %d sessions", len(engine.sessions))
+	}
+}
+
+func TestEngine_AcknowledgeAndResolve(t *testing.T) {
+	ctx := context.Background()
+	engine, notifier, clock := testEngine(NewMemoryStore())
+
+	engine.ProcessPrediction(ctx, "session1", 0.9)
+	alert := openAlerts(t, engine, TypeHighRisk)[0]
+
+	acked, err := engine.Acknowledge(ctx, alert.ID, "dr.ivanov", "вижу")
+	if err != nil || acked.State != StateAcknowledged || acked.AcknowledgedBy != "dr.ivanov" {
+		t.Fatalf("Acknowledge: %+v, %v", acked, err)
+	}
+	if _, err := engine.Acknowledge(ctx, alert.ID, "dr.ivanov", ""); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("expected ErrInvalidTransition on double ack, got %v", err)
+	}
+
+	resolved, err := engine.Resolve(ctx, alert.ID, "dr.petrova", "КТГ переснята")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if len(resolved.History) != 3 {
+		t.Fatalf("expected raise/ack/resolve history, got %+v", resolved.History)
+	}
+	if last := resolved.History[2]; last.From != StateAcknowledged || last.To != StateResolved || last.By != "dr.petrova" {
+		t.Fatalf("unexpected resolve transition: %+v", last)
+	}
+
+	// Пока условие держится, закрытая вручную тревога не поднимается заново
+	*clock = clock.Add(time.Minute)
+	engine.ProcessPrediction(ctx, "session1", 0.9)
+	if len(openAlerts(t, engine, TypeHighRisk)) != 0 {
+		t.Fatal("manually resolved alert re-raised while condition persists")
+	}
+
+	// Условие ушло и вернулось - новая тревога
+	engine.ProcessPrediction(ctx, "session1", 0.3)
+	engine.ProcessPrediction(ctx, "session1", 0.9)
+	if len(openAlerts(t, engine, TypeHighRisk)) != 1 {
+		t.Fatal("alert not re-raised after condition returned")
+	}
+	if notifier.Count() != 4 {
+		t.Fatalf("expected 4 notifications (raise, ack, resolve, raise), got %d", notifier.Count())
+	}
+
+	if _, err := engine.Resolve(ctx, "missing", "dr.ivanov", ""); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestEngine_RestoresOpenAlertsFromStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	first, _, _ := testEngine(store)
+	first.ProcessPrediction(ctx, "session1", 0.9)
+
+	// Новый экземпляр (рестарт) не должен дублировать открытую тревогу
+	second, notifier, _ := testEngine(store)
+	second.ProcessPrediction(ctx, "session1", 0.95)
+
+	if len(openAlerts(t, second, TypeHighRisk)) != 1 {
+		t.Fatal("duplicate alert after restart")
+	}
+	if notifier.Count() != 0 {
+		t.Fatalf("unexpected notifications after restart: %d", notifier.Count())
+	}
+}
+
+func TestEngine_TransitionFallsBackToArchive(t *testing.T) {
+	ctx := context.Background()
+
+	// Тревога сохраненной сессии: в Redis ее уже нет (истек TTL), есть только в PostgreSQL
+	raisedAt := time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC)
+	archive := NewMemoryStore()
+	archive.SaveAlert(ctx, &Alert{
+		ID:        "archived",
+		SessionID: "session1",
+		Type:      TypeHighRisk,
+		State:     StateActive,
+		RaisedAt:  raisedAt,
+		UpdatedAt: raisedAt,
+		History:   []Transition{{To: StateActive, By: "system", At: raisedAt}},
+	})
+
+	store := NewMemoryStore()
+	engine, notifier, _ := testEngine(store)
+	if _, err := engine.Acknowledge(ctx, "archived", "dr.ivanov", ""); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound without archive, got %v", err)
+	}
+
+	engine.SetArchive(archive)
+	acked, err := engine.Acknowledge(ctx, "archived", "dr.ivanov", "")
+	if err != nil {
+		t.Fatalf("Acknowledge: %v", err)
+	}
+	if acked.State != StateAcknowledged || len(acked.History) != 2 {
+		t.Fatalf("unexpected archived alert after ack: %+v", acked)
+	}
+
+	stored, err := archive.GetAlert(ctx, "archived")
+	if err != nil || stored.State != StateAcknowledged {
+		t.Fatalf("ack not written to archive: %+v, %v", stored, err)
+	}
+	if _, err := store.GetAlert(ctx, "archived"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("archived alert must not be copied back to the store, got %v", err)
+	}
+	if notifier.Count() != 1 {
+		t.Fatalf("expected 1 notification, got %d", notifier.Count())
+	}
+
+	if _, err := engine.Resolve(ctx, "archived", "dr.petrova", ""); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if _, err := engine.Resolve(ctx, "missing", "dr.ivanov", ""); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestEngine_UpdateConfig(t *testing.T) {
+	ctx := context.Background()
+	engine, _, clock := testEngine(NewMemoryStore())
+
+	engine.ProcessFeatures(ctx, features(3.5, 140, 0))
+	*clock = clock.Add(2 * time.Minute)
+	engine.ProcessFeatures(ctx, features(3.5, 140, 0))
+	if len(openAlerts(t, engine, TypeLowSTV)) != 0 {
+		t.Fatal("alert raised above STV threshold")
+	}
+
+	// Новые пороги действуют с ближайшей оценки
+	updated := testConfig()
+	updated.AlertSTVLow = 4.0
+	updated.AlertSTVDuration = time.Minute
+	engine.UpdateConfig(updated)
+
+	engine.ProcessFeatures(ctx, features(3.5, 140, 0))
+	*clock = clock.Add(time.Minute)
+	engine.ProcessFeatures(ctx, features(3.5, 140, 0))
+	if len(openAlerts(t, engine, TypeLowSTV)) != 1 {
+		t.Fatal("expected alert with updated thresholds")
+	}
+}
@@ -0,0 +1,108 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore хранит тревоги в Redis рядом с остальными данными сессии
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore создает новый экземпляр RedisStore
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{
+		client: client,
+	}
+}
+
+// SessionAlertsKey - hash alert_id -> JSON; удаляется и истекает вместе
+// с остальными ключами session:{id}:*
+func SessionAlertsKey(sessionID string) string {
+	return fmt.Sprintf("session:%s:alerts", sessionID)
+}
+
+// IndexKey - строка alert_id -> session_id для поиска тревоги по ID. Ключ живет
+// столько же, сколько тревоги сессии: session.RedisStore удаляет его и задает ему
+// TTL вместе с ключами сессии
+func IndexKey(alertID string) string {
+	return fmt.Sprintf("alert:%s:session", alertID)
+}
+
+func (r *RedisStore) SaveAlert(ctx context.Context, alert *Alert) error {
+	data, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert: %w", err)
+	}
+
+	pipe := r.client.TxPipeline()
+	pipe.HSet(ctx, SessionAlertsKey(alert.SessionID), alert.ID, data)
+	pipe.Set(ctx, IndexKey(alert.ID), alert.SessionID, 0)
+	ttl := pipe.PTTL(ctx, SessionAlertsKey(alert.SessionID))
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save alert: %w", err)
+	}
+
+	// У сессии уже есть TTL (остановлена или сохранена) - индекс истекает вместе с ней
+	if d := ttl.Val(); d > 0 {
+		if err := r.client.PExpire(ctx, IndexKey(alert.ID), d).Err(); err != nil {
+			return fmt.Errorf("failed to set alert index ttl: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *RedisStore) GetAlert(ctx context.Context, alertID string) (*Alert, error) {
+	sessionID, err := r.client.Get(ctx, IndexKey(alertID)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, alertID)
+		}
+		return nil, fmt.Errorf("failed to get alert: %w", err)
+	}
+
+	data, err := r.client.HGet(ctx, SessionAlertsKey(sessionID), alertID).Result()
+	if err != nil {
+		if err == redis.Nil {
+			// Сессия удалена в обход session.RedisStore, индекс устарел
+			r.client.Del(ctx, IndexKey(alertID))
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, alertID)
+		}
+		return nil, fmt.Errorf("failed to get alert: %w", err)
+	}
+
+	var alert Alert
+	if err := json.Unmarshal([]byte(data), &alert); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal alert: %w", err)
+	}
+
+	return &alert, nil
+}
+
+func (r *RedisStore) ListAlerts(ctx context.Context, sessionID string) ([]*Alert, error) {
+	values, err := r.client.HVals(ctx, SessionAlertsKey(sessionID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alerts: %w", err)
+	}
+
+	alerts := make([]*Alert, 0, len(values))
+	for _, data := range values {
+		var alert Alert
+		if err := json.Unmarshal([]byte(data), &alert); err != nil {
+			continue // Пропускаем поврежденные записи
+		}
+		alerts = append(alerts, &alert)
+	}
+
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].RaisedAt.Before(alerts[j].RaisedAt)
+	})
+
+	return alerts, nil
+}
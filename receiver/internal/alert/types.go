@@ -0,0 +1,111 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Type - вид клинической тревоги
+type Type string
+
+const (
+	TypeLowSTV            Type = "low_stv"              // STV ниже порога дольше заданного времени
+	TypeTachycardia       Type = "tachycardia"          // Базальная ЧСС выше нормы
+	TypeBradycardia       Type = "bradycardia"          // Базальная ЧСС ниже нормы
+	TypeLateDecelerations Type = "late_decelerations"   // Повторяющиеся поздние децелерации
+	TypeHighRisk          Type = "high_risk_prediction" // ML предсказание выше порога
+)
+
+// Severity - важность тревоги
+type Severity string
+
+const (
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+// State - состояние тревоги
+type State string
+
+const (
+	StateActive       State = "active"       // Условие выполняется, тревогу никто не видел
+	StateAcknowledged State = "acknowledged" // Врач увидел тревогу
+	StateResolved     State = "resolved"     // Условие ушло или тревога закрыта вручную
+)
+
+// SystemUser - автор автоматических изменений состояния
+const SystemUser = "system"
+
+var (
+	ErrNotFound          = errors.New("alert not found")
+	ErrInvalidTransition = errors.New("invalid alert state transition")
+)
+
+// Alert представляет клиническую тревогу сессии
+type Alert struct {
+	ID             string     `json:"id"`
+	SessionID      string     `json:"session_id"`
+	Type           Type       `json:"type"`
+	Severity       Severity   `json:"severity"`
+	State          State      `json:"state"`
+	Message        string     `json:"message"`
+	Value          float64    `json:"value"`     // Значение показателя при последней оценке
+	Threshold      float64    `json:"threshold"` // Порог срабатывания
+	RaisedAt       time.Time  `json:"raised_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy string     `json:"acknowledged_by,omitempty"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+	ResolvedBy     string     `json:"resolved_by,omitempty"`
+	Comment        string     `json:"comment,omitempty"`
+
+	// История изменений состояния (аудит: кто и когда увидел и закрыл тревогу)
+	History []Transition `json:"history"`
+}
+
+// Transition - запись журнала изменений состояния тревоги
+type Transition struct {
+	From    State     `json:"from,omitempty"`
+	To      State     `json:"to"`
+	By      string    `json:"by"`
+	Comment string    `json:"comment,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// Open - тревога еще не закрыта
+func (a *Alert) Open() bool {
+	return a.State != StateResolved
+}
+
+// moveTo переводит тревогу в новое состояние и записывает переход в историю
+func (a *Alert) moveTo(state State, by, comment string, at time.Time) {
+	a.History = append(a.History, Transition{
+		From:    a.State,
+		To:      state,
+		By:      by,
+		Comment: comment,
+		At:      at,
+	})
+	a.State = state
+	a.UpdatedAt = at
+}
+
+// Store определяет хранилище тревог
+type Store interface {
+	SaveAlert(ctx context.Context, alert *Alert) error
+	GetAlert(ctx context.Context, alertID string) (*Alert, error)
+	ListAlerts(ctx context.Context, sessionID string) ([]*Alert, error)
+}
+
+// Archive - долговременное хранилище тревог сохраненных сессий (PostgreSQL).
+// Движок обращается к нему, когда тревоги уже нет в Store (истек TTL кэша)
+type Archive interface {
+	SaveAlert(ctx context.Context, alert *Alert) error
+	GetAlert(ctx context.Context, alertID string) (*Alert, error)
+}
+
+// Notifier получает тревоги при каждом изменении состояния (например, WebSocket hub)
+type Notifier interface {
+	NotifyAlert(alert *Alert)
+}
@@ -3,23 +3,39 @@ package batch
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Krimson/fetal-monitory/logging"
 	telemetryv1 "github.com/Krimson/fetal-monitory/proto/telemetry"
 	"github.com/Krimson/fetal-monitory/receiver/internal/config"
+	"github.com/Krimson/fetal-monitory/receiver/internal/metrics"
+	"github.com/Krimson/fetal-monitory/receiver/internal/tracing"
 )
 
 type Batcher struct {
-	cfg     *config.Config
+	cfg     atomic.Pointer[config.Config] // Меняется UpdateConfig при перезагрузке конфигурации
 	sink    Sink
 	mu      sync.RWMutex
 	batches map[BatchKey]*currentBatch
+	joint   map[string]*jointBatch // Окна сессий в режиме joint
+
+	flushChan  chan Batch
+	stopChan   chan struct{}
+	reloadChan chan struct{} // Сигнал timerFlusher о новой конфигурации
+
+	// Очередь между flushChan и sink: повторные попытки и порядок внутри сессии
+	queue    *outboundQueue
+	syncChan chan chan struct{}
 
-	flushChan chan Batch
-	stopChan  chan struct{}
+	// Метрики Prometheus по каналам (необязательны)
+	metrics *metrics.Receiver
 
 	stats struct {
 		mu         sync.RWMutex
@@ -34,46 +50,130 @@ type LogSink struct{}
 
 func (ls *LogSink) Consume(ctx context.Context, b Batch) error {
 	spanMS := b.T1MS - b.T0MS
-	log.Printf("[BATCH] session=%s metric=%s points=%d span_ms=%d t0=%d t1=%d",
-		b.Key.SessionID,
-		b.Key.Metric.String(),
-		len(b.Points),
-		spanMS,
-		b.T0MS,
-		b.T1MS)
+	slog.DebugContext(ctx, "Batch",
+		logging.SessionID(b.Key.SessionID),
+		logging.Metric(b.Key.Metric),
+		logging.BatchTS(b.T0MS),
+		"points", len(b.Points),
+		"span_ms", spanMS)
 	return nil
 }
 
+// Stats - статистика работы Batcher и его исходящей очереди
+type Stats struct {
+	Received   int64 `json:"received"`
+	Dropped    int64 `json:"dropped"`
+	Flushed    int64 `json:"flushed"`
+	OutOfOrder int64 `json:"out_of_order"`
+
+	QueueDepth       int   `json:"queue_depth"`       // Батчей ожидает доставки
+	QueueSessions    int   `json:"queue_sessions"`    // Сессий с непустой очередью
+	Delivered        int64 `json:"delivered"`         // Батчей успешно доставлено в sink
+	Retries          int64 `json:"retries"`           // Повторных попыток после ошибки sink
+	DeliveryFailures int64 `json:"delivery_failures"` // Батчей отброшено после исчерпания попыток
+	Evicted          int64 `json:"evicted"`           // Батчей вытеснено из переполненной очереди
+}
+
+// sinkTimeout - таймаут одного вызова Sink.Consume
+const sinkTimeout = 5 * time.Second
+
+// stopDrainTimeout - сколько Stop ждет доставки оставшихся батчей
+const stopDrainTimeout = 10 * time.Second
+
 func NewBatcher(cfg *config.Config, sink Sink) *Batcher {
+	backoff := backoffPolicy{
+		base:        cfg.RetryBaseDelay,
+		max:         cfg.RetryMaxDelay,
+		maxAttempts: cfg.RetryMaxAttempts,
+	}
+
+	workers := cfg.FlushWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	queue, err := newOutboundQueue(cfg.QueueMaxDepth, backoff, cfg.QueueDir, workers)
+	if err != nil {
+		slog.Error("Failed to open outbound queue, falling back to in-memory queue", "dir", cfg.QueueDir, logging.Err(err))
+		queue, _ = newOutboundQueue(cfg.QueueMaxDepth, backoff, "", workers)
+	}
+
 	b := &Batcher{
-		cfg:       cfg,
-		sink:      sink,
-		batches:   make(map[BatchKey]*currentBatch),
-		flushChan: make(chan Batch, 100),
-		stopChan:  make(chan struct{}),
+		sink:       sink,
+		batches:    make(map[BatchKey]*currentBatch),
+		joint:      make(map[string]*jointBatch),
+		flushChan:  make(chan Batch, 100),
+		stopChan:   make(chan struct{}),
+		reloadChan: make(chan struct{}, 1),
+		queue:      queue,
+		syncChan:   make(chan chan struct{}),
 	}
+	b.cfg.Store(cfg)
 
 	go b.flushWorker()
+	for shard := 0; shard < workers; shard++ {
+		go b.deliveryWorker(shard)
+	}
 	go b.timerFlusher()
 
 	return b
 }
 
+// SetMetrics подключает метрики Prometheus; вызывается до первого Add
+func (b *Batcher) SetMetrics(m *metrics.Receiver) {
+	b.metrics = m
+}
+
+// UpdateConfig применяет перезагруженную конфигурацию. Действуют поля батчинга
+// (размер и длина батча, интервал сброса, допуски по времени); режим, очередь и
+// воркеры задаются при создании
+func (b *Batcher) UpdateConfig(cfg *config.Config) {
+	b.cfg.Store(cfg)
+	select {
+	case b.reloadChan <- struct{}{}:
+	default:
+	}
+}
+
+// settings возвращает действующую конфигурацию
+func (b *Batcher) settings() *config.Config {
+	return b.cfg.Load()
+}
+
+// FlushChanDepth возвращает число батчей, ожидающих перекладки в исходящую очередь
+func (b *Batcher) FlushChanDepth() int {
+	return len(b.flushChan)
+}
+
+// Add добавляет сэмпл без контекста трассировки (см. AddContext)
 func (b *Batcher) Add(sample *telemetryv1.Sample) error {
+	return b.AddContext(context.Background(), sample)
+}
+
+// AddContext добавляет сэмпл; спан из ctx (поток PushSamples) становится связью
+// корневого спана батча, в который попадет сэмпл
+func (b *Batcher) AddContext(ctx context.Context, sample *telemetryv1.Sample) error {
 	if err := b.validateSample(sample); err != nil {
 		b.incrementDropped()
-		log.Printf("[WARN] Invalid sample dropped: %v", err)
+		b.metrics.SamplesDropped(sample.Metric, metrics.DropInvalid, 1)
+		slog.Warn("Invalid sample dropped", logging.SessionID(sample.SessionId), logging.Metric(sample.Metric), logging.Err(err))
 		return nil
 	}
 
+	cfg := b.settings()
+	if cfg.BatchMode == config.BatchModeJoint {
+		return b.addJoint(ctx, sample)
+	}
+
 	key := BatchKey{
 		SessionID: sample.SessionId,
 		Metric:    sample.Metric,
 	}
 
 	point := Point{
-		TsMS:  int64(sample.TsMs),
-		Value: sample.Value,
+		TsMS:   int64(sample.TsMs),
+		Value:  sample.Value,
+		Metric: sample.Metric,
 	}
 
 	b.mu.Lock()
@@ -88,17 +188,17 @@ func (b *Batcher) Add(sample *telemetryv1.Sample) error {
 	if exists && len(batch.Points) > 0 {
 		timeDiff := batch.T1MS - point.TsMS
 
-		if timeDiff > b.cfg.DropTooOldMS {
+		if timeDiff > cfg.DropTooOldMS {
 			b.incrementDropped()
-			log.Printf("[WARN] Sample too old, dropped: session=%s metric=%s ts_diff=%d",
-				key.SessionID, key.Metric.String(), timeDiff)
+			b.metrics.SamplesDropped(key.Metric, metrics.DropTooOld, 1)
+			slog.Warn("Sample too old, dropped", logging.SessionID(key.SessionID), logging.Metric(key.Metric), "ts_diff", timeDiff)
 			return nil
 		}
 
-		if timeDiff > int64(b.cfg.OutOfOrderTolerance.Milliseconds()) {
+		if timeDiff > int64(cfg.OutOfOrderTolerance.Milliseconds()) {
 			b.incrementOutOfOrder()
-			log.Printf("[WARN] Out of order sample: session=%s metric=%s ts_diff=%d",
-				key.SessionID, key.Metric.String(), timeDiff)
+			b.metrics.SampleOutOfOrder(key.Metric)
+			slog.Warn("Out of order sample", logging.SessionID(key.SessionID), logging.Metric(key.Metric), "ts_diff", timeDiff)
 		}
 	}
 
@@ -107,7 +207,7 @@ func (b *Batcher) Add(sample *telemetryv1.Sample) error {
 		if point.TsMS < batch.T0MS {
 			tempSpan = batch.T1MS - point.TsMS
 		}
-		if tempSpan > b.cfg.BatchMaxSpanMS {
+		if tempSpan > cfg.BatchMaxSpanMS {
 			b.flushBatch(key, batch)
 			batch = newCurrentBatch(key)
 			b.batches[key] = batch
@@ -115,9 +215,11 @@ func (b *Batcher) Add(sample *telemetryv1.Sample) error {
 	}
 
 	batch.addPoint(point)
+	batch.sources.observe(ctx, time.Now())
 	b.incrementReceived()
+	b.metrics.SampleReceived(key.Metric)
 
-	if batch.shouldFlushBySize(b.cfg.BatchMaxSamples) {
+	if batch.shouldFlushBySize(cfg.BatchMaxSamples) {
 		b.flushBatch(key, batch)
 	}
 
@@ -151,27 +253,84 @@ func (b *Batcher) flushBatch(key BatchKey, batch *currentBatch) {
 	}
 
 	batchCopy := batch.clone()
+	sources := batch.sources.take()
 
 	batch.reset()
 
+	b.emit(batchCopy, sources)
+}
+
+// emit начинает трассу батча и передает его в flushChan. Если flushChan заполнен,
+// emit ждет, пока flushWorker освободит место: батч не теряется, а Add
+// притормаживает входящий поток. Отбрасывается батч только после Stop.
+func (b *Batcher) emit(batch Batch, sources batchSources) {
+	span := startBatchSpan(&batch, sources)
+	defer span.End()
+
 	select {
-	case b.flushChan <- batchCopy:
+	case b.flushChan <- batch:
 		b.incrementFlushed()
+		b.countSamples(batch, b.metrics.SamplesFlushed)
+		return
 	default:
-		log.Printf("[WARN] Flush channel full, batch dropped")
+	}
+
+	slog.Warn("Flush channel full, waiting for flush worker", logging.SessionID(batch.Key.SessionID), logging.Metric(batch.Key.Metric), logging.BatchTS(batch.T0MS))
+	select {
+	case b.flushChan <- batch:
+		b.incrementFlushed()
+		b.countSamples(batch, b.metrics.SamplesFlushed)
+	case <-b.stopChan:
+		slog.Warn("Batcher stopped, batch dropped", logging.SessionID(batch.Key.SessionID), logging.Metric(batch.Key.Metric), logging.BatchTS(batch.T0MS))
+		span.SetStatus(codes.Error, "batcher stopped")
 		b.incrementDropped()
+		b.countSamples(batch, func(metric telemetryv1.Metric, n int) {
+			b.metrics.SamplesDropped(metric, metrics.DropStopped, n)
+		})
+	}
+}
+
+// countSamples передает в count число точек батча по каналам
+func (b *Batcher) countSamples(batch Batch, count func(metric telemetryv1.Metric, n int)) {
+	if b.metrics == nil {
+		return
+	}
+	var fhr, uc int
+	for _, p := range batch.Points {
+		switch p.Metric {
+		case telemetryv1.Metric_METRIC_FHR:
+			fhr++
+		case telemetryv1.Metric_METRIC_UC:
+			uc++
+		}
+	}
+	if fhr > 0 {
+		count(telemetryv1.Metric_METRIC_FHR, fhr)
+	}
+	if uc > 0 {
+		count(telemetryv1.Metric_METRIC_UC, uc)
 	}
 }
 
+// flushWorker перекладывает сброшенные батчи из flushChan в исходящую очередь,
+// чтобы медленный sink не переполнял flushChan
 func (b *Batcher) flushWorker() {
 	for {
 		select {
 		case batch := <-b.flushChan:
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			if err := b.sink.Consume(ctx, batch); err != nil {
-				log.Printf("[ERROR] Failed to consume batch: %v", err)
+			b.queue.push(batch)
+
+		case done := <-b.syncChan:
+			// Забираем все, что уже лежит в flushChan, и сообщаем Stop
+			for drained := false; !drained; {
+				select {
+				case batch := <-b.flushChan:
+					b.queue.push(batch)
+				default:
+					drained = true
+				}
 			}
-			cancel()
+			close(done)
 
 		case <-b.stopChan:
 			return
@@ -179,14 +338,85 @@ func (b *Batcher) flushWorker() {
 	}
 }
 
+// deliveryWorker отправляет в sink батчи сессий своего шарда. Сессии шардируются
+// по SessionID, поэтому порядок внутри сессии сохраняется, а медленная сессия
+// задерживает только сессии своего шарда. При ошибке батч остается головным
+// в очереди своей сессии и повторяется с экспоненциальной задержкой.
+func (b *Batcher) deliveryWorker(shard int) {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		batch, ok, wakeAt := b.queue.next(time.Now(), shard)
+		if !ok {
+			wait := time.Hour
+			if !wakeAt.IsZero() {
+				wait = time.Until(wakeAt)
+			}
+			timer.Reset(wait)
+
+			select {
+			case <-b.queue.notify[shard]:
+			case <-timer.C:
+			case <-b.stopChan:
+				return
+			}
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			continue
+		}
+
+		b.deliver(batch)
+	}
+}
+
+// deliver выполняет одну попытку доставки батча
+func (b *Batcher) deliver(batch Batch) {
+	sessionID := batch.Key.SessionID
+
+	ctx, span := tracer.Start(tracing.Extract(context.Background(), batch.Trace), "Batcher.deliver",
+		trace.WithAttributes(batchAttributes(batch)...))
+	ctx, cancel := context.WithTimeout(ctx, sinkTimeout)
+	err := b.sink.Consume(ctx, batch)
+	cancel()
+	endSpan(span, err)
+
+	if err == nil {
+		b.queue.ack(sessionID)
+		return
+	}
+
+	if gaveUp := b.queue.retry(sessionID, time.Now()); gaveUp {
+		slog.ErrorContext(ctx, "Failed to consume batch, giving up", logging.SessionID(sessionID), logging.Metric(batch.Key.Metric), logging.BatchTS(batch.T0MS), logging.Err(err))
+		return
+	}
+	slog.WarnContext(ctx, "Failed to consume batch, will retry", logging.SessionID(sessionID), logging.Metric(batch.Key.Metric), logging.BatchTS(batch.T0MS), logging.Err(err))
+}
+
 func (b *Batcher) timerFlusher() {
-	ticker := time.NewTicker(time.Duration(b.cfg.FlushIntervalMS) * time.Millisecond)
+	interval := time.Duration(b.settings().FlushIntervalMS) * time.Millisecond
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
+		case <-b.reloadChan:
+			// Интервал сброса мог измениться при перезагрузке конфигурации
+			if next := time.Duration(b.settings().FlushIntervalMS) * time.Millisecond; next != interval {
+				interval = next
+				ticker.Reset(interval)
+			}
+
 		case <-ticker.C:
-			b.flushOldBatches()
+			if b.settings().BatchMode == config.BatchModeJoint {
+				b.flushJointBatches(time.Now())
+			} else {
+				b.flushOldBatches()
+			}
 
 		case <-b.stopChan:
 			return
@@ -196,7 +426,7 @@ func (b *Batcher) timerFlusher() {
 
 func (b *Batcher) flushOldBatches() {
 	now := time.Now().UnixMilli()
-	flushIntervalMS := b.cfg.FlushIntervalMS
+	flushIntervalMS := b.settings().FlushIntervalMS
 
 	b.mu.Lock()
 	defer b.mu.Unlock()
@@ -210,22 +440,34 @@ func (b *Batcher) flushOldBatches() {
 }
 
 func (b *Batcher) Stop() {
-	log.Printf("[INFO] Stopping batcher...")
+	slog.Info("Stopping batcher...")
 
 	b.flushAllBatches()
 
-	for len(b.flushChan) > 0 {
-		time.Sleep(10 * time.Millisecond)
-	}
-
-	time.Sleep(100 * time.Millisecond)
-
 	select {
 	case <-b.stopChan:
+		return
 	default:
-		close(b.stopChan)
 	}
 
+	// Дожидаемся, пока flushWorker переложит все из flushChan в очередь
+	done := make(chan struct{})
+	b.syncChan <- done
+	<-done
+
+	// Даем очереди доставить оставшиеся батчи; при дисковой очереди
+	// недоставленное будет восстановлено при следующем запуске
+	deadline := time.Now().Add(stopDrainTimeout)
+	for b.queue.len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if pending := b.queue.len(); pending > 0 {
+		slog.Warn("Batcher stopped with undelivered batches in queue", "pending", pending)
+	}
+
+	close(b.stopChan)
+	b.queue.close()
+
 	b.logStats()
 }
 
@@ -238,6 +480,11 @@ func (b *Batcher) flushAllBatches() {
 			b.flushBatch(key, batch)
 		}
 	}
+
+	for sessionID, jb := range b.joint {
+		b.drainJoint(jb)
+		delete(b.joint, sessionID)
+	}
 }
 
 // Методы для работы со статистикой
@@ -266,19 +513,37 @@ func (b *Batcher) incrementOutOfOrder() {
 }
 
 func (b *Batcher) logStats() {
-	b.stats.mu.RLock()
-	defer b.stats.mu.RUnlock()
-
-	log.Printf("[STATS] received=%d dropped=%d flushed=%d out_of_order=%d",
-		b.stats.received,
-		b.stats.dropped,
-		b.stats.flushed,
-		b.stats.outOfOrder)
+	stats := b.GetStats()
+
+	slog.Info("Batcher stats",
+		"received", stats.Received,
+		"dropped", stats.Dropped,
+		"flushed", stats.Flushed,
+		"out_of_order", stats.OutOfOrder,
+		"queue_depth", stats.QueueDepth,
+		"delivered", stats.Delivered,
+		"retries", stats.Retries,
+		"delivery_failures", stats.DeliveryFailures,
+		"evicted", stats.Evicted)
 }
 
-func (b *Batcher) GetStats() (received, dropped, flushed, outOfOrder int64) {
+func (b *Batcher) GetStats() Stats {
 	b.stats.mu.RLock()
-	defer b.stats.mu.RUnlock()
+	stats := Stats{
+		Received:   b.stats.received,
+		Dropped:    b.stats.dropped,
+		Flushed:    b.stats.flushed,
+		OutOfOrder: b.stats.outOfOrder,
+	}
+	b.stats.mu.RUnlock()
+
+	depth, sessions, qs := b.queue.snapshot()
+	stats.QueueDepth = depth
+	stats.QueueSessions = sessions
+	stats.Delivered = qs.delivered
+	stats.Retries = qs.retries
+	stats.DeliveryFailures = qs.failed
+	stats.Evicted = qs.evicted
 
-	return b.stats.received, b.stats.dropped, b.stats.flushed, b.stats.outOfOrder
+	return stats
 }
@@ -2,12 +2,15 @@ package batch
 
 import (
 	"context"
+	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	telemetryv1 "github.com/Krimson/fetal-monitory/proto/telemetry"
 	"github.com/Krimson/fetal-monitory/receiver/internal/config"
+	"github.com/Krimson/fetal-monitory/receiver/internal/metrics"
 )
 
 // TestSink для тестирования - собирает все батчи
@@ -195,12 +198,12 @@ func TestBatcher_DropTooOld(t *testing.T) {
 	}
 
 	// Проверяем статистику
-	received, dropped, _, _ := batcher.GetStats()
-	if received != 2 {
-		t.Errorf("Expected 2 received samples, got %d", received)
+	stats := batcher.GetStats()
+	if stats.Received != 2 {
+		t.Errorf("Expected 2 received samples, got %d", stats.Received)
 	}
-	if dropped != 1 {
-		t.Errorf("Expected 1 dropped sample, got %d", dropped)
+	if stats.Dropped != 1 {
+		t.Errorf("Expected 1 dropped sample, got %d", stats.Dropped)
 	}
 }
 
@@ -277,3 +280,101 @@ func TestBatcher_MultipleMetrics(t *testing.T) {
 		t.Errorf("Expected 2 batches (one per metric), got %d", len(batches))
 	}
 }
+
+func TestBatcher_Metrics(t *testing.T) {
+	cfg := &config.Config{
+		BatchMaxSamples:     2,
+		BatchMaxSpanMS:      30000,
+		FlushIntervalMS:     500,
+		AckEveryN:           50,
+		OutOfOrderTolerance: 500 * time.Millisecond,
+		DropTooOldMS:        2000,
+	}
+
+	sink := &TestSink{}
+	batcher := NewBatcher(cfg, sink)
+	defer batcher.Stop()
+
+	m := metrics.NewReceiver()
+	batcher.SetMetrics(m)
+
+	samples := []*telemetryv1.Sample{
+		{SessionId: "session1", TsMs: 5000, Metric: telemetryv1.Metric_METRIC_FHR, Value: 120.0},
+		{SessionId: "session1", TsMs: 4000, Metric: telemetryv1.Metric_METRIC_FHR, Value: 121.0}, // Не по порядку, флаш
+		{SessionId: "session1", TsMs: 6000, Metric: telemetryv1.Metric_METRIC_UC, Value: 10.0},
+		{SessionId: "session1", TsMs: 1000, Metric: telemetryv1.Metric_METRIC_UC, Value: 11.0}, // Слишком старый
+		{SessionId: "", TsMs: 7000, Metric: telemetryv1.Metric_METRIC_FHR, Value: 122.0},       // Невалидный
+	}
+	for _, sample := range samples {
+		if err := batcher.Add(sample); err != nil {
+			t.Fatalf("Failed to add sample: %v", err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, line := range []string{
+		`receiver_samples_received_total{metric="fhr"} 2`,
+		`receiver_samples_received_total{metric="uc"} 1`,
+		`receiver_samples_out_of_order_total{metric="fhr"} 1`,
+		`receiver_samples_flushed_total{metric="fhr"} 2`,
+		`receiver_samples_dropped_total{metric="fhr",reason="invalid"} 1`,
+		`receiver_samples_dropped_total{metric="uc",reason="too_old"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Expected %q in metrics output:\n%s", line, body)
+		}
+	}
+}
+
+func TestBatcher_UpdateConfig(t *testing.T) {
+	cfg := &config.Config{
+		BatchMaxSamples: 10,
+		BatchMaxSpanMS:  30000,
+		FlushIntervalMS: 10000,
+		AckEveryN:       50,
+		DropTooOldMS:    30000,
+	}
+
+	sink := &TestSink{}
+	batcher := NewBatcher(cfg, sink)
+	defer batcher.Stop()
+
+	add := func(ts uint64) {
+		sample := &telemetryv1.Sample{SessionId: "reload", TsMs: ts, Metric: telemetryv1.Metric_METRIC_FHR, Value: 130}
+		if err := batcher.Add(sample); err != nil {
+			t.Fatalf("Failed to add sample: %v", err)
+		}
+	}
+
+	add(1000)
+	add(1250)
+
+	// Новый размер батча действует с ближайшего сэмпла, накопленные точки сохраняются
+	updated := *cfg
+	updated.BatchMaxSamples = 3
+	batcher.UpdateConfig(&updated)
+	add(1500)
+
+	time.Sleep(100 * time.Millisecond)
+	batches := sink.GetBatches()
+	if len(batches) != 1 || len(batches[0].Points) != 3 {
+		t.Fatalf("Expected one batch of 3 points after reload, got %+v", batches)
+	}
+
+	// Интервал сброса применяется к таймеру сразу, не дожидаясь прежнего интервала
+	faster := updated
+	faster.FlushIntervalMS = 50
+	batcher.UpdateConfig(&faster)
+	add(1750)
+
+	deadline := time.Now().Add(time.Second)
+	for len(sink.GetBatches()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if got := len(sink.GetBatches()); got != 2 {
+		t.Errorf("Expected idle batch to be flushed after interval change, got %d batches", got)
+	}
+}
@@ -2,14 +2,23 @@ package batch
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 
+	"github.com/Krimson/fetal-monitory/logging"
 	featureextractorv1 "github.com/Krimson/fetal-monitory/proto/feature_extractor"
 	telemetryv1 "github.com/Krimson/fetal-monitory/proto/telemetry"
+	"github.com/Krimson/fetal-monitory/receiver/internal/config"
+	"github.com/Krimson/fetal-monitory/receiver/internal/health"
+	"github.com/Krimson/fetal-monitory/receiver/internal/metrics"
+	"github.com/Krimson/fetal-monitory/receiver/internal/tracing"
 )
 
 // SessionManager интерфейс для управления сессиями
@@ -23,8 +32,24 @@ type FeatureExtractorSink struct {
 	conn           *grpc.ClientConn
 	sessionManager SessionManager
 
+	// Потоковый режим: по одному ProcessBatchStream на сессию
+	streaming bool
+	streamsMu sync.Mutex
+	streams   map[string]*sessionStream
+	stopChan  chan struct{}
+
 	// Канал для передачи обработанных данных дальше (например, для WebSocket)
-	processedBatchChan chan *featureextractorv1.ProcessBatchResponse
+	processedBatchChan chan ProcessedBatch
+
+	// Метрики Prometheus (необязательны)
+	metrics *metrics.Receiver
+}
+
+// ProcessedBatch - признаки батча от feature extractor и спан его обработки,
+// от которого трасса продолжается в WebSocket и ML сервисе
+type ProcessedBatch struct {
+	Features *featureextractorv1.ProcessBatchResponse
+	Trace    trace.SpanContext
 }
 
 // NewFeatureExtractorSink создает новый экземпляр FeatureExtractorSink (без session manager)
@@ -32,58 +57,94 @@ func NewFeatureExtractorSink(featureExtractorAddr string) (*FeatureExtractorSink
 	return NewFeatureExtractorSinkWithSession(featureExtractorAddr, nil)
 }
 
-// NewFeatureExtractorSinkWithSession создает новый экземпляр с session manager
+// NewFeatureExtractorSinkWithSession создает новый экземпляр с session manager (unary ProcessBatch)
 func NewFeatureExtractorSinkWithSession(featureExtractorAddr string, sessionManager SessionManager) (*FeatureExtractorSink, error) {
+	return NewFeatureExtractorSinkWithMode(featureExtractorAddr, sessionManager, config.FeatureExtractorModeUnary)
+}
+
+// NewFeatureExtractorSinkWithMode создает экземпляр с указанным режимом вызова feature extractor:
+// config.FeatureExtractorModeUnary - ProcessBatch на каждый батч,
+// config.FeatureExtractorModeStream - долгоживущий ProcessBatchStream на каждую сессию
+func NewFeatureExtractorSinkWithMode(featureExtractorAddr string, sessionManager SessionManager, mode string) (*FeatureExtractorSink, error) {
+	if mode != config.FeatureExtractorModeUnary && mode != config.FeatureExtractorModeStream {
+		return nil, fmt.Errorf("unknown feature extractor mode: %q", mode)
+	}
+
 	// Подключаемся к Python gRPC сервису
-	conn, err := grpc.Dial(featureExtractorAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.Dial(featureExtractorAddr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		tracing.ClientHandler(),
+	)
 	if err != nil {
 		return nil, err
 	}
 
 	client := featureextractorv1.NewFeatureExtractorServiceClient(conn)
 
-	return &FeatureExtractorSink{
+	fs := &FeatureExtractorSink{
 		client:             client,
 		conn:               conn,
 		sessionManager:     sessionManager,
-		processedBatchChan: make(chan *featureextractorv1.ProcessBatchResponse, 100),
-	}, nil
+		streaming:          mode == config.FeatureExtractorModeStream,
+		streams:            make(map[string]*sessionStream),
+		stopChan:           make(chan struct{}),
+		processedBatchChan: make(chan ProcessedBatch, 100),
+	}
+
+	if fs.streaming {
+		go fs.streamJanitor()
+	}
+
+	return fs, nil
+}
+
+// SetMetrics подключает метрики Prometheus (длительность вызовов feature extractor)
+func (fs *FeatureExtractorSink) SetMetrics(m *metrics.Receiver) {
+	fs.metrics = m
 }
 
 // Consume реализует интерфейс Sink
-func (fs *FeatureExtractorSink) Consume(ctx context.Context, b Batch) error {
-	log.Printf("[FEATURE_EXTRACTOR] Processing batch: session=%s metric=%s points=%d",
-		b.Key.SessionID, b.Key.Metric.String(), len(b.Points))
+func (fs *FeatureExtractorSink) Consume(ctx context.Context, b Batch) (err error) {
+	ctx, span := tracer.Start(ctx, "FeatureExtractorSink.Consume", trace.WithAttributes(batchAttributes(b)...))
+	defer func() { endSpan(span, err) }()
+
+	slog.DebugContext(ctx, "Processing batch", logging.SessionID(b.Key.SessionID), logging.Metric(b.Key.Metric), "points", len(b.Points))
 
 	// Конвертируем batch в gRPC request
 	request, err := fs.convertBatchToRequest(b)
 	if err != nil {
-		log.Printf("[ERROR] Failed to convert batch to request: %v", err)
+		slog.ErrorContext(ctx, "Failed to convert batch to request", logging.SessionID(b.Key.SessionID), logging.Err(err))
 		return err
 	}
 
 	// Отправляем в Python сервис
-	response, err := fs.client.ProcessBatch(ctx, request)
+	var response *featureextractorv1.ProcessBatchResponse
+	start := time.Now()
+	if fs.streaming {
+		response, err = fs.processStream(ctx, request)
+	} else {
+		response, err = fs.client.ProcessBatch(ctx, request)
+	}
+	fs.metrics.ObserveFeatureExtractor(time.Since(start), err)
 	if err != nil {
-		log.Printf("[ERROR] Failed to process batch in feature extractor: %v", err)
+		slog.ErrorContext(ctx, "Failed to process batch in feature extractor", logging.SessionID(b.Key.SessionID), logging.BatchTS(request.BatchTsMs), logging.Err(err))
 		return err
 	}
 
 	// Сохраняем в Session Manager (если доступен)
 	if fs.sessionManager != nil {
 		if err := fs.sessionManager.ProcessFeatureBatch(ctx, response); err != nil {
-			log.Printf("[ERROR] Failed to process feature batch in session manager: %v", err)
+			slog.ErrorContext(ctx, "Failed to process feature batch in session manager", logging.SessionID(response.SessionId), logging.Err(err))
 			// Не возвращаем ошибку, продолжаем обработку
 		}
 	}
 
 	// Отправляем обработанные данные в канал для дальнейшей обработки (WebSocket)
 	select {
-	case fs.processedBatchChan <- response:
-		log.Printf("[FEATURE_EXTRACTOR] Processed batch successfully: session=%s stv=%.2f ltv=%.2f baseline=%.1f",
-			response.SessionId, response.Stv, response.Ltv, response.BaselineHeartRate)
+	case fs.processedBatchChan <- ProcessedBatch{Features: response, Trace: span.SpanContext()}:
+		slog.DebugContext(ctx, "Processed batch", logging.SessionID(response.SessionId), logging.BatchTS(response.BatchTsMs), "stv", response.Stv, "ltv", response.Ltv, "baseline", response.BaselineHeartRate)
 	default:
-		log.Printf("[WARN] Processed batch channel full, dropping batch")
+		slog.WarnContext(ctx, "Processed batch channel full, dropping batch", logging.SessionID(response.SessionId))
 	}
 
 	return nil
@@ -105,7 +166,13 @@ func (fs *FeatureExtractorSink) convertBatchToRequest(b Batch) (*featureextracto
 			Value:   float64(point.Value),
 		}
 
-		switch b.Key.Metric {
+		// В совместном батче канал задан у точки, в раздельном - ключом батча
+		metric := point.Metric
+		if metric == telemetryv1.Metric_METRIC_UNSPECIFIED {
+			metric = b.Key.Metric
+		}
+
+		switch metric {
 		case telemetryv1.Metric_METRIC_FHR:
 			request.BpmData = append(request.BpmData, dataPoint)
 		case telemetryv1.Metric_METRIC_UC:
@@ -117,12 +184,19 @@ func (fs *FeatureExtractorSink) convertBatchToRequest(b Batch) (*featureextracto
 }
 
 // GetProcessedBatchChannel возвращает канал с обработанными данными
-func (fs *FeatureExtractorSink) GetProcessedBatchChannel() <-chan *featureextractorv1.ProcessBatchResponse {
+func (fs *FeatureExtractorSink) GetProcessedBatchChannel() <-chan ProcessedBatch {
 	return fs.processedBatchChan
 }
 
-// Close закрывает соединение
+// CheckHealth проверяет feature extractor через grpc.health.v1
+func (fs *FeatureExtractorSink) CheckHealth(ctx context.Context) error {
+	return health.CheckGRPC(ctx, fs.conn, "")
+}
+
+// Close закрывает потоки и соединение
 func (fs *FeatureExtractorSink) Close() error {
+	close(fs.stopChan)
+	fs.closeStreams()
 	close(fs.processedBatchChan)
 	return fs.conn.Close()
 }
@@ -139,13 +213,16 @@ func NewCompositeSink(sinks ...Sink) *CompositeSink {
 	}
 }
 
-// Consume отправляет batch во все подключенные sink'и
+// Consume отправляет batch во все подключенные sink'и.
+// Ошибка одного sink не прерывает остальные, но возвращается вызывающему,
+// чтобы очередь Batcher могла повторить доставку.
 func (cs *CompositeSink) Consume(ctx context.Context, b Batch) error {
+	var errs []error
 	for _, sink := range cs.sinks {
 		if err := sink.Consume(ctx, b); err != nil {
-			log.Printf("[ERROR] Sink failed to consume batch: %v", err)
-			// Не возвращаем ошибку, продолжаем обработку в других sink'ах
+			slog.ErrorContext(ctx, "Sink failed to consume batch", logging.SessionID(b.Key.SessionID), logging.Err(err))
+			errs = append(errs, err)
 		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
@@ -0,0 +1,284 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Krimson/fetal-monitory/logging"
+	featureextractorv1 "github.com/Krimson/fetal-monitory/proto/feature_extractor"
+	"github.com/Krimson/fetal-monitory/receiver/internal/tracing"
+)
+
+// streamIdleTimeout - поток сессии закрывается, если по нему ничего не отправлялось это время
+const streamIdleTimeout = 2 * time.Minute
+
+// errStreamClosed возвращается ожидающим запросам, если поток оборвался до ответа
+var errStreamClosed = errors.New("feature extractor stream closed")
+
+// errBatchFailed - feature extractor не смог обработать батч и вернул ошибку в ответе
+var errBatchFailed = errors.New("feature extractor failed to process batch")
+
+// streamResult - ответ (или ошибка) на конкретный запрос в потоке
+type streamResult struct {
+	response *featureextractorv1.ProcessBatchResponse
+	err      error
+}
+
+// pendingRequest - запрос, ожидающий ответа в потоке
+type pendingRequest struct {
+	batchTsMS uint64
+	result    chan streamResult
+	abandoned bool // Отправитель перестал ждать (таймаут); ответ будет отброшен
+}
+
+// sessionStream - долгоживущий ProcessBatchStream одной сессии.
+// Feature extractor отвечает на каждый запрос ровно одним ответом в порядке
+// запросов (при ошибке - ответом с полем error), поэтому ответы сопоставляются
+// с запросами по очереди, а batch_ts_ms только сверяется.
+type sessionStream struct {
+	sessionID string
+	stream    featureextractorv1.FeatureExtractorService_ProcessBatchStreamClient
+	cancel    context.CancelFunc
+
+	// gRPC не допускает конкурентный Send в один поток; sendMu также
+	// гарантирует, что порядок pending совпадает с порядком отправки
+	sendMu sync.Mutex
+
+	mu       sync.Mutex
+	pending  []*pendingRequest
+	lastUsed time.Time
+	closed   bool
+}
+
+// openSessionStream открывает поток и запускает чтение ответов
+func openSessionStream(client featureextractorv1.FeatureExtractorServiceClient, sessionID string, onClose func(*sessionStream)) (*sessionStream, error) {
+	// Поток живет дольше отдельного Consume, поэтому у него свой контекст
+	ctx, cancel := context.WithCancel(context.Background())
+
+	stream, err := client.ProcessBatchStream(ctx)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to open feature extractor stream: %w", err)
+	}
+
+	ss := &sessionStream{
+		sessionID: sessionID,
+		stream:    stream,
+		cancel:    cancel,
+		lastUsed:  time.Now(),
+	}
+
+	go ss.recvLoop(onClose)
+
+	slog.Info("Opened batch stream", logging.SessionID(sessionID))
+	return ss, nil
+}
+
+// send отправляет запрос в поток и ждет ответ на него. Запрос не изменяется
+func (ss *sessionStream) send(ctx context.Context, request *featureextractorv1.ProcessBatchRequest) (*featureextractorv1.ProcessBatchResponse, error) {
+	p := &pendingRequest{
+		batchTsMS: request.BatchTsMs,
+		result:    make(chan streamResult, 1),
+	}
+
+	ss.sendMu.Lock()
+	ss.mu.Lock()
+	if ss.closed {
+		ss.mu.Unlock()
+		ss.sendMu.Unlock()
+		return nil, errStreamClosed
+	}
+	ss.lastUsed = time.Now()
+	ss.pending = append(ss.pending, p)
+	ss.mu.Unlock()
+
+	err := ss.stream.Send(request)
+	ss.sendMu.Unlock()
+
+	if err != nil {
+		ss.abandon(p)
+		ss.close()
+		return nil, fmt.Errorf("failed to send batch to stream: %w", err)
+	}
+
+	select {
+	case result := <-p.result:
+		return result.response, result.err
+	case <-ctx.Done():
+		ss.abandon(p)
+		return nil, ctx.Err()
+	}
+}
+
+// abandon помечает запрос как неактуальный (например, после таймаута). Запрос
+// остается в очереди, чтобы следующие ответы сопоставились со своими запросами
+func (ss *sessionStream) abandon(p *pendingRequest) {
+	ss.mu.Lock()
+	p.abandoned = true
+	ss.mu.Unlock()
+}
+
+// recvLoop читает ответы и передает их ожидающим запросам
+func (ss *sessionStream) recvLoop(onClose func(*sessionStream)) {
+	var recvErr error
+	for {
+		response, err := ss.stream.Recv()
+		if err != nil {
+			recvErr = err
+			break
+		}
+
+		ss.mu.Lock()
+		var p *pendingRequest
+		abandoned := false
+		if len(ss.pending) > 0 {
+			p = ss.pending[0]
+			ss.pending = ss.pending[1:]
+			abandoned = p.abandoned
+		}
+		ss.mu.Unlock()
+
+		if p == nil {
+			slog.Warn("Unmatched stream response", logging.SessionID(ss.sessionID), logging.BatchTS(response.BatchTsMs))
+			continue
+		}
+		if abandoned {
+			continue
+		}
+		p.result <- ss.result(p, response)
+	}
+
+	if recvErr != io.EOF && !errors.Is(recvErr, context.Canceled) {
+		slog.Warn("Feature extractor stream closed", logging.SessionID(ss.sessionID), logging.Err(recvErr))
+	}
+
+	// Все ожидающие запросы получают ошибку и будут повторены очередью Batcher
+	ss.mu.Lock()
+	ss.closed = true
+	for _, p := range ss.pending {
+		if !p.abandoned {
+			p.result <- streamResult{err: fmt.Errorf("%w: %v", errStreamClosed, recvErr)}
+		}
+	}
+	ss.pending = nil
+	ss.mu.Unlock()
+
+	ss.cancel()
+	if onClose != nil {
+		onClose(ss)
+	}
+}
+
+// result превращает ответ потока в результат запроса p: ответ с ошибкой или
+// ответ на другой батч становится ошибкой, и Batcher повторит доставку
+func (ss *sessionStream) result(p *pendingRequest, response *featureextractorv1.ProcessBatchResponse) streamResult {
+	if response.Error != "" {
+		return streamResult{err: fmt.Errorf("%w: %s", errBatchFailed, response.Error)}
+	}
+	if response.BatchTsMs != p.batchTsMS {
+		slog.Warn("Stream response for unexpected batch", logging.SessionID(ss.sessionID), logging.BatchTS(response.BatchTsMs), "expected_batch_ts_ms", p.batchTsMS)
+		return streamResult{err: fmt.Errorf("stream response batch_ts_ms %d does not match request %d", response.BatchTsMs, p.batchTsMS)}
+	}
+	return streamResult{response: response}
+}
+
+// idleSince возвращает время последней отправки
+func (ss *sessionStream) idleSince() time.Time {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.lastUsed
+}
+
+// close закрывает поток; recvLoop завершится и уведомит ожидающих
+func (ss *sessionStream) close() {
+	ss.sendMu.Lock()
+	ss.stream.CloseSend()
+	ss.sendMu.Unlock()
+	ss.cancel()
+}
+
+// processStream отправляет запрос через поток сессии, открывая его при необходимости.
+// Оборванный поток удаляется из карты и переоткрывается при следующем батче.
+// Поток открыт вне трассы батча, поэтому контекст трассировки ctx передается
+// в самом запросе (trace_context), а не в gRPC metadata.
+func (fs *FeatureExtractorSink) processStream(ctx context.Context, request *featureextractorv1.ProcessBatchRequest) (*featureextractorv1.ProcessBatchResponse, error) {
+	ss, err := fs.getOrOpenStream(request.SessionId)
+	if err != nil {
+		return nil, err
+	}
+	request.TraceContext = tracing.Inject(ctx)
+	return ss.send(ctx, request)
+}
+
+func (fs *FeatureExtractorSink) getOrOpenStream(sessionID string) (*sessionStream, error) {
+	fs.streamsMu.Lock()
+	defer fs.streamsMu.Unlock()
+
+	if ss, ok := fs.streams[sessionID]; ok {
+		return ss, nil
+	}
+
+	ss, err := openSessionStream(fs.client, sessionID, fs.removeStream)
+	if err != nil {
+		return nil, err
+	}
+	fs.streams[sessionID] = ss
+	return ss, nil
+}
+
+// removeStream удаляет закрытый поток из карты
+func (fs *FeatureExtractorSink) removeStream(ss *sessionStream) {
+	fs.streamsMu.Lock()
+	defer fs.streamsMu.Unlock()
+
+	if current, ok := fs.streams[ss.sessionID]; ok && current == ss {
+		delete(fs.streams, ss.sessionID)
+	}
+}
+
+// streamJanitor закрывает потоки сессий, по которым давно не было данных
+func (fs *FeatureExtractorSink) streamJanitor() {
+	ticker := time.NewTicker(streamIdleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			fs.streamsMu.Lock()
+			var idle []*sessionStream
+			for _, ss := range fs.streams {
+				if time.Since(ss.idleSince()) > streamIdleTimeout {
+					idle = append(idle, ss)
+				}
+			}
+			fs.streamsMu.Unlock()
+
+			for _, ss := range idle {
+				slog.Info("Closing idle batch stream", logging.SessionID(ss.sessionID))
+				ss.close()
+			}
+
+		case <-fs.stopChan:
+			return
+		}
+	}
+}
+
+// closeStreams закрывает все открытые потоки
+func (fs *FeatureExtractorSink) closeStreams() {
+	fs.streamsMu.Lock()
+	streams := make([]*sessionStream, 0, len(fs.streams))
+	for _, ss := range fs.streams {
+		streams = append(streams, ss)
+	}
+	fs.streamsMu.Unlock()
+
+	for _, ss := range streams {
+		ss.close()
+	}
+}
@@ -0,0 +1,173 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	featureextractorv1 "github.com/Krimson/fetal-monitory/proto/feature_extractor"
+)
+
+// fakeBatchStream - ProcessBatchStream в памяти: на каждый запрос отвечает reply
+type fakeBatchStream struct {
+	grpc.ClientStream
+	requests  chan *featureextractorv1.ProcessBatchRequest
+	responses chan *featureextractorv1.ProcessBatchResponse
+	closed    chan struct{}
+}
+
+func newFakeBatchStream(reply func(*featureextractorv1.ProcessBatchRequest) *featureextractorv1.ProcessBatchResponse) *fakeBatchStream {
+	fs := &fakeBatchStream{
+		requests:  make(chan *featureextractorv1.ProcessBatchRequest, 16),
+		responses: make(chan *featureextractorv1.ProcessBatchResponse, 16),
+		closed:    make(chan struct{}),
+	}
+	go func() {
+		for request := range fs.requests {
+			fs.responses <- reply(request)
+		}
+		close(fs.responses)
+	}()
+	return fs
+}
+
+func (fs *fakeBatchStream) Send(request *featureextractorv1.ProcessBatchRequest) error {
+	fs.requests <- request
+	return nil
+}
+
+func (fs *fakeBatchStream) Recv() (*featureextractorv1.ProcessBatchResponse, error) {
+	response, ok := <-fs.responses
+	if !ok {
+		return nil, io.EOF
+	}
+	return response, nil
+}
+
+func (fs *fakeBatchStream) CloseSend() error {
+	select {
+	case <-fs.closed:
+	default:
+		close(fs.closed)
+		close(fs.requests)
+	}
+	return nil
+}
+
+func newTestSessionStream(reply func(*featureextractorv1.ProcessBatchRequest) *featureextractorv1.ProcessBatchResponse) *sessionStream {
+	_, cancel := context.WithCancel(context.Background())
+	ss := &sessionStream{
+		sessionID: "session1",
+		stream:    newFakeBatchStream(reply),
+		cancel:    cancel,
+		lastUsed:  time.Now(),
+	}
+	go ss.recvLoop(nil)
+	return ss
+}
+
+func TestSessionStream_ErrorResponseFailsFast(t *testing.T) {
+	ss := newTestSessionStream(func(r *featureextractorv1.ProcessBatchRequest) *featureextractorv1.ProcessBatchResponse {
+		return &featureextractorv1.ProcessBatchResponse{SessionId: r.SessionId, BatchTsMs: r.BatchTsMs, Error: "preprocessor failed"}
+	})
+	defer ss.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), sinkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	_, err := ss.send(ctx, &featureextractorv1.ProcessBatchRequest{SessionId: "session1", BatchTsMs: 1000})
+	if !errors.Is(err, errBatchFailed) {
+		t.Fatalf("Expected errBatchFailed, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Error response should fail fast, waited %v", elapsed)
+	}
+}
+
+func TestSessionStream_KeepsRequestTimestamp(t *testing.T) {
+	ss := newTestSessionStream(func(r *featureextractorv1.ProcessBatchRequest) *featureextractorv1.ProcessBatchResponse {
+		return &featureextractorv1.ProcessBatchResponse{SessionId: r.SessionId, BatchTsMs: r.BatchTsMs, Stv: float64(len(r.BpmData))}
+	})
+	defer ss.close()
+
+	ctx := context.Background()
+	for i := 1; i <= 2; i++ {
+		// Одинаковый batch_ts_ms у соседних батчей не должен переписываться
+		request := &featureextractorv1.ProcessBatchRequest{
+			SessionId: "session1",
+			BatchTsMs: 1000,
+			BpmData:   make([]*featureextractorv1.DataPoint, i),
+		}
+		response, err := ss.send(ctx, request)
+		if err != nil {
+			t.Fatalf("Batch %d: unexpected error: %v", i, err)
+		}
+		if request.BatchTsMs != 1000 {
+			t.Errorf("Batch %d: request batch_ts_ms rewritten to %d", i, request.BatchTsMs)
+		}
+		if response.Stv != float64(i) {
+			t.Errorf("Batch %d: got response for another request (stv=%v)", i, response.Stv)
+		}
+	}
+}
+
+func TestSessionStream_AbandonedResponseDoesNotShiftOthers(t *testing.T) {
+	release := make(chan struct{})
+	ss := newTestSessionStream(func(r *featureextractorv1.ProcessBatchRequest) *featureextractorv1.ProcessBatchResponse {
+		if r.BatchTsMs == 1000 {
+			<-release
+		}
+		return &featureextractorv1.ProcessBatchResponse{SessionId: r.SessionId, BatchTsMs: r.BatchTsMs}
+	})
+	defer ss.close()
+
+	// Первый запрос отваливается по таймауту
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	_, err := ss.send(ctx, &featureextractorv1.ProcessBatchRequest{SessionId: "session1", BatchTsMs: 1000})
+	cancel()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline exceeded, got %v", err)
+	}
+	close(release)
+
+	// Запоздавший ответ на первый запрос не достается второму
+	response, err := ss.send(context.Background(), &featureextractorv1.ProcessBatchRequest{SessionId: "session1", BatchTsMs: 2000})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.BatchTsMs != 2000 {
+		t.Errorf("Expected response for batch 2000, got %d", response.BatchTsMs)
+	}
+}
+
+func TestProcessStream_PropagatesBatchTraceContext(t *testing.T) {
+	recordSpans()
+
+	traceparents := make(chan string, 2)
+	ss := newTestSessionStream(func(r *featureextractorv1.ProcessBatchRequest) *featureextractorv1.ProcessBatchResponse {
+		traceparents <- r.TraceContext["traceparent"]
+		return &featureextractorv1.ProcessBatchResponse{SessionId: r.SessionId, BatchTsMs: r.BatchTsMs}
+	})
+	defer ss.close()
+	fs := &FeatureExtractorSink{streams: map[string]*sessionStream{"session1": ss}}
+
+	// Два батча одного потока - две разные трассы
+	for _, ts := range []uint64{1000, 2000} {
+		ctx, span := tracer.Start(context.Background(), "test.batch")
+		if _, err := fs.processStream(ctx, &featureextractorv1.ProcessBatchRequest{SessionId: "session1", BatchTsMs: ts}); err != nil {
+			t.Fatalf("Batch %d: unexpected error: %v", ts, err)
+		}
+		span.End()
+
+		traceID := span.SpanContext().TraceID().String()
+		if got := <-traceparents; !strings.Contains(got, traceID) {
+			t.Errorf("Batch %d: expected traceparent with trace %s, got %q", ts, traceID, got)
+		}
+	}
+}
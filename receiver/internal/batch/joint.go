@@ -0,0 +1,245 @@
+package batch
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/Krimson/fetal-monitory/logging"
+	telemetryv1 "github.com/Krimson/fetal-monitory/proto/telemetry"
+	"github.com/Krimson/fetal-monitory/receiver/internal/metrics"
+)
+
+// Совместный батчинг (BATCH_MODE=joint).
+// Точки FHR и UC одной сессии собираются в общее окно [start, start+BatchMaxSpanMS).
+// Окно отправляется одним батчем, когда оба канала прислали точки за его концом.
+// Если один канал отстает дольше JointMaxWait, окно отправляется без его точек,
+// и пока канал не догонит, следующие окна тоже не ждут его.
+
+// jointChannels - каналы, которые должны присутствовать в совместном окне
+var jointChannels = []telemetryv1.Metric{
+	telemetryv1.Metric_METRIC_FHR,
+	telemetryv1.Metric_METRIC_UC,
+}
+
+// jointBatch - текущее окно сессии в режиме joint
+type jointBatch struct {
+	key         BatchKey
+	windowStart int64                        // Начало текущего окна (мс)
+	points      []Point                      // Точки текущего окна
+	ahead       []Point                      // Точки, пришедшие за концом окна
+	latest      map[telemetryv1.Metric]int64 // Последняя метка времени по каналу
+	lagSince    time.Time                    // Когда один из каналов ушел за конец окна, а другой нет
+	lagging     bool                         // Ожидание истекло, окна отправляются без отстающего канала
+	lastAdded   time.Time                    // Время последнего добавления (по часам сервера)
+	sources     batchSources                 // Для корневого спана следующего отправленного окна
+}
+
+func newJointBatch(sessionID string, startMS int64) *jointBatch {
+	return &jointBatch{
+		key:         BatchKey{SessionID: sessionID, Metric: telemetryv1.Metric_METRIC_UNSPECIFIED},
+		windowStart: startMS,
+		latest:      make(map[telemetryv1.Metric]int64),
+	}
+}
+
+// add раскладывает точку в текущее окно или в очередь следующих окон
+func (jb *jointBatch) add(point Point, spanMS int64, now time.Time) {
+	if point.TsMS < jb.windowStart+spanMS {
+		// Точки раньше начала окна (окно уже отправлено) прикладываем к текущему
+		jb.points = append(jb.points, point)
+	} else {
+		jb.ahead = append(jb.ahead, point)
+	}
+
+	if latest, ok := jb.latest[point.Metric]; !ok || point.TsMS > latest {
+		jb.latest[point.Metric] = point.TsMS
+	}
+	jb.lastAdded = now
+}
+
+// passed сообщает, сколько каналов прислали точки не раньше конца окна
+func (jb *jointBatch) passed(endMS int64) (all, any bool) {
+	all = true
+	for _, metric := range jointChannels {
+		if latest, ok := jb.latest[metric]; ok && latest >= endMS {
+			any = true
+		} else {
+			all = false
+		}
+	}
+	return all, any
+}
+
+// empty - в окне и очереди нет точек
+func (jb *jointBatch) empty() bool {
+	return len(jb.points) == 0 && len(jb.ahead) == 0
+}
+
+// take забирает точки текущего окна и сдвигает окно вперед.
+// Возвращает false, если окно было пустым.
+func (jb *jointBatch) take(spanMS int64) (Batch, bool) {
+	points := jb.points
+	jb.points = nil
+
+	// Следующее окно начинается сразу за текущим; через пропуск в данных перескакиваем
+	jb.windowStart += spanMS
+	if len(jb.ahead) > 0 {
+		minTs := jb.ahead[0].TsMS
+		for _, p := range jb.ahead[1:] {
+			if p.TsMS < minTs {
+				minTs = p.TsMS
+			}
+		}
+		if minTs >= jb.windowStart+spanMS {
+			jb.windowStart = minTs
+		}
+	}
+
+	end := jb.windowStart + spanMS
+	remaining := jb.ahead[:0]
+	for _, p := range jb.ahead {
+		if p.TsMS < end {
+			jb.points = append(jb.points, p)
+		} else {
+			remaining = append(remaining, p)
+		}
+	}
+	jb.ahead = remaining
+
+	if len(points) == 0 {
+		return Batch{}, false
+	}
+
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].TsMS < points[j].TsMS
+	})
+
+	return Batch{
+		Key:    jb.key,
+		T0MS:   points[0].TsMS,
+		T1MS:   points[len(points)-1].TsMS,
+		Points: points,
+	}, true
+}
+
+// jointSpanMS - длина совместного окна (BatchMaxSpanMS, но не меньше 1мс)
+func (b *Batcher) jointSpanMS() int64 {
+	spanMS := b.settings().BatchMaxSpanMS
+	if spanMS < 1 {
+		return 1
+	}
+	return spanMS
+}
+
+// addJoint добавляет сэмпл в совместное окно сессии
+func (b *Batcher) addJoint(ctx context.Context, sample *telemetryv1.Sample) error {
+	now := time.Now()
+	point := Point{
+		TsMS:   int64(sample.TsMs),
+		Value:  sample.Value,
+		Metric: sample.Metric,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	jb, exists := b.joint[sample.SessionId]
+	if !exists {
+		jb = newJointBatch(sample.SessionId, point.TsMS)
+		b.joint[sample.SessionId] = jb
+	}
+
+	if latest, ok := jb.latest[point.Metric]; ok {
+		timeDiff := latest - point.TsMS
+		cfg := b.settings()
+
+		if timeDiff > cfg.DropTooOldMS {
+			b.incrementDropped()
+			b.metrics.SamplesDropped(point.Metric, metrics.DropTooOld, 1)
+			slog.Warn("Sample too old, dropped", logging.SessionID(sample.SessionId), logging.Metric(sample.Metric), "ts_diff", timeDiff)
+			return nil
+		}
+
+		if timeDiff > int64(cfg.OutOfOrderTolerance.Milliseconds()) {
+			b.incrementOutOfOrder()
+			b.metrics.SampleOutOfOrder(point.Metric)
+			slog.Warn("Out of order sample", logging.SessionID(sample.SessionId), logging.Metric(sample.Metric), "ts_diff", timeDiff)
+		}
+	}
+
+	jb.add(point, b.jointSpanMS(), now)
+	jb.sources.observe(ctx, now)
+	b.incrementReceived()
+	b.metrics.SampleReceived(point.Metric)
+
+	b.flushReadyJoint(jb, now)
+	return nil
+}
+
+// flushReadyJoint отправляет все окна сессии, которые больше не нужно ждать
+func (b *Batcher) flushReadyJoint(jb *jointBatch, now time.Time) {
+	spanMS := b.jointSpanMS()
+	maxWait := b.settings().JointMaxWait
+
+	for {
+		all, any := jb.passed(jb.windowStart + spanMS)
+		switch {
+		case all:
+			jb.lagSince = time.Time{}
+			jb.lagging = false
+		case any:
+			if jb.lagSince.IsZero() {
+				jb.lagSince = now
+			}
+			if !jb.lagging && now.Sub(jb.lagSince) < maxWait {
+				return
+			}
+			// Отстающий канал не догнал - отправляем окно без него
+			// и не ждем его на следующих окнах, пока не догонит
+			jb.lagging = true
+		default:
+			if !jb.lagging {
+				jb.lagSince = time.Time{}
+			}
+			return
+		}
+
+		if batch, ok := jb.take(spanMS); ok {
+			b.emit(batch, jb.sources.take())
+		}
+	}
+}
+
+// flushJointBatches проверяет таймауты ожидания и отправляет окна замолчавших сессий
+func (b *Batcher) flushJointBatches(now time.Time) {
+	cfg := b.settings()
+	idleTimeout := time.Duration(cfg.FlushIntervalMS) * time.Millisecond
+	if cfg.JointMaxWait > idleTimeout {
+		idleTimeout = cfg.JointMaxWait
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sessionID, jb := range b.joint {
+		if now.Sub(jb.lastAdded) > idleTimeout {
+			b.drainJoint(jb)
+			delete(b.joint, sessionID)
+			continue
+		}
+		b.flushReadyJoint(jb, now)
+	}
+}
+
+// drainJoint отправляет все накопленные окна сессии, не дожидаясь каналов
+func (b *Batcher) drainJoint(jb *jointBatch) {
+	for !jb.empty() {
+		if batch, ok := jb.take(b.jointSpanMS()); ok {
+			b.emit(batch, jb.sources.take())
+		}
+	}
+	jb.lagSince = time.Time{}
+	jb.lagging = false
+}
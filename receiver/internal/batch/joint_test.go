@@ -0,0 +1,119 @@
+package batch
+
+import (
+	"testing"
+	"time"
+
+	telemetryv1 "github.com/Krimson/fetal-monitory/proto/telemetry"
+	"github.com/Krimson/fetal-monitory/receiver/internal/config"
+)
+
+func jointSample(metric telemetryv1.Metric, tsMS uint64) *telemetryv1.Sample {
+	return &telemetryv1.Sample{SessionId: "session1", TsMs: tsMS, Metric: metric, Value: 100}
+}
+
+// channelCounts считает точки батча по каналам
+func channelCounts(b Batch) (fhr, uc int) {
+	for _, p := range b.Points {
+		switch p.Metric {
+		case telemetryv1.Metric_METRIC_FHR:
+			fhr++
+		case telemetryv1.Metric_METRIC_UC:
+			uc++
+		}
+	}
+	return fhr, uc
+}
+
+func TestBatcher_JointAlignsChannels(t *testing.T) {
+	cfg := &config.Config{
+		BatchMaxSamples: 2,
+		BatchMaxSpanMS:  250,
+		FlushIntervalMS: 60000,
+		DropTooOldMS:    30000,
+		BatchMode:       config.BatchModeJoint,
+		JointMaxWait:    time.Hour,
+	}
+
+	sink := &TestSink{}
+	batcher := NewBatcher(cfg, sink)
+
+	// 4Hz по обоим каналам, UC приходит со сдвигом относительно FHR
+	for i := uint64(0); i < 4; i++ {
+		ts := 1000 + i*250
+		batcher.Add(jointSample(telemetryv1.Metric_METRIC_FHR, ts))
+		batcher.Add(jointSample(telemetryv1.Metric_METRIC_UC, ts+10))
+	}
+
+	batcher.Stop()
+
+	batches := sink.GetBatches()
+	if len(batches) != 4 {
+		t.Fatalf("Expected 4 joint batches, got %d", len(batches))
+	}
+	for i, b := range batches {
+		if b.Key.Metric != telemetryv1.Metric_METRIC_UNSPECIFIED {
+			t.Errorf("Batch %d: expected joint key, got metric %s", i, b.Key.Metric)
+		}
+		if fhr, uc := channelCounts(b); fhr != 1 || uc != 1 {
+			t.Errorf("Batch %d: expected 1 FHR and 1 UC point, got fhr=%d uc=%d", i, fhr, uc)
+		}
+		if want := int64(1000 + i*250); b.T0MS != want {
+			t.Errorf("Batch %d: expected t0=%d, got %d", i, want, b.T0MS)
+		}
+	}
+}
+
+func TestBatcher_JointWaitsForLaggingChannel(t *testing.T) {
+	cfg := &config.Config{
+		BatchMaxSamples: 2,
+		BatchMaxSpanMS:  250,
+		FlushIntervalMS: 60000,
+		DropTooOldMS:    30000,
+		BatchMode:       config.BatchModeJoint,
+		JointMaxWait:    time.Minute,
+	}
+
+	sink := &TestSink{}
+	batcher := NewBatcher(cfg, sink)
+
+	batcher.Add(jointSample(telemetryv1.Metric_METRIC_FHR, 1000))
+	batcher.Add(jointSample(telemetryv1.Metric_METRIC_UC, 1000))
+	batcher.Add(jointSample(telemetryv1.Metric_METRIC_FHR, 1250))
+	batcher.Add(jointSample(telemetryv1.Metric_METRIC_FHR, 1500))
+
+	// UC отстает - окно [1000, 1250) еще ждет
+	if flushed := batcher.GetStats().Flushed; flushed != 0 {
+		t.Fatalf("Expected window to wait for UC, got %d flushed batches", flushed)
+	}
+
+	// Истек JointMaxWait - окна отправляются без отстающего канала
+	batcher.mu.Lock()
+	batcher.flushReadyJoint(batcher.joint["session1"], time.Now().Add(cfg.JointMaxWait))
+	batcher.mu.Unlock()
+
+	if flushed := batcher.GetStats().Flushed; flushed != 2 {
+		t.Fatalf("Expected 2 windows flushed after wait timeout, got %d", flushed)
+	}
+
+	// Пока UC отстает, следующие окна не ждут его
+	batcher.Add(jointSample(telemetryv1.Metric_METRIC_FHR, 1750))
+	if flushed := batcher.GetStats().Flushed; flushed != 3 {
+		t.Fatalf("Expected window to flush without waiting again, got %d flushed batches", flushed)
+	}
+
+	batcher.Stop()
+
+	batches := sink.GetBatches()
+	if len(batches) != 4 {
+		t.Fatalf("Expected 4 batches, got %d", len(batches))
+	}
+	if fhr, uc := channelCounts(batches[0]); fhr != 1 || uc != 1 {
+		t.Errorf("Expected first window with both channels, got fhr=%d uc=%d", fhr, uc)
+	}
+	for i, b := range batches[1:] {
+		if fhr, uc := channelCounts(b); fhr != 1 || uc != 0 {
+			t.Errorf("Batch %d: expected FHR-only window, got fhr=%d uc=%d", i+1, fhr, uc)
+		}
+	}
+}
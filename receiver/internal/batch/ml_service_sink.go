@@ -2,13 +2,19 @@ package batch
 
 import (
 	"context"
-	"log"
+	"log/slog"
+	"time"
 
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 
+	"github.com/Krimson/fetal-monitory/logging"
 	featureextractorv1 "github.com/Krimson/fetal-monitory/proto/feature_extractor"
 	mlservicev1 "github.com/Krimson/fetal-monitory/proto/ml_service"
+	"github.com/Krimson/fetal-monitory/receiver/internal/health"
+	"github.com/Krimson/fetal-monitory/receiver/internal/metrics"
+	"github.com/Krimson/fetal-monitory/receiver/internal/tracing"
 )
 
 // MLServiceSink отправляет признаки в ML сервис для предсказания
@@ -18,12 +24,18 @@ type MLServiceSink struct {
 
 	// Канал для передачи предсказаний дальше (например, для WebSocket)
 	predictionChan chan *mlservicev1.PredictResponse
+
+	// Метрики Prometheus (необязательны)
+	metrics *metrics.Receiver
 }
 
 // NewMLServiceSink создает новый экземпляр MLServiceSink
 func NewMLServiceSink(mlServiceAddr string) (*MLServiceSink, error) {
 	// Подключаемся к ML gRPC сервису
-	conn, err := grpc.Dial(mlServiceAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.Dial(mlServiceAddr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		tracing.ClientHandler(),
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -37,10 +49,14 @@ func NewMLServiceSink(mlServiceAddr string) (*MLServiceSink, error) {
 	}, nil
 }
 
+// SetMetrics подключает метрики Prometheus (длительность вызовов ML сервиса)
+func (ms *MLServiceSink) SetMetrics(m *metrics.Receiver) {
+	ms.metrics = m
+}
+
 // ConsumeFeatures принимает признаки от feature extractor и отправляет в ML сервис
 func (ms *MLServiceSink) ConsumeFeatures(ctx context.Context, features *featureextractorv1.ProcessBatchResponse) error {
-	log.Printf("[ML_SERVICE] Processing features: session=%s stv=%.2f ltv=%.2f",
-		features.SessionId, features.Stv, features.Ltv)
+	slog.DebugContext(ctx, "Processing features", logging.SessionID(features.SessionId), logging.BatchTS(features.BatchTsMs), "stv", features.Stv, "ltv", features.Ltv)
 
 	// Конвертируем признаки в ML request
 	request := &mlservicev1.PredictRequest{
@@ -63,9 +79,15 @@ func (ms *MLServiceSink) ConsumeFeatures(ctx context.Context, features *featuree
 
 	// Отправляем в ML сервис (асинхронно, не блокируем основной поток)
 	go func() {
+		ctx, span := tracer.Start(ctx, "MLServiceSink.ConsumeFeatures",
+			trace.WithAttributes(tracing.AttrSessionID.String(request.SessionId)))
+
+		start := time.Now()
 		response, err := ms.client.PredictFromFeatures(ctx, request)
+		ms.metrics.ObserveMLService(time.Since(start), err)
+		endSpan(span, err)
 		if err != nil {
-			log.Printf("[ERROR] Failed to get prediction from ML service: %v", err)
+			slog.ErrorContext(ctx, "Failed to get prediction from ML service", logging.SessionID(request.SessionId), logging.BatchTS(request.BatchTsMs), logging.Err(err))
 			// При ошибке отправляем response со статусом error и последним известным предиктом (0.0)
 			response = &mlservicev1.PredictResponse{
 				SessionId:     request.SessionId,
@@ -80,10 +102,10 @@ func (ms *MLServiceSink) ConsumeFeatures(ctx context.Context, features *featuree
 		// Отправляем предсказание в канал
 		select {
 		case ms.predictionChan <- response:
-			log.Printf("[ML_SERVICE] Prediction for session %s: %.4f (status: %s)",
-				response.SessionId, response.Prediction, response.Status)
+			slog.DebugContext(ctx, "Prediction", logging.SessionID(response.SessionId), logging.BatchTS(request.BatchTsMs),
+				"prediction", response.Prediction, "status", response.Status)
 		default:
-			log.Printf("[WARN] Prediction channel full, dropping prediction")
+			slog.WarnContext(ctx, "Prediction channel full, dropping prediction", logging.SessionID(response.SessionId))
 		}
 	}()
 
@@ -95,6 +117,11 @@ func (ms *MLServiceSink) GetPredictionChannel() <-chan *mlservicev1.PredictRespo
 	return ms.predictionChan
 }
 
+// CheckHealth проверяет ML сервис через grpc.health.v1
+func (ms *MLServiceSink) CheckHealth(ctx context.Context) error {
+	return health.CheckGRPC(ctx, ms.conn, "")
+}
+
 // Close закрывает соединение
 func (ms *MLServiceSink) Close() error {
 	close(ms.predictionChan)
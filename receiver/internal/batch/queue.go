@@ -0,0 +1,320 @@
+package batch
+
+import (
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Krimson/fetal-monitory/logging"
+)
+
+// defaultQueueMaxDepth - глубина очереди сессии, если в конфиге не задана
+// (≈5 минут данных при 4Hz по двум метрикам)
+const defaultQueueMaxDepth = 2400
+
+// backoffPolicy описывает экспоненциальную задержку между повторными попытками
+type backoffPolicy struct {
+	base        time.Duration
+	max         time.Duration
+	maxAttempts int // 0 - повторять до успеха
+}
+
+// delay возвращает задержку перед попыткой номер attempt+1 (attempt >= 1)
+func (p backoffPolicy) delay(attempt int) time.Duration {
+	if p.base <= 0 {
+		return 0
+	}
+	d := p.base
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if p.max > 0 && d >= p.max {
+			return p.max
+		}
+	}
+	if p.max > 0 && d > p.max {
+		return p.max
+	}
+	return d
+}
+
+// queuedBatch - батч в очереди с глобальным порядковым номером
+type queuedBatch struct {
+	seq   uint64
+	batch Batch
+}
+
+// sessionQueue - FIFO батчей одной сессии
+type sessionQueue struct {
+	items         []queuedBatch
+	attempts      int       // Неудачные попытки доставки головного батча
+	nextAttemptAt time.Time // Раньше этого времени головной батч не отправляется
+	inFlight      bool      // Головной батч сейчас доставляется
+}
+
+// queueStats - счетчики очереди
+type queueStats struct {
+	enqueued  int64
+	delivered int64
+	retries   int64
+	failed    int64 // Батчи, отброшенные после maxAttempts
+	evicted   int64 // Батчи, вытесненные из переполненной очереди
+}
+
+// outboundQueue - ограниченная очередь батчей между Batcher и Sink.
+// Порядок внутри сессии сохраняется: следующий батч сессии не отправляется,
+// пока не доставлен (или не отброшен) предыдущий.
+type outboundQueue struct {
+	mu       sync.Mutex
+	sessions map[string]*sessionQueue
+	depth    int
+	seq      uint64
+	maxDepth int
+	backoff  backoffPolicy
+	journal  *queueJournal // nil - очередь только в памяти
+	stats    queueStats
+
+	// Сессии распределены по шардам; каждый шард обслуживает свой воркер доставки.
+	// notify[i] будит воркер шарда i при появлении работы.
+	shards int
+	notify []chan struct{}
+}
+
+// newOutboundQueue создает очередь с указанным числом шардов; если dir не пустой,
+// очередь дублируется на диск и восстанавливается из него после перезапуска
+func newOutboundQueue(maxDepth int, backoff backoffPolicy, dir string, shards int) (*outboundQueue, error) {
+	if maxDepth <= 0 {
+		maxDepth = defaultQueueMaxDepth
+	}
+	if shards <= 0 {
+		shards = 1
+	}
+
+	q := &outboundQueue{
+		sessions: make(map[string]*sessionQueue),
+		maxDepth: maxDepth,
+		backoff:  backoff,
+		shards:   shards,
+		notify:   make([]chan struct{}, shards),
+	}
+	for i := range q.notify {
+		q.notify[i] = make(chan struct{}, 1)
+	}
+
+	if dir != "" {
+		journal, restored, err := openQueueJournal(dir)
+		if err != nil {
+			return nil, err
+		}
+		q.journal = journal
+
+		for _, b := range restored {
+			q.pushLocked(b, false)
+		}
+		if q.depth > 0 {
+			slog.Info("Restored pending batches", "batches", q.depth, "sessions", len(q.sessions), "dir", dir)
+		}
+	}
+
+	return q, nil
+}
+
+// push добавляет батч в очередь его сессии
+func (q *outboundQueue) push(b Batch) {
+	q.mu.Lock()
+	q.pushLocked(b, true)
+	q.mu.Unlock()
+
+	q.wake(q.shardOf(b.Key.SessionID))
+}
+
+// shardOf возвращает шард сессии; все батчи сессии обслуживает один воркер
+func (q *outboundQueue) shardOf(sessionID string) int {
+	if q.shards == 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(sessionID))
+	return int(h.Sum32() % uint32(q.shards))
+}
+
+func (q *outboundQueue) pushLocked(b Batch, persist bool) {
+	sessionID := b.Key.SessionID
+
+	sq, ok := q.sessions[sessionID]
+	if !ok {
+		sq = &sessionQueue{}
+		q.sessions[sessionID] = sq
+	}
+
+	// Очередь сессии переполнена - вытесняем самый старый батч, который не
+	// доставляется прямо сейчас: порядок сохраняется, свежие данные остаются
+	if len(sq.items) >= q.maxDepth {
+		if !sq.inFlight {
+			q.popHeadLocked(sessionID, sq)
+			q.sessions[sessionID] = sq // popHeadLocked удаляет опустевшую сессию
+			q.stats.evicted++
+			slog.Warn("Outbound queue full, oldest batch evicted", logging.SessionID(sessionID))
+		} else if len(sq.items) > 1 {
+			q.dropLocked(sessionID, sq, 1)
+			q.stats.evicted++
+			slog.Warn("Outbound queue full, oldest pending batch evicted", logging.SessionID(sessionID))
+		}
+		// Иначе единственный батч в полете: новый батч временно превышает глубину на один
+	}
+
+	q.seq++
+	sq.items = append(sq.items, queuedBatch{seq: q.seq, batch: b})
+	q.depth++
+	q.stats.enqueued++
+
+	if persist && q.journal != nil {
+		if err := q.journal.appendPush(b); err != nil {
+			slog.Error("Failed to persist queued batch", logging.SessionID(sessionID), logging.Err(err))
+		}
+	}
+}
+
+// popHeadLocked удаляет головной батч сессии
+func (q *outboundQueue) popHeadLocked(sessionID string, sq *sessionQueue) {
+	sq.items = sq.items[1:]
+	sq.attempts = 0
+	sq.nextAttemptAt = time.Time{}
+	q.depth--
+
+	if q.journal != nil {
+		if err := q.journal.appendPop(sessionID, len(sq.items)); err != nil {
+			slog.Error("Failed to persist queue pop", logging.SessionID(sessionID), logging.Err(err))
+		}
+	}
+
+	if len(sq.items) == 0 && !sq.inFlight {
+		delete(q.sessions, sessionID)
+	}
+}
+
+// dropLocked удаляет из очереди сессии батч с индексом i > 0 (головной батч
+// удаляет только popHeadLocked)
+func (q *outboundQueue) dropLocked(sessionID string, sq *sessionQueue, i int) {
+	sq.items = append(sq.items[:i], sq.items[i+1:]...)
+	q.depth--
+
+	if q.journal != nil {
+		if err := q.journal.appendDrop(sessionID, i); err != nil {
+			slog.Error("Failed to persist queue drop", logging.SessionID(sessionID), logging.Err(err))
+		}
+	}
+}
+
+// next выбирает готовый к отправке батч шарда: среди сессий, у которых головной батч
+// не в полете и не ждет backoff, берется батч с наименьшим порядковым номером.
+// Если готовых батчей нет, возвращает время, когда стоит проверить снова (ноль - ждать push).
+func (q *outboundQueue) next(now time.Time, shard int) (Batch, bool, time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var (
+		bestSQ   *sessionQueue
+		wakeAt   time.Time
+		bestSeq  uint64
+		hasReady bool
+	)
+
+	for sessionID, sq := range q.sessions {
+		if sq.inFlight || len(sq.items) == 0 {
+			continue
+		}
+		if q.shardOf(sessionID) != shard {
+			continue
+		}
+		if sq.nextAttemptAt.After(now) {
+			if wakeAt.IsZero() || sq.nextAttemptAt.Before(wakeAt) {
+				wakeAt = sq.nextAttemptAt
+			}
+			continue
+		}
+		if head := sq.items[0].seq; !hasReady || head < bestSeq {
+			bestSQ, bestSeq, hasReady = sq, head, true
+		}
+	}
+
+	if !hasReady {
+		return Batch{}, false, wakeAt
+	}
+
+	bestSQ.inFlight = true
+	return bestSQ.items[0].batch, true, time.Time{}
+}
+
+// ack подтверждает доставку головного батча сессии
+func (q *outboundQueue) ack(sessionID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	sq, ok := q.sessions[sessionID]
+	if !ok || len(sq.items) == 0 {
+		return
+	}
+	sq.inFlight = false
+	q.popHeadLocked(sessionID, sq)
+	q.stats.delivered++
+}
+
+// retry планирует повторную отправку головного батча сессии после ошибки.
+// Возвращает true, если батч отброшен из-за исчерпания попыток.
+func (q *outboundQueue) retry(sessionID string, now time.Time) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	sq, ok := q.sessions[sessionID]
+	if !ok || len(sq.items) == 0 {
+		return false
+	}
+	sq.inFlight = false
+	sq.attempts++
+
+	if q.backoff.maxAttempts > 0 && sq.attempts >= q.backoff.maxAttempts {
+		q.popHeadLocked(sessionID, sq)
+		q.stats.failed++
+		return true
+	}
+
+	q.stats.retries++
+	sq.nextAttemptAt = now.Add(q.backoff.delay(sq.attempts))
+	return false
+}
+
+// wake будит воркер доставки шарда
+func (q *outboundQueue) wake(shard int) {
+	select {
+	case q.notify[shard] <- struct{}{}:
+	default:
+	}
+}
+
+// len возвращает общее количество батчей в очереди
+func (q *outboundQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.depth
+}
+
+// snapshot возвращает глубину очереди, число сессий и счетчики
+func (q *outboundQueue) snapshot() (depth, sessions int, stats queueStats) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.depth, len(q.sessions), q.stats
+}
+
+// close закрывает журнал очереди; оставшиеся батчи будут восстановлены при следующем запуске
+func (q *outboundQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.journal != nil {
+		if err := q.journal.close(); err != nil {
+			slog.Error("Failed to close queue journal", logging.Err(err))
+		}
+		q.journal = nil
+	}
+}
@@ -0,0 +1,317 @@
+package batch
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Журнал очереди на диске: по одному append-only файлу на сессию.
+// Каждая строка - JSON запись {"op":"push","batch":{...}}, {"op":"pop"} или
+// {"op":"drop","index":N} (вытеснение не головного батча).
+// Когда очередь сессии опустевает, файл удаляется. Компактизация пишет новый файл
+// рядом и атомарно подменяет им старый, поэтому сбой посреди нее не теряет батчи.
+
+const (
+	journalOpPush = "push"
+	journalOpPop  = "pop"
+	journalOpDrop = "drop"
+
+	journalFileExt = ".jsonl"
+	// Временный файл компактизации: <журнал>.jsonl.tmp-<random>, при восстановлении удаляется
+	journalTempInfix = journalFileExt + ".tmp-"
+
+	// После стольких pop без опустошения очереди файл переписывается целиком
+	journalCompactEvery = 1000
+)
+
+type journalRecord struct {
+	Op    string `json:"op"`
+	Batch *Batch `json:"batch,omitempty"`
+	Index int    `json:"index,omitempty"`
+}
+
+// sessionJournal - файл журнала одной сессии
+type sessionJournal struct {
+	path    string
+	file    *os.File
+	pending []Batch // Копия содержимого очереди для компактизации
+	pops    int
+}
+
+// queueJournal хранит файлы журналов всех сессий
+type queueJournal struct {
+	dir      string
+	sessions map[string]*sessionJournal
+}
+
+// openQueueJournal открывает каталог журнала и восстанавливает неотправленные батчи
+// в порядке их исходного поступления внутри каждой сессии
+func openQueueJournal(dir string) (*queueJournal, []Batch, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create queue dir: %w", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read queue dir: %w", err)
+	}
+
+	j := &queueJournal{
+		dir:      dir,
+		sessions: make(map[string]*sessionJournal),
+	}
+
+	var restored []Batch
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		// Недописанная компактизация: исходный журнал остался на месте
+		if strings.Contains(entry.Name(), journalTempInfix) {
+			os.Remove(filepath.Join(dir, entry.Name()))
+			continue
+		}
+		if !strings.HasSuffix(entry.Name(), journalFileExt) {
+			continue
+		}
+
+		pending, err := replayJournalFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(pending) == 0 {
+			os.Remove(filepath.Join(dir, entry.Name()))
+			continue
+		}
+
+		// Переписываем файл компактно, чтобы не тянуть историю pop
+		sessionID := pending[0].Key.SessionID
+		sj, err := j.open(sessionID)
+		if err != nil {
+			return nil, nil, err
+		}
+		sj.pending = append(sj.pending, pending...)
+		if err := sj.rewrite(); err != nil {
+			return nil, nil, err
+		}
+
+		restored = append(restored, pending...)
+	}
+
+	// Восстанавливаем в порядке времени, чтобы глобальная очередность была близка к исходной
+	sort.SliceStable(restored, func(a, b int) bool {
+		return restored[a].T0MS < restored[b].T0MS
+	})
+
+	return j, restored, nil
+}
+
+// replayJournalFile проигрывает записи файла и возвращает оставшиеся в очереди батчи
+func replayJournalFile(path string) ([]Batch, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open queue journal %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var pending []Batch
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		var rec journalRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// Оборванная последняя строка после аварийного завершения - пропускаем
+			continue
+		}
+		switch rec.Op {
+		case journalOpPush:
+			if rec.Batch != nil {
+				pending = append(pending, *rec.Batch)
+			}
+		case journalOpPop:
+			if len(pending) > 0 {
+				pending = pending[1:]
+			}
+		case journalOpDrop:
+			if rec.Index > 0 && rec.Index < len(pending) {
+				pending = append(pending[:rec.Index], pending[rec.Index+1:]...)
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read queue journal %s: %w", path, err)
+	}
+	return pending, nil
+}
+
+// journalPath возвращает имя файла сессии (session_id кодируется в hex, чтобы быть безопасным для ФС)
+func (j *queueJournal) journalPath(sessionID string) string {
+	return filepath.Join(j.dir, hex.EncodeToString([]byte(sessionID))+journalFileExt)
+}
+
+func (j *queueJournal) open(sessionID string) (*sessionJournal, error) {
+	if sj, ok := j.sessions[sessionID]; ok {
+		return sj, nil
+	}
+
+	path := j.journalPath(sessionID)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open queue journal: %w", err)
+	}
+
+	sj := &sessionJournal{path: path, file: f}
+	j.sessions[sessionID] = sj
+	return sj, nil
+}
+
+// appendPush записывает добавление батча
+func (j *queueJournal) appendPush(b Batch) error {
+	sj, err := j.open(b.Key.SessionID)
+	if err != nil {
+		return err
+	}
+	sj.pending = append(sj.pending, b)
+	return sj.write(journalRecord{Op: journalOpPush, Batch: &b})
+}
+
+// appendPop записывает удаление головного батча; remaining - сколько батчей осталось в очереди
+func (j *queueJournal) appendPop(sessionID string, remaining int) error {
+	sj, err := j.open(sessionID)
+	if err != nil {
+		return err
+	}
+	if len(sj.pending) > 0 {
+		sj.pending = sj.pending[1:]
+	}
+
+	// Очередь пуста - журнал сессии больше не нужен
+	if remaining == 0 {
+		sj.pending = nil
+		sj.pops = 0
+		sj.file.Close()
+		delete(j.sessions, sessionID)
+		if err := os.Remove(j.journalPath(sessionID)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove queue journal: %w", err)
+		}
+		return nil
+	}
+
+	sj.pops++
+	if sj.pops >= journalCompactEvery {
+		return sj.rewrite()
+	}
+	return sj.write(journalRecord{Op: journalOpPop})
+}
+
+// appendDrop записывает удаление батча с индексом index > 0 из очереди сессии
+func (j *queueJournal) appendDrop(sessionID string, index int) error {
+	sj, err := j.open(sessionID)
+	if err != nil {
+		return err
+	}
+	if index < len(sj.pending) {
+		sj.pending = append(sj.pending[:index], sj.pending[index+1:]...)
+	}
+
+	sj.pops++
+	if sj.pops >= journalCompactEvery {
+		return sj.rewrite()
+	}
+	return sj.write(journalRecord{Op: journalOpDrop, Index: index})
+}
+
+func (sj *sessionJournal) write(rec journalRecord) error {
+	return writeJournalRecord(sj.file, rec)
+}
+
+func writeJournalRecord(f *os.File, rec journalRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal record: %w", err)
+	}
+	data = append(data, '\n')
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("failed to write journal record: %w", err)
+	}
+	return nil
+}
+
+// rewrite переписывает файл, оставляя только push записи для батчей в очереди.
+// Новый файл пишется во временный, сбрасывается на диск и переименовывается поверх
+// журнала: после сбоя на диске остается либо старый, либо новый журнал целиком
+func (sj *sessionJournal) rewrite() error {
+	tmp, err := os.CreateTemp(filepath.Dir(sj.path), filepath.Base(sj.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create queue journal: %w", err)
+	}
+	if err := sj.writeCompacted(tmp); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), sj.path); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to replace queue journal: %w", err)
+	}
+
+	// Дальше пишем в новый файл: позиция уже в конце
+	sj.file.Close()
+	sj.file = tmp
+	sj.pops = 0
+
+	if err := syncDir(filepath.Dir(sj.path)); err != nil {
+		return fmt.Errorf("failed to sync queue dir: %w", err)
+	}
+	return nil
+}
+
+// writeCompacted пишет push записи очереди в f и сбрасывает их на диск
+func (sj *sessionJournal) writeCompacted(f *os.File) error {
+	if err := f.Chmod(0o644); err != nil {
+		return fmt.Errorf("failed to chmod queue journal: %w", err)
+	}
+	for i := range sj.pending {
+		if err := writeJournalRecord(f, journalRecord{Op: journalOpPush, Batch: &sj.pending[i]}); err != nil {
+			return err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync queue journal: %w", err)
+	}
+	return nil
+}
+
+// syncDir сбрасывает на диск запись каталога (переименование файла в нем)
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// close сбрасывает данные на диск и закрывает файлы
+func (j *queueJournal) close() error {
+	var firstErr error
+	for sessionID, sj := range j.sessions {
+		if err := sj.file.Sync(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if err := sj.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(j.sessions, sessionID)
+	}
+	return firstErr
+}
@@ -0,0 +1,482 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	telemetryv1 "github.com/Krimson/fetal-monitory/proto/telemetry"
+	"github.com/Krimson/fetal-monitory/receiver/internal/config"
+)
+
+// FlakySink для тестирования - отклоняет первые failures вызовов
+type FlakySink struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+	batches  []Batch
+}
+
+func (fs *FlakySink) Consume(ctx context.Context, b Batch) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.calls++
+	if fs.failures > 0 {
+		fs.failures--
+		return errors.New("feature extractor unavailable")
+	}
+	fs.batches = append(fs.batches, b)
+	return nil
+}
+
+func (fs *FlakySink) GetBatches() []Batch {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	result := make([]Batch, len(fs.batches))
+	copy(result, fs.batches)
+	return result
+}
+
+func TestBatcher_RetryKeepsSessionOrder(t *testing.T) {
+	cfg := &config.Config{
+		BatchMaxSamples: 1,
+		BatchMaxSpanMS:  30000,
+		FlushIntervalMS: 500,
+		DropTooOldMS:    30000,
+		RetryBaseDelay:  10 * time.Millisecond,
+		RetryMaxDelay:   40 * time.Millisecond,
+	}
+
+	sink := &FlakySink{failures: 3}
+	batcher := NewBatcher(cfg, sink)
+
+	for i := 0; i < 5; i++ {
+		sample := &telemetryv1.Sample{
+			SessionId: "session1",
+			TsMs:      uint64(1000 + i*250),
+			Metric:    telemetryv1.Metric_METRIC_FHR,
+			Value:     float32(120 + i),
+		}
+		if err := batcher.Add(sample); err != nil {
+			t.Fatalf("Failed to add sample: %v", err)
+		}
+	}
+
+	batcher.Stop()
+
+	batches := sink.GetBatches()
+	if len(batches) != 5 {
+		t.Fatalf("Expected all 5 batches to be delivered after retries, got %d", len(batches))
+	}
+	for i, b := range batches {
+		if want := int64(1000 + i*250); b.T0MS != want {
+			t.Errorf("Batch %d delivered out of order: t0=%d, want %d", i, b.T0MS, want)
+		}
+	}
+
+	stats := batcher.GetStats()
+	if stats.Retries != 3 {
+		t.Errorf("Expected 3 retries, got %d", stats.Retries)
+	}
+	if stats.QueueDepth != 0 {
+		t.Errorf("Expected empty queue after stop, got depth %d", stats.QueueDepth)
+	}
+}
+
+func TestOutboundQueue_RestoresFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	backoff := backoffPolicy{base: time.Millisecond}
+
+	q, err := newOutboundQueue(10, backoff, dir, 1)
+	if err != nil {
+		t.Fatalf("Failed to open queue: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		q.push(Batch{
+			Key:    BatchKey{SessionID: "session1", Metric: telemetryv1.Metric_METRIC_UC},
+			T0MS:   int64(1000 + i),
+			T1MS:   int64(1000 + i),
+			Points: []Point{{TsMS: int64(1000 + i), Value: 10}},
+		})
+	}
+
+	// Первый батч доставлен, остальные - нет (например, процесс упал)
+	if _, ok, _ := q.next(time.Now(), 0); !ok {
+		t.Fatalf("Expected a ready batch")
+	}
+	q.ack("session1")
+	q.close()
+
+	restored, err := newOutboundQueue(10, backoff, dir, 1)
+	if err != nil {
+		t.Fatalf("Failed to reopen queue: %v", err)
+	}
+	defer restored.close()
+
+	if depth := restored.len(); depth != 2 {
+		t.Fatalf("Expected 2 restored batches, got %d", depth)
+	}
+
+	b, ok, _ := restored.next(time.Now(), 0)
+	if !ok || b.T0MS != 1001 {
+		t.Errorf("Expected restored head batch t0=1001, got ok=%v t0=%d", ok, b.T0MS)
+	}
+}
+
+func queueTestBatch(t0 int64) Batch {
+	return Batch{
+		Key:    BatchKey{SessionID: "session1", Metric: telemetryv1.Metric_METRIC_FHR},
+		T0MS:   t0,
+		T1MS:   t0,
+		Points: []Point{{TsMS: t0, Value: 120}},
+	}
+}
+
+func TestQueueJournal_RewriteReplacesFile(t *testing.T) {
+	dir := t.TempDir()
+
+	j, _, err := openQueueJournal(dir)
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	for i := int64(0); i < 3; i++ {
+		if err := j.appendPush(queueTestBatch(1000 + i)); err != nil {
+			t.Fatalf("appendPush failed: %v", err)
+		}
+	}
+	if err := j.appendPop("session1", 2); err != nil {
+		t.Fatalf("appendPop failed: %v", err)
+	}
+
+	sj := j.sessions["session1"]
+	if err := sj.rewrite(); err != nil {
+		t.Fatalf("rewrite failed: %v", err)
+	}
+	// Записи после компактизации попадают в новый файл
+	if err := j.appendPush(queueTestBatch(1003)); err != nil {
+		t.Fatalf("appendPush after rewrite failed: %v", err)
+	}
+	if err := j.close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	// Временный файл оборванной компактизации не мешает восстановлению
+	stale := filepath.Join(dir, filepath.Base(sj.path)+".tmp-42")
+	if err := os.WriteFile(stale, []byte("{\"op\":\"pu"), 0o644); err != nil {
+		t.Fatalf("Failed to write stale temp file: %v", err)
+	}
+
+	reopened, restored, err := openQueueJournal(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen journal: %v", err)
+	}
+	defer reopened.close()
+
+	var got []int64
+	for _, b := range restored {
+		got = append(got, b.T0MS)
+	}
+	if fmt.Sprint(got) != "[1001 1002 1003]" {
+		t.Errorf("Expected restored batches [1001 1002 1003], got %v", got)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read dir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != filepath.Base(sj.path) {
+		t.Errorf("Expected only the session journal on disk, got %v", entries)
+	}
+}
+
+func TestOutboundQueue_EvictsOldestPendingWhileHeadInFlight(t *testing.T) {
+	dir := t.TempDir()
+	backoff := backoffPolicy{base: time.Millisecond}
+
+	q, err := newOutboundQueue(3, backoff, dir, 1)
+	if err != nil {
+		t.Fatalf("Failed to open queue: %v", err)
+	}
+
+	for _, t0 := range []int64{1000, 1001, 1002} {
+		q.push(queueTestBatch(t0))
+	}
+	head, ok, _ := q.next(time.Now(), 0)
+	if !ok || head.T0MS != 1000 {
+		t.Fatalf("Expected head 1000 in flight, got ok=%v t0=%d", ok, head.T0MS)
+	}
+
+	// Очередь полна, голова в полете: вытесняется 1001, свежий 1003 остается
+	q.push(queueTestBatch(1003))
+
+	if depth := q.len(); depth != 3 {
+		t.Fatalf("Expected depth 3, got %d", depth)
+	}
+	if _, _, qs := q.snapshot(); qs.evicted != 1 {
+		t.Errorf("Expected 1 evicted batch, got %d", qs.evicted)
+	}
+
+	q.ack("session1")
+	q.close()
+
+	// Журнал учитывает вытеснение: после перезапуска порядок тот же
+	restored, err := newOutboundQueue(3, backoff, dir, 1)
+	if err != nil {
+		t.Fatalf("Failed to reopen queue: %v", err)
+	}
+	defer restored.close()
+
+	var got []int64
+	for {
+		b, ok, _ := restored.next(time.Now(), 0)
+		if !ok {
+			break
+		}
+		got = append(got, b.T0MS)
+		restored.ack("session1")
+	}
+	if fmt.Sprint(got) != fmt.Sprint([]int64{1002, 1003}) {
+		t.Errorf("Expected delivery order [1002 1003], got %v", got)
+	}
+}
+
+func TestOutboundQueue_SingleInFlightBatchKeepsNewest(t *testing.T) {
+	q, err := newOutboundQueue(1, backoffPolicy{}, "", 1)
+	if err != nil {
+		t.Fatalf("Failed to open queue: %v", err)
+	}
+
+	q.push(queueTestBatch(1000))
+	if _, ok, _ := q.next(time.Now(), 0); !ok {
+		t.Fatalf("Expected a ready batch")
+	}
+	q.push(queueTestBatch(1001))
+	q.ack("session1")
+
+	b, ok, _ := q.next(time.Now(), 0)
+	if !ok || b.T0MS != 1001 {
+		t.Errorf("Expected newest batch 1001 to be kept, got ok=%v t0=%d", ok, b.T0MS)
+	}
+}
+
+// BlockingSink для тестирования - держит доставку, пока не закрыт release
+type BlockingSink struct {
+	release chan struct{}
+	mu      sync.Mutex
+	batches []Batch
+}
+
+func (bs *BlockingSink) Consume(ctx context.Context, b Batch) error {
+	select {
+	case <-bs.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bs.batches = append(bs.batches, b)
+	return nil
+}
+
+func (bs *BlockingSink) Count() int {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	return len(bs.batches)
+}
+
+func TestBatcher_FullFlushChanDoesNotDropBatches(t *testing.T) {
+	cfg := &config.Config{
+		BatchMaxSamples: 1,
+		BatchMaxSpanMS:  30000,
+		FlushIntervalMS: 500,
+		DropTooOldMS:    30000,
+		QueueMaxDepth:   1000,
+	}
+
+	sink := &BlockingSink{release: make(chan struct{})}
+	batcher := NewBatcher(cfg, sink)
+
+	// Больше, чем вмещает flushChan: лишние батчи ждут flushWorker, а не теряются
+	const total = 300
+	for i := 0; i < total; i++ {
+		batcher.Add(&telemetryv1.Sample{
+			SessionId: "session1",
+			TsMs:      uint64(1000 + i*250),
+			Metric:    telemetryv1.Metric_METRIC_FHR,
+			Value:     120,
+		})
+	}
+	close(sink.release)
+	batcher.Stop()
+
+	if got := sink.Count(); got != total {
+		t.Errorf("Expected %d delivered batches, got %d", total, got)
+	}
+	if stats := batcher.GetStats(); stats.Dropped != 0 {
+		t.Errorf("Expected no dropped batches, got %d", stats.Dropped)
+	}
+}
+
+// SlowSink для тестирования - держит доставку батчей выбранной сессии,
+// пока не закрыт release; entered сигнализирует, что доставка началась
+type SlowSink struct {
+	mu        sync.Mutex
+	slow      string
+	release   chan struct{}
+	entered   chan struct{}
+	once      sync.Once
+	delivered map[string][]int64
+}
+
+func (ss *SlowSink) Consume(ctx context.Context, b Batch) error {
+	if b.Key.SessionID == ss.slow {
+		ss.once.Do(func() { close(ss.entered) })
+		<-ss.release
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.delivered[b.Key.SessionID] = append(ss.delivered[b.Key.SessionID], b.T0MS)
+	return nil
+}
+
+func (ss *SlowSink) Delivered(sessionID string) []int64 {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	result := make([]int64, len(ss.delivered[sessionID]))
+	copy(result, ss.delivered[sessionID])
+	return result
+}
+
+func TestBatcher_SlowSessionDoesNotBlockOthers(t *testing.T) {
+	cfg := &config.Config{
+		BatchMaxSamples: 1,
+		BatchMaxSpanMS:  30000,
+		FlushIntervalMS: 500,
+		DropTooOldMS:    30000,
+		FlushWorkers:    4,
+	}
+
+	sink := &SlowSink{
+		slow:      "slow",
+		release:   make(chan struct{}),
+		entered:   make(chan struct{}),
+		delivered: make(map[string][]int64),
+	}
+	batcher := NewBatcher(cfg, sink)
+
+	// Быстрая сессия должна попасть в другой шард, чем медленная
+	fast := ""
+	for i := 0; fast == ""; i++ {
+		if id := fmt.Sprintf("fast%d", i); batcher.queue.shardOf(id) != batcher.queue.shardOf("slow") {
+			fast = id
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		for _, sessionID := range []string{"slow", fast} {
+			sample := &telemetryv1.Sample{
+				SessionId: sessionID,
+				TsMs:      uint64(1000 + i*250),
+				Metric:    telemetryv1.Metric_METRIC_FHR,
+				Value:     float32(120 + i),
+			}
+			if err := batcher.Add(sample); err != nil {
+				t.Fatalf("Failed to add sample: %v", err)
+			}
+		}
+	}
+
+	select {
+	case <-sink.entered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Slow session delivery never started")
+	}
+
+	// Медленная сессия заблокирована в sink; быстрая должна доставиться полностью.
+	// Таймаут - только защита от зависания, на результат время не влияет
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sink.Delivered(fast)) < 5 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := len(sink.Delivered(fast)); got != 5 {
+		t.Errorf("Expected fast session to be delivered while slow one is blocked, got %d batches", got)
+	}
+	if got := len(sink.Delivered("slow")); got != 0 {
+		t.Errorf("Expected slow session to still be blocked, got %d batches", got)
+	}
+
+	close(sink.release)
+	batcher.Stop()
+
+	for _, sessionID := range []string{"slow", fast} {
+		delivered := sink.Delivered(sessionID)
+		if len(delivered) != 5 {
+			t.Fatalf("Expected 5 batches for %s, got %d", sessionID, len(delivered))
+		}
+		for i, t0 := range delivered {
+			if want := int64(1000 + i*250); t0 != want {
+				t.Errorf("Session %s batch %d delivered out of order: t0=%d, want %d", sessionID, i, t0, want)
+			}
+		}
+	}
+}
+
+func TestBatcher_ParallelWorkersKeepSessionOrder(t *testing.T) {
+	cfg := &config.Config{
+		BatchMaxSamples: 1,
+		BatchMaxSpanMS:  30000,
+		FlushIntervalMS: 500,
+		DropTooOldMS:    30000,
+		FlushWorkers:    4,
+	}
+
+	sink := &TestSink{}
+	batcher := NewBatcher(cfg, sink)
+
+	const sessions, samples = 6, 10
+	var wg sync.WaitGroup
+	for s := 0; s < sessions; s++ {
+		wg.Add(1)
+		go func(sessionID string) {
+			defer wg.Done()
+			for i := 0; i < samples; i++ {
+				sample := &telemetryv1.Sample{
+					SessionId: sessionID,
+					TsMs:      uint64(1000 + i*250),
+					Metric:    telemetryv1.Metric_METRIC_UC,
+					Value:     float32(i),
+				}
+				if err := batcher.Add(sample); err != nil {
+					t.Errorf("Failed to add sample: %v", err)
+				}
+			}
+		}(fmt.Sprintf("session%d", s))
+	}
+	wg.Wait()
+
+	batcher.Stop()
+
+	perSession := make(map[string][]int64)
+	for _, b := range sink.GetBatches() {
+		perSession[b.Key.SessionID] = append(perSession[b.Key.SessionID], b.T0MS)
+	}
+	if len(perSession) != sessions {
+		t.Fatalf("Expected batches for %d sessions, got %d", sessions, len(perSession))
+	}
+	for sessionID, delivered := range perSession {
+		if len(delivered) != samples {
+			t.Errorf("Expected %d batches for %s, got %d", samples, sessionID, len(delivered))
+		}
+		for i := 1; i < len(delivered); i++ {
+			if delivered[i] <= delivered[i-1] {
+				t.Errorf("Session %s delivered out of order: %v", sessionID, delivered)
+				break
+			}
+		}
+	}
+}
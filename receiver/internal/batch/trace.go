@@ -0,0 +1,88 @@
+package batch
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Krimson/fetal-monitory/receiver/internal/tracing"
+)
+
+// Трассировка батча. Трасса создается на каждый батч: корневой спан Batcher.collect
+// длится от первой точки до сброса и связан (links) со спанами потоков PushSamples,
+// из которых пришли точки. Контекст корневого спана хранится в Batch.Trace и переживает
+// очередь (в том числе журнал на диске), от него продолжаются Batcher.deliver, вызовы
+// sink и дальше через gRPC metadata (в потоке ProcessBatchStream - через поле
+// trace_context запроса) - спаны Python сервисов.
+
+var tracer = otel.Tracer("github.com/Krimson/fetal-monitory/receiver/internal/batch")
+
+// batchSources - время первой точки собираемого батча и спаны потоков, из которых пришли точки
+type batchSources struct {
+	openedAt time.Time
+	links    []trace.Link
+}
+
+// observe учитывает точку, пришедшую с контекстом ctx
+func (bs *batchSources) observe(ctx context.Context, now time.Time) {
+	if bs.openedAt.IsZero() {
+		bs.openedAt = now
+	}
+
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	for _, link := range bs.links {
+		if link.SpanContext.Equal(sc) {
+			return
+		}
+	}
+	bs.links = append(bs.links, trace.Link{SpanContext: sc})
+}
+
+// take возвращает накопленное и начинает сбор заново
+func (bs *batchSources) take() batchSources {
+	taken := *bs
+	*bs = batchSources{}
+	return taken
+}
+
+// startBatchSpan начинает корневой спан батча и сохраняет его контекст в batch.Trace
+func startBatchSpan(batch *Batch, sources batchSources) trace.Span {
+	opts := []trace.SpanStartOption{
+		trace.WithLinks(sources.links...),
+		trace.WithAttributes(batchAttributes(*batch)...),
+	}
+	if !sources.openedAt.IsZero() {
+		opts = append(opts, trace.WithTimestamp(sources.openedAt))
+	}
+
+	ctx, span := tracer.Start(context.Background(), "Batcher.collect", opts...)
+	batch.Trace = tracing.Inject(ctx)
+	return span
+}
+
+// batchAttributes - атрибуты спанов стадий батча
+func batchAttributes(b Batch) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		tracing.AttrSessionID.String(b.Key.SessionID),
+		tracing.AttrMetric.String(b.Key.Metric.String()),
+		tracing.AttrPoints.Int(len(b.Points)),
+		tracing.AttrT0MS.Int64(b.T0MS),
+		tracing.AttrT1MS.Int64(b.T1MS),
+	}
+}
+
+// endSpan завершает спан, отмечая ошибку
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,119 @@
+package batch
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	telemetryv1 "github.com/Krimson/fetal-monitory/proto/telemetry"
+	"github.com/Krimson/fetal-monitory/receiver/internal/config"
+	"github.com/Krimson/fetal-monitory/receiver/internal/tracing"
+)
+
+var (
+	spanRecorder     = tracetest.NewSpanRecorder()
+	spanRecorderOnce sync.Once
+)
+
+// recordSpans направляет спаны в spanRecorder. Глобальный TracerProvider
+// подключается к tracer пакета один раз, поэтому recorder общий для всех тестов
+func recordSpans() *tracetest.SpanRecorder {
+	spanRecorderOnce.Do(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+	return spanRecorder
+}
+
+// waitSpan ждет завершения спана name для сессии sessionID
+func waitSpan(t *testing.T, recorder *tracetest.SpanRecorder, name, sessionID string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		for _, span := range recorder.Ended() {
+			if span.Name() != name {
+				continue
+			}
+			for _, attr := range span.Attributes() {
+				if attr.Key == tracing.AttrSessionID && attr.Value.AsString() == sessionID {
+					return span
+				}
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Span %s for session %s was not recorded", name, sessionID)
+	return nil
+}
+
+// ContextSink запоминает спан, с которым пришел каждый батч
+type ContextSink struct {
+	mu    sync.Mutex
+	spans []trace.SpanContext
+}
+
+func (cs *ContextSink) Consume(ctx context.Context, b Batch) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.spans = append(cs.spans, trace.SpanContextFromContext(ctx))
+	return nil
+}
+
+func TestBatcher_Tracing(t *testing.T) {
+	recorder := recordSpans()
+
+	cfg := &config.Config{
+		BatchMaxSamples: 2,
+		BatchMaxSpanMS:  30000,
+		FlushIntervalMS: 500,
+		DropTooOldMS:    30000,
+	}
+	sink := &ContextSink{}
+	batcher := NewBatcher(cfg, sink)
+	defer batcher.Stop()
+
+	// Спан потока PushSamples
+	stream := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+	ctx := trace.ContextWithRemoteSpanContext(context.Background(), stream)
+
+	for _, ts := range []uint64{1000, 1250} {
+		sample := &telemetryv1.Sample{SessionId: "traced", TsMs: ts, Metric: telemetryv1.Metric_METRIC_FHR, Value: 140}
+		if err := batcher.AddContext(ctx, sample); err != nil {
+			t.Fatalf("Failed to add sample: %v", err)
+		}
+	}
+
+	collect := waitSpan(t, recorder, "Batcher.collect", "traced")
+	deliver := waitSpan(t, recorder, "Batcher.deliver", "traced")
+
+	// Батч начинает собственную трассу, связанную с потоком
+	if collect.Parent().IsValid() || collect.SpanContext().TraceID() == stream.TraceID() {
+		t.Errorf("Expected batch span to be a new root, got parent %v", collect.Parent())
+	}
+	if links := collect.Links(); len(links) != 1 || !links[0].SpanContext.Equal(stream) {
+		t.Errorf("Expected link to stream span, got %+v", links)
+	}
+
+	if deliver.Parent().SpanID() != collect.SpanContext().SpanID() ||
+		deliver.SpanContext().TraceID() != collect.SpanContext().TraceID() {
+		t.Errorf("Expected deliver span to be a child of batch span")
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if len(sink.spans) != 1 || !sink.spans[0].Equal(deliver.SpanContext()) {
+		t.Errorf("Expected sink to be called within deliver span, got %+v", sink.spans)
+	}
+}
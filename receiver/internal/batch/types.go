@@ -8,11 +8,13 @@ import (
 
 // Point представляет одну точку данных
 type Point struct {
-	TsMS  int64   // Временная метка в миллисекундах
-	Value float32 // Значение измерения
+	TsMS   int64              // Временная метка в миллисекундах
+	Value  float32            // Значение измерения
+	Metric telemetryv1.Metric // Канал точки (важен для совместных батчей)
 }
 
-// BatchKey уникально идентифицирует батч по сессии и метрике
+// BatchKey уникально идентифицирует батч по сессии и метрике.
+// В режиме joint Metric = METRIC_UNSPECIFIED, батч содержит оба канала.
 type BatchKey struct {
 	SessionID string             // Идентификатор сессии
 	Metric    telemetryv1.Metric // Тип метрики (FHR или UC)
@@ -24,6 +26,9 @@ type Batch struct {
 	T0MS   int64    // Время первой точки в батче
 	T1MS   int64    // Время последней точки в батче
 	Points []Point  // Точки данных в батче
+
+	// Контекст трассировки батча (W3C traceparent/tracestate), см. trace.go
+	Trace map[string]string `json:",omitempty"`
 }
 
 // Sink интерфейс для обработки готовых батчей
@@ -34,7 +39,8 @@ type Sink interface {
 // currentBatch - внутренняя структура для отслеживания текущего состояния батча
 type currentBatch struct {
 	Batch
-	lastAddedMS int64 // Время последнего добавления точки
+	lastAddedMS int64        // Время последнего добавления точки
+	sources     batchSources // Для корневого спана батча
 }
 
 // newCurrentBatch создает новый текущий батч
@@ -0,0 +1,331 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// TestMain задает ключ подписи JWT: без него конфигурация по умолчанию не проходит проверку
+func TestMain(m *testing.M) {
+	os.Setenv("AUTH_JWT_SECRET", "test-secret-test-secret-test-secret")
+	os.Exit(m.Run())
+}
+
+// writeFile записывает файл конфигурации во временный каталог теста
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+// rewriteFile меняет файл и время его изменения (файловые системы с грубой меткой времени)
+func rewriteFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("Failed to touch config file: %v", err)
+	}
+}
+
+func TestLoadFile_Defaults(t *testing.T) {
+	cfg, err := LoadFile("")
+	if err != nil {
+		t.Fatalf("Defaults must be valid: %v", err)
+	}
+	if cfg.BatchMaxSamples != 2 || cfg.OutOfOrderTolerance != 250*time.Millisecond ||
+		cfg.AlertSTVDuration != 10*time.Minute || cfg.BatchMode != BatchModePerMetric ||
+		!cfg.MigrateOnStart || cfg.AlertSTVLow != 3.0 || !cfg.DeviceAuthEnabled {
+		t.Errorf("Unexpected defaults: %+v", cfg)
+	}
+}
+
+func TestLoadFile_Precedence(t *testing.T) {
+	path := writeFile(t, `
+batch:
+  max_samples: 8
+  max_span_ms: 500
+alerts:
+  stv_duration_sec: 120
+redis:
+  password:
+`)
+	t.Setenv("BATCH_MAX_SPAN_MS", "1000")
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.BatchMaxSamples != 8 {
+		t.Errorf("Expected file value 8, got %d", cfg.BatchMaxSamples)
+	}
+	if cfg.BatchMaxSpanMS != 1000 {
+		t.Errorf("Expected env to override file, got %d", cfg.BatchMaxSpanMS)
+	}
+	if cfg.AlertSTVDuration != 2*time.Minute {
+		t.Errorf("Expected 2m, got %v", cfg.AlertSTVDuration)
+	}
+	if cfg.FlushIntervalMS != 250 || cfg.File != path {
+		t.Errorf("Expected default flush interval and file path, got %+v", cfg)
+	}
+}
+
+func TestLoadFile_Errors(t *testing.T) {
+	path := writeFile(t, `batch:
+  max_sample: 8
+  max_span_ms: fast
+alerts:
+  enabled: [true]
+`)
+	t.Setenv("ALERT_STV_LOW", "low")
+
+	_, err := LoadFile(path)
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+	for _, want := range []string{
+		path + `:2: batch.max_sample: unknown key`,
+		path + `:3: batch.max_span_ms: invalid integer "fast"`,
+		path + `:5: alerts.enabled: expected a single value`,
+		`ALERT_STV_LOW: invalid number "low"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to contain %q, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoadFile_Validation(t *testing.T) {
+	path := writeFile(t, `
+batch:
+  max_samples: 0
+  mode: mixed
+alerts:
+  tachycardia_bpm: 100
+`)
+	_, err := LoadFile(path)
+	if err == nil {
+		t.Fatal("Expected validation error")
+	}
+	for _, want := range []string{
+		"batch.max_samples (BATCH_MAX_SAMPLES): must be at least 1, got 0",
+		`batch.mode (BATCH_MODE): must be one of per_metric, joint, got "mixed"`,
+		"alerts.tachycardia_bpm (ALERT_TACHYCARDIA_BPM): must be greater than alerts.bradycardia_bpm, got 100",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to contain %q, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoadFile_AuthFailsClosed(t *testing.T) {
+	t.Setenv("AUTH_JWT_SECRET", "")
+
+	_, err := LoadFile("")
+	if err == nil || !strings.Contains(err.Error(), "auth.jwt_secret (AUTH_JWT_SECRET): is required") {
+		t.Errorf("Expected missing key to fail, got %v", err)
+	}
+
+	// Выключить аутентификацию можно только вместе с явным режимом разработки
+	t.Setenv("AUTH_ENABLED", "false")
+	_, err = LoadFile("")
+	if err == nil || !strings.Contains(err.Error(), "auth.enabled (AUTH_ENABLED): may be false only with auth.insecure_dev=true") {
+		t.Errorf("Expected disabled auth without dev override to fail, got %v", err)
+	}
+
+	t.Setenv("AUTH_INSECURE_DEV", "true")
+	cfg, err := LoadFile("")
+	if err != nil {
+		t.Fatalf("Dev override must allow disabled auth: %v", err)
+	}
+	if cfg.CORSAllowedOrigins != "" {
+		t.Errorf("Expected no allowed CORS origins by default, got %q", cfg.CORSAllowedOrigins)
+	}
+}
+
+func TestLoadFile_DeviceAuthFailsClosed(t *testing.T) {
+	// Реестр устройств выключается, как и JWT, только вместе с явным режимом разработки
+	t.Setenv("DEVICE_AUTH_ENABLED", "false")
+	_, err := LoadFile("")
+	if err == nil || !strings.Contains(err.Error(), "device.auth_enabled (DEVICE_AUTH_ENABLED): may be false only with auth.insecure_dev=true") {
+		t.Errorf("Expected disabled device auth without dev override to fail, got %v", err)
+	}
+
+	t.Setenv("AUTH_INSECURE_DEV", "true")
+	cfg, err := LoadFile("")
+	if err != nil {
+		t.Fatalf("Dev override must allow disabled device auth: %v", err)
+	}
+	if cfg.DeviceAuthEnabled || !cfg.AuthEnabled {
+		t.Errorf("Expected only device auth to be disabled, got device=%v auth=%v", cfg.DeviceAuthEnabled, cfg.AuthEnabled)
+	}
+}
+
+func TestLoadFile_Example(t *testing.T) {
+	if _, err := LoadFile("../../config.example.yaml"); err != nil {
+		t.Errorf("Example config must be valid: %v", err)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	t.Setenv("AUTH_JWT_SECRET", "top-secret")
+	t.Setenv("POSTGRES_DSN", "postgres://user:pass@db:5432/fetal?sslmode=disable")
+
+	cfg, err := LoadFile("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	view := cfg.Redacted()
+
+	if got := view["auth"]["jwt_secret"]; got != redacted {
+		t.Errorf("Expected secret to be redacted, got %v", got)
+	}
+	if got := view["redis"]["password"]; got != "" {
+		t.Errorf("Expected empty secret to stay empty, got %v", got)
+	}
+	if got := view["postgres"]["dsn"]; got != "postgres://user:xxxxx@db:5432/fetal?sslmode=disable" {
+		t.Errorf("Expected DSN password to be redacted, got %v", got)
+	}
+	if got := view["batch"]["out_of_order_tolerance_ms"]; got != int64(250) {
+		t.Errorf("Expected duration in file units, got %v", got)
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	tests := []struct {
+		raw, want string
+	}{
+		{"postgres://user:pass@db:5432/fetal", "postgres://user:xxxxx@db:5432/fetal"},
+		{"postgres://db/fetal?user=app&password=secret&sslmode=disable", "postgres://db/fetal?user=app&password=xxxxx&sslmode=disable"},
+		{"postgres://db/fetal?sslmode=verify-full&sslpassword=key-pass", "postgres://db/fetal?sslmode=verify-full&sslpassword=xxxxx"},
+		{"postgres://db/fetal?PASSWORD", "postgres://db/fetal?PASSWORD=xxxxx"},
+		{"postgres://db/fetal", "postgres://db/fetal"},
+		{"host=db password=secret", redacted},
+	}
+	for _, tt := range tests {
+		if got := redactURL(tt.raw); got != tt.want {
+			t.Errorf("redactURL(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestReloader_Reload(t *testing.T) {
+	path := writeFile(t, "batch:\n  max_samples: 2\nserver:\n  http_port: \"8080\"\n")
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	reloader := NewReloader(cfg)
+	var applied []*Config
+	reloader.OnReload(func(cfg *Config) { applied = append(applied, cfg) })
+
+	// Безопасное поле применяется, поле с перезапуском - нет
+	rewriteFile(t, path, "batch:\n  max_samples: 6\nserver:\n  http_port: \"9090\"\nlog:\n  level: debug\n")
+	changed, err := reloader.Reload()
+	if err != nil {
+		t.Fatalf("Failed to reload: %v", err)
+	}
+	if strings.Join(changed, ",") != "batch.max_samples,log.level" {
+		t.Errorf("Unexpected changed keys: %v", changed)
+	}
+	current := reloader.Current()
+	if current.BatchMaxSamples != 6 || current.LogLevel != "debug" || current.HTTPPort != "8080" {
+		t.Errorf("Unexpected config after reload: %+v", current)
+	}
+	if cfg.BatchMaxSamples != 2 {
+		t.Error("Reload must not modify previous config")
+	}
+	if len(applied) != 1 || applied[0] != current {
+		t.Errorf("Expected listener to get new config once, got %d calls", len(applied))
+	}
+
+	// Неверный файл не применяется
+	rewriteFile(t, path, "batch:\n  max_samples: -1\n")
+	if _, err := reloader.Reload(); err == nil {
+		t.Error("Expected error for invalid config")
+	}
+	if reloader.Current() != current || len(applied) != 1 {
+		t.Error("Invalid config must not be applied")
+	}
+}
+
+func TestReloader_ValidatesMergedConfig(t *testing.T) {
+	path := writeFile(t, "batch:\n  max_samples: 2\n")
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	// Действующее значение поля без reload, которое новый файл не исправит до перезапуска
+	cfg.RetryMaxDelay = cfg.RetryBaseDelay - 1
+	reloader := NewReloader(cfg)
+
+	rewriteFile(t, path, "batch:\n  max_samples: 4\n")
+	if _, err := reloader.Reload(); err == nil || !strings.Contains(err.Error(), "queue.retry_max_delay_ms") {
+		t.Errorf("Expected merged config to fail validation, got %v", err)
+	}
+	if reloader.Current() != cfg {
+		t.Error("Invalid merged config must not be applied")
+	}
+}
+
+func TestReloader_FileChanged(t *testing.T) {
+	path := writeFile(t, "batch:\n  max_samples: 2\n")
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	reloader := NewReloader(cfg)
+
+	if reloader.fileChanged() {
+		t.Error("Expected unchanged file")
+	}
+	rewriteFile(t, path, "batch:\n  max_samples: 3\n")
+	if !reloader.fileChanged() {
+		t.Error("Expected file change to be detected")
+	}
+	if reloader.fileChanged() {
+		t.Error("Expected change to be reported once")
+	}
+}
+
+func TestHTTPHandler_GetConfig(t *testing.T) {
+	t.Setenv("REDIS_PASSWORD", "hunter2")
+	cfg, err := LoadFile("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	router := mux.NewRouter()
+	NewHTTPHandler(NewReloader(cfg)).RegisterRoutes(router)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/config", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "hunter2") {
+		t.Error("Response must not contain secrets")
+	}
+
+	var resp Effective
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Config["batch"]["max_samples"] != float64(2) || len(resp.Reloadable) == 0 {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Krimson/fetal-monitory/logging"
+)
+
+// Effective - действующая конфигурация для GET /api/config
+type Effective struct {
+	File       string                            `json:"file,omitempty"` // Файл конфигурации (CONFIG_FILE)
+	LoadedAt   time.Time                         `json:"loaded_at"`      // Время последнего применения
+	Reloadable []string                          `json:"reloadable"`     // Ключи, которые меняются без перезапуска
+	Config     map[string]map[string]interface{} `json:"config"`         // Значения по секциям файла, секреты скрыты
+}
+
+// HTTPHandler отдает действующую конфигурацию (Presentation Layer)
+type HTTPHandler struct {
+	reloader *Reloader
+}
+
+// NewHTTPHandler создает новый HTTP обработчик
+func NewHTTPHandler(reloader *Reloader) *HTTPHandler {
+	return &HTTPHandler{
+		reloader: reloader,
+	}
+}
+
+// RegisterRoutes регистрирует маршруты в роутере
+func (h *HTTPHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/api/config", h.GetConfig).Methods("GET", "OPTIONS")
+}
+
+// GetConfig возвращает действующую конфигурацию
+// @Summary Действующая конфигурация
+// @Description Значения после умолчаний, файла конфигурации и переменных окружения, с учетом перезагрузок. Секреты скрыты
+// @Tags Config
+// @Produce json
+// @Success 200 {object} Effective "Конфигурация"
+// @Security BearerAuth
+// @Router /api/config [get]
+func (h *HTTPHandler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	cfg := h.reloader.Current()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(Effective{
+		File:       cfg.File,
+		LoadedAt:   h.reloader.LoadedAt(),
+		Reloadable: ReloadableKeys(),
+		Config:     cfg.Redacted(),
+	}); err != nil {
+		slog.Error("Failed to encode JSON response", logging.Err(err))
+	}
+}
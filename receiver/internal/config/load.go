@@ -0,0 +1,388 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted заменяет значения секретов в GET /api/config
+const redacted = "[REDACTED]"
+
+// field - описание настройки из тегов поля Config
+type field struct {
+	index  int
+	name   string        // Имя поля Config
+	env    string        // Переменная окружения
+	key    string        // Ключ в файле: секция.имя
+	def    string        // Значение по умолчанию
+	unit   time.Duration // Единица числа для time.Duration
+	reload bool          // Меняется без перезапуска
+	secret string        // "true" - скрывается целиком, "url" - скрывается пароль в URL
+}
+
+var (
+	fields       = parseFields()
+	fieldsByName = make(map[string]*field)
+	fieldsByKey  = make(map[string]*field)
+)
+
+func init() {
+	for i := range fields {
+		fieldsByName[fields[i].name] = &fields[i]
+		fieldsByKey[fields[i].key] = &fields[i]
+	}
+}
+
+func parseFields() []field {
+	t := reflect.TypeOf(Config{})
+	var result []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		env, ok := sf.Tag.Lookup("env")
+		if !ok {
+			continue
+		}
+		f := field{
+			index:  i,
+			name:   sf.Name,
+			env:    env,
+			key:    sf.Tag.Get("file"),
+			def:    sf.Tag.Get("default"),
+			reload: sf.Tag.Get("reload") == "true",
+			secret: sf.Tag.Get("secret"),
+		}
+		switch sf.Tag.Get("unit") {
+		case "ms":
+			f.unit = time.Millisecond
+		case "s":
+			f.unit = time.Second
+		}
+		result = append(result, f)
+	}
+	return result
+}
+
+// value возвращает поле cfg для чтения и записи
+func (f *field) value(cfg *Config) reflect.Value {
+	return reflect.ValueOf(cfg).Elem().Field(f.index)
+}
+
+// set разбирает raw в поле cfg
+func (f *field) set(cfg *Config, raw string) error {
+	v := f.value(cfg)
+	raw = strings.TrimSpace(raw)
+
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(n * int64(f.unit))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		v.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q (expected true or false)", raw)
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+// display возвращает значение в единицах файла конфигурации, секреты скрыты
+func (f *field) display(cfg *Config) interface{} {
+	v := f.value(cfg)
+	if f.unit != 0 {
+		return int64(v.Interface().(time.Duration) / f.unit)
+	}
+	if f.secret != "" && v.String() != "" {
+		if f.secret == "url" {
+			return redactURL(v.String())
+		}
+		return redacted
+	}
+	return v.Interface()
+}
+
+// describe - имя настройки в сообщениях об ошибках
+func (f *field) describe() string {
+	return fmt.Sprintf("%s (%s)", f.key, f.env)
+}
+
+// redactURL скрывает пароль в URL - в userinfo и в параметрах запроса (lib/pq и pgx
+// принимают ?password=...); строки, которые не разбираются как URL, скрываются целиком
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" {
+		return redacted
+	}
+
+	// Параметры разбираются вручную, чтобы сохранить их порядок
+	params := strings.Split(u.RawQuery, "&")
+	for i, param := range params {
+		name, _, _ := strings.Cut(param, "=")
+		if key, err := url.QueryUnescape(name); err != nil || isPasswordParam(key) {
+			params[i] = name + "=xxxxx"
+		}
+	}
+	u.RawQuery = strings.Join(params, "&")
+	return u.Redacted()
+}
+
+// isPasswordParam - параметр URL с паролем (password, sslpassword и т.п.)
+func isPasswordParam(name string) bool {
+	return strings.Contains(strings.ToLower(name), "password")
+}
+
+// Load загружает конфигурацию: значения по умолчанию, файл CONFIG_FILE (если задан),
+// затем переменные окружения
+func Load() (*Config, error) {
+	return LoadFile(os.Getenv("CONFIG_FILE"))
+}
+
+// LoadFile загружает конфигурацию с файлом path (пусто - без файла). Ошибка перечисляет
+// все неверные значения с указанием, откуда они взяты
+func LoadFile(path string) (*Config, error) {
+	cfg := &Config{File: path}
+	for i := range fields {
+		if err := fields[i].set(cfg, fields[i].def); err != nil {
+			return nil, fmt.Errorf("default of %s: %w", fields[i].describe(), err)
+		}
+	}
+
+	var errs []error
+	if path != "" {
+		if err := applyFile(cfg, path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, applyEnv(cfg)...)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyEnv применяет непустые переменные окружения
+func applyEnv(cfg *Config) []error {
+	var errs []error
+	for i := range fields {
+		f := &fields[i]
+		raw := os.Getenv(f.env)
+		if raw == "" {
+			continue
+		}
+		if err := f.set(cfg, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.env, err))
+		}
+	}
+	return errs
+}
+
+// applyFile применяет YAML файл конфигурации. Файл состоит из секций с ключами
+// (batch.max_samples - ключ max_samples в секции batch); неизвестные ключи - ошибка,
+// чтобы опечатка не оставляла значение по умолчанию незаметно
+func applyFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil // Пустой файл
+	}
+
+	var errs []error
+	walkFile(doc.Content[0], "", func(key string, node *yaml.Node, err error) {
+		if err == nil {
+			f, ok := fieldsByKey[key]
+			switch {
+			case !ok:
+				err = fmt.Errorf("unknown key")
+			case node.Tag == "!!null":
+				return // Ключ без значения - остается значение по умолчанию
+			default:
+				err = f.set(cfg, node.Value)
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %s: %w", path, node.Line, key, err))
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// walkFile обходит секции файла и вызывает fn для каждого скалярного значения
+func walkFile(node *yaml.Node, prefix string, fn func(key string, node *yaml.Node, err error)) {
+	if node.Kind != yaml.MappingNode {
+		fn(strings.TrimSuffix(prefix, "."), node, fmt.Errorf("expected a mapping of keys"))
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := prefix + node.Content[i].Value
+		value := node.Content[i+1]
+		switch value.Kind {
+		case yaml.MappingNode:
+			walkFile(value, key+".", fn)
+		case yaml.ScalarNode:
+			fn(key, value, nil)
+		default:
+			fn(key, value, fmt.Errorf("expected a single value"))
+		}
+	}
+}
+
+// Validate проверяет значения настроек; ошибка перечисляет все неверные поля
+func (c *Config) Validate() error {
+	v := &validator{cfg: c}
+
+	v.check(c.GRPCPort != "", "GRPCPort", "must not be empty")
+	v.check(c.HTTPPort != "", "HTTPPort", "must not be empty")
+	v.check(c.ConfigWatchInterval >= 0, "ConfigWatchInterval", "must not be negative")
+
+	v.check(c.BatchMaxSamples >= 1, "BatchMaxSamples", "must be at least 1")
+	v.check(c.BatchMaxSpanMS >= 1, "BatchMaxSpanMS", "must be at least 1")
+	v.check(c.FlushIntervalMS >= 1, "FlushIntervalMS", "must be at least 1")
+	v.check(c.AckEveryN >= 1, "AckEveryN", "must be at least 1")
+	v.check(c.OutOfOrderTolerance >= 0, "OutOfOrderTolerance", "must not be negative")
+	v.check(c.DropTooOldMS >= 0, "DropTooOldMS", "must not be negative")
+	v.oneOf("BatchMode", c.BatchMode, BatchModePerMetric, BatchModeJoint)
+	v.check(c.JointMaxWait >= 0, "JointMaxWait", "must not be negative")
+
+	v.check(c.QueueMaxDepth >= 1, "QueueMaxDepth", "must be at least 1")
+	v.check(c.RetryBaseDelay >= 0, "RetryBaseDelay", "must not be negative")
+	v.check(c.RetryMaxDelay >= c.RetryBaseDelay, "RetryMaxDelay", "must not be less than queue.retry_base_delay_ms")
+	v.check(c.RetryMaxAttempts >= 0, "RetryMaxAttempts", "must not be negative")
+
+	v.check(c.SessionDataTTLSeconds >= 1, "SessionDataTTLSeconds", "must be at least 1")
+	v.check(c.RawChunkSamples >= 1, "RawChunkSamples", "must be at least 1")
+	v.check(c.RawFlushInterval > 0, "RawFlushInterval", "must be positive")
+	v.check(c.ReplayMaxSpeed > 0, "ReplayMaxSpeed", "must be positive")
+
+	v.check(c.AlertSTVLow > 0, "AlertSTVLow", "must be positive")
+	v.check(c.AlertSTVHysteresis >= 0, "AlertSTVHysteresis", "must not be negative")
+	v.check(c.AlertSTVDuration >= 0, "AlertSTVDuration", "must not be negative")
+	v.check(c.AlertBradycardiaBPM > 0, "AlertBradycardiaBPM", "must be positive")
+	v.check(c.AlertTachycardiaBPM > c.AlertBradycardiaBPM, "AlertTachycardiaBPM", "must be greater than alerts.bradycardia_bpm")
+	v.check(c.AlertBPMHysteresis >= 0, "AlertBPMHysteresis", "must not be negative")
+	v.check(c.AlertBaselineDuration >= 0, "AlertBaselineDuration", "must not be negative")
+	v.check(c.AlertLateDecelCount >= 1, "AlertLateDecelCount", "must be at least 1")
+	v.check(c.AlertLateDecelWindow > 0, "AlertLateDecelWindow", "must be positive")
+	v.check(c.AlertPredictionCutoff > 0 && c.AlertPredictionCutoff <= 1, "AlertPredictionCutoff", "must be in (0, 1]")
+	v.check(c.AlertPredictionHysteresis >= 0 && c.AlertPredictionHysteresis < c.AlertPredictionCutoff,
+		"AlertPredictionHysteresis", "must be in [0, alerts.prediction_cutoff)")
+	v.check(c.AlertClearDelay >= 0, "AlertClearDelay", "must not be negative")
+
+	// Аутентификация пользователей и устройств включена по умолчанию и выключается только явно для разработки
+	v.check(c.AuthEnabled || c.AuthInsecureDev, "AuthEnabled", "may be false only with auth.insecure_dev=true (local development)")
+	v.check(!c.AuthEnabled || c.AuthJWTSecret != "" || c.AuthJWTSecretFile != "", "AuthJWTSecret",
+		"is required when auth.enabled is true (or set auth.jwt_secret_file)")
+	v.check(c.DeviceAuthEnabled || c.AuthInsecureDev, "DeviceAuthEnabled", "may be false only with auth.insecure_dev=true (local development)")
+
+	v.oneOf("FeatureExtractorMode", c.FeatureExtractorMode, FeatureExtractorModeUnary, FeatureExtractorModeStream)
+	v.check(c.HealthCheckInterval > 0, "HealthCheckInterval", "must be positive")
+	v.check(c.HealthCheckTimeout > 0, "HealthCheckTimeout", "must be positive")
+
+	v.oneOf("TracingExporter", c.TracingExporter, TracingExporterNone, TracingExporterStdout, TracingExporterOTLP)
+	v.check(c.TracingSampleRatio >= 0 && c.TracingSampleRatio <= 1, "TracingSampleRatio", "must be in [0, 1]")
+
+	v.oneOf("LogLevel", strings.ToLower(c.LogLevel), "debug", "info", "warn", "warning", "error")
+	v.oneOf("LogFormat", strings.ToLower(c.LogFormat), "text", "json")
+	v.check(c.LogDebugSample >= 0, "LogDebugSample", "must not be negative")
+
+	return v.err()
+}
+
+// validator собирает ошибки проверки
+type validator struct {
+	cfg  *Config
+	errs []error
+}
+
+func (v *validator) check(ok bool, name, message string) {
+	if ok {
+		return
+	}
+	f := fieldsByName[name]
+	value := f.display(v.cfg)
+	if s, isString := value.(string); isString {
+		value = strconv.Quote(s)
+	}
+	v.errs = append(v.errs, fmt.Errorf("%s: %s, got %v", f.describe(), message, value))
+}
+
+func (v *validator) oneOf(name, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.check(false, name, "must be one of "+strings.Join(allowed, ", "))
+}
+
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration:\n%w", errors.Join(v.errs...))
+}
+
+// Redacted возвращает настройки по секциям файла конфигурации со скрытыми секретами
+func (c *Config) Redacted() map[string]map[string]interface{} {
+	result := make(map[string]map[string]interface{})
+	for i := range fields {
+		f := &fields[i]
+		section, name, _ := strings.Cut(f.key, ".")
+		if result[section] == nil {
+			result[section] = make(map[string]interface{})
+		}
+		result[section][name] = f.display(c)
+	}
+	return result
+}
+
+// ReloadableKeys возвращает ключи настроек, которые меняются без перезапуска
+func ReloadableKeys() []string {
+	var keys []string
+	for i := range fields {
+		if fields[i].reload {
+			keys = append(keys, fields[i].key)
+		}
+	}
+	return keys
+}
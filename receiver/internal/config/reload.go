@@ -0,0 +1,181 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Krimson/fetal-monitory/logging"
+)
+
+// Reloader хранит действующую конфигурацию и перечитывает ее без перезапуска:
+// по SIGHUP и при изменении файла конфигурации. Меняются только поля с тегом
+// reload (батчинг, пороги тревог, уровень журнала); изменения остальных полей
+// записываются в журнал и вступают в силу после перезапуска. Неверный файл
+// не применяется - продолжает действовать прежняя конфигурация
+type Reloader struct {
+	path     string     // Файл конфигурации (Config.File), не меняется при перезагрузке
+	reloadMu sync.Mutex // Перезагрузки выполняются по одной
+
+	mu        sync.RWMutex
+	current   *Config
+	loadedAt  time.Time
+	listeners []func(*Config)
+
+	// Состояние файла при последней проверке (для отслеживания изменений)
+	modTime time.Time
+	size    int64
+}
+
+// NewReloader создает Reloader для конфигурации, загруженной Load
+func NewReloader(cfg *Config) *Reloader {
+	r := &Reloader{
+		path:     cfg.File,
+		current:  cfg,
+		loadedAt: time.Now(),
+	}
+	r.modTime, r.size = r.stat()
+	return r
+}
+
+// OnReload регистрирует обработчик новой конфигурации; вызывается до Watch
+func (r *Reloader) OnReload(fn func(*Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners = append(r.listeners, fn)
+}
+
+// Current возвращает действующую конфигурацию. Возвращенное значение не меняется:
+// при перезагрузке создается новый Config
+func (r *Reloader) Current() *Config {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current
+}
+
+// LoadedAt возвращает время последнего применения конфигурации
+func (r *Reloader) LoadedAt() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.loadedAt
+}
+
+// Reload перечитывает файл и окружение и применяет изменившиеся поля с тегом reload.
+// Возвращает ключи примененных изменений
+func (r *Reloader) Reload() ([]string, error) {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	current := r.Current()
+	next, err := LoadFile(r.path)
+	if err != nil {
+		slog.Error("Configuration reload rejected, keeping current configuration", logging.Err(err))
+		return nil, err
+	}
+
+	merged := *current
+	var changed, restart []string
+	for i := range fields {
+		f := &fields[i]
+		if reflect.DeepEqual(f.value(current).Interface(), f.value(next).Interface()) {
+			continue
+		}
+		if !f.reload {
+			restart = append(restart, f.key)
+			continue
+		}
+		f.value(&merged).Set(f.value(next))
+		changed = append(changed, f.key)
+	}
+
+	if len(restart) > 0 {
+		slog.Warn("Configuration changes require restart, not applied", "keys", restart)
+	}
+	if len(changed) == 0 {
+		slog.Info("Configuration reloaded, no changes to apply")
+		return nil, nil
+	}
+
+	// Проверяется итоговая конфигурация: новые значения применяются вместе с
+	// действующими значениями полей, требующих перезапуска
+	if err := merged.Validate(); err != nil {
+		slog.Error("Configuration reload rejected, keeping current configuration", logging.Err(err))
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.current = &merged
+	r.loadedAt = time.Now()
+	listeners := r.listeners
+	r.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(&merged)
+	}
+	slog.Info("Configuration reloaded", "changed", changed)
+	return changed, nil
+}
+
+// Watch перечитывает конфигурацию по SIGHUP и при изменении файла (проверка
+// раз в ConfigWatchInterval); возвращается при отмене ctx
+func (r *Reloader) Watch(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var poll <-chan time.Time
+	if interval := r.Current().ConfigWatchInterval; r.path != "" && interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-hup:
+			slog.Info("Received SIGHUP, reloading configuration")
+			r.Reload()
+
+		case <-poll:
+			if r.fileChanged() {
+				slog.Info("Configuration file changed, reloading", "file", r.path)
+				r.Reload()
+			}
+		}
+	}
+}
+
+// fileChanged сравнивает время изменения и размер файла с предыдущей проверкой
+func (r *Reloader) fileChanged() bool {
+	modTime, size := r.stat()
+	if modTime.IsZero() {
+		return false // Файл временно недоступен (например, заменяется)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if modTime.Equal(r.modTime) && size == r.size {
+		return false
+	}
+	r.modTime, r.size = modTime, size
+	return true
+}
+
+func (r *Reloader) stat() (time.Time, int64) {
+	if r.path == "" {
+		return time.Time{}, 0
+	}
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return time.Time{}, 0
+	}
+	return info.ModTime(), info.Size()
+}
@@ -0,0 +1,337 @@
+package ctg
+
+import (
+	"fmt"
+	"sort"
+
+	featureextractorv1 "github.com/Krimson/fetal-monitory/proto/feature_extractor"
+)
+
+// Guideline - руководство, по которому выполняется классификация
+const Guideline = "FIGO 2015 / NICE 2017"
+
+// Category - категория КТГ
+type Category string
+
+const (
+	CategoryNormal       Category = "normal"
+	CategorySuspicious   Category = "suspicious"
+	CategoryPathological Category = "pathological"
+	CategoryUnknown      Category = "insufficient_data" // Нет базальной ЧСС - классифицировать нечего
+)
+
+// Критерии классификации
+const (
+	CriterionBaseline      = "baseline"
+	CriterionVariability   = "variability"
+	CriterionDecelerations = "decelerations"
+	CriterionAccelerations = "accelerations"
+	CriterionSTV           = "stv"
+)
+
+// Пороги руководств
+const (
+	baselineNormalLow        = 110.0 // уд/мин
+	baselineNormalHigh       = 160.0
+	baselinePathologicalLow  = 100.0
+	baselinePathologicalHigh = 180.0
+
+	variabilityNormalLow  = 5.0 // амплитуда, уд/мин
+	variabilityNormalHigh = 25.0
+
+	reducedVariabilityPathologicalSec   = 50 * 60 // Сниженная вариабельность дольше 50 минут
+	increasedVariabilityPathologicalSec = 30 * 60 // Повышенная вариабельность дольше 30 минут
+	repetitiveDecelPathologicalSec      = 30 * 60 // Повторяющиеся поздние децелерации дольше 30 минут
+
+	// Перерыв между поздними децелерациями, после которого эпизод повторяющихся
+	// децелераций считается прерванным (схватки идут каждые 2-5 минут)
+	repetitiveDecelMaxGapSec = 10 * 60
+
+	prolongedDecelerationSec = 5 * 60 // Пролонгированная децелерация дольше 5 минут
+
+	stvSuspicious   = 3.0 // мс, критерии Dawes-Redman
+	stvPathological = 2.6
+)
+
+// sampleRateHz - частота ЧСС; в ее отсчетах feature extractor возвращает начало и конец событий
+const sampleRateHz = 4.0
+
+// Deceleration - децелерация, необходимая для классификации
+type Deceleration struct {
+	StartSec  float64 // От начала записи
+	EndSec    float64
+	Duration  float64 // секунды
+	Amplitude float64 // уд/мин
+	IsLate    bool
+}
+
+// Input - признаки КТГ для классификации
+type Input struct {
+	BaselineHeartRate  float64   // уд/мин
+	STV                float64   // мс
+	LTV                float64   // мс (размах RR-интервалов за минуту)
+	LTVWindows         []float64 // LTV по окнам от начала записи, мс
+	LTVWindowSec       float64   // Длительность окна LTV
+	TotalAccelerations int32
+	TotalContractions  int32
+	LateDecelerations  int32
+	Decelerations      []Deceleration
+}
+
+// Criterion - оценка одного критерия с обоснованием
+type Criterion struct {
+	Name     string   `json:"name"`
+	Category Category `json:"category"`
+	Value    float64  `json:"value"`
+	Reason   string   `json:"reason"`
+}
+
+// Classification - итоговая категория КТГ и оценки по критериям
+type Classification struct {
+	Category  Category    `json:"category"`
+	Guideline string      `json:"guideline"`
+	Criteria  []Criterion `json:"criteria"`
+}
+
+// Classify детерминированно относит КТГ к категории normal / suspicious / pathological.
+// Итоговая категория - худшая из категорий критериев
+func Classify(in Input) *Classification {
+	result := &Classification{
+		Category:  CategoryNormal,
+		Guideline: Guideline,
+	}
+
+	if in.BaselineHeartRate <= 0 {
+		result.Category = CategoryUnknown
+		result.Criteria = []Criterion{{
+			Name:     CriterionBaseline,
+			Category: CategoryUnknown,
+			Reason:   "Базальная ЧСС еще не определена",
+		}}
+		return result
+	}
+
+	result.Criteria = []Criterion{
+		classifyBaseline(in.BaselineHeartRate),
+		classifyVariability(in),
+		classifyDecelerations(in),
+		classifyAccelerations(in.TotalAccelerations),
+	}
+	if in.STV > 0 {
+		result.Criteria = append(result.Criteria, classifySTV(in.STV))
+	}
+
+	for _, c := range result.Criteria {
+		if severity(c.Category) > severity(result.Category) {
+			result.Category = c.Category
+		}
+	}
+
+	return result
+}
+
+func severity(c Category) int {
+	switch c {
+	case CategoryPathological:
+		return 2
+	case CategorySuspicious:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func classifyBaseline(bpm float64) Criterion {
+	c := Criterion{Name: CriterionBaseline, Value: bpm}
+
+	switch {
+	case bpm < baselinePathologicalLow:
+		c.Category = CategoryPathological
+		c.Reason = fmt.Sprintf("Базальная ЧСС %.0f уд/мин ниже %.0f (брадикардия)", bpm, baselinePathologicalLow)
+	case bpm > baselinePathologicalHigh:
+		c.Category = CategoryPathological
+		c.Reason = fmt.Sprintf("Базальная ЧСС %.0f уд/мин выше %.0f (выраженная тахикардия)", bpm, baselinePathologicalHigh)
+	case bpm < baselineNormalLow:
+		c.Category = CategorySuspicious
+		c.Reason = fmt.Sprintf("Базальная ЧСС %.0f уд/мин ниже нормы %.0f-%.0f", bpm, baselineNormalLow, baselineNormalHigh)
+	case bpm > baselineNormalHigh:
+		c.Category = CategorySuspicious
+		c.Reason = fmt.Sprintf("Базальная ЧСС %.0f уд/мин выше нормы %.0f-%.0f", bpm, baselineNormalLow, baselineNormalHigh)
+	default:
+		c.Category = CategoryNormal
+		c.Reason = fmt.Sprintf("Базальная ЧСС %.0f уд/мин в норме", bpm)
+	}
+
+	return c
+}
+
+// VariabilityBPM переводит LTV (размах RR-интервалов в мс) в амплитуду вариабельности в уд/мин
+// около базальной ЧСС: ЧСС = 60000 / RR, поэтому dЧСС ≈ dRR * ЧСС² / 60000
+func VariabilityBPM(ltvMS, baselineBPM float64) float64 {
+	return ltvMS * baselineBPM * baselineBPM / 60000
+}
+
+func classifyVariability(in Input) Criterion {
+	amplitude := VariabilityBPM(in.LTV, in.BaselineHeartRate)
+	c := Criterion{Name: CriterionVariability, Value: amplitude}
+
+	switch {
+	case amplitude < variabilityNormalLow:
+		persisted := variabilityPersistedSec(in, func(a float64) bool { return a < variabilityNormalLow })
+		if persisted >= reducedVariabilityPathologicalSec {
+			c.Category = CategoryPathological
+			c.Reason = fmt.Sprintf("Сниженная вариабельность %.1f уд/мин в течение %.0f мин", amplitude, persisted/60)
+		} else {
+			c.Category = CategorySuspicious
+			c.Reason = fmt.Sprintf("Сниженная вариабельность %.1f уд/мин (< %.0f) в течение %.0f мин", amplitude, variabilityNormalLow, persisted/60)
+		}
+	case amplitude > variabilityNormalHigh:
+		persisted := variabilityPersistedSec(in, func(a float64) bool { return a > variabilityNormalHigh })
+		if persisted >= increasedVariabilityPathologicalSec {
+			c.Category = CategoryPathological
+			c.Reason = fmt.Sprintf("Повышенная вариабельность %.1f уд/мин в течение %.0f мин", amplitude, persisted/60)
+		} else {
+			c.Category = CategorySuspicious
+			c.Reason = fmt.Sprintf("Повышенная вариабельность %.1f уд/мин (> %.0f) в течение %.0f мин", amplitude, variabilityNormalHigh, persisted/60)
+		}
+	default:
+		c.Category = CategoryNormal
+		c.Reason = fmt.Sprintf("Вариабельность %.1f уд/мин в норме", amplitude)
+	}
+
+	return c
+}
+
+// variabilityPersistedSec возвращает, сколько секунд подряд к концу записи держится
+// отклонение вариабельности: последние окна LTV, для которых abnormal истинно
+func variabilityPersistedSec(in Input, abnormal func(amplitude float64) bool) float64 {
+	windows := 0
+	for i := len(in.LTVWindows) - 1; i >= 0; i-- {
+		if !abnormal(VariabilityBPM(in.LTVWindows[i], in.BaselineHeartRate)) {
+			break
+		}
+		windows++
+	}
+	return float64(windows) * in.LTVWindowSec
+}
+
+func classifyDecelerations(in Input) Criterion {
+	c := Criterion{Name: CriterionDecelerations, Value: float64(in.LateDecelerations)}
+
+	// Одна пролонгированная децелерация дольше 5 минут - патология независимо от остального
+	for _, d := range in.Decelerations {
+		if d.Duration > prolongedDecelerationSec {
+			c.Category = CategoryPathological
+			c.Value = d.Duration
+			c.Reason = fmt.Sprintf("Пролонгированная децелерация %.0f с (> %d мин)", d.Duration, prolongedDecelerationSec/60)
+			return c
+		}
+	}
+
+	// Повторяющиеся - поздние децелерации более чем с половиной схваток
+	repetitive := in.LateDecelerations >= 2 && in.TotalContractions > 0 && in.LateDecelerations*2 > in.TotalContractions
+
+	switch {
+	case repetitive:
+		persisted := lateDecelerationEpisodeSec(in.Decelerations)
+		if persisted >= repetitiveDecelPathologicalSec {
+			c.Category = CategoryPathological
+			c.Reason = fmt.Sprintf("Повторяющиеся поздние децелерации (%d на %d схваток) в течение %.0f мин (> %d мин)",
+				in.LateDecelerations, in.TotalContractions, persisted/60, repetitiveDecelPathologicalSec/60)
+		} else {
+			c.Category = CategorySuspicious
+			c.Reason = fmt.Sprintf("Повторяющиеся поздние децелерации (%d на %d схваток) в течение %.0f мин",
+				in.LateDecelerations, in.TotalContractions, persisted/60)
+		}
+	case in.LateDecelerations > 0:
+		c.Category = CategoryNormal
+		c.Reason = fmt.Sprintf("Единичные поздние децелерации (%d), не повторяющиеся", in.LateDecelerations)
+	default:
+		c.Category = CategoryNormal
+		c.Reason = "Повторяющихся децелераций нет"
+	}
+
+	return c
+}
+
+// lateDecelerationEpisodeSec возвращает длительность последнего эпизода поздних
+// децелераций: от начала первой до конца последней, пока перерывы между ними не
+// превышают repetitiveDecelMaxGapSec. Одиночная поздняя децелерация эпизодом не считается
+func lateDecelerationEpisodeSec(decelerations []Deceleration) float64 {
+	var late []Deceleration
+	for _, d := range decelerations {
+		if d.IsLate {
+			late = append(late, d)
+		}
+	}
+	if len(late) < 2 {
+		return 0
+	}
+	sort.Slice(late, func(i, j int) bool { return late[i].StartSec < late[j].StartSec })
+
+	last := len(late) - 1
+	first := last
+	for first > 0 && late[first].StartSec-late[first-1].EndSec <= repetitiveDecelMaxGapSec {
+		first--
+	}
+	if first == last {
+		return 0
+	}
+	return late[last].EndSec - late[first].StartSec
+}
+
+// classifyAccelerations - только информативный критерий: отсутствие акселераций
+// в родах по FIGO имеет неопределенное значение и категорию не меняет
+func classifyAccelerations(total int32) Criterion {
+	c := Criterion{Name: CriterionAccelerations, Category: CategoryNormal, Value: float64(total)}
+	if total > 0 {
+		c.Reason = fmt.Sprintf("Акселерации присутствуют (%d)", total)
+	} else {
+		c.Reason = "Акселераций нет (значение неопределенно)"
+	}
+	return c
+}
+
+// classifySTV - дополнительный критерий Dawes-Redman по кратковременной вариабельности
+func classifySTV(stv float64) Criterion {
+	c := Criterion{Name: CriterionSTV, Value: stv}
+
+	switch {
+	case stv < stvPathological:
+		c.Category = CategoryPathological
+		c.Reason = fmt.Sprintf("STV %.2f мс ниже %.1f мс (Dawes-Redman)", stv, stvPathological)
+	case stv < stvSuspicious:
+		c.Category = CategorySuspicious
+		c.Reason = fmt.Sprintf("STV %.2f мс ниже %.1f мс (Dawes-Redman)", stv, stvSuspicious)
+	default:
+		c.Category = CategoryNormal
+		c.Reason = fmt.Sprintf("STV %.2f мс в норме", stv)
+	}
+
+	return c
+}
+
+// FromFeatureResponse собирает входные данные классификатора из ответа feature extractor
+func FromFeatureResponse(resp *featureextractorv1.ProcessBatchResponse) Input {
+	in := Input{
+		BaselineHeartRate:  resp.BaselineHeartRate,
+		STV:                resp.Stv,
+		LTV:                resp.Ltv,
+		LTVWindows:         resp.Ltvs,
+		LTVWindowSec:       resp.LtvsWindowDuration,
+		TotalAccelerations: resp.TotalAccelerations,
+		TotalContractions:  resp.TotalContractions,
+		LateDecelerations:  resp.LateDecelerations,
+		Decelerations:      make([]Deceleration, 0, len(resp.Decelerations)),
+	}
+	for _, d := range resp.Decelerations {
+		in.Decelerations = append(in.Decelerations, Deceleration{
+			StartSec:  d.Start / sampleRateHz,
+			EndSec:    d.End / sampleRateHz,
+			Duration:  d.Duration,
+			Amplitude: d.Amplitude,
+			IsLate:    d.IsLate,
+		})
+	}
+	return in
+}
@@ -0,0 +1,253 @@
+package ctg
+
+import (
+	"math"
+	"testing"
+
+	featureextractorv1 "github.com/Krimson/fetal-monitory/proto/feature_extractor"
+)
+
+// normalInput - 40 минут КТГ без отклонений: ЧСС 140, амплитуда ~10 уд/мин, STV 6 мс
+func normalInput() Input {
+	return Input{
+		BaselineHeartRate:  140,
+		STV:                6,
+		LTV:                30,
+		LTVWindows:         ltvWindows(40, 30),
+		LTVWindowSec:       60,
+		TotalAccelerations: 3,
+		TotalContractions:  6,
+	}
+}
+
+// ltvWindows возвращает n минутных окон LTV со значением ltv
+func ltvWindows(n int, ltv float64) []float64 {
+	windows := make([]float64, n)
+	for i := range windows {
+		windows[i] = ltv
+	}
+	return windows
+}
+
+// withAbnormalLTV делает последние minutes минутных окон из total отклоненными (ltv)
+func withAbnormalLTV(in *Input, total, minutes int, ltv float64) {
+	in.LTV = ltv
+	in.LTVWindows = append(ltvWindows(total-minutes, 30), ltvWindows(minutes, ltv)...)
+}
+
+// lateDecelerations возвращает минутные поздние децелерации, начинающиеся в указанные минуты
+func lateDecelerations(startMinutes ...float64) []Deceleration {
+	decelerations := make([]Deceleration, 0, len(startMinutes))
+	for _, m := range startMinutes {
+		decelerations = append(decelerations, Deceleration{
+			StartSec:  m * 60,
+			EndSec:    m*60 + 60,
+			Duration:  60,
+			Amplitude: 20,
+			IsLate:    true,
+		})
+	}
+	return decelerations
+}
+
+func criterion(t *testing.T, c *Classification, name string) Criterion {
+	t.Helper()
+	for _, cr := range c.Criteria {
+		if cr.Name == name {
+			return cr
+		}
+	}
+	t.Fatalf("criterion %s not found in %+v", name, c.Criteria)
+	return Criterion{}
+}
+
+func TestVariabilityBPM(t *testing.T) {
+	// RR 428.6 мс при 140 уд/мин; размах 30 мс ≈ 9.8 уд/мин
+	if got := VariabilityBPM(30, 140); math.Abs(got-9.8) > 0.01 {
+		t.Errorf("VariabilityBPM(30, 140) = %.3f, want 9.8", got)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name      string
+		modify    func(in *Input)
+		want      Category
+		criterion string
+		wantCrit  Category
+	}{
+		{
+			name:      "normal",
+			modify:    func(in *Input) {},
+			want:      CategoryNormal,
+			criterion: CriterionBaseline,
+			wantCrit:  CategoryNormal,
+		},
+		{
+			name:      "mild tachycardia is suspicious",
+			modify:    func(in *Input) { in.BaselineHeartRate = 165 },
+			want:      CategorySuspicious,
+			criterion: CriterionBaseline,
+			wantCrit:  CategorySuspicious,
+		},
+		{
+			name:      "bradycardia below 100 is pathological",
+			modify:    func(in *Input) { in.BaselineHeartRate = 95; in.LTV = 60 },
+			want:      CategoryPathological,
+			criterion: CriterionBaseline,
+			wantCrit:  CategoryPathological,
+		},
+		{
+			name:      "short reduced variability is suspicious",
+			modify:    func(in *Input) { withAbnormalLTV(in, 40, 10, 10) },
+			want:      CategorySuspicious,
+			criterion: CriterionVariability,
+			wantCrit:  CategorySuspicious,
+		},
+		{
+			name:      "reduced variability over 50 minutes is pathological",
+			modify:    func(in *Input) { withAbnormalLTV(in, 55, 55, 10) },
+			want:      CategoryPathological,
+			criterion: CriterionVariability,
+			wantCrit:  CategoryPathological,
+		},
+		{
+			name:      "long recording with short reduced variability is suspicious",
+			modify:    func(in *Input) { withAbnormalLTV(in, 120, 20, 10) },
+			want:      CategorySuspicious,
+			criterion: CriterionVariability,
+			wantCrit:  CategorySuspicious,
+		},
+		{
+			name:      "reduced variability interrupted by normal windows restarts",
+			modify:    func(in *Input) { withAbnormalLTV(in, 100, 45, 10); in.LTVWindows[70] = 30 },
+			want:      CategorySuspicious,
+			criterion: CriterionVariability,
+			wantCrit:  CategorySuspicious,
+		},
+		{
+			name:      "long recording with short increased variability is suspicious",
+			modify:    func(in *Input) { withAbnormalLTV(in, 90, 10, 80) },
+			want:      CategorySuspicious,
+			criterion: CriterionVariability,
+			wantCrit:  CategorySuspicious,
+		},
+		{
+			name:      "increased variability over 30 minutes is pathological",
+			modify:    func(in *Input) { withAbnormalLTV(in, 90, 35, 80) },
+			want:      CategoryPathological,
+			criterion: CriterionVariability,
+			wantCrit:  CategoryPathological,
+		},
+		{
+			name: "prolonged deceleration is pathological",
+			modify: func(in *Input) {
+				in.Decelerations = []Deceleration{{Duration: 360, Amplitude: 40}}
+			},
+			want:      CategoryPathological,
+			criterion: CriterionDecelerations,
+			wantCrit:  CategoryPathological,
+		},
+		{
+			name: "repetitive late decelerations under 30 minutes are suspicious",
+			modify: func(in *Input) {
+				in.Decelerations = lateDecelerations(20, 24, 28, 32)
+				in.LateDecelerations = 4
+			},
+			want:      CategorySuspicious,
+			criterion: CriterionDecelerations,
+			wantCrit:  CategorySuspicious,
+		},
+		{
+			name: "repetitive late decelerations over 30 minutes are pathological",
+			modify: func(in *Input) {
+				in.Decelerations = lateDecelerations(0, 5, 10, 15, 20, 25, 30, 35)
+				in.LateDecelerations = 8
+			},
+			want:      CategoryPathological,
+			criterion: CriterionDecelerations,
+			wantCrit:  CategoryPathological,
+		},
+		{
+			name: "long recording with a short late deceleration episode is suspicious",
+			modify: func(in *Input) {
+				// Первые две децелерации отделены от последнего эпизода часом без децелераций
+				in.Decelerations = lateDecelerations(0, 4, 64, 68, 72)
+				in.LateDecelerations = 5
+				in.TotalContractions = 8
+			},
+			want:      CategorySuspicious,
+			criterion: CriterionDecelerations,
+			wantCrit:  CategorySuspicious,
+		},
+		{
+			name:      "isolated late deceleration stays normal",
+			modify:    func(in *Input) { in.LateDecelerations = 1 },
+			want:      CategoryNormal,
+			criterion: CriterionDecelerations,
+			wantCrit:  CategoryNormal,
+		},
+		{
+			name:      "absent accelerations do not change category",
+			modify:    func(in *Input) { in.TotalAccelerations = 0 },
+			want:      CategoryNormal,
+			criterion: CriterionAccelerations,
+			wantCrit:  CategoryNormal,
+		},
+		{
+			name:      "low STV is pathological",
+			modify:    func(in *Input) { in.STV = 2.4 },
+			want:      CategoryPathological,
+			criterion: CriterionSTV,
+			wantCrit:  CategoryPathological,
+		},
+		{
+			name:      "no baseline yet",
+			modify:    func(in *Input) { in.BaselineHeartRate = 0 },
+			want:      CategoryUnknown,
+			criterion: CriterionBaseline,
+			wantCrit:  CategoryUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := normalInput()
+			tt.modify(&in)
+
+			got := Classify(in)
+			if got.Category != tt.want {
+				t.Errorf("Category = %s, want %s (criteria: %+v)", got.Category, tt.want, got.Criteria)
+			}
+
+			c := criterion(t, got, tt.criterion)
+			if c.Category != tt.wantCrit {
+				t.Errorf("criterion %s = %s, want %s", tt.criterion, c.Category, tt.wantCrit)
+			}
+			if c.Reason == "" {
+				t.Errorf("criterion %s has no reason", tt.criterion)
+			}
+		})
+	}
+}
+
+func TestFromFeatureResponse_UsesWindowsAndEventTimes(t *testing.T) {
+	resp := &featureextractorv1.ProcessBatchResponse{
+		BaselineHeartRate:  140,
+		Ltv:                10,
+		Ltvs:               []float64{30, 10, 10},
+		LtvsWindowDuration: 60,
+		Decelerations:      []*featureextractorv1.Deceleration{{Start: 240, End: 480, Duration: 60, IsLate: true}},
+	}
+
+	in := FromFeatureResponse(resp)
+	if len(in.LTVWindows) != 3 || in.LTVWindowSec != 60 {
+		t.Fatalf("LTV windows not copied: %+v", in)
+	}
+	if d := in.Decelerations[0]; d.StartSec != 60 || d.EndSec != 120 {
+		t.Errorf("Deceleration indices not converted to seconds: %+v", d)
+	}
+	if got := variabilityPersistedSec(in, func(a float64) bool { return a < variabilityNormalLow }); got != 120 {
+		t.Errorf("Reduced variability persisted %v s, want 120", got)
+	}
+}
@@ -0,0 +1,160 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/Krimson/fetal-monitory/auth"
+	"github.com/Krimson/fetal-monitory/logging"
+)
+
+// errNoCredentials - вызов без клиентского сертификата и API ключа
+var errNoCredentials = errors.New("device credentials required: client certificate or " + auth.APIKeyHeader)
+
+// authenticate определяет устройство по клиентскому сертификату или API ключу
+func (s *Service) authenticate(ctx context.Context, fullMethod string) (context.Context, error) {
+	d, err := s.identify(ctx)
+	if err != nil {
+		return nil, authError(err, "method", fullMethod)
+	}
+	return NewContext(ctx, d), nil
+}
+
+// identify находит включенное устройство по учетным данным вызова
+func (s *Service) identify(ctx context.Context) (*Device, error) {
+	if cn := certificateCommonName(ctx); cn != "" {
+		return s.AuthenticateCertificate(ctx, cn)
+	}
+	if key := apiKeyFromContext(ctx); key != "" {
+		return s.Authenticate(ctx, key)
+	}
+	return nil, errNoCredentials
+}
+
+// authError переводит ошибку аутентификации в код gRPC: нет или неизвестны учетные
+// данные - Unauthenticated, устройство отключено - PermissionDenied
+func authError(err error, attrs ...any) error {
+	switch {
+	case errors.Is(err, errNoCredentials):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, ErrUnknownDevice):
+		slog.Warn("Rejected unregistered device", attrs...)
+		return status.Error(codes.Unauthenticated, ErrUnknownDevice.Error())
+	case errors.Is(err, ErrDisabled):
+		slog.Warn("Rejected device", append(attrs, logging.Err(err))...)
+		return status.Error(codes.PermissionDenied, err.Error())
+	default:
+		slog.Error("Failed to authenticate device", logging.Err(err))
+		return status.Error(codes.Unavailable, "device registry unavailable")
+	}
+}
+
+// UnaryServerInterceptor требует аутентификации устройства для методов сервисов с указанными
+// префиксами ("/telemetry.v1.DataService/"); остальные методы (health, reflection) открыты
+func (s *Service) UnaryServerInterceptor(prefixes ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !hasPrefix(info.FullMethod, prefixes) {
+			return handler(ctx, req)
+		}
+		ctx, err := s.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor - то же для потоковых вызовов
+func (s *Service) StreamServerInterceptor(prefixes ...string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !hasPrefix(info.FullMethod, prefixes) {
+			return handler(srv, ss)
+		}
+		ctx, err := s.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &deviceStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// AuthorizeSession проверяет, что устройство вызова привязано к сессии (см. server.SessionAuthorizer).
+// Учетные данные проверяются заново: долгий поток не должен пережить отключение
+// устройства или смену его API ключа
+func (s *Service) AuthorizeSession(ctx context.Context, sessionID string) error {
+	stream := FromContext(ctx)
+	if stream == nil {
+		return status.Error(codes.Unauthenticated, "device is not authenticated")
+	}
+	d, err := s.identify(ctx)
+	if err != nil {
+		return authError(err, "device_id", stream.ID)
+	}
+	if d.ID != stream.ID {
+		return status.Error(codes.Unauthenticated, "device credentials changed")
+	}
+
+	err = s.Authorize(ctx, d, sessionID)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrNotBound):
+		slog.Warn("Device tried to push to unbound session", "device_id", d.ID, logging.SessionID(sessionID))
+		return status.Errorf(codes.PermissionDenied, "device %s is not bound to session %s", d.ID, sessionID)
+	default:
+		slog.Error("Failed to check device binding", logging.Err(err))
+		return status.Error(codes.Unavailable, "device registry unavailable")
+	}
+}
+
+// deviceStream подменяет контекст потока контекстом с устройством
+type deviceStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *deviceStream) Context() context.Context {
+	return s.ctx
+}
+
+// certificateCommonName возвращает CN клиентского сертификата, если он проверен по CA сервера
+func certificateCommonName(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return tlsInfo.State.VerifiedChains[0][0].Subject.CommonName
+}
+
+func apiKeyFromContext(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(auth.APIKeyHeader); len(values) > 0 {
+		return strings.TrimSpace(values[0])
+	}
+	return ""
+}
+
+func hasPrefix(method string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
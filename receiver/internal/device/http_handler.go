@@ -0,0 +1,318 @@
+package device
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Krimson/fetal-monitory/logging"
+)
+
+// HTTPHandler обрабатывает HTTP запросы реестра устройств (Presentation Layer)
+type HTTPHandler struct {
+	service *Service
+}
+
+// NewHTTPHandler создает новый HTTP обработчик
+func NewHTTPHandler(service *Service) *HTTPHandler {
+	return &HTTPHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes регистрирует маршруты в роутере. Управление реестром - /api/devices,
+// привязка мониторов к сессиям - /api/sessions/{session_id}/devices
+func (h *HTTPHandler) RegisterRoutes(router *mux.Router) {
+	api := router.PathPrefix("/api/devices").Subrouter()
+
+	api.HandleFunc("", h.RegisterDevice).Methods("POST", "OPTIONS")
+	api.HandleFunc("", h.ListDevices).Methods("GET", "OPTIONS")
+	api.HandleFunc("/{id}", h.GetDevice).Methods("GET", "OPTIONS")
+	api.HandleFunc("/{id}", h.UpdateDevice).Methods("PUT", "OPTIONS")
+	api.HandleFunc("/{id}", h.DeleteDevice).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/{id}/api-key", h.RotateKey).Methods("POST", "OPTIONS")
+	api.HandleFunc("/{id}/sessions", h.GetDeviceSessions).Methods("GET", "OPTIONS")
+
+	sessions := router.PathPrefix("/api/sessions/{session_id}/devices").Subrouter()
+	sessions.HandleFunc("", h.GetSessionDevices).Methods("GET", "OPTIONS")
+	sessions.HandleFunc("/{id}", h.BindDevice).Methods("PUT", "OPTIONS")
+	sessions.HandleFunc("/{id}", h.UnbindDevice).Methods("DELETE", "OPTIONS")
+}
+
+// RegisterDevice регистрирует монитор
+// @Summary Зарегистрировать устройство
+// @Description Регистрирует монитор и выпускает API ключ для gRPC (метаданные x-api-key). Ключ возвращается только один раз.
+// @Description Для входа по клиентскому сертификату ID должен совпадать с CN сертификата; no_api_key=true отключает выпуск ключа
+// @Tags Devices
+// @Accept json
+// @Produce json
+// @Param request body DeviceRequest true "Данные устройства"
+// @Success 201 {object} Registration "Устройство и API ключ"
+// @Failure 400 {object} map[string]interface{} "Неверные данные"
+// @Failure 409 {object} map[string]interface{} "Устройство с таким ID уже есть"
+// @Security BearerAuth
+// @Router /api/devices [post]
+func (h *HTTPHandler) RegisterDevice(w http.ResponseWriter, r *http.Request) {
+	var req DeviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	reg, err := h.service.Register(r.Context(), &req)
+	if err != nil {
+		respondServiceError(w, "register", err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, reg)
+}
+
+// ListDevices возвращает реестр устройств
+// @Summary Список устройств
+// @Tags Devices
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Устройства"
+// @Failure 500 {object} map[string]interface{} "Ошибка сервера"
+// @Security BearerAuth
+// @Router /api/devices [get]
+func (h *HTTPHandler) ListDevices(w http.ResponseWriter, r *http.Request) {
+	devices, err := h.service.List(r.Context())
+	if err != nil {
+		slog.Error("Failed to list devices", logging.Err(err))
+		respondError(w, http.StatusInternalServerError, "Failed to list devices")
+		return
+	}
+	if devices == nil {
+		devices = []*Device{}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"devices": devices,
+		"count":   len(devices),
+	})
+}
+
+// GetDevice возвращает устройство
+// @Summary Получить устройство
+// @Tags Devices
+// @Produce json
+// @Param id path string true "ID устройства"
+// @Success 200 {object} Device "Устройство"
+// @Failure 404 {object} map[string]interface{} "Устройство не найдено"
+// @Security BearerAuth
+// @Router /api/devices/{id} [get]
+func (h *HTTPHandler) GetDevice(w http.ResponseWriter, r *http.Request) {
+	d, err := h.service.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		respondServiceError(w, "get", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, d)
+}
+
+// UpdateDevice изменяет устройство
+// @Summary Изменить устройство
+// @Description Изменяет описание устройства; enabled=false блокирует прием данных от него
+// @Tags Devices
+// @Accept json
+// @Produce json
+// @Param id path string true "ID устройства"
+// @Param request body DeviceRequest true "Данные устройства"
+// @Success 200 {object} Device "Устройство изменено"
+// @Failure 400 {object} map[string]interface{} "Неверные данные"
+// @Failure 404 {object} map[string]interface{} "Устройство не найдено"
+// @Security BearerAuth
+// @Router /api/devices/{id} [put]
+func (h *HTTPHandler) UpdateDevice(w http.ResponseWriter, r *http.Request) {
+	var req DeviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	d, err := h.service.Update(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		respondServiceError(w, "update", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, d)
+}
+
+// DeleteDevice удаляет устройство
+// @Summary Удалить устройство
+// @Description Удаляет устройство и его привязки к сессиям
+// @Tags Devices
+// @Produce json
+// @Param id path string true "ID устройства"
+// @Success 200 {object} map[string]interface{} "Устройство удалено"
+// @Failure 404 {object} map[string]interface{} "Устройство не найдено"
+// @Security BearerAuth
+// @Router /api/devices/{id} [delete]
+func (h *HTTPHandler) DeleteDevice(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if err := h.service.Delete(r.Context(), id); err != nil {
+		respondServiceError(w, "delete", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message":   "Device deleted successfully",
+		"device_id": id,
+	})
+}
+
+// RotateKey выпускает новый API ключ
+// @Summary Перевыпустить API ключ
+// @Description Выпускает новый API ключ устройства; прежний ключ сразу перестает действовать
+// @Tags Devices
+// @Produce json
+// @Param id path string true "ID устройства"
+// @Success 200 {object} Registration "Устройство и новый API ключ"
+// @Failure 404 {object} map[string]interface{} "Устройство не найдено"
+// @Security BearerAuth
+// @Router /api/devices/{id}/api-key [post]
+func (h *HTTPHandler) RotateKey(w http.ResponseWriter, r *http.Request) {
+	reg, err := h.service.RotateKey(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		respondServiceError(w, "rotate api key of", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, reg)
+}
+
+// GetDeviceSessions возвращает сессии, к которым привязано устройство
+// @Summary Сессии устройства
+// @Tags Devices
+// @Produce json
+// @Param id path string true "ID устройства"
+// @Success 200 {object} map[string]interface{} "Привязки"
+// @Failure 404 {object} map[string]interface{} "Устройство не найдено"
+// @Security BearerAuth
+// @Router /api/devices/{id}/sessions [get]
+func (h *HTTPHandler) GetDeviceSessions(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	bindings, err := h.service.Bindings(r.Context(), id)
+	if err != nil {
+		respondServiceError(w, "get sessions of", err)
+		return
+	}
+	respondBindings(w, bindings)
+}
+
+// GetSessionDevices возвращает устройства, привязанные к сессии
+// @Summary Устройства сессии
+// @Tags Devices
+// @Produce json
+// @Param session_id path string true "ID сессии"
+// @Success 200 {object} map[string]interface{} "Привязки"
+// @Failure 500 {object} map[string]interface{} "Ошибка сервера"
+// @Security BearerAuth
+// @Router /api/sessions/{session_id}/devices [get]
+func (h *HTTPHandler) GetSessionDevices(w http.ResponseWriter, r *http.Request) {
+	bindings, err := h.service.SessionBindings(r.Context(), mux.Vars(r)["session_id"])
+	if err != nil {
+		slog.Error("Failed to get session devices", logging.Err(err))
+		respondError(w, http.StatusInternalServerError, "Failed to get session devices")
+		return
+	}
+	respondBindings(w, bindings)
+}
+
+// BindDevice привязывает устройство к сессии
+// @Summary Привязать устройство к сессии
+// @Description Разрешает монитору отправлять сэмплы в сессию. Сессию можно привязать заранее - она будет создана при первых данных
+// @Tags Devices
+// @Produce json
+// @Param session_id path string true "ID сессии"
+// @Param id path string true "ID устройства"
+// @Success 200 {object} Binding "Привязка"
+// @Failure 400 {object} map[string]interface{} "Неверный ID сессии"
+// @Failure 404 {object} map[string]interface{} "Устройство не найдено"
+// @Security BearerAuth
+// @Router /api/sessions/{session_id}/devices/{id} [put]
+func (h *HTTPHandler) BindDevice(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	b, err := h.service.Bind(r.Context(), vars["id"], vars["session_id"])
+	if err != nil {
+		respondServiceError(w, "bind", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, b)
+}
+
+// UnbindDevice отвязывает устройство от сессии
+// @Summary Отвязать устройство от сессии
+// @Description Новые потоки устройства в эту сессию будут отклонены с PermissionDenied
+// @Tags Devices
+// @Produce json
+// @Param session_id path string true "ID сессии"
+// @Param id path string true "ID устройства"
+// @Success 200 {object} map[string]interface{} "Привязка удалена"
+// @Failure 404 {object} map[string]interface{} "Привязка не найдена"
+// @Security BearerAuth
+// @Router /api/sessions/{session_id}/devices/{id} [delete]
+func (h *HTTPHandler) UnbindDevice(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := h.service.Unbind(r.Context(), vars["id"], vars["session_id"]); err != nil {
+		respondServiceError(w, "unbind", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message":    "Device unbound successfully",
+		"device_id":  vars["id"],
+		"session_id": vars["session_id"],
+	})
+}
+
+func respondBindings(w http.ResponseWriter, bindings []Binding) {
+	if bindings == nil {
+		bindings = []Binding{}
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"bindings": bindings,
+		"count":    len(bindings),
+	})
+}
+
+// respondServiceError переводит ошибку сервиса в HTTP статус
+func respondServiceError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case errors.Is(err, ErrInvalid):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrExists):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		slog.Error("Device operation failed", "action", action, logging.Err(err))
+		respondError(w, http.StatusInternalServerError, "Failed to "+action+" device")
+	}
+}
+
+func respondJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		slog.Error("Failed to encode JSON response", logging.Err(err))
+	}
+}
+
+func respondError(w http.ResponseWriter, status int, message string) {
+	respondJSON(w, status, map[string]interface{}{
+		"error":  message,
+		"status": status,
+	})
+}
@@ -0,0 +1,236 @@
+package device
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// pqUniqueViolation - код ошибки PostgreSQL при нарушении уникальности
+const pqUniqueViolation = "23505"
+
+// PostgresRepository реализует Repository для PostgreSQL (Infrastructure Layer)
+type PostgresRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresRepository создает репозиторий поверх общего пула соединений
+func NewPostgresRepository(db *sql.DB) *PostgresRepository {
+	return &PostgresRepository{
+		db: db,
+	}
+}
+
+const deviceColumns = `id, name, model, location, enabled, api_key_hash IS NOT NULL, created_at, updated_at, last_seen_at`
+
+func (r *PostgresRepository) Create(ctx context.Context, d *Device, keyHash string) error {
+	query := `
+		INSERT INTO devices (id, name, model, location, api_key_hash, enabled, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8)
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		d.ID,
+		d.Name,
+		d.Model,
+		d.Location,
+		keyHash,
+		d.Enabled,
+		d.CreatedAt,
+		d.UpdatedAt,
+	)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation {
+			return fmt.Errorf("%w: %s", ErrExists, d.ID)
+		}
+		return fmt.Errorf("failed to create device: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) Get(ctx context.Context, id string) (*Device, error) {
+	query := `SELECT ` + deviceColumns + ` FROM devices WHERE id = $1`
+
+	d, err := scanDevice(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to get device: %w", err)
+	}
+	return d, nil
+}
+
+func (r *PostgresRepository) GetByKeyHash(ctx context.Context, keyHash string) (*Device, error) {
+	query := `SELECT ` + deviceColumns + ` FROM devices WHERE api_key_hash = $1`
+
+	d, err := scanDevice(r.db.QueryRowContext(ctx, query, keyHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get device by api key: %w", err)
+	}
+	return d, nil
+}
+
+func (r *PostgresRepository) List(ctx context.Context) ([]*Device, error) {
+	query := `SELECT ` + deviceColumns + ` FROM devices ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+	defer rows.Close()
+
+	var devices []*Device
+	for rows.Next() {
+		d, err := scanDevice(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan device: %w", err)
+		}
+		devices = append(devices, d)
+	}
+	return devices, rows.Err()
+}
+
+func (r *PostgresRepository) Update(ctx context.Context, d *Device) error {
+	query := `
+		UPDATE devices SET name = $2, model = $3, location = $4, enabled = $5, updated_at = $6
+		WHERE id = $1
+	`
+
+	result, err := r.db.ExecContext(ctx, query, d.ID, d.Name, d.Model, d.Location, d.Enabled, d.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update device: %w", err)
+	}
+	return checkAffected(result, d.ID)
+}
+
+func (r *PostgresRepository) SetKeyHash(ctx context.Context, id, keyHash string, at time.Time) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE devices SET api_key_hash = $2, updated_at = $3 WHERE id = $1`, id, keyHash, at)
+	if err != nil {
+		return fmt.Errorf("failed to set device api key: %w", err)
+	}
+	return checkAffected(result, id)
+}
+
+func (r *PostgresRepository) Delete(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM devices WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete device: %w", err)
+	}
+	return checkAffected(result, id)
+}
+
+func (r *PostgresRepository) Touch(ctx context.Context, id string, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE devices SET last_seen_at = $2 WHERE id = $1`, id, at)
+	if err != nil {
+		return fmt.Errorf("failed to touch device: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) Bind(ctx context.Context, b *Binding) error {
+	query := `
+		INSERT INTO device_sessions (device_id, session_id, bound_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (device_id, session_id) DO NOTHING
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, b.DeviceID, b.SessionID, b.BoundAt); err != nil {
+		return fmt.Errorf("failed to bind device: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) Unbind(ctx context.Context, deviceID, sessionID string) error {
+	result, err := r.db.ExecContext(ctx,
+		`DELETE FROM device_sessions WHERE device_id = $1 AND session_id = $2`, deviceID, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to unbind device: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return fmt.Errorf("%w: binding %s -> %s", ErrNotFound, deviceID, sessionID)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) IsBound(ctx context.Context, deviceID, sessionID string) (bool, error) {
+	var bound bool
+	err := r.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM device_sessions WHERE device_id = $1 AND session_id = $2)`,
+		deviceID, sessionID,
+	).Scan(&bound)
+	if err != nil {
+		return false, fmt.Errorf("failed to check device binding: %w", err)
+	}
+	return bound, nil
+}
+
+func (r *PostgresRepository) Bindings(ctx context.Context, deviceID string) ([]Binding, error) {
+	return r.queryBindings(ctx, `
+		SELECT device_id, session_id, bound_at FROM device_sessions
+		WHERE device_id = $1
+		ORDER BY bound_at DESC
+	`, deviceID)
+}
+
+func (r *PostgresRepository) SessionBindings(ctx context.Context, sessionID string) ([]Binding, error) {
+	return r.queryBindings(ctx, `
+		SELECT device_id, session_id, bound_at FROM device_sessions
+		WHERE session_id = $1
+		ORDER BY bound_at
+	`, sessionID)
+}
+
+func (r *PostgresRepository) queryBindings(ctx context.Context, query string, arg string) ([]Binding, error) {
+	rows, err := r.db.QueryContext(ctx, query, arg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list device bindings: %w", err)
+	}
+	defer rows.Close()
+
+	var bindings []Binding
+	for rows.Next() {
+		var b Binding
+		if err := rows.Scan(&b.DeviceID, &b.SessionID, &b.BoundAt); err != nil {
+			return nil, fmt.Errorf("failed to scan device binding: %w", err)
+		}
+		bindings = append(bindings, b)
+	}
+	return bindings, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanDevice(row rowScanner) (*Device, error) {
+	var (
+		d        Device
+		lastSeen sql.NullTime
+	)
+	err := row.Scan(&d.ID, &d.Name, &d.Model, &d.Location, &d.Enabled, &d.HasAPIKey,
+		&d.CreatedAt, &d.UpdatedAt, &lastSeen)
+	if err != nil {
+		return nil, err
+	}
+	if lastSeen.Valid {
+		d.LastSeenAt = &lastSeen.Time
+	}
+	return &d, nil
+}
+
+func checkAffected(result sql.Result, id string) error {
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return nil
+}
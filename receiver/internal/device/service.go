@@ -0,0 +1,257 @@
+package device
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Krimson/fetal-monitory/logging"
+)
+
+// apiKeyPrefix помечает ключи устройств, чтобы их было легко узнать в конфигурации и логах
+const apiKeyPrefix = "fmd_"
+
+// Ошибки аутентификации устройств
+var (
+	ErrUnknownDevice = errors.New("unknown device")
+	ErrDisabled      = errors.New("device is disabled")
+	ErrNotBound      = errors.New("device is not bound to session")
+)
+
+// Service ведет реестр устройств и проверяет их доступ к сессиям (Application Layer)
+type Service struct {
+	repository Repository
+	now        func() time.Time
+}
+
+// NewService создает сервис реестра устройств
+func NewService(repository Repository) *Service {
+	return &Service{
+		repository: repository,
+		now:        time.Now,
+	}
+}
+
+// Register регистрирует устройство и, если не запрошено иное, выпускает ему API ключ
+func (s *Service) Register(ctx context.Context, req *DeviceRequest) (*Registration, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	d := &Device{ID: strings.TrimSpace(req.ID), Enabled: true, CreatedAt: now, UpdatedAt: now}
+	if d.ID == "" {
+		d.ID = uuid.NewString()
+	}
+	apply(d, req)
+
+	reg := &Registration{Device: d}
+	var keyHash string
+	if !req.NoAPIKey {
+		key, err := generateAPIKey()
+		if err != nil {
+			return nil, err
+		}
+		reg.APIKey = key
+		keyHash = hashAPIKey(key)
+		d.HasAPIKey = true
+	}
+
+	if err := s.repository.Create(ctx, d, keyHash); err != nil {
+		return nil, err
+	}
+
+	slog.Info("Registered device", "device_id", d.ID, "name", d.Name)
+	return reg, nil
+}
+
+// Get возвращает устройство
+func (s *Service) Get(ctx context.Context, id string) (*Device, error) {
+	return s.repository.Get(ctx, id)
+}
+
+// List возвращает все устройства
+func (s *Service) List(ctx context.Context) ([]*Device, error) {
+	return s.repository.List(ctx)
+}
+
+// Update изменяет описание устройства и включает/отключает его
+func (s *Service) Update(ctx context.Context, id string, req *DeviceRequest) (*Device, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+
+	d, err := s.repository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	apply(d, req)
+	d.UpdatedAt = s.now()
+
+	if err := s.repository.Update(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Delete удаляет устройство вместе с привязками
+func (s *Service) Delete(ctx context.Context, id string) error {
+	if err := s.repository.Delete(ctx, id); err != nil {
+		return err
+	}
+	slog.Info("Deleted device", "device_id", id)
+	return nil
+}
+
+// RotateKey выпускает новый API ключ; старый перестает действовать сразу
+func (s *Service) RotateKey(ctx context.Context, id string) (*Registration, error) {
+	key, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repository.SetKeyHash(ctx, id, hashAPIKey(key), s.now()); err != nil {
+		return nil, err
+	}
+
+	d, err := s.repository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Rotated API key", "device_id", id)
+	return &Registration{Device: d, APIKey: key}, nil
+}
+
+// Bind разрешает устройству отправлять сэмплы в сессию. Сессия может еще не существовать:
+// она будет создана при первых данных от устройства
+func (s *Service) Bind(ctx context.Context, deviceID, sessionID string) (*Binding, error) {
+	sessionID = strings.TrimSpace(sessionID)
+	if sessionID == "" || len(sessionID) > 64 {
+		return nil, fmt.Errorf("%w: session_id must be 1-64 characters", ErrInvalid)
+	}
+	if _, err := s.repository.Get(ctx, deviceID); err != nil {
+		return nil, err
+	}
+
+	b := &Binding{DeviceID: deviceID, SessionID: sessionID, BoundAt: s.now()}
+	if err := s.repository.Bind(ctx, b); err != nil {
+		return nil, err
+	}
+
+	slog.Info("Bound device to session", "device_id", deviceID, logging.SessionID(sessionID))
+	return b, nil
+}
+
+// Unbind отзывает доступ устройства к сессии
+func (s *Service) Unbind(ctx context.Context, deviceID, sessionID string) error {
+	if err := s.repository.Unbind(ctx, deviceID, sessionID); err != nil {
+		return err
+	}
+	slog.Info("Unbound device from session", "device_id", deviceID, logging.SessionID(sessionID))
+	return nil
+}
+
+// Bindings возвращает сессии устройства
+func (s *Service) Bindings(ctx context.Context, deviceID string) ([]Binding, error) {
+	if _, err := s.repository.Get(ctx, deviceID); err != nil {
+		return nil, err
+	}
+	return s.repository.Bindings(ctx, deviceID)
+}
+
+// SessionBindings возвращает устройства, привязанные к сессии
+func (s *Service) SessionBindings(ctx context.Context, sessionID string) ([]Binding, error) {
+	return s.repository.SessionBindings(ctx, sessionID)
+}
+
+// Authenticate находит включенное устройство по API ключу
+func (s *Service) Authenticate(ctx context.Context, apiKey string) (*Device, error) {
+	if !strings.HasPrefix(apiKey, apiKeyPrefix) {
+		return nil, ErrUnknownDevice
+	}
+	d, err := s.repository.GetByKeyHash(ctx, hashAPIKey(apiKey))
+	return s.checkDevice(ctx, d, err)
+}
+
+// AuthenticateCertificate находит включенное устройство по CN проверенного клиентского сертификата
+func (s *Service) AuthenticateCertificate(ctx context.Context, commonName string) (*Device, error) {
+	if commonName == "" {
+		return nil, ErrUnknownDevice
+	}
+	d, err := s.repository.Get(ctx, commonName)
+	return s.checkDevice(ctx, d, err)
+}
+
+func (s *Service) checkDevice(ctx context.Context, d *Device, err error) (*Device, error) {
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, ErrUnknownDevice
+		}
+		return nil, err
+	}
+	if !d.Enabled {
+		return nil, fmt.Errorf("%w: %s", ErrDisabled, d.ID)
+	}
+
+	if err := s.repository.Touch(ctx, d.ID, s.now()); err != nil {
+		slog.Warn("Failed to update last_seen_at", "device_id", d.ID, logging.Err(err))
+	}
+	return d, nil
+}
+
+// Authorize проверяет, что устройство привязано к сессии
+func (s *Service) Authorize(ctx context.Context, d *Device, sessionID string) error {
+	bound, err := s.repository.IsBound(ctx, d.ID, sessionID)
+	if err != nil {
+		return err
+	}
+	if !bound {
+		return fmt.Errorf("%w: %s -> %s", ErrNotBound, d.ID, sessionID)
+	}
+	return nil
+}
+
+func validate(req *DeviceRequest) error {
+	switch {
+	case len(req.ID) > 64:
+		return fmt.Errorf("%w: id is longer than 64 characters", ErrInvalid)
+	case strings.TrimSpace(req.Name) == "":
+		return fmt.Errorf("%w: name is required", ErrInvalid)
+	}
+	return nil
+}
+
+// apply переносит поля запроса в устройство
+func apply(d *Device, req *DeviceRequest) {
+	d.Name = strings.TrimSpace(req.Name)
+	d.Model = strings.TrimSpace(req.Model)
+	d.Location = strings.TrimSpace(req.Location)
+	if req.Enabled != nil {
+		d.Enabled = *req.Enabled
+	}
+}
+
+// generateAPIKey возвращает новый случайный ключ устройства (256 бит)
+func generateAPIKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashAPIKey - в БД хранится только SHA-256 ключа; у ключа 256 бит энтропии,
+// поэтому медленный KDF не нужен
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,316 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// memoryRepository - реестр устройств в памяти
+type memoryRepository struct {
+	devices  map[string]*Device
+	keys     map[string]string // хеш ключа -> ID устройства
+	bindings map[string]map[string]time.Time
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{
+		devices:  make(map[string]*Device),
+		keys:     make(map[string]string),
+		bindings: make(map[string]map[string]time.Time),
+	}
+}
+
+func (r *memoryRepository) Create(ctx context.Context, d *Device, keyHash string) error {
+	if _, ok := r.devices[d.ID]; ok {
+		return ErrExists
+	}
+	copied := *d
+	r.devices[d.ID] = &copied
+	if keyHash != "" {
+		r.keys[keyHash] = d.ID
+	}
+	return nil
+}
+
+func (r *memoryRepository) Get(ctx context.Context, id string) (*Device, error) {
+	d, ok := r.devices[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *d
+	return &copied, nil
+}
+
+func (r *memoryRepository) GetByKeyHash(ctx context.Context, keyHash string) (*Device, error) {
+	id, ok := r.keys[keyHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return r.Get(ctx, id)
+}
+
+func (r *memoryRepository) List(ctx context.Context) ([]*Device, error) {
+	var devices []*Device
+	for _, d := range r.devices {
+		devices = append(devices, d)
+	}
+	return devices, nil
+}
+
+func (r *memoryRepository) Update(ctx context.Context, d *Device) error {
+	if _, ok := r.devices[d.ID]; !ok {
+		return ErrNotFound
+	}
+	copied := *d
+	r.devices[d.ID] = &copied
+	return nil
+}
+
+func (r *memoryRepository) SetKeyHash(ctx context.Context, id, keyHash string, at time.Time) error {
+	d, ok := r.devices[id]
+	if !ok {
+		return ErrNotFound
+	}
+	for hash, owner := range r.keys {
+		if owner == id {
+			delete(r.keys, hash)
+		}
+	}
+	r.keys[keyHash] = id
+	d.HasAPIKey = true
+	return nil
+}
+
+func (r *memoryRepository) Delete(ctx context.Context, id string) error {
+	if _, ok := r.devices[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.devices, id)
+	delete(r.bindings, id)
+	return nil
+}
+
+func (r *memoryRepository) Touch(ctx context.Context, id string, at time.Time) error {
+	if d, ok := r.devices[id]; ok {
+		d.LastSeenAt = &at
+	}
+	return nil
+}
+
+func (r *memoryRepository) Bind(ctx context.Context, b *Binding) error {
+	if r.bindings[b.DeviceID] == nil {
+		r.bindings[b.DeviceID] = make(map[string]time.Time)
+	}
+	r.bindings[b.DeviceID][b.SessionID] = b.BoundAt
+	return nil
+}
+
+func (r *memoryRepository) Unbind(ctx context.Context, deviceID, sessionID string) error {
+	if _, ok := r.bindings[deviceID][sessionID]; !ok {
+		return ErrNotFound
+	}
+	delete(r.bindings[deviceID], sessionID)
+	return nil
+}
+
+func (r *memoryRepository) IsBound(ctx context.Context, deviceID, sessionID string) (bool, error) {
+	_, ok := r.bindings[deviceID][sessionID]
+	return ok, nil
+}
+
+func (r *memoryRepository) Bindings(ctx context.Context, deviceID string) ([]Binding, error) {
+	var bindings []Binding
+	for sessionID, at := range r.bindings[deviceID] {
+		bindings = append(bindings, Binding{DeviceID: deviceID, SessionID: sessionID, BoundAt: at})
+	}
+	return bindings, nil
+}
+
+func (r *memoryRepository) SessionBindings(ctx context.Context, sessionID string) ([]Binding, error) {
+	var bindings []Binding
+	for deviceID, sessions := range r.bindings {
+		if at, ok := sessions[sessionID]; ok {
+			bindings = append(bindings, Binding{DeviceID: deviceID, SessionID: sessionID, BoundAt: at})
+		}
+	}
+	return bindings, nil
+}
+
+func register(t *testing.T, s *Service, id string) *Registration {
+	t.Helper()
+	reg, err := s.Register(context.Background(), &DeviceRequest{ID: id, Name: "Monitor " + id})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	return reg
+}
+
+func TestRegisterAndAuthenticate(t *testing.T) {
+	repo := newMemoryRepository()
+	s := NewService(repo)
+	ctx := context.Background()
+
+	reg := register(t, s, "monitor-1")
+	if !strings.HasPrefix(reg.APIKey, apiKeyPrefix) || !reg.Device.HasAPIKey || !reg.Device.Enabled {
+		t.Fatalf("Unexpected registration: %+v", reg)
+	}
+	for hash := range repo.keys {
+		if hash == reg.APIKey {
+			t.Error("API key must not be stored in plain text")
+		}
+	}
+
+	d, err := s.Authenticate(ctx, reg.APIKey)
+	if err != nil || d.ID != "monitor-1" {
+		t.Fatalf("Authenticate failed: %+v, %v", d, err)
+	}
+	if repo.devices["monitor-1"].LastSeenAt == nil {
+		t.Error("Expected last_seen_at to be updated")
+	}
+
+	if _, err := s.Authenticate(ctx, apiKeyPrefix+"wrong"); !errors.Is(err, ErrUnknownDevice) {
+		t.Errorf("Expected ErrUnknownDevice, got %v", err)
+	}
+	if _, err := s.Register(ctx, &DeviceRequest{ID: "monitor-1", Name: "dup"}); !errors.Is(err, ErrExists) {
+		t.Errorf("Expected ErrExists, got %v", err)
+	}
+	if _, err := s.Register(ctx, &DeviceRequest{ID: "monitor-2"}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Expected ErrInvalid for missing name, got %v", err)
+	}
+}
+
+func TestDisabledDeviceAndKeyRotation(t *testing.T) {
+	s := NewService(newMemoryRepository())
+	ctx := context.Background()
+	reg := register(t, s, "monitor-1")
+
+	rotated, err := s.RotateKey(ctx, "monitor-1")
+	if err != nil {
+		t.Fatalf("RotateKey failed: %v", err)
+	}
+	if _, err := s.Authenticate(ctx, reg.APIKey); !errors.Is(err, ErrUnknownDevice) {
+		t.Errorf("Old key must stop working, got %v", err)
+	}
+	if _, err := s.Authenticate(ctx, rotated.APIKey); err != nil {
+		t.Errorf("New key must work, got %v", err)
+	}
+
+	disabled := false
+	if _, err := s.Update(ctx, "monitor-1", &DeviceRequest{Name: "Monitor", Enabled: &disabled}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if _, err := s.Authenticate(ctx, rotated.APIKey); !errors.Is(err, ErrDisabled) {
+		t.Errorf("Expected ErrDisabled, got %v", err)
+	}
+	if _, err := s.AuthenticateCertificate(ctx, "monitor-1"); !errors.Is(err, ErrDisabled) {
+		t.Errorf("Expected ErrDisabled for certificate, got %v", err)
+	}
+}
+
+func TestStreamInterceptorAndSessionAuthorization(t *testing.T) {
+	s := NewService(newMemoryRepository())
+	reg := register(t, s, "monitor-1")
+	if _, err := s.Bind(context.Background(), "monitor-1", "session-a"); err != nil {
+		t.Fatalf("Bind failed: %v", err)
+	}
+
+	interceptor := s.StreamServerInterceptor("/telemetry.v1.DataService/")
+	info := &grpc.StreamServerInfo{FullMethod: "/telemetry.v1.DataService/PushSamples"}
+
+	call := func(key, sessionID string) error {
+		ctx := context.Background()
+		if key != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("x-api-key", key))
+		}
+		return interceptor(nil, &fakeStream{ctx: ctx}, info, func(srv interface{}, ss grpc.ServerStream) error {
+			return s.AuthorizeSession(ss.Context(), sessionID)
+		})
+	}
+
+	tests := []struct {
+		name      string
+		key       string
+		sessionID string
+		want      codes.Code
+	}{
+		{"no credentials", "", "session-a", codes.Unauthenticated},
+		{"unregistered", apiKeyPrefix + "unknown", "session-a", codes.Unauthenticated},
+		{"bound session", reg.APIKey, "session-a", codes.OK},
+		{"unbound session", reg.APIKey, "session-b", codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status.Code(call(tt.key, tt.sessionID)); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	if err := s.Unbind(context.Background(), "monitor-1", "session-a"); err != nil {
+		t.Fatalf("Unbind failed: %v", err)
+	}
+	if got := status.Code(call(reg.APIKey, "session-a")); got != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied after unbind, got %v", got)
+	}
+
+	// Методы других сервисов не требуют учетных данных устройства
+	other := &grpc.StreamServerInfo{FullMethod: "/grpc.health.v1.Health/Watch"}
+	err := interceptor(nil, &fakeStream{ctx: context.Background()}, other, func(srv interface{}, ss grpc.ServerStream) error {
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Expected other services to be open, got %v", err)
+	}
+}
+
+func TestAuthorizeSession_RechecksCredentials(t *testing.T) {
+	s := NewService(newMemoryRepository())
+	ctx := context.Background()
+	reg := register(t, s, "monitor-1")
+	if _, err := s.Bind(ctx, "monitor-1", "session-a"); err != nil {
+		t.Fatalf("Bind failed: %v", err)
+	}
+
+	// Контекст уже открытого потока: устройство аутентифицировано старым ключом
+	streamCtx := NewContext(metadata.NewIncomingContext(ctx, metadata.Pairs("x-api-key", reg.APIKey)), reg.Device)
+	if err := s.AuthorizeSession(streamCtx, "session-a"); err != nil {
+		t.Fatalf("Expected access before rotation, got %v", err)
+	}
+
+	if _, err := s.RotateKey(ctx, "monitor-1"); err != nil {
+		t.Fatalf("RotateKey failed: %v", err)
+	}
+	if got := status.Code(s.AuthorizeSession(streamCtx, "session-a")); got != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated after key rotation, got %v", got)
+	}
+
+	rotated, err := s.RotateKey(ctx, "monitor-1")
+	if err != nil {
+		t.Fatalf("RotateKey failed: %v", err)
+	}
+	streamCtx = NewContext(metadata.NewIncomingContext(ctx, metadata.Pairs("x-api-key", rotated.APIKey)), rotated.Device)
+	disabled := false
+	if _, err := s.Update(ctx, "monitor-1", &DeviceRequest{Name: "Monitor", Enabled: &disabled}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if got := status.Code(s.AuthorizeSession(streamCtx, "session-a")); got != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied for disabled device, got %v", got)
+	}
+}
+
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeStream) Context() context.Context {
+	return s.ctx
+}
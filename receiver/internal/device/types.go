@@ -0,0 +1,82 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Ошибки реестра устройств
+var (
+	ErrNotFound = errors.New("device not found")
+	ErrExists   = errors.New("device already exists")
+	ErrInvalid  = errors.New("invalid device")
+)
+
+// Device - зарегистрированный монитор. API ключ хранится только в виде хеша
+type Device struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Model      string     `json:"model,omitempty"`
+	Location   string     `json:"location,omitempty"` // Палата, пост
+	Enabled    bool       `json:"enabled"`
+	HasAPIKey  bool       `json:"has_api_key"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+}
+
+// DeviceRequest - данные для регистрации и изменения устройства
+type DeviceRequest struct {
+	ID       string `json:"id,omitempty"` // Для сертификатов - CN клиентского сертификата
+	Name     string `json:"name"`
+	Model    string `json:"model,omitempty"`
+	Location string `json:"location,omitempty"`
+	Enabled  *bool  `json:"enabled,omitempty"` // По умолчанию true
+	// NoAPIKey - не выпускать API ключ (устройство входит только по сертификату)
+	NoAPIKey bool `json:"no_api_key,omitempty"`
+}
+
+// Registration - устройство с выпущенным API ключом. Ключ показывается один раз
+type Registration struct {
+	Device *Device `json:"device"`
+	APIKey string  `json:"api_key,omitempty"`
+}
+
+// Binding - привязка устройства к сессии
+type Binding struct {
+	DeviceID  string    `json:"device_id"`
+	SessionID string    `json:"session_id"`
+	BoundAt   time.Time `json:"bound_at"`
+}
+
+// Repository - хранилище реестра устройств
+type Repository interface {
+	Create(ctx context.Context, d *Device, keyHash string) error
+	Get(ctx context.Context, id string) (*Device, error)
+	GetByKeyHash(ctx context.Context, keyHash string) (*Device, error)
+	List(ctx context.Context) ([]*Device, error)
+	Update(ctx context.Context, d *Device) error
+	SetKeyHash(ctx context.Context, id, keyHash string, at time.Time) error
+	Delete(ctx context.Context, id string) error
+	Touch(ctx context.Context, id string, at time.Time) error
+
+	Bind(ctx context.Context, b *Binding) error
+	Unbind(ctx context.Context, deviceID, sessionID string) error
+	IsBound(ctx context.Context, deviceID, sessionID string) (bool, error)
+	Bindings(ctx context.Context, deviceID string) ([]Binding, error)
+	SessionBindings(ctx context.Context, sessionID string) ([]Binding, error)
+}
+
+type contextKey struct{}
+
+// NewContext возвращает контекст с аутентифицированным устройством
+func NewContext(ctx context.Context, d *Device) context.Context {
+	return context.WithValue(ctx, contextKey{}, d)
+}
+
+// FromContext возвращает устройство из контекста вызова или nil
+func FromContext(ctx context.Context) *Device {
+	d, _ := ctx.Value(contextKey{}).(*Device)
+	return d
+}
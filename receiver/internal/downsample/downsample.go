@@ -0,0 +1,160 @@
+// Package downsample прореживает трассы для обзорных графиков длинных сессий
+// с сохранением формы сигнала.
+//
+// LTTB (Largest-Triangle-Three-Buckets) выбирает в каждом бакете точку,
+// образующую наибольший треугольник с соседними бакетами, - кривая визуально
+// совпадает с исходной. MinMax оставляет минимум и максимум каждого бакета по
+// времени - ни один пик и провал (децелерация) не теряется.
+package downsample
+
+import (
+	"fmt"
+	"math"
+)
+
+// Point - точка трассы: X - время в секундах, Y - значение
+type Point struct {
+	X float64
+	Y float64
+}
+
+// Method - алгоритм прореживания
+type Method string
+
+const (
+	MethodLTTB   Method = "lttb"
+	MethodMinMax Method = "minmax"
+)
+
+// ParseMethod разбирает название алгоритма; пустое значение - LTTB
+func ParseMethod(s string) (Method, error) {
+	switch Method(s) {
+	case "", MethodLTTB:
+		return MethodLTTB, nil
+	case MethodMinMax:
+		return MethodMinMax, nil
+	default:
+		return "", fmt.Errorf("unknown downsampling method: %s", s)
+	}
+}
+
+// Apply прореживает трассу выбранным алгоритмом до не более чем threshold точек
+func Apply(method Method, points []Point, threshold int) []Point {
+	if method == MethodMinMax {
+		return MinMax(points, threshold)
+	}
+	return LTTB(points, threshold)
+}
+
+// LTTB прореживает трассу до threshold точек. Первая и последняя точки
+// сохраняются; точки должны быть упорядочены по X
+func LTTB(points []Point, threshold int) []Point {
+	if threshold <= 0 || len(points) <= threshold {
+		return points
+	}
+	if threshold < 3 {
+		return []Point{points[0], points[len(points)-1]}
+	}
+
+	sampled := make([]Point, 0, threshold)
+	sampled = append(sampled, points[0])
+
+	// Внутренние точки делятся на threshold-2 бакета
+	every := float64(len(points)-2) / float64(threshold-2)
+	a := 0
+
+	for i := 0; i < threshold-2; i++ {
+		// Среднее следующего бакета - третья вершина треугольника
+		nextStart := int(math.Floor(float64(i+1)*every)) + 1
+		nextEnd := int(math.Floor(float64(i+2)*every)) + 1
+		if nextEnd > len(points) {
+			nextEnd = len(points)
+		}
+		var avgX, avgY float64
+		for _, p := range points[nextStart:nextEnd] {
+			avgX += p.X
+			avgY += p.Y
+		}
+		n := float64(nextEnd - nextStart)
+		avgX /= n
+		avgY /= n
+
+		start := int(math.Floor(float64(i)*every)) + 1
+		end := int(math.Floor(float64(i+1)*every)) + 1
+
+		pa := points[a]
+		maxArea := -1.0
+		next := start
+		for j := start; j < end; j++ {
+			area := math.Abs((pa.X-avgX)*(points[j].Y-pa.Y) - (pa.X-points[j].X)*(avgY-pa.Y))
+			if area > maxArea {
+				maxArea = area
+				next = j
+			}
+		}
+
+		sampled = append(sampled, points[next])
+		a = next
+	}
+
+	return append(sampled, points[len(points)-1])
+}
+
+// MinMax прореживает трассу до не более чем threshold точек: промежуток
+// времени делится на threshold/2 равных бакетов, от каждого остаются минимум и
+// максимум в порядке времени. Точки должны быть упорядочены по X
+func MinMax(points []Point, threshold int) []Point {
+	if threshold <= 0 || len(points) <= threshold {
+		return points
+	}
+	if threshold < 2 {
+		return points[:1]
+	}
+	buckets := threshold / 2
+
+	span := points[len(points)-1].X - points[0].X
+	if span <= 0 {
+		return []Point{points[0], points[len(points)-1]}
+	}
+	// Чуть шире, чтобы последняя точка попала в последний бакет
+	return MinMaxBuckets(points, span/float64(buckets)*(1+1e-9))
+}
+
+// MinMaxBuckets оставляет минимум и максимум каждого бакета шириной width
+// секунд, отсчитывая бакеты от первой точки. Пустые бакеты (разрывы записи)
+// пропускаются. Точки должны быть упорядочены по X
+func MinMaxBuckets(points []Point, width float64) []Point {
+	if len(points) == 0 || width <= 0 {
+		return points
+	}
+
+	x0 := points[0].X
+	buckets := int((points[len(points)-1].X-x0)/width) + 1
+	result := make([]Point, 0, min(len(points), 2*buckets))
+
+	for i := 0; i < len(points); {
+		bucket := math.Floor((points[i].X - x0) / width)
+		lo, hi := points[i], points[i]
+		j := i + 1
+		for ; j < len(points) && math.Floor((points[j].X-x0)/width) == bucket; j++ {
+			if points[j].Y < lo.Y {
+				lo = points[j]
+			}
+			if points[j].Y > hi.Y {
+				hi = points[j]
+			}
+		}
+
+		switch {
+		case lo == hi:
+			result = append(result, lo)
+		case lo.X <= hi.X:
+			result = append(result, lo, hi)
+		default:
+			result = append(result, hi, lo)
+		}
+		i = j
+	}
+
+	return result
+}
@@ -0,0 +1,94 @@
+package downsample
+
+import (
+	"math"
+	"testing"
+)
+
+// trace - 4 Гц сигнал с узкой децелерацией на 300-й секунде
+func trace(seconds int) []Point {
+	points := make([]Point, 0, seconds*4)
+	for i := 0; i < seconds*4; i++ {
+		x := float64(i) / 4
+		y := 140 + 5*math.Sin(x/10)
+		if x >= 300 && x < 305 {
+			y = 90
+		}
+		points = append(points, Point{X: x, Y: y})
+	}
+	return points
+}
+
+func TestLTTB(t *testing.T) {
+	points := trace(600)
+
+	sampled := LTTB(points, 200)
+	if len(sampled) != 200 {
+		t.Fatalf("len = %d, want 200", len(sampled))
+	}
+	if sampled[0] != points[0] || sampled[len(sampled)-1] != points[len(points)-1] {
+		t.Errorf("first/last points not preserved")
+	}
+	assertSorted(t, sampled)
+	assertHasValue(t, sampled, 90)
+
+	if got := LTTB(points[:10], 200); len(got) != 10 {
+		t.Errorf("short trace changed: %d points", len(got))
+	}
+}
+
+func TestMinMax(t *testing.T) {
+	points := trace(600)
+
+	sampled := MinMax(points, 100)
+	if len(sampled) > 100 || len(sampled) < 50 {
+		t.Fatalf("len = %d, want 50..100", len(sampled))
+	}
+	assertSorted(t, sampled)
+	assertHasValue(t, sampled, 90)
+}
+
+func TestMinMaxBucketsSkipsGaps(t *testing.T) {
+	points := []Point{{0, 1}, {0.5, 3}, {1, 2}, {100, 5}, {100.2, 4}}
+
+	got := MinMaxBuckets(points, 1)
+	want := []Point{{0, 1}, {0.5, 3}, {1, 2}, {100, 5}, {100.2, 4}}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestParseMethod(t *testing.T) {
+	for in, want := range map[string]Method{"": MethodLTTB, "lttb": MethodLTTB, "minmax": MethodMinMax} {
+		if got, err := ParseMethod(in); err != nil || got != want {
+			t.Errorf("ParseMethod(%q) = %v, %v", in, got, err)
+		}
+	}
+	if _, err := ParseMethod("avg"); err == nil {
+		t.Error("expected error for unknown method")
+	}
+}
+
+func assertSorted(t *testing.T, points []Point) {
+	t.Helper()
+	for i := 1; i < len(points); i++ {
+		if points[i].X < points[i-1].X {
+			t.Fatalf("points not sorted at %d: %v < %v", i, points[i].X, points[i-1].X)
+		}
+	}
+}
+
+func assertHasValue(t *testing.T, points []Point, y float64) {
+	t.Helper()
+	for _, p := range points {
+		if p.Y == y {
+			return
+		}
+	}
+	t.Errorf("value %v lost after downsampling", y)
+}
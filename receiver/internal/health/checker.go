@@ -0,0 +1,260 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/Krimson/fetal-monitory/logging"
+)
+
+// Статусы зависимостей и готовности
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusReady    = "ready"     // Все зависимости доступны
+	StatusDegraded = "degraded"  // Недоступны только некритичные зависимости
+	StatusNotReady = "not_ready" // Недоступна хотя бы одна критичная зависимость
+)
+
+// Dependency - внешняя зависимость, которую проверяет готовность
+type Dependency struct {
+	Name     string
+	Critical bool // Недоступность переводит сервис в NOT_SERVING и /readyz в 503
+	Check    func(ctx context.Context) error
+}
+
+// DependencyStatus - результат последней проверки зависимости
+type DependencyStatus struct {
+	Status    string    `json:"status"` // up или down
+	Critical  bool      `json:"critical"`
+	LatencyMS float64   `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+	Since     time.Time `json:"since"` // Когда зависимость перешла в текущий статус
+}
+
+// Report - состояние готовности сервиса
+type Report struct {
+	Status       string                      `json:"status"`
+	CheckedAt    time.Time                   `json:"checked_at"`
+	Dependencies map[string]DependencyStatus `json:"dependencies"`
+}
+
+// Ready сообщает, доступны ли все критичные зависимости
+func (r Report) Ready() bool {
+	return r.Status != StatusNotReady
+}
+
+// Checker периодически проверяет зависимости, хранит последний отчет для /readyz
+// и переключает статусы gRPC health сервера
+type Checker struct {
+	deps     []Dependency
+	interval time.Duration
+	timeout  time.Duration
+
+	server   *HealthServer
+	services []string
+
+	mu     sync.RWMutex
+	report Report
+
+	stopChan chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewChecker создает проверку зависимостей: каждая проверяется раз в interval
+// с ограничением timeout
+func NewChecker(deps []Dependency, interval, timeout time.Duration) *Checker {
+	return &Checker{
+		deps:     deps,
+		interval: interval,
+		timeout:  timeout,
+		report: Report{
+			Status:       StatusNotReady,
+			Dependencies: make(map[string]DependencyStatus),
+		},
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// SetHealthServer подключает gRPC health сервер: services получают SERVING,
+// пока доступны критичные зависимости, и NOT_SERVING в остальное время
+func (c *Checker) SetHealthServer(server *HealthServer, services ...string) {
+	c.server = server
+	c.services = services
+}
+
+// Start выполняет первую проверку синхронно и запускает периодические
+func (c *Checker) Start() {
+	c.Run(context.Background())
+	go c.loop()
+}
+
+// Stop останавливает проверки; статусы gRPC сервера больше не меняются
+func (c *Checker) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stopChan)
+		<-c.done
+	})
+}
+
+func (c *Checker) loop() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.Run(context.Background())
+		case <-c.stopChan:
+			return
+		}
+	}
+}
+
+// Run проверяет все зависимости параллельно и обновляет отчет
+func (c *Checker) Run(ctx context.Context) Report {
+	results := make([]DependencyStatus, len(c.deps))
+
+	var wg sync.WaitGroup
+	for i, dep := range c.deps {
+		wg.Add(1)
+		go func(i int, dep Dependency) {
+			defer wg.Done()
+			results[i] = c.check(ctx, dep)
+		}(i, dep)
+	}
+	wg.Wait()
+
+	c.mu.Lock()
+	previous := c.report
+	report := Report{
+		Status:       StatusReady,
+		CheckedAt:    time.Now(),
+		Dependencies: make(map[string]DependencyStatus, len(c.deps)),
+	}
+	for i, dep := range c.deps {
+		result := results[i]
+		prev, seen := previous.Dependencies[dep.Name]
+		switch {
+		case seen && prev.Status == result.Status:
+			result.Since = prev.Since
+		case result.Status == StatusDown:
+			slog.Warn("Dependency is down", "dependency", dep.Name, "error", result.Error)
+		case seen:
+			slog.Info("Dependency is up again", "dependency", dep.Name)
+		}
+		report.Dependencies[dep.Name] = result
+
+		if result.Status == StatusDown {
+			if dep.Critical {
+				report.Status = StatusNotReady
+			} else if report.Status == StatusReady {
+				report.Status = StatusDegraded
+			}
+		}
+	}
+	c.report = report
+	c.mu.Unlock()
+
+	if c.server != nil {
+		select {
+		case <-c.stopChan:
+			// После Stop статусами управляет завершение работы
+		default:
+			for _, service := range c.services {
+				if report.Ready() {
+					c.server.SetServingStatus(service)
+				} else {
+					c.server.SetNotServingStatus(service)
+				}
+			}
+		}
+	}
+
+	return report
+}
+
+func (c *Checker) check(ctx context.Context, dep Dependency) DependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := dep.Check(ctx)
+	result := DependencyStatus{
+		Status:    StatusUp,
+		Critical:  dep.Critical,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: time.Now(),
+		Since:     time.Now(),
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Report возвращает последний отчет
+func (c *Checker) Report() Report {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.report
+}
+
+// ReadyHandler - /readyz: 200, если критичные зависимости доступны, иначе 503.
+// В ответе - состояние каждой зависимости по последней проверке
+func (c *Checker) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	report := c.Report()
+
+	code := http.StatusOK
+	if !report.Ready() {
+		code = http.StatusServiceUnavailable
+	}
+	respondJSON(w, code, report)
+}
+
+// LiveHandler - /healthz: процесс жив и обслуживает HTTP. Зависимости не проверяются:
+// перезапуск receiver не вернет недоступный Redis или PostgreSQL
+func LiveHandler(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func respondJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		slog.Error("Failed to encode JSON response", logging.Err(err))
+	}
+}
+
+// CheckGRPC проверяет сервер за соединением conn через grpc.health.v1.
+// Сервер без health сервиса (Unimplemented) считается доступным: он ответил на вызов
+func CheckGRPC(ctx context.Context, conn grpc.ClientConnInterface, service string) error {
+	resp, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: service})
+	if status.Code(err) == codes.Unimplemented {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if resp.GetStatus() != grpc_health_v1.HealthCheckResponse_SERVING {
+		return fmt.Errorf("status %s", resp.GetStatus())
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func servingStatus(t *testing.T, s *HealthServer, service string) grpc_health_v1.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	resp, err := s.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: service})
+	if err != nil {
+		t.Fatalf("Check(%q) failed: %v", service, err)
+	}
+	return resp.Status
+}
+
+func TestCheckerReadiness(t *testing.T) {
+	var redisErr, mlErr error
+	checker := NewChecker([]Dependency{
+		{Name: "redis", Critical: true, Check: func(ctx context.Context) error { return redisErr }},
+		{Name: "ml_service", Check: func(ctx context.Context) error { return mlErr }},
+	}, time.Hour, time.Second)
+
+	server := NewHealthServer()
+	checker.SetHealthServer(server, "", "telemetry.v1.DataService")
+
+	tests := []struct {
+		name           string
+		redisErr       error
+		mlErr          error
+		expectedStatus string
+		expectedCode   int
+		expectedGRPC   grpc_health_v1.HealthCheckResponse_ServingStatus
+	}{
+		{"all up", nil, nil, StatusReady, http.StatusOK, grpc_health_v1.HealthCheckResponse_SERVING},
+		{"non-critical down", nil, errors.New("unavailable"), StatusDegraded, http.StatusOK, grpc_health_v1.HealthCheckResponse_SERVING},
+		{"critical down", errors.New("connection refused"), nil, StatusNotReady, http.StatusServiceUnavailable, grpc_health_v1.HealthCheckResponse_NOT_SERVING},
+		{"recovered", nil, nil, StatusReady, http.StatusOK, grpc_health_v1.HealthCheckResponse_SERVING},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisErr, mlErr = tt.redisErr, tt.mlErr
+			checker.Run(context.Background())
+
+			rec := httptest.NewRecorder()
+			checker.ReadyHandler(rec, httptest.NewRequest("GET", "/readyz", nil))
+			if rec.Code != tt.expectedCode {
+				t.Errorf("Expected HTTP %d, got %d", tt.expectedCode, rec.Code)
+			}
+
+			var report Report
+			if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+				t.Fatalf("Invalid JSON: %v", err)
+			}
+			if report.Status != tt.expectedStatus {
+				t.Errorf("Expected status %s, got %s", tt.expectedStatus, report.Status)
+			}
+			if redis := report.Dependencies["redis"]; (redis.Status == StatusDown) != (tt.redisErr != nil) || !redis.Critical {
+				t.Errorf("Unexpected redis status: %+v", redis)
+			}
+			if tt.redisErr != nil && report.Dependencies["redis"].Error != tt.redisErr.Error() {
+				t.Errorf("Expected redis error %q, got %q", tt.redisErr, report.Dependencies["redis"].Error)
+			}
+
+			for _, service := range []string{"", "telemetry.v1.DataService"} {
+				if got := servingStatus(t, server, service); got != tt.expectedGRPC {
+					t.Errorf("Expected gRPC status %s for %q, got %s", tt.expectedGRPC, service, got)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckerTimeout(t *testing.T) {
+	checker := NewChecker([]Dependency{
+		{Name: "postgres", Critical: true, Check: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+	}, time.Hour, 20*time.Millisecond)
+
+	start := time.Now()
+	report := checker.Run(context.Background())
+	if time.Since(start) > time.Second {
+		t.Errorf("Check was not limited by timeout")
+	}
+	if report.Ready() || report.Dependencies["postgres"].Status != StatusDown {
+		t.Errorf("Expected postgres down, got %+v", report)
+	}
+}
+
+func TestCheckerStopKeepsShutdownStatus(t *testing.T) {
+	checker := NewChecker([]Dependency{
+		{Name: "redis", Critical: true, Check: func(ctx context.Context) error { return nil }},
+	}, 5*time.Millisecond, time.Second)
+	server := NewHealthServer()
+	checker.SetHealthServer(server, "")
+
+	checker.Start()
+	checker.Stop()
+	server.SetNotServingStatus("")
+	time.Sleep(20 * time.Millisecond)
+
+	if got := servingStatus(t, server, ""); got != grpc_health_v1.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Expected NOT_SERVING after Stop, got %s", got)
+	}
+}
+
+func TestCheckGRPC(t *testing.T) {
+	listener := bufconn.Listen(1 << 16)
+	grpcServer := grpc.NewServer()
+	server := NewHealthServer()
+	grpc_health_v1.RegisterHealthServer(grpcServer, server)
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := CheckGRPC(ctx, conn, ""); err != nil {
+		t.Errorf("Expected healthy server, got %v", err)
+	}
+
+	// Watch получает изменение статуса
+	stream, err := grpc_health_v1.NewHealthClient(conn).Watch(ctx, &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	if resp, err := stream.Recv(); err != nil || resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		t.Fatalf("Expected initial SERVING, got %v %v", resp, err)
+	}
+
+	server.SetNotServingStatus("")
+	if err := CheckGRPC(ctx, conn, ""); err == nil {
+		t.Error("Expected error for NOT_SERVING server")
+	}
+	if resp, err := stream.Recv(); err != nil || resp.Status != grpc_health_v1.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Expected NOT_SERVING update, got %v %v", resp, err)
+	}
+
+	if err := CheckGRPC(ctx, conn, "unknown.Service"); err == nil {
+		t.Error("Expected error for unknown service")
+	}
+}
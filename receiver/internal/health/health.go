@@ -13,27 +13,25 @@ type HealthServer struct {
 	grpc_health_v1.UnimplementedHealthServer
 	mu       sync.RWMutex
 	services map[string]grpc_health_v1.HealthCheckResponse_ServingStatus
+
+	// Подписчики Watch по сервисам; получают статус при каждом изменении
+	watchers map[string]map[chan grpc_health_v1.HealthCheckResponse_ServingStatus]struct{}
 }
 
 func NewHealthServer() *HealthServer {
 	return &HealthServer{
 		services: make(map[string]grpc_health_v1.HealthCheckResponse_ServingStatus),
+		watchers: make(map[string]map[chan grpc_health_v1.HealthCheckResponse_ServingStatus]struct{}),
 	}
 }
 
+// Check возвращает статус сервиса. Пустое имя - сервер целиком: пока статус
+// не задан явно, считается SERVING
 func (h *HealthServer) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
-	service := req.GetService()
-
-	if service == "" {
-		return &grpc_health_v1.HealthCheckResponse{
-			Status: grpc_health_v1.HealthCheckResponse_SERVING,
-		}, nil
-	}
-
-	servingStatus, exists := h.services[service]
+	servingStatus, exists := h.statusLocked(req.GetService())
 	if !exists {
 		return nil, status.Error(codes.NotFound, "service not found")
 	}
@@ -43,18 +41,50 @@ func (h *HealthServer) Check(ctx context.Context, req *grpc_health_v1.HealthChec
 	}, nil
 }
 
+// Watch отправляет текущий статус сервиса и затем каждое его изменение.
+// Для неизвестного сервиса отправляется SERVICE_UNKNOWN, как требует grpc.health.v1
 func (h *HealthServer) Watch(req *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
-	response, err := h.Check(stream.Context(), req)
-	if err != nil {
-		return err
+	service := req.GetService()
+	updates := make(chan grpc_health_v1.HealthCheckResponse_ServingStatus, 1)
+
+	h.mu.Lock()
+	current, exists := h.statusLocked(service)
+	if !exists {
+		current = grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN
+	}
+	if h.watchers[service] == nil {
+		h.watchers[service] = make(map[chan grpc_health_v1.HealthCheckResponse_ServingStatus]struct{})
 	}
+	h.watchers[service][updates] = struct{}{}
+	h.mu.Unlock()
+
+	defer func() {
+		h.mu.Lock()
+		delete(h.watchers[service], updates)
+		if len(h.watchers[service]) == 0 {
+			delete(h.watchers, service)
+		}
+		h.mu.Unlock()
+	}()
 
-	if err := stream.Send(response); err != nil {
+	if err := stream.Send(&grpc_health_v1.HealthCheckResponse{Status: current}); err != nil {
 		return err
 	}
 
-	<-stream.Context().Done()
-	return stream.Context().Err()
+	for {
+		select {
+		case next := <-updates:
+			if next == current {
+				continue
+			}
+			current = next
+			if err := stream.Send(&grpc_health_v1.HealthCheckResponse{Status: current}); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
 }
 
 func (h *HealthServer) SetServingStatus(service string) {
@@ -69,4 +99,22 @@ func (h *HealthServer) setStatus(service string, status grpc_health_v1.HealthChe
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	h.services[service] = status
+
+	for updates := range h.watchers[service] {
+		// Подписчику важен только последний статус: заменяем непрочитанный
+		select {
+		case <-updates:
+		default:
+		}
+		updates <- status
+	}
+}
+
+// statusLocked возвращает статус сервиса (вызывается под h.mu)
+func (h *HealthServer) statusLocked(service string) (grpc_health_v1.HealthCheckResponse_ServingStatus, bool) {
+	servingStatus, exists := h.services[service]
+	if !exists && service == "" {
+		return grpc_health_v1.HealthCheckResponse_SERVING, true
+	}
+	return servingStatus, exists
 }
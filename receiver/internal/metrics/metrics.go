@@ -0,0 +1,32 @@
+// Package metrics отдает метрики receiver в формате Prometheus.
+//
+// Метрики конвейера собраны в тип Receiver поверх prometheus/client_golang;
+// у Receiver собственный реестр, поэтому тесты и несколько экземпляров
+// не делят глобальное состояние.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// LatencyBuckets - границы гистограмм длительности вызовов, в секундах
+var LatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// newRegistry создает реестр со стандартными метриками процесса и рантайма Go
+func newRegistry() *prometheus.Registry {
+	r := prometheus.NewRegistry()
+	r.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return r
+}
+
+// handlerFor отдает метрики реестра (GET /metrics)
+func handlerFor(r *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(r, promhttp.HandlerOpts{Registry: r})
+}
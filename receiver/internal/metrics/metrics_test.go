@@ -0,0 +1,171 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	telemetryv1 "github.com/Krimson/fetal-monitory/proto/telemetry"
+)
+
+func TestReceiverSampleCounters(t *testing.T) {
+	m := NewReceiver()
+	m.SampleReceived(telemetryv1.Metric_METRIC_FHR)
+	m.SampleReceived(telemetryv1.Metric_METRIC_FHR)
+	m.SamplesDropped(telemetryv1.Metric_METRIC_UC, DropTooOld, 3)
+	m.SamplesDropped(telemetryv1.Metric_METRIC_UC, DropTooOld, -5)
+	m.SamplesFlushed(telemetryv1.Metric_METRIC_FHR, 0)
+
+	if got := testutil.ToFloat64(m.samplesReceived.WithLabelValues("fhr")); got != 2 {
+		t.Errorf("Expected 2 received FHR samples, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.samplesDropped.WithLabelValues("uc", DropTooOld)); got != 3 {
+		t.Errorf("Expected 3 dropped UC samples, got %v", got)
+	}
+	if got := testutil.CollectAndCount(m.samplesFlushed); got != 0 {
+		t.Errorf("Expected no flushed series for empty batch, got %d", got)
+	}
+}
+
+func TestReceiverNilSafe(t *testing.T) {
+	var m *Receiver
+	m.SampleReceived(telemetryv1.Metric_METRIC_FHR)
+	m.SamplesDropped(telemetryv1.Metric_METRIC_UC, DropTooOld, 2)
+	m.SampleOutOfOrder(telemetryv1.Metric_METRIC_FHR)
+	m.SamplesFlushed(telemetryv1.Metric_METRIC_FHR, 10)
+	m.ObserveFeatureExtractor(time.Second, nil)
+	m.ObserveMLService(time.Second, errors.New("unavailable"))
+}
+
+func TestReceiverMetrics(t *testing.T) {
+	m := NewReceiver()
+	m.ObserveFeatureExtractor(30*time.Millisecond, nil)
+	m.ObserveMLService(2*time.Second, errors.New("unavailable"))
+	m.TrackChannel("prediction", func() int { return 4 })
+	m.TrackActiveSessions(func() int { return 2 })
+	m.TrackWebSocketClients(func() int { return 5 })
+
+	expected := `# HELP receiver_channel_depth Items waiting in internal pipeline channels.
+# TYPE receiver_channel_depth gauge
+receiver_channel_depth{channel="prediction"} 4
+# HELP receiver_active_sessions Active monitoring sessions.
+# TYPE receiver_active_sessions gauge
+receiver_active_sessions 2
+# HELP receiver_websocket_clients Connected WebSocket clients.
+# TYPE receiver_websocket_clients gauge
+receiver_websocket_clients 5
+`
+	if err := testutil.GatherAndCompare(m.registry, strings.NewReader(expected),
+		"receiver_channel_depth", "receiver_active_sessions", "receiver_websocket_clients"); err != nil {
+		t.Error(err)
+	}
+
+	if got := testutil.CollectAndCount(m.featureExtractorDuration); got != 1 {
+		t.Errorf("Expected 1 feature extractor series, got %d", got)
+	}
+	if got := testutil.CollectAndCount(m.mlServiceDuration); got != 1 {
+		t.Errorf("Expected 1 ML service series, got %d", got)
+	}
+
+	// Обработчик отдает и метрики конвейера, и метрики процесса
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, line := range []string{
+		`receiver_feature_extractor_duration_seconds_bucket{outcome="success",le="0.05"} 1`,
+		`receiver_ml_service_duration_seconds_count{outcome="error"} 1`,
+		`go_goroutines `,
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("Expected %q in metrics output:\n%s", line, body)
+		}
+	}
+}
+
+// fakeConnector - драйвер, возвращающий заданные ошибки
+type fakeConnector struct {
+	execErr   error
+	commitErr error
+}
+
+func (c *fakeConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &fakeConn{connector: c}, nil
+}
+
+func (c *fakeConnector) Driver() driver.Driver { return nil }
+
+type fakeConn struct {
+	connector *fakeConnector
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return &fakeTx{connector: c.connector}, nil }
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if c.connector.execErr != nil {
+		return nil, c.connector.execErr
+	}
+	return driver.RowsAffected(1), nil
+}
+
+type fakeTx struct {
+	connector *fakeConnector
+}
+
+func (t *fakeTx) Commit() error   { return t.connector.commitErr }
+func (t *fakeTx) Rollback() error { return nil }
+
+func TestInstrumentPostgres(t *testing.T) {
+	m := NewReceiver()
+	connector := &fakeConnector{}
+	db := sql.OpenDB(m.InstrumentPostgres(connector))
+	defer db.Close()
+	ctx := context.Background()
+
+	if _, err := db.ExecContext(ctx, "UPDATE sessions SET status = 'stopped'"); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+
+	connector.execErr = errors.New("relation does not exist")
+	if _, err := db.ExecContext(ctx, "UPDATE missing SET x = 1"); err == nil {
+		t.Fatal("Expected exec error")
+	}
+	connector.execErr = context.Canceled
+	db.ExecContext(ctx, "SELECT pg_sleep(10)")
+	connector.execErr = nil
+
+	// Без PrepareContext и QueryContext у драйвера database/sql уходит в Prepare
+	if _, err := db.QueryContext(ctx, "SELECT 1"); err == nil {
+		t.Fatal("Expected query error")
+	}
+
+	connector.commitErr = errors.New("serialization failure")
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if err := tx.Commit(); err == nil {
+		t.Fatal("Expected commit error")
+	}
+
+	expected := `# HELP receiver_postgres_errors_total Failed PostgreSQL operations.
+# TYPE receiver_postgres_errors_total counter
+receiver_postgres_errors_total{operation="commit"} 1
+receiver_postgres_errors_total{operation="exec"} 1
+receiver_postgres_errors_total{operation="prepare"} 1
+`
+	// Без PrepareContext у драйвера ошибка запроса учитывается как prepare, а не query
+	if err := testutil.CollectAndCompare(m.postgresErrors, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
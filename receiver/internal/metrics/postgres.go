@@ -0,0 +1,175 @@
+package metrics
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// InstrumentPostgres оборачивает коннектор database/sql так, что ошибки подключения,
+// запросов, подготовки выражений и транзакций учитываются по виду операции.
+// Используется вместе с sql.OpenDB
+func (m *Receiver) InstrumentPostgres(connector driver.Connector) driver.Connector {
+	return &pgConnector{Connector: connector, errors: m.postgresErrors}
+}
+
+type pgConnector struct {
+	driver.Connector
+	errors *prometheus.CounterVec
+}
+
+func (c *pgConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		c.count("connect", err)
+		return nil, err
+	}
+	return &pgConn{Conn: conn, connector: c}, nil
+}
+
+// count учитывает ошибку операции; ErrSkip и отмена запроса клиентом - не ошибки БД
+func (c *pgConnector) count(operation string, err error) {
+	if err == nil || errors.Is(err, driver.ErrSkip) || errors.Is(err, context.Canceled) {
+		return
+	}
+	c.errors.WithLabelValues(operation).Inc()
+}
+
+// pgConn пробрасывает необязательные интерфейсы драйвера: database/sql выбирает
+// поведение по ним, поэтому обертка реализует все, что реализует lib/pq
+type pgConn struct {
+	driver.Conn
+	connector *pgConnector
+}
+
+func (c *pgConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	res, err := execer.ExecContext(ctx, query, args)
+	c.connector.count("exec", err)
+	return res, err
+}
+
+func (c *pgConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	rows, err := queryer.QueryContext(ctx, query, args)
+	c.connector.count("query", err)
+	return rows, err
+}
+
+func (c *pgConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	c.connector.count("prepare", err)
+	if err != nil {
+		return nil, err
+	}
+	return &pgStmt{Stmt: stmt, connector: c.connector}, nil
+}
+
+func (c *pgConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var (
+		tx  driver.Tx
+		err error
+	)
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = beginner.BeginTx(ctx, opts)
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	c.connector.count("begin", err)
+	if err != nil {
+		return nil, err
+	}
+	return &pgTx{Tx: tx, connector: c.connector}, nil
+}
+
+func (c *pgConn) Ping(ctx context.Context) error {
+	pinger, ok := c.Conn.(driver.Pinger)
+	if !ok {
+		return nil
+	}
+	err := pinger.Ping(ctx)
+	c.connector.count("ping", err)
+	return err
+}
+
+func (c *pgConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *pgConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+type pgStmt struct {
+	driver.Stmt
+	connector *pgConnector
+}
+
+func (s *pgStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	var (
+		res driver.Result
+		err error
+	)
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = execer.ExecContext(ctx, args)
+	} else {
+		res, err = s.Stmt.Exec(values(args))
+	}
+	s.connector.count("exec", err)
+	return res, err
+}
+
+func (s *pgStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var (
+		rows driver.Rows
+		err  error
+	)
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(values(args))
+	}
+	s.connector.count("query", err)
+	return rows, err
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	result := make([]driver.Value, len(args))
+	for i, arg := range args {
+		result[i] = arg.Value
+	}
+	return result
+}
+
+type pgTx struct {
+	driver.Tx
+	connector *pgConnector
+}
+
+func (t *pgTx) Commit() error {
+	err := t.Tx.Commit()
+	t.connector.count("commit", err)
+	return err
+}
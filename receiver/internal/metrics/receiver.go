@@ -0,0 +1,191 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	telemetryv1 "github.com/Krimson/fetal-monitory/proto/telemetry"
+)
+
+// Причины отбрасывания сэмплов
+const (
+	DropInvalid = "invalid"         // Не прошел валидацию
+	DropTooOld  = "too_old"         // Старше DROP_TOO_OLD_MS относительно последнего сэмпла
+	DropStopped = "batcher_stopped" // Батч сброшен после остановки Batcher
+)
+
+// Receiver - метрики конвейера receiver. Методы безопасны для nil: компоненты,
+// созданные без метрик (например, в тестах), ничего не считают
+type Receiver struct {
+	registry *prometheus.Registry
+
+	samplesReceived   *prometheus.CounterVec
+	samplesDropped    *prometheus.CounterVec
+	samplesOutOfOrder *prometheus.CounterVec
+	samplesFlushed    *prometheus.CounterVec
+
+	featureExtractorDuration *prometheus.HistogramVec
+	mlServiceDuration        *prometheus.HistogramVec
+
+	redisErrors    *prometheus.CounterVec
+	postgresErrors *prometheus.CounterVec
+}
+
+// NewReceiver создает метрики receiver в собственном реестре
+func NewReceiver() *Receiver {
+	m := &Receiver{
+		registry: newRegistry(),
+
+		samplesReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "receiver_samples_received_total",
+			Help: "Samples accepted into batches.",
+		}, []string{"metric"}),
+		samplesDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "receiver_samples_dropped_total",
+			Help: "Samples dropped before reaching the outbound queue.",
+		}, []string{"metric", "reason"}),
+		samplesOutOfOrder: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "receiver_samples_out_of_order_total",
+			Help: "Samples older than the latest sample of their channel by more than OUT_OF_ORDER_TOLERANCE_MS.",
+		}, []string{"metric"}),
+		samplesFlushed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "receiver_samples_flushed_total",
+			Help: "Samples in batches flushed to the outbound queue.",
+		}, []string{"metric"}),
+
+		featureExtractorDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "receiver_feature_extractor_duration_seconds",
+			Help:    "Feature extractor ProcessBatch latency.",
+			Buckets: LatencyBuckets,
+		}, []string{"outcome"}),
+		mlServiceDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "receiver_ml_service_duration_seconds",
+			Help:    "ML service PredictFromFeatures latency.",
+			Buckets: LatencyBuckets,
+		}, []string{"outcome"}),
+
+		redisErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "receiver_redis_errors_total",
+			Help: "Failed Redis commands.",
+		}, []string{"command"}),
+		postgresErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "receiver_postgres_errors_total",
+			Help: "Failed PostgreSQL operations.",
+		}, []string{"operation"}),
+	}
+
+	m.registry.MustRegister(
+		m.samplesReceived,
+		m.samplesDropped,
+		m.samplesOutOfOrder,
+		m.samplesFlushed,
+		m.featureExtractorDuration,
+		m.mlServiceDuration,
+		m.redisErrors,
+		m.postgresErrors,
+	)
+	return m
+}
+
+// Handler отдает метрики в формате Prometheus
+func (m *Receiver) Handler() http.Handler {
+	return handlerFor(m.registry)
+}
+
+// SampleReceived учитывает сэмпл, принятый в батч
+func (m *Receiver) SampleReceived(metric telemetryv1.Metric) {
+	if m == nil {
+		return
+	}
+	m.samplesReceived.WithLabelValues(metricLabel(metric)).Inc()
+}
+
+// SamplesDropped учитывает n отброшенных сэмплов
+func (m *Receiver) SamplesDropped(metric telemetryv1.Metric, reason string, n int) {
+	if m == nil {
+		return
+	}
+	if n > 0 {
+		m.samplesDropped.WithLabelValues(metricLabel(metric), reason).Add(float64(n))
+	}
+}
+
+// SampleOutOfOrder учитывает сэмпл, пришедший не по порядку
+func (m *Receiver) SampleOutOfOrder(metric telemetryv1.Metric) {
+	if m == nil {
+		return
+	}
+	m.samplesOutOfOrder.WithLabelValues(metricLabel(metric)).Inc()
+}
+
+// SamplesFlushed учитывает n сэмплов канала в сброшенном батче
+func (m *Receiver) SamplesFlushed(metric telemetryv1.Metric, n int) {
+	if m == nil {
+		return
+	}
+	if n > 0 {
+		m.samplesFlushed.WithLabelValues(metricLabel(metric)).Add(float64(n))
+	}
+}
+
+// ObserveFeatureExtractor учитывает длительность вызова feature extractor
+func (m *Receiver) ObserveFeatureExtractor(d time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.featureExtractorDuration.WithLabelValues(outcomeLabel(err)).Observe(d.Seconds())
+}
+
+// ObserveMLService учитывает длительность вызова ML сервиса
+func (m *Receiver) ObserveMLService(d time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.mlServiceDuration.WithLabelValues(outcomeLabel(err)).Observe(d.Seconds())
+}
+
+// TrackChannel публикует глубину внутреннего канала конвейера. Повторный вызов
+// с тем же именем канала - ошибка программы (паника при регистрации)
+func (m *Receiver) TrackChannel(name string, depth func() int) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "receiver_channel_depth",
+		Help:        "Items waiting in internal pipeline channels.",
+		ConstLabels: prometheus.Labels{"channel": name},
+	}, func() float64 { return float64(depth()) }))
+}
+
+// TrackActiveSessions публикует число активных сессий
+func (m *Receiver) TrackActiveSessions(count func() int) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "receiver_active_sessions",
+		Help: "Active monitoring sessions.",
+	}, func() float64 { return float64(count()) }))
+}
+
+// TrackWebSocketClients публикует число подключенных WebSocket клиентов
+func (m *Receiver) TrackWebSocketClients(count func() int) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "receiver_websocket_clients",
+		Help: "Connected WebSocket clients.",
+	}, func() float64 { return float64(count()) }))
+}
+
+func metricLabel(metric telemetryv1.Metric) string {
+	switch metric {
+	case telemetryv1.Metric_METRIC_FHR:
+		return "fhr"
+	case telemetryv1.Metric_METRIC_UC:
+		return "uc"
+	default:
+		return "unknown"
+	}
+}
+
+func outcomeLabel(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
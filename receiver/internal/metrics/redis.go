@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+// InstrumentRedis считает ошибки команд клиента Redis (redis.Nil - не ошибка)
+func (m *Receiver) InstrumentRedis(client *redis.Client) {
+	client.AddHook(redisHook{errors: m.redisErrors})
+}
+
+// redisHook учитывает ошибки по имени команды; ошибки подключения - как dial
+type redisHook struct {
+	errors *prometheus.CounterVec
+}
+
+func (h redisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := next(ctx, network, addr)
+		if isRedisError(err) {
+			h.errors.WithLabelValues("dial").Inc()
+		}
+		return conn, err
+	}
+}
+
+func (h redisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		if isRedisError(err) {
+			h.errors.WithLabelValues(cmd.Name()).Inc()
+		}
+		return err
+	}
+}
+
+func (h redisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		err := next(ctx, cmds)
+		for _, cmd := range cmds {
+			if isRedisError(cmd.Err()) {
+				h.errors.WithLabelValues(cmd.Name()).Inc()
+			}
+		}
+		return err
+	}
+}
+
+// isRedisError отсекает отсутствие ключа и отмену запроса клиентом
+func isRedisError(err error) bool {
+	return err != nil && !errors.Is(err, redis.Nil) && !errors.Is(err, context.Canceled)
+}
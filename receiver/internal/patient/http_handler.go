@@ -0,0 +1,238 @@
+package patient
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Krimson/fetal-monitory/logging"
+)
+
+// HTTPHandler обрабатывает HTTP запросы карточек пациенток (Presentation Layer)
+type HTTPHandler struct {
+	service *Service
+}
+
+// NewHTTPHandler создает новый HTTP обработчик
+func NewHTTPHandler(service *Service) *HTTPHandler {
+	return &HTTPHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes регистрирует маршруты в роутере
+func (h *HTTPHandler) RegisterRoutes(router *mux.Router) {
+	api := router.PathPrefix("/api/patients").Subrouter()
+
+	api.HandleFunc("", h.CreatePatient).Methods("POST", "OPTIONS")
+	api.HandleFunc("", h.ListPatients).Methods("GET", "OPTIONS")
+	api.HandleFunc("/{id}", h.GetPatient).Methods("GET", "OPTIONS")
+	api.HandleFunc("/{id}", h.UpdatePatient).Methods("PUT", "OPTIONS")
+	api.HandleFunc("/{id}", h.DeletePatient).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/{id}/sessions", h.GetPatientSessions).Methods("GET", "OPTIONS")
+}
+
+// CreatePatient заводит карточку пациентки
+// @Summary Создать карточку пациентки
+// @Description Заводит карточку пациентки. Если id не задан, он генерируется. Сессии, в метаданных которых уже указан этот patient_id, привязываются к карточке
+// @Tags Patients
+// @Accept json
+// @Produce json
+// @Param request body PatientRequest true "Данные пациентки"
+// @Success 201 {object} Patient "Карточка создана"
+// @Failure 400 {object} map[string]interface{} "Неверные данные"
+// @Failure 409 {object} map[string]interface{} "Пациентка с таким ID или номером карты уже есть"
+// @Failure 500 {object} map[string]interface{} "Ошибка сервера"
+// @Security BearerAuth
+// @Router /api/patients [post]
+func (h *HTTPHandler) CreatePatient(w http.ResponseWriter, r *http.Request) {
+	var req PatientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	patient, err := h.service.Create(r.Context(), &req)
+	if err != nil {
+		respondServiceError(w, "create", err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, patient)
+}
+
+// ListPatients возвращает список карточек
+// @Summary Список пациенток
+// @Description Возвращает карточки пациенток по ФИО; q ищет по ID, номеру карты и ФИО
+// @Tags Patients
+// @Produce json
+// @Param q query string false "Поиск"
+// @Param limit query int false "Количество карточек на странице" default(50)
+// @Param offset query int false "Смещение от начала списка" default(0)
+// @Success 200 {object} map[string]interface{} "Список пациенток"
+// @Failure 500 {object} map[string]interface{} "Ошибка сервера"
+// @Security BearerAuth
+// @Router /api/patients [get]
+func (h *HTTPHandler) ListPatients(w http.ResponseWriter, r *http.Request) {
+	limit := getQueryInt(r, "limit", 50)
+	offset := getQueryInt(r, "offset", 0)
+
+	patients, err := h.service.List(r.Context(), r.URL.Query().Get("q"), limit, offset)
+	if err != nil {
+		slog.Error("Failed to list patients", logging.Err(err))
+		respondError(w, http.StatusInternalServerError, "Failed to list patients")
+		return
+	}
+	if patients == nil {
+		patients = []*Patient{}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"patients": patients,
+		"limit":    limit,
+		"offset":   offset,
+		"count":    len(patients),
+	})
+}
+
+// GetPatient возвращает карточку пациентки
+// @Summary Получить карточку пациентки
+// @Description Возвращает карточку с текущим сроком беременности, вычисленным по ПДР
+// @Tags Patients
+// @Produce json
+// @Param id path string true "ID пациентки"
+// @Success 200 {object} Patient "Карточка"
+// @Failure 404 {object} map[string]interface{} "Пациентка не найдена"
+// @Security BearerAuth
+// @Router /api/patients/{id} [get]
+func (h *HTTPHandler) GetPatient(w http.ResponseWriter, r *http.Request) {
+	patient, err := h.service.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		respondServiceError(w, "get", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, patient)
+}
+
+// UpdatePatient заменяет поля карточки
+// @Summary Изменить карточку пациентки
+// @Description Заменяет все поля карточки, кроме ID
+// @Tags Patients
+// @Accept json
+// @Produce json
+// @Param id path string true "ID пациентки"
+// @Param request body PatientRequest true "Данные пациентки"
+// @Success 200 {object} Patient "Карточка изменена"
+// @Failure 400 {object} map[string]interface{} "Неверные данные"
+// @Failure 404 {object} map[string]interface{} "Пациентка не найдена"
+// @Failure 409 {object} map[string]interface{} "Номер карты занят"
+// @Security BearerAuth
+// @Router /api/patients/{id} [put]
+func (h *HTTPHandler) UpdatePatient(w http.ResponseWriter, r *http.Request) {
+	var req PatientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	patient, err := h.service.Update(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		respondServiceError(w, "update", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, patient)
+}
+
+// DeletePatient удаляет карточку
+// @Summary Удалить карточку пациентки
+// @Description Удаляет карточку; сессии сохраняются, но отвязываются от нее
+// @Tags Patients
+// @Produce json
+// @Param id path string true "ID пациентки"
+// @Success 200 {object} map[string]interface{} "Карточка удалена"
+// @Failure 404 {object} map[string]interface{} "Пациентка не найдена"
+// @Security BearerAuth
+// @Router /api/patients/{id} [delete]
+func (h *HTTPHandler) DeletePatient(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if err := h.service.Delete(r.Context(), id); err != nil {
+		respondServiceError(w, "delete", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message":    "Patient deleted successfully",
+		"patient_id": id,
+	})
+}
+
+// GetPatientSessions возвращает историю КТГ пациентки
+// @Summary История КТГ пациентки
+// @Description Возвращает сохраненные сессии пациентки по возрастанию времени со сроком беременности и итоговыми показателями,
+// @Description а также тренды базальной ЧСС, STV и предсказания ML между визитами (наклон - единиц в неделю)
+// @Tags Patients
+// @Produce json
+// @Param id path string true "ID пациентки"
+// @Success 200 {object} History "История визитов"
+// @Failure 404 {object} map[string]interface{} "Пациентка не найдена"
+// @Failure 500 {object} map[string]interface{} "Ошибка сервера"
+// @Security BearerAuth
+// @Router /api/patients/{id}/sessions [get]
+func (h *HTTPHandler) GetPatientSessions(w http.ResponseWriter, r *http.Request) {
+	history, err := h.service.History(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		respondServiceError(w, "get history of", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, history)
+}
+
+// respondServiceError переводит ошибку сервиса в HTTP статус
+func respondServiceError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case errors.Is(err, ErrInvalid):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrNotFound):
+		respondError(w, http.StatusNotFound, "Patient not found")
+	case errors.Is(err, ErrExists):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		slog.Error("Patient operation failed", "action", action, logging.Err(err))
+		respondError(w, http.StatusInternalServerError, "Failed to "+action+" patient")
+	}
+}
+
+func getQueryInt(r *http.Request, key string, defaultValue int) int {
+	valueStr := r.URL.Query().Get(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.Atoi(valueStr)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func respondJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		slog.Error("Failed to encode JSON response", logging.Err(err))
+	}
+}
+
+func respondError(w http.ResponseWriter, status int, message string) {
+	respondJSON(w, status, map[string]interface{}{
+		"error":  message,
+		"status": status,
+	})
+}
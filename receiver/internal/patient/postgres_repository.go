@@ -0,0 +1,263 @@
+package patient
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// pqUniqueViolation - код ошибки PostgreSQL при нарушении уникальности
+const pqUniqueViolation = "23505"
+
+// PostgresRepository реализует Repository для PostgreSQL (Infrastructure Layer)
+type PostgresRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresRepository создает репозиторий поверх общего пула соединений
+func NewPostgresRepository(db *sql.DB) *PostgresRepository {
+	return &PostgresRepository{
+		db: db,
+	}
+}
+
+const patientColumns = `id, COALESCE(mrn, ''), full_name, birth_date, edd, gravidity, parity, risk_factors, notes, created_at, updated_at`
+
+// Create сохраняет карточку и привязывает к ней сессии, в метаданных которых
+// уже указан этот patient_id
+func (r *PostgresRepository) Create(ctx context.Context, p *Patient) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO patients (id, mrn, full_name, birth_date, edd, gravidity, parity, risk_factors, notes, created_at, updated_at)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+
+	_, err = tx.ExecContext(ctx, query,
+		p.ID,
+		p.MRN,
+		p.FullName,
+		dateValue(p.BirthDate),
+		dateValue(p.EDD),
+		p.Gravidity,
+		p.Parity,
+		pq.Array(p.RiskFactors),
+		p.Notes,
+		p.CreatedAt,
+		p.UpdatedAt,
+	)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation {
+			return fmt.Errorf("%w: %s", ErrExists, p.ID)
+		}
+		return fmt.Errorf("failed to create patient: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE sessions SET patient_id = $1
+		WHERE patient_id IS NULL AND metadata->>'patient_id' = $1
+	`, p.ID)
+	if err != nil {
+		return fmt.Errorf("failed to link patient sessions: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (r *PostgresRepository) Get(ctx context.Context, id string) (*Patient, error) {
+	query := `SELECT ` + patientColumns + ` FROM patients WHERE id = $1`
+
+	p, err := scanPatient(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to get patient: %w", err)
+	}
+	return p, nil
+}
+
+func (r *PostgresRepository) Update(ctx context.Context, p *Patient) error {
+	query := `
+		UPDATE patients
+		SET mrn = NULLIF($2, ''), full_name = $3, birth_date = $4, edd = $5, gravidity = $6, parity = $7,
+			risk_factors = $8, notes = $9, updated_at = $10
+		WHERE id = $1
+	`
+
+	result, err := r.db.ExecContext(ctx, query,
+		p.ID,
+		p.MRN,
+		p.FullName,
+		dateValue(p.BirthDate),
+		dateValue(p.EDD),
+		p.Gravidity,
+		p.Parity,
+		pq.Array(p.RiskFactors),
+		p.Notes,
+		p.UpdatedAt,
+	)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation {
+			return fmt.Errorf("%w: mrn %s", ErrExists, p.MRN)
+		}
+		return fmt.Errorf("failed to update patient: %w", err)
+	}
+
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, p.ID)
+	}
+	return nil
+}
+
+// Delete удаляет карточку; ссылки сессий обнуляются внешним ключом (ON DELETE SET NULL)
+func (r *PostgresRepository) Delete(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM patients WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete patient: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) List(ctx context.Context, query string, limit, offset int) ([]*Patient, error) {
+	sqlQuery := `
+		SELECT ` + patientColumns + `
+		FROM patients
+		WHERE $1 = '' OR id ILIKE $2 OR mrn ILIKE $2 OR full_name ILIKE $2
+		ORDER BY full_name ASC, id ASC
+		LIMIT $3 OFFSET $4
+	`
+
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query) + "%"
+	rows, err := r.db.QueryContext(ctx, sqlQuery, query, pattern, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list patients: %w", err)
+	}
+	defer rows.Close()
+
+	var patients []*Patient
+	for rows.Next() {
+		p, err := scanPatient(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan patient: %w", err)
+		}
+		patients = append(patients, p)
+	}
+
+	return patients, rows.Err()
+}
+
+func (r *PostgresRepository) Visits(ctx context.Context, id string) ([]Visit, error) {
+	query := `
+		SELECT s.id, s.status, s.started_at, s.total_duration_ms,
+			m.baseline_heart_rate, m.stv, m.ltv, m.prediction,
+			COALESCE(m.total_accelerations, 0), COALESCE(m.total_decelerations, 0), COALESCE(m.late_decelerations, 0)
+		FROM sessions s
+		LEFT JOIN session_metrics m ON m.session_id = s.id
+		WHERE s.patient_id = $1
+		ORDER BY s.started_at ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get patient visits: %w", err)
+	}
+	defer rows.Close()
+
+	var visits []Visit
+	for rows.Next() {
+		var v Visit
+		var baseline, stv, ltv, prediction sql.NullFloat64
+		err := rows.Scan(
+			&v.SessionID,
+			&v.Status,
+			&v.StartedAt,
+			&v.DurationMs,
+			&baseline,
+			&stv,
+			&ltv,
+			&prediction,
+			&v.Accelerations,
+			&v.Decelerations,
+			&v.LateDecelerations,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan visit: %w", err)
+		}
+		v.BaselineHeartRate = nullFloat(baseline)
+		v.STV = nullFloat(stv)
+		v.LTV = nullFloat(ltv)
+		v.Prediction = nullFloat(prediction)
+		visits = append(visits, v)
+	}
+
+	return visits, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPatient(row rowScanner) (*Patient, error) {
+	var p Patient
+	var birthDate, edd sql.NullTime
+	err := row.Scan(
+		&p.ID,
+		&p.MRN,
+		&p.FullName,
+		&birthDate,
+		&edd,
+		&p.Gravidity,
+		&p.Parity,
+		pq.Array(&p.RiskFactors),
+		&p.Notes,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	p.BirthDate = nullDate(birthDate)
+	p.EDD = nullDate(edd)
+	if p.RiskFactors == nil {
+		p.RiskFactors = []string{}
+	}
+	return &p, nil
+}
+
+func dateValue(d *Date) interface{} {
+	if d == nil {
+		return nil
+	}
+	return d.Format(time.DateOnly)
+}
+
+func nullDate(t sql.NullTime) *Date {
+	if !t.Valid {
+		return nil
+	}
+	d := NewDate(t.Time.Year(), t.Time.Month(), t.Time.Day())
+	return &d
+}
+
+func nullFloat(f sql.NullFloat64) *float64 {
+	if !f.Valid {
+		return nil
+	}
+	return &f.Float64
+}
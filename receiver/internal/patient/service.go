@@ -0,0 +1,214 @@
+package patient
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Service управляет карточками пациенток (Application Layer)
+type Service struct {
+	repository Repository
+	now        func() time.Time
+}
+
+// NewService создает сервис пациенток
+func NewService(repository Repository) *Service {
+	return &Service{
+		repository: repository,
+		now:        time.Now,
+	}
+}
+
+// Create заводит карточку пациентки
+func (s *Service) Create(ctx context.Context, req *PatientRequest) (*Patient, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	p := &Patient{ID: strings.TrimSpace(req.ID), CreatedAt: now, UpdatedAt: now}
+	if p.ID == "" {
+		p.ID = uuid.NewString()
+	}
+	apply(p, req)
+
+	if err := s.repository.Create(ctx, p); err != nil {
+		return nil, err
+	}
+
+	slog.Info("Created patient", "patient_id", p.ID)
+	return s.withGestationalAge(p), nil
+}
+
+// Get возвращает карточку пациентки
+func (s *Service) Get(ctx context.Context, id string) (*Patient, error) {
+	p, err := s.repository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.withGestationalAge(p), nil
+}
+
+// Update заменяет поля карточки
+func (s *Service) Update(ctx context.Context, id string, req *PatientRequest) (*Patient, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+
+	p, err := s.repository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	apply(p, req)
+	p.UpdatedAt = s.now()
+
+	if err := s.repository.Update(ctx, p); err != nil {
+		return nil, err
+	}
+	return s.withGestationalAge(p), nil
+}
+
+// Delete удаляет карточку; сессии пациентки остаются, но теряют ссылку на нее
+func (s *Service) Delete(ctx context.Context, id string) error {
+	if err := s.repository.Delete(ctx, id); err != nil {
+		return err
+	}
+	slog.Info("Deleted patient", "patient_id", id)
+	return nil
+}
+
+// List возвращает карточки; query ищет по ID, номеру карты и ФИО
+func (s *Service) List(ctx context.Context, query string, limit, offset int) ([]*Patient, error) {
+	patients, err := s.repository.List(ctx, query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range patients {
+		s.withGestationalAge(p)
+	}
+	return patients, nil
+}
+
+// History собирает визиты пациентки со сроком беременности и тренды базальной
+// ЧСС, STV и предсказания между визитами
+func (s *Service) History(ctx context.Context, id string) (*History, error) {
+	p, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	visits, err := s.repository.Visits(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get patient sessions: %w", err)
+	}
+	if visits == nil {
+		visits = []Visit{}
+	}
+	for i := range visits {
+		visits[i].GestationalAge = p.GestationalAgeAt(visits[i].StartedAt)
+	}
+
+	return &History{Patient: p, Visits: visits, Trends: buildTrends(visits)}, nil
+}
+
+func (s *Service) withGestationalAge(p *Patient) *Patient {
+	p.GestationalAge = p.GestationalAgeAt(s.now())
+	return p
+}
+
+// validate проверяет поля карточки
+func validate(req *PatientRequest) error {
+	switch {
+	case len(req.ID) > 64:
+		return fmt.Errorf("%w: id is longer than 64 characters", ErrInvalid)
+	case req.Gravidity < 0 || req.Parity < 0:
+		return fmt.Errorf("%w: gravidity and parity must not be negative", ErrInvalid)
+	case req.Gravidity > 0 && req.Parity >= req.Gravidity:
+		return fmt.Errorf("%w: parity must be less than gravidity", ErrInvalid)
+	case req.BirthDate != nil && req.EDD != nil && !req.BirthDate.Before(req.EDD.Time):
+		return fmt.Errorf("%w: birth_date must be before edd", ErrInvalid)
+	}
+	return nil
+}
+
+// apply переносит поля запроса в карточку
+func apply(p *Patient, req *PatientRequest) {
+	p.MRN = strings.TrimSpace(req.MRN)
+	p.FullName = strings.TrimSpace(req.FullName)
+	p.BirthDate = req.BirthDate
+	p.EDD = req.EDD
+	p.Gravidity = req.Gravidity
+	p.Parity = req.Parity
+	p.Notes = req.Notes
+
+	p.RiskFactors = make([]string, 0, len(req.RiskFactors))
+	for _, f := range req.RiskFactors {
+		if f = strings.TrimSpace(f); f != "" {
+			p.RiskFactors = append(p.RiskFactors, f)
+		}
+	}
+}
+
+// buildTrends считает динамику показателей по визитам, упорядоченным по времени
+func buildTrends(visits []Visit) []Trend {
+	trends := make([]Trend, 0, 3)
+	for _, metric := range []struct {
+		name  string
+		value func(Visit) *float64
+	}{
+		{"baseline_heart_rate", func(v Visit) *float64 { return v.BaselineHeartRate }},
+		{"stv", func(v Visit) *float64 { return v.STV }},
+		{"prediction", func(v Visit) *float64 { return v.Prediction }},
+	} {
+		var weeks, values []float64
+		for _, v := range visits {
+			if value := metric.value(v); value != nil {
+				weeks = append(weeks, v.StartedAt.Sub(visits[0].StartedAt).Hours()/(24*7))
+				values = append(values, *value)
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		trend := Trend{
+			Metric: metric.name,
+			Count:  len(values),
+			First:  values[0],
+			Last:   values[len(values)-1],
+			Min:    values[0],
+			Max:    values[0],
+		}
+		for _, value := range values {
+			trend.Min = min(trend.Min, value)
+			trend.Max = max(trend.Max, value)
+			trend.Mean += value / float64(len(values))
+		}
+		trend.SlopePerWeek = slope(weeks, values)
+		trends = append(trends, trend)
+	}
+	return trends
+}
+
+// slope - наклон линейной регрессии y по x; 0, если x не меняется
+func slope(x, y []float64) float64 {
+	n := float64(len(x))
+	var sumX, sumY, sumXY, sumXX float64
+	for i := range x {
+		sumX += x[i]
+		sumY += y[i]
+		sumXY += x[i] * y[i]
+		sumXX += x[i] * x[i]
+	}
+	denom := n*sumXX - sumX*sumX
+	if math.Abs(denom) < 1e-12 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denom
+}
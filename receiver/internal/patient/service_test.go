@@ -0,0 +1,227 @@
+package patient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// memoryRepository - карточки и визиты в памяти
+type memoryRepository struct {
+	patients map[string]*Patient
+	visits   map[string][]Visit
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{patients: make(map[string]*Patient), visits: make(map[string][]Visit)}
+}
+
+func (r *memoryRepository) Create(ctx context.Context, p *Patient) error {
+	if _, ok := r.patients[p.ID]; ok {
+		return ErrExists
+	}
+	copied := *p
+	r.patients[p.ID] = &copied
+	return nil
+}
+
+func (r *memoryRepository) Get(ctx context.Context, id string) (*Patient, error) {
+	p, ok := r.patients[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *p
+	return &copied, nil
+}
+
+func (r *memoryRepository) Update(ctx context.Context, p *Patient) error {
+	if _, ok := r.patients[p.ID]; !ok {
+		return ErrNotFound
+	}
+	copied := *p
+	r.patients[p.ID] = &copied
+	return nil
+}
+
+func (r *memoryRepository) Delete(ctx context.Context, id string) error {
+	if _, ok := r.patients[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.patients, id)
+	return nil
+}
+
+func (r *memoryRepository) List(ctx context.Context, query string, limit, offset int) ([]*Patient, error) {
+	var patients []*Patient
+	for _, p := range r.patients {
+		patients = append(patients, p)
+	}
+	return patients, nil
+}
+
+func (r *memoryRepository) Visits(ctx context.Context, id string) ([]Visit, error) {
+	return r.visits[id], nil
+}
+
+func newTestService(repo Repository, now time.Time) *Service {
+	s := NewService(repo)
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func float(v float64) *float64 { return &v }
+
+func TestGestationalAgeAt(t *testing.T) {
+	edd := NewDate(2025, time.June, 1)
+	p := &Patient{EDD: &edd}
+
+	tests := []struct {
+		at   time.Time
+		want *GestationalAge
+	}{
+		{time.Date(2025, time.June, 1, 15, 30, 0, 0, time.UTC), &GestationalAge{Weeks: 40}},
+		{time.Date(2025, time.May, 27, 8, 0, 0, 0, time.UTC), &GestationalAge{Weeks: 39, Days: 2}},
+		{time.Date(2025, time.March, 30, 0, 0, 0, 0, time.UTC), &GestationalAge{Weeks: 31}},
+		{time.Date(2024, time.August, 1, 0, 0, 0, 0, time.UTC), nil},
+	}
+
+	for _, tt := range tests {
+		got := p.GestationalAgeAt(tt.at)
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("GestationalAgeAt(%s) = %v, want %v", tt.at.Format(time.DateOnly), got, tt.want)
+		}
+	}
+
+	if got := (&Patient{}).GestationalAgeAt(time.Now()); got != nil {
+		t.Errorf("Expected no gestational age without EDD, got %v", got)
+	}
+}
+
+func TestDate_JSON(t *testing.T) {
+	var req PatientRequest
+	if err := json.Unmarshal([]byte(`{"edd": "2025-06-01"}`), &req); err != nil {
+		t.Fatalf("Failed to decode date: %v", err)
+	}
+	if req.EDD == nil || !req.EDD.Equal(NewDate(2025, time.June, 1).Time) {
+		t.Fatalf("Unexpected EDD: %v", req.EDD)
+	}
+
+	data, err := json.Marshal(req.EDD)
+	if err != nil || string(data) != `"2025-06-01"` {
+		t.Errorf("Expected \"2025-06-01\", got %s (%v)", data, err)
+	}
+
+	if err := json.Unmarshal([]byte(`{"edd": "01.06.2025"}`), &req); err == nil {
+		t.Error("Expected error for non-ISO date")
+	}
+}
+
+func TestService_CreateValidation(t *testing.T) {
+	s := newTestService(newMemoryRepository(), time.Now())
+	birth := NewDate(2030, time.January, 1)
+	edd := NewDate(2025, time.June, 1)
+
+	invalid := []*PatientRequest{
+		{Gravidity: -1},
+		{Gravidity: 2, Parity: 2},
+		{BirthDate: &birth, EDD: &edd},
+	}
+	for _, req := range invalid {
+		if _, err := s.Create(context.Background(), req); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Expected ErrInvalid for %+v, got %v", req, err)
+		}
+	}
+}
+
+func TestService_CreateAndUpdate(t *testing.T) {
+	repo := newMemoryRepository()
+	now := time.Date(2025, time.March, 30, 10, 0, 0, 0, time.UTC)
+	s := newTestService(repo, now)
+	edd := NewDate(2025, time.June, 1)
+
+	p, err := s.Create(context.Background(), &PatientRequest{
+		ID:          "patient-001",
+		FullName:    "  Иванова Анна  ",
+		EDD:         &edd,
+		Gravidity:   2,
+		Parity:      1,
+		RiskFactors: []string{"preeclampsia", " ", "gestational_diabetes"},
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if p.FullName != "Иванова Анна" || len(p.RiskFactors) != 2 {
+		t.Errorf("Fields were not normalized: %+v", p)
+	}
+	if p.GestationalAge == nil || *p.GestationalAge != (GestationalAge{Weeks: 31}) {
+		t.Errorf("Expected gestational age 31+0, got %v", p.GestationalAge)
+	}
+
+	if _, err := s.Create(context.Background(), &PatientRequest{ID: "patient-001"}); !errors.Is(err, ErrExists) {
+		t.Errorf("Expected ErrExists for duplicate ID, got %v", err)
+	}
+
+	updated, err := s.Update(context.Background(), "patient-001", &PatientRequest{FullName: "Иванова А.", Gravidity: 2, Parity: 1})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if updated.EDD != nil || updated.GestationalAge != nil || updated.CreatedAt != p.CreatedAt {
+		t.Errorf("Update must replace fields and keep created_at: %+v", updated)
+	}
+
+	if _, err := s.Update(context.Background(), "unknown", &PatientRequest{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestService_History(t *testing.T) {
+	repo := newMemoryRepository()
+	edd := NewDate(2025, time.June, 1)
+	repo.patients["p1"] = &Patient{ID: "p1", EDD: &edd}
+
+	start := time.Date(2025, time.March, 30, 9, 0, 0, 0, time.UTC) // 31+0
+	repo.visits["p1"] = []Visit{
+		{SessionID: "s1", StartedAt: start, BaselineHeartRate: float(140), STV: float(8), Prediction: float(0.1)},
+		{SessionID: "s2", StartedAt: start.AddDate(0, 0, 7)}, // Без метрик
+		{SessionID: "s3", StartedAt: start.AddDate(0, 0, 14), BaselineHeartRate: float(136), STV: float(6), Prediction: float(0.3)},
+		{SessionID: "s4", StartedAt: start.AddDate(0, 0, 21), BaselineHeartRate: float(134), STV: float(5), Prediction: float(0.4)},
+	}
+
+	history, err := newTestService(repo, start).History(context.Background(), "p1")
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+
+	if len(history.Visits) != 4 {
+		t.Fatalf("Expected 4 visits, got %d", len(history.Visits))
+	}
+	if ga := history.Visits[3].GestationalAge; ga == nil || *ga != (GestationalAge{Weeks: 34}) {
+		t.Errorf("Expected 34+0 at the last visit, got %v", ga)
+	}
+
+	if len(history.Trends) != 3 {
+		t.Fatalf("Expected trends for baseline, STV and prediction, got %+v", history.Trends)
+	}
+	stv := history.Trends[1]
+	if stv.Metric != "stv" || stv.Count != 3 || stv.First != 8 || stv.Last != 5 || stv.Min != 5 || stv.Max != 8 {
+		t.Errorf("Unexpected STV trend: %+v", stv)
+	}
+	// Точки (0, 8), (2, 6), (3, 5) лежат на прямой с наклоном -1 в неделю
+	if math.Abs(stv.SlopePerWeek+1) > 1e-9 {
+		t.Errorf("Expected STV slope -1, got %.4f", stv.SlopePerWeek)
+	}
+
+	if _, err := newTestService(repo, start).History(context.Background(), "unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestBuildTrends_SingleVisit(t *testing.T) {
+	trends := buildTrends([]Visit{{StartedAt: time.Now(), STV: float(7)}})
+	if len(trends) != 1 || trends[0].SlopePerWeek != 0 || trends[0].Mean != 7 {
+		t.Errorf("Unexpected trends for a single visit: %+v", trends)
+	}
+}
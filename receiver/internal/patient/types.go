@@ -0,0 +1,158 @@
+// Package patient ведет карточки пациенток и собирает историю КТГ по сессиям
+// беременности для сравнения антенатальных записей между визитами.
+package patient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("patient not found")
+	ErrExists   = errors.New("patient already exists")
+	ErrInvalid  = errors.New("invalid patient")
+)
+
+// termDays - срок беременности на предполагаемую дату родов (40 недель)
+const termDays = 280
+
+// Date - календарная дата без времени, в JSON - "YYYY-MM-DD"
+type Date struct {
+	time.Time
+}
+
+// NewDate возвращает дату по году, месяцу и дню
+func NewDate(year int, month time.Month, day int) Date {
+	return Date{time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Format(time.DateOnly))
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", s)
+	}
+	d.Time = t
+	return nil
+}
+
+// GestationalAge - срок беременности
+type GestationalAge struct {
+	Weeks int `json:"weeks"`
+	Days  int `json:"days"`
+}
+
+// String форматирует срок как "32+4"
+func (ga GestationalAge) String() string {
+	return fmt.Sprintf("%d+%d", ga.Weeks, ga.Days)
+}
+
+// TotalWeeks - срок в неделях с дробной частью
+func (ga GestationalAge) TotalWeeks() float64 {
+	return float64(ga.Weeks) + float64(ga.Days)/7
+}
+
+// Patient - карточка пациентки
+type Patient struct {
+	ID          string   `json:"id"`
+	MRN         string   `json:"mrn,omitempty"` // Номер медицинской карты
+	FullName    string   `json:"full_name,omitempty"`
+	BirthDate   *Date    `json:"birth_date,omitempty"`
+	EDD         *Date    `json:"edd,omitempty"` // Предполагаемая дата родов
+	Gravidity   int      `json:"gravidity"`     // Беременность по счету
+	Parity      int      `json:"parity"`        // Число родов в анамнезе
+	RiskFactors []string `json:"risk_factors"`
+	Notes       string   `json:"notes,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// GestationalAge - срок на текущую дату, вычисляется по EDD
+	GestationalAge *GestationalAge `json:"gestational_age,omitempty"`
+}
+
+// GestationalAgeAt возвращает срок беременности на момент t; nil без EDD
+// или если t раньше зачатия
+func (p *Patient) GestationalAgeAt(t time.Time) *GestationalAge {
+	if p.EDD == nil {
+		return nil
+	}
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	days := termDays - int(p.EDD.Sub(day).Hours()/24)
+	if days < 0 {
+		return nil
+	}
+	return &GestationalAge{Weeks: days / 7, Days: days % 7}
+}
+
+// PatientRequest - поля карточки при создании и изменении. ID задается
+// только при создании; пустой ID генерируется
+type PatientRequest struct {
+	ID          string   `json:"id,omitempty"`
+	MRN         string   `json:"mrn,omitempty"`
+	FullName    string   `json:"full_name,omitempty"`
+	BirthDate   *Date    `json:"birth_date,omitempty"`
+	EDD         *Date    `json:"edd,omitempty"`
+	Gravidity   int      `json:"gravidity"`
+	Parity      int      `json:"parity"`
+	RiskFactors []string `json:"risk_factors,omitempty"`
+	Notes       string   `json:"notes,omitempty"`
+}
+
+// Visit - сессия КТГ пациентки с итоговыми показателями. Показатели пусты,
+// если сессия сохранена без метрик
+type Visit struct {
+	SessionID         string          `json:"session_id"`
+	Status            string          `json:"status"`
+	StartedAt         time.Time       `json:"started_at"`
+	DurationMs        int64           `json:"duration_ms"`
+	GestationalAge    *GestationalAge `json:"gestational_age,omitempty"`
+	BaselineHeartRate *float64        `json:"baseline_heart_rate,omitempty"`
+	STV               *float64        `json:"stv,omitempty"`
+	LTV               *float64        `json:"ltv,omitempty"`
+	Prediction        *float64        `json:"prediction,omitempty"`
+	Accelerations     int             `json:"accelerations"`
+	Decelerations     int             `json:"decelerations"`
+	LateDecelerations int             `json:"late_decelerations"`
+}
+
+// Trend - динамика показателя между визитами
+type Trend struct {
+	Metric       string  `json:"metric"`
+	Count        int     `json:"count"` // Визитов с этим показателем
+	First        float64 `json:"first"`
+	Last         float64 `json:"last"`
+	Min          float64 `json:"min"`
+	Max          float64 `json:"max"`
+	Mean         float64 `json:"mean"`
+	SlopePerWeek float64 `json:"slope_per_week"` // Наклон линейной регрессии, единиц в неделю
+}
+
+// History - визиты пациентки по возрастанию времени и тренды показателей
+type History struct {
+	Patient *Patient `json:"patient"`
+	Visits  []Visit  `json:"visits"`
+	Trends  []Trend  `json:"trends"`
+}
+
+// Repository - хранилище карточек пациенток (Infrastructure Layer)
+type Repository interface {
+	Create(ctx context.Context, p *Patient) error
+	Get(ctx context.Context, id string) (*Patient, error)
+	Update(ctx context.Context, p *Patient) error
+	Delete(ctx context.Context, id string) error
+	List(ctx context.Context, query string, limit, offset int) ([]*Patient, error)
+
+	// Visits возвращает сессии пациентки с метриками по возрастанию started_at
+	Visits(ctx context.Context, id string) ([]Visit, error)
+}
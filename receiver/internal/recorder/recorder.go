@@ -0,0 +1,275 @@
+// Package recorder сохраняет сырой поток телеметрии по сессиям, чтобы записи
+// можно было воспроизвести через пайплайн (см. пакет replay).
+//
+// Сэмплы копятся в памяти по сессиям и уходят в хранилище append-only чанками:
+// когда набирается RawChunkSamples сэмплов или когда самый старый сэмпл чанка
+// ждет дольше RawFlushInterval. Запись идет в отдельной горутине, поэтому
+// медленная база не тормозит прием телеметрии.
+//
+// У таблицы сэмплов нет внешнего ключа на sessions (строка сессии появляется
+// только при сохранении), поэтому сэмплы сессий, которые так и не сохранили,
+// удаляет фоновая очистка (EnableRetention) после истечения TTL данных сессии.
+package recorder
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/Krimson/fetal-monitory/logging"
+	telemetryv1 "github.com/Krimson/fetal-monitory/proto/telemetry"
+	"github.com/Krimson/fetal-monitory/receiver/internal/session"
+)
+
+// Store - хранилище чанков (реализуется session.PostgresRepository)
+type Store interface {
+	AppendRawSamples(ctx context.Context, sessionID string, samples []session.RawSample) error
+}
+
+// RetentionStore удаляет сэмплы несохраненных сессий (реализуется session.PostgresRepository)
+type RetentionStore interface {
+	DeleteUnsavedRawSamples(ctx context.Context, maxAge time.Duration) (int64, error)
+}
+
+// RetentionInterval - период фоновой очистки сэмплов
+const RetentionInterval = time.Hour
+
+const (
+	// queueSize - сколько чанков может ждать записи; при переполнении чанк теряется
+	queueSize = 1024
+
+	writeTimeout  = 5 * time.Second
+	writeAttempts = 3
+	retryDelay    = 500 * time.Millisecond
+)
+
+type chunk struct {
+	sessionID string
+	samples   []session.RawSample
+}
+
+type buffer struct {
+	samples []session.RawSample
+	firstAt time.Time // Когда в буфер попал первый сэмпл
+}
+
+// Stats - статистика записи
+type Stats struct {
+	Recorded int64 `json:"recorded"` // Сэмплов записано в хранилище
+	Chunks   int64 `json:"chunks"`   // Чанков записано
+	Dropped  int64 `json:"dropped"`  // Сэмплов потеряно (очередь переполнена или ошибка записи)
+}
+
+// Recorder буферизует сырые сэмплы и пишет их чанками
+type Recorder struct {
+	store         Store
+	chunkSize     int
+	flushInterval time.Duration
+
+	mu      sync.Mutex
+	buffers map[string]*buffer
+	stats   Stats
+	stopped bool
+
+	chunks   chan chunk
+	stopChan chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// New создает Recorder и запускает фоновую запись
+func New(store Store, chunkSize int, flushInterval time.Duration) *Recorder {
+	if chunkSize <= 0 {
+		chunkSize = 1
+	}
+	if flushInterval <= 0 {
+		flushInterval = time.Second
+	}
+
+	r := &Recorder{
+		store:         store,
+		chunkSize:     chunkSize,
+		flushInterval: flushInterval,
+		buffers:       make(map[string]*buffer),
+		chunks:        make(chan chunk, queueSize),
+		stopChan:      make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	go r.writer()
+	go r.flusher()
+
+	return r
+}
+
+// Record добавляет сэмпл в буфер его сессии. Невалидные сэмплы пропускаются
+// (их так же отбрасывает Batcher)
+func (r *Recorder) Record(sample *telemetryv1.Sample) {
+	if sample.SessionId == "" || sample.TsMs == 0 ||
+		(sample.Metric != telemetryv1.Metric_METRIC_FHR && sample.Metric != telemetryv1.Metric_METRIC_UC) ||
+		math.IsNaN(float64(sample.Value)) || math.IsInf(float64(sample.Value), 0) {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stopped {
+		return
+	}
+
+	buf, ok := r.buffers[sample.SessionId]
+	if !ok {
+		buf = &buffer{
+			samples: make([]session.RawSample, 0, r.chunkSize),
+			firstAt: time.Now(),
+		}
+		r.buffers[sample.SessionId] = buf
+	}
+
+	buf.samples = append(buf.samples, session.RawSample{
+		TsMS:   int64(sample.TsMs),
+		Metric: sample.Metric,
+		Value:  sample.Value,
+	})
+
+	if len(buf.samples) >= r.chunkSize {
+		r.enqueueLocked(sample.SessionId, buf)
+	}
+}
+
+// Stop сбрасывает все буферы и дожидается их записи
+func (r *Recorder) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stopChan)
+		<-r.done
+		stats := r.GetStats()
+		slog.Info("Raw recorder stopped", "recorded", stats.Recorded, "chunks", stats.Chunks, "dropped", stats.Dropped)
+	})
+}
+
+// EnableRetention запускает фоновую очистку: раз в interval удаляются сэмплы
+// сессий, которых нет в PostgreSQL и по которым не приходило данных дольше maxAge.
+// maxAge совпадает с TTL данных сессии в Redis: после него несохраненную сессию
+// уже нельзя сохранить, и ее сырой поток больше не нужен
+func (r *Recorder) EnableRetention(store RetentionStore, maxAge, interval time.Duration) {
+	if maxAge <= 0 || interval <= 0 {
+		return
+	}
+	go r.retention(store, maxAge, interval)
+}
+
+func (r *Recorder) retention(store RetentionStore, maxAge, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+			deleted, err := store.DeleteUnsavedRawSamples(ctx, maxAge)
+			cancel()
+			if err != nil {
+				slog.Error("Failed to delete stale raw samples", logging.Err(err))
+			} else if deleted > 0 {
+				slog.Info("Deleted raw samples of unsaved sessions", "chunks", deleted, "max_age", maxAge)
+			}
+
+		case <-r.stopChan:
+			return
+		}
+	}
+}
+
+// GetStats возвращает статистику записи
+func (r *Recorder) GetStats() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
+
+// enqueueLocked отправляет буфер сессии на запись; вызывается под r.mu
+func (r *Recorder) enqueueLocked(sessionID string, buf *buffer) {
+	delete(r.buffers, sessionID)
+
+	select {
+	case r.chunks <- chunk{sessionID: sessionID, samples: buf.samples}:
+	default:
+		r.stats.Dropped += int64(len(buf.samples))
+		slog.Warn("Raw recorder queue full, samples dropped", logging.SessionID(sessionID), "samples", len(buf.samples))
+	}
+}
+
+// flushOld отправляет на запись буферы, которые ждут дольше flushInterval
+func (r *Recorder) flushOld(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for sessionID, buf := range r.buffers {
+		if now.Sub(buf.firstAt) >= r.flushInterval {
+			r.enqueueLocked(sessionID, buf)
+		}
+	}
+}
+
+func (r *Recorder) flusher() {
+	ticker := time.NewTicker(r.flushInterval / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			r.flushOld(now)
+
+		case <-r.stopChan:
+			r.mu.Lock()
+			r.stopped = true
+			for sessionID, buf := range r.buffers {
+				r.enqueueLocked(sessionID, buf)
+			}
+			close(r.chunks)
+			r.mu.Unlock()
+			return
+		}
+	}
+}
+
+// writer пишет чанки в хранилище в порядке поступления
+func (r *Recorder) writer() {
+	defer close(r.done)
+
+	for c := range r.chunks {
+		err := r.write(c)
+
+		r.mu.Lock()
+		if err != nil {
+			r.stats.Dropped += int64(len(c.samples))
+		} else {
+			r.stats.Recorded += int64(len(c.samples))
+			r.stats.Chunks++
+		}
+		r.mu.Unlock()
+
+		if err != nil {
+			slog.Error("Failed to store raw samples", logging.SessionID(c.sessionID), "samples", len(c.samples), logging.Err(err))
+		}
+	}
+}
+
+func (r *Recorder) write(c chunk) error {
+	var err error
+	for attempt := 1; attempt <= writeAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+		err = r.store.AppendRawSamples(ctx, c.sessionID, c.samples)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if attempt < writeAttempts {
+			time.Sleep(retryDelay)
+		}
+	}
+	return err
+}
@@ -0,0 +1,148 @@
+package recorder
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	telemetryv1 "github.com/Krimson/fetal-monitory/proto/telemetry"
+	"github.com/Krimson/fetal-monitory/receiver/internal/session"
+)
+
+// memoryStore запоминает записанные чанки
+type memoryStore struct {
+	mu     sync.Mutex
+	chunks map[string][][]session.RawSample
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{chunks: make(map[string][][]session.RawSample)}
+}
+
+func (s *memoryStore) AppendRawSamples(ctx context.Context, sessionID string, samples []session.RawSample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chunks[sessionID] = append(s.chunks[sessionID], samples)
+	return nil
+}
+
+func (s *memoryStore) sessionChunks(sessionID string) [][]session.RawSample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.chunks[sessionID]
+}
+
+func sample(sessionID string, ts uint64, value float32) *telemetryv1.Sample {
+	return &telemetryv1.Sample{
+		SessionId: sessionID,
+		TsMs:      ts,
+		Metric:    telemetryv1.Metric_METRIC_FHR,
+		Value:     value,
+	}
+}
+
+func TestRecorderChunksBySize(t *testing.T) {
+	store := newMemoryStore()
+	r := New(store, 3, time.Hour)
+
+	for i := 0; i < 7; i++ {
+		r.Record(sample("s1", uint64(1000+i*250), float32(140+i)))
+	}
+	r.Record(sample("s2", 1000, 120))
+	r.Stop()
+
+	chunks := store.sessionChunks("s1")
+	if len(chunks) != 3 || len(chunks[0]) != 3 || len(chunks[1]) != 3 || len(chunks[2]) != 1 {
+		t.Fatalf("unexpected s1 chunks: %v", chunks)
+	}
+	if got := chunks[1][0]; got.TsMS != 1750 || got.Value != 143 || got.Metric != telemetryv1.Metric_METRIC_FHR {
+		t.Errorf("unexpected sample: %+v", got)
+	}
+	if len(store.sessionChunks("s2")) != 1 {
+		t.Errorf("s2 buffer not flushed on stop")
+	}
+
+	stats := r.GetStats()
+	if stats.Recorded != 8 || stats.Chunks != 4 || stats.Dropped != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	// После остановки сэмплы игнорируются
+	r.Record(sample("s1", 5000, 150))
+	r.Stop()
+	if r.GetStats().Recorded != 8 {
+		t.Errorf("sample recorded after stop")
+	}
+}
+
+func TestRecorderFlushByInterval(t *testing.T) {
+	store := newMemoryStore()
+	r := New(store, 1000, 50*time.Millisecond)
+	defer r.Stop()
+
+	r.Record(sample("s1", 1000, 140))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(store.sessionChunks("s1")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("buffer was not flushed by interval")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRecorderSkipsInvalidSamples(t *testing.T) {
+	store := newMemoryStore()
+	r := New(store, 10, time.Hour)
+
+	r.Record(sample("", 1000, 140))
+	r.Record(sample("s1", 0, 140))
+	r.Record(sample("s1", 1000, float32(math.NaN())))
+	r.Record(&telemetryv1.Sample{SessionId: "s1", TsMs: 1000, Metric: telemetryv1.Metric_METRIC_UNSPECIFIED, Value: 1})
+	r.Stop()
+
+	if stats := r.GetStats(); stats.Recorded != 0 || stats.Chunks != 0 {
+		t.Errorf("invalid samples recorded: %+v", stats)
+	}
+}
+
+// retentionStore считает вызовы очистки
+type retentionStore struct {
+	calls chan time.Duration
+}
+
+func (s *retentionStore) DeleteUnsavedRawSamples(ctx context.Context, maxAge time.Duration) (int64, error) {
+	s.calls <- maxAge
+	return 1, nil
+}
+
+func TestRecorderRetention(t *testing.T) {
+	r := New(newMemoryStore(), 10, time.Hour)
+	store := &retentionStore{calls: make(chan time.Duration, 16)}
+	r.EnableRetention(store, 24*time.Hour, 10*time.Millisecond)
+
+	select {
+	case maxAge := <-store.calls:
+		if maxAge != 24*time.Hour {
+			t.Errorf("maxAge = %v, want 24h", maxAge)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("retention did not run")
+	}
+
+	r.Stop()
+}
+
+func TestRecorderRetentionDisabledWithoutTTL(t *testing.T) {
+	r := New(newMemoryStore(), 10, time.Hour)
+	defer r.Stop()
+	store := &retentionStore{calls: make(chan time.Duration, 1)}
+	r.EnableRetention(store, 0, 10*time.Millisecond)
+
+	time.Sleep(50 * time.Millisecond)
+	if len(store.calls) != 0 {
+		t.Error("retention must be disabled when session data never expires")
+	}
+}
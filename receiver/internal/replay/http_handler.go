@@ -0,0 +1,164 @@
+package replay
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Krimson/fetal-monitory/logging"
+)
+
+// StartReplayRequest - запрос на запуск воспроизведения
+type StartReplayRequest struct {
+	SourceSessionID string  `json:"source_session_id"`
+	Speed           float64 `json:"speed,omitempty"` // По умолчанию 1 (реальное время)
+}
+
+// HTTPHandler обрабатывает HTTP запросы воспроизведения (Presentation Layer)
+type HTTPHandler struct {
+	replayer *Replayer
+}
+
+// NewHTTPHandler создает новый HTTP обработчик
+func NewHTTPHandler(replayer *Replayer) *HTTPHandler {
+	return &HTTPHandler{
+		replayer: replayer,
+	}
+}
+
+// RegisterRoutes регистрирует маршруты в роутере
+func (h *HTTPHandler) RegisterRoutes(router *mux.Router) {
+	api := router.PathPrefix("/api/replays").Subrouter()
+
+	api.HandleFunc("", h.StartReplay).Methods("POST", "OPTIONS")
+	api.HandleFunc("", h.ListReplays).Methods("GET", "OPTIONS")
+	api.HandleFunc("/{id}", h.GetReplay).Methods("GET", "OPTIONS")
+	api.HandleFunc("/{id}", h.CancelReplay).Methods("DELETE", "OPTIONS")
+}
+
+// StartReplay запускает воспроизведение записанной сессии
+// @Summary Воспроизвести сессию
+// @Description Прогоняет сохраненный сырой поток сессии через пайплайн (батчинг, feature extractor, ML, тревоги) под новым ID сессии в реальном или ускоренном времени
+// @Tags Replays
+// @Accept json
+// @Produce json
+// @Param request body StartReplayRequest true "Исходная сессия и скорость"
+// @Success 202 {object} Replay "Воспроизведение запущено"
+// @Failure 400 {object} map[string]interface{} "Неверный запрос или скорость"
+// @Failure 404 {object} map[string]interface{} "Для сессии нет записанных сэмплов"
+// @Failure 500 {object} map[string]interface{} "Ошибка сервера"
+// @Security BearerAuth
+// @Router /api/replays [post]
+func (h *HTTPHandler) StartReplay(w http.ResponseWriter, r *http.Request) {
+	var req StartReplayRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.SourceSessionID == "" {
+		respondError(w, http.StatusBadRequest, "source_session_id is required")
+		return
+	}
+	if req.Speed == 0 {
+		req.Speed = 1
+	}
+
+	replay, err := h.replayer.Start(r.Context(), req.SourceSessionID, req.Speed)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidSpeed):
+			respondError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, ErrNoSamples):
+			respondError(w, http.StatusNotFound, "No recorded samples")
+		default:
+			slog.Error("Failed to start replay", logging.SessionID(req.SourceSessionID), logging.Err(err))
+			respondError(w, http.StatusInternalServerError, "Failed to start replay")
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusAccepted, replay)
+}
+
+// ListReplays возвращает список воспроизведений
+// @Summary Список воспроизведений
+// @Description Возвращает активные и недавно завершенные воспроизведения
+// @Tags Replays
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Список воспроизведений"
+// @Security BearerAuth
+// @Router /api/replays [get]
+func (h *HTTPHandler) ListReplays(w http.ResponseWriter, r *http.Request) {
+	replays := h.replayer.List()
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"replays": replays,
+		"count":   len(replays),
+	})
+}
+
+// GetReplay возвращает состояние воспроизведения
+// @Summary Получить воспроизведение
+// @Description Возвращает состояние и прогресс воспроизведения
+// @Tags Replays
+// @Produce json
+// @Param id path string true "ID воспроизведения"
+// @Success 200 {object} Replay "Воспроизведение"
+// @Failure 404 {object} map[string]interface{} "Воспроизведение не найдено"
+// @Security BearerAuth
+// @Router /api/replays/{id} [get]
+func (h *HTTPHandler) GetReplay(w http.ResponseWriter, r *http.Request) {
+	replay, err := h.replayer.Get(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Replay not found")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, replay)
+}
+
+// CancelReplay останавливает воспроизведение
+// @Summary Остановить воспроизведение
+// @Description Прерывает воспроизведение; новая сессия останавливается с уже отправленными данными
+// @Tags Replays
+// @Produce json
+// @Param id path string true "ID воспроизведения"
+// @Success 200 {object} Replay "Воспроизведение остановлено"
+// @Failure 404 {object} map[string]interface{} "Воспроизведение не найдено"
+// @Failure 409 {object} map[string]interface{} "Воспроизведение уже завершено"
+// @Security BearerAuth
+// @Router /api/replays/{id} [delete]
+func (h *HTTPHandler) CancelReplay(w http.ResponseWriter, r *http.Request) {
+	replay, err := h.replayer.Cancel(mux.Vars(r)["id"])
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNotFound):
+			respondError(w, http.StatusNotFound, "Replay not found")
+		case errors.Is(err, ErrNotRunning):
+			respondError(w, http.StatusConflict, err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "Failed to cancel replay")
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, replay)
+}
+
+func respondJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		slog.Error("Failed to encode JSON response", logging.Err(err))
+	}
+}
+
+func respondError(w http.ResponseWriter, status int, message string) {
+	respondJSON(w, status, map[string]interface{}{
+		"error":  message,
+		"status": status,
+	})
+}
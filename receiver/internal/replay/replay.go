@@ -0,0 +1,322 @@
+// Package replay воспроизводит сохраненный сырой поток сессии (см. пакет recorder)
+// через Batcher, как если бы данные снова пришли от устройства.
+//
+// Воспроизведение идет под новым ID сессии в реальном или ускоренном времени:
+// интервалы между сэмплами делятся на скорость, а метки времени сдвигаются к
+// моменту запуска с сохранением исходных интервалов, чтобы батчинг и feature
+// extractor видели ту же временную шкалу, что и при записи.
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Krimson/fetal-monitory/logging"
+	telemetryv1 "github.com/Krimson/fetal-monitory/proto/telemetry"
+	"github.com/Krimson/fetal-monitory/receiver/internal/session"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNoSamples    = errors.New("session has no recorded samples")
+	ErrInvalidSpeed = errors.New("invalid replay speed")
+	ErrNotFound     = errors.New("replay not found")
+	ErrNotRunning   = errors.New("replay is not running")
+)
+
+// Source - хранилище сырых сэмплов (реализуется session.PostgresRepository)
+type Source interface {
+	GetRawSamples(ctx context.Context, sessionID string) ([]session.RawSample, error)
+}
+
+// Sessions - управление сессиями (реализуется session.Manager)
+type Sessions interface {
+	GetSession(ctx context.Context, sessionID string) (*session.Session, error)
+	CreateSession(ctx context.Context, req *session.CreateSessionRequest) (*session.Session, error)
+	StopSession(ctx context.Context, sessionID string) error
+}
+
+// Sink принимает воспроизводимые сэмплы (реализуется batch.Batcher)
+type Sink interface {
+	Add(sample *telemetryv1.Sample) error
+}
+
+// State - состояние воспроизведения
+type State string
+
+const (
+	StateRunning   State = "running"
+	StateCompleted State = "completed"
+	StateCancelled State = "cancelled"
+	StateFailed    State = "failed"
+)
+
+// CreatedFrom - источник, который записывается в метаданные новой сессии
+const CreatedFrom = "replay"
+
+// maxFinished - сколько завершенных воспроизведений хранится для GET /api/replays
+const maxFinished = 100
+
+// Replay описывает одно воспроизведение
+type Replay struct {
+	ID              string     `json:"id"`
+	SourceSessionID string     `json:"source_session_id"`
+	SessionID       string     `json:"session_id"` // Новая сессия, в которую идут данные
+	Speed           float64    `json:"speed"`
+	State           State      `json:"state"`
+	TotalSamples    int        `json:"total_samples"`
+	SentSamples     int        `json:"sent_samples"`
+	StartedAt       time.Time  `json:"started_at"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+	Error           string     `json:"error,omitempty"`
+}
+
+type run struct {
+	replay Replay
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Replayer запускает воспроизведения и хранит их состояние в памяти
+type Replayer struct {
+	source   Source
+	sessions Sessions
+	sink     Sink
+	maxSpeed float64
+
+	mu   sync.Mutex
+	runs map[string]*run
+	wg   sync.WaitGroup
+}
+
+// NewReplayer создает Replayer. maxSpeed ограничивает ускорение
+func NewReplayer(source Source, sessions Sessions, sink Sink, maxSpeed float64) *Replayer {
+	if maxSpeed < 1 {
+		maxSpeed = 1
+	}
+	return &Replayer{
+		source:   source,
+		sessions: sessions,
+		sink:     sink,
+		maxSpeed: maxSpeed,
+		runs:     make(map[string]*run),
+	}
+}
+
+// Start загружает сырые сэмплы сессии, создает новую сессию и запускает
+// воспроизведение в фоне. speed = 1 - реальное время
+func (r *Replayer) Start(ctx context.Context, sourceSessionID string, speed float64) (*Replay, error) {
+	if speed <= 0 || speed > r.maxSpeed {
+		return nil, fmt.Errorf("%w: %g (allowed 0 < speed <= %g)", ErrInvalidSpeed, speed, r.maxSpeed)
+	}
+
+	samples, err := r.source.GetRawSamples(ctx, sourceSessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load raw samples: %w", err)
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoSamples, sourceSessionID)
+	}
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].TsMS < samples[j].TsMS })
+
+	req := &session.CreateSessionRequest{
+		CustomData: map[string]interface{}{
+			"replay_of":    sourceSessionID,
+			"replay_speed": speed,
+		},
+		CreatedFrom: CreatedFrom,
+	}
+	// Метаданных может не быть, если сессию не сохраняли и она истекла в Redis
+	if src, err := r.sessions.GetSession(ctx, sourceSessionID); err == nil {
+		req.PatientID = src.Metadata.PatientID
+		req.DoctorID = src.Metadata.DoctorID
+		req.FacilityID = src.Metadata.FacilityID
+		req.Notes = src.Metadata.Notes
+	}
+
+	target, err := r.sessions.CreateSession(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create replay session: %w", err)
+	}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	rn := &run{
+		replay: Replay{
+			ID:              uuid.New().String(),
+			SourceSessionID: sourceSessionID,
+			SessionID:       target.ID,
+			Speed:           speed,
+			State:           StateRunning,
+			TotalSamples:    len(samples),
+			StartedAt:       time.Now(),
+		},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	r.mu.Lock()
+	r.runs[rn.replay.ID] = rn
+	r.pruneLocked()
+	snapshot := rn.replay
+	r.mu.Unlock()
+
+	slog.Info("Replay started", "replay_id", snapshot.ID, "source_session_id", sourceSessionID, logging.SessionID(target.ID),
+		"samples", len(samples), "speed", speed)
+
+	r.wg.Add(1)
+	go r.play(runCtx, rn, samples)
+
+	return &snapshot, nil
+}
+
+// Get возвращает состояние воспроизведения
+func (r *Replayer) Get(id string) (*Replay, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rn, ok := r.runs[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	snapshot := rn.replay
+	return &snapshot, nil
+}
+
+// List возвращает все известные воспроизведения, новые первыми
+func (r *Replayer) List() []*Replay {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	replays := make([]*Replay, 0, len(r.runs))
+	for _, rn := range r.runs {
+		snapshot := rn.replay
+		replays = append(replays, &snapshot)
+	}
+	sort.Slice(replays, func(i, j int) bool { return replays[i].StartedAt.After(replays[j].StartedAt) })
+	return replays
+}
+
+// Cancel останавливает воспроизведение и дожидается его завершения
+func (r *Replayer) Cancel(id string) (*Replay, error) {
+	r.mu.Lock()
+	rn, ok := r.runs[id]
+	if !ok {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if rn.replay.State != StateRunning {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrNotRunning, rn.replay.State)
+	}
+	r.mu.Unlock()
+
+	rn.cancel()
+	<-rn.done
+
+	return r.Get(id)
+}
+
+// Stop отменяет все активные воспроизведения (при остановке сервера)
+func (r *Replayer) Stop() {
+	r.mu.Lock()
+	for _, rn := range r.runs {
+		rn.cancel()
+	}
+	r.mu.Unlock()
+
+	r.wg.Wait()
+}
+
+// play отправляет сэмплы в Sink, выдерживая исходные интервалы с учетом скорости
+func (r *Replayer) play(ctx context.Context, rn *run, samples []session.RawSample) {
+	defer r.wg.Done()
+	defer close(rn.done)
+	defer rn.cancel()
+
+	sessionID := rn.replay.SessionID
+	speed := rn.replay.Speed
+	t0 := samples[0].TsMS
+	startedAt := time.Now()
+	baseMS := startedAt.UnixMilli()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+
+	state, errMsg := StateCompleted, ""
+
+	for i, s := range samples {
+		offset := time.Duration(float64(s.TsMS-t0)/speed) * time.Millisecond
+		if wait := time.Until(startedAt.Add(offset)); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-ctx.Done():
+				state = StateCancelled
+			case <-timer.C:
+			}
+		} else if ctx.Err() != nil {
+			state = StateCancelled
+		}
+		if state == StateCancelled {
+			break
+		}
+
+		err := r.sink.Add(&telemetryv1.Sample{
+			SessionId: sessionID,
+			TsMs:      uint64(baseMS + (s.TsMS - t0)),
+			Metric:    s.Metric,
+			Value:     s.Value,
+		})
+		if err != nil {
+			state, errMsg = StateFailed, err.Error()
+			break
+		}
+
+		r.mu.Lock()
+		rn.replay.SentSamples = i + 1
+		r.mu.Unlock()
+	}
+
+	// Сессию останавливаем без контекста воспроизведения - он уже может быть отменен
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := r.sessions.StopSession(stopCtx, sessionID); err != nil {
+		slog.Warn("Failed to stop replay session", logging.SessionID(sessionID), logging.Err(err))
+	}
+	stopCancel()
+
+	now := time.Now()
+	r.mu.Lock()
+	rn.replay.State = state
+	rn.replay.Error = errMsg
+	rn.replay.FinishedAt = &now
+	sent := rn.replay.SentSamples
+	r.mu.Unlock()
+
+	slog.Info("Replay finished", "replay_id", rn.replay.ID, "state", state, logging.SessionID(sessionID),
+		"sent", sent, "samples", len(samples))
+}
+
+// pruneLocked удаляет самые старые завершенные воспроизведения сверх maxFinished
+func (r *Replayer) pruneLocked() {
+	finished := make([]*run, 0, len(r.runs))
+	for _, rn := range r.runs {
+		if rn.replay.State != StateRunning {
+			finished = append(finished, rn)
+		}
+	}
+	if len(finished) <= maxFinished {
+		return
+	}
+
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].replay.StartedAt.Before(finished[j].replay.StartedAt)
+	})
+	for _, rn := range finished[:len(finished)-maxFinished] {
+		delete(r.runs, rn.replay.ID)
+	}
+}
@@ -0,0 +1,206 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	telemetryv1 "github.com/Krimson/fetal-monitory/proto/telemetry"
+	"github.com/Krimson/fetal-monitory/receiver/internal/session"
+)
+
+// memorySource - сырые сэмплы в памяти
+type memorySource map[string][]session.RawSample
+
+func (s memorySource) GetRawSamples(ctx context.Context, sessionID string) ([]session.RawSample, error) {
+	return append([]session.RawSample(nil), s[sessionID]...), nil
+}
+
+// memorySessions запоминает созданные и остановленные сессии
+type memorySessions struct {
+	mu       sync.Mutex
+	sessions map[string]*session.Session
+	created  []*session.CreateSessionRequest
+	stopped  []string
+}
+
+func newMemorySessions() *memorySessions {
+	return &memorySessions{sessions: make(map[string]*session.Session)}
+}
+
+func (m *memorySessions) GetSession(ctx context.Context, sessionID string) (*session.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[sessionID]
+	if !ok {
+		return nil, session.ErrSessionNotFound
+	}
+	return s, nil
+}
+
+func (m *memorySessions) CreateSession(ctx context.Context, req *session.CreateSessionRequest) (*session.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.created = append(m.created, req)
+	s := &session.Session{ID: "replay-session", Status: session.SessionStatusActive}
+	m.sessions[s.ID] = s
+	return s, nil
+}
+
+func (m *memorySessions) StopSession(ctx context.Context, sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stopped = append(m.stopped, sessionID)
+	return nil
+}
+
+// memorySink запоминает отправленные сэмплы
+type memorySink struct {
+	mu      sync.Mutex
+	samples []*telemetryv1.Sample
+}
+
+func (s *memorySink) Add(sample *telemetryv1.Sample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples = append(s.samples, sample)
+	return nil
+}
+
+func (s *memorySink) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.samples)
+}
+
+// recordedSamples - поток 4 Гц по обоим каналам длительностью seconds
+func recordedSamples(seconds int) []session.RawSample {
+	const t0 = 1_700_000_000_000
+	var samples []session.RawSample
+	for i := 0; i < seconds*4; i++ {
+		ts := int64(t0 + i*250)
+		samples = append(samples,
+			session.RawSample{TsMS: ts, Metric: telemetryv1.Metric_METRIC_FHR, Value: float32(140 + i%5)},
+			session.RawSample{TsMS: ts, Metric: telemetryv1.Metric_METRIC_UC, Value: float32(10 + i%3)},
+		)
+	}
+	return samples
+}
+
+func waitFinished(t *testing.T, r *Replayer, id string) *Replay {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		replay, err := r.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if replay.State != StateRunning {
+			return replay
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("replay %s did not finish", id)
+	return nil
+}
+
+func TestReplayAccelerated(t *testing.T) {
+	sessions := newMemorySessions()
+	sessions.sessions["source"] = &session.Session{
+		ID:       "source",
+		Metadata: session.Metadata{PatientID: "P001", DoctorID: "D001"},
+	}
+	sink := &memorySink{}
+	samples := recordedSamples(10)
+	r := NewReplayer(memorySource{"source": samples}, sessions, sink, 100)
+
+	started := time.Now()
+	replay, err := r.Start(context.Background(), "source", 50)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if replay.SessionID != "replay-session" || replay.TotalSamples != len(samples) {
+		t.Fatalf("unexpected replay: %+v", replay)
+	}
+
+	done := waitFinished(t, r, replay.ID)
+	elapsed := time.Since(started)
+
+	if done.State != StateCompleted || done.SentSamples != len(samples) {
+		t.Fatalf("unexpected result: %+v", done)
+	}
+	// 10 секунд записи при 50x - около 200 мс
+	if elapsed < 150*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("replay took %v, want about 200ms", elapsed)
+	}
+
+	req := sessions.created[0]
+	if req.PatientID != "P001" || req.CreatedFrom != CreatedFrom || req.CustomData["replay_of"] != "source" {
+		t.Errorf("unexpected session request: %+v", req)
+	}
+	if len(sessions.stopped) != 1 || sessions.stopped[0] != "replay-session" {
+		t.Errorf("replay session not stopped: %v", sessions.stopped)
+	}
+
+	// Интервалы между метками времени сохраняются, ID сессии - новый
+	first, last := sink.samples[0], sink.samples[len(sink.samples)-1]
+	if first.SessionId != "replay-session" {
+		t.Errorf("sample session = %s", first.SessionId)
+	}
+	if span := int64(last.TsMs - first.TsMs); span != samples[len(samples)-1].TsMS-samples[0].TsMS {
+		t.Errorf("span = %d ms", span)
+	}
+	if first.Metric != telemetryv1.Metric_METRIC_FHR || first.Value != samples[0].Value {
+		t.Errorf("unexpected first sample: %+v", first)
+	}
+}
+
+func TestReplayCancel(t *testing.T) {
+	sessions := newMemorySessions()
+	sink := &memorySink{}
+	r := NewReplayer(memorySource{"source": recordedSamples(60)}, sessions, sink, 60)
+
+	replay, err := r.Start(context.Background(), "source", 1)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	cancelled, err := r.Cancel(replay.ID)
+	if err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if cancelled.State != StateCancelled || cancelled.FinishedAt == nil {
+		t.Fatalf("unexpected state: %+v", cancelled)
+	}
+	if sink.count() >= cancelled.TotalSamples {
+		t.Errorf("all %d samples sent despite cancel", sink.count())
+	}
+	if len(sessions.stopped) != 1 {
+		t.Errorf("replay session not stopped: %v", sessions.stopped)
+	}
+
+	if _, err := r.Cancel(replay.ID); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("second cancel: %v, want ErrNotRunning", err)
+	}
+	if _, err := r.Cancel("unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown cancel: %v, want ErrNotFound", err)
+	}
+}
+
+func TestReplayStartErrors(t *testing.T) {
+	r := NewReplayer(memorySource{"source": recordedSamples(1)}, newMemorySessions(), &memorySink{}, 10)
+
+	for _, speed := range []float64{0, -1, 11} {
+		if _, err := r.Start(context.Background(), "source", speed); !errors.Is(err, ErrInvalidSpeed) {
+			t.Errorf("speed %g: %v, want ErrInvalidSpeed", speed, err)
+		}
+	}
+	if _, err := r.Start(context.Background(), "empty", 1); !errors.Is(err, ErrNoSamples) {
+		t.Errorf("empty session: %v, want ErrNoSamples", err)
+	}
+	if len(r.List()) != 0 {
+		t.Errorf("failed starts must not be listed")
+	}
+}
@@ -0,0 +1,130 @@
+package report
+
+import (
+	"embed"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/Krimson/fetal-monitory/receiver/internal/alert"
+	"github.com/Krimson/fetal-monitory/receiver/internal/ctg"
+)
+
+//go:embed templates/report.html
+var templateFS embed.FS
+
+var htmlTemplate = template.Must(template.New("report.html").Funcs(template.FuncMap{
+	"time":          formatTime,
+	"timePtr":       formatTimePtr,
+	"duration":      formatDuration,
+	"clock":         formatClock,
+	"orDash":        orDash,
+	"eventTitle":    eventTitle,
+	"categoryTitle": categoryTitle,
+	"categoryColor": func(c ctg.Category) string { return categoryColor(c).hex() },
+	"alertState":    func(s alert.State) string { return alertStateTitle(s) },
+	"svg":           svgChart,
+	"percent":       func(v float64) string { return fmt.Sprintf("%.1f%%", v*100) },
+	"f1":            func(v float64) string { return fmt.Sprintf("%.1f", v) },
+	"f2":            func(v float64) string { return fmt.Sprintf("%.2f", v) },
+}).ParseFS(templateFS, "templates/report.html"))
+
+// htmlView - данные шаблона
+type htmlView struct {
+	*Data
+	Charts []chart
+}
+
+// RenderHTML рендерит самодостаточный HTML отчет (графики встроены как SVG)
+func RenderHTML(w io.Writer, d *Data) error {
+	return htmlTemplate.Execute(w, htmlView{Data: d, Charts: d.charts()})
+}
+
+// Размеры SVG графика
+const (
+	svgWidth   = 1000.0
+	svgHeight  = 220.0
+	svgPadLeft = 48.0
+	svgPadTop  = 10.0
+	svgPadBot  = 24.0
+)
+
+// svgChart рисует график как inline SVG
+func svgChart(c chart) template.HTML {
+	plotW := svgWidth - svgPadLeft - 10
+	plotH := svgHeight - svgPadTop - svgPadBot
+	minX, maxX := c.xRange()
+
+	x := func(t float64) float64 { return svgPadLeft + (t-minX)/(maxX-minX)*plotW }
+	y := func(v float64) float64 {
+		v = clamp(v, c.YMin, c.YMax)
+		return svgPadTop + plotH - (v-c.YMin)/(c.YMax-c.YMin)*plotH
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg viewBox="0 0 %.0f %.0f" xmlns="http://www.w3.org/2000/svg" role="img" aria-label="%s">`,
+		svgWidth, svgHeight, html.EscapeString(c.Title))
+
+	// Коридор нормы
+	if c.NormalHigh > c.NormalLow {
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`,
+			svgPadLeft, y(c.NormalHigh), plotW, y(c.NormalLow)-y(c.NormalHigh), colorNormalBand.hex())
+	}
+
+	// События
+	for _, bd := range c.Bands {
+		from, to := clamp(bd.From, minX, maxX), clamp(bd.To, minX, maxX)
+		if to <= from {
+			continue
+		}
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s" opacity="0.7"/>`,
+			x(from), svgPadTop, x(to)-x(from), plotH, bd.Color.hex())
+	}
+
+	// Сетка и подписи осей
+	for _, v := range gridValues(c.YMin, c.YMax) {
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#ddd" stroke-width="1"/>`,
+			svgPadLeft, y(v), svgPadLeft+plotW, y(v))
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" font-size="11" text-anchor="end" fill="#555">%g</text>`,
+			svgPadLeft-6, y(v)+4, v)
+	}
+	for _, t := range gridValues(minX, maxX) {
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" font-size="11" text-anchor="middle" fill="#555">%s</text>`,
+			x(t), svgHeight-6, formatClock(t))
+	}
+	fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="none" stroke="#999"/>`,
+		svgPadLeft, svgPadTop, plotW, plotH)
+
+	// Трасса
+	b.WriteString(`<polyline fill="none" stroke-width="1.2" stroke="` + c.Color.hex() + `" points="`)
+	for _, p := range c.Points {
+		fmt.Fprintf(&b, "%.1f,%.1f ", x(p.TimeSec), y(p.Value))
+	}
+	b.WriteString(`"/></svg>`)
+
+	return template.HTML(b.String())
+}
+
+// gridValues возвращает около пяти значений сетки внутри диапазона
+func gridValues(min, max float64) []float64 {
+	step := niceCeil((max - min) / 5)
+	var values []float64
+	for v := step * float64(int(min/step)); v <= max; v += step {
+		if v >= min {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func clamp(v, min, max float64) float64 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
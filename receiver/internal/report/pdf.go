@@ -0,0 +1,278 @@
+package report
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Шрифт с кириллицей: стандартные шрифты PDF ее не поддерживают
+//
+//go:embed fonts/DejaVuSansCondensed.ttf
+var fontDejaVu []byte
+
+const pdfFont = "dejavu"
+
+// Геометрия страницы A4 в мм
+const (
+	pdfMargin      = 12.0
+	pdfPageWidth   = 210.0
+	pdfPageHeight  = 297.0
+	pdfContentW    = pdfPageWidth - 2*pdfMargin
+	pdfChartHeight = 48.0
+	pdfAxisWidth   = 10.0
+)
+
+// RenderPDF рендерит отчет в PDF (A4) средствами чистого Go
+func RenderPDF(w io.Writer, d *Data) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(true, pdfMargin)
+	pdf.AddUTF8FontFromBytes(pdfFont, "", fontDejaVu)
+	pdf.SetTitle("Отчет КТГ "+d.SessionID, true)
+	pdf.AddPage()
+
+	r := &pdfRenderer{pdf: pdf}
+
+	r.text(16, "Отчет кардиотокографии")
+	r.muted("Сформирован " + formatTime(d.GeneratedAt))
+
+	r.heading("Сессия")
+	r.keyValues([][2]string{
+		{"ID сессии", d.SessionID},
+		{"Пациент", orDash(d.PatientID)},
+		{"Врач", orDash(d.DoctorID)},
+		{"Учреждение", orDash(d.FacilityID)},
+		{"Начало записи", formatTime(d.StartedAt)},
+		{"Окончание записи", formatTimePtr(d.StoppedAt)},
+		{"Сохранена", formatTimePtr(d.SavedAt)},
+		{"Длительность", formatDuration(d.DurationMs)},
+		{"Статус", d.Status},
+	})
+	if d.Notes != "" {
+		r.keyValues([][2]string{{"Заметки", d.Notes}})
+	}
+
+	r.heading("Заключение")
+	if c := d.Classification; c != nil {
+		color := categoryColor(c.Category)
+		pdf.SetFont(pdfFont, "", 11)
+		pdf.SetTextColor(color.R, color.G, color.B)
+		pdf.CellFormat(0, 7, fmt.Sprintf("Категория КТГ: %s (%s)", categoryTitle(c.Category), c.Guideline), "", 1, "L", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+
+		rows := make([][]string, 0, len(c.Criteria))
+		for _, cr := range c.Criteria {
+			rows = append(rows, []string{cr.Name, categoryTitle(cr.Category), cr.Reason})
+		}
+		r.table([]string{"Критерий", "Оценка", "Обоснование"}, []float64{30, 32, pdfContentW - 62}, rows)
+	} else {
+		r.muted("Классификация недоступна: метрики сессии не сохранены.")
+	}
+
+	if s := d.Summary; s != nil {
+		r.text(11, fmt.Sprintf("Вероятность патологии по ML модели: %.1f%%", s.Prediction*100))
+
+		r.heading("Сводные показатели")
+		r.keyValues([][2]string{
+			{"Базальная ЧСС", fmt.Sprintf("%.1f уд/мин", s.BaselineHeartRate)},
+			{"STV", fmt.Sprintf("%.2f мс", s.STV)},
+			{"LTV", fmt.Sprintf("%.2f мс", s.LTV)},
+			{"Акселерации", fmt.Sprintf("%d", s.TotalAccelerations)},
+			{"Децелерации (из них поздних)", fmt.Sprintf("%d (%d)", s.TotalDecelerations, s.LateDecelerations)},
+			{"Схватки", fmt.Sprintf("%d", s.TotalContractions)},
+			{"Длительность анализа", formatClock(s.TimeSpanSec)},
+		})
+	}
+
+	r.heading("Трассы")
+	charts := d.charts()
+	if len(charts) == 0 {
+		r.muted("Сигналы сессии не сохранены.")
+	}
+	for _, c := range charts {
+		r.chart(c)
+	}
+
+	r.heading("События")
+	if len(d.Events) > 0 {
+		rows := make([][]string, 0, len(d.Events))
+		for _, e := range d.Events {
+			rows = append(rows, []string{
+				eventTitle(e),
+				formatClock(e.StartSec),
+				formatClock(e.EndSec),
+				fmt.Sprintf("%.1f", e.Duration),
+				fmt.Sprintf("%.1f", e.Amplitude),
+			})
+		}
+		r.table([]string{"Событие", "Начало", "Окончание", "Длительность, с", "Амплитуда"},
+			[]float64{50, 32, 32, 36, pdfContentW - 150}, rows)
+	} else {
+		r.muted("Событий нет.")
+	}
+
+	r.heading("Клинические тревоги")
+	if len(d.Alerts) > 0 {
+		rows := make([][]string, 0, len(d.Alerts))
+		for _, a := range d.Alerts {
+			ack, resolved := "—", "—"
+			if a.AcknowledgedAt != nil {
+				ack = a.AcknowledgedBy + ", " + formatTimePtr(a.AcknowledgedAt)
+			}
+			if a.ResolvedAt != nil {
+				resolved = a.ResolvedBy + ", " + formatTimePtr(a.ResolvedAt)
+			}
+			rows = append(rows, []string{a.Message, formatTime(a.RaisedAt), alertStateTitle(a.State), ack, resolved})
+		}
+		r.table([]string{"Тревога", "Поднята", "Состояние", "Подтверждена", "Закрыта"},
+			[]float64{54, 32, 26, 37, pdfContentW - 149}, rows)
+	} else {
+		r.muted("Тревог не было.")
+	}
+
+	return pdf.Output(w)
+}
+
+// pdfRenderer - вспомогательные методы верстки PDF
+type pdfRenderer struct {
+	pdf *gofpdf.Fpdf
+}
+
+// ensureSpace переносит на новую страницу, если до нижнего поля осталось меньше h мм
+func (r *pdfRenderer) ensureSpace(h float64) {
+	if r.pdf.GetY()+h > pdfPageHeight-pdfMargin {
+		r.pdf.AddPage()
+	}
+}
+
+func (r *pdfRenderer) text(size float64, s string) {
+	r.pdf.SetFont(pdfFont, "", size)
+	r.pdf.MultiCell(0, size*0.5, s, "", "L", false)
+	r.pdf.Ln(1)
+}
+
+func (r *pdfRenderer) muted(s string) {
+	r.pdf.SetTextColor(120, 120, 120)
+	r.text(9, s)
+	r.pdf.SetTextColor(0, 0, 0)
+}
+
+func (r *pdfRenderer) heading(s string) {
+	r.ensureSpace(20)
+	r.pdf.Ln(3)
+	r.pdf.SetFont(pdfFont, "", 13)
+	r.pdf.CellFormat(0, 7, s, "B", 1, "L", false, 0, "")
+	r.pdf.Ln(2)
+}
+
+func (r *pdfRenderer) keyValues(rows [][2]string) {
+	r.pdf.SetFont(pdfFont, "", 9)
+	for _, row := range rows {
+		r.ensureSpace(5)
+		r.pdf.SetTextColor(90, 90, 90)
+		r.pdf.CellFormat(60, 5, row[0], "", 0, "L", false, 0, "")
+		r.pdf.SetTextColor(0, 0, 0)
+		r.pdf.MultiCell(0, 5, row[1], "", "L", false)
+	}
+}
+
+// table рисует таблицу; строки переносятся по ширине колонок
+func (r *pdfRenderer) table(header []string, widths []float64, rows [][]string) {
+	const lineH = 4.5
+	pdf := r.pdf
+	pdf.SetFont(pdfFont, "", 8.5)
+
+	r.ensureSpace(2 * lineH)
+	pdf.SetFillColor(240, 240, 240)
+	for i, h := range header {
+		pdf.CellFormat(widths[i], lineH+1, h, "B", 0, "L", true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	for _, row := range rows {
+		lines := 1
+		for i, cell := range row {
+			if n := len(pdf.SplitText(cell, widths[i]-2)); n > lines {
+				lines = n
+			}
+		}
+		h := float64(lines) * lineH
+		r.ensureSpace(h)
+
+		x, y := pdf.GetX(), pdf.GetY()
+		for i, cell := range row {
+			pdf.SetXY(x, y)
+			pdf.MultiCell(widths[i], lineH, cell, "", "L", false)
+			x += widths[i]
+		}
+		pdf.SetDrawColor(230, 230, 230)
+		pdf.Line(pdfMargin, y+h, pdfMargin+pdfContentW, y+h)
+		pdf.SetXY(pdfMargin, y+h)
+	}
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.Ln(2)
+}
+
+// chart рисует график: коридор нормы, события, сетку и трассу
+func (r *pdfRenderer) chart(c chart) {
+	pdf := r.pdf
+	r.ensureSpace(pdfChartHeight + 14)
+
+	pdf.SetFont(pdfFont, "", 9)
+	pdf.CellFormat(0, 5, c.Title+", "+c.Unit, "", 1, "L", false, 0, "")
+
+	left := pdfMargin + pdfAxisWidth
+	top := pdf.GetY()
+	width := pdfContentW - pdfAxisWidth
+	height := pdfChartHeight
+	minX, maxX := c.xRange()
+
+	x := func(t float64) float64 { return left + (t-minX)/(maxX-minX)*width }
+	y := func(v float64) float64 {
+		v = clamp(v, c.YMin, c.YMax)
+		return top + height - (v-c.YMin)/(c.YMax-c.YMin)*height
+	}
+
+	if c.NormalHigh > c.NormalLow {
+		pdf.SetFillColor(colorNormalBand.R, colorNormalBand.G, colorNormalBand.B)
+		pdf.Rect(left, y(c.NormalHigh), width, y(c.NormalLow)-y(c.NormalHigh), "F")
+	}
+	for _, bd := range c.Bands {
+		from, to := clamp(bd.From, minX, maxX), clamp(bd.To, minX, maxX)
+		if to <= from {
+			continue
+		}
+		pdf.SetFillColor(bd.Color.R, bd.Color.G, bd.Color.B)
+		pdf.Rect(x(from), top, x(to)-x(from), height, "F")
+	}
+
+	pdf.SetFont(pdfFont, "", 6.5)
+	pdf.SetTextColor(90, 90, 90)
+	pdf.SetDrawColor(220, 220, 220)
+	pdf.SetLineWidth(0.1)
+	for _, v := range gridValues(c.YMin, c.YMax) {
+		pdf.Line(left, y(v), left+width, y(v))
+		pdf.SetXY(pdfMargin, y(v)-1.5)
+		pdf.CellFormat(pdfAxisWidth-1, 3, fmt.Sprintf("%g", v), "", 0, "R", false, 0, "")
+	}
+	for _, t := range gridValues(minX, maxX) {
+		pdf.SetXY(x(t)-8, top+height+0.5)
+		pdf.CellFormat(16, 3, formatClock(t), "", 0, "C", false, 0, "")
+	}
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetDrawColor(150, 150, 150)
+	pdf.Rect(left, top, width, height, "D")
+
+	pdf.SetDrawColor(c.Color.R, c.Color.G, c.Color.B)
+	pdf.SetLineWidth(0.2)
+	for i := 1; i < len(c.Points); i++ {
+		p0, p1 := c.Points[i-1], c.Points[i]
+		pdf.Line(x(p0.TimeSec), y(p0.Value), x(p1.TimeSec), y(p1.Value))
+	}
+	pdf.SetDrawColor(0, 0, 0)
+
+	pdf.SetXY(pdfMargin, top+height+5)
+}
@@ -0,0 +1,346 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Krimson/fetal-monitory/receiver/internal/alert"
+	"github.com/Krimson/fetal-monitory/receiver/internal/ctg"
+	"github.com/Krimson/fetal-monitory/receiver/internal/downsample"
+)
+
+// Форматы отчета
+const (
+	FormatHTML = "html"
+	FormatPDF  = "pdf"
+)
+
+// Типы событий на трассах
+const (
+	EventAcceleration = "acceleration"
+	EventDeceleration = "deceleration"
+	EventContraction  = "contraction"
+)
+
+// maxChartPoints - сколько точек трассы рисуем; длинные записи прореживаются
+const maxChartPoints = 1500
+
+// Point - точка трассы (секунды от начала записи)
+type Point struct {
+	TimeSec float64
+	Value   float64
+}
+
+// Event - отмеченное событие на трассе
+type Event struct {
+	Type      string
+	StartSec  float64
+	EndSec    float64
+	Duration  float64 // секунды
+	Amplitude float64
+	IsLate    bool
+}
+
+// Series - ряд значений по окнам фиксированной длительности (STV, LTV)
+type Series struct {
+	Values    []float64
+	WindowSec float64
+}
+
+// Summary - сводные метрики сессии
+type Summary struct {
+	STV                float64
+	LTV                float64
+	BaselineHeartRate  float64
+	TotalAccelerations int32
+	TotalDecelerations int32
+	LateDecelerations  int32
+	TotalContractions  int32
+	TimeSpanSec        float64
+	Prediction         float64
+}
+
+// Data - все, что попадает в отчет по сохраненной сессии
+type Data struct {
+	SessionID  string
+	Status     string
+	StartedAt  time.Time
+	StoppedAt  *time.Time
+	SavedAt    *time.Time
+	DurationMs int64
+
+	PatientID  string
+	DoctorID   string
+	FacilityID string
+	Notes      string
+
+	Summary        *Summary // nil, если метрик нет
+	Classification *ctg.Classification
+
+	FHR    []Point
+	UC     []Point
+	Events []Event
+	STV    Series
+	LTV    Series
+
+	Alerts []*alert.Alert
+
+	GeneratedAt time.Time
+}
+
+// ===== Модель графика, общая для HTML и PDF =====
+
+type rgb struct {
+	R, G, B int
+}
+
+func (c rgb) hex() string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+var (
+	colorFHR          = rgb{200, 30, 45}
+	colorUC           = rgb{30, 90, 200}
+	colorSTV          = rgb{120, 60, 170}
+	colorLTV          = rgb{20, 140, 120}
+	colorNormalBand   = rgb{225, 245, 225}
+	colorAcceleration = rgb{170, 225, 170}
+	colorDeceleration = rgb{250, 200, 150}
+	colorLateDecel    = rgb{245, 150, 150}
+	colorContraction  = rgb{190, 210, 245}
+)
+
+// band - выделенный интервал по оси времени
+type band struct {
+	From, To float64
+	Color    rgb
+}
+
+type chart struct {
+	Title      string
+	Unit       string
+	Points     []Point
+	Color      rgb
+	YMin, YMax float64
+	NormalLow  float64 // Коридор нормы (0 - нет)
+	NormalHigh float64
+	Bands      []band
+}
+
+// xRange возвращает границы оси времени
+func (c *chart) xRange() (float64, float64) {
+	if len(c.Points) == 0 {
+		return 0, 1
+	}
+	minX, maxX := c.Points[0].TimeSec, c.Points[len(c.Points)-1].TimeSec
+	if maxX <= minX {
+		maxX = minX + 1
+	}
+	return minX, maxX
+}
+
+// charts собирает графики отчета: ЧСС, маточная активность, STV и LTV
+func (d *Data) charts() []chart {
+	var charts []chart
+
+	if len(d.FHR) > 0 {
+		c := chart{
+			Title:      "ЧСС плода",
+			Unit:       "уд/мин",
+			Points:     downsampleTrace(d.FHR, maxChartPoints),
+			Color:      colorFHR,
+			YMin:       50,
+			YMax:       210,
+			NormalLow:  110,
+			NormalHigh: 160,
+		}
+		for _, e := range d.Events {
+			switch e.Type {
+			case EventAcceleration:
+				c.Bands = append(c.Bands, band{From: e.StartSec, To: e.EndSec, Color: colorAcceleration})
+			case EventDeceleration:
+				color := colorDeceleration
+				if e.IsLate {
+					color = colorLateDecel
+				}
+				c.Bands = append(c.Bands, band{From: e.StartSec, To: e.EndSec, Color: color})
+			}
+		}
+		charts = append(charts, c)
+	}
+
+	if len(d.UC) > 0 {
+		c := chart{
+			Title:  "Маточная активность",
+			Unit:   "отн. ед.",
+			Points: downsampleTrace(d.UC, maxChartPoints),
+			Color:  colorUC,
+			YMin:   0,
+			YMax:   math.Max(100, maxValue(d.UC)),
+		}
+		for _, e := range d.Events {
+			if e.Type == EventContraction {
+				c.Bands = append(c.Bands, band{From: e.StartSec, To: e.EndSec, Color: colorContraction})
+			}
+		}
+		charts = append(charts, c)
+	}
+
+	if len(d.STV.Values) > 0 {
+		charts = append(charts, seriesChart("STV", "мс", d.STV, colorSTV))
+	}
+	if len(d.LTV.Values) > 0 {
+		charts = append(charts, seriesChart("LTV", "мс", d.LTV, colorLTV))
+	}
+
+	return charts
+}
+
+func seriesChart(title, unit string, s Series, color rgb) chart {
+	points := make([]Point, len(s.Values))
+	for i, v := range s.Values {
+		points[i] = Point{TimeSec: float64(i) * s.WindowSec, Value: v}
+	}
+	return chart{
+		Title:  title,
+		Unit:   unit,
+		Points: downsampleTrace(points, maxChartPoints),
+		Color:  color,
+		YMin:   0,
+		YMax:   niceCeil(maxValue(points)),
+	}
+}
+
+// downsampleTrace прореживает трассу до не более чем max точек алгоритмом MinMax:
+// каждый бакет по времени дает минимум и максимум, поэтому кратковременные
+// децелерации и пики не пропадают с графика, как при простом шаге по индексу
+func downsampleTrace(points []Point, max int) []Point {
+	if len(points) <= max || max <= 0 {
+		return points
+	}
+
+	trace := make([]downsample.Point, len(points))
+	for i, p := range points {
+		trace[i] = downsample.Point{X: p.TimeSec, Y: p.Value}
+	}
+
+	sampled := downsample.MinMax(trace, max)
+	result := make([]Point, len(sampled))
+	for i, p := range sampled {
+		result[i] = Point{TimeSec: p.X, Value: p.Y}
+	}
+	return result
+}
+
+func maxValue(points []Point) float64 {
+	m := 0.0
+	for _, p := range points {
+		if p.Value > m {
+			m = p.Value
+		}
+	}
+	return m
+}
+
+// niceCeil округляет верхнюю границу оси вверх до "красивого" числа
+func niceCeil(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if v <= m*magnitude {
+			return m * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+// ===== Форматирование =====
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "—"
+	}
+	return t.Format("02.01.2006 15:04:05")
+}
+
+func formatTimePtr(t *time.Time) string {
+	if t == nil {
+		return "—"
+	}
+	return formatTime(*t)
+}
+
+func formatDuration(ms int64) string {
+	d := time.Duration(ms) * time.Millisecond
+	return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
+
+func formatClock(sec float64) string {
+	return formatDuration(int64(sec * 1000))
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "—"
+	}
+	return s
+}
+
+// eventTitle - подпись события в таблице
+func eventTitle(e Event) string {
+	switch e.Type {
+	case EventAcceleration:
+		return "Акселерация"
+	case EventDeceleration:
+		if e.IsLate {
+			return "Поздняя децелерация"
+		}
+		return "Децелерация"
+	case EventContraction:
+		return "Схватка"
+	default:
+		return e.Type
+	}
+}
+
+func categoryTitle(c ctg.Category) string {
+	switch c {
+	case ctg.CategoryNormal:
+		return "Нормальная"
+	case ctg.CategorySuspicious:
+		return "Подозрительная"
+	case ctg.CategoryPathological:
+		return "Патологическая"
+	default:
+		return "Недостаточно данных"
+	}
+}
+
+func categoryColor(c ctg.Category) rgb {
+	switch c {
+	case ctg.CategoryNormal:
+		return rgb{30, 130, 60}
+	case ctg.CategorySuspicious:
+		return rgb{200, 130, 0}
+	case ctg.CategoryPathological:
+		return rgb{200, 30, 45}
+	default:
+		return rgb{110, 110, 110}
+	}
+}
+
+func alertStateTitle(s alert.State) string {
+	switch s {
+	case alert.StateActive:
+		return "Активна"
+	case alert.StateAcknowledged:
+		return "Подтверждена"
+	case alert.StateResolved:
+		return "Закрыта"
+	default:
+		return string(s)
+	}
+}
@@ -0,0 +1,154 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Krimson/fetal-monitory/receiver/internal/alert"
+	"github.com/Krimson/fetal-monitory/receiver/internal/ctg"
+)
+
+// sampleData - 20 минут записи с децелерацией, схваткой и тревогой
+func sampleData() *Data {
+	started := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	stopped := started.Add(20 * time.Minute)
+
+	d := &Data{
+		SessionID:  "session-1",
+		Status:     "SAVED",
+		StartedAt:  started,
+		StoppedAt:  &stopped,
+		SavedAt:    &stopped,
+		DurationMs: (20 * time.Minute).Milliseconds(),
+		PatientID:  "P-42",
+		DoctorID:   "Иванова",
+		Notes:      "Плановое наблюдение <без осложнений>",
+		Summary: &Summary{
+			STV:                5.2,
+			LTV:                32,
+			BaselineHeartRate:  138,
+			TotalDecelerations: 1,
+			LateDecelerations:  1,
+			TotalContractions:  1,
+			TimeSpanSec:        1200,
+			Prediction:         0.27,
+		},
+		Events: []Event{
+			{Type: EventDeceleration, StartSec: 300, EndSec: 360, Duration: 60, Amplitude: 25, IsLate: true},
+			{Type: EventContraction, StartSec: 280, EndSec: 340, Duration: 60, Amplitude: 60},
+		},
+		STV:         Series{Values: []float64{5, 5.5, 4.8}, WindowSec: 60},
+		LTV:         Series{Values: []float64{30, 34, 31}, WindowSec: 60},
+		GeneratedAt: stopped,
+	}
+	for i := 0; i < 4800; i++ {
+		t := float64(i) / 4
+		d.FHR = append(d.FHR, Point{TimeSec: t, Value: 138 + float64(i%20) - 10})
+		d.UC = append(d.UC, Point{TimeSec: t, Value: float64(i % 80)})
+	}
+	d.Classification = ctg.Classify(ctg.Input{
+		BaselineHeartRate: 138,
+		STV:               5.2,
+		LTV:               32,
+		LTVWindows:        d.LTV.Values,
+		LTVWindowSec:      d.LTV.WindowSec,
+		TotalContractions: 1,
+		LateDecelerations: 1,
+	})
+
+	ackAt := started.Add(6 * time.Minute)
+	d.Alerts = []*alert.Alert{{
+		ID:             "alert-1",
+		SessionID:      "session-1",
+		Type:           alert.TypeLateDecelerations,
+		Severity:       alert.SeverityWarning,
+		State:          alert.StateAcknowledged,
+		Message:        "Поздние децелерации",
+		RaisedAt:       started.Add(5 * time.Minute),
+		AcknowledgedAt: &ackAt,
+		AcknowledgedBy: "dr.ivanova",
+	}}
+
+	return d
+}
+
+func TestRenderHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := RenderHTML(&buf, sampleData()); err != nil {
+		t.Fatalf("RenderHTML: %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"session-1",
+		"P-42",
+		"Нормальная",
+		"27.0%",
+		"Поздняя децелерация",
+		"Поздние децелерации",
+		"dr.ivanova",
+		"<svg",
+		"&lt;без осложнений&gt;",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("HTML report does not contain %q", want)
+		}
+	}
+	if got := strings.Count(out, "<svg"); got != 4 {
+		t.Errorf("HTML report has %d charts, want 4", got)
+	}
+}
+
+func TestRenderHTMLWithoutData(t *testing.T) {
+	var buf bytes.Buffer
+	err := RenderHTML(&buf, &Data{SessionID: "empty", Status: "SAVED"})
+	if err != nil {
+		t.Fatalf("RenderHTML: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Сигналы сессии не сохранены") {
+		t.Error("empty report should say that signals are missing")
+	}
+}
+
+func TestRenderPDF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := RenderPDF(&buf, sampleData()); err != nil {
+		t.Fatalf("RenderPDF: %v", err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")) {
+		t.Fatalf("output is not a PDF: %q", buf.Bytes()[:min(16, buf.Len())])
+	}
+}
+
+func TestDownsampleTrace(t *testing.T) {
+	points := make([]Point, 10000)
+	for i := range points {
+		points[i] = Point{TimeSec: float64(i), Value: 140}
+	}
+	// Кратковременная децелерация между шагами прореживания по индексу
+	points[4321].Value = 80
+
+	got := downsampleTrace(points, 1000)
+	if len(got) == 0 || len(got) > 1000 {
+		t.Fatalf("len = %d, want 1..1000", len(got))
+	}
+	// Трасса покрывается целиком: первая точка и последний бакет (20 с) на месте
+	if got[0].TimeSec != 0 || got[len(got)-1].TimeSec < 9980 {
+		t.Errorf("unexpected bounds %v..%v", got[0].TimeSec, got[len(got)-1].TimeSec)
+	}
+
+	dip := false
+	for i, p := range got {
+		if i > 0 && p.TimeSec < got[i-1].TimeSec {
+			t.Fatalf("points out of order at %d", i)
+		}
+		if p.Value == 80 {
+			dip = true
+		}
+	}
+	if !dip {
+		t.Error("deceleration lost by downsampling")
+	}
+}
@@ -1,20 +1,42 @@
 package server
 
 import (
+	"context"
 	"io"
-	"log"
+	"log/slog"
 	"sync"
+	"time"
 
+	"github.com/Krimson/fetal-monitory/logging"
 	telemetryv1 "github.com/Krimson/fetal-monitory/proto/telemetry"
 	"github.com/Krimson/fetal-monitory/receiver/internal/batch"
 	"github.com/Krimson/fetal-monitory/receiver/internal/config"
 )
 
+// SampleRecorder сохраняет сырые сэмплы (см. пакет recorder)
+type SampleRecorder interface {
+	Record(sample *telemetryv1.Sample)
+}
+
+// SessionAuthorizer проверяет право отправителя потока писать в сессию (см. пакет device).
+// Ошибка должна быть gRPC статусом: с ней поток завершается
+type SessionAuthorizer interface {
+	AuthorizeSession(ctx context.Context, sessionID string) error
+}
+
+// sessionAuthTTL - как долго проверка доступа к сессии действует в потоке. После
+// этого она повторяется, чтобы отключение устройства, смена ключа или отвязка от
+// сессии обрывали уже открытые потоки
+const sessionAuthTTL = 30 * time.Second
+
 // DataServer реализует telemetryv1.DataServiceServer
 type DataServer struct {
 	telemetryv1.UnimplementedDataServiceServer
-	cfg     *config.Config
-	batcher *batch.Batcher
+	cfg        *config.Config
+	batcher    *batch.Batcher
+	recorder   SampleRecorder
+	authorizer SessionAuthorizer
+	authTTL    time.Duration
 }
 
 // NewDataServer создает новый экземпляр DataServer
@@ -22,18 +44,31 @@ func NewDataServer(cfg *config.Config, batcher *batch.Batcher) *DataServer {
 	return &DataServer{
 		cfg:     cfg,
 		batcher: batcher,
+		authTTL: sessionAuthTTL,
 	}
 }
 
+// SetRecorder включает запись сырых сэмплов
+func (s *DataServer) SetRecorder(recorder SampleRecorder) {
+	s.recorder = recorder
+}
+
+// SetSessionAuthorizer включает проверку привязки устройства к сессии
+func (s *DataServer) SetSessionAuthorizer(authorizer SessionAuthorizer) {
+	s.authorizer = authorizer
+}
+
 // PushSamples обрабатывает стрим сэмплов от клиента
 func (s *DataServer) PushSamples(stream telemetryv1.DataService_PushSamplesServer) error {
-	log.Printf("[INFO] New PushSamples stream started")
+	slog.Info("New PushSamples stream started")
 
 	// Счетчики для Ack
 	var (
 		mu              sync.Mutex
 		totalReceived   uint64 = 0
 		sessionCounters        = make(map[string]uint64)
+		// Время последней успешной проверки доступа к сессии в этом потоке
+		authorizedAt = make(map[string]time.Time)
 	)
 
 	// Горутина для отправки Ack
@@ -46,23 +81,33 @@ func (s *DataServer) PushSamples(stream telemetryv1.DataService_PushSamplesServe
 	for {
 		select {
 		case <-stream.Context().Done():
-			log.Printf("[INFO] PushSamples stream context cancelled")
+			slog.Info("PushSamples stream context cancelled")
 			return stream.Context().Err()
 
 		default:
 			sample, err := stream.Recv()
 			if err != nil {
 				if err == io.EOF {
-					log.Printf("[INFO] PushSamples stream finished normally")
+					slog.Info("PushSamples stream finished normally")
 					return nil
 				}
-				log.Printf("[ERROR] Failed to receive sample: %v", err)
+				slog.Error("Failed to receive sample", logging.Err(err))
 				return err
 			}
 
+			// Проверяем доступ к сессии при первом сэмпле и повторно по истечении authTTL
+			if s.authorizer != nil {
+				if at, ok := authorizedAt[sample.SessionId]; !ok || time.Since(at) >= s.authTTL {
+					if err := s.authorizer.AuthorizeSession(stream.Context(), sample.SessionId); err != nil {
+						return err
+					}
+					authorizedAt[sample.SessionId] = time.Now()
+				}
+			}
+
 			// Обрабатываем сэмпл
-			if err := s.processSample(sample); err != nil {
-				log.Printf("[WARN] Failed to process sample: %v", err)
+			if err := s.processSample(stream.Context(), sample); err != nil {
+				slog.Warn("Failed to process sample", logging.SessionID(sample.SessionId), logging.Err(err))
 				// Не возвращаем ошибку, продолжаем обработку
 				continue
 			}
@@ -71,15 +116,21 @@ func (s *DataServer) PushSamples(stream telemetryv1.DataService_PushSamplesServe
 			select {
 			case ackChan <- sample:
 			default:
-				log.Printf("[WARN] Ack channel full, skipping ack for sample")
+				slog.Warn("Ack channel full, skipping ack for sample")
 			}
 		}
 	}
 }
 
-// processSample обрабатывает один сэмпл
-func (s *DataServer) processSample(sample *telemetryv1.Sample) error {
-	return s.batcher.Add(sample)
+// processSample обрабатывает один сэмпл; ctx несет спан потока PushSamples
+func (s *DataServer) processSample(ctx context.Context, sample *telemetryv1.Sample) error {
+	if err := s.batcher.AddContext(ctx, sample); err != nil {
+		return err
+	}
+	if s.recorder != nil {
+		s.recorder.Record(sample)
+	}
+	return nil
 }
 
 // ackSender отправляет Ack сообщения клиенту
@@ -112,13 +163,12 @@ func (s *DataServer) ackSender(
 				}
 
 				if err := stream.Send(ack); err != nil {
-					log.Printf("[ERROR] Failed to send ack: %v", err)
+					slog.Error("Failed to send ack", logging.Err(err))
 					mu.Unlock()
 					return
 				}
 
-				log.Printf("[DEBUG] Sent ack: session=%s count=%d",
-					ack.SessionId, ack.ReceivedCnt)
+				slog.Debug("Sent ack", logging.SessionID(ack.SessionId), "count", ack.ReceivedCnt)
 			}
 			mu.Unlock()
 		}
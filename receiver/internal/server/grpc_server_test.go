@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	telemetryv1 "github.com/Krimson/fetal-monitory/proto/telemetry"
+	"github.com/Krimson/fetal-monitory/receiver/internal/batch"
+	"github.com/Krimson/fetal-monitory/receiver/internal/config"
+)
+
+type discardSink struct{}
+
+func (discardSink) Consume(context.Context, batch.Batch) error { return nil }
+
+// fakePushStream отдает сэмплы по очереди, затем io.EOF
+type fakePushStream struct {
+	grpc.ServerStream
+	samples []*telemetryv1.Sample
+}
+
+func (s *fakePushStream) Context() context.Context { return context.Background() }
+
+func (s *fakePushStream) Send(*telemetryv1.Ack) error { return nil }
+
+func (s *fakePushStream) Recv() (*telemetryv1.Sample, error) {
+	if len(s.samples) == 0 {
+		return nil, io.EOF
+	}
+	sample := s.samples[0]
+	s.samples = s.samples[1:]
+	return sample, nil
+}
+
+// revokingAuthorizer разрешает первые allowed проверок, затем отказывает (устройство отвязали)
+type revokingAuthorizer struct {
+	allowed int
+	calls   int
+}
+
+func (a *revokingAuthorizer) AuthorizeSession(context.Context, string) error {
+	a.calls++
+	if a.calls > a.allowed {
+		return status.Error(codes.PermissionDenied, "device is not bound to session")
+	}
+	return nil
+}
+
+func newTestDataServer(t *testing.T, authorizer SessionAuthorizer, ttl time.Duration) *DataServer {
+	t.Helper()
+	cfg := &config.Config{BatchMaxSamples: 100, BatchMaxSpanMS: 30000, FlushIntervalMS: 500, AckEveryN: 50, DropTooOldMS: 30000}
+	batcher := batch.NewBatcher(cfg, discardSink{})
+	t.Cleanup(batcher.Stop)
+
+	s := NewDataServer(cfg, batcher)
+	s.SetSessionAuthorizer(authorizer)
+	s.authTTL = ttl
+	return s
+}
+
+func testSamples(n int) []*telemetryv1.Sample {
+	samples := make([]*telemetryv1.Sample, n)
+	for i := range samples {
+		samples[i] = &telemetryv1.Sample{SessionId: "session1", TsMs: uint64(1000 + 250*i), Metric: telemetryv1.Metric_METRIC_FHR, Value: 140}
+	}
+	return samples
+}
+
+func TestPushSamples_ReauthorizesAfterTTL(t *testing.T) {
+	authorizer := &revokingAuthorizer{allowed: 1}
+	s := newTestDataServer(t, authorizer, 0)
+
+	// Доступ отозван после первой проверки: поток обрывается на следующей
+	err := s.PushSamples(&fakePushStream{samples: testSamples(3)})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("Expected PermissionDenied after access was revoked, got %v", err)
+	}
+	if authorizer.calls != 2 {
+		t.Errorf("Expected 2 authorization checks, got %d", authorizer.calls)
+	}
+}
+
+func TestPushSamples_CachesAuthorizationWithinTTL(t *testing.T) {
+	authorizer := &revokingAuthorizer{allowed: 1}
+	s := newTestDataServer(t, authorizer, time.Hour)
+
+	if err := s.PushSamples(&fakePushStream{samples: testSamples(3)}); err != nil {
+		t.Fatalf("PushSamples failed: %v", err)
+	}
+	if authorizer.calls != 1 {
+		t.Errorf("Expected a single authorization check within TTL, got %d", authorizer.calls)
+	}
+}
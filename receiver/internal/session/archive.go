@@ -0,0 +1,313 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Krimson/fetal-monitory/archive"
+	"github.com/Krimson/fetal-monitory/logging"
+	telemetryv1 "github.com/Krimson/fetal-monitory/proto/telemetry"
+)
+
+// ErrSessionExists возвращается при импорте сессии, ID которой уже есть в PostgreSQL
+var ErrSessionExists = errors.New("session already exists")
+
+// ExportSession собирает переносимый архив сохраненной сессии
+func (m *Manager) ExportSession(ctx context.Context, sessionID string) (*archive.Archive, error) {
+	session, err := m.repository.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	a := archive.New(archive.SourceReceiver)
+	a.Session = archive.Session{
+		ID:              session.ID,
+		Status:          string(session.Status),
+		StartedAt:       session.StartedAt,
+		StoppedAt:       session.StoppedAt,
+		SavedAt:         session.SavedAt,
+		TotalDurationMs: session.TotalDurationMs,
+		TotalDataPoints: session.TotalDataPoints,
+		PatientID:       session.Metadata.PatientID,
+		DoctorID:        session.Metadata.DoctorID,
+		FacilityID:      session.Metadata.FacilityID,
+		Notes:           session.Metadata.Notes,
+		CreatedFrom:     session.Metadata.CreatedFrom,
+		CustomData:      session.Metadata.CustomData,
+	}
+
+	// Метрик нет, если сессию сохранили до первого батча признаков
+	if metrics, err := m.repository.GetMetrics(ctx, sessionID); err == nil {
+		a.Metrics = archiveMetrics(metrics)
+		a.Predictions = []archive.Prediction{{At: metrics.UpdatedAt, Value: metrics.Prediction}}
+	}
+
+	events, err := m.repository.GetEvents(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get events: %w", err)
+	}
+	a.Events = make([]archive.Event, 0, len(events))
+	for _, e := range events {
+		a.Events = append(a.Events, archive.Event{
+			Type:      string(e.Type),
+			Start:     e.StartTime,
+			End:       e.EndTime,
+			Duration:  e.Duration,
+			Amplitude: e.Amplitude,
+			IsLate:    e.IsLate,
+		})
+	}
+
+	for seriesType, series := range map[TimeSeriesType]*archive.Series{
+		TimeSeriesTypeSTV: &a.TimeSeries.STV,
+		TimeSeriesTypeLTV: &a.TimeSeries.LTV,
+	} {
+		points, err := m.repository.GetTimeSeries(ctx, sessionID, seriesType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s time series: %w", seriesType, err)
+		}
+		*series = archiveSeries(points)
+	}
+
+	for metricType, signal := range map[MetricType]*archive.Signal{
+		MetricTypeBPM:    &a.FilteredSignals.FHR,
+		MetricTypeUterus: &a.FilteredSignals.UC,
+	} {
+		points, err := m.repository.GetFilteredData(ctx, sessionID, metricType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s data: %w", metricType, err)
+		}
+		*signal = archiveSignal(points)
+	}
+
+	raw, err := m.repository.GetRawSamples(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get raw samples: %w", err)
+	}
+	a.RawSignals = archiveRawSignals(raw)
+
+	return a, nil
+}
+
+// ImportSession сохраняет сессию из архива в PostgreSQL со статусом SAVED.
+// Сессия сохраняет исходный ID; если он уже занят, возвращается ErrSessionExists.
+// Сессия, ее данные и сырой поток (нужен для воспроизведения) пишутся в одной транзакции
+func (m *Manager) ImportSession(ctx context.Context, a *archive.Archive) (*Session, error) {
+	data := sessionDataFromArchive(a)
+	if err := m.repository.ImportSessionData(ctx, data, rawSamplesFromArchive(a)); err != nil {
+		if errors.Is(err, ErrSessionExists) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to save imported session: %w", err)
+	}
+
+	slog.Info("Imported session from archive", logging.SessionID(a.Session.ID), "source", a.Source, "version", a.Version,
+		"events", len(data.Events), "fhr_points", len(data.FilteredBPMData))
+	return data.Session, nil
+}
+
+// sessionDataFromArchive преобразует архив в данные сессии для сохранения
+func sessionDataFromArchive(a *archive.Archive) *SessionData {
+	sessionID := a.Session.ID
+
+	customData := make(map[string]interface{}, len(a.Session.CustomData)+1)
+	for k, v := range a.Session.CustomData {
+		customData[k] = v
+	}
+	customData["imported_from"] = a.Source
+
+	session := &Session{
+		ID:              sessionID,
+		Status:          SessionStatusSaved,
+		StartedAt:       a.Session.StartedAt,
+		StoppedAt:       a.Session.StoppedAt,
+		SavedAt:         a.Session.SavedAt,
+		TotalDurationMs: a.Session.TotalDurationMs,
+		TotalDataPoints: a.Session.TotalDataPoints,
+		Metadata: Metadata{
+			PatientID:   a.Session.PatientID,
+			DoctorID:    a.Session.DoctorID,
+			FacilityID:  a.Session.FacilityID,
+			Notes:       a.Session.Notes,
+			CustomData:  customData,
+			CreatedFrom: a.Session.CreatedFrom,
+		},
+	}
+	if session.SavedAt == nil {
+		now := time.Now()
+		session.SavedAt = &now
+	}
+
+	data := &SessionData{
+		Session:            session,
+		Events:             make([]SessionEvent, 0, len(a.Events)),
+		TimeSeriesSTV:      timeSeriesFromArchive(sessionID, TimeSeriesTypeSTV, a.TimeSeries.STV),
+		TimeSeriesLTV:      timeSeriesFromArchive(sessionID, TimeSeriesTypeLTV, a.TimeSeries.LTV),
+		FilteredBPMData:    filteredDataFromArchive(a.FilteredSignals.FHR),
+		FilteredUterusData: filteredDataFromArchive(a.FilteredSignals.UC),
+	}
+
+	for _, e := range a.Events {
+		data.Events = append(data.Events, SessionEvent{
+			SessionID: sessionID,
+			Type:      EventType(e.Type),
+			StartTime: e.Start,
+			EndTime:   e.End,
+			Duration:  e.Duration,
+			Amplitude: e.Amplitude,
+			IsLate:    e.IsLate,
+			CreatedAt: time.Now(),
+		})
+	}
+
+	prediction, hasPrediction := a.LastPrediction()
+	if a.Metrics != nil || hasPrediction {
+		metrics := &SessionMetrics{SessionID: sessionID, UpdatedAt: prediction.At}
+		if mt := a.Metrics; mt != nil {
+			metrics.STV = mt.STV
+			metrics.LTV = mt.LTV
+			metrics.BaselineHeartRate = mt.BaselineHeartRate
+			metrics.TotalAccelerations = int32(mt.TotalAccelerations)
+			metrics.TotalDecelerations = int32(mt.TotalDecelerations)
+			metrics.LateDecelerations = int32(mt.LateDecelerations)
+			metrics.LateDecelerationRatio = mt.LateDecelerationRatio
+			metrics.TotalContractions = int32(mt.TotalContractions)
+			metrics.AccelDecelRatio = mt.AccelDecelRatio
+			metrics.STVTrend = mt.STVTrend
+			metrics.BPMTrend = mt.BPMTrend
+			metrics.DataPoints = int32(mt.DataPoints)
+			metrics.TimeSpanSec = mt.TimeSpanSec
+			metrics.UpdatedAt = mt.UpdatedAt
+		}
+		metrics.Prediction = prediction.Value
+		if metrics.UpdatedAt.IsZero() {
+			metrics.UpdatedAt = time.Now()
+		}
+		data.Metrics = metrics
+	}
+
+	return data
+}
+
+func archiveMetrics(m *SessionMetrics) *archive.Metrics {
+	return &archive.Metrics{
+		STV:                   m.STV,
+		LTV:                   m.LTV,
+		BaselineHeartRate:     m.BaselineHeartRate,
+		TotalAccelerations:    int64(m.TotalAccelerations),
+		TotalDecelerations:    int64(m.TotalDecelerations),
+		LateDecelerations:     int64(m.LateDecelerations),
+		LateDecelerationRatio: m.LateDecelerationRatio,
+		TotalContractions:     int64(m.TotalContractions),
+		AccelDecelRatio:       m.AccelDecelRatio,
+		STVTrend:              m.STVTrend,
+		BPMTrend:              m.BPMTrend,
+		DataPoints:            int64(m.DataPoints),
+		TimeSpanSec:           m.TimeSpanSec,
+		UpdatedAt:             m.UpdatedAt,
+	}
+}
+
+func archiveSeries(points []TimeSeriesPoint) archive.Series {
+	series := archive.Series{Values: make([]float64, 0, len(points))}
+	for _, p := range points {
+		series.Values = append(series.Values, p.Value)
+		series.WindowSec = p.WindowDuration
+	}
+	return series
+}
+
+func archiveSignal(points []FilteredDataPoint) archive.Signal {
+	signal := archive.Signal{
+		TimeSec: make([]float64, 0, len(points)),
+		Value:   make([]float64, 0, len(points)),
+	}
+	for _, p := range points {
+		signal.TimeSec = append(signal.TimeSec, p.TimeSec)
+		signal.Value = append(signal.Value, p.Value)
+	}
+	return signal
+}
+
+// archiveRawSignals переводит сырые сэмплы в сигналы архива; время отсчитывается
+// от первого сэмпла, его абсолютное время сохраняется в T0Ms
+func archiveRawSignals(samples []RawSample) archive.Signals {
+	var signals archive.Signals
+	if len(samples) == 0 {
+		return signals
+	}
+
+	t0 := samples[0].TsMS
+	for _, s := range samples {
+		if s.TsMS < t0 {
+			t0 = s.TsMS
+		}
+	}
+
+	signals.T0Ms = t0
+
+	for _, s := range samples {
+		signal := &signals.FHR
+		if s.Metric == telemetryv1.Metric_METRIC_UC {
+			signal = &signals.UC
+		}
+		signal.TimeSec = append(signal.TimeSec, float64(s.TsMS-t0)/1000)
+		signal.Value = append(signal.Value, float64(s.Value))
+	}
+	return signals
+}
+
+// rawSamplesFromArchive восстанавливает сырой поток из архива с исходными временными
+// метками: время сигналов отсчитывается от T0Ms (в старых архивах - от начала сессии)
+func rawSamplesFromArchive(a *archive.Archive) []RawSample {
+	baseMS := a.RawSignals.BaseMs(a.Session.StartedAt)
+	samples := make([]RawSample, 0, a.RawSignals.FHR.Len()+a.RawSignals.UC.Len())
+
+	for metric, signal := range map[telemetryv1.Metric]archive.Signal{
+		telemetryv1.Metric_METRIC_FHR: a.RawSignals.FHR,
+		telemetryv1.Metric_METRIC_UC:  a.RawSignals.UC,
+	} {
+		for i := range signal.TimeSec {
+			samples = append(samples, RawSample{
+				TsMS:   baseMS + int64(math.Round(signal.TimeSec[i]*1000)),
+				Metric: metric,
+				Value:  float32(signal.Value[i]),
+			})
+		}
+	}
+
+	sort.SliceStable(samples, func(i, j int) bool {
+		if samples[i].TsMS != samples[j].TsMS {
+			return samples[i].TsMS < samples[j].TsMS
+		}
+		return samples[i].Metric < samples[j].Metric
+	})
+	return samples
+}
+
+func timeSeriesFromArchive(sessionID string, seriesType TimeSeriesType, series archive.Series) []TimeSeriesPoint {
+	points := make([]TimeSeriesPoint, 0, len(series.Values))
+	for i, v := range series.Values {
+		points = append(points, TimeSeriesPoint{
+			SessionID:      sessionID,
+			Type:           seriesType,
+			TimeIndex:      i,
+			Value:          v,
+			WindowDuration: series.WindowSec,
+		})
+	}
+	return points
+}
+
+func filteredDataFromArchive(signal archive.Signal) []FilteredDataPoint {
+	points := make([]FilteredDataPoint, 0, signal.Len())
+	for i := range signal.TimeSec {
+		points = append(points, FilteredDataPoint{TimeSec: signal.TimeSec[i], Value: signal.Value[i]})
+	}
+	return points
+}
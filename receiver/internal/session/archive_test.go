@@ -0,0 +1,61 @@
+package session
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/Krimson/fetal-monitory/archive"
+	telemetryv1 "github.com/Krimson/fetal-monitory/proto/telemetry"
+)
+
+func TestRawSignals_ArchiveRoundTrip(t *testing.T) {
+	startedAt := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	// Первый сэмпл пришел через 7.5 с после старта сессии
+	t0 := startedAt.UnixMilli() + 7500
+	want := []RawSample{
+		{TsMS: t0, Metric: telemetryv1.Metric_METRIC_FHR, Value: 140},
+		{TsMS: t0, Metric: telemetryv1.Metric_METRIC_UC, Value: 12},
+		{TsMS: t0 + 250, Metric: telemetryv1.Metric_METRIC_FHR, Value: 141},
+		{TsMS: t0 + 250, Metric: telemetryv1.Metric_METRIC_UC, Value: 13},
+		{TsMS: t0 + 500, Metric: telemetryv1.Metric_METRIC_FHR, Value: 139},
+	}
+
+	a := archive.New(archive.SourceReceiver)
+	a.Session = archive.Session{ID: "session1", Status: string(SessionStatusSaved), StartedAt: startedAt}
+	a.RawSignals = archiveRawSignals(want)
+	if a.RawSignals.T0Ms != t0 || a.RawSignals.FHR.TimeSec[0] != 0 {
+		t.Fatalf("Exported raw signals must start at 0 with t0_ms=%d, got t0_ms=%d", t0, a.RawSignals.T0Ms)
+	}
+
+	var buf bytes.Buffer
+	if err := archive.Write(&buf, a); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	restored, err := archive.Read(&buf)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	got := rawSamplesFromArchive(restored)
+	if len(got) != len(want) {
+		t.Fatalf("Restored %d samples, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Sample %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRawSignals_LegacyArchiveUsesSessionStart(t *testing.T) {
+	startedAt := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	a := archive.New(archive.SourceOfflineService)
+	a.Session = archive.Session{ID: "session1", StartedAt: startedAt}
+	a.RawSignals.FHR = archive.Signal{TimeSec: []float64{0, 0.25}, Value: []float64{140, 141}}
+
+	got := rawSamplesFromArchive(a)
+	if len(got) != 2 || got[0].TsMS != startedAt.UnixMilli() || got[1].TsMS != startedAt.UnixMilli()+250 {
+		t.Errorf("Samples without t0_ms must be based on started_at, got %+v", got)
+	}
+}
@@ -1,12 +1,25 @@
 package session
 
 import (
+	"bytes"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+
+	"github.com/Krimson/fetal-monitory/archive"
+	"github.com/Krimson/fetal-monitory/auth"
+	"github.com/Krimson/fetal-monitory/logging"
+	"github.com/Krimson/fetal-monitory/receiver/internal/alert"
+	"github.com/Krimson/fetal-monitory/receiver/internal/downsample"
+	"github.com/Krimson/fetal-monitory/receiver/internal/report"
 )
 
 // HTTPHandler обрабатывает HTTP запросы для управления сессиями (Presentation Layer)
@@ -27,12 +40,28 @@ func (h *HTTPHandler) RegisterRoutes(router *mux.Router) {
 
 	api.HandleFunc("", h.CreateSession).Methods("POST", "OPTIONS")
 	api.HandleFunc("", h.ListSessions).Methods("GET", "OPTIONS")
+	api.HandleFunc("/import", h.ImportSession).Methods("POST", "OPTIONS")
 	api.HandleFunc("/{id}", h.GetSession).Methods("GET", "OPTIONS")
 	api.HandleFunc("/{id}/stop", h.StopSession).Methods("POST", "OPTIONS")
 	api.HandleFunc("/{id}/save", h.SaveSession).Methods("POST", "OPTIONS")
 	api.HandleFunc("/{id}", h.DeleteSession).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/{id}/patient", h.LinkPatient).Methods("PUT", "OPTIONS")
+	api.HandleFunc("/{id}/patient", h.UnlinkPatient).Methods("DELETE", "OPTIONS")
 	api.HandleFunc("/{id}/metrics", h.GetSessionMetrics).Methods("GET", "OPTIONS")
 	api.HandleFunc("/{id}/data", h.GetSessionData).Methods("GET", "OPTIONS")
+	api.HandleFunc("/{id}/fhr", h.GetFHR).Methods("GET", "OPTIONS")
+	api.HandleFunc("/{id}/uc", h.GetUC).Methods("GET", "OPTIONS")
+	api.HandleFunc("/{id}/events", h.GetEvents).Methods("GET", "OPTIONS")
+	api.HandleFunc("/{id}/stv", h.GetSTV).Methods("GET", "OPTIONS")
+	api.HandleFunc("/{id}/ltv", h.GetLTV).Methods("GET", "OPTIONS")
+	api.HandleFunc("/{id}/alerts", h.GetSessionAlerts).Methods("GET", "OPTIONS")
+	api.HandleFunc("/{id}/report", h.GetSessionReport).Methods("GET", "OPTIONS")
+	api.HandleFunc("/{id}/export", h.ExportSession).Methods("GET", "OPTIONS")
+
+	alerts := router.PathPrefix("/api/alerts").Subrouter()
+
+	alerts.HandleFunc("/{id}/ack", h.AcknowledgeAlert).Methods("POST", "OPTIONS")
+	alerts.HandleFunc("/{id}/resolve", h.ResolveAlert).Methods("POST", "OPTIONS")
 }
 
 // CreateSession создает новую сессию мониторинга
@@ -43,8 +72,9 @@ func (h *HTTPHandler) RegisterRoutes(router *mux.Router) {
 // @Produce json
 // @Param request body CreateSessionRequest true "Параметры сессии"
 // @Success 201 {object} SessionResponse "Сессия успешно создана"
-// @Failure 400 {object} map[string]interface{} "Неверный запрос"
+// @Failure 400 {object} map[string]interface{} "Неверный запрос или карточка patient_id не заведена"
 // @Failure 500 {object} map[string]interface{} "Ошибка сервера"
+// @Security BearerAuth
 // @Router /api/sessions [post]
 func (h *HTTPHandler) CreateSession(w http.ResponseWriter, r *http.Request) {
 	var req CreateSessionRequest
@@ -55,7 +85,11 @@ func (h *HTTPHandler) CreateSession(w http.ResponseWriter, r *http.Request) {
 
 	session, err := h.manager.CreateSession(r.Context(), &req)
 	if err != nil {
-		log.Printf("[ERROR] Failed to create session: %v", err)
+		if errors.Is(err, ErrPatientNotFound) {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		slog.Error("Failed to create session", logging.Err(err))
 		respondError(w, http.StatusInternalServerError, "Failed to create session")
 		return
 	}
@@ -65,31 +99,42 @@ func (h *HTTPHandler) CreateSession(w http.ResponseWriter, r *http.Request) {
 
 // ListSessions возвращает список сессий
 // @Summary Получить список сессий
-// @Description Возвращает список сессий с пагинацией
+// @Description Возвращает сохраненные сессии, подходящие под фильтры, с курсорной пагинацией.
+// @Description Для следующей страницы передайте next_cursor из ответа в параметр cursor с теми же фильтрами и сортировкой
 // @Tags Sessions
 // @Produce json
+// @Param status query string false "Статусы через запятую" example(SAVED,STOPPED)
+// @Param patient_id query string false "ID пациента"
+// @Param doctor_id query string false "ID врача"
+// @Param facility_id query string false "ID учреждения"
+// @Param q query string false "Поиск по подстроке ID сессии, пациента, врача и заметок"
+// @Param started_from query string false "Начало сессии не раньше (RFC3339 или YYYY-MM-DD)"
+// @Param started_to query string false "Начало сессии раньше (RFC3339 или YYYY-MM-DD)"
+// @Param prediction_min query number false "Минимальное последнее предсказание ML"
+// @Param prediction_max query number false "Максимальное последнее предсказание ML"
+// @Param sort query string false "Сортировка" Enums(-started_at, started_at) default(-started_at)
 // @Param limit query int false "Количество сессий на странице" default(50)
-// @Param offset query int false "Смещение от начала списка" default(0)
-// @Success 200 {object} map[string]interface{} "Список сессий"
+// @Param cursor query string false "Курсор следующей страницы"
+// @Success 200 {object} SessionPage "Страница сессий"
+// @Failure 400 {object} map[string]interface{} "Неверный фильтр или курсор"
 // @Failure 500 {object} map[string]interface{} "Ошибка сервера"
+// @Security BearerAuth
 // @Router /api/sessions [get]
 func (h *HTTPHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
-	limit := getQueryInt(r, "limit", 50)
-	offset := getQueryInt(r, "offset", 0)
+	filter, err := parseSessionFilter(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	sessions, err := h.manager.ListSessions(r.Context(), limit, offset)
+	page, err := h.manager.ListSessions(r.Context(), filter)
 	if err != nil {
-		log.Printf("[ERROR] Failed to list sessions: %v", err)
+		slog.Error("Failed to list sessions", logging.Err(err))
 		respondError(w, http.StatusInternalServerError, "Failed to list sessions")
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"sessions": sessions,
-		"limit":    limit,
-		"offset":   offset,
-		"count":    len(sessions),
-	})
+	respondJSON(w, http.StatusOK, page)
 }
 
 // GetSession получает информацию о сессии
@@ -100,6 +145,7 @@ func (h *HTTPHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
 // @Param id path string true "ID сессии"
 // @Success 200 {object} SessionResponse "Информация о сессии"
 // @Failure 404 {object} map[string]interface{} "Сессия не найдена"
+// @Security BearerAuth
 // @Router /api/sessions/{id} [get]
 func (h *HTTPHandler) GetSession(w http.ResponseWriter, r *http.Request) {
 	sessionID := mux.Vars(r)["id"]
@@ -127,12 +173,13 @@ func (h *HTTPHandler) GetSession(w http.ResponseWriter, r *http.Request) {
 // @Param id path string true "ID сессии"
 // @Success 200 {object} map[string]interface{} "Сессия остановлена"
 // @Failure 500 {object} map[string]interface{} "Ошибка остановки сессии"
+// @Security BearerAuth
 // @Router /api/sessions/{id}/stop [post]
 func (h *HTTPHandler) StopSession(w http.ResponseWriter, r *http.Request) {
 	sessionID := mux.Vars(r)["id"]
 
 	if err := h.manager.StopSession(r.Context(), sessionID); err != nil {
-		log.Printf("[ERROR] Failed to stop session %s: %v", sessionID, err)
+		slog.Error("Failed to stop session", logging.SessionID(sessionID), logging.Err(err))
 		respondError(w, http.StatusInternalServerError, "Failed to stop session")
 		return
 	}
@@ -153,6 +200,7 @@ func (h *HTTPHandler) StopSession(w http.ResponseWriter, r *http.Request) {
 // @Param request body SaveSessionRequest false "Дополнительные заметки"
 // @Success 200 {object} map[string]interface{} "Сессия сохранена"
 // @Failure 500 {object} map[string]interface{} "Ошибка сохранения сессии"
+// @Security BearerAuth
 // @Router /api/sessions/{id}/save [post]
 func (h *HTTPHandler) SaveSession(w http.ResponseWriter, r *http.Request) {
 	sessionID := mux.Vars(r)["id"]
@@ -164,7 +212,7 @@ func (h *HTTPHandler) SaveSession(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.manager.SaveSession(r.Context(), sessionID, req.Notes); err != nil {
-		log.Printf("[ERROR] Failed to save session %s: %v", sessionID, err)
+		slog.Error("Failed to save session", logging.SessionID(sessionID), logging.Err(err))
 		respondError(w, http.StatusInternalServerError, "Failed to save session")
 		return
 	}
@@ -183,12 +231,13 @@ func (h *HTTPHandler) SaveSession(w http.ResponseWriter, r *http.Request) {
 // @Param id path string true "ID сессии"
 // @Success 200 {object} map[string]interface{} "Сессия удалена"
 // @Failure 500 {object} map[string]interface{} "Ошибка удаления сессии"
+// @Security BearerAuth
 // @Router /api/sessions/{id} [delete]
 func (h *HTTPHandler) DeleteSession(w http.ResponseWriter, r *http.Request) {
 	sessionID := mux.Vars(r)["id"]
 
 	if err := h.manager.DeleteSession(r.Context(), sessionID); err != nil {
-		log.Printf("[ERROR] Failed to delete session %s: %v", sessionID, err)
+		slog.Error("Failed to delete session", logging.SessionID(sessionID), logging.Err(err))
 		respondError(w, http.StatusInternalServerError, "Failed to delete session")
 		return
 	}
@@ -199,14 +248,78 @@ func (h *HTTPHandler) DeleteSession(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// LinkPatient привязывает сессию к карточке пациентки
+// @Summary Привязать сессию к пациентке
+// @Description Привязывает сессию (активную, остановленную или сохраненную) к заведенной карточке пациентки, заменяя прежнюю привязку
+// @Tags Sessions
+// @Accept json
+// @Produce json
+// @Param id path string true "ID сессии"
+// @Param request body SetSessionPatientRequest true "ID карточки"
+// @Success 200 {object} SessionResponse "Сессия привязана"
+// @Failure 400 {object} map[string]interface{} "Карточка не указана или не заведена"
+// @Failure 404 {object} map[string]interface{} "Сессия не найдена"
+// @Failure 500 {object} map[string]interface{} "Ошибка сервера"
+// @Security BearerAuth
+// @Router /api/sessions/{id}/patient [put]
+func (h *HTTPHandler) LinkPatient(w http.ResponseWriter, r *http.Request) {
+	var req SetSessionPatientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.PatientID = strings.TrimSpace(req.PatientID); req.PatientID == "" {
+		respondError(w, http.StatusBadRequest, "patient_id is required")
+		return
+	}
+
+	h.setSessionPatient(w, r, req.PatientID)
+}
+
+// UnlinkPatient отвязывает сессию от карточки пациентки
+// @Summary Отвязать сессию от пациентки
+// @Description Убирает привязку сессии к карточке пациентки; данные сессии не меняются
+// @Tags Sessions
+// @Produce json
+// @Param id path string true "ID сессии"
+// @Success 200 {object} SessionResponse "Сессия отвязана"
+// @Failure 404 {object} map[string]interface{} "Сессия не найдена"
+// @Failure 500 {object} map[string]interface{} "Ошибка сервера"
+// @Security BearerAuth
+// @Router /api/sessions/{id}/patient [delete]
+func (h *HTTPHandler) UnlinkPatient(w http.ResponseWriter, r *http.Request) {
+	h.setSessionPatient(w, r, "")
+}
+
+func (h *HTTPHandler) setSessionPatient(w http.ResponseWriter, r *http.Request, patientID string) {
+	sessionID := mux.Vars(r)["id"]
+
+	session, err := h.manager.SetSessionPatient(r.Context(), sessionID, patientID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrPatientNotFound):
+			respondError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, ErrSessionNotFound):
+			respondError(w, http.StatusNotFound, "Session not found")
+		default:
+			slog.Error("Failed to set session patient", logging.SessionID(sessionID), logging.Err(err))
+			respondError(w, http.StatusInternalServerError, "Failed to set session patient")
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, SessionResponse{Session: session})
+}
+
 // GetSessionMetrics получает метрики сессии
 // @Summary Получить метрики сессии
-// @Description Возвращает агрегированные метрики сессии (STV, LTV, ЧСС, и т.д.)
+// @Description Возвращает агрегированные метрики сессии (STV, LTV, ЧСС, и т.д.) и классификацию КТГ по FIGO/NICE с обоснованием по каждому критерию
 // @Tags Sessions
 // @Produce json
 // @Param id path string true "ID сессии"
 // @Success 200 {object} SessionMetrics "Метрики сессии"
 // @Failure 404 {object} map[string]interface{} "Метрики не найдены"
+// @Security BearerAuth
 // @Router /api/sessions/{id}/metrics [get]
 func (h *HTTPHandler) GetSessionMetrics(w http.ResponseWriter, r *http.Request) {
 	sessionID := mux.Vars(r)["id"]
@@ -222,19 +335,45 @@ func (h *HTTPHandler) GetSessionMetrics(w http.ResponseWriter, r *http.Request)
 
 // GetSessionData получает все данные сессии
 // @Summary Получить все данные сессии
-// @Description Возвращает полный набор данных сессии включая метрики, события и временные ряды
+// @Description Возвращает полный набор данных сессии включая метрики, события и временные ряды.
+// @Description С параметрами from/to/limit сигналы, события и временные ряды ограничиваются диапазоном (limit - для каждого отдельно); для сохраненных сессий данные берутся из PostgreSQL.
+// @Description Если limit урезал ряд, поле next содержит from (для событий - cursor) следующей страницы в /fhr, /uc, /stv, /ltv или /events.
+// @Description С max_points сигналы FHR и UC прореживаются до заданного числа точек с сохранением формы
 // @Tags Sessions
 // @Produce json
 // @Param id path string true "ID сессии"
+// @Param from query number false "Начало диапазона, секунды от начала записи"
+// @Param to query number false "Конец диапазона, секунды от начала записи"
+// @Param limit query int false "Максимум точек в каждом ряду"
+// @Param max_points query int false "Прореживать сигналы до этого числа точек"
+// @Param method query string false "Алгоритм прореживания" Enums(lttb, minmax) default(lttb)
 // @Success 200 {object} SessionData "Полные данные сессии"
+// @Failure 400 {object} map[string]interface{} "Неверный диапазон"
 // @Failure 404 {object} map[string]interface{} "Данные сессии не найдены"
+// @Security BearerAuth
 // @Router /api/sessions/{id}/data [get]
 func (h *HTTPHandler) GetSessionData(w http.ResponseWriter, r *http.Request) {
 	sessionID := mux.Vars(r)["id"]
 
-	data, err := h.manager.GetSessionData(r.Context(), sessionID)
+	tr, err := parseTimeRange(r, 0)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ds, err := parseDownsampling(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var data *SessionData
+	if tr.IsZero() && ds.MaxPoints == 0 {
+		data, err = h.manager.GetSessionData(r.Context(), sessionID)
+	} else {
+		data, err = h.manager.GetSessionDataRange(r.Context(), sessionID, tr, ds)
+	}
 	if err != nil {
-		log.Printf("[ERROR] Failed to get session data %s: %v", sessionID, err)
+		slog.Error("Failed to get session data", logging.SessionID(sessionID), logging.Err(err))
 		respondError(w, http.StatusNotFound, "Session data not found")
 		return
 	}
@@ -242,13 +381,444 @@ func (h *HTTPHandler) GetSessionData(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, data)
 }
 
+// GetFHR получает отфильтрованный сигнал ЧСС за диапазон времени
+// @Summary Получить сигнал ЧСС
+// @Description Возвращает отфильтрованную ЧСС плода за диапазон времени. Если лимит исчерпан, next_from указывает начало следующей страницы.
+// @Description С max_points весь диапазон прореживается до заданного числа точек (LTTB или min/max по бакетам) без постраничности; для сохраненных сессий используется пирамида заранее прореженных трасс
+// @Tags Sessions
+// @Produce json
+// @Param id path string true "ID сессии"
+// @Param from query number false "Начало диапазона, секунды от начала записи"
+// @Param to query number false "Конец диапазона, секунды от начала записи"
+// @Param limit query int false "Максимум точек" default(10000)
+// @Param max_points query int false "Прореживать до этого числа точек"
+// @Param method query string false "Алгоритм прореживания" Enums(lttb, minmax) default(lttb)
+// @Success 200 {object} SignalRange "Сигнал"
+// @Failure 400 {object} map[string]interface{} "Неверный диапазон"
+// @Failure 404 {object} map[string]interface{} "Сессия не найдена"
+// @Security BearerAuth
+// @Router /api/sessions/{id}/fhr [get]
+func (h *HTTPHandler) GetFHR(w http.ResponseWriter, r *http.Request) {
+	h.getSignal(w, r, MetricTypeBPM)
+}
+
+// GetUC получает отфильтрованный сигнал маточной активности за диапазон времени
+// @Summary Получить сигнал маточной активности
+// @Description Возвращает отфильтрованную маточную активность за диапазон времени. Если лимит исчерпан, next_from указывает начало следующей страницы.
+// @Description С max_points весь диапазон прореживается до заданного числа точек (LTTB или min/max по бакетам) без постраничности; для сохраненных сессий используется пирамида заранее прореженных трасс
+// @Tags Sessions
+// @Produce json
+// @Param id path string true "ID сессии"
+// @Param from query number false "Начало диапазона, секунды от начала записи"
+// @Param to query number false "Конец диапазона, секунды от начала записи"
+// @Param limit query int false "Максимум точек" default(10000)
+// @Param max_points query int false "Прореживать до этого числа точек"
+// @Param method query string false "Алгоритм прореживания" Enums(lttb, minmax) default(lttb)
+// @Success 200 {object} SignalRange "Сигнал"
+// @Failure 400 {object} map[string]interface{} "Неверный диапазон"
+// @Failure 404 {object} map[string]interface{} "Сессия не найдена"
+// @Security BearerAuth
+// @Router /api/sessions/{id}/uc [get]
+func (h *HTTPHandler) GetUC(w http.ResponseWriter, r *http.Request) {
+	h.getSignal(w, r, MetricTypeUterus)
+}
+
+func (h *HTTPHandler) getSignal(w http.ResponseWriter, r *http.Request, metricType MetricType) {
+	sessionID := mux.Vars(r)["id"]
+
+	tr, err := parseTimeRange(r, maxRangeLimit)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ds, err := parseDownsampling(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	signal, err := h.manager.GetSignalRange(r.Context(), sessionID, metricType, tr, ds)
+	if err != nil {
+		respondRangeError(w, sessionID, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, signal)
+}
+
+// GetEvents получает события сессии за диапазон времени
+// @Summary Получить события сессии
+// @Description Возвращает акселерации, децелерации и схватки, начинающиеся в диапазоне времени, по возрастанию начала.
+// @Description Если лимит исчерпан, next_cursor нужно передать в cursor с теми же from/to/type для следующей страницы
+// @Tags Sessions
+// @Produce json
+// @Param id path string true "ID сессии"
+// @Param type query string false "Тип события" Enums(acceleration, deceleration, contraction)
+// @Param from query number false "Начало диапазона, секунды от начала записи"
+// @Param to query number false "Конец диапазона, секунды от начала записи"
+// @Param limit query int false "Максимум событий" default(10000)
+// @Param cursor query string false "next_cursor предыдущей страницы"
+// @Success 200 {object} EventsRange "События"
+// @Failure 400 {object} map[string]interface{} "Неверный диапазон или тип"
+// @Failure 404 {object} map[string]interface{} "Сессия не найдена"
+// @Security BearerAuth
+// @Router /api/sessions/{id}/events [get]
+func (h *HTTPHandler) GetEvents(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+
+	tr, err := parseTimeRange(r, maxRangeLimit)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var after *EventCursor
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		if after, err = DecodeEventCursor(cursor); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	eventType := EventType(r.URL.Query().Get("type"))
+	switch eventType {
+	case "", EventTypeAcceleration, EventTypeDeceleration, EventTypeContraction:
+	default:
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Unknown event type: %s", eventType))
+		return
+	}
+
+	events, err := h.manager.GetEventsRange(r.Context(), sessionID, eventType, tr, after)
+	if err != nil {
+		respondRangeError(w, sessionID, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, events)
+}
+
+// GetSTV получает окна STV за диапазон времени
+// @Summary Получить ряд STV
+// @Description Возвращает окна кратковременной вариабельности, начинающиеся в диапазоне времени
+// @Tags Sessions
+// @Produce json
+// @Param id path string true "ID сессии"
+// @Param from query number false "Начало диапазона, секунды от начала записи"
+// @Param to query number false "Конец диапазона, секунды от начала записи"
+// @Param limit query int false "Максимум окон" default(10000)
+// @Success 200 {object} TimeSeriesRange "Ряд STV"
+// @Failure 400 {object} map[string]interface{} "Неверный диапазон"
+// @Failure 404 {object} map[string]interface{} "Сессия не найдена"
+// @Security BearerAuth
+// @Router /api/sessions/{id}/stv [get]
+func (h *HTTPHandler) GetSTV(w http.ResponseWriter, r *http.Request) {
+	h.getTimeSeries(w, r, TimeSeriesTypeSTV)
+}
+
+// GetLTV получает окна LTV за диапазон времени
+// @Summary Получить ряд LTV
+// @Description Возвращает окна долговременной вариабельности, начинающиеся в диапазоне времени
+// @Tags Sessions
+// @Produce json
+// @Param id path string true "ID сессии"
+// @Param from query number false "Начало диапазона, секунды от начала записи"
+// @Param to query number false "Конец диапазона, секунды от начала записи"
+// @Param limit query int false "Максимум окон" default(10000)
+// @Success 200 {object} TimeSeriesRange "Ряд LTV"
+// @Failure 400 {object} map[string]interface{} "Неверный диапазон"
+// @Failure 404 {object} map[string]interface{} "Сессия не найдена"
+// @Security BearerAuth
+// @Router /api/sessions/{id}/ltv [get]
+func (h *HTTPHandler) GetLTV(w http.ResponseWriter, r *http.Request) {
+	h.getTimeSeries(w, r, TimeSeriesTypeLTV)
+}
+
+func (h *HTTPHandler) getTimeSeries(w http.ResponseWriter, r *http.Request, seriesType TimeSeriesType) {
+	sessionID := mux.Vars(r)["id"]
+
+	tr, err := parseTimeRange(r, maxRangeLimit)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	series, err := h.manager.GetTimeSeriesRange(r.Context(), sessionID, seriesType, tr)
+	if err != nil {
+		respondRangeError(w, sessionID, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, series)
+}
+
+// GetSessionAlerts получает тревоги сессии
+// @Summary Получить тревоги сессии
+// @Description Возвращает клинические тревоги сессии с историей подтверждений и закрытия
+// @Tags Alerts
+// @Produce json
+// @Param id path string true "ID сессии"
+// @Success 200 {object} AlertsResponse "Тревоги сессии"
+// @Failure 500 {object} map[string]interface{} "Ошибка сервера"
+// @Security BearerAuth
+// @Router /api/sessions/{id}/alerts [get]
+func (h *HTTPHandler) GetSessionAlerts(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+
+	alerts, err := h.manager.GetSessionAlerts(r.Context(), sessionID)
+	if err != nil {
+		slog.Error("Failed to get alerts", logging.SessionID(sessionID), logging.Err(err))
+		respondError(w, http.StatusInternalServerError, "Failed to get alerts")
+		return
+	}
+	if alerts == nil {
+		alerts = []*alert.Alert{}
+	}
+
+	respondJSON(w, http.StatusOK, AlertsResponse{
+		SessionID: sessionID,
+		Alerts:    alerts,
+		Count:     len(alerts),
+	})
+}
+
+// GetSessionReport формирует отчет КТГ по сохраненной сессии
+// @Summary Получить отчет КТГ
+// @Description Формирует самодостаточный отчет по сохраненной сессии: трассы ЧСС и маточной активности с отмеченными событиями, кривые STV/LTV, сводные метрики, предсказание, классификацию FIGO/NICE, тревоги и данные пациента. Формат html (по умолчанию) или pdf
+// @Tags Sessions
+// @Produce html
+// @Produce application/pdf
+// @Param id path string true "ID сессии"
+// @Param format query string false "Формат отчета" Enums(html, pdf) default(html)
+// @Success 200 {file} file "Отчет"
+// @Failure 400 {object} map[string]interface{} "Неизвестный формат"
+// @Failure 404 {object} map[string]interface{} "Сессия не сохранена"
+// @Failure 500 {object} map[string]interface{} "Ошибка формирования отчета"
+// @Security BearerAuth
+// @Router /api/sessions/{id}/report [get]
+func (h *HTTPHandler) GetSessionReport(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = report.FormatHTML
+	}
+	if format != report.FormatHTML && format != report.FormatPDF {
+		respondError(w, http.StatusBadRequest, "Unsupported report format")
+		return
+	}
+
+	data, err := h.manager.BuildReport(r.Context(), sessionID)
+	if err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			respondError(w, http.StatusNotFound, "Session not saved")
+			return
+		}
+		slog.Error("Failed to build report", logging.SessionID(sessionID), logging.Err(err))
+		respondError(w, http.StatusInternalServerError, "Failed to build report")
+		return
+	}
+
+	// Рендерим в буфер, чтобы при ошибке вернуть JSON, а не половину документа
+	var buf bytes.Buffer
+	if format == report.FormatPDF {
+		err = report.RenderPDF(&buf, data)
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=ctg-report-%s.pdf", sessionID))
+	} else {
+		err = report.RenderHTML(&buf, data)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+	if err != nil {
+		w.Header().Del("Content-Disposition")
+		slog.Error("Failed to render report", "format", format, logging.SessionID(sessionID), logging.Err(err))
+		respondError(w, http.StatusInternalServerError, "Failed to render report")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if _, err := buf.WriteTo(w); err != nil {
+		slog.Error("Failed to write report", logging.SessionID(sessionID), logging.Err(err))
+	}
+}
+
+// maxImportSize - ограничение размера загружаемого архива
+const maxImportSize = 256 << 20
+
+// ExportSession выгружает сохраненную сессию в переносимый архив
+// @Summary Экспортировать сессию
+// @Description Выгружает сохраненную сессию в версионированный архив (gzip JSON): метаданные, сигналы, события, временные ряды, метрики и предсказания. Архив можно загрузить в другую установку или в offline-service
+// @Tags Sessions
+// @Produce application/gzip
+// @Param id path string true "ID сессии"
+// @Success 200 {file} file "Архив сессии"
+// @Failure 404 {object} map[string]interface{} "Сессия не сохранена"
+// @Failure 500 {object} map[string]interface{} "Ошибка экспорта"
+// @Security BearerAuth
+// @Router /api/sessions/{id}/export [get]
+func (h *HTTPHandler) ExportSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+
+	a, err := h.manager.ExportSession(r.Context(), sessionID)
+	if err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			respondError(w, http.StatusNotFound, "Session not saved")
+			return
+		}
+		slog.Error("Failed to export session", logging.SessionID(sessionID), logging.Err(err))
+		respondError(w, http.StatusInternalServerError, "Failed to export session")
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := archive.Write(&buf, a); err != nil {
+		slog.Error("Failed to write archive", logging.SessionID(sessionID), logging.Err(err))
+		respondError(w, http.StatusInternalServerError, "Failed to export session")
+		return
+	}
+
+	w.Header().Set("Content-Type", archive.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=session-%s%s", sessionID, archive.FileExtension))
+	w.WriteHeader(http.StatusOK)
+	if _, err := buf.WriteTo(w); err != nil {
+		slog.Error("Failed to write archive", logging.SessionID(sessionID), logging.Err(err))
+	}
+}
+
+// ImportSession загружает сессию из архива
+// @Summary Импортировать сессию
+// @Description Загружает архив сессии (gzip или несжатый JSON), выгруженный receiver или offline-service, и сохраняет его в PostgreSQL со статусом SAVED и исходным ID
+// @Tags Sessions
+// @Accept application/gzip
+// @Produce json
+// @Param archive body archive.Archive true "Архив сессии"
+// @Success 201 {object} SessionResponse "Сессия импортирована"
+// @Failure 400 {object} map[string]interface{} "Неверный или неподдерживаемый архив"
+// @Failure 409 {object} map[string]interface{} "Сессия с таким ID уже существует"
+// @Failure 500 {object} map[string]interface{} "Ошибка импорта"
+// @Security BearerAuth
+// @Router /api/sessions/import [post]
+func (h *HTTPHandler) ImportSession(w http.ResponseWriter, r *http.Request) {
+	a, err := archive.Read(http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	session, err := h.manager.ImportSession(r.Context(), a)
+	if err != nil {
+		if errors.Is(err, ErrSessionExists) {
+			respondError(w, http.StatusConflict, "Session already exists")
+			return
+		}
+		slog.Error("Failed to import session", logging.SessionID(a.Session.ID), logging.Err(err))
+		respondError(w, http.StatusInternalServerError, "Failed to import session")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, SessionResponse{Session: session})
+}
+
+// AcknowledgeAlert подтверждает тревогу
+// @Summary Подтвердить тревогу
+// @Description Отмечает, что врач увидел тревогу. Подтвердить можно только активную тревогу
+// @Tags Alerts
+// @Accept json
+// @Produce json
+// @Param id path string true "ID тревоги"
+// @Param request body AlertActionRequest true "Пользователь и комментарий"
+// @Success 200 {object} alert.Alert "Тревога подтверждена"
+// @Failure 400 {object} map[string]interface{} "Неверный запрос"
+// @Failure 404 {object} map[string]interface{} "Тревога не найдена"
+// @Failure 409 {object} map[string]interface{} "Тревога уже подтверждена или закрыта"
+// @Security BearerAuth
+// @Router /api/alerts/{id}/ack [post]
+func (h *HTTPHandler) AcknowledgeAlert(w http.ResponseWriter, r *http.Request) {
+	alertID := mux.Vars(r)["id"]
+
+	req, ok := decodeAlertAction(w, r)
+	if !ok {
+		return
+	}
+
+	a, err := h.manager.AcknowledgeAlert(r.Context(), alertID, req.User, req.Comment)
+	if err != nil {
+		respondAlertError(w, alertID, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, a)
+}
+
+// ResolveAlert закрывает тревогу
+// @Summary Закрыть тревогу
+// @Description Закрывает тревогу вручную. Пока условие тревоги сохраняется, повторная тревога того же типа не поднимается
+// @Tags Alerts
+// @Accept json
+// @Produce json
+// @Param id path string true "ID тревоги"
+// @Param request body AlertActionRequest true "Пользователь и комментарий"
+// @Success 200 {object} alert.Alert "Тревога закрыта"
+// @Failure 400 {object} map[string]interface{} "Неверный запрос"
+// @Failure 404 {object} map[string]interface{} "Тревога не найдена"
+// @Failure 409 {object} map[string]interface{} "Тревога уже закрыта"
+// @Security BearerAuth
+// @Router /api/alerts/{id}/resolve [post]
+func (h *HTTPHandler) ResolveAlert(w http.ResponseWriter, r *http.Request) {
+	alertID := mux.Vars(r)["id"]
+
+	req, ok := decodeAlertAction(w, r)
+	if !ok {
+		return
+	}
+
+	a, err := h.manager.ResolveAlert(r.Context(), alertID, req.User, req.Comment)
+	if err != nil {
+		respondAlertError(w, alertID, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, a)
+}
+
 // ===== Утилиты =====
 
+// decodeAlertAction читает тело запроса ack/resolve; пользователь обязателен для журнала.
+// При включенной аутентификации пользователь берется из токена, а не из тела запроса
+func decodeAlertAction(w http.ResponseWriter, r *http.Request) (*AlertActionRequest, bool) {
+	var req AlertActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return nil, false
+	}
+	if claims := auth.FromContext(r.Context()); claims != nil {
+		req.User = claims.Subject
+	}
+	if req.User == "" {
+		respondError(w, http.StatusBadRequest, "User is required")
+		return nil, false
+	}
+	return &req, true
+}
+
+func respondAlertError(w http.ResponseWriter, alertID string, err error) {
+	switch {
+	case errors.Is(err, alert.ErrNotFound):
+		respondError(w, http.StatusNotFound, "Alert not found")
+	case errors.Is(err, alert.ErrInvalidTransition):
+		respondError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrAlertsDisabled):
+		respondError(w, http.StatusServiceUnavailable, "Alerts are disabled")
+	default:
+		slog.Error("Failed to update alert", "alert_id", alertID, logging.Err(err))
+		respondError(w, http.StatusInternalServerError, "Failed to update alert")
+	}
+}
+
 func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(data); err != nil {
-		log.Printf("[ERROR] Failed to encode JSON response: %v", err)
+		slog.Error("Failed to encode JSON response", logging.Err(err))
 	}
 }
 
@@ -259,6 +829,145 @@ func respondError(w http.ResponseWriter, status int, message string) {
 	})
 }
 
+// maxRangeLimit - максимум элементов в ответе выборки по диапазону
+const maxRangeLimit = 10000
+
+func respondRangeError(w http.ResponseWriter, sessionID string, err error) {
+	if errors.Is(err, ErrSessionNotFound) {
+		respondError(w, http.StatusNotFound, "Session not found")
+		return
+	}
+	slog.Error("Failed to get range data", logging.SessionID(sessionID), logging.Err(err))
+	respondError(w, http.StatusInternalServerError, "Failed to get session data")
+}
+
+// maxSessionListLimit - наибольший размер страницы списка сессий
+const maxSessionListLimit = 500
+
+// parseTimeRange читает from/to/limit из запроса. defaultLimit используется,
+// если limit не задан; limit больше maxRangeLimit урезается
+func parseTimeRange(r *http.Request, defaultLimit int) (TimeRange, error) {
+	query := r.URL.Query()
+	tr := TimeRange{Limit: defaultLimit}
+
+	for key, dst := range map[string]**float64{"from": &tr.From, "to": &tr.To} {
+		valueStr := query.Get(key)
+		if valueStr == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(valueStr, 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			return tr, fmt.Errorf("invalid %s: %s", key, valueStr)
+		}
+		*dst = &value
+	}
+	if tr.From != nil && tr.To != nil && *tr.From > *tr.To {
+		return tr, fmt.Errorf("from must not exceed to")
+	}
+
+	if valueStr := query.Get("limit"); valueStr != "" {
+		limit, err := strconv.Atoi(valueStr)
+		if err != nil || limit <= 0 {
+			return tr, fmt.Errorf("invalid limit: %s", valueStr)
+		}
+		tr.Limit = limit
+	}
+	if tr.Limit > maxRangeLimit {
+		tr.Limit = maxRangeLimit
+	}
+
+	return tr, nil
+}
+
+// parseSessionFilter разбирает фильтры, сортировку и курсор списка сессий
+func parseSessionFilter(r *http.Request) (SessionFilter, error) {
+	query := r.URL.Query()
+	filter := SessionFilter{
+		PatientID:  query.Get("patient_id"),
+		DoctorID:   query.Get("doctor_id"),
+		FacilityID: query.Get("facility_id"),
+		Query:      strings.TrimSpace(query.Get("q")),
+		Limit:      getQueryInt(r, "limit", defaultSessionListLimit),
+	}
+	if filter.Limit <= 0 || filter.Limit > maxSessionListLimit {
+		return filter, fmt.Errorf("limit must be between 1 and %d", maxSessionListLimit)
+	}
+
+	if statuses := query.Get("status"); statuses != "" {
+		for _, status := range strings.Split(statuses, ",") {
+			switch s := SessionStatus(strings.ToUpper(strings.TrimSpace(status))); s {
+			case SessionStatusActive, SessionStatusStopped, SessionStatusSaved:
+				filter.Statuses = append(filter.Statuses, s)
+			default:
+				return filter, fmt.Errorf("unknown status: %s", status)
+			}
+		}
+	}
+
+	for key, dst := range map[string]**time.Time{"started_from": &filter.StartedFrom, "started_to": &filter.StartedTo} {
+		valueStr := query.Get(key)
+		if valueStr == "" {
+			continue
+		}
+		value, err := time.Parse(time.RFC3339, valueStr)
+		if err != nil {
+			if value, err = time.Parse(time.DateOnly, valueStr); err != nil {
+				return filter, fmt.Errorf("invalid %s: %s", key, valueStr)
+			}
+		}
+		*dst = &value
+	}
+
+	for key, dst := range map[string]**float64{"prediction_min": &filter.PredictionMin, "prediction_max": &filter.PredictionMax} {
+		valueStr := query.Get(key)
+		if valueStr == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(valueStr, 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			return filter, fmt.Errorf("invalid %s: %s", key, valueStr)
+		}
+		*dst = &value
+	}
+
+	sort, err := ParseSessionSort(query.Get("sort"))
+	if err != nil {
+		return filter, err
+	}
+	filter.Sort = sort
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		if filter.After, err = DecodeSessionCursor(cursor, sort); err != nil {
+			return filter, err
+		}
+	}
+
+	return filter, nil
+}
+
+// parseDownsampling разбирает параметры max_points и method; без max_points
+// прореживание не выполняется
+func parseDownsampling(r *http.Request) (Downsampling, error) {
+	query := r.URL.Query()
+	var ds Downsampling
+
+	method, err := downsample.ParseMethod(query.Get("method"))
+	if err != nil {
+		return ds, err
+	}
+	ds.Method = method
+
+	if valueStr := query.Get("max_points"); valueStr != "" {
+		maxPoints, err := strconv.Atoi(valueStr)
+		if err != nil || maxPoints < 2 {
+			return ds, fmt.Errorf("invalid max_points: %s", valueStr)
+		}
+		ds.MaxPoints = min(maxPoints, maxRangeLimit)
+	}
+
+	return ds, nil
+}
+
 func getQueryInt(r *http.Request, key string, defaultValue int) int {
 	valueStr := r.URL.Query().Get(key)
 	if valueStr == "" {
@@ -0,0 +1,79 @@
+package session
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseSessionFilter(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/sessions?status=saved,%20STOPPED&doctor_id=doc-7&q=%20ivanova%20"+
+		"&started_from=2026-03-01&started_to=2026-03-02T12:00:00Z&prediction_min=0.7&sort=started_at&limit=20", nil)
+
+	filter, err := parseSessionFilter(r)
+	if err != nil {
+		t.Fatalf("parseSessionFilter failed: %v", err)
+	}
+	if len(filter.Statuses) != 2 || filter.Statuses[0] != SessionStatusSaved || filter.Statuses[1] != SessionStatusStopped {
+		t.Errorf("Statuses = %v", filter.Statuses)
+	}
+	if filter.DoctorID != "doc-7" || filter.Query != "ivanova" || filter.Limit != 20 || filter.Sort != SessionSortStartedAsc {
+		t.Errorf("Unexpected filter: %+v", filter)
+	}
+	if filter.StartedFrom == nil || !filter.StartedFrom.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("StartedFrom = %v", filter.StartedFrom)
+	}
+	if filter.StartedTo == nil || !filter.StartedTo.Equal(time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("StartedTo = %v", filter.StartedTo)
+	}
+	if filter.PredictionMin == nil || *filter.PredictionMin != 0.7 || filter.PredictionMax != nil {
+		t.Errorf("Prediction bounds = %v, %v", filter.PredictionMin, filter.PredictionMax)
+	}
+}
+
+func TestParseSessionFilter_Defaults(t *testing.T) {
+	filter, err := parseSessionFilter(httptest.NewRequest("GET", "/api/sessions", nil))
+	if err != nil {
+		t.Fatalf("parseSessionFilter failed: %v", err)
+	}
+	if filter.Limit != defaultSessionListLimit || filter.Sort != SessionSortStartedDesc || filter.After != nil {
+		t.Errorf("Unexpected defaults: %+v", filter)
+	}
+}
+
+func TestParseSessionFilter_Cursor(t *testing.T) {
+	cursor := SessionCursor{StartedAt: time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC), ID: "s2", Sort: SessionSortStartedAsc}.Encode()
+
+	filter, err := parseSessionFilter(httptest.NewRequest("GET", "/api/sessions?sort=started_at&cursor="+cursor, nil))
+	if err != nil {
+		t.Fatalf("parseSessionFilter failed: %v", err)
+	}
+	if filter.After == nil || filter.After.ID != "s2" {
+		t.Errorf("After = %+v", filter.After)
+	}
+
+	// Курсор, выданный для другой сортировки, недействителен
+	_, err = parseSessionFilter(httptest.NewRequest("GET", "/api/sessions?cursor="+cursor, nil))
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestParseSessionFilter_Invalid(t *testing.T) {
+	for _, query := range []string{
+		"limit=0",
+		"limit=501",
+		"status=RUNNING",
+		"started_from=yesterday",
+		"started_to=2026-13-01",
+		"prediction_min=abc",
+		"prediction_max=NaN",
+		"sort=stopped_at",
+		"cursor=garbage!",
+	} {
+		if _, err := parseSessionFilter(httptest.NewRequest("GET", "/api/sessions?"+query, nil)); err == nil {
+			t.Errorf("Expected error for %q", query)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package session
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidCursor возвращается, если курсор списка сессий поврежден или
+// получен для другой сортировки
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// SessionSort - порядок списка сессий
+type SessionSort string
+
+const (
+	SessionSortStartedDesc SessionSort = "-started_at" // Сначала новые (по умолчанию)
+	SessionSortStartedAsc  SessionSort = "started_at"
+)
+
+// ParseSessionSort разбирает порядок сортировки; пустое значение - сначала новые
+func ParseSessionSort(s string) (SessionSort, error) {
+	switch SessionSort(s) {
+	case "", SessionSortStartedDesc:
+		return SessionSortStartedDesc, nil
+	case SessionSortStartedAsc:
+		return SessionSortStartedAsc, nil
+	default:
+		return "", fmt.Errorf("unknown sort: %s", s)
+	}
+}
+
+// defaultSessionListLimit - размер страницы списка сессий по умолчанию
+const defaultSessionListLimit = 50
+
+// SessionFilter - условия выборки списка сессий. Пустые поля не ограничивают выборку
+type SessionFilter struct {
+	Statuses      []SessionStatus
+	PatientID     string
+	DoctorID      string
+	FacilityID    string
+	Query         string     // Подстрока ID сессии, пациента, врача или заметок
+	StartedFrom   *time.Time // Включительно
+	StartedTo     *time.Time // Не включительно
+	PredictionMin *float64   // Последнее предсказание ML, включительно
+	PredictionMax *float64
+	Sort          SessionSort
+	After         *SessionCursor // Позиция, после которой начинается страница
+	Limit         int
+}
+
+// SessionCursor - позиция в списке сессий: начало и ID последней сессии страницы
+type SessionCursor struct {
+	StartedAt time.Time   `json:"t"`
+	ID        string      `json:"id"`
+	Sort      SessionSort `json:"s"`
+}
+
+// Encode кодирует курсор в непрозрачную строку для клиента
+func (c SessionCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeSessionCursor разбирает курсор, выданный для сортировки sort
+func DecodeSessionCursor(s string, sort SessionSort) (*SessionCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c SessionCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" || c.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// SessionPage - страница списка сессий
+type SessionPage struct {
+	Sessions   []*Session `json:"sessions"`
+	Count      int        `json:"count"`
+	Limit      int        `json:"limit"`
+	NextCursor string     `json:"next_cursor,omitempty"` // Пусто на последней странице
+}
+
+// ListSessions возвращает страницу сохраненных сессий, подходящих под фильтр
+func (m *Manager) ListSessions(ctx context.Context, filter SessionFilter) (*SessionPage, error) {
+	if filter.Sort == "" {
+		filter.Sort = SessionSortStartedDesc
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultSessionListLimit
+	}
+
+	// На одну больше лимита, чтобы понять, есть ли следующая страница
+	fetch := filter
+	fetch.Limit = filter.Limit + 1
+	sessions, err := m.repository.ListSessions(ctx, fetch)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &SessionPage{Sessions: sessions, Limit: filter.Limit}
+	if len(sessions) > filter.Limit {
+		last := sessions[filter.Limit-1]
+		page.Sessions = sessions[:filter.Limit]
+		page.NextCursor = SessionCursor{StartedAt: last.StartedAt, ID: last.ID, Sort: filter.Sort}.Encode()
+	}
+	if page.Sessions == nil {
+		page.Sessions = []*Session{}
+	}
+	page.Count = len(page.Sessions)
+	return page, nil
+}
@@ -0,0 +1,142 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var sessionListColumns = []string{"id", "status", "started_at", "stopped_at", "saved_at", "total_duration_ms", "total_data_points", "metadata"}
+
+func TestParseSessionSort(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    SessionSort
+		wantErr bool
+	}{
+		{in: "", want: SessionSortStartedDesc},
+		{in: "-started_at", want: SessionSortStartedDesc},
+		{in: "started_at", want: SessionSortStartedAsc},
+		{in: "stopped_at", wantErr: true},
+		{in: "STARTED_AT", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSessionSort(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSessionSort(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSessionSort(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSessionCursor_EncodeDecode(t *testing.T) {
+	want := SessionCursor{
+		StartedAt: time.Date(2026, 3, 1, 10, 0, 0, 123000000, time.UTC),
+		ID:        "session1",
+		Sort:      SessionSortStartedAsc,
+	}
+
+	got, err := DecodeSessionCursor(want.Encode(), SessionSortStartedAsc)
+	if err != nil {
+		t.Fatalf("DecodeSessionCursor failed: %v", err)
+	}
+	if !got.StartedAt.Equal(want.StartedAt) || got.ID != want.ID || got.Sort != want.Sort {
+		t.Errorf("Decoded %+v, want %+v", *got, want)
+	}
+
+	// Курсор другой сортировки, пустой ID и мусор отклоняются
+	if _, err := DecodeSessionCursor(want.Encode(), SessionSortStartedDesc); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Cursor for another sort: got %v, want ErrInvalidCursor", err)
+	}
+	for _, bad := range []string{"not base64!", "e30", "bnVsbA"} {
+		if _, err := DecodeSessionCursor(bad, SessionSortStartedDesc); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("DecodeSessionCursor(%q) = %v, want ErrInvalidCursor", bad, err)
+		}
+	}
+}
+
+func TestListSessions_NextPage(t *testing.T) {
+	repo, mock := newMockRepository(t)
+	manager := NewManager(nil, repo)
+	ctx := context.Background()
+
+	started := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	row := func(rows *sqlmock.Rows, id string, offset time.Duration) *sqlmock.Rows {
+		return rows.AddRow(id, SessionStatusSaved, started.Add(offset), nil, nil, int64(0), int64(0), []byte(`{}`))
+	}
+
+	// Запрашивается на одну сессию больше страницы, лишняя только говорит о следующей странице
+	rows := sqlmock.NewRows(sessionListColumns)
+	row(rows, "s3", 3*time.Hour)
+	row(rows, "s2", 2*time.Hour)
+	row(rows, "s1", time.Hour)
+	mock.ExpectQuery("FROM sessions s .*ORDER BY s.started_at DESC, s.id DESC").
+		WithArgs(3).
+		WillReturnRows(rows)
+
+	page, err := manager.ListSessions(ctx, SessionFilter{Limit: 2})
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	if page.Count != 2 || page.Sessions[1].ID != "s2" || page.NextCursor == "" {
+		t.Fatalf("Expected 2 sessions ending at s2 with next cursor, got %d sessions, cursor %q", page.Count, page.NextCursor)
+	}
+
+	// Следующая страница начинается после последней сессии предыдущей
+	after, err := DecodeSessionCursor(page.NextCursor, SessionSortStartedDesc)
+	if err != nil {
+		t.Fatalf("Next cursor is invalid: %v", err)
+	}
+	rows = sqlmock.NewRows(sessionListColumns)
+	row(rows, "s1", time.Hour)
+	mock.ExpectQuery("\\(s.started_at, s.id\\) < \\(\\$1, \\$2\\)").
+		WithArgs(started.Add(2*time.Hour), "s2", 3).
+		WillReturnRows(rows)
+
+	page, err = manager.ListSessions(ctx, SessionFilter{Limit: 2, After: after})
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	if page.Count != 1 || page.NextCursor != "" {
+		t.Errorf("Expected last page with 1 session and no cursor, got %d sessions, cursor %q", page.Count, page.NextCursor)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestListSessions_EmptyPage(t *testing.T) {
+	repo, mock := newMockRepository(t)
+
+	mock.ExpectQuery("FROM sessions s").WillReturnRows(sqlmock.NewRows(sessionListColumns))
+
+	page, err := NewManager(nil, repo).ListSessions(context.Background(), SessionFilter{})
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	if page.Sessions == nil || page.Count != 0 || page.Limit != defaultSessionListLimit || page.NextCursor != "" {
+		t.Errorf("Unexpected empty page: %+v", page)
+	}
+}
+
+func TestPostgresListSessions_EscapesQuery(t *testing.T) {
+	repo, mock := newMockRepository(t)
+
+	mock.ExpectQuery("s.id ILIKE \\$1 OR s.metadata->>'patient_id' ILIKE \\$1").
+		WithArgs(`%50\%\_x%`, 10).
+		WillReturnRows(sqlmock.NewRows(sessionListColumns))
+
+	if _, err := repo.ListSessions(context.Background(), SessionFilter{Query: "50%_x", Limit: 10}); err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
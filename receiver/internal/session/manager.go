@@ -2,19 +2,43 @@ package session
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
 	"time"
 
+	"github.com/Krimson/fetal-monitory/logging"
 	featureextractorv1 "github.com/Krimson/fetal-monitory/proto/feature_extractor"
+	"github.com/Krimson/fetal-monitory/receiver/internal/alert"
+	"github.com/Krimson/fetal-monitory/receiver/internal/ctg"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer - спаны обработки признаков (продолжают трассу батча)
+var tracer = otel.Tracer("github.com/Krimson/fetal-monitory/receiver/internal/session")
+
+var (
+	// ErrAlertsDisabled возвращается, если движок тревог не подключен
+	ErrAlertsDisabled = errors.New("alerts are disabled")
+
+	// ErrSessionNotFound возвращается, если сессии нет в PostgreSQL
+	ErrSessionNotFound = errors.New("session not found")
+
+	// ErrPatientNotFound возвращается, если сессию привязывают к незаведенной карточке
+	ErrPatientNotFound = errors.New("patient not found")
 )
 
 // Manager управляет сессиями мониторинга (Application Layer)
 type Manager struct {
 	cache      CacheStore
 	repository Repository
+	alerts     AlertService  // nil, если тревоги отключены
+	dataTTL    time.Duration // TTL данных сессии в Redis после остановки (0 - без TTL)
 
 	mu             sync.RWMutex
 	activeSessions map[string]*Session // Кэш активных сессий в памяти
@@ -29,8 +53,33 @@ func NewManager(cache CacheStore, repository Repository) *Manager {
 	}
 }
 
+// SetAlertService подключает движок клинических тревог
+func (m *Manager) SetAlertService(alerts AlertService) {
+	m.alerts = alerts
+}
+
+// SetDataTTL задает, сколько данные остановленной или сохраненной сессии
+// хранятся в Redis (SESSION_DATA_TTL_SECONDS)
+func (m *Manager) SetDataTTL(ttl time.Duration) {
+	m.dataTTL = ttl
+}
+
+// expireCache задает TTL ключам сессии в Redis (вместе с индексом ее тревог)
+func (m *Manager) expireCache(ctx context.Context, sessionID string) {
+	if m.dataTTL <= 0 {
+		return
+	}
+	if err := m.cache.SetSessionTTL(ctx, sessionID, int(m.dataTTL.Seconds())); err != nil {
+		slog.Warn("Failed to set session cache TTL", logging.SessionID(sessionID), logging.Err(err))
+	}
+}
+
 // CreateSession создает новую сессию
 func (m *Manager) CreateSession(ctx context.Context, req *CreateSessionRequest) (*Session, error) {
+	if err := m.checkPatient(ctx, req.PatientID); err != nil {
+		return nil, err
+	}
+
 	sessionID := uuid.New().String()
 
 	session := &Session{
@@ -57,7 +106,7 @@ func (m *Manager) CreateSession(ctx context.Context, req *CreateSessionRequest)
 	m.activeSessions[sessionID] = session
 	m.mu.Unlock()
 
-	log.Printf("[SESSION] Created new session: %s", sessionID)
+	slog.Info("Created new session", logging.SessionID(sessionID))
 	return session, nil
 }
 
@@ -107,7 +156,10 @@ func (m *Manager) StopSession(ctx context.Context, sessionID string) error {
 	delete(m.activeSessions, sessionID)
 	m.mu.Unlock()
 
-	log.Printf("[SESSION] Stopped session: %s, duration: %dms", sessionID, session.TotalDurationMs)
+	m.expireCache(ctx, sessionID)
+	m.forgetAlerts(sessionID)
+
+	slog.Info("Stopped session", logging.SessionID(sessionID), "duration_ms", session.TotalDurationMs)
 	return nil
 }
 
@@ -124,6 +176,8 @@ func (m *Manager) SaveSession(ctx context.Context, sessionID string, notes strin
 		sessionData.Session.Metadata.Notes = notes
 	}
 
+	sessionData.Alerts = m.liveAlerts(ctx, sessionID)
+
 	now := time.Now()
 	sessionData.Session.Status = SessionStatusSaved
 	sessionData.Session.SavedAt = &now
@@ -133,23 +187,69 @@ func (m *Manager) SaveSession(ctx context.Context, sessionID string, notes strin
 		return fmt.Errorf("failed to save session to database: %w", err)
 	}
 
-	// Обновляем статус в Redis
+	// Обновляем статус в Redis; SET сбрасывает TTL ключа, поэтому задаем его заново
 	if err := m.cache.SetSession(ctx, sessionData.Session); err != nil {
-		log.Printf("[WARN] Failed to update session status in cache: %v", err)
+		slog.Warn("Failed to update session status in cache", logging.Err(err))
 	}
+	m.expireCache(ctx, sessionID)
+	m.forgetAlerts(sessionID)
 
-	// Опционально: удаляем из Redis (можно оставить с TTL)
-	// if err := m.cache.DeleteSession(ctx, sessionID); err != nil {
-	// 	log.Printf("[WARN] Failed to delete session from cache: %v", err)
-	// }
-
-	log.Printf("[SESSION] Saved session to database: %s", sessionID)
+	slog.Info("Saved session to database", logging.SessionID(sessionID))
 	return nil
 }
 
-// ListSessions возвращает список сессий
-func (m *Manager) ListSessions(ctx context.Context, limit, offset int) ([]*Session, error) {
-	return m.repository.ListSessions(ctx, limit, offset)
+// SetSessionPatient привязывает сессию к карточке пациентки; пустой patientID
+// отвязывает ее. Меняются и метаданные сессии в Redis, чтобы последующее
+// сохранение не вернуло прежнюю привязку
+func (m *Manager) SetSessionPatient(ctx context.Context, sessionID, patientID string) (*Session, error) {
+	if err := m.checkPatient(ctx, patientID); err != nil {
+		return nil, err
+	}
+
+	session, err := m.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	session.Metadata.PatientID = patientID
+
+	// Сохраненная сессия остается в Redis до истечения TTL
+	inCache := session.Status != SessionStatusSaved
+	if !inCache {
+		_, err := m.cache.GetSession(ctx, sessionID)
+		inCache = err == nil
+	}
+	if inCache {
+		if err := m.cache.SetSession(ctx, session); err != nil {
+			return nil, fmt.Errorf("failed to update session in cache: %w", err)
+		}
+		if session.Status != SessionStatusActive {
+			m.expireCache(ctx, sessionID)
+		}
+	}
+
+	if session.Status == SessionStatusSaved {
+		if err := m.repository.SetSessionPatient(ctx, sessionID, patientID); err != nil {
+			return nil, err
+		}
+	}
+
+	slog.Info("Set session patient", logging.SessionID(sessionID), "patient_id", patientID)
+	return session, nil
+}
+
+// checkPatient проверяет, что карточка patientID заведена; пустой ID допустим
+func (m *Manager) checkPatient(ctx context.Context, patientID string) error {
+	if patientID == "" {
+		return nil
+	}
+	exists, err := m.repository.PatientExists(ctx, patientID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrPatientNotFound, patientID)
+	}
+	return nil
 }
 
 // DeleteSession удаляет сессию
@@ -158,10 +258,11 @@ func (m *Manager) DeleteSession(ctx context.Context, sessionID string) error {
 	m.mu.Lock()
 	delete(m.activeSessions, sessionID)
 	m.mu.Unlock()
+	m.forgetAlerts(sessionID)
 
 	// Удаляем из Redis
 	if err := m.cache.DeleteSession(ctx, sessionID); err != nil {
-		log.Printf("[WARN] Failed to delete session from cache: %v", err)
+		slog.Warn("Failed to delete session from cache", logging.Err(err))
 	}
 
 	// Удаляем из PostgreSQL
@@ -169,14 +270,24 @@ func (m *Manager) DeleteSession(ctx context.Context, sessionID string) error {
 		return fmt.Errorf("failed to delete session from database: %w", err)
 	}
 
-	log.Printf("[SESSION] Deleted session: %s", sessionID)
+	slog.Info("Deleted session", logging.SessionID(sessionID))
 	return nil
 }
 
 // ProcessFeatureBatch обрабатывает батч от feature extractor
-func (m *Manager) ProcessFeatureBatch(ctx context.Context, response *featureextractorv1.ProcessBatchResponse) error {
+func (m *Manager) ProcessFeatureBatch(ctx context.Context, response *featureextractorv1.ProcessBatchResponse) (err error) {
 	sessionID := response.SessionId
 
+	ctx, span := tracer.Start(ctx, "Manager.ProcessFeatureBatch",
+		trace.WithAttributes(attribute.String("session.id", sessionID)))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
 	// Получаем или создаем сессию
 	session, err := m.getOrCreateSession(ctx, sessionID)
 	if err != nil {
@@ -184,7 +295,7 @@ func (m *Manager) ProcessFeatureBatch(ctx context.Context, response *featureextr
 	}
 
 	if session.Status != SessionStatusActive {
-		log.Printf("[WARN] Received batch for non-active session: %s (status: %s)", sessionID, session.Status)
+		slog.WarnContext(ctx, "Received batch for non-active session", logging.SessionID(sessionID), "status", session.Status)
 		return nil // Не возвращаем ошибку, просто игнорируем
 	}
 
@@ -196,27 +307,26 @@ func (m *Manager) ProcessFeatureBatch(ctx context.Context, response *featureextr
 
 	// 2. Добавляем новые события (только если они еще не существуют)
 	if err := m.processEvents(ctx, sessionID, response); err != nil {
-		log.Printf("[WARN] Failed to process events: %v", err)
+		slog.WarnContext(ctx, "Failed to process events", logging.SessionID(sessionID), logging.Err(err))
 	}
 
 	// 3. Добавляем новые значения временных рядов
 	if err := m.processTimeSeries(ctx, sessionID, response); err != nil {
-		log.Printf("[WARN] Failed to process time series: %v", err)
+		slog.WarnContext(ctx, "Failed to process time series", logging.SessionID(sessionID), logging.Err(err))
 	}
 
 	// 4. Обновляем отфильтрованные данные
 	if err := m.processFilteredData(ctx, sessionID, response); err != nil {
-		log.Printf("[WARN] Failed to process filtered data: %v", err)
+		slog.WarnContext(ctx, "Failed to process filtered data", logging.SessionID(sessionID), logging.Err(err))
 	}
 
 	// 5. Обновляем счетчик точек данных в сессии
 	session.TotalDataPoints += int64(response.DataPoints)
 	if err := m.cache.SetSession(ctx, session); err != nil {
-		log.Printf("[WARN] Failed to update session: %v", err)
+		slog.Warn("Failed to update session", logging.Err(err))
 	}
 
-	log.Printf("[SESSION] Processed batch for session %s: stv=%.2f ltv=%.2f points=%d",
-		sessionID, response.Stv, response.Ltv, response.DataPoints)
+	slog.DebugContext(ctx, "Processed feature batch", logging.SessionID(sessionID), logging.BatchTS(response.BatchTsMs), "stv", response.Stv, "ltv", response.Ltv, "points", response.DataPoints)
 
 	return nil
 }
@@ -281,7 +391,7 @@ func (m *Manager) processEvents(ctx context.Context, sessionID string, response
 		if err := m.cache.AppendEvents(ctx, sessionID, newEvents); err != nil {
 			return err
 		}
-		log.Printf("[SESSION] Added %d new events to session %s", len(newEvents), sessionID)
+		slog.Info("Added new events", logging.SessionID(sessionID), "events", len(newEvents))
 	}
 
 	return nil
@@ -359,14 +469,119 @@ func (m *Manager) processFilteredData(ctx context.Context, sessionID string, res
 	return nil
 }
 
-// GetSessionMetrics получает текущие метрики сессии
+// GetSessionMetrics получает текущие метрики сессии вместе с классификацией FIGO/NICE
 func (m *Manager) GetSessionMetrics(ctx context.Context, sessionID string) (*SessionMetrics, error) {
-	return m.cache.GetMetrics(ctx, sessionID)
+	metrics, err := m.cache.GetMetrics(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := m.cache.GetEvents(ctx, sessionID, EventTypeDeceleration)
+	if err != nil {
+		slog.Warn("Failed to get decelerations for classification", logging.Err(err))
+	}
+	ltv, err := m.cache.GetTimeSeries(ctx, sessionID, TimeSeriesTypeLTV)
+	if err != nil {
+		slog.Warn("Failed to get LTV windows for classification", logging.Err(err))
+	}
+	metrics.Classification = ctg.Classify(ClassificationInput(metrics, events, ltv))
+
+	return metrics, nil
+}
+
+// UpdatePrediction сохраняет последнее предсказание ML сервиса в метриках сессии
+func (m *Manager) UpdatePrediction(ctx context.Context, sessionID string, prediction float64) error {
+	return m.cache.SetPrediction(ctx, sessionID, prediction)
 }
 
 // GetSessionData получает все данные сессии
 func (m *Manager) GetSessionData(ctx context.Context, sessionID string) (*SessionData, error) {
-	return m.cache.GetSessionData(ctx, sessionID)
+	data, err := m.cache.GetSessionData(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	data.Alerts = m.liveAlerts(ctx, sessionID)
+	return data, nil
+}
+
+// GetSessionAlerts возвращает тревоги сессии: текущие из движка,
+// а для сессий, которых уже нет в кэше, - сохраненные в PostgreSQL
+func (m *Manager) GetSessionAlerts(ctx context.Context, sessionID string) ([]*alert.Alert, error) {
+	if alerts := m.liveAlerts(ctx, sessionID); len(alerts) > 0 {
+		return alerts, nil
+	}
+	return m.repository.GetAlerts(ctx, sessionID)
+}
+
+// AcknowledgeAlert подтверждает тревогу от имени пользователя
+func (m *Manager) AcknowledgeAlert(ctx context.Context, alertID, user, comment string) (*alert.Alert, error) {
+	if m.alerts == nil {
+		return nil, ErrAlertsDisabled
+	}
+
+	a, err := m.alerts.Acknowledge(ctx, alertID, user, comment)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.syncSavedAlert(ctx, a); err != nil {
+		return nil, err
+	}
+	slog.Info("Alert acknowledged", "alert_id", alertID, "user", user, logging.SessionID(a.SessionID))
+	return a, nil
+}
+
+// ResolveAlert закрывает тревогу от имени пользователя
+func (m *Manager) ResolveAlert(ctx context.Context, alertID, user, comment string) (*alert.Alert, error) {
+	if m.alerts == nil {
+		return nil, ErrAlertsDisabled
+	}
+
+	a, err := m.alerts.Resolve(ctx, alertID, user, comment)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.syncSavedAlert(ctx, a); err != nil {
+		return nil, err
+	}
+	slog.Info("Alert resolved", "alert_id", alertID, "user", user, logging.SessionID(a.SessionID))
+	return a, nil
+}
+
+// liveAlerts возвращает тревоги из движка (пустой список, если тревоги отключены)
+func (m *Manager) liveAlerts(ctx context.Context, sessionID string) []*alert.Alert {
+	if m.alerts == nil {
+		return nil
+	}
+
+	alerts, err := m.alerts.Alerts(ctx, sessionID)
+	if err != nil {
+		slog.Warn("Failed to get alerts", logging.SessionID(sessionID), logging.Err(err))
+		return nil
+	}
+	return alerts
+}
+
+// forgetAlerts освобождает состояние правил сессии, которая больше не мониторится
+func (m *Manager) forgetAlerts(sessionID string) {
+	if m.alerts != nil {
+		m.alerts.Forget(sessionID)
+	}
+}
+
+// syncSavedAlert обновляет тревогу в PostgreSQL, если сессия уже сохранена,
+// чтобы подтверждения после сохранения тоже попали в журнал
+func (m *Manager) syncSavedAlert(ctx context.Context, a *alert.Alert) error {
+	session, err := m.GetSession(ctx, a.SessionID)
+	if err != nil || session.Status != SessionStatusSaved {
+		return nil
+	}
+
+	if err := m.repository.SaveAlerts(ctx, []*alert.Alert{a}); err != nil {
+		return fmt.Errorf("failed to update alert %s in database: %w", a.ID, err)
+	}
+	return nil
 }
 
 // IsSessionActive проверяет, активна ли сессия
@@ -377,6 +592,13 @@ func (m *Manager) IsSessionActive(sessionID string) bool {
 	return exists
 }
 
+// ActiveSessionCount возвращает число активных сессий в памяти
+func (m *Manager) ActiveSessionCount() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.activeSessions)
+}
+
 // getOrCreateSession получает существующую сессию или создает новую
 // Используется для автоматического создания сессий при получении данных от устройств
 func (m *Manager) getOrCreateSession(ctx context.Context, sessionID string) (*Session, error) {
@@ -402,9 +624,9 @@ func (m *Manager) getOrCreateSession(ctx context.Context, sessionID string) (*Se
 	session, err = m.repository.GetSession(ctx, sessionID)
 	if err == nil {
 		// Найдена в БД, загружаем в кэш
-		log.Printf("[SESSION] Loaded existing session from database: %s (status: %s)", sessionID, session.Status)
+		slog.Info("Loaded existing session from database", logging.SessionID(sessionID), "status", session.Status)
 		if err := m.cache.SetSession(ctx, session); err != nil {
-			log.Printf("[WARN] Failed to cache session: %v", err)
+			slog.Warn("Failed to cache session", logging.Err(err))
 		}
 		if session.Status == SessionStatusActive {
 			m.mu.Lock()
@@ -415,7 +637,7 @@ func (m *Manager) getOrCreateSession(ctx context.Context, sessionID string) (*Se
 	}
 
 	// Сессия не найдена нигде - создаем новую
-	log.Printf("[SESSION] Auto-creating new session from incoming data: %s", sessionID)
+	slog.Info("Auto-creating new session from incoming data", logging.SessionID(sessionID))
 
 	session = &Session{
 		ID:        sessionID,
@@ -437,6 +659,6 @@ func (m *Manager) getOrCreateSession(ctx context.Context, sessionID string) (*Se
 	m.activeSessions[sessionID] = session
 	m.mu.Unlock()
 
-	log.Printf("[SESSION] Successfully auto-created session: %s", sessionID)
+	slog.Info("Successfully auto-created session", logging.SessionID(sessionID))
 	return session, nil
 }
@@ -0,0 +1,130 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// fakeSessionCache - сессии Redis в памяти; остальные методы CacheStore не используются
+type fakeSessionCache struct {
+	CacheStore
+	sessions map[string]*Session
+}
+
+func (c *fakeSessionCache) SetSession(_ context.Context, session *Session) error {
+	copied := *session
+	c.sessions[session.ID] = &copied
+	return nil
+}
+
+func (c *fakeSessionCache) GetSession(_ context.Context, sessionID string) (*Session, error) {
+	if session, ok := c.sessions[sessionID]; ok {
+		return session, nil
+	}
+	return nil, errors.New("redis: nil")
+}
+
+func (c *fakeSessionCache) SetSessionTTL(context.Context, string, int) error {
+	return nil
+}
+
+// fakeAlertService запоминает сессии, состояние правил которых освобождено
+type fakeAlertService struct {
+	AlertService
+	forgotten []string
+}
+
+func (a *fakeAlertService) Forget(sessionID string) {
+	a.forgotten = append(a.forgotten, sessionID)
+}
+
+func TestCreateSession_RejectsUnknownPatient(t *testing.T) {
+	repo, mock := newMockRepository(t)
+	cache := &fakeSessionCache{sessions: map[string]*Session{}}
+	manager := NewManager(cache, repo)
+
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM patients WHERE id = \\$1\\)").
+		WithArgs("p-missing").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	_, err := manager.CreateSession(context.Background(), &CreateSessionRequest{PatientID: "p-missing"})
+	if !errors.Is(err, ErrPatientNotFound) {
+		t.Fatalf("Expected ErrPatientNotFound, got %v", err)
+	}
+	if len(cache.sessions) != 0 || manager.ActiveSessionCount() != 0 {
+		t.Error("Session must not be created for an unknown patient")
+	}
+}
+
+func TestSetSessionPatient_LinksSavedSession(t *testing.T) {
+	repo, mock := newMockRepository(t)
+	manager := NewManager(&fakeSessionCache{sessions: map[string]*Session{}}, repo)
+	started := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs("p1").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("FROM sessions\\s+WHERE id = \\$1").
+		WithArgs("session1").
+		WillReturnRows(sqlmock.NewRows(sessionListColumns).
+			AddRow("session1", SessionStatusSaved, started, started, started, int64(0), int64(0), []byte(`{"patient_id":"p0"}`)))
+	mock.ExpectExec("UPDATE sessions\\s+SET patient_id = NULLIF\\(\\$2, ''\\)").
+		WithArgs("session1", "p1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	session, err := manager.SetSessionPatient(context.Background(), "session1", "p1")
+	if err != nil {
+		t.Fatalf("SetSessionPatient failed: %v", err)
+	}
+	if session.Metadata.PatientID != "p1" {
+		t.Errorf("PatientID = %q, want p1", session.Metadata.PatientID)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSetSessionPatient_UnlinksActiveSession(t *testing.T) {
+	repo, mock := newMockRepository(t)
+	cache := &fakeSessionCache{sessions: map[string]*Session{}}
+	manager := NewManager(cache, repo)
+
+	session, err := manager.CreateSession(context.Background(), &CreateSessionRequest{})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	session.Metadata.PatientID = "p1"
+
+	// Активная сессия еще не в PostgreSQL: меняются только Redis и память
+	if _, err := manager.SetSessionPatient(context.Background(), session.ID, ""); err != nil {
+		t.Fatalf("SetSessionPatient failed: %v", err)
+	}
+	if got := cache.sessions[session.ID].Metadata.PatientID; got != "" {
+		t.Errorf("Cached session still linked to %q", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStopSession_ForgetsAlertState(t *testing.T) {
+	repo, _ := newMockRepository(t)
+	alerts := &fakeAlertService{}
+	manager := NewManager(&fakeSessionCache{sessions: map[string]*Session{}}, repo)
+	manager.SetAlertService(alerts)
+
+	session, err := manager.CreateSession(context.Background(), &CreateSessionRequest{})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if err := manager.StopSession(context.Background(), session.ID); err != nil {
+		t.Fatalf("StopSession failed: %v", err)
+	}
+	if len(alerts.forgotten) != 1 || alerts.forgotten[0] != session.ID {
+		t.Errorf("Expected alert state of %s to be forgotten, got %v", session.ID, alerts.forgotten)
+	}
+}
@@ -3,11 +3,17 @@ package session
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+
+	telemetryv1 "github.com/Krimson/fetal-monitory/proto/telemetry"
+	"github.com/Krimson/fetal-monitory/receiver/internal/alert"
 )
 
 // PostgresRepository реализует Repository для PostgreSQL (Infrastructure Layer)
@@ -15,6 +21,15 @@ type PostgresRepository struct {
 	db *sql.DB
 }
 
+// dbExecutor - общее подмножество *sql.DB и *sql.Tx.
+// Внутренние функции репозитория принимают его, чтобы выполняться как отдельно, так и в общей транзакции.
+type dbExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
 // NewPostgresRepository создает новый экземпляр PostgresRepository
 func NewPostgresRepository(db *sql.DB) *PostgresRepository {
 	return &PostgresRepository{
@@ -28,9 +43,20 @@ func NewPostgresRepositoryFromDSN(dsn string) (*PostgresRepository, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	return openPostgresRepository(db)
+}
 
+// NewPostgresRepositoryFromConnector создает репозиторий поверх коннектора драйвера
+// (например, с подсчетом ошибок - metrics.Receiver.InstrumentPostgres)
+func NewPostgresRepositoryFromConnector(connector driver.Connector) (*PostgresRepository, error) {
+	return openPostgresRepository(sql.OpenDB(connector))
+}
+
+// openPostgresRepository проверяет соединение и настраивает пул
+func openPostgresRepository(db *sql.DB) (*PostgresRepository, error) {
 	// Проверяем соединение
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
@@ -42,25 +68,82 @@ func NewPostgresRepositoryFromDSN(dsn string) (*PostgresRepository, error) {
 	return &PostgresRepository{db: db}, nil
 }
 
+// DB возвращает пул соединений (используется раннером миграций)
+func (r *PostgresRepository) DB() *sql.DB {
+	return r.db
+}
+
 // Close закрывает соединение с БД
 func (r *PostgresRepository) Close() error {
 	return r.db.Close()
 }
 
+// withTx выполняет fn в транзакции: коммит при успехе, откат при любой ошибке
+func (r *PostgresRepository) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
 // ===== Управление сессиями =====
 
 func (r *PostgresRepository) CreateSession(ctx context.Context, session *Session) error {
+	return createSession(ctx, r.db, session)
+}
+
+func createSession(ctx context.Context, db dbExecutor, session *Session) error {
+	if _, err := insertSession(ctx, db, session, ""); err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+// upsertSession создает сессию или перезаписывает существующую
+func upsertSession(ctx context.Context, db dbExecutor, session *Session) error {
+	_, err := insertSession(ctx, db, session, `
+		ON CONFLICT (id) DO UPDATE SET
+			status = EXCLUDED.status,
+			started_at = EXCLUDED.started_at,
+			stopped_at = EXCLUDED.stopped_at,
+			saved_at = EXCLUDED.saved_at,
+			total_duration_ms = EXCLUDED.total_duration_ms,
+			total_data_points = EXCLUDED.total_data_points,
+			metadata = EXCLUDED.metadata,
+			patient_id = EXCLUDED.patient_id`)
+	if err != nil {
+		return fmt.Errorf("failed to upsert session: %w", err)
+	}
+	return nil
+}
+
+// insertSession вставляет строку сессии; conflict - необязательная секция ON CONFLICT.
+// Возвращает число вставленных или обновленных строк
+func insertSession(ctx context.Context, db dbExecutor, session *Session, conflict string) (int64, error) {
 	metadataJSON, err := json.Marshal(session.Metadata)
 	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
+		return 0, fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
+	// Карточка проверяется при создании и привязке сессии (Manager); подзапрос
+	// оставляет patient_id пустым для импортированных сессий с чужой карточкой -
+	// они привяжутся, когда такую карточку заведут
 	query := `
-		INSERT INTO sessions (id, status, started_at, stopped_at, saved_at, total_duration_ms, total_data_points, metadata)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-	`
+		INSERT INTO sessions (id, status, started_at, stopped_at, saved_at, total_duration_ms, total_data_points, metadata, patient_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, (SELECT id FROM patients WHERE id = $9))` + conflict
 
-	_, err = r.db.ExecContext(ctx, query,
+	result, err := db.ExecContext(ctx, query,
 		session.ID,
 		session.Status,
 		session.StartedAt,
@@ -69,13 +152,12 @@ func (r *PostgresRepository) CreateSession(ctx context.Context, session *Session
 		session.TotalDurationMs,
 		session.TotalDataPoints,
 		metadataJSON,
+		session.Metadata.PatientID,
 	)
-
 	if err != nil {
-		return fmt.Errorf("failed to create session: %w", err)
+		return 0, err
 	}
-
-	return nil
+	return result.RowsAffected()
 }
 
 func (r *PostgresRepository) GetSession(ctx context.Context, sessionID string) (*Session, error) {
@@ -101,7 +183,7 @@ func (r *PostgresRepository) GetSession(ctx context.Context, sessionID string) (
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("session not found: %s", sessionID)
+			return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
 		}
 		return nil, fmt.Errorf("failed to get session: %w", err)
 	}
@@ -121,7 +203,8 @@ func (r *PostgresRepository) UpdateSession(ctx context.Context, session *Session
 
 	query := `
 		UPDATE sessions
-		SET status = $2, stopped_at = $3, saved_at = $4, total_duration_ms = $5, total_data_points = $6, metadata = $7
+		SET status = $2, stopped_at = $3, saved_at = $4, total_duration_ms = $5, total_data_points = $6, metadata = $7,
+			patient_id = (SELECT id FROM patients WHERE id = $8)
 		WHERE id = $1
 	`
 
@@ -133,6 +216,7 @@ func (r *PostgresRepository) UpdateSession(ctx context.Context, session *Session
 		session.TotalDurationMs,
 		session.TotalDataPoints,
 		metadataJSON,
+		session.Metadata.PatientID,
 	)
 
 	if err != nil {
@@ -147,15 +231,68 @@ func (r *PostgresRepository) UpdateSession(ctx context.Context, session *Session
 	return nil
 }
 
-func (r *PostgresRepository) ListSessions(ctx context.Context, limit, offset int) ([]*Session, error) {
+// ListSessions возвращает сессии, подходящие под фильтр, в порядке filter.Sort.
+// Пагинация по ключу (started_at, id): страница начинается после filter.After
+func (r *PostgresRepository) ListSessions(ctx context.Context, filter SessionFilter) ([]*Session, error) {
+	var conds []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if len(filter.Statuses) > 0 {
+		statuses := make([]string, len(filter.Statuses))
+		for i, status := range filter.Statuses {
+			statuses[i] = string(status)
+		}
+		conds = append(conds, "s.status = ANY("+arg(pq.Array(statuses))+")")
+	}
+	for _, field := range []struct{ key, value string }{
+		{"patient_id", filter.PatientID},
+		{"doctor_id", filter.DoctorID},
+		{"facility_id", filter.FacilityID},
+	} {
+		if field.value != "" {
+			conds = append(conds, fmt.Sprintf("s.metadata->>'%s' = %s", field.key, arg(field.value)))
+		}
+	}
+	if filter.Query != "" {
+		pattern := arg("%" + escapeLike(filter.Query) + "%")
+		conds = append(conds, fmt.Sprintf(`(s.id ILIKE %[1]s OR s.metadata->>'patient_id' ILIKE %[1]s
+			OR s.metadata->>'doctor_id' ILIKE %[1]s OR s.metadata->>'notes' ILIKE %[1]s)`, pattern))
+	}
+	if filter.StartedFrom != nil {
+		conds = append(conds, "s.started_at >= "+arg(*filter.StartedFrom))
+	}
+	if filter.StartedTo != nil {
+		conds = append(conds, "s.started_at < "+arg(*filter.StartedTo))
+	}
+	if filter.PredictionMin != nil {
+		conds = append(conds, "m.prediction >= "+arg(*filter.PredictionMin))
+	}
+	if filter.PredictionMax != nil {
+		conds = append(conds, "m.prediction <= "+arg(*filter.PredictionMax))
+	}
+
+	order, cmp := "DESC", "<"
+	if filter.Sort == SessionSortStartedAsc {
+		order, cmp = "ASC", ">"
+	}
+	if filter.After != nil {
+		conds = append(conds, fmt.Sprintf("(s.started_at, s.id) %s (%s, %s)", cmp, arg(filter.After.StartedAt), arg(filter.After.ID)))
+	}
+
 	query := `
-		SELECT id, status, started_at, stopped_at, saved_at, total_duration_ms, total_data_points, metadata
-		FROM sessions
-		ORDER BY started_at DESC
-		LIMIT $1 OFFSET $2
-	`
+		SELECT s.id, s.status, s.started_at, s.stopped_at, s.saved_at, s.total_duration_ms, s.total_data_points, s.metadata
+		FROM sessions s
+		LEFT JOIN session_metrics m ON m.session_id = s.id`
+	if len(conds) > 0 {
+		query += "\n\t\tWHERE " + strings.Join(conds, "\n\t\t  AND ")
+	}
+	query += fmt.Sprintf("\n\t\tORDER BY s.started_at %[1]s, s.id %[1]s\n\t\tLIMIT %s", order, arg(filter.Limit))
 
-	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list sessions: %w", err)
 	}
@@ -187,7 +324,42 @@ func (r *PostgresRepository) ListSessions(ctx context.Context, limit, offset int
 		}
 	}
 
-	return sessions, nil
+	return sessions, rows.Err()
+}
+
+// escapeLike экранирует спецсимволы шаблона LIKE
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// PatientExists проверяет, заведена ли карточка пациентки
+func (r *PostgresRepository) PatientExists(ctx context.Context, patientID string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM patients WHERE id = $1)`, patientID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check patient: %w", err)
+	}
+	return exists, nil
+}
+
+// SetSessionPatient привязывает сохраненную сессию к карточке (пустой patientID - отвязывает)
+func (r *PostgresRepository) SetSessionPatient(ctx context.Context, sessionID, patientID string) error {
+	query := `
+		UPDATE sessions
+		SET patient_id = NULLIF($2, ''),
+			metadata = CASE WHEN $2 = '' THEN COALESCE(metadata, '{}'::jsonb) - 'patient_id'
+				ELSE jsonb_set(COALESCE(metadata, '{}'::jsonb), '{patient_id}', to_jsonb($2::text)) END
+		WHERE id = $1
+	`
+
+	result, err := r.db.ExecContext(ctx, query, sessionID, patientID)
+	if err != nil {
+		return fmt.Errorf("failed to set session patient: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
+	}
+	return nil
 }
 
 func (r *PostgresRepository) DeleteSession(ctx context.Context, sessionID string) error {
@@ -200,6 +372,9 @@ func (r *PostgresRepository) DeleteSession(ctx context.Context, sessionID string
 	// Удаляем связанные данные (каскадное удаление должно работать через FK, но для надежности делаем явно)
 	queries := []string{
 		"DELETE FROM session_raw_data WHERE session_id = $1",
+		"DELETE FROM session_signal_pyramids WHERE session_id = $1",
+		"DELETE FROM session_raw_samples WHERE session_id = $1",
+		"DELETE FROM device_sessions WHERE session_id = $1",
 		"DELETE FROM session_timeseries WHERE session_id = $1",
 		"DELETE FROM session_events WHERE session_id = $1",
 		"DELETE FROM session_metrics WHERE session_id = $1",
@@ -222,13 +397,17 @@ func (r *PostgresRepository) DeleteSession(ctx context.Context, sessionID string
 // ===== Метрики =====
 
 func (r *PostgresRepository) SaveMetrics(ctx context.Context, metrics *SessionMetrics) error {
+	return saveMetrics(ctx, r.db, metrics)
+}
+
+func saveMetrics(ctx context.Context, db dbExecutor, metrics *SessionMetrics) error {
 	query := `
 		INSERT INTO session_metrics (
 			session_id, stv, ltv, baseline_heart_rate,
 			total_accelerations, total_decelerations, late_decelerations, late_deceleration_ratio,
 			total_contractions, accel_decel_ratio, stv_trend, bpm_trend,
-			data_points, time_span_sec, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+			data_points, time_span_sec, prediction, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
 		ON CONFLICT (session_id) DO UPDATE SET
 			stv = EXCLUDED.stv,
 			ltv = EXCLUDED.ltv,
@@ -243,10 +422,11 @@ func (r *PostgresRepository) SaveMetrics(ctx context.Context, metrics *SessionMe
 			bpm_trend = EXCLUDED.bpm_trend,
 			data_points = EXCLUDED.data_points,
 			time_span_sec = EXCLUDED.time_span_sec,
+			prediction = EXCLUDED.prediction,
 			updated_at = EXCLUDED.updated_at
 	`
 
-	_, err := r.db.ExecContext(ctx, query,
+	_, err := db.ExecContext(ctx, query,
 		metrics.SessionID,
 		metrics.STV,
 		metrics.LTV,
@@ -261,6 +441,7 @@ func (r *PostgresRepository) SaveMetrics(ctx context.Context, metrics *SessionMe
 		metrics.BPMTrend,
 		metrics.DataPoints,
 		metrics.TimeSpanSec,
+		metrics.Prediction,
 		metrics.UpdatedAt,
 	)
 
@@ -276,7 +457,7 @@ func (r *PostgresRepository) GetMetrics(ctx context.Context, sessionID string) (
 		SELECT session_id, stv, ltv, baseline_heart_rate,
 			total_accelerations, total_decelerations, late_decelerations, late_deceleration_ratio,
 			total_contractions, accel_decel_ratio, stv_trend, bpm_trend,
-			data_points, time_span_sec, updated_at
+			data_points, time_span_sec, COALESCE(prediction, 0), updated_at
 		FROM session_metrics
 		WHERE session_id = $1
 	`
@@ -298,6 +479,7 @@ func (r *PostgresRepository) GetMetrics(ctx context.Context, sessionID string) (
 		&metrics.BPMTrend,
 		&metrics.DataPoints,
 		&metrics.TimeSpanSec,
+		&metrics.Prediction,
 		&metrics.UpdatedAt,
 	)
 
@@ -318,18 +500,22 @@ func (r *PostgresRepository) SaveEvents(ctx context.Context, events []SessionEve
 		return nil
 	}
 
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		return insertEvents(ctx, tx, events)
+	})
+}
+
+func insertEvents(ctx context.Context, db dbExecutor, events []SessionEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
 	query := `
 		INSERT INTO session_events (session_id, event_type, start_time, end_time, duration, amplitude, is_late, created_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 	`
 
-	tx, err := r.db.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer tx.Rollback()
-
-	stmt, err := tx.PrepareContext(ctx, query)
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("failed to prepare statement: %w", err)
 	}
@@ -352,10 +538,6 @@ func (r *PostgresRepository) SaveEvents(ctx context.Context, events []SessionEve
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
 	return nil
 }
 
@@ -400,6 +582,59 @@ func (r *PostgresRepository) GetEvents(ctx context.Context, sessionID string) ([
 	return events, nil
 }
 
+// GetEventsRange возвращает события, начинающиеся в диапазоне и идущие после курсора,
+// в порядке (start_time, id)
+func (r *PostgresRepository) GetEventsRange(ctx context.Context, sessionID string, tr TimeRange, after *EventCursor) ([]SessionEvent, error) {
+	// start_time хранится в отсчетах сигнала, диапазон - в секундах
+	query := `
+		SELECT id, session_id, event_type, start_time, end_time, duration, amplitude, is_late, created_at
+		FROM session_events
+		WHERE session_id = $1
+		  AND ($2::float8 IS NULL OR start_time >= $2::float8 * $4)
+		  AND ($3::float8 IS NULL OR start_time <= $3::float8 * $4)
+		  AND ($6::float8 IS NULL OR (start_time, id) > ($6::float8, $7::bigint))
+		ORDER BY start_time ASC, id ASC
+		LIMIT $5
+	`
+
+	var afterStart, afterID interface{}
+	if after != nil {
+		afterStart, afterID = after.StartTime, after.ID
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, sessionID, tr.From, tr.To, sampleRateHz, rangeLimit(tr), afterStart, afterID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []SessionEvent
+
+	for rows.Next() {
+		var event SessionEvent
+
+		err := rows.Scan(
+			&event.ID,
+			&event.SessionID,
+			&event.Type,
+			&event.StartTime,
+			&event.EndTime,
+			&event.Duration,
+			&event.Amplitude,
+			&event.IsLate,
+			&event.CreatedAt,
+		)
+
+		if err != nil {
+			continue
+		}
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
 // ===== Временные ряды =====
 
 func (r *PostgresRepository) SaveTimeSeries(ctx context.Context, points []TimeSeriesPoint) error {
@@ -407,18 +642,22 @@ func (r *PostgresRepository) SaveTimeSeries(ctx context.Context, points []TimeSe
 		return nil
 	}
 
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		return insertTimeSeries(ctx, tx, points)
+	})
+}
+
+func insertTimeSeries(ctx context.Context, db dbExecutor, points []TimeSeriesPoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+
 	query := `
 		INSERT INTO session_timeseries (session_id, metric_type, time_index, value, window_duration)
 		VALUES ($1, $2, $3, $4, $5)
 	`
 
-	tx, err := r.db.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer tx.Rollback()
-
-	stmt, err := tx.PrepareContext(ctx, query)
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("failed to prepare statement: %w", err)
 	}
@@ -438,10 +677,6 @@ func (r *PostgresRepository) SaveTimeSeries(ctx context.Context, points []TimeSe
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
 	return nil
 }
 
@@ -482,66 +717,708 @@ func (r *PostgresRepository) GetTimeSeries(ctx context.Context, sessionID string
 	return points, nil
 }
 
-// ===== Сохранение полных данных сессии =====
+// GetTimeSeriesRange возвращает окна временного ряда, начинающиеся в диапазоне
+func (r *PostgresRepository) GetTimeSeriesRange(ctx context.Context, sessionID string, seriesType TimeSeriesType, tr TimeRange) ([]TimeSeriesPoint, error) {
+	query := `
+		SELECT session_id, metric_type, time_index, value, window_duration
+		FROM session_timeseries
+		WHERE session_id = $1 AND metric_type = $2
+		  AND ($3::float8 IS NULL OR time_index * window_duration >= $3::float8)
+		  AND ($4::float8 IS NULL OR time_index * window_duration <= $4::float8)
+		ORDER BY time_index ASC
+		LIMIT $5
+	`
 
-func (r *PostgresRepository) SaveSessionData(ctx context.Context, data *SessionData) error {
-	tx, err := r.db.BeginTx(ctx, nil)
+	rows, err := r.db.QueryContext(ctx, query, sessionID, seriesType, tr.From, tr.To, rangeLimit(tr))
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to get time series: %w", err)
 	}
-	defer tx.Rollback()
+	defer rows.Close()
 
-	// 1. Сохраняем/обновляем сессию
-	if err := r.CreateSession(ctx, data.Session); err != nil {
-		// Если сессия уже существует, обновляем
-		if err := r.UpdateSession(ctx, data.Session); err != nil {
-			return fmt.Errorf("failed to save session: %w", err)
+	var points []TimeSeriesPoint
+
+	for rows.Next() {
+		var point TimeSeriesPoint
+
+		err := rows.Scan(
+			&point.SessionID,
+			&point.Type,
+			&point.TimeIndex,
+			&point.Value,
+			&point.WindowDuration,
+		)
+
+		if err != nil {
+			continue
+		}
+
+		points = append(points, point)
+	}
+
+	return points, rows.Err()
+}
+
+// GetFilteredData возвращает отфильтрованный сигнал сохраненной сессии из session_raw_data
+func (r *PostgresRepository) GetFilteredData(ctx context.Context, sessionID string, metricType MetricType) ([]FilteredDataPoint, error) {
+	query := `
+		SELECT data
+		FROM session_raw_data
+		WHERE session_id = $1 AND metric_type = $2
+		ORDER BY batch_ts_ms ASC, id ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, sessionID, rawMetricType(metricType))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get raw data: %w", err)
+	}
+	defer rows.Close()
+
+	var points []FilteredDataPoint
+
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			continue
+		}
+
+		var batch []FilteredDataPoint
+		if err := json.Unmarshal(data, &batch); err == nil {
+			points = append(points, batch...)
+			continue
+		}
+
+		// offline-service хранит сигнал в колоночном виде {time_sec: [...], value: [...]}
+		var columns struct {
+			TimeSec []float64 `json:"time_sec"`
+			Value   []float64 `json:"value"`
+		}
+		if err := json.Unmarshal(data, &columns); err != nil || len(columns.TimeSec) != len(columns.Value) {
+			continue
+		}
+		for i := range columns.TimeSec {
+			points = append(points, FilteredDataPoint{TimeSec: columns.TimeSec[i], Value: columns.Value[i]})
+		}
+	}
+
+	return points, nil
+}
+
+// GetFilteredDataRange возвращает точки сигнала с time_sec в диапазоне. Точки
+// разворачиваются из JSONB на стороне PostgreSQL в обоих форматах session_raw_data:
+// массив точек (receiver) и колонки {time_sec, value} (offline-service)
+func (r *PostgresRepository) GetFilteredDataRange(ctx context.Context, sessionID string, metricType MetricType, tr TimeRange) ([]FilteredDataPoint, error) {
+	query := `
+		SELECT time_sec, value FROM (
+			SELECT (p->>'time_sec')::float8 AS time_sec, (p->>'value')::float8 AS value
+			FROM session_raw_data d, jsonb_array_elements(d.data) AS p
+			WHERE d.session_id = $1 AND d.metric_type = $2 AND jsonb_typeof(d.data) = 'array'
+			UNION ALL
+			SELECT c.time_sec::float8, (d.data->'value'->>(c.idx::int - 1))::float8
+			FROM session_raw_data d, jsonb_array_elements_text(d.data->'time_sec') WITH ORDINALITY AS c(time_sec, idx)
+			WHERE d.session_id = $1 AND d.metric_type = $2 AND jsonb_typeof(d.data) = 'object'
+		) points
+		WHERE ($3::float8 IS NULL OR time_sec >= $3::float8)
+		  AND ($4::float8 IS NULL OR time_sec <= $4::float8)
+		ORDER BY time_sec ASC
+		LIMIT $5
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, sessionID, rawMetricType(metricType), tr.From, tr.To, rangeLimit(tr))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get raw data: %w", err)
+	}
+	defer rows.Close()
+
+	var points []FilteredDataPoint
+
+	for rows.Next() {
+		var point FilteredDataPoint
+		var value sql.NullFloat64
+		if err := rows.Scan(&point.TimeSec, &value); err != nil || !value.Valid {
+			continue
+		}
+		point.Value = value.Float64
+		points = append(points, point)
+	}
+
+	return points, rows.Err()
+}
+
+// ===== Пирамиды прореженных трасс =====
+
+// SaveSignalPyramid сохраняет уровни пирамиды трассы, заменяя уровни с тем же бакетом
+func (r *PostgresRepository) SaveSignalPyramid(ctx context.Context, sessionID string, metricType MetricType, levels []PyramidLevel) error {
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		return saveSignalPyramid(ctx, tx, sessionID, metricType, levels)
+	})
+}
+
+func saveSignalPyramid(ctx context.Context, db dbExecutor, sessionID string, metricType MetricType, levels []PyramidLevel) error {
+	query := `
+		INSERT INTO session_signal_pyramids (session_id, metric_type, bucket_sec, point_count, t0_sec, t1_sec, data)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (session_id, metric_type, bucket_sec) DO UPDATE SET
+			point_count = EXCLUDED.point_count,
+			t0_sec = EXCLUDED.t0_sec,
+			t1_sec = EXCLUDED.t1_sec,
+			data = EXCLUDED.data,
+			created_at = NOW()
+	`
+
+	for _, level := range levels {
+		if len(level.Points) == 0 {
+			continue
+		}
+
+		columns := struct {
+			TimeSec []float64 `json:"time_sec"`
+			Value   []float64 `json:"value"`
+		}{
+			TimeSec: make([]float64, len(level.Points)),
+			Value:   make([]float64, len(level.Points)),
+		}
+		for i, p := range level.Points {
+			columns.TimeSec[i] = p.TimeSec
+			columns.Value[i] = p.Value
+		}
+		data, err := json.Marshal(columns)
+		if err != nil {
+			return fmt.Errorf("failed to marshal pyramid level: %w", err)
+		}
+
+		_, err = db.ExecContext(ctx, query,
+			sessionID,
+			rawMetricType(metricType),
+			level.BucketSec,
+			len(level.Points),
+			level.Points[0].TimeSec,
+			level.Points[len(level.Points)-1].TimeSec,
+			data,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert pyramid level: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetPyramidLevels возвращает описание уровней пирамиды от детального к грубому
+func (r *PostgresRepository) GetPyramidLevels(ctx context.Context, sessionID string, metricType MetricType) ([]PyramidLevelInfo, error) {
+	query := `
+		SELECT bucket_sec, point_count, t0_sec, t1_sec
+		FROM session_signal_pyramids
+		WHERE session_id = $1 AND metric_type = $2
+		ORDER BY bucket_sec ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, sessionID, rawMetricType(metricType))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pyramid levels: %w", err)
+	}
+	defer rows.Close()
+
+	var levels []PyramidLevelInfo
+	for rows.Next() {
+		var level PyramidLevelInfo
+		if err := rows.Scan(&level.BucketSec, &level.PointCount, &level.T0Sec, &level.T1Sec); err != nil {
+			return nil, fmt.Errorf("failed to scan pyramid level: %w", err)
+		}
+		levels = append(levels, level)
+	}
+
+	return levels, rows.Err()
+}
+
+// GetPyramidLevel возвращает точки уровня пирамиды в диапазоне времени
+func (r *PostgresRepository) GetPyramidLevel(ctx context.Context, sessionID string, metricType MetricType, bucketSec float64, tr TimeRange) ([]FilteredDataPoint, error) {
+	query := `
+		SELECT c.time_sec::float8, (p.data->'value'->>(c.idx::int - 1))::float8
+		FROM session_signal_pyramids p,
+			jsonb_array_elements_text(p.data->'time_sec') WITH ORDINALITY AS c(time_sec, idx)
+		WHERE p.session_id = $1 AND p.metric_type = $2 AND p.bucket_sec = $3
+		  AND ($4::float8 IS NULL OR c.time_sec::float8 >= $4::float8)
+		  AND ($5::float8 IS NULL OR c.time_sec::float8 <= $5::float8)
+		ORDER BY c.idx ASC
+		LIMIT $6
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, sessionID, rawMetricType(metricType), bucketSec, tr.From, tr.To, rangeLimit(tr))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pyramid level: %w", err)
+	}
+	defer rows.Close()
+
+	var points []FilteredDataPoint
+	for rows.Next() {
+		var point FilteredDataPoint
+		if err := rows.Scan(&point.TimeSec, &point.Value); err != nil {
+			return nil, fmt.Errorf("failed to scan pyramid point: %w", err)
+		}
+		points = append(points, point)
+	}
+
+	return points, rows.Err()
+}
+
+// rangeLimit - параметр LIMIT для выборки по диапазону; NULL снимает ограничение
+func rangeLimit(tr TimeRange) interface{} {
+	if limit := tr.fetchLimit(); limit > 0 {
+		return limit
+	}
+	return nil
+}
+
+// rawMetricType - обозначение метрики в session_raw_data
+func rawMetricType(metricType MetricType) string {
+	if metricType == MetricTypeUterus {
+		return "UC"
+	}
+	return "FHR"
+}
+
+// ===== Сырые сэмплы =====
+
+// rawSampleChunk - колоночное представление чанка в session_raw_samples
+type rawSampleChunk struct {
+	TsMS   []int64              `json:"ts_ms"`
+	Metric []telemetryv1.Metric `json:"metric"`
+	Value  []float32            `json:"value"`
+}
+
+// AppendRawSamples дописывает чанк сырых сэмплов сессии
+func (r *PostgresRepository) AppendRawSamples(ctx context.Context, sessionID string, samples []RawSample) error {
+	return appendRawSamples(ctx, r.db, sessionID, samples)
+}
+
+func appendRawSamples(ctx context.Context, db dbExecutor, sessionID string, samples []RawSample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	chunk := rawSampleChunk{
+		TsMS:   make([]int64, len(samples)),
+		Metric: make([]telemetryv1.Metric, len(samples)),
+		Value:  make([]float32, len(samples)),
+	}
+	t0, t1 := samples[0].TsMS, samples[0].TsMS
+	for i, s := range samples {
+		chunk.TsMS[i] = s.TsMS
+		chunk.Metric[i] = s.Metric
+		chunk.Value[i] = s.Value
+		if s.TsMS < t0 {
+			t0 = s.TsMS
+		}
+		if s.TsMS > t1 {
+			t1 = s.TsMS
+		}
+	}
+
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return fmt.Errorf("failed to marshal raw samples: %w", err)
+	}
+
+	query := `
+		INSERT INTO session_raw_samples (session_id, t0_ms, t1_ms, sample_count, samples)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	if _, err := db.ExecContext(ctx, query, sessionID, t0, t1, len(samples), data); err != nil {
+		return fmt.Errorf("failed to insert raw samples: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteUnsavedRawSamples удаляет сырые сэмплы сессий, которых нет в таблице sessions
+// и последний чанк которых записан раньше maxAge назад. Возвращает число удаленных чанков
+func (r *PostgresRepository) DeleteUnsavedRawSamples(ctx context.Context, maxAge time.Duration) (int64, error) {
+	query := `
+		DELETE FROM session_raw_samples r
+		WHERE NOT EXISTS (SELECT 1 FROM sessions s WHERE s.id = r.session_id)
+		  AND r.session_id IN (
+			SELECT session_id FROM session_raw_samples
+			GROUP BY session_id
+			HAVING MAX(created_at) < NOW() - make_interval(secs => $1)
+		  )
+	`
+
+	result, err := r.db.ExecContext(ctx, query, maxAge.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete unsaved raw samples: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// GetRawSamples возвращает все сырые сэмплы сессии в порядке поступления
+func (r *PostgresRepository) GetRawSamples(ctx context.Context, sessionID string) ([]RawSample, error) {
+	query := `
+		SELECT samples
+		FROM session_raw_samples
+		WHERE session_id = $1
+		ORDER BY id ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get raw samples: %w", err)
+	}
+	defer rows.Close()
+
+	var samples []RawSample
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to scan raw samples: %w", err)
+		}
+
+		var chunk rawSampleChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal raw samples: %w", err)
+		}
+		if len(chunk.Metric) != len(chunk.TsMS) || len(chunk.Value) != len(chunk.TsMS) {
+			return nil, fmt.Errorf("corrupted raw samples chunk for session %s", sessionID)
+		}
+
+		for i := range chunk.TsMS {
+			samples = append(samples, RawSample{TsMS: chunk.TsMS[i], Metric: chunk.Metric[i], Value: chunk.Value[i]})
 		}
 	}
 
+	return samples, rows.Err()
+}
+
+// ===== Работа с тревогами =====
+
+// SaveAlerts сохраняет текущее состояние тревог и дописывает недостающие записи журнала
+func (r *PostgresRepository) SaveAlerts(ctx context.Context, alerts []*alert.Alert) error {
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		return saveAlerts(ctx, tx, alerts)
+	})
+}
+
+func saveAlerts(ctx context.Context, db dbExecutor, alerts []*alert.Alert) error {
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	alertQuery := `
+		INSERT INTO session_alerts (id, session_id, alert_type, severity, state, message, value, threshold,
+			raised_at, updated_at, acknowledged_at, acknowledged_by, resolved_at, resolved_by, comment)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		ON CONFLICT (id) DO UPDATE SET
+			state = EXCLUDED.state,
+			message = EXCLUDED.message,
+			value = EXCLUDED.value,
+			updated_at = EXCLUDED.updated_at,
+			acknowledged_at = EXCLUDED.acknowledged_at,
+			acknowledged_by = EXCLUDED.acknowledged_by,
+			resolved_at = EXCLUDED.resolved_at,
+			resolved_by = EXCLUDED.resolved_by,
+			comment = EXCLUDED.comment
+	`
+
+	historyQuery := `
+		INSERT INTO session_alert_history (alert_id, session_id, from_state, to_state, changed_by, comment, changed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (alert_id, to_state, changed_at) DO NOTHING
+	`
+
+	for _, a := range alerts {
+		_, err := db.ExecContext(ctx, alertQuery,
+			a.ID,
+			a.SessionID,
+			a.Type,
+			a.Severity,
+			a.State,
+			a.Message,
+			a.Value,
+			a.Threshold,
+			a.RaisedAt,
+			a.UpdatedAt,
+			a.AcknowledgedAt,
+			a.AcknowledgedBy,
+			a.ResolvedAt,
+			a.ResolvedBy,
+			a.Comment,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save alert %s: %w", a.ID, err)
+		}
+
+		// Журнал только дополняется: уже сохраненные переходы пропускаются
+		for _, t := range a.History {
+			_, err := db.ExecContext(ctx, historyQuery,
+				a.ID,
+				a.SessionID,
+				t.From,
+				t.To,
+				t.By,
+				t.Comment,
+				t.At,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to save alert history: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+const alertColumns = `id, session_id, alert_type, severity, state, message, value, threshold,
+	raised_at, updated_at, acknowledged_at, acknowledged_by, resolved_at, resolved_by, comment`
+
+// scanAlert читает строку session_alerts (колонки alertColumns)
+func scanAlert(scan func(dest ...any) error) (*alert.Alert, error) {
+	var a alert.Alert
+	var message, acknowledgedBy, resolvedBy, comment sql.NullString
+	var acknowledgedAt, resolvedAt sql.NullTime
+
+	err := scan(
+		&a.ID,
+		&a.SessionID,
+		&a.Type,
+		&a.Severity,
+		&a.State,
+		&message,
+		&a.Value,
+		&a.Threshold,
+		&a.RaisedAt,
+		&a.UpdatedAt,
+		&acknowledgedAt,
+		&acknowledgedBy,
+		&resolvedAt,
+		&resolvedBy,
+		&comment,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	a.Message = message.String
+	a.AcknowledgedBy = acknowledgedBy.String
+	a.ResolvedBy = resolvedBy.String
+	a.Comment = comment.String
+	if acknowledgedAt.Valid {
+		a.AcknowledgedAt = &acknowledgedAt.Time
+	}
+	if resolvedAt.Valid {
+		a.ResolvedAt = &resolvedAt.Time
+	}
+	return &a, nil
+}
+
+// loadAlertHistory дописывает в тревоги byID их журнал изменений
+func (r *PostgresRepository) loadAlertHistory(ctx context.Context, where string, arg any, byID map[string]*alert.Alert) error {
+	query := `
+		SELECT alert_id, from_state, to_state, changed_by, comment, changed_at
+		FROM session_alert_history
+		WHERE ` + where + ` = $1
+		ORDER BY changed_at ASC, id ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, arg)
+	if err != nil {
+		return fmt.Errorf("failed to get alert history: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var alertID string
+		var t alert.Transition
+		var from, comment sql.NullString
+
+		if err := rows.Scan(&alertID, &from, &t.To, &t.By, &comment, &t.At); err != nil {
+			return fmt.Errorf("failed to scan alert history: %w", err)
+		}
+		t.From = alert.State(from.String)
+		t.Comment = comment.String
+
+		if a, ok := byID[alertID]; ok {
+			a.History = append(a.History, t)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read alert history: %w", err)
+	}
+
+	return nil
+}
+
+// GetAlerts возвращает тревоги сохраненной сессии вместе с журналом изменений
+func (r *PostgresRepository) GetAlerts(ctx context.Context, sessionID string) ([]*alert.Alert, error) {
+	query := `SELECT ` + alertColumns + `
+		FROM session_alerts
+		WHERE session_id = $1
+		ORDER BY raised_at ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alerts: %w", err)
+	}
+	defer rows.Close()
+
+	var alerts []*alert.Alert
+	byID := make(map[string]*alert.Alert)
+
+	for rows.Next() {
+		a, err := scanAlert(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert: %w", err)
+		}
+		alerts = append(alerts, a)
+		byID[a.ID] = a
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read alerts: %w", err)
+	}
+
+	if len(alerts) == 0 {
+		return alerts, nil
+	}
+
+	if err := r.loadAlertHistory(ctx, "session_id", sessionID, byID); err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// GetAlert возвращает тревогу сохраненной сессии по ID (alert.ErrNotFound, если ее нет).
+// Вместе с SaveAlert реализует alert.Archive: движок тревог обращается сюда,
+// когда тревога уже удалена из Redis по TTL.
+func (r *PostgresRepository) GetAlert(ctx context.Context, alertID string) (*alert.Alert, error) {
+	query := `SELECT ` + alertColumns + ` FROM session_alerts WHERE id = $1`
+
+	a, err := scanAlert(r.db.QueryRowContext(ctx, query, alertID).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, alert.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get alert: %w", err)
+	}
+
+	if err := r.loadAlertHistory(ctx, "alert_id", alertID, map[string]*alert.Alert{a.ID: a}); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// SaveAlert сохраняет одну тревогу сохраненной сессии
+func (r *PostgresRepository) SaveAlert(ctx context.Context, a *alert.Alert) error {
+	return r.SaveAlerts(ctx, []*alert.Alert{a})
+}
+
+// ===== Сохранение полных данных сессии =====
+
+// SaveSessionData сохраняет снимок сессии целиком в одной транзакции.
+// События, временные ряды и отфильтрованные данные сессии заменяются,
+// поэтому повторное сохранение той же сессии не создает дубликатов.
+func (r *PostgresRepository) SaveSessionData(ctx context.Context, data *SessionData) error {
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		// 1. Сохраняем/обновляем сессию
+		if err := upsertSession(ctx, tx, data.Session); err != nil {
+			return fmt.Errorf("failed to save session: %w", err)
+		}
+		return saveSessionContents(ctx, tx, data)
+	})
+}
+
+// ImportSessionData сохраняет импортированную сессию вместе с сырыми сэмплами в одной
+// транзакции. Существующая сессия не перезаписывается - возвращается ErrSessionExists
+func (r *PostgresRepository) ImportSessionData(ctx context.Context, data *SessionData, raw []RawSample) error {
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		created, err := insertSession(ctx, tx, data.Session, " ON CONFLICT (id) DO NOTHING")
+		if err != nil {
+			return fmt.Errorf("failed to create session: %w", err)
+		}
+		if created == 0 {
+			return fmt.Errorf("%w: %s", ErrSessionExists, data.Session.ID)
+		}
+
+		if err := saveSessionContents(ctx, tx, data); err != nil {
+			return err
+		}
+		if err := appendRawSamples(ctx, tx, data.Session.ID, raw); err != nil {
+			return fmt.Errorf("failed to save raw samples: %w", err)
+		}
+		return nil
+	})
+}
+
+// saveSessionContents заменяет данные сессии, строка которой уже записана в транзакции
+func saveSessionContents(ctx context.Context, tx *sql.Tx, data *SessionData) error {
+	sessionID := data.Session.ID
+
 	// 2. Сохраняем метрики
 	if data.Metrics != nil {
-		if err := r.SaveMetrics(ctx, data.Metrics); err != nil {
+		if err := saveMetrics(ctx, tx, data.Metrics); err != nil {
 			return fmt.Errorf("failed to save metrics: %w", err)
 		}
 	}
 
-	// 3. Сохраняем события
-	if len(data.Events) > 0 {
-		if err := r.SaveEvents(ctx, data.Events); err != nil {
-			return fmt.Errorf("failed to save events: %w", err)
-		}
+	// 3. Заменяем события
+	if _, err := tx.ExecContext(ctx, "DELETE FROM session_events WHERE session_id = $1", sessionID); err != nil {
+		return fmt.Errorf("failed to delete old events: %w", err)
+	}
+	if err := insertEvents(ctx, tx, data.Events); err != nil {
+		return fmt.Errorf("failed to save events: %w", err)
 	}
 
-	// 4. Сохраняем временные ряды
-	allTimeSeries := append(data.TimeSeriesSTV, data.TimeSeriesLTV...)
-	if len(allTimeSeries) > 0 {
-		if err := r.SaveTimeSeries(ctx, allTimeSeries); err != nil {
-			return fmt.Errorf("failed to save time series: %w", err)
-		}
+	// 4. Заменяем временные ряды
+	if _, err := tx.ExecContext(ctx, "DELETE FROM session_timeseries WHERE session_id = $1", sessionID); err != nil {
+		return fmt.Errorf("failed to delete old time series: %w", err)
+	}
+	allTimeSeries := make([]TimeSeriesPoint, 0, len(data.TimeSeriesSTV)+len(data.TimeSeriesLTV))
+	allTimeSeries = append(allTimeSeries, data.TimeSeriesSTV...)
+	allTimeSeries = append(allTimeSeries, data.TimeSeriesLTV...)
+	if err := insertTimeSeries(ctx, tx, allTimeSeries); err != nil {
+		return fmt.Errorf("failed to save time series: %w", err)
 	}
 
-	// 5. Опционально: сохраняем отфильтрованные данные как raw data
-	if len(data.FilteredBPMData) > 0 || len(data.FilteredUterusData) > 0 {
-		if err := r.saveFilteredDataAsRaw(ctx, data.Session.ID, data.FilteredBPMData, data.FilteredUterusData); err != nil {
-			return fmt.Errorf("failed to save filtered data: %w", err)
+	// 5. Заменяем отфильтрованные данные (хранятся как raw data)
+	if _, err := tx.ExecContext(ctx, "DELETE FROM session_raw_data WHERE session_id = $1", sessionID); err != nil {
+		return fmt.Errorf("failed to delete old raw data: %w", err)
+	}
+	if err := saveFilteredDataAsRaw(ctx, tx, sessionID, data.FilteredBPMData, data.FilteredUterusData); err != nil {
+		return fmt.Errorf("failed to save filtered data: %w", err)
+	}
+
+	// 6. Перестраиваем пирамиды прореженных трасс
+	if _, err := tx.ExecContext(ctx, "DELETE FROM session_signal_pyramids WHERE session_id = $1", sessionID); err != nil {
+		return fmt.Errorf("failed to delete old pyramids: %w", err)
+	}
+	for metricType, points := range map[MetricType][]FilteredDataPoint{
+		MetricTypeBPM:    data.FilteredBPMData,
+		MetricTypeUterus: data.FilteredUterusData,
+	} {
+		if err := saveSignalPyramid(ctx, tx, sessionID, metricType, buildPyramid(points)); err != nil {
+			return fmt.Errorf("failed to save %s pyramid: %w", metricType, err)
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	// 7. Обновляем тревоги; журнал тревог не удаляется
+	if err := saveAlerts(ctx, tx, data.Alerts); err != nil {
+		return fmt.Errorf("failed to save alerts: %w", err)
 	}
 
 	return nil
 }
 
 // saveFilteredDataAsRaw сохраняет отфильтрованные данные в таблицу raw_data
-func (r *PostgresRepository) saveFilteredDataAsRaw(ctx context.Context, sessionID string, bpmData, uterusData []FilteredDataPoint) error {
+func saveFilteredDataAsRaw(ctx context.Context, db dbExecutor, sessionID string, bpmData, uterusData []FilteredDataPoint) error {
 	query := `
 		INSERT INTO session_raw_data (session_id, batch_ts_ms, metric_type, data, created_at)
 		VALUES ($1, $2, $3, $4, $5)
 	`
 
+	now := time.Now()
+
 	// Сохраняем BPM данные
 	if len(bpmData) > 0 {
 		dataJSON, err := json.Marshal(bpmData)
@@ -549,12 +1426,12 @@ func (r *PostgresRepository) saveFilteredDataAsRaw(ctx context.Context, sessionI
 			return fmt.Errorf("failed to marshal bpm data: %w", err)
 		}
 
-		_, err = r.db.ExecContext(ctx, query,
+		_, err = db.ExecContext(ctx, query,
 			sessionID,
-			time.Now().UnixMilli(),
-			"FHR",
+			now.UnixMilli(),
+			rawMetricType(MetricTypeBPM),
 			dataJSON,
-			time.Now(),
+			now,
 		)
 
 		if err != nil {
@@ -569,12 +1446,12 @@ func (r *PostgresRepository) saveFilteredDataAsRaw(ctx context.Context, sessionI
 			return fmt.Errorf("failed to marshal uterus data: %w", err)
 		}
 
-		_, err = r.db.ExecContext(ctx, query,
+		_, err = db.ExecContext(ctx, query,
 			sessionID,
-			time.Now().UnixMilli(),
-			"UC",
+			now.UnixMilli(),
+			rawMetricType(MetricTypeUterus),
 			dataJSON,
-			time.Now(),
+			now,
 		)
 
 		if err != nil {
@@ -584,4 +1461,3 @@ func (r *PostgresRepository) saveFilteredDataAsRaw(ctx context.Context, sessionI
 
 	return nil
 }
-
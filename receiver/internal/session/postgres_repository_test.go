@@ -0,0 +1,299 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/Krimson/fetal-monitory/receiver/internal/alert"
+)
+
+func newMockRepository(t *testing.T) (*PostgresRepository, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewPostgresRepository(db), mock
+}
+
+func testSessionData() *SessionData {
+	started := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	stopped := started.Add(40 * time.Minute)
+	return &SessionData{
+		Session: &Session{
+			ID:        "session1",
+			Status:    SessionStatusSaved,
+			StartedAt: started,
+			StoppedAt: &stopped,
+		},
+		Metrics: &SessionMetrics{SessionID: "session1", STV: 6.5, LTV: 40, UpdatedAt: stopped},
+		Events: []SessionEvent{
+			{SessionID: "session1", Type: EventTypeAcceleration, StartTime: 10, EndTime: 30, Duration: 20, Amplitude: 18, CreatedAt: stopped},
+		},
+		TimeSeriesSTV: []TimeSeriesPoint{
+			{SessionID: "session1", Type: TimeSeriesTypeSTV, TimeIndex: 0, Value: 6.5, WindowDuration: 60},
+		},
+		FilteredBPMData: []FilteredDataPoint{{TimeSec: 0, Value: 140}, {TimeSec: 0.25, Value: 141}},
+		Alerts: []*alert.Alert{{
+			ID:        "alert1",
+			SessionID: "session1",
+			Type:      alert.TypeTachycardia,
+			Severity:  alert.SeverityWarning,
+			State:     alert.StateActive,
+			RaisedAt:  started,
+			UpdatedAt: started,
+			History:   []alert.Transition{{To: alert.StateActive, By: "system", At: started}},
+		}},
+	}
+}
+
+// expectSessionDataWrites ожидает все записи SaveSessionData для testSessionData внутри транзакции
+func expectSessionDataWrites(mock sqlmock.Sqlmock) {
+	mock.ExpectExec("INSERT INTO sessions .* ON CONFLICT \\(id\\) DO UPDATE").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSessionContentWrites(mock)
+}
+
+// expectSessionContentWrites ожидает записи данных testSessionData после строки сессии
+func expectSessionContentWrites(mock sqlmock.Sqlmock) {
+	mock.ExpectExec("INSERT INTO session_metrics .* ON CONFLICT \\(session_id\\) DO UPDATE").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectExec("DELETE FROM session_events WHERE session_id = \\$1").WithArgs("session1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectPrepare("INSERT INTO session_events").ExpectExec().WillReturnResult(sqlmock.NewResult(1, 1))
+
+	mock.ExpectExec("DELETE FROM session_timeseries WHERE session_id = \\$1").WithArgs("session1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectPrepare("INSERT INTO session_timeseries").ExpectExec().WillReturnResult(sqlmock.NewResult(1, 1))
+
+	mock.ExpectExec("DELETE FROM session_raw_data WHERE session_id = \\$1").WithArgs("session1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO session_raw_data").WillReturnResult(sqlmock.NewResult(1, 1))
+
+	mock.ExpectExec("DELETE FROM session_signal_pyramids WHERE session_id = \\$1").WithArgs("session1").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Тревоги обновляются на месте, журнал тревог только дополняется
+	mock.ExpectExec("INSERT INTO session_alerts .* ON CONFLICT \\(id\\) DO UPDATE").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO session_alert_history .* ON CONFLICT \\(alert_id, to_state, changed_at\\) DO NOTHING").WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+func TestSaveSessionData_CommitsAllInOneTransaction(t *testing.T) {
+	repo, mock := newMockRepository(t)
+
+	mock.ExpectBegin()
+	expectSessionDataWrites(mock)
+	mock.ExpectCommit()
+
+	if err := repo.SaveSessionData(context.Background(), testSessionData()); err != nil {
+		t.Fatalf("SaveSessionData failed: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSaveSessionData_RollsBackOnMidSaveFailure(t *testing.T) {
+	repo, mock := newMockRepository(t)
+	failure := errors.New("connection reset")
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO sessions").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO session_metrics").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM session_events").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectPrepare("INSERT INTO session_events").ExpectExec().WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM session_timeseries").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectPrepare("INSERT INTO session_timeseries").ExpectExec().WillReturnError(failure)
+	// Ничего из уже выполненного не фиксируется
+	mock.ExpectRollback()
+
+	err := repo.SaveSessionData(context.Background(), testSessionData())
+	if !errors.Is(err, failure) {
+		t.Fatalf("Expected wrapped %v, got %v", failure, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSaveSessionData_RollsBackOnCommitFailure(t *testing.T) {
+	repo, mock := newMockRepository(t)
+
+	mock.ExpectBegin()
+	expectSessionDataWrites(mock)
+	mock.ExpectCommit().WillReturnError(errors.New("serialization failure"))
+
+	if err := repo.SaveSessionData(context.Background(), testSessionData()); err == nil {
+		t.Fatal("Expected commit error to be returned")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSaveSessionData_ResaveReplacesRows(t *testing.T) {
+	repo, mock := newMockRepository(t)
+
+	// Данные сессии сначала удаляются и только потом вставляются, а журнал тревог
+	// пропускает уже сохраненные переходы, поэтому повторное сохранение не оставляет дубликатов
+	for i := 0; i < 2; i++ {
+		mock.ExpectBegin()
+		expectSessionDataWrites(mock)
+		mock.ExpectCommit()
+	}
+
+	data := testSessionData()
+	for i := 0; i < 2; i++ {
+		if err := repo.SaveSessionData(context.Background(), data); err != nil {
+			t.Fatalf("Save %d failed: %v", i+1, err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestImportSessionData_WritesRawSamplesInSameTransaction(t *testing.T) {
+	repo, mock := newMockRepository(t)
+	raw := []RawSample{{TsMS: 1000, Value: 140}, {TsMS: 1250, Value: 141}}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO sessions .* ON CONFLICT \\(id\\) DO NOTHING").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSessionContentWrites(mock)
+	mock.ExpectExec("INSERT INTO session_raw_samples").
+		WithArgs("session1", int64(1000), int64(1250), 2, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := repo.ImportSessionData(context.Background(), testSessionData(), raw); err != nil {
+		t.Fatalf("ImportSessionData failed: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestImportSessionData_DoesNotOverwriteExistingSession(t *testing.T) {
+	repo, mock := newMockRepository(t)
+
+	// Сессию с тем же ID успели записать: вставка ничего не меняет, остальное не пишется
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO sessions .* ON CONFLICT \\(id\\) DO NOTHING").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err := repo.ImportSessionData(context.Background(), testSessionData(), []RawSample{{TsMS: 1000, Value: 140}})
+	if !errors.Is(err, ErrSessionExists) {
+		t.Fatalf("Expected ErrSessionExists, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestImportSessionData_RollsBackOnRawSamplesFailure(t *testing.T) {
+	repo, mock := newMockRepository(t)
+	failure := errors.New("connection reset")
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO sessions .* ON CONFLICT \\(id\\) DO NOTHING").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSessionContentWrites(mock)
+	mock.ExpectExec("INSERT INTO session_raw_samples").WillReturnError(failure)
+	// Сессия без сырого потока не остается: импорт можно повторить
+	mock.ExpectRollback()
+
+	err := repo.ImportSessionData(context.Background(), testSessionData(), []RawSample{{TsMS: 1000, Value: 140}})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Expected wrapped %v, got %v", failure, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSaveAlerts_AppendsHistoryWithoutDeleting(t *testing.T) {
+	repo, mock := newMockRepository(t)
+
+	a := testSessionData().Alerts[0]
+	ackAt := a.RaisedAt.Add(time.Minute)
+	a.State = alert.StateAcknowledged
+	a.History = append(a.History, alert.Transition{From: alert.StateActive, To: alert.StateAcknowledged, By: "dr.ivanov", At: ackAt})
+
+	// Никаких DELETE: уже сохраненный переход пропускается по уникальному ключу
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO session_alerts").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO session_alert_history .* DO NOTHING").
+		WithArgs("alert1", "session1", alert.State(""), alert.StateActive, "system", "", a.RaisedAt).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO session_alert_history .* DO NOTHING").
+		WithArgs("alert1", "session1", alert.StateActive, alert.StateAcknowledged, "dr.ivanov", "", ackAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := repo.SaveAlerts(context.Background(), []*alert.Alert{a}); err != nil {
+		t.Fatalf("SaveAlerts failed: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetAlert_NotFound(t *testing.T) {
+	repo, mock := newMockRepository(t)
+
+	mock.ExpectQuery("SELECT .* FROM session_alerts WHERE id = \\$1").
+		WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	if _, err := repo.GetAlert(context.Background(), "missing"); !errors.Is(err, alert.ErrNotFound) {
+		t.Fatalf("Expected alert.ErrNotFound, got %v", err)
+	}
+}
+
+func TestGetAlerts_FailsOnBrokenHistory(t *testing.T) {
+	raisedAt := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	alertRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "session_id", "alert_type", "severity", "state", "message", "value", "threshold",
+			"raised_at", "updated_at", "acknowledged_at", "acknowledged_by", "resolved_at", "resolved_by", "comment"}).
+			AddRow("alert1", "session1", alert.TypeTachycardia, alert.SeverityWarning, alert.StateAcknowledged, nil, 170.0, 160.0,
+				raisedAt, raisedAt, raisedAt, "dr.ivanov", nil, nil, nil)
+	}
+	historyColumns := []string{"alert_id", "from_state", "to_state", "changed_by", "comment", "changed_at"}
+
+	// Тревога с неполным журналом не отдается: подтверждение не должно молча пропасть
+	tests := []struct {
+		name    string
+		history *sqlmock.Rows
+		want    string
+	}{
+		{
+			name: "scan error",
+			history: sqlmock.NewRows(historyColumns).
+				AddRow("alert1", nil, alert.StateActive, "system", nil, "not a time"),
+			want: "failed to scan alert history",
+		},
+		{
+			name: "rows error",
+			history: sqlmock.NewRows(historyColumns).
+				AddRow("alert1", nil, alert.StateActive, "system", nil, raisedAt).
+				AddRow("alert1", alert.StateActive, alert.StateAcknowledged, "dr.ivanov", nil, raisedAt).
+				RowError(1, errors.New("connection reset")),
+			want: "failed to read alert history",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, mock := newMockRepository(t)
+			mock.ExpectQuery("FROM session_alerts").WithArgs("session1").WillReturnRows(alertRows())
+			mock.ExpectQuery("FROM session_alert_history").WithArgs("session1").WillReturnRows(tt.history)
+
+			alerts, err := repo.GetAlerts(context.Background(), "session1")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Expected error %q, got %v (alerts %+v)", tt.want, err, alerts)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
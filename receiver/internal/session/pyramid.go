@@ -0,0 +1,180 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/Krimson/fetal-monitory/logging"
+	"github.com/Krimson/fetal-monitory/receiver/internal/downsample"
+)
+
+const (
+	// pyramidBaseBucketSec - бакет самого детального уровня пирамиды
+	pyramidBaseBucketSec = 4.0
+	// pyramidFactor - во сколько раз растет бакет от уровня к уровню
+	pyramidFactor = 4
+	// pyramidMinPoints - уровни строятся, пока в уровне больше точек
+	pyramidMinPoints = 500
+	// pyramidOversample - уровень выбирается с запасом точек относительно
+	// max_points, чтобы итоговое прореживание сохраняло форму
+	pyramidOversample = 4
+)
+
+// Downsampling - параметры прореживания трассы. Нулевое значение - без прореживания
+type Downsampling struct {
+	MaxPoints int
+	Method    downsample.Method
+}
+
+// PyramidLevel - уровень пирамиды прореженной трассы: вся трасса, сжатая
+// до минимума и максимума в каждом бакете BucketSec
+type PyramidLevel struct {
+	BucketSec float64
+	Points    []FilteredDataPoint
+}
+
+// PyramidLevelInfo описывает сохраненный уровень пирамиды без точек
+type PyramidLevelInfo struct {
+	BucketSec  float64
+	PointCount int
+	T0Sec      float64
+	T1Sec      float64
+}
+
+// buildPyramid строит уровни пирамиды трассы от детального к грубому
+func buildPyramid(points []FilteredDataPoint) []PyramidLevel {
+	var levels []PyramidLevel
+	if len(points) <= pyramidMinPoints {
+		return levels
+	}
+
+	source := toDownsamplePoints(points)
+	for bucket := pyramidBaseBucketSec; ; bucket *= pyramidFactor {
+		level := downsample.MinMaxBuckets(source, bucket)
+		levels = append(levels, PyramidLevel{BucketSec: bucket, Points: fromDownsamplePoints(level)})
+		if len(level) <= pyramidMinPoints {
+			return levels
+		}
+		// Следующий уровень строится из текущего: min/max бакета - это min/max его min/max
+		source = level
+	}
+}
+
+// downsampleSignal прореживает трассу, если задан ds.MaxPoints
+func downsampleSignal(points []FilteredDataPoint, ds Downsampling) []FilteredDataPoint {
+	if ds.MaxPoints <= 0 || len(points) <= ds.MaxPoints {
+		return points
+	}
+	return fromDownsamplePoints(downsample.Apply(ds.Method, toDownsamplePoints(points), ds.MaxPoints))
+}
+
+// savedSignalOverview возвращает прореженную трассу сохраненной сессии. Точки
+// берутся из самого детального уровня пирамиды, которого хватает для max_points,
+// или из полной трассы, если диапазон короткий
+func (m *Manager) savedSignalOverview(ctx context.Context, sessionID string, metricType MetricType, tr TimeRange, ds Downsampling) ([]FilteredDataPoint, error) {
+	levels, err := m.repository.GetPyramidLevels(ctx, sessionID, metricType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pyramid levels: %w", err)
+	}
+	if len(levels) == 0 {
+		// Сессия сохранена до появления пирамид или offline-service - строим сейчас
+		if levels, err = m.buildSavedPyramid(ctx, sessionID, metricType); err != nil {
+			return nil, err
+		}
+	}
+
+	budget := ds.MaxPoints * pyramidOversample
+	full := tr
+	full.Limit = 0
+
+	// Полная трасса, если в диапазоне и так немного точек
+	if len(levels) == 0 || estimatePoints(levels[0], tr, sampleRateHz) <= budget {
+		points, err := m.repository.GetFilteredDataRange(ctx, sessionID, metricType, full)
+		if err != nil {
+			return nil, err
+		}
+		return downsampleSignal(points, ds), nil
+	}
+
+	chosen := levels[len(levels)-1]
+	for _, level := range levels {
+		if estimatePoints(level, tr, 0) <= budget {
+			chosen = level
+			break
+		}
+	}
+
+	points, err := m.repository.GetPyramidLevel(ctx, sessionID, metricType, chosen.BucketSec, full)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pyramid level: %w", err)
+	}
+	return downsampleSignal(points, ds), nil
+}
+
+// buildSavedPyramid строит и сохраняет пирамиду по трассе из PostgreSQL
+func (m *Manager) buildSavedPyramid(ctx context.Context, sessionID string, metricType MetricType) ([]PyramidLevelInfo, error) {
+	points, err := m.repository.GetFilteredData(ctx, sessionID, metricType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s data: %w", metricType, err)
+	}
+
+	levels := buildPyramid(points)
+	if len(levels) == 0 {
+		return nil, nil
+	}
+	if err := m.repository.SaveSignalPyramid(ctx, sessionID, metricType, levels); err != nil {
+		return nil, fmt.Errorf("failed to save pyramid: %w", err)
+	}
+	slog.Debug("Built pyramid levels", logging.SessionID(sessionID), "metric", metricType, "levels", len(levels))
+
+	return pyramidInfo(levels), nil
+}
+
+// estimatePoints оценивает число точек уровня в диапазоне. rateHz > 0 -
+// оценка для полной трассы с этой частотой
+func estimatePoints(level PyramidLevelInfo, tr TimeRange, rateHz float64) int {
+	from, to := level.T0Sec, level.T1Sec
+	if tr.From != nil && *tr.From > from {
+		from = *tr.From
+	}
+	if tr.To != nil && *tr.To < to {
+		to = *tr.To
+	}
+	if to <= from {
+		return 0
+	}
+	if rateHz > 0 {
+		return int((to - from) * rateHz)
+	}
+	span := level.T1Sec - level.T0Sec
+	return int(float64(level.PointCount) * (to - from) / span)
+}
+
+func pyramidInfo(levels []PyramidLevel) []PyramidLevelInfo {
+	infos := make([]PyramidLevelInfo, 0, len(levels))
+	for _, level := range levels {
+		info := PyramidLevelInfo{BucketSec: level.BucketSec, PointCount: len(level.Points)}
+		if n := len(level.Points); n > 0 {
+			info.T0Sec, info.T1Sec = level.Points[0].TimeSec, level.Points[n-1].TimeSec
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+func toDownsamplePoints(points []FilteredDataPoint) []downsample.Point {
+	result := make([]downsample.Point, len(points))
+	for i, p := range points {
+		result[i] = downsample.Point{X: p.TimeSec, Y: p.Value}
+	}
+	return result
+}
+
+func fromDownsamplePoints(points []downsample.Point) []FilteredDataPoint {
+	result := make([]FilteredDataPoint, len(points))
+	for i, p := range points {
+		result[i] = FilteredDataPoint{TimeSec: p.X, Value: p.Y}
+	}
+	return result
+}
@@ -0,0 +1,135 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// fakePyramidRepository отдает заданные уровни пирамиды и запоминает, откуда читались точки;
+// остальные методы Repository не используются
+type fakePyramidRepository struct {
+	Repository
+	levels  []PyramidLevelInfo
+	fetched string
+}
+
+func (r *fakePyramidRepository) GetPyramidLevels(context.Context, string, MetricType) ([]PyramidLevelInfo, error) {
+	return r.levels, nil
+}
+
+func (r *fakePyramidRepository) GetPyramidLevel(_ context.Context, _ string, _ MetricType, bucketSec float64, _ TimeRange) ([]FilteredDataPoint, error) {
+	r.fetched = fmt.Sprintf("level %g", bucketSec)
+	return nil, nil
+}
+
+func (r *fakePyramidRepository) GetFilteredDataRange(context.Context, string, MetricType, TimeRange) ([]FilteredDataPoint, error) {
+	r.fetched = "full"
+	return nil, nil
+}
+
+// testTrace - трасса 4 Гц длиной n точек; соседние значения различаются, поэтому
+// в каждом бакете уровня остаются и минимум, и максимум
+func testTrace(n int) []FilteredDataPoint {
+	points := make([]FilteredDataPoint, n)
+	for i := range points {
+		points[i] = FilteredDataPoint{TimeSec: float64(i) / sampleRateHz, Value: 140 + float64(i%2)}
+	}
+	return points
+}
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
+
+func TestBuildPyramid(t *testing.T) {
+	tests := []struct {
+		name    string
+		points  int
+		buckets []float64
+		counts  []int
+	}{
+		{name: "short trace", points: pyramidMinPoints},
+		{name: "one level", points: 2000, buckets: []float64{4}, counts: []int{250}},
+		{name: "hour", points: 4 * 3600, buckets: []float64{4, 16}, counts: []int{1800, 450}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			levels := buildPyramid(testTrace(tt.points))
+			if len(levels) != len(tt.buckets) {
+				t.Fatalf("Expected %d levels, got %d", len(tt.buckets), len(levels))
+			}
+			for i, level := range levels {
+				if level.BucketSec != tt.buckets[i] || len(level.Points) != tt.counts[i] {
+					t.Errorf("Level %d: expected bucket %g with %d points, got bucket %g with %d points",
+						i, tt.buckets[i], tt.counts[i], level.BucketSec, len(level.Points))
+				}
+			}
+		})
+	}
+}
+
+func TestEstimatePoints(t *testing.T) {
+	level := PyramidLevelInfo{BucketSec: 4, PointCount: 3600, T0Sec: 100, T1Sec: 7300}
+
+	tests := []struct {
+		name   string
+		tr     TimeRange
+		rateHz float64
+		want   int
+	}{
+		{name: "whole level", want: 3600},
+		{name: "second half", tr: TimeRange{From: float64Ptr(3700)}, want: 1800},
+		{name: "inner range", tr: TimeRange{From: float64Ptr(1900), To: float64Ptr(3700)}, want: 900},
+		{name: "clamped to level", tr: TimeRange{From: float64Ptr(-500), To: float64Ptr(820)}, want: 360},
+		{name: "after level", tr: TimeRange{From: float64Ptr(8000)}, want: 0},
+		{name: "before level", tr: TimeRange{To: float64Ptr(50)}, want: 0},
+		{name: "full trace rate", tr: TimeRange{From: float64Ptr(100), To: float64Ptr(200)}, rateHz: sampleRateHz, want: 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := estimatePoints(level, tt.tr, tt.rateHz); got != tt.want {
+				t.Errorf("estimatePoints() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSavedSignalOverview_ChoosesLevel(t *testing.T) {
+	// Сохраненная сессия длиной 2 часа: 28800 точек полной трассы
+	levels := []PyramidLevelInfo{
+		{BucketSec: 4, PointCount: 3600, T0Sec: 0, T1Sec: 7200},
+		{BucketSec: 16, PointCount: 900, T0Sec: 0, T1Sec: 7200},
+		{BucketSec: 64, PointCount: 226, T0Sec: 0, T1Sec: 7200},
+	}
+
+	tests := []struct {
+		name      string
+		maxPoints int
+		tr        TimeRange
+		want      string
+	}{
+		{name: "most detailed level fits", maxPoints: 2000, want: "level 4"},
+		{name: "coarser level", maxPoints: 500, want: "level 16"},
+		{name: "no level fits", maxPoints: 50, want: "level 64"},
+		{name: "short range reads full trace", maxPoints: 500, tr: TimeRange{From: float64Ptr(1000), To: float64Ptr(1400)}, want: "full"},
+		{name: "partial range", maxPoints: 100, tr: TimeRange{To: float64Ptr(1800)}, want: "level 16"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakePyramidRepository{levels: levels}
+			manager := NewManager(&fakeSessionCache{sessions: map[string]*Session{}}, repo)
+
+			_, err := manager.savedSignalOverview(context.Background(), "session1", MetricTypeBPM, tt.tr, Downsampling{MaxPoints: tt.maxPoints})
+			if err != nil {
+				t.Fatalf("savedSignalOverview failed: %v", err)
+			}
+			if repo.fetched != tt.want {
+				t.Errorf("Expected points from %s, got %s", tt.want, repo.fetched)
+			}
+		})
+	}
+}
@@ -0,0 +1,399 @@
+package session
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/Krimson/fetal-monitory/receiver/internal/downsample"
+)
+
+// sampleRateHz - частота сигнала; в ее отсчетах feature extractor
+// возвращает начало и конец событий
+const sampleRateHz = 4.0
+
+// TimeRange ограничивает выборку по времени от начала записи (в секундах).
+// Нулевое значение - все данные
+type TimeRange struct {
+	From  *float64 // Включительно
+	To    *float64 // Включительно
+	Limit int      // Максимум элементов; 0 - без ограничения
+}
+
+// IsZero сообщает, что диапазон ничего не ограничивает
+func (tr TimeRange) IsZero() bool {
+	return tr.From == nil && tr.To == nil && tr.Limit <= 0
+}
+
+// Contains проверяет, попадает ли момент времени в диапазон
+func (tr TimeRange) Contains(sec float64) bool {
+	return (tr.From == nil || sec >= *tr.From) && (tr.To == nil || sec <= *tr.To)
+}
+
+// Overlaps проверяет, пересекается ли интервал [startSec, endSec] с диапазоном
+func (tr TimeRange) Overlaps(startSec, endSec float64) bool {
+	return (tr.From == nil || endSec >= *tr.From) && (tr.To == nil || startSec <= *tr.To)
+}
+
+// fetchLimit - сколько элементов запрашивать у хранилища: на один больше
+// лимита, чтобы понять, есть ли продолжение
+func (tr TimeRange) fetchLimit() int {
+	if tr.Limit <= 0 {
+		return 0
+	}
+	return tr.Limit + 1
+}
+
+// EventCursor - позиция в списке событий: начало, ID и тип последнего события страницы.
+// События упорядочены по (start_time, id, type): в PostgreSQL ID уникален, в Redis
+// у событий нет ID, но начало события уникально в пределах типа
+type EventCursor struct {
+	StartTime float64   `json:"t"`
+	ID        int64     `json:"id,omitempty"`
+	Type      EventType `json:"type"`
+}
+
+// Encode кодирует курсор в непрозрачную строку для клиента
+func (c EventCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeEventCursor разбирает курсор списка событий
+func DecodeEventCursor(s string) (*EventCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c EventCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Type == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// cursorOf возвращает курсор, указывающий на событие
+func (e SessionEvent) cursorOf() EventCursor {
+	return EventCursor{StartTime: e.StartTime, ID: e.ID, Type: e.Type}
+}
+
+// eventLess задает порядок событий (start_time, id, type)
+func eventLess(a, b SessionEvent) bool {
+	if a.StartTime != b.StartTime {
+		return a.StartTime < b.StartTime
+	}
+	if a.ID != b.ID {
+		return a.ID < b.ID
+	}
+	return a.Type < b.Type
+}
+
+// isAfter сообщает, что событие идет после курсора c (nil - с начала)
+func (e SessionEvent) isAfter(c *EventCursor) bool {
+	if c == nil {
+		return true
+	}
+	return eventLess(SessionEvent{StartTime: c.StartTime, ID: c.ID, Type: c.Type}, e)
+}
+
+// selectEvents отбирает события, начинающиеся в диапазоне и идущие после курсора,
+// упорядочивает их и ограничивает tr.fetchLimit()
+func selectEvents(all []SessionEvent, tr TimeRange, after *EventCursor) []SessionEvent {
+	events := make([]SessionEvent, 0, len(all))
+	for _, event := range all {
+		if tr.Contains(event.StartSec()) && event.isAfter(after) {
+			events = append(events, event)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return eventLess(events[i], events[j]) })
+
+	if limit := tr.fetchLimit(); limit > 0 && len(events) > limit {
+		events = events[:limit]
+	}
+	return events
+}
+
+// StartSec - начало события в секундах от начала записи
+func (e SessionEvent) StartSec() float64 {
+	return e.StartTime / sampleRateHz
+}
+
+// EndSec - конец события в секундах от начала записи
+func (e SessionEvent) EndSec() float64 {
+	return e.EndTime / sampleRateHz
+}
+
+// StartSec - начало окна точки временного ряда в секундах от начала записи
+func (p TimeSeriesPoint) StartSec() float64 {
+	return float64(p.TimeIndex) * p.WindowDuration
+}
+
+// rangeStore - выборки по диапазону времени; реализуются RedisStore
+// (активные сессии) и PostgresRepository (сохраненные)
+type rangeStore interface {
+	GetFilteredDataRange(ctx context.Context, sessionID string, metricType MetricType, tr TimeRange) ([]FilteredDataPoint, error)
+	GetEventsRange(ctx context.Context, sessionID string, tr TimeRange, after *EventCursor) ([]SessionEvent, error)
+	GetTimeSeriesRange(ctx context.Context, sessionID string, seriesType TimeSeriesType, tr TimeRange) ([]TimeSeriesPoint, error)
+}
+
+// SignalRange - отфильтрованный сигнал за диапазон времени
+type SignalRange struct {
+	SessionID string              `json:"session_id"`
+	Metric    MetricType          `json:"metric"`
+	Points    []FilteredDataPoint `json:"points"`
+	Count     int                 `json:"count"`
+	NextFrom  *float64            `json:"next_from,omitempty"` // from для следующей страницы, если лимит исчерпан
+	// Downsampled сообщает, что точки прорежены до max_points методом Method
+	Downsampled bool              `json:"downsampled,omitempty"`
+	Method      downsample.Method `json:"method,omitempty"`
+}
+
+// EventsRange - события за диапазон времени
+type EventsRange struct {
+	SessionID  string         `json:"session_id"`
+	Events     []SessionEvent `json:"events"`
+	Count      int            `json:"count"`
+	NextCursor string         `json:"next_cursor,omitempty"` // cursor для следующей страницы, если лимит исчерпан
+}
+
+// RangeContinuation - продолжение рядов /data, урезанных лимитом: from (для событий -
+// cursor) следующей страницы соответствующего эндпоинта (/fhr, /uc, /stv, /ltv, /events)
+type RangeContinuation struct {
+	FHRFrom      *float64 `json:"fhr_from,omitempty"`
+	UCFrom       *float64 `json:"uc_from,omitempty"`
+	STVFrom      *float64 `json:"stv_from,omitempty"`
+	LTVFrom      *float64 `json:"ltv_from,omitempty"`
+	EventsCursor string   `json:"events_cursor,omitempty"`
+}
+
+// TimeSeriesRange - окна STV/LTV за диапазон времени
+type TimeSeriesRange struct {
+	SessionID string            `json:"session_id"`
+	Type      TimeSeriesType    `json:"type"`
+	Points    []TimeSeriesPoint `json:"points"`
+	Count     int               `json:"count"`
+	NextFrom  *float64          `json:"next_from,omitempty"`
+}
+
+// rangeSource выбирает хранилище: Redis, пока сессия в кэше, иначе PostgreSQL.
+// cached сообщает, что выбран Redis
+func (m *Manager) rangeSource(ctx context.Context, sessionID string) (store rangeStore, cached bool, err error) {
+	if _, err := m.cache.GetSession(ctx, sessionID); err == nil {
+		return m.cache, true, nil
+	}
+	if _, err := m.repository.GetSession(ctx, sessionID); err != nil {
+		return nil, false, err
+	}
+	return m.repository, false, nil
+}
+
+// GetSignalRange возвращает отфильтрованный сигнал сессии за диапазон времени.
+// Если задан ds.MaxPoints, весь диапазон прореживается до этого числа точек
+// (лимит и постраничность при этом не применяются)
+func (m *Manager) GetSignalRange(ctx context.Context, sessionID string, metricType MetricType, tr TimeRange, ds Downsampling) (*SignalRange, error) {
+	store, cached, err := m.rangeSource(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if ds.MaxPoints > 0 {
+		return m.getSignalOverview(ctx, store, cached, sessionID, metricType, tr, ds)
+	}
+
+	points, err := store.GetFilteredDataRange(ctx, sessionID, metricType, tr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s data: %w", metricType, err)
+	}
+
+	result := &SignalRange{SessionID: sessionID, Metric: metricType, Points: points}
+	if tr.Limit > 0 && len(points) > tr.Limit {
+		next := points[tr.Limit].TimeSec
+		result.Points, result.NextFrom = points[:tr.Limit], &next
+	}
+	if result.Points == nil {
+		result.Points = []FilteredDataPoint{}
+	}
+	result.Count = len(result.Points)
+	return result, nil
+}
+
+// getSignalOverview возвращает прореженный сигнал: активные сессии прореживаются
+// из кэша целиком, сохраненные - из пирамиды
+func (m *Manager) getSignalOverview(ctx context.Context, store rangeStore, cached bool, sessionID string, metricType MetricType, tr TimeRange, ds Downsampling) (*SignalRange, error) {
+	tr.Limit = 0
+
+	var points []FilteredDataPoint
+	var err error
+	if cached {
+		points, err = store.GetFilteredDataRange(ctx, sessionID, metricType, tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s data: %w", metricType, err)
+		}
+		points = downsampleSignal(points, ds)
+	} else if points, err = m.savedSignalOverview(ctx, sessionID, metricType, tr, ds); err != nil {
+		return nil, err
+	}
+
+	if points == nil {
+		points = []FilteredDataPoint{}
+	}
+	return &SignalRange{
+		SessionID:   sessionID,
+		Metric:      metricType,
+		Points:      points,
+		Count:       len(points),
+		Downsampled: true,
+		Method:      ds.Method,
+	}, nil
+}
+
+// GetEventsRange возвращает события сессии, начинающиеся в диапазоне времени, в порядке
+// (start_time, id). after - курсор предыдущей страницы; eventType фильтрует по типу,
+// пустой - все события
+func (m *Manager) GetEventsRange(ctx context.Context, sessionID string, eventType EventType, tr TimeRange, after *EventCursor) (*EventsRange, error) {
+	store, _, err := m.rangeSource(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Лимит применяется после фильтра по типу
+	fetch := tr
+	if eventType != "" {
+		fetch.Limit = 0
+	}
+	events, err := store.GetEventsRange(ctx, sessionID, fetch, after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get events: %w", err)
+	}
+
+	result := &EventsRange{SessionID: sessionID, Events: make([]SessionEvent, 0, len(events))}
+	for _, e := range events {
+		if eventType == "" || e.Type == eventType {
+			result.Events = append(result.Events, e)
+		}
+	}
+	result.Events, result.NextCursor = pageEvents(result.Events, tr.Limit)
+	result.Count = len(result.Events)
+	return result, nil
+}
+
+// pageEvents урезает события до limit и возвращает курсор следующей страницы
+// (пустой, если продолжения нет)
+func pageEvents(events []SessionEvent, limit int) ([]SessionEvent, string) {
+	if limit <= 0 || len(events) <= limit {
+		return events, ""
+	}
+	return events[:limit], events[limit-1].cursorOf().Encode()
+}
+
+// GetTimeSeriesRange возвращает окна STV или LTV, начинающиеся в диапазоне времени
+func (m *Manager) GetTimeSeriesRange(ctx context.Context, sessionID string, seriesType TimeSeriesType, tr TimeRange) (*TimeSeriesRange, error) {
+	store, _, err := m.rangeSource(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	points, err := store.GetTimeSeriesRange(ctx, sessionID, seriesType, tr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s time series: %w", seriesType, err)
+	}
+
+	result := &TimeSeriesRange{SessionID: sessionID, Type: seriesType, Points: points}
+	if tr.Limit > 0 && len(points) > tr.Limit {
+		next := points[tr.Limit].StartSec()
+		result.Points, result.NextFrom = points[:tr.Limit], &next
+	}
+	if result.Points == nil {
+		result.Points = []TimeSeriesPoint{}
+	}
+	result.Count = len(result.Points)
+	return result, nil
+}
+
+// GetSessionDataRange возвращает данные сессии, где сигналы, события и временные
+// ряды ограничены диапазоном (лимит применяется к каждому из них отдельно).
+// Если лимит урезал ряд, в data.Next указано, откуда продолжить его чтение.
+// Если задан ds.MaxPoints, сигналы FHR и UC прореживаются вместо лимита
+func (m *Manager) GetSessionDataRange(ctx context.Context, sessionID string, tr TimeRange, ds Downsampling) (*SessionData, error) {
+	session, err := m.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	store, cached, err := m.rangeSource(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &SessionData{Session: session}
+	if cached {
+		data.Metrics, _ = m.cache.GetMetrics(ctx, sessionID) // Может не быть метрик
+		data.Alerts = m.liveAlerts(ctx, sessionID)
+	} else {
+		data.Metrics, _ = m.repository.GetMetrics(ctx, sessionID)
+		if data.Alerts, err = m.repository.GetAlerts(ctx, sessionID); err != nil {
+			return nil, fmt.Errorf("failed to get alerts: %w", err)
+		}
+	}
+
+	next := &RangeContinuation{}
+	nextFrom := func(n int, at func(i int) float64) (int, *float64) {
+		if tr.Limit <= 0 || n <= tr.Limit {
+			return n, nil
+		}
+		from := at(tr.Limit)
+		return tr.Limit, &from
+	}
+
+	events, err := store.GetEventsRange(ctx, sessionID, tr, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get events: %w", err)
+	}
+	data.Events, next.EventsCursor = pageEvents(events, tr.Limit)
+
+	for seriesType, dst := range map[TimeSeriesType]struct {
+		points *[]TimeSeriesPoint
+		from   **float64
+	}{
+		TimeSeriesTypeSTV: {&data.TimeSeriesSTV, &next.STVFrom},
+		TimeSeriesTypeLTV: {&data.TimeSeriesLTV, &next.LTVFrom},
+	} {
+		points, err := store.GetTimeSeriesRange(ctx, sessionID, seriesType, tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s time series: %w", seriesType, err)
+		}
+		n, from := nextFrom(len(points), func(i int) float64 { return points[i].StartSec() })
+		*dst.points, *dst.from = points[:n], from
+	}
+
+	for metricType, dst := range map[MetricType]struct {
+		points *[]FilteredDataPoint
+		from   **float64
+	}{
+		MetricTypeBPM:    {&data.FilteredBPMData, &next.FHRFrom},
+		MetricTypeUterus: {&data.FilteredUterusData, &next.UCFrom},
+	} {
+		if ds.MaxPoints > 0 {
+			overview, err := m.getSignalOverview(ctx, store, cached, sessionID, metricType, tr, ds)
+			if err != nil {
+				return nil, err
+			}
+			*dst.points = overview.Points
+			continue
+		}
+
+		points, err := store.GetFilteredDataRange(ctx, sessionID, metricType, tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s data: %w", metricType, err)
+		}
+		n, from := nextFrom(len(points), func(i int) float64 { return points[i].TimeSec })
+		*dst.points, *dst.from = points[:n], from
+	}
+
+	if *next != (RangeContinuation{}) {
+		data.Next = next
+	}
+
+	return data, nil
+}
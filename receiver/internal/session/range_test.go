@@ -0,0 +1,101 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestEventCursor_EncodeDecode(t *testing.T) {
+	want := EventCursor{StartTime: 2400, ID: 17, Type: EventTypeDeceleration}
+
+	got, err := DecodeEventCursor(want.Encode())
+	if err != nil {
+		t.Fatalf("DecodeEventCursor failed: %v", err)
+	}
+	if *got != want {
+		t.Errorf("Decoded %+v, want %+v", *got, want)
+	}
+
+	for _, bad := range []string{"not base64!", "e30", "bnVsbA"} {
+		if _, err := DecodeEventCursor(bad); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("DecodeEventCursor(%q) = %v, want ErrInvalidCursor", bad, err)
+		}
+	}
+}
+
+func TestSelectEvents_PaginationVisitsEachEventOnce(t *testing.T) {
+	// Длинные схватки перекрывают многие короткие события, у части событий одинаковое начало
+	var all []SessionEvent
+	for i := 0; i < 30; i++ {
+		start := float64(i/3) * 240 // По три события на одно начало
+		all = append(all, SessionEvent{
+			Type:      []EventType{EventTypeAcceleration, EventTypeDeceleration, EventTypeContraction}[i%3],
+			StartTime: start,
+			EndTime:   start + 4800, // 20 минут
+		})
+	}
+
+	from := 0.0
+	tr := TimeRange{From: &from, Limit: 4}
+	seen := make(map[EventCursor]int)
+	var after *EventCursor
+	for page := 0; ; page++ {
+		if page > len(all) {
+			t.Fatal("Pagination does not terminate")
+		}
+		events, next := pageEvents(selectEvents(all, tr, after), tr.Limit)
+		for _, e := range events {
+			seen[e.cursorOf()]++
+		}
+		if next == "" {
+			break
+		}
+		var err error
+		if after, err = DecodeEventCursor(next); err != nil {
+			t.Fatalf("Invalid next cursor: %v", err)
+		}
+	}
+
+	if len(seen) != len(all) {
+		t.Errorf("Visited %d distinct events, want %d", len(seen), len(all))
+	}
+	for c, n := range seen {
+		if n != 1 {
+			t.Errorf("Event %+v returned %d times", c, n)
+		}
+	}
+}
+
+func TestSelectEvents_FiltersByStart(t *testing.T) {
+	from, to := 60.0, 120.0
+	all := []SessionEvent{
+		{Type: EventTypeContraction, StartTime: 0, EndTime: 800},    // Началась до from
+		{Type: EventTypeDeceleration, StartTime: 240, EndTime: 400}, // 60 с
+		{Type: EventTypeAcceleration, StartTime: 600, EndTime: 700}, // После to
+	}
+
+	events := selectEvents(all, TimeRange{From: &from, To: &to}, nil)
+	if len(events) != 1 || events[0].Type != EventTypeDeceleration {
+		t.Errorf("Expected only the event starting in range, got %+v", events)
+	}
+}
+
+func TestPostgresGetEventsRange_UsesCursor(t *testing.T) {
+	repo, mock := newMockRepository(t)
+	from := 60.0
+	after := &EventCursor{StartTime: 240, ID: 7, Type: EventTypeDeceleration}
+
+	mock.ExpectQuery("FROM session_events .*start_time >= .*\\(start_time, id\\) > .*ORDER BY start_time ASC, id ASC").
+		WithArgs("session1", &from, nil, sampleRateHz, 3, 240.0, int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "session_id", "event_type", "start_time", "end_time", "duration", "amplitude", "is_late", "created_at"}))
+
+	if _, err := repo.GetEventsRange(context.Background(), "session1", TimeRange{From: &from, Limit: 2}, after); err != nil {
+		t.Fatalf("GetEventsRange failed: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/redis/go-redis/v9"
+
+	"github.com/Krimson/fetal-monitory/receiver/internal/alert"
 )
 
 // RedisStore реализует CacheStore для Redis (Infrastructure Layer)
@@ -73,6 +75,12 @@ func (r *RedisStore) GetSession(ctx context.Context, sessionID string) (*Session
 }
 
 func (r *RedisStore) DeleteSession(ctx context.Context, sessionID string) error {
+	// Индекс тревог лежит вне session:{id}:*, собираем его ключи до удаления
+	indexKeys, err := r.alertIndexKeys(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
 	// Удаляем все ключи, связанные с сессией
 	pattern := fmt.Sprintf("session:%s:*", sessionID)
 
@@ -82,12 +90,15 @@ func (r *RedisStore) DeleteSession(ctx context.Context, sessionID string) error
 	for iter.Next(ctx) {
 		pipe.Del(ctx, iter.Val())
 	}
+	for _, key := range indexKeys {
+		pipe.Del(ctx, key)
+	}
 
 	if err := iter.Err(); err != nil {
 		return fmt.Errorf("failed to scan keys: %w", err)
 	}
 
-	_, err := pipe.Exec(ctx)
+	_, err = pipe.Exec(ctx)
 	return err
 }
 
@@ -100,6 +111,11 @@ func (r *RedisStore) SessionExists(ctx context.Context, sessionID string) (bool,
 }
 
 func (r *RedisStore) SetSessionTTL(ctx context.Context, sessionID string, ttl int) error {
+	indexKeys, err := r.alertIndexKeys(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
 	pattern := fmt.Sprintf("session:%s:*", sessionID)
 	duration := time.Duration(ttl) * time.Second
 
@@ -109,15 +125,32 @@ func (r *RedisStore) SetSessionTTL(ctx context.Context, sessionID string, ttl in
 	for iter.Next(ctx) {
 		pipe.Expire(ctx, iter.Val(), duration)
 	}
+	for _, key := range indexKeys {
+		pipe.Expire(ctx, key, duration)
+	}
 
 	if err := iter.Err(); err != nil {
 		return fmt.Errorf("failed to scan keys: %w", err)
 	}
 
-	_, err := pipe.Exec(ctx)
+	_, err = pipe.Exec(ctx)
 	return err
 }
 
+// alertIndexKeys возвращает ключи индекса alert_id -> session_id тревог сессии
+func (r *RedisStore) alertIndexKeys(ctx context.Context, sessionID string) ([]string, error) {
+	alertIDs, err := r.client.HKeys(ctx, alert.SessionAlertsKey(sessionID)).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to list session alerts: %w", err)
+	}
+
+	keys := make([]string, len(alertIDs))
+	for i, alertID := range alertIDs {
+		keys[i] = alert.IndexKey(alertID)
+	}
+	return keys, nil
+}
+
 // ===== Метрики =====
 
 func (r *RedisStore) SetMetrics(ctx context.Context, metrics *SessionMetrics) error {
@@ -199,6 +232,9 @@ func (r *RedisStore) GetMetrics(ctx context.Context, sessionID string) (*Session
 	if val, ok := data["time_span_sec"]; ok {
 		metrics.TimeSpanSec, _ = strconv.ParseFloat(val, 64)
 	}
+	if val, ok := data["prediction"]; ok {
+		metrics.Prediction, _ = strconv.ParseFloat(val, 64)
+	}
 	if val, ok := data["updated_at"]; ok {
 		timestamp, _ := strconv.ParseInt(val, 10, 64)
 		metrics.UpdatedAt = time.Unix(timestamp, 0)
@@ -207,6 +243,11 @@ func (r *RedisStore) GetMetrics(ctx context.Context, sessionID string) (*Session
 	return metrics, nil
 }
 
+// SetPrediction обновляет только поле предсказания: SetMetrics его не перезаписывает
+func (r *RedisStore) SetPrediction(ctx context.Context, sessionID string, prediction float64) error {
+	return r.client.HSet(ctx, metricsKey(sessionID), "prediction", prediction).Err()
+}
+
 // ===== События =====
 
 func (r *RedisStore) AppendEvents(ctx context.Context, sessionID string, events []SessionEvent) error {
@@ -265,6 +306,17 @@ func (r *RedisStore) GetAllEvents(ctx context.Context, sessionID string) ([]Sess
 	return allEvents, nil
 }
 
+// GetEventsRange возвращает события всех типов, начинающиеся в диапазоне и идущие после
+// курсора, в порядке (start_time, id, type). События хранятся в списках без индекса
+// по времени, поэтому фильтруются на стороне receiver
+func (r *RedisStore) GetEventsRange(ctx context.Context, sessionID string, tr TimeRange, after *EventCursor) ([]SessionEvent, error) {
+	all, err := r.GetAllEvents(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return selectEvents(all, tr, after), nil
+}
+
 func (r *RedisStore) EventExists(ctx context.Context, sessionID string, eventType EventType, startTime float64) (bool, error) {
 	events, err := r.GetEvents(ctx, sessionID, eventType)
 	if err != nil {
@@ -321,6 +373,26 @@ func (r *RedisStore) GetTimeSeries(ctx context.Context, sessionID string, series
 	return points, nil
 }
 
+// GetTimeSeriesRange возвращает окна временного ряда, начинающиеся в диапазоне
+func (r *RedisStore) GetTimeSeriesRange(ctx context.Context, sessionID string, seriesType TimeSeriesType, tr TimeRange) ([]TimeSeriesPoint, error) {
+	all, err := r.GetTimeSeries(ctx, sessionID, seriesType)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]TimeSeriesPoint, 0, len(all))
+	limit := tr.fetchLimit()
+	for _, point := range all {
+		if limit > 0 && len(points) == limit {
+			break
+		}
+		if tr.Contains(point.StartSec()) {
+			points = append(points, point)
+		}
+	}
+	return points, nil
+}
+
 func (r *RedisStore) GetTimeSeriesCount(ctx context.Context, sessionID string, seriesType TimeSeriesType) (int, error) {
 	key := timeSeriesKey(sessionID, seriesType)
 	count, err := r.client.LLen(ctx, key).Result()
@@ -379,6 +451,38 @@ func (r *RedisStore) GetFilteredData(ctx context.Context, sessionID string, metr
 	return points, nil
 }
 
+// GetFilteredDataRange возвращает точки сигнала с time_sec в диапазоне через ZRangeByScore
+func (r *RedisStore) GetFilteredDataRange(ctx context.Context, sessionID string, metricType MetricType, tr TimeRange) ([]FilteredDataPoint, error) {
+	key := filteredDataKey(sessionID, metricType)
+
+	opt := &redis.ZRangeBy{Min: "-inf", Max: "+inf"}
+	if tr.From != nil {
+		opt.Min = strconv.FormatFloat(*tr.From, 'f', -1, 64)
+	}
+	if tr.To != nil {
+		opt.Max = strconv.FormatFloat(*tr.To, 'f', -1, 64)
+	}
+	if limit := tr.fetchLimit(); limit > 0 {
+		opt.Count = int64(limit)
+	}
+
+	data, err := r.client.ZRangeByScore(ctx, key, opt).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get filtered data: %w", err)
+	}
+
+	points := make([]FilteredDataPoint, 0, len(data))
+	for _, item := range data {
+		var point FilteredDataPoint
+		if err := json.Unmarshal([]byte(item), &point); err != nil {
+			continue
+		}
+		points = append(points, point)
+	}
+
+	return points, nil
+}
+
 func (r *RedisStore) GetFilteredDataCount(ctx context.Context, sessionID string, metricType MetricType) (int, error) {
 	key := filteredDataKey(sessionID, metricType)
 	count, err := r.client.ZCard(ctx, key).Result()
@@ -0,0 +1,120 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Krimson/fetal-monitory/logging"
+	"github.com/Krimson/fetal-monitory/receiver/internal/ctg"
+	"github.com/Krimson/fetal-monitory/receiver/internal/report"
+)
+
+// BuildReport собирает данные отчета КТГ по сохраненной в PostgreSQL сессии
+func (m *Manager) BuildReport(ctx context.Context, sessionID string) (*report.Data, error) {
+	session, err := m.repository.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &report.Data{
+		SessionID:   session.ID,
+		Status:      string(session.Status),
+		StartedAt:   session.StartedAt,
+		StoppedAt:   session.StoppedAt,
+		SavedAt:     session.SavedAt,
+		DurationMs:  session.TotalDurationMs,
+		PatientID:   session.Metadata.PatientID,
+		DoctorID:    session.Metadata.DoctorID,
+		FacilityID:  session.Metadata.FacilityID,
+		Notes:       session.Metadata.Notes,
+		GeneratedAt: time.Now(),
+	}
+
+	events, err := m.repository.GetEvents(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get events: %w", err)
+	}
+	for _, e := range events {
+		data.Events = append(data.Events, report.Event{
+			Type:      string(e.Type),
+			StartSec:  e.StartSec(),
+			EndSec:    e.EndSec(),
+			Duration:  e.Duration,
+			Amplitude: e.Amplitude,
+			IsLate:    e.IsLate,
+		})
+	}
+
+	ltv, err := m.repository.GetTimeSeries(ctx, sessionID, TimeSeriesTypeLTV)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s time series: %w", TimeSeriesTypeLTV, err)
+	}
+
+	// Метрик может не быть, если сессию сохранили до первого батча признаков
+	if metrics, err := m.repository.GetMetrics(ctx, sessionID); err == nil {
+		data.Summary = &report.Summary{
+			STV:                metrics.STV,
+			LTV:                metrics.LTV,
+			BaselineHeartRate:  metrics.BaselineHeartRate,
+			TotalAccelerations: metrics.TotalAccelerations,
+			TotalDecelerations: metrics.TotalDecelerations,
+			LateDecelerations:  metrics.LateDecelerations,
+			TotalContractions:  metrics.TotalContractions,
+			TimeSpanSec:        metrics.TimeSpanSec,
+			Prediction:         metrics.Prediction,
+		}
+		data.Classification = ctg.Classify(ClassificationInput(metrics, events, ltv))
+	} else {
+		slog.Warn("No metrics for report", logging.SessionID(sessionID), logging.Err(err))
+	}
+
+	if data.STV, err = m.reportSeries(ctx, sessionID, TimeSeriesTypeSTV); err != nil {
+		return nil, err
+	}
+	data.LTV = toReportSeries(ltv)
+
+	if data.FHR, err = m.reportTrace(ctx, sessionID, MetricTypeBPM); err != nil {
+		return nil, err
+	}
+	if data.UC, err = m.reportTrace(ctx, sessionID, MetricTypeUterus); err != nil {
+		return nil, err
+	}
+
+	if data.Alerts, err = m.repository.GetAlerts(ctx, sessionID); err != nil {
+		return nil, fmt.Errorf("failed to get alerts: %w", err)
+	}
+
+	return data, nil
+}
+
+func (m *Manager) reportSeries(ctx context.Context, sessionID string, seriesType TimeSeriesType) (report.Series, error) {
+	points, err := m.repository.GetTimeSeries(ctx, sessionID, seriesType)
+	if err != nil {
+		return report.Series{}, fmt.Errorf("failed to get %s time series: %w", seriesType, err)
+	}
+	return toReportSeries(points), nil
+}
+
+func toReportSeries(points []TimeSeriesPoint) report.Series {
+	series := report.Series{Values: make([]float64, 0, len(points))}
+	for _, p := range points {
+		series.Values = append(series.Values, p.Value)
+		series.WindowSec = p.WindowDuration
+	}
+	return series
+}
+
+func (m *Manager) reportTrace(ctx context.Context, sessionID string, metricType MetricType) ([]report.Point, error) {
+	points, err := m.repository.GetFilteredData(ctx, sessionID, metricType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s data: %w", metricType, err)
+	}
+
+	trace := make([]report.Point, 0, len(points))
+	for _, p := range points {
+		trace = append(trace, report.Point{TimeSec: p.TimeSec, Value: p.Value})
+	}
+	return trace, nil
+}
@@ -2,6 +2,8 @@ package session
 
 import (
 	"context"
+
+	"github.com/Krimson/fetal-monitory/receiver/internal/alert"
 )
 
 // Repository определяет интерфейс для работы с хранилищем сессий (Domain Layer)
@@ -10,9 +12,13 @@ type Repository interface {
 	CreateSession(ctx context.Context, session *Session) error
 	GetSession(ctx context.Context, sessionID string) (*Session, error)
 	UpdateSession(ctx context.Context, session *Session) error
-	ListSessions(ctx context.Context, limit, offset int) ([]*Session, error)
+	ListSessions(ctx context.Context, filter SessionFilter) ([]*Session, error)
 	DeleteSession(ctx context.Context, sessionID string) error
 
+	// Привязка сессий к карточкам пациенток
+	PatientExists(ctx context.Context, patientID string) (bool, error)
+	SetSessionPatient(ctx context.Context, sessionID, patientID string) error
+
 	// Работа с метриками
 	SaveMetrics(ctx context.Context, metrics *SessionMetrics) error
 	GetMetrics(ctx context.Context, sessionID string) (*SessionMetrics, error)
@@ -20,13 +26,41 @@ type Repository interface {
 	// Работа с событиями
 	SaveEvents(ctx context.Context, events []SessionEvent) error
 	GetEvents(ctx context.Context, sessionID string) ([]SessionEvent, error)
+	GetEventsRange(ctx context.Context, sessionID string, tr TimeRange, after *EventCursor) ([]SessionEvent, error)
 
 	// Работа с временными рядами
 	SaveTimeSeries(ctx context.Context, points []TimeSeriesPoint) error
 	GetTimeSeries(ctx context.Context, sessionID string, seriesType TimeSeriesType) ([]TimeSeriesPoint, error)
+	GetTimeSeriesRange(ctx context.Context, sessionID string, seriesType TimeSeriesType, tr TimeRange) ([]TimeSeriesPoint, error)
+
+	// Отфильтрованные сигналы (session_raw_data)
+	GetFilteredData(ctx context.Context, sessionID string, metricType MetricType) ([]FilteredDataPoint, error)
+	GetFilteredDataRange(ctx context.Context, sessionID string, metricType MetricType, tr TimeRange) ([]FilteredDataPoint, error)
+
+	// Пирамиды прореженных трасс для обзорных графиков
+	SaveSignalPyramid(ctx context.Context, sessionID string, metricType MetricType, levels []PyramidLevel) error
+	GetPyramidLevels(ctx context.Context, sessionID string, metricType MetricType) ([]PyramidLevelInfo, error)
+	GetPyramidLevel(ctx context.Context, sessionID string, metricType MetricType, bucketSec float64, tr TimeRange) ([]FilteredDataPoint, error)
+
+	// Сырые сэмплы телеметрии (append-only, в порядке поступления)
+	AppendRawSamples(ctx context.Context, sessionID string, samples []RawSample) error
+	GetRawSamples(ctx context.Context, sessionID string) ([]RawSample, error)
+
+	// Работа с тревогами и их журналом
+	SaveAlerts(ctx context.Context, alerts []*alert.Alert) error
+	GetAlerts(ctx context.Context, sessionID string) ([]*alert.Alert, error)
 
 	// Сохранение полных данных сессии
 	SaveSessionData(ctx context.Context, data *SessionData) error
+	ImportSessionData(ctx context.Context, data *SessionData, raw []RawSample) error
+}
+
+// AlertService определяет жизненный цикл клинических тревог (реализуется alert.Engine)
+type AlertService interface {
+	Alerts(ctx context.Context, sessionID string) ([]*alert.Alert, error)
+	Acknowledge(ctx context.Context, alertID, user, comment string) (*alert.Alert, error)
+	Resolve(ctx context.Context, alertID, user, comment string) (*alert.Alert, error)
+	Forget(sessionID string)
 }
 
 // CacheStore определяет интерфейс для работы с кэшем (Redis)
@@ -39,21 +73,25 @@ type CacheStore interface {
 	// Метрики (перезаписываются целиком)
 	SetMetrics(ctx context.Context, metrics *SessionMetrics) error
 	GetMetrics(ctx context.Context, sessionID string) (*SessionMetrics, error)
+	SetPrediction(ctx context.Context, sessionID string, prediction float64) error
 
 	// События (append-only)
 	AppendEvents(ctx context.Context, sessionID string, events []SessionEvent) error
 	GetEvents(ctx context.Context, sessionID string, eventType EventType) ([]SessionEvent, error)
 	GetAllEvents(ctx context.Context, sessionID string) ([]SessionEvent, error)
+	GetEventsRange(ctx context.Context, sessionID string, tr TimeRange, after *EventCursor) ([]SessionEvent, error)
 	EventExists(ctx context.Context, sessionID string, eventType EventType, startTime float64) (bool, error)
 
 	// Временные ряды (append-only)
 	AppendTimeSeries(ctx context.Context, sessionID string, seriesType TimeSeriesType, points []TimeSeriesPoint) error
 	GetTimeSeries(ctx context.Context, sessionID string, seriesType TimeSeriesType) ([]TimeSeriesPoint, error)
+	GetTimeSeriesRange(ctx context.Context, sessionID string, seriesType TimeSeriesType, tr TimeRange) ([]TimeSeriesPoint, error)
 	GetTimeSeriesCount(ctx context.Context, sessionID string, seriesType TimeSeriesType) (int, error)
 
 	// Отфильтрованные данные (обновляются через Sorted Set)
 	UpdateFilteredData(ctx context.Context, sessionID string, metricType MetricType, points []FilteredDataPoint) error
 	GetFilteredData(ctx context.Context, sessionID string, metricType MetricType) ([]FilteredDataPoint, error)
+	GetFilteredDataRange(ctx context.Context, sessionID string, metricType MetricType, tr TimeRange) ([]FilteredDataPoint, error)
 	GetFilteredDataCount(ctx context.Context, sessionID string, metricType MetricType) (int, error)
 
 	// Получение всех данных сессии
@@ -4,6 +4,9 @@ import (
 	"time"
 
 	featureextractorv1 "github.com/Krimson/fetal-monitory/proto/feature_extractor"
+	telemetryv1 "github.com/Krimson/fetal-monitory/proto/telemetry"
+	"github.com/Krimson/fetal-monitory/receiver/internal/alert"
+	"github.com/Krimson/fetal-monitory/receiver/internal/ctg"
 )
 
 // SessionStatus представляет статус сессии
@@ -53,7 +56,11 @@ type SessionMetrics struct {
 	BPMTrend              float64   `json:"bpm_trend"`
 	DataPoints            int32     `json:"data_points"`
 	TimeSpanSec           float64   `json:"time_span_sec"`
+	Prediction            float64   `json:"prediction"` // Последнее предсказание ML сервиса
 	UpdatedAt             time.Time `json:"updated_at"`
+
+	// Классификация FIGO/NICE (вычисляется при запросе, не хранится)
+	Classification *ctg.Classification `json:"classification,omitempty"`
 }
 
 // EventType представляет тип события
@@ -101,6 +108,13 @@ type FilteredDataPoint struct {
 	Value   float64 `json:"value"`
 }
 
+// RawSample - сырой сэмпл телеметрии в том виде, в каком его прислало устройство
+type RawSample struct {
+	TsMS   int64              `json:"ts_ms"`
+	Metric telemetryv1.Metric `json:"metric"`
+	Value  float32            `json:"value"`
+}
+
 // MetricType представляет тип метрики
 type MetricType string
 
@@ -118,6 +132,10 @@ type SessionData struct {
 	TimeSeriesLTV      []TimeSeriesPoint   `json:"time_series_ltv"`
 	FilteredBPMData    []FilteredDataPoint `json:"filtered_bpm_data"`
 	FilteredUterusData []FilteredDataPoint `json:"filtered_uterus_data"`
+	Alerts             []*alert.Alert      `json:"alerts"`
+
+	// Next - продолжение рядов, урезанных limit (только для выборки по диапазону)
+	Next *RangeContinuation `json:"next,omitempty"`
 }
 
 // CreateSessionRequest представляет запрос на создание сессии
@@ -136,11 +154,29 @@ type SessionResponse struct {
 	Metrics *SessionMetrics `json:"metrics,omitempty"`
 }
 
+// SetSessionPatientRequest представляет запрос на привязку сессии к карточке пациентки
+type SetSessionPatientRequest struct {
+	PatientID string `json:"patient_id"`
+}
+
 // SaveSessionRequest представляет запрос на сохранение сессии
 type SaveSessionRequest struct {
 	Notes string `json:"notes,omitempty"`
 }
 
+// AlertActionRequest представляет запрос на подтверждение или закрытие тревоги
+type AlertActionRequest struct {
+	User    string `json:"user"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// AlertsResponse представляет список тревог сессии
+type AlertsResponse struct {
+	SessionID string         `json:"session_id"`
+	Alerts    []*alert.Alert `json:"alerts"`
+	Count     int            `json:"count"`
+}
+
 // ConvertFromFeatureResponse преобразует ответ от feature extractor
 func ConvertFromFeatureResponse(response *featureextractorv1.ProcessBatchResponse) *SessionMetrics {
 	return &SessionMetrics{
@@ -162,6 +198,36 @@ func ConvertFromFeatureResponse(response *featureextractorv1.ProcessBatchRespons
 	}
 }
 
+// ClassificationInput собирает входные данные классификатора КТГ из метрик, событий
+// и окон LTV сессии (окна нужны, чтобы оценить, как долго держится отклонение вариабельности)
+func ClassificationInput(metrics *SessionMetrics, events []SessionEvent, ltv []TimeSeriesPoint) ctg.Input {
+	in := ctg.Input{
+		BaselineHeartRate:  metrics.BaselineHeartRate,
+		STV:                metrics.STV,
+		LTV:                metrics.LTV,
+		TotalAccelerations: metrics.TotalAccelerations,
+		TotalContractions:  metrics.TotalContractions,
+		LateDecelerations:  metrics.LateDecelerations,
+		LTVWindows:         make([]float64, 0, len(ltv)),
+	}
+	for _, p := range ltv {
+		in.LTVWindows = append(in.LTVWindows, p.Value)
+		in.LTVWindowSec = p.WindowDuration
+	}
+	for _, event := range events {
+		if event.Type == EventTypeDeceleration {
+			in.Decelerations = append(in.Decelerations, ctg.Deceleration{
+				StartSec:  event.StartSec(),
+				EndSec:    event.EndSec(),
+				Duration:  event.Duration,
+				Amplitude: event.Amplitude,
+				IsLate:    event.IsLate,
+			})
+		}
+	}
+	return in
+}
+
 // ConvertAccelerations преобразует акселерации из протобуфа
 func ConvertAccelerations(sessionID string, accelerations []*featureextractorv1.Acceleration) []SessionEvent {
 	events := make([]SessionEvent, 0, len(accelerations))
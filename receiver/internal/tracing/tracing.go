@@ -0,0 +1,120 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc/filters"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+
+	"github.com/Krimson/fetal-monitory/receiver/internal/config"
+)
+
+// Атрибуты спанов конвейера
+const (
+	AttrSessionID = attribute.Key("session.id")
+	AttrMetric    = attribute.Key("batch.metric")
+	AttrPoints    = attribute.Key("batch.points")
+	AttrT0MS      = attribute.Key("batch.t0_ms")
+	AttrT1MS      = attribute.Key("batch.t1_ms")
+)
+
+// Setup настраивает глобальный TracerProvider и W3C propagator (traceparent, tracestate, baggage).
+// Propagator включается при любом экспортере, чтобы контекст трассировки доходил
+// до Python сервисов, даже если receiver сам спаны не записывает.
+// Возвращает функцию, которая дописывает оставшиеся спаны при остановке
+func Setup(ctx context.Context, cfg *config.Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.TracingExporter {
+	case config.TracingExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case config.TracingExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case config.TracingExporterOTLP:
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.TracingOTLPEndpoint)}
+		if cfg.TracingOTLPInsecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err = otlptracegrpc.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter: %q", cfg.TracingExporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s exporter: %w", cfg.TracingExporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(attribute.String("service.name", cfg.TracingServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build tracing resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// Решение о записи принимается на корневом спане батча и наследуется всеми стадиями
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.TracingSampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Inject сохраняет контекст трассировки ctx в виде W3C заголовков
+// (для передачи вместе с данными, например в батче очереди)
+func Inject(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+// Extract восстанавливает контекст трассировки, сохраненный Inject
+func Extract(ctx context.Context, carrier map[string]string) context.Context {
+	if len(carrier) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(carrier))
+}
+
+// WithSpanContext возвращает ctx с родительским спаном sc
+// (для передачи трассы через каналы конвейера)
+func WithSpanContext(ctx context.Context, sc trace.SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return trace.ContextWithSpanContext(ctx, sc)
+}
+
+// ClientHandler - опция gRPC клиента: спан на каждый вызов и передача контекста
+// трассировки в metadata. Вызовы grpc.health.v1 не трассируются
+func ClientHandler() grpc.DialOption {
+	return grpc.WithStatsHandler(otelgrpc.NewClientHandler(
+		otelgrpc.WithFilter(filters.Not(filters.HealthCheck())),
+	))
+}
+
+// ServerHandler - опция gRPC сервера: спан на каждый вызов с родителем из metadata
+func ServerHandler() grpc.ServerOption {
+	return grpc.StatsHandler(otelgrpc.NewServerHandler(
+		otelgrpc.WithFilter(filters.Not(filters.HealthCheck())),
+	))
+}
@@ -3,11 +3,21 @@ package websocket
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
+	"sort"
 	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/Krimson/fetal-monitory/audit"
+	"github.com/Krimson/fetal-monitory/auth"
+	"github.com/Krimson/fetal-monitory/logging"
 	featureextractorv1 "github.com/Krimson/fetal-monitory/proto/feature_extractor"
+	"github.com/Krimson/fetal-monitory/receiver/internal/alert"
+	"github.com/Krimson/fetal-monitory/receiver/internal/ctg"
 	"github.com/gorilla/websocket"
 )
 
@@ -16,23 +26,50 @@ type Hub struct {
 	// Зарегистрированные клиенты
 	clients map[*Client]bool
 
-	// Канал для регистрации клиентов
-	register chan *Client
+	// Подписки: session_id -> клиенты, которые получают данные этой сессии
+	sessions map[string]map[*Client]bool
 
 	// Канал для отмены регистрации клиентов
 	unregister chan *Client
 
-	// Канал для входящих сообщений от клиентов
-	broadcast chan []byte
+	// Канал для сообщений, адресованных подписчикам сессии
+	broadcast chan *sessionMessage
 
-	// Мютекс для безопасной работы с картой клиентов
+	// Мютекс для безопасной работы с картами клиентов и подписок
 	mu sync.RWMutex
 
 	// Последние предикты для каждой сессии (session_id -> prediction)
 	lastPredictions map[string]float64
 	predMu          sync.RWMutex
+
+	// Проверка Origin при подключении
+	upgrader websocket.Upgrader
+
+	// Журнал доступа к сессиям (необязателен)
+	auditor Auditor
 }
 
+// Auditor записывает подписки на сессии в журнал доступа (см. пакет audit)
+type Auditor interface {
+	// Record ставит запись в очередь; ошибка - запись потеряна
+	Record(ctx context.Context, e *audit.Entry) error
+	// Err возвращает ошибку, пока журнал не может сохранять записи
+	Err() error
+}
+
+// auditTimeout - сколько подписка ждет места в очереди журнала
+const auditTimeout = 5 * time.Second
+
+// maxSessionIDLength - ширина sessions.id (VARCHAR(64)): более длинный идентификатор
+// не может принадлежать сессии
+const maxSessionIDLength = 64
+
+var (
+	// errAuditUnavailable - подписка отклонена: без записи в журнал доступа данные не отдаются
+	errAuditUnavailable = errors.New("audit log unavailable")
+	errInvalidSessionID = errors.New("invalid session id")
+)
+
 // Client представляет WebSocket клиента
 type Client struct {
 	hub *Hub
@@ -43,17 +80,59 @@ type Client struct {
 	// Буферизованный канал исходящих сообщений
 	send chan []byte
 
-	// ID сессии для фильтрации данных
+	// ID сессии из query-параметра (начальная подписка)
+	sessionID string
+
+	// Сессии, на которые подписан клиент (защищено hub.mu)
+	subscriptions map[string]bool
+
+	// Кто подключился (для журнала доступа)
+	actor      string
+	role       string
+	remoteAddr string
+}
+
+// sessionMessage - сообщение для рассылки подписчикам одной сессии
+type sessionMessage struct {
 	sessionID string
+	data      []byte
+}
+
+// Действия управляющих сообщений от клиента
+const (
+	ActionSubscribe   = "subscribe"
+	ActionUnsubscribe = "unsubscribe"
+	ActionList        = "list"
+)
+
+// ControlMessage - управляющее сообщение, которое клиент отправляет через WebSocket
+// Пример: {"action": "subscribe", "session_ids": ["bed-1", "bed-2"]}
+type ControlMessage struct {
+	Action     string   `json:"action"`
+	SessionIDs []string `json:"session_ids"`
+}
+
+// SubscriptionsMessage - ответ клиенту с текущим списком подписок
+type SubscriptionsMessage struct {
+	Type       string   `json:"type"`
+	SessionIDs []string `json:"session_ids"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// AlertMessage - уведомление о клинической тревоге (поднята, подтверждена или снята)
+type AlertMessage struct {
+	Type  string       `json:"type"`
+	Alert *alert.Alert `json:"alert"`
 }
 
 // ProcessedData представляет данные для отправки на фронтенд в новом формате
 type ProcessedData struct {
-	Message    string      `json:"message"`
-	Prediction float64     `json:"prediction"` // Заглушка для ML модели
-	Records    RecordsData `json:"records"`
-	SessionID  string      `json:"session_id"`
-	Status     string      `json:"status"`
+	Message        string              `json:"message"`
+	Prediction     float64             `json:"prediction"`     // Заглушка для ML модели
+	Classification *ctg.Classification `json:"classification"` // Объяснимая оценка по правилам FIGO/NICE
+	Records        RecordsData         `json:"records"`
+	SessionID      string              `json:"session_id"`
+	Status         string              `json:"status"`
 }
 
 // RecordsData содержит все медицинские метрики
@@ -115,75 +194,201 @@ type Contraction struct {
 	Amplitude float64 `json:"amplitude"`
 }
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		// В продакшене следует проверять домен
-		return true
-	},
-}
-
 // NewHub создает новый Hub
 func NewHub() *Hub {
 	return &Hub{
 		clients:         make(map[*Client]bool),
-		register:        make(chan *Client),
+		sessions:        make(map[string]map[*Client]bool),
 		unregister:      make(chan *Client),
-		broadcast:       make(chan []byte),
+		broadcast:       make(chan *sessionMessage, 256),
 		lastPredictions: make(map[string]float64),
+		upgrader: websocket.Upgrader{
+			// По умолчанию подключения принимаются с любого Origin; см. SetCheckOrigin
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
 	}
 }
 
+// SetCheckOrigin задает проверку Origin браузерных подключений
+func (h *Hub) SetCheckOrigin(check func(r *http.Request) bool) {
+	h.upgrader.CheckOrigin = check
+}
+
+// SetAuditor включает запись подписок на сессии в журнал доступа
+func (h *Hub) SetAuditor(auditor Auditor) {
+	h.auditor = auditor
+}
+
 // Run запускает Hub
 func (h *Hub) Run() {
 	for {
 		select {
-		case client := <-h.register:
-			h.mu.Lock()
-			h.clients[client] = true
-			h.mu.Unlock()
-			log.Printf("[WEBSOCKET] Client registered: %p, session: %s", client, client.sessionID)
-
 		case client := <-h.unregister:
 			h.mu.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-			}
+			h.removeClientLocked(client)
 			h.mu.Unlock()
-			log.Printf("[WEBSOCKET] Client unregistered: %p", client)
+			slog.Info("Client unregistered", "client", client.remoteAddr)
 
 		case message := <-h.broadcast:
-			h.mu.RLock()
-			for client := range h.clients {
+			h.mu.Lock()
+			for client := range h.sessions[message.sessionID] {
 				select {
-				case client.send <- message:
+				case client.send <- message.data:
 				default:
-					delete(h.clients, client)
-					close(client.send)
+					// Клиент не успевает читать - отключаем его
+					h.removeClientLocked(client)
 				}
 			}
-			h.mu.RUnlock()
+			h.mu.Unlock()
 		}
 	}
 }
 
-// BroadcastProcessedData отправляет обработанные данные всем клиентам
+// registerClient регистрирует клиента синхронно, чтобы управляющие сообщения
+// из readPump не могли опередить регистрацию
+func (h *Hub) registerClient(client *Client) {
+	h.mu.Lock()
+	h.clients[client] = true
+	if client.sessionID != "" {
+		h.addSubscriptionLocked(client, client.sessionID)
+	}
+	h.mu.Unlock()
+	slog.Info("Client registered", "client", client.remoteAddr, logging.SessionID(client.sessionID))
+}
+
+// removeClientLocked удаляет клиента и все его подписки (вызывается под h.mu)
+func (h *Hub) removeClientLocked(client *Client) {
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+	for sessionID := range client.subscriptions {
+		h.removeSubscriptionLocked(client, sessionID)
+	}
+	delete(h.clients, client)
+	close(client.send)
+}
+
+// addSubscriptionLocked подписывает клиента на сессию (вызывается под h.mu)
+func (h *Hub) addSubscriptionLocked(client *Client, sessionID string) {
+	subscribers, ok := h.sessions[sessionID]
+	if !ok {
+		subscribers = make(map[*Client]bool)
+		h.sessions[sessionID] = subscribers
+	}
+	subscribers[client] = true
+	client.subscriptions[sessionID] = true
+}
+
+// removeSubscriptionLocked отписывает клиента от сессии (вызывается под h.mu)
+func (h *Hub) removeSubscriptionLocked(client *Client, sessionID string) {
+	delete(client.subscriptions, sessionID)
+	if subscribers, ok := h.sessions[sessionID]; ok {
+		delete(subscribers, client)
+		if len(subscribers) == 0 {
+			delete(h.sessions, sessionID)
+		}
+	}
+}
+
+// Subscribe подписывает клиента на указанные сессии и возвращает актуальный список подписок
+func (h *Hub) Subscribe(client *Client, sessionIDs []string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.clients[client]; !ok {
+		return nil
+	}
+	for _, sessionID := range sessionIDs {
+		if sessionID != "" {
+			h.addSubscriptionLocked(client, sessionID)
+		}
+	}
+	return client.subscriptionListLocked()
+}
+
+// Unsubscribe отписывает клиента от указанных сессий и возвращает актуальный список подписок
+func (h *Hub) Unsubscribe(client *Client, sessionIDs []string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.clients[client]; !ok {
+		return nil
+	}
+	for _, sessionID := range sessionIDs {
+		h.removeSubscriptionLocked(client, sessionID)
+	}
+	return client.subscriptionListLocked()
+}
+
+// Subscriptions возвращает список сессий, на которые подписан клиент
+func (h *Hub) Subscriptions(client *Client) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return client.subscriptionListLocked()
+}
+
+// SubscriberCount возвращает количество клиентов, подписанных на сессию
+func (h *Hub) SubscriberCount(sessionID string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.sessions[sessionID])
+}
+
+// ClientCount возвращает количество подключенных клиентов
+func (h *Hub) ClientCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// subscriptionListLocked возвращает отсортированный список подписок (вызывается под h.mu)
+func (c *Client) subscriptionListLocked() []string {
+	ids := make([]string, 0, len(c.subscriptions))
+	for sessionID := range c.subscriptions {
+		ids = append(ids, sessionID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// BroadcastProcessedData отправляет обработанные данные клиентам, подписанным на сессию
 func (h *Hub) BroadcastProcessedData(response *featureextractorv1.ProcessBatchResponse) {
 	data := h.convertResponseToProcessedData(response)
 
 	message, err := json.Marshal(data)
 	if err != nil {
-		log.Printf("[ERROR] Failed to marshal processed data: %v", err)
+		slog.Error("Failed to marshal processed data", logging.Err(err))
+		return
+	}
+
+	h.BroadcastToSession(response.SessionId, message)
+}
+
+// BroadcastToSession отправляет готовое сообщение подписчикам указанной сессии
+func (h *Hub) BroadcastToSession(sessionID string, message []byte) {
+	// Нет подписчиков - не тратим место в канале
+	if h.SubscriberCount(sessionID) == 0 {
 		return
 	}
 
 	select {
-	case h.broadcast <- message:
+	case h.broadcast <- &sessionMessage{sessionID: sessionID, data: message}:
 	default:
-		log.Printf("[WARN] Broadcast channel full, dropping message")
+		slog.Warn("Broadcast channel full, dropping message", logging.SessionID(sessionID))
 	}
 }
 
+// NotifyAlert отправляет изменение состояния тревоги подписчикам сессии
+func (h *Hub) NotifyAlert(a *alert.Alert) {
+	message, err := json.Marshal(AlertMessage{Type: "alert", Alert: a})
+	if err != nil {
+		slog.Error("Failed to marshal alert", logging.Err(err))
+		return
+	}
+
+	h.BroadcastToSession(a.SessionID, message)
+}
+
 // GetLastPrediction возвращает последнее предсказание для сессии
 func (h *Hub) GetLastPrediction(sessionID string) float64 {
 	h.predMu.RLock()
@@ -196,7 +401,7 @@ func (h *Hub) UpdatePrediction(sessionID string, prediction float64) {
 	h.predMu.Lock()
 	defer h.predMu.Unlock()
 	h.lastPredictions[sessionID] = prediction
-	log.Printf("[WEBSOCKET] Updated prediction for session %s: %.4f", sessionID, prediction)
+	slog.Debug("Updated prediction", logging.SessionID(sessionID), "prediction", prediction)
 }
 
 // convertResponseToProcessedData конвертирует gRPC ответ в JSON структуру нового формата
@@ -254,10 +459,11 @@ func (h *Hub) convertResponseToProcessedData(response *featureextractorv1.Proces
 	prediction := h.GetLastPrediction(response.SessionId)
 
 	data := &ProcessedData{
-		Message:    "Done",
-		Prediction: prediction, // Реальный предикт из ML сервиса
-		SessionID:  response.SessionId,
-		Status:     "processed",
+		Message:        "Done",
+		Prediction:     prediction, // Реальный предикт из ML сервиса
+		Classification: ctg.Classify(ctg.FromFeatureResponse(response)),
+		SessionID:      response.SessionId,
+		Status:         "processed",
 		Records: RecordsData{
 			STV:                   response.Stv,
 			LTV:                   response.Ltv,
@@ -295,32 +501,59 @@ func (h *Hub) convertResponseToProcessedData(response *featureextractorv1.Proces
 
 // HandleWebSocket обрабатывает WebSocket соединения
 func (h *Hub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Printf("[ERROR] Failed to upgrade connection: %v", err)
-		return
+	// session_id необязателен: клиент может подписаться позже управляющим сообщением
+	sessionID := r.URL.Query().Get("session_id")
+
+	client := &Client{
+		hub:           h,
+		send:          make(chan []byte, 256),
+		sessionID:     sessionID,
+		subscriptions: make(map[string]bool),
+		remoteAddr:    r.RemoteAddr,
+	}
+	if claims := auth.FromContext(r.Context()); claims != nil {
+		client.actor = claims.Subject
+		client.role = string(claims.Role)
 	}
 
-	sessionID := r.URL.Query().Get("session_id")
-	if sessionID == "" {
-		sessionID = "default"
+	// Начальную подписку проверяем до upgrade, чтобы отказ был обычным HTTP ответом
+	if sessionID != "" {
+		if err := h.checkSubscribe([]string{sessionID}); err != nil {
+			status := http.StatusServiceUnavailable
+			if errors.Is(err, errInvalidSessionID) {
+				status = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
 	}
 
-	client := &Client{
-		hub:       h,
-		conn:      conn,
-		send:      make(chan []byte, 256),
-		sessionID: sessionID,
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		slog.Error("Failed to upgrade connection", logging.Err(err))
+		return
+	}
+	client.conn = conn
+
+	if sessionID != "" {
+		if err := client.audit(audit.ActionSubscribe, []string{sessionID}); err != nil {
+			// Запись в журнал потеряна - данные сессии не отдаем
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseTryAgainLater, errAuditUnavailable.Error()),
+				time.Now().Add(time.Second))
+			conn.Close()
+			return
+		}
 	}
 
-	client.hub.register <- client
+	h.registerClient(client)
 
 	// Запускаем горутины для клиента
 	go client.writePump()
 	go client.readPump()
 }
 
-// readPump обрабатывает входящие сообщения от клиента
+// readPump обрабатывает входящие управляющие сообщения от клиента
 func (c *Client) readPump() {
 	defer func() {
 		c.hub.unregister <- c
@@ -328,13 +561,135 @@ func (c *Client) readPump() {
 	}()
 
 	for {
-		_, _, err := c.conn.ReadMessage()
+		_, payload, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("[ERROR] WebSocket error: %v", err)
+				slog.Error("WebSocket error", logging.Err(err))
 			}
 			break
 		}
+
+		c.handleControlMessage(payload)
+	}
+}
+
+// handleControlMessage применяет subscribe/unsubscribe/list и отвечает списком подписок
+func (c *Client) handleControlMessage(payload []byte) {
+	var msg ControlMessage
+	reply := SubscriptionsMessage{Type: "subscriptions"}
+
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		reply.Error = "invalid control message"
+		reply.SessionIDs = c.hub.Subscriptions(c)
+		c.reply(reply)
+		return
+	}
+
+	switch msg.Action {
+	case ActionSubscribe:
+		// Подписка записывается в журнал до того, как клиент начнет получать данные
+		err := c.hub.checkSubscribe(msg.SessionIDs)
+		if err == nil {
+			err = c.audit(audit.ActionSubscribe, msg.SessionIDs)
+		}
+		if err != nil {
+			reply.Error = err.Error()
+			reply.SessionIDs = c.hub.Subscriptions(c)
+			slog.Warn("Client subscription rejected", "client", c.remoteAddr, logging.Err(err))
+			break
+		}
+		reply.SessionIDs = c.hub.Subscribe(c, msg.SessionIDs)
+		slog.Info("Client subscribed", "client", c.remoteAddr, "session_ids", msg.SessionIDs)
+	case ActionUnsubscribe:
+		reply.SessionIDs = c.hub.Unsubscribe(c, msg.SessionIDs)
+		c.audit(audit.ActionUnsubscribe, msg.SessionIDs)
+		slog.Info("Client unsubscribed", "client", c.remoteAddr, "session_ids", msg.SessionIDs)
+	case ActionList:
+		reply.SessionIDs = c.hub.Subscriptions(c)
+	default:
+		reply.Error = "unknown action: " + msg.Action
+		reply.SessionIDs = c.hub.Subscriptions(c)
+	}
+
+	c.reply(reply)
+}
+
+// checkSubscribe проверяет идентификаторы сессий и готовность журнала доступа:
+// пока журнал не сохраняет записи, подписки отклоняются, как и REST запросы
+func (h *Hub) checkSubscribe(sessionIDs []string) error {
+	for _, sessionID := range sessionIDs {
+		if !validSessionID(sessionID) {
+			return errInvalidSessionID
+		}
+	}
+	if h.auditor != nil && h.auditor.Err() != nil {
+		return errAuditUnavailable
+	}
+	return nil
+}
+
+// validSessionID - непустой идентификатор не длиннее sessions.id из печатных символов
+func validSessionID(sessionID string) bool {
+	if sessionID == "" || !utf8.ValidString(sessionID) || utf8.RuneCountInString(sessionID) > maxSessionIDLength {
+		return false
+	}
+	for _, r := range sessionID {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// audit записывает подписку или отписку клиента по каждой сессии в журнал доступа.
+// Ошибка - хотя бы одна запись потеряна
+func (c *Client) audit(action string, sessionIDs []string) error {
+	if c.hub.auditor == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), auditTimeout)
+	defer cancel()
+
+	for _, sessionID := range sessionIDs {
+		if sessionID == "" {
+			continue
+		}
+		err := c.hub.auditor.Record(ctx, &audit.Entry{
+			Actor:      c.actor,
+			Role:       c.role,
+			Action:     action,
+			SessionID:  sessionID,
+			Resource:   "/ws",
+			Outcome:    audit.OutcomeSuccess,
+			RemoteAddr: c.remoteAddr,
+		})
+		if err != nil {
+			return errAuditUnavailable
+		}
+	}
+	return nil
+}
+
+// reply отправляет ответ на управляющее сообщение только этому клиенту
+func (c *Client) reply(reply SubscriptionsMessage) {
+	data, err := json.Marshal(reply)
+	if err != nil {
+		slog.Error("Failed to marshal control reply", logging.Err(err))
+		return
+	}
+
+	c.hub.mu.RLock()
+	defer c.hub.mu.RUnlock()
+
+	// Клиент мог быть уже отключен и его канал закрыт
+	if _, ok := c.hub.clients[c]; !ok {
+		return
+	}
+	select {
+	case c.send <- data:
+	default:
+		slog.Warn("Client send buffer full, dropping control reply", "client", c.remoteAddr)
 	}
 }
 
@@ -351,7 +706,7 @@ func (c *Client) writePump() {
 			}
 
 			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
-				log.Printf("[ERROR] Failed to write message: %v", err)
+				slog.Error("Failed to write message", logging.Err(err))
 				return
 			}
 		}
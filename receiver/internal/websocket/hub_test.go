@@ -0,0 +1,261 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Krimson/fetal-monitory/audit"
+)
+
+// newTestClient регистрирует в хабе клиента без сетевого соединения
+func newTestClient(h *Hub, sessionID string) *Client {
+	client := &Client{
+		hub:           h,
+		send:          make(chan []byte, 16),
+		sessionID:     sessionID,
+		subscriptions: make(map[string]bool),
+		remoteAddr:    "test",
+	}
+	h.registerClient(client)
+	return client
+}
+
+// startHub запускает цикл рассылки хаба в фоне
+func startHub(t *testing.T) *Hub {
+	t.Helper()
+	h := NewHub()
+	go h.Run()
+	return h
+}
+
+// expectMessage ждет сообщение в канале клиента
+func expectMessage(t *testing.T, c *Client, want string) {
+	t.Helper()
+	select {
+	case got := <-c.send:
+		if string(got) != want {
+			t.Fatalf("got message %q, want %q", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for %q", want)
+	}
+}
+
+// expectNoMessage проверяет, что клиенту ничего не пришло
+func expectNoMessage(t *testing.T, c *Client) {
+	t.Helper()
+	select {
+	case got := <-c.send:
+		t.Fatalf("unexpected message %q", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestHub_InitialSubscriptionFromQuery(t *testing.T) {
+	h := NewHub()
+	c := newTestClient(h, "bed-1")
+
+	if got := h.Subscriptions(c); !reflect.DeepEqual(got, []string{"bed-1"}) {
+		t.Fatalf("subscriptions = %v, want [bed-1]", got)
+	}
+	if h.SubscriberCount("bed-1") != 1 {
+		t.Fatalf("subscriber count = %d, want 1", h.SubscriberCount("bed-1"))
+	}
+}
+
+func TestHub_SubscribeUnsubscribe(t *testing.T) {
+	h := NewHub()
+	c := newTestClient(h, "")
+
+	got := h.Subscribe(c, []string{"bed-2", "bed-1", ""})
+	if !reflect.DeepEqual(got, []string{"bed-1", "bed-2"}) {
+		t.Fatalf("after subscribe = %v, want [bed-1 bed-2]", got)
+	}
+
+	got = h.Unsubscribe(c, []string{"bed-2", "unknown"})
+	if !reflect.DeepEqual(got, []string{"bed-1"}) {
+		t.Fatalf("after unsubscribe = %v, want [bed-1]", got)
+	}
+	if h.SubscriberCount("bed-2") != 0 {
+		t.Fatalf("bed-2 still has %d subscribers", h.SubscriberCount("bed-2"))
+	}
+}
+
+func TestHub_SubscribeUnknownClient(t *testing.T) {
+	h := NewHub()
+	c := &Client{hub: h, send: make(chan []byte, 1), subscriptions: make(map[string]bool)}
+
+	if got := h.Subscribe(c, []string{"bed-1"}); got != nil {
+		t.Fatalf("unregistered client subscribed: %v", got)
+	}
+	if h.SubscriberCount("bed-1") != 0 {
+		t.Fatal("unregistered client must not appear among subscribers")
+	}
+}
+
+func TestHub_FanOutToSubscribersOnly(t *testing.T) {
+	h := startHub(t)
+	a := newTestClient(h, "bed-1")
+	b := newTestClient(h, "bed-1")
+	other := newTestClient(h, "bed-2")
+	idle := newTestClient(h, "")
+
+	h.BroadcastToSession("bed-1", []byte("fhr-1"))
+
+	expectMessage(t, a, "fhr-1")
+	expectMessage(t, b, "fhr-1")
+	expectNoMessage(t, other)
+	expectNoMessage(t, idle)
+}
+
+func TestHub_MultiSessionClient(t *testing.T) {
+	h := startHub(t)
+	ward := newTestClient(h, "")
+	h.Subscribe(ward, []string{"bed-1", "bed-2"})
+	single := newTestClient(h, "bed-2")
+
+	h.BroadcastToSession("bed-1", []byte("one"))
+	expectMessage(t, ward, "one")
+	expectNoMessage(t, single)
+
+	h.BroadcastToSession("bed-2", []byte("two"))
+	expectMessage(t, ward, "two")
+	expectMessage(t, single, "two")
+}
+
+func TestHub_NoMessagesAfterUnsubscribe(t *testing.T) {
+	h := startHub(t)
+	c := newTestClient(h, "bed-1")
+
+	h.BroadcastToSession("bed-1", []byte("before"))
+	expectMessage(t, c, "before")
+
+	h.Unsubscribe(c, []string{"bed-1"})
+	h.BroadcastToSession("bed-1", []byte("after"))
+	expectNoMessage(t, c)
+}
+
+func TestHub_UnregisterRemovesSubscriptions(t *testing.T) {
+	h := startHub(t)
+	c := newTestClient(h, "bed-1")
+	h.Subscribe(c, []string{"bed-2"})
+
+	h.unregister <- c
+
+	deadline := time.Now().Add(time.Second)
+	for h.ClientCount() != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if h.ClientCount() != 0 {
+		t.Fatal("client was not unregistered")
+	}
+	if h.SubscriberCount("bed-1") != 0 || h.SubscriberCount("bed-2") != 0 {
+		t.Fatal("subscriptions of unregistered client were not removed")
+	}
+	if _, ok := <-c.send; ok {
+		t.Fatal("send channel of unregistered client must be closed")
+	}
+}
+
+// fakeAuditor - журнал доступа в памяти; err делает его недоступным, recordErr теряет записи
+type fakeAuditor struct {
+	mu        sync.Mutex
+	entries   []*audit.Entry
+	err       error
+	recordErr error
+}
+
+func (a *fakeAuditor) Record(ctx context.Context, e *audit.Entry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.recordErr != nil {
+		return a.recordErr
+	}
+	a.entries = append(a.entries, e)
+	return nil
+}
+
+func (a *fakeAuditor) Err() error {
+	return a.err
+}
+
+// controlReply отправляет управляющее сообщение и возвращает ответ клиенту
+func controlReply(t *testing.T, c *Client, msg ControlMessage) SubscriptionsMessage {
+	t.Helper()
+	payload, _ := json.Marshal(msg)
+	c.handleControlMessage(payload)
+
+	var reply SubscriptionsMessage
+	select {
+	case data := <-c.send:
+		if err := json.Unmarshal(data, &reply); err != nil {
+			t.Fatalf("invalid reply %q: %v", data, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for reply")
+	}
+	return reply
+}
+
+func TestHub_SubscribeFailsClosedWithoutAudit(t *testing.T) {
+	tests := []struct {
+		name      string
+		auditor   *fakeAuditor
+		sessionID string
+		wantErr   error
+	}{
+		{"audit available", &fakeAuditor{}, "bed-1", nil},
+		{"audit unavailable", &fakeAuditor{err: errors.New("connection refused")}, "bed-1", errAuditUnavailable},
+		{"audit entry lost", &fakeAuditor{recordErr: errors.New("queue full")}, "bed-1", errAuditUnavailable},
+		{"oversized session id", &fakeAuditor{}, strings.Repeat("x", maxSessionIDLength+1), errInvalidSessionID},
+		{"control characters", &fakeAuditor{}, "bed-1\n", errInvalidSessionID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHub()
+			h.SetAuditor(tt.auditor)
+			c := newTestClient(h, "")
+
+			reply := controlReply(t, c, ControlMessage{Action: ActionSubscribe, SessionIDs: []string{tt.sessionID}})
+
+			if tt.wantErr == nil {
+				if reply.Error != "" || h.SubscriberCount(tt.sessionID) != 1 || len(tt.auditor.entries) != 1 {
+					t.Fatalf("Expected audited subscription, got reply %+v, %d entries", reply, len(tt.auditor.entries))
+				}
+				return
+			}
+			if reply.Error != tt.wantErr.Error() || len(reply.SessionIDs) != 0 {
+				t.Errorf("Expected error %q and no subscriptions, got %+v", tt.wantErr, reply)
+			}
+			if h.SubscriberCount(tt.sessionID) != 0 {
+				t.Error("Rejected subscription must not receive session data")
+			}
+		})
+	}
+}
+
+func TestHub_HandleWebSocketRejectsInitialSubscription(t *testing.T) {
+	h := NewHub()
+	h.SetAuditor(&fakeAuditor{err: errors.New("connection refused")})
+
+	rec := httptest.NewRecorder()
+	h.HandleWebSocket(rec, httptest.NewRequest("GET", "/ws?session_id=bed-1", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 while audit log is unavailable, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.HandleWebSocket(rec, httptest.NewRequest("GET", "/ws?session_id="+strings.Repeat("x", 100), nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for oversized session id, got %d", rec.Code)
+	}
+}